@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/db"
+	"github.com/waldirborbajr/sync/processor"
+	"github.com/waldirborbajr/sync/run"
+	"github.com/waldirborbajr/sync/state"
+)
+
+// backfillUsage documents the backfill subcommand
+const backfillUsage = "backfill TABLE --from DATE --to DATE [--chunk 7d] [--restart]"
+
+// backfillArgs are the arguments of "sync backfill"
+type backfillArgs struct {
+	table    string
+	from, to time.Time
+	chunk    string
+	restart  bool
+}
+
+// backfillSummary is the output of "sync backfill"
+type backfillSummary struct {
+	Table       string        `json:"table" yaml:"table"`
+	Target      string        `json:"target" yaml:"target"`
+	From        time.Time     `json:"from" yaml:"from"`
+	To          time.Time     `json:"to" yaml:"to"`
+	Chunk       string        `json:"chunk" yaml:"chunk"`
+	ResumedFrom time.Time     `json:"resumed_from,omitzero" yaml:"resumed_from,omitempty"` // Checkpoint of an interrupted backfill
+	Chunks      int           `json:"chunks" yaml:"chunks"`
+	Rows        int           `json:"rows" yaml:"rows"`
+	Inserted    int           `json:"inserted" yaml:"inserted"`
+	Updated     int           `json:"updated" yaml:"updated"`
+	Unchanged   int           `json:"unchanged" yaml:"unchanged"`
+	NullKeys    int           `json:"null_keys" yaml:"null_keys"`
+	Duration    time.Duration `json:"duration" yaml:"duration"`
+}
+
+// backfillCommand populates a SYNC_TABLES target from Firebird history: the
+// table's backfill query is run for each chunk of the range in turn, and a
+// checkpoint saved after each one, so an interrupted backfill run again with
+// the same range resumes after the last chunk written.
+func backfillCommand(env *commandEnv) int {
+	args, err := parseBackfillArgs(env.args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\nusage: sync %s\n", redBold, reset, err, backfillUsage)
+		return 2
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+		return exitConfig
+	}
+	var mapping config.TableMapping
+	for _, m := range cfg.Tables {
+		if m.Name == args.table {
+			mapping = m
+		}
+	}
+	if mapping.Name == "" {
+		fmt.Fprintf(os.Stderr, "%sError:%s %s is not listed in SYNC_TABLES\n", redBold, reset, args.table)
+		return 2
+	}
+	if mapping.BackfillQuery == "" {
+		fmt.Fprintf(os.Stderr, "%sError:%s %s is not set\n", redBold, reset, config.TableKey(mapping.Name, "BACKFILL_QUERY"))
+		return 2
+	}
+	if err := db.CheckWritable(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v - nothing can be backfilled\n", redBold, reset, err)
+		return 1
+	}
+
+	st, err := state.Load(cfg.StateFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+		return 1
+	}
+	summary := backfillSummary{Table: mapping.Name, Target: mapping.Target(), From: args.from, To: args.to, Chunk: args.chunk}
+	start := args.from
+	if cp, ok := st.Backfills[mapping.Name]; ok && !args.restart {
+		if !cp.From.Equal(args.from) || !cp.To.Equal(args.to) || cp.Chunk != args.chunk {
+			fmt.Fprintf(os.Stderr, "%sError:%s the backfill of %s from %s to %s by %s is unfinished; run it again to resume it, or pass --restart\n",
+				redBold, reset, mapping.Name, cp.From.Format(time.DateOnly), cp.To.Format(time.DateOnly), cp.Chunk)
+			return 1
+		}
+		start, summary.ResumedFrom = cp.Done, cp.Done
+	}
+
+	firebirdConn, err := db.ConnectFirebird(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+		return 1
+	}
+	defer func() { _ = firebirdConn.Close() }()
+	mysqlConn, err := db.ConnectMySQL(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+		return 1
+	}
+	defer func() { _ = mysqlConn.Close() }()
+
+	began := time.Now()
+	ctx := run.WithID(context.Background(), run.NewID())
+	for from := start; from.Before(args.to); {
+		to := nextChunk(from, args.chunk)
+		if to.After(args.to) {
+			to = args.to
+		}
+		ts, err := processor.BackfillTable(ctx, firebirdConn, mysqlConn, mapping, from, to, cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%sError:%s chunk %s to %s: %v\n", redBold, reset, from.Format(time.RFC3339), to.Format(time.RFC3339), err)
+			if from.After(args.from) {
+				fmt.Fprintf(os.Stderr, "Rows before %s are written; run the same command again to resume\n", from.Format(time.RFC3339))
+			}
+			return 1
+		}
+
+		if _, err := state.Update(cfg.StateFile, func(s *state.State) {
+			if s.Backfills == nil {
+				s.Backfills = make(map[string]state.Backfill)
+			}
+			s.Backfills[mapping.Name] = state.Backfill{From: args.from, To: args.to, Chunk: args.chunk, Done: to}
+		}); err != nil {
+			fmt.Fprintf(os.Stderr, "%sError:%s error saving the backfill checkpoint: %v\n", redBold, reset, err)
+			return 1
+		}
+		fmt.Fprintf(os.Stderr, "%s to %s: %d rows, %d inserted, %d updated, %d unchanged\n",
+			from.Format(time.DateTime), to.Format(time.DateTime), ts.Rows, ts.Inserted, ts.Updated, ts.Ignored)
+
+		summary.Chunks++
+		summary.Rows += ts.Rows
+		summary.Inserted += ts.Inserted
+		summary.Updated += ts.Updated
+		summary.Unchanged += ts.Ignored
+		summary.NullKeys += ts.NullKeys
+		from = to
+	}
+
+	if _, err := state.Update(cfg.StateFile, func(s *state.State) { delete(s.Backfills, mapping.Name) }); err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s error removing the backfill checkpoint: %v\n", redBold, reset, err)
+		return 1
+	}
+	summary.Duration = time.Since(began).Round(time.Millisecond)
+	return env.render(summary)
+}
+
+// parseBackfillArgs reads the table and flags of "sync backfill"
+func parseBackfillArgs(args []string) (backfillArgs, error) {
+	b := backfillArgs{chunk: "7d"}
+	for i := 0; i < len(args); i++ {
+		name, value, inline := strings.Cut(args[i], "=")
+		switch name {
+		case "--restart":
+			b.restart = true
+			continue
+		case "--from", "--to", "--chunk":
+		default:
+			if strings.HasPrefix(args[i], "-") {
+				return b, fmt.Errorf("unknown flag %q", args[i])
+			}
+			if b.table != "" {
+				return b, fmt.Errorf("unexpected argument %q", args[i])
+			}
+			b.table = strings.ToUpper(args[i])
+			continue
+		}
+		if !inline {
+			if i+1 >= len(args) {
+				return b, fmt.Errorf("%s requires a value", name)
+			}
+			i++
+			value = args[i]
+		}
+
+		if name == "--chunk" {
+			if _, err := parseChunk(value); err != nil {
+				return b, err
+			}
+			b.chunk = value
+			continue
+		}
+		t, err := parseTimeArg(value)
+		if err != nil {
+			return b, fmt.Errorf("invalid %s %q: expected e.g. 2024-01-01 or 2024-01-01T12:00:00-03:00", name, value)
+		}
+		if name == "--from" {
+			b.from = t
+		} else {
+			b.to = t
+		}
+	}
+	switch {
+	case b.table == "":
+		return b, fmt.Errorf("no table given")
+	case b.from.IsZero() || b.to.IsZero():
+		return b, fmt.Errorf("--from and --to are required")
+	case !b.from.Before(b.to):
+		return b, fmt.Errorf("--from must be before --to")
+	}
+	return b, nil
+}
+
+// chunkLength is the length of a backfill chunk: calendar days, so chunks
+// keep starting at midnight across daylight saving changes, or a duration
+type chunkLength struct {
+	days     int
+	duration time.Duration
+}
+
+// parseChunk parses a --chunk value, a number of days such as "7d" or a
+// duration such as "12h"
+func parseChunk(s string) (chunkLength, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return chunkLength{}, fmt.Errorf("invalid --chunk %q: expected a positive number of days (7d) or a duration (12h)", s)
+		}
+		return chunkLength{days: n}, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return chunkLength{}, fmt.Errorf("invalid --chunk %q: expected a positive number of days (7d) or a duration (12h)", s)
+	}
+	return chunkLength{duration: d}, nil
+}
+
+// nextChunk returns the end of the chunk starting at from; chunk is valid
+func nextChunk(from time.Time, chunk string) time.Time {
+	c, _ := parseChunk(chunk)
+	if c.days > 0 {
+		return from.AddDate(0, 0, c.days)
+	}
+	return from.Add(c.duration)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/db"
+	"github.com/waldirborbajr/sync/flags"
+	"github.com/waldirborbajr/sync/processor"
+)
+
+// benchUsage documents the bench subcommand
+const benchUsage = "bench [--strategy map-preload|streaming]..."
+
+// Comparison strategies of "sync bench"
+const (
+	strategyMapPreload = "map-preload" // Stored rows kept in memory column by column (MYSQL_PRELOAD=columns)
+	strategyStreaming  = "streaming"   // Only a hash per stored row (MYSQL_PRELOAD=hash)
+)
+
+// benchStrategies lists the strategies in the order they run by default
+var benchStrategies = []string{strategyMapPreload, strategyStreaming}
+
+// benchInfo is a row of "sync bench"
+type benchInfo struct {
+	Strategy  string        `json:"strategy" yaml:"strategy"`
+	Records   int           `json:"records" yaml:"records"`
+	Load      time.Duration `json:"load" yaml:"load"`
+	Compare   time.Duration `json:"compare" yaml:"compare"`
+	HeapMB    float64       `json:"heap_mb" yaml:"heap_mb"`   // Held by the loaded rows
+	AllocMB   float64       `json:"alloc_mb" yaml:"alloc_mb"` // Allocated loading and comparing
+	Inserts   int           `json:"inserts" yaml:"inserts"`
+	Updates   int           `json:"updates" yaml:"updates"`
+	Unchanged int           `json:"unchanged" yaml:"unchanged"`
+}
+
+// benchCommand compares the stored rows with each strategy without writing
+// and prints how long each took and how much memory it needed, to choose
+// MYSQL_PRELOAD for a deployment
+func benchCommand(env *commandEnv) int {
+	strategies, err := parseStrategies(env.args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\nusage: sync %s\n", redBold, reset, err, benchUsage)
+		return 2
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+		return exitConfig
+	}
+	cfgs := make([]config.Config, 0, len(strategies))
+	for _, s := range strategies {
+		c, err := cfg.WithFlags(cfg.FeatureFlags.Merge(flags.Set{flags.StreamingCompare: s == strategyStreaming}))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%sError:%s strategy %s: %v\n", redBold, reset, s, err)
+			return 1
+		}
+		cfgs = append(cfgs, c)
+	}
+
+	firebirdConn, err := db.ConnectFirebird(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+		return 1
+	}
+	defer func() { _ = firebirdConn.Close() }()
+	mysqlConn, err := db.ConnectMySQL(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+		return 1
+	}
+	defer func() { _ = mysqlConn.Close() }()
+
+	results, err := processor.Bench(context.Background(), firebirdConn, mysqlConn, cfgs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+		return 1
+	}
+	rows := make([]benchInfo, len(results))
+	for i, r := range results {
+		rows[i] = benchInfo{
+			Strategy:  strategies[i],
+			Records:   r.Records,
+			Load:      r.LoadTime.Round(time.Millisecond),
+			Compare:   r.CompareTime.Round(time.Millisecond),
+			HeapMB:    megabytes(r.HeapBytes),
+			AllocMB:   megabytes(r.AllocBytes),
+			Inserts:   r.Inserts,
+			Updates:   r.Updates,
+			Unchanged: r.Unchanged,
+		}
+	}
+	return env.render(rows)
+}
+
+// parseStrategies reads the --strategy flags of args, every strategy when there are none
+func parseStrategies(args []string) ([]string, error) {
+	var strategies []string
+	for i := 0; i < len(args); i++ {
+		var s string
+		switch arg := args[i]; {
+		case arg == "--strategy":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("--strategy requires a value (%s)", strings.Join(benchStrategies, " or "))
+			}
+			i++
+			s = args[i]
+		case strings.HasPrefix(arg, "--strategy="):
+			s = strings.TrimPrefix(arg, "--strategy=")
+		default:
+			return nil, fmt.Errorf("unexpected argument %q", arg)
+		}
+		if !slices.Contains(benchStrategies, s) {
+			return nil, fmt.Errorf("unknown strategy %q (expected %s)", s, strings.Join(benchStrategies, " or "))
+		}
+		if !slices.Contains(strategies, s) {
+			strategies = append(strategies, s)
+		}
+	}
+	if len(strategies) == 0 {
+		return benchStrategies, nil
+	}
+	return strategies, nil
+}
+
+// megabytes converts bytes to megabytes rounded to two decimals
+func megabytes(b uint64) float64 {
+	return math.Round(float64(b)/(1024*1024)*100) / 100
+}
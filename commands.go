@@ -0,0 +1,575 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/db"
+	"github.com/waldirborbajr/sync/logger"
+	"github.com/waldirborbajr/sync/output"
+	"github.com/waldirborbajr/sync/preflight"
+	"github.com/waldirborbajr/sync/state"
+	"github.com/waldirborbajr/sync/updater"
+)
+
+// commandEnv is what every subcommand receives
+type commandEnv struct {
+	cfg    config.Config
+	args   []string
+	output string // output.FormatTable, output.FormatJSON, output.FormatYAML or output.FormatCSV
+}
+
+// render prints v in the selected output format
+func (e *commandEnv) render(v any) int {
+	if err := output.Render(os.Stdout, e.output, v); err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+		return 1
+	}
+	return 0
+}
+
+// command is a subcommand invoked as "sync <name> [args]"
+type command struct {
+	usage       string
+	summary     string
+	examples    []string
+	subcommands []string // First-argument words offered by shell completion
+	run         func(env *commandEnv) int
+	logs        bool // Keep info logging, for commands that run syncs
+}
+
+// maintenanceUsage documents the maintenance subcommand
+const maintenanceUsage = "maintenance on [reason] | off | status"
+
+// commands lists the available subcommands; without one, sync runs once.
+// It is filled in init because man refers back to it.
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"run": {
+			usage:    "run",
+			summary:  "Run one synchronization, as sync does without a command",
+			examples: []string{"sync run", "sync", "sync --tui", "sync run -o json > report.json"},
+			run:      runCommand,
+			logs:     true,
+		},
+		"maintenance": {
+			usage:       maintenanceUsage,
+			summary:     "Pause or resume synchronization runs while the databases are being maintained",
+			examples:    []string{"sync maintenance on reindexing TB_ESTOQUE", "sync maintenance status -o json", "sync maintenance off"},
+			subcommands: []string{"on", "off", "status"},
+			run:         maintenanceCommand,
+		},
+		"version": {
+			usage:    "version",
+			summary:  "Show the version, Go runtime, platform and machine ID",
+			examples: []string{"sync version", "sync version -o yaml"},
+			run:      versionCommand,
+		},
+		"check": {
+			usage:    "check",
+			summary:  "Check whether a newer release is available, without downloading it",
+			examples: []string{"sync check", "sync check -o json"},
+			run:      checkCommand,
+		},
+		"update": {
+			usage:    "update",
+			summary:  "Download and install the latest release now, whatever AUTO_UPDATE says, once the runs in progress end (UPDATE_ACTIVE_RUNS)",
+			examples: []string{"sync update", "sync update -o json", "sync update --update-active-runs cancel --update-active-runs-wait 5m"},
+			run:      updateCommand,
+			logs:     true,
+		},
+		"config": {
+			usage:       configUsage,
+			summary:     "Show the effective configuration and where each value came from, validate it without syncing, or rename its deprecated settings",
+			examples:    []string{"sync config show", "sync config show -o json", "sync config validate", "sync config validate --reachable", "sync config migrate --dry-run", "sync config deprecations"},
+			subcommands: []string{"show", "validate", "migrate", "deprecations"},
+			run:         configCommand,
+		},
+		"init": {
+			usage:    initUsage,
+			summary:  "Write a .env with every setting, its default and documentation, asking for the connections and margins on a terminal",
+			examples: []string{"sync init", "sync init --mysql-host shop-db --lucro 35 --defaults", "sync init --file /etc/sync/.env --force"},
+			run:      initCommand,
+		},
+		"doctor": {
+			usage:    "doctor",
+			summary:  "Check the databases, tables, columns, procedures, privileges and max_allowed_packet a run needs, with tips to fix them",
+			examples: []string{"sync doctor", "sync doctor -o json"},
+			run:      doctorCommand,
+		},
+		"daemon": {
+			usage:    daemonUsage,
+			summary:  "Run the SYNC_JOBS on their cron schedules, or the sync every interval, until interrupted",
+			examples: []string{"sync daemon", "sync daemon --every 5m"},
+			run:      daemonCommand,
+			logs:     true,
+		},
+		"schedule": {
+			usage:       "schedule list",
+			summary:     "List the daemon jobs, what they sync and when they run next",
+			examples:    []string{"sync schedule list", "sync schedule list -o json"},
+			subcommands: []string{"list"},
+			run:         scheduleCommand,
+		},
+		"bench": {
+			usage:    benchUsage,
+			summary:  "Compare the stored rows with each preload strategy, without writing, and show their time and memory",
+			examples: []string{"sync bench", "sync bench --strategy map-preload --strategy streaming", "sync bench -o json"},
+			run:      benchCommand,
+		},
+		"verify": {
+			usage:    verifyUsage,
+			summary:  "Compare Firebird with MySQL without writing and report the rows out of sync",
+			examples: []string{"sync verify", "sync verify --tolerance 0.01 --max-drift 10", "sync verify -o json > drift.json"},
+			run:      verifyCommand,
+		},
+		"trace": {
+			usage:    traceUsage,
+			summary:  "Show everything known about one product: values on both sides, what a run would write and why, and its recorded changes",
+			examples: []string{"sync trace --id 17973", "sync trace --id 17973 -o json"},
+			run:      traceCommand,
+		},
+		"report": {
+			usage:       reportUsage,
+			summary:     "Compare what two runs changed: rows, quantities, sale prices and inventory value, from TB_ESTOQUE_SYNC_AUDIT",
+			examples:    []string{"sync report compare --run 20260901T030000Z-3f1c9a2e --run 20261001T030000Z-8b04d7c1", "sync report compare --run 20260901T030000Z-3f1c9a2e --run 20261001T030000Z-8b04d7c1 -o csv > deck.csv"},
+			subcommands: []string{"compare"},
+			run:         reportCommand,
+		},
+		"state": {
+			usage:       stateUsage,
+			summary:     "Check the state file and state tables for corruption, repair them or reset parts of them",
+			examples:    []string{"sync state verify", "sync state verify --repair", "sync state reset watermarks", "sync state reset --force locks"},
+			subcommands: []string{"verify", "reset"},
+			run:         stateCommand,
+		},
+		"replay": {
+			usage:    replayUsage,
+			summary:  "Execute the transactions of REPLAY_LOG_FILE logs against MySQL, e.g. to bring a restored backup up to a point in time",
+			examples: []string{"sync replay --since \"2026-10-16 03:00:00\" --until \"2026-10-16 13:45:00\" logs/replay*.sql*", "sync replay --dry-run -o json logs/replay.sql"},
+			run:      replayCommand,
+		},
+		"backfill": {
+			usage:    backfillUsage,
+			summary:  "Sync the history of a SYNC_TABLES table date range by date range, resuming where an interrupted backfill stopped",
+			examples: []string{"sync backfill TB_PEDIDO --from 2024-01-01 --to 2024-06-30 --chunk 7d", "sync backfill TB_PEDIDO --from 2024-01-01 --to 2024-06-30 --chunk 12h --restart"},
+			run:      backfillCommand,
+			logs:     true,
+		},
+		"man": {
+			usage:    "man",
+			summary:  "Print the manual page in roff format",
+			examples: []string{"sync man > /usr/local/share/man/man1/sync.1", "sync man | man -l -"},
+			run:      manCommand,
+		},
+	}
+}
+
+// defaultCommand is the command run when none is named
+const defaultCommand = "run"
+
+// dispatchCommand runs the subcommand named in args, the default one when
+// there is none, and returns the process exit code
+func dispatchCommand(args []string) int {
+	format := output.FormatTable
+	// Completion requests carry the command line being typed as is
+	if len(args) == 0 || !strings.HasPrefix(args[0], cobra.ShellCompRequestCmd) {
+		var err error
+		if format, args, err = parseOutputFlag(args); err != nil {
+			fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+			return 2
+		}
+		// Settings given as flags override the .env file for this invocation
+		if args, err = config.ApplyFlags(args); err != nil {
+			fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+			return 2
+		}
+	}
+
+	var code int
+	root := newRootCommand(format, &code)
+	// Never nil: cobra would read os.Args again, settings flags included
+	root.SetArgs(append([]string{}, args...))
+	if err := root.Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+		return 2
+	}
+	return code
+}
+
+// newRootCommand builds the command line from the command table; cobra
+// generates the help, the help command and the completion scripts. The
+// commands parse their own arguments, so only the root parses flags. The
+// exit code of the command run is stored in code.
+func newRootCommand(format string, code *int) *cobra.Command {
+	root := &cobra.Command{
+		Use:           "sync",
+		Short:         "Synchronize Firebird products, stock and prices into MySQL",
+		Long:          rootHelp(),
+		Example:       "  sync\n  sync --mysql-host staging-db --lucro 35 run\n  sync help maintenance",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+	}
+	root.PersistentFlags().StringP("output", "o", output.FormatTable, "output format of informational commands: table, json, yaml or csv")
+	_ = root.RegisterFlagCompletionFunc("output", completeOutput)
+
+	for _, name := range commandNames() {
+		c := commands[name]
+		sub := &cobra.Command{
+			Use:                c.usage,
+			Short:              c.summary,
+			Example:            "  " + strings.Join(c.examples, "\n  "),
+			DisableFlagParsing: true,
+			ValidArgsFunction:  completeArgs(c.subcommands),
+		}
+		sub.Run = func(cmd *cobra.Command, args []string) {
+			*code = runTableCommand(cmd, c, args, format)
+		}
+		root.AddCommand(sub)
+		if name == defaultCommand {
+			root.Run = sub.Run
+		}
+	}
+	return root
+}
+
+// runTableCommand runs c, invoked as cmd, with args, the help when they ask for it
+func runTableCommand(cmd *cobra.Command, c command, args []string, format string) int {
+	if slices.Contains(args, "-h") || slices.Contains(args, "--help") {
+		_ = cmd.Help()
+		return 0
+	}
+
+	// Commands print their own output; only errors are logged
+	if !c.logs {
+		logger.SetLevel(zerolog.ErrorLevel)
+	}
+
+	cfg, _ := config.LoadUpdateConfig()
+	return c.run(&commandEnv{cfg: cfg, args: args, output: format})
+}
+
+// parseOutputFlag extracts --output/-o (table, json, yaml or csv) from anywhere in args
+func parseOutputFlag(args []string) (format string, rest []string, err error) {
+	format = output.FormatTable
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--output" || arg == "-o":
+			if i+1 >= len(args) {
+				return "", nil, fmt.Errorf("%s requires a value (table, json, yaml or csv)", arg)
+			}
+			i++
+			format = args[i]
+		case strings.HasPrefix(arg, "--output="):
+			format = strings.TrimPrefix(arg, "--output=")
+		default:
+			rest = append(rest, arg)
+			continue
+		}
+		if !output.ValidFormat(format) {
+			return "", nil, fmt.Errorf("unsupported output format %q (expected table, json, yaml or csv)", format)
+		}
+	}
+	return format, rest, nil
+}
+
+// rootHelp is the description shown by "sync help"
+func rootHelp() string {
+	var b strings.Builder
+	b.WriteString("Without a command, a single synchronization run is executed.\n\n")
+	b.WriteString("Any setting can be given as a flag, overriding the .env file for one\n")
+	b.WriteString("invocation: --mysql-host staging-db --lucro 35 --debug\n\n")
+	b.WriteString("Exit statuses:\n")
+	for _, s := range exitStatuses {
+		fmt.Fprintf(&b, "  %-3d %s\n", s.code, s.meaning)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// commandNames returns the subcommand names in alphabetical order
+func commandNames() []string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// maintenanceInfo is the output of "sync maintenance"
+type maintenanceInfo struct {
+	Maintenance bool      `json:"maintenance" yaml:"maintenance"`
+	Since       time.Time `json:"since,omitzero" yaml:"since,omitempty"`
+	Reason      string    `json:"reason,omitempty" yaml:"reason,omitempty"`
+}
+
+// maintenanceCommand toggles or shows the maintenance flag. While it is on,
+// runs are skipped so DBAs can do schema work without racing the sync.
+func maintenanceCommand(env *commandEnv) int {
+	args := env.args
+	if len(args) == 0 {
+		args = []string{"status"}
+	}
+
+	var (
+		st  state.State
+		err error
+	)
+	if args[0] == "on" || args[0] == "off" {
+		if err := db.CheckWritable(env.cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "%sError:%s %v - maintenance mode cannot be changed\n", redBold, reset, err)
+			return 1
+		}
+	}
+
+	switch args[0] {
+	case "on":
+		st, err = state.Update(env.cfg.StateFile, func(s *state.State) {
+			s.Maintenance = true
+			s.MaintenanceSince = time.Now()
+			s.MaintenanceReason = strings.Join(args[1:], " ")
+		})
+	case "off":
+		st, err = state.Update(env.cfg.StateFile, func(s *state.State) {
+			s.Maintenance = false
+			s.MaintenanceSince = time.Time{}
+			s.MaintenanceReason = ""
+		})
+	case "status":
+		st, err = state.Load(env.cfg.StateFile)
+	default:
+		fmt.Fprintf(os.Stderr, "usage: sync %s\n", maintenanceUsage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+		return 1
+	}
+
+	if env.output == output.FormatTable {
+		fmt.Println(maintenanceStatus(st))
+		return 0
+	}
+	return env.render(maintenanceInfo{Maintenance: st.Maintenance, Since: st.MaintenanceSince, Reason: st.MaintenanceReason})
+}
+
+// maintenanceStatus describes the maintenance flag for humans
+func maintenanceStatus(st state.State) string {
+	if !st.Maintenance {
+		return "Maintenance mode is off"
+	}
+	msg := "Maintenance mode is on since " + st.MaintenanceSince.Format(time.RFC3339)
+	if st.MaintenanceReason != "" {
+		msg += " (" + st.MaintenanceReason + ")"
+	}
+	return msg
+}
+
+// versionInfo is the output of "sync version"
+type versionInfo struct {
+	Version   string `json:"version" yaml:"version"`
+	GoVersion string `json:"go_version" yaml:"go_version"`
+	Platform  string `json:"platform" yaml:"platform"`
+	MachineID string `json:"machine_id,omitempty" yaml:"machine_id,omitempty"`
+}
+
+// versionCommand prints build and installation details
+func versionCommand(env *commandEnv) int {
+	info := versionInfo{
+		Version:   version,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	if st, err := state.Load(env.cfg.StateFile); err == nil {
+		info.MachineID = st.MachineID
+	}
+	return env.render(info)
+}
+
+// checkInfo is the output of "sync check"
+type checkInfo struct {
+	CurrentVersion  string `json:"current_version" yaml:"current_version"`
+	LatestVersion   string `json:"latest_version" yaml:"latest_version"`
+	UpdateAvailable bool   `json:"update_available" yaml:"update_available"`
+	DownloadURL     string `json:"download_url,omitempty" yaml:"download_url,omitempty"`
+
+	// Tables written, when SYNC_TABLES targets other schemas than MYSQL_DATABASE
+	Privileges []privilegeCheck `json:"privileges,omitempty" yaml:"privileges,omitempty"`
+}
+
+// privilegeCheck is what the MySQL user may not do on a table the sync writes
+type privilegeCheck struct {
+	Schema  string   `json:"schema" yaml:"schema"`
+	Table   string   `json:"table" yaml:"table"`
+	Missing []string `json:"missing,omitempty" yaml:"missing,omitempty"` // SELECT, INSERT or UPDATE
+	Error   string   `json:"error,omitempty" yaml:"error,omitempty"`     // First refusal
+}
+
+// String describes the check in one table cell
+func (c privilegeCheck) String() string {
+	if len(c.Missing) == 0 {
+		return c.Schema + "." + c.Table + ": ok"
+	}
+	return c.Schema + "." + c.Table + ": no " + strings.Join(c.Missing, "/")
+}
+
+// checkCommand reports whether a newer release is available without
+// downloading it and, when SYNC_TABLES writes to other schemas, whether the
+// MySQL user may read and write every target table, schema by schema
+func checkCommand(env *commandEnv) int {
+	available, info, err := updater.CheckForUpdateWithContext(context.Background(), version, env.cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+		return 1
+	}
+	result := checkInfo{
+		CurrentVersion:  version,
+		LatestVersion:   info.Version,
+		UpdateAvailable: available,
+		DownloadURL:     info.URL,
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil || !slices.ContainsFunc(cfg.Tables, func(m config.TableMapping) bool { return m.TargetSchema != "" }) {
+		return env.render(result)
+	}
+	if result.Privileges, err = checkPrivileges(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+		return 1
+	}
+	status := env.render(result)
+	for _, c := range result.Privileges {
+		if len(c.Missing) > 0 {
+			fmt.Fprintf(os.Stderr, "%sError:%s %s.%s cannot be read and written: %s\n", redBold, reset, c.Schema, c.Table, c.Error)
+			status = 1
+		}
+	}
+	return status
+}
+
+// checkPrivileges checks the privileges on TB_ESTOQUE and every SYNC_TABLES
+// target, ordered by schema
+func checkPrivileges(cfg config.Config) ([]privilegeCheck, error) {
+	conn, err := db.ConnectMySQL(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+	return tablePrivileges(conn, cfg), nil
+}
+
+// tablePrivileges checks the privileges of conn on TB_ESTOQUE and every
+// SYNC_TABLES target, ordered by schema
+func tablePrivileges(conn *sql.DB, cfg config.Config) []privilegeCheck {
+	targets := append([]config.TableMapping{{TargetTable: "TB_ESTOQUE", KeyColumns: []string{cfg.ProductColumn(config.ProductKey)}}}, cfg.Tables...)
+	checks := make([]privilegeCheck, 0, len(targets))
+	for _, m := range targets {
+		schema := m.TargetSchema
+		if schema == "" {
+			schema = cfg.MySQLDatabase
+		}
+		p := db.CheckTablePrivileges(context.Background(), conn, m.QuotedTarget(), config.QuoteIdentifier(m.KeyColumns[0]))
+		c := privilegeCheck{Schema: schema, Table: m.TargetTable, Missing: p.Missing()}
+		if p.Err != nil {
+			c.Error = p.Err.Error()
+		}
+		checks = append(checks, c)
+	}
+	sort.SliceStable(checks, func(i, j int) bool { return checks[i].Schema < checks[j].Schema })
+	return checks
+}
+
+// updateInfo is the output of "sync update"
+type updateInfo struct {
+	PreviousVersion string `json:"previous_version" yaml:"previous_version"`
+	LatestVersion   string `json:"latest_version" yaml:"latest_version"`
+	Installed       bool   `json:"installed" yaml:"installed"`
+	Download        string `json:"download,omitempty" yaml:"download,omitempty"` // File the release was downloaded to
+}
+
+// updateCommand downloads and installs the latest release when it is newer
+// than this one; the new binary is used from the next invocation. Runs in
+// progress hold the install back, or are cancelled, per UPDATE_ACTIVE_RUNS.
+func updateCommand(env *commandEnv) int {
+	if len(env.args) > 0 {
+		fmt.Fprintf(os.Stderr, "%sError:%s unexpected argument %q\nusage: sync update\n", redBold, reset, env.args[0])
+		return 2
+	}
+	ctx := context.Background()
+	available, latest, err := updater.CheckForUpdateWithContext(ctx, version, env.cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+		return 1
+	}
+	info := updateInfo{PreviousVersion: version, LatestVersion: latest.Version}
+	if !available {
+		return env.render(info)
+	}
+	if latest.URL == "" {
+		fmt.Fprintf(os.Stderr, "%sError:%s release %s has no download for this platform\n", redBold, reset, latest.Version)
+		return 1
+	}
+
+	if err := preflight.Check(env.cfg, preflight.Update(env.cfg)...); err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v - free some space before updating\n", redBold, reset, err)
+		return 1
+	}
+	if info.Download, err = updater.DownloadUpdateWithContext(ctx, latest.URL, env.cfg.UpdateDownloadDir); err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+		return 1
+	}
+	err = updater.InstallWhenIdle(ctx, runRegistry(env.cfg), info.Download, env.cfg.UpdateActiveRuns, env.cfg.UpdateActiveRunsWait)
+	var deferred *updater.DeferredError
+	if errors.As(err, &deferred) {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v (the release is kept as %s); retry once it ends, wait longer with --update-active-runs-wait or stop it with --update-active-runs cancel\n", redBold, reset, err, info.Download)
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v (the release is kept as %s)\n", redBold, reset, err, info.Download)
+		return 1
+	}
+	info.Installed = true
+	return env.render(info)
+}
+
+// configCommand prints the effective configuration with the source of each
+// value, or validates it
+func configCommand(env *commandEnv) int {
+	if len(env.args) > 0 {
+		switch env.args[0] {
+		case "validate":
+			return configValidateCommand(env)
+		case "migrate":
+			return configMigrateCommand(env)
+		case "deprecations":
+			if len(env.args) == 1 {
+				return env.render(config.Deprecations)
+			}
+		}
+	}
+	if len(env.args) != 1 || env.args[0] != "show" {
+		fmt.Fprintf(os.Stderr, "usage: sync %s\n", configUsage)
+		return 2
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+		return exitConfig
+	}
+	return env.render(config.Describe(cfg))
+}
@@ -0,0 +1,234 @@
+// Package compare holds the column comparators deciding whether a stored
+// value is current. Comparators are selected per column in the mapping
+// configuration by spec ("name" or "name=argument"); Register adds new ones.
+package compare
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Comparator reports whether the stored value already matches the current one
+type Comparator interface {
+	Equal(stored, current interface{}) bool
+}
+
+// Func adapts a function to Comparator
+type Func func(stored, current interface{}) bool
+
+// Equal calls f
+func (f Func) Equal(stored, current interface{}) bool {
+	return f(stored, current)
+}
+
+// Factory builds a comparator from the argument of its spec ("" when absent)
+type Factory func(arg string) (Comparator, error)
+
+var (
+	mu        sync.RWMutex
+	factories = map[string]Factory{}
+)
+
+// Built-in comparators
+var (
+	// Exact compares values, numbers by value: "10.50" equals 10.5
+	Exact Comparator = Func(Equal)
+	// StoredScale compares a computed number after rounding it to the decimal
+	// places of the stored value, so 12.345 matches the 12.35 of a DECIMAL(10,2)
+	StoredScale Comparator = Func(equalAtStoredScale)
+)
+
+func init() {
+	Register("exact", noArg("exact", Exact))
+	Register("scale", noArg("scale", StoredScale))
+	Register("casefold", noArg("casefold", Func(func(a, b interface{}) bool {
+		return nullsEqual(a, b) || a != nil && b != nil && strings.EqualFold(Format(a), Format(b))
+	})))
+	Register("trim", noArg("trim", Func(func(a, b interface{}) bool {
+		return nullsEqual(a, b) || a != nil && b != nil && strings.TrimSpace(Format(a)) == strings.TrimSpace(Format(b))
+	})))
+	Register("ignore", noArg("ignore", Func(func(a, b interface{}) bool { return true })))
+	Register("tolerance", tolerance)
+}
+
+// Register makes a comparator available under name, replacing any previous one
+func Register(name string, f Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	factories[name] = f
+}
+
+// Names returns the registered comparator names, sorted
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New builds the comparator of spec, e.g. "casefold" or "tolerance=0.01"
+func New(spec string) (Comparator, error) {
+	name, arg, _ := strings.Cut(strings.TrimSpace(spec), "=")
+	mu.RLock()
+	f, ok := factories[strings.ToLower(strings.TrimSpace(name))]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown comparator %q (available: %s)", name, strings.Join(Names(), ", "))
+	}
+	c, err := f(strings.TrimSpace(arg))
+	if err != nil {
+		return nil, fmt.Errorf("comparator %s: %w", name, err)
+	}
+	return c, nil
+}
+
+// noArg returns a factory for a comparator taking no argument
+func noArg(name string, c Comparator) Factory {
+	return func(arg string) (Comparator, error) {
+		if arg != "" {
+			return nil, fmt.Errorf("%s takes no argument", name)
+		}
+		return c, nil
+	}
+}
+
+// tolerance builds a comparator treating numbers within an absolute difference as equal
+func tolerance(arg string) (Comparator, error) {
+	tol, err := strconv.ParseFloat(arg, 64)
+	if err != nil || tol < 0 {
+		return nil, fmt.Errorf("expected a non-negative number, e.g. tolerance=0.01")
+	}
+	return Func(func(a, b interface{}) bool {
+		if Equal(a, b) {
+			return true
+		}
+		fa, okA := number(a)
+		fb, okB := number(b)
+		return okA && okB && math.Abs(fa-fb) <= tol+1e-9
+	}), nil
+}
+
+// Equal reports whether two values are the same. NULL (nil) only equals
+// NULL; numbers are compared by value whatever their type or notation.
+func Equal(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return nullsEqual(a, b)
+	}
+	if isComparable(a) && a == b {
+		return true
+	}
+	sa, sb := Format(a), Format(b)
+	if sa == sb {
+		return true
+	}
+	fa, errA := strconv.ParseFloat(sa, 64)
+	fb, errB := strconv.ParseFloat(sb, 64)
+	return errA == nil && errB == nil && fa == fb
+}
+
+// Format returns the textual form of a value
+func Format(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case time.Time:
+		return x.Format("2006-01-02 15:04:05")
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(x), 'f', -1, 32)
+	}
+	return fmt.Sprint(v)
+}
+
+// Key returns a form of v shared by all values Equal reports as the same, so
+// values can be compared by hash: numbers by value, NULL apart from "".
+func Key(v interface{}) string {
+	if v == nil {
+		return "\x00"
+	}
+	s := Format(v)
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return s
+}
+
+// Order compares a and b for sorting keys the way MySQL orders an index:
+// NULL first, numbers by value and before text, text byte-wise. It returns
+// -1, 0 or +1.
+func Order(a, b interface{}) int {
+	if a == nil || b == nil {
+		return cmp.Compare(boolRank(a != nil), boolRank(b != nil))
+	}
+	fa, na := number(a)
+	fb, nb := number(b)
+	switch {
+	case na && nb:
+		return cmp.Compare(fa, fb)
+	case na != nb:
+		return cmp.Compare(boolRank(!na), boolRank(!nb))
+	}
+	return strings.Compare(Format(a), Format(b))
+}
+
+// boolRank returns 1 for true, so false sorts first
+func boolRank(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// equalAtStoredScale implements StoredScale
+func equalAtStoredScale(stored, current interface{}) bool {
+	if Equal(stored, current) {
+		return true
+	}
+	f, ok := current.(float64)
+	if !ok || stored == nil {
+		return false
+	}
+	s := Format(stored)
+	if _, err := strconv.ParseFloat(s, 64); err != nil {
+		return false
+	}
+	scale := 0
+	if dot := strings.IndexByte(s, '.'); dot >= 0 {
+		scale = len(s) - dot - 1
+	}
+	pow := math.Pow(10, float64(scale))
+	return Equal(stored, math.Round(f*pow)/pow)
+}
+
+// nullsEqual reports whether both values are NULL
+func nullsEqual(a, b interface{}) bool {
+	return a == nil && b == nil
+}
+
+// number returns v as a float64 when it is numeric
+func number(v interface{}) (float64, bool) {
+	if f, ok := v.(float64); ok {
+		return f, true
+	}
+	f, err := strconv.ParseFloat(Format(v), 64)
+	return f, err == nil
+}
+
+// isComparable reports whether == can be used on v without panicking
+func isComparable(v interface{}) bool {
+	return reflect.TypeOf(v).Comparable()
+}
@@ -0,0 +1,112 @@
+package compare
+
+import (
+	"testing"
+
+	"github.com/waldirborbajr/sync/money"
+)
+
+func TestComparators(t *testing.T) {
+	tests := []struct {
+		spec    string
+		stored  interface{}
+		current interface{}
+		want    bool
+	}{
+		{"exact", "10.50", 10.5, true},
+		{"exact", int64(3), 3, true},
+		{"exact", money.Cents(1205), money.Cents(1205), true},
+		{"exact", "12.05", money.Cents(1205), true},
+		{"exact", nil, "", false},
+		{"exact", nil, nil, true},
+		{"exact", "Cabo", "cabo", false},
+		{"casefold", "Cabo USB", "CABO usb", true},
+		{"casefold", nil, "x", false},
+		{"trim", " Cabo ", "Cabo", true},
+		{"tolerance=0.01", money.Cents(1205), money.Cents(1206), true},
+		{"tolerance=0.01", 12.05, 12.07, false},
+		{"tolerance=0", "a", "a", true},
+		{"ignore", nil, 42.0, true},
+		{"scale", 12.35, 12.345, true},
+		{"scale", "12.35", 12.3449, false},
+		{"scale", 182.70000000000002, 182.70000000000002, true},
+	}
+
+	for _, tt := range tests {
+		c, err := New(tt.spec)
+		if err != nil {
+			t.Errorf("New(%q) returned error: %v", tt.spec, err)
+			continue
+		}
+		if got := c.Equal(tt.stored, tt.current); got != tt.want {
+			t.Errorf("%s.Equal(%#v, %#v) = %v; want %v", tt.spec, tt.stored, tt.current, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", "fuzzy", "tolerance", "tolerance=-1", "exact=1"} {
+		if _, err := New(bad); err == nil {
+			t.Errorf("New(%q) expected error", bad)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	Register("prefix", func(arg string) (Comparator, error) {
+		return Func(func(a, b interface{}) bool {
+			return len(Format(a)) >= len(arg) && len(Format(b)) >= len(arg) && Format(a)[:len(arg)] == Format(b)[:len(arg)]
+		}), nil
+	})
+
+	c, err := New("prefix=AB")
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	if !c.Equal("ABC", "ABD") || c.Equal("ABC", "ACD") {
+		t.Errorf("registered comparator not used")
+	}
+}
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		a, b interface{}
+		want bool
+	}{
+		{"10.50", 10.5, true},
+		{int64(3), 3, true},
+		{"12.05", money.Cents(1205), true},
+		{money.Cents(1205), money.Cents(1206), false},
+		{nil, "", false},
+		{nil, nil, true},
+		{"Cabo", "cabo", false},
+	}
+
+	for _, tt := range tests {
+		if got := Key(tt.a) == Key(tt.b); got != tt.want {
+			t.Errorf("Key(%#v) == Key(%#v) = %v; want %v", tt.a, tt.b, got, tt.want)
+		}
+		if eq := Equal(tt.a, tt.b); eq != tt.want {
+			t.Errorf("Equal(%#v, %#v) = %v; Key disagrees", tt.a, tt.b, eq)
+		}
+	}
+}
+
+func TestOrder(t *testing.T) {
+	tests := []struct {
+		a, b interface{}
+		want int
+	}{
+		{int64(9), int64(10), -1},
+		{"9", "10", -1},
+		{10.5, "10.50", 0},
+		{nil, int64(0), -1},
+		{nil, nil, 0},
+		{int64(5), "A5", -1},
+		{"B", "A", 1},
+	}
+
+	for _, tt := range tests {
+		if got := Order(tt.a, tt.b); got != tt.want {
+			t.Errorf("Order(%#v, %#v) = %d; want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
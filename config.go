@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/db"
+	"github.com/waldirborbajr/sync/output"
+)
+
+// configUsage documents the config subcommand
+const configUsage = "config show | validate [--reachable] | migrate [--dry-run] [FILE] | deprecations"
+
+// dialTimeout bounds each connection attempt of "sync config validate --reachable"
+const dialTimeout = 5 * time.Second
+
+// configCheck is a check of "sync config validate"
+type configCheck struct {
+	Check  string `json:"check" yaml:"check"`
+	Status string `json:"status" yaml:"status"` // ok, failed or skipped
+	Detail string `json:"detail,omitempty" yaml:"detail,omitempty"`
+}
+
+// configValidation is the output of "sync config validate" in json and yaml
+type configValidation struct {
+	Valid    bool             `json:"valid" yaml:"valid"`
+	Checks   []configCheck    `json:"checks" yaml:"checks"`
+	Settings []config.Setting `json:"settings" yaml:"settings"` // Secrets masked, empty when the configuration does not load
+}
+
+// configValidateCommand loads and validates the configuration: required
+// settings and their ranges, the syntax of the connection strings and, with
+// --reachable, whether the database hosts accept connections. It prints the
+// effective values, secrets masked, and syncs nothing.
+func configValidateCommand(env *commandEnv) int {
+	reachable := false
+	for _, arg := range env.args[1:] {
+		if arg != "--reachable" {
+			fmt.Fprintf(os.Stderr, "%sError:%s unexpected argument %q\nusage: sync %s\n", redBold, reset, arg, configUsage)
+			return 2
+		}
+		reachable = true
+	}
+
+	v := configValidation{Valid: true, Checks: []configCheck{}, Settings: []config.Setting{}}
+	code := 0
+	check := func(name string, problems []string, failure int) {
+		c := configCheck{Check: name, Status: "ok"}
+		if len(problems) > 0 {
+			c.Status, c.Detail = "failed", strings.Join(problems, "; ")
+			v.Valid = false
+			if code == 0 {
+				code = failure
+			}
+		}
+		v.Checks = append(v.Checks, c)
+	}
+	skip := func(name, reason string) {
+		v.Checks = append(v.Checks, configCheck{Check: name, Status: "skipped", Detail: reason})
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		check("settings", []string{err.Error()}, exitConfig)
+		return renderValidation(env, v, code)
+	}
+	check("settings", nil, exitConfig)
+	v.Settings = config.Describe(cfg)
+
+	if cfg.DevMode {
+		skip("connection strings", "DEV_MODE uses the SQLite mocks")
+	} else {
+		check("connection strings", db.DSNProblems(cfg), exitConfig)
+	}
+	switch {
+	case !reachable:
+		skip("hosts reachable", "add --reachable to connect to the database hosts")
+	case cfg.DevMode:
+		skip("hosts reachable", "DEV_MODE uses the SQLite mocks")
+	case code != 0:
+		skip("hosts reachable", "the connection strings are invalid")
+	default:
+		check("Firebird reachable", dialProblems(db.FirebirdAddress(cfg)), exitFirebird)
+		check("MySQL reachable", dialProblems(db.MySQLAddress(cfg)), exitMySQL)
+	}
+	return renderValidation(env, v, code)
+}
+
+// dialProblems returns why address accepts no TCP connection, nil when it does
+func dialProblems(address string) []string {
+	conn, err := net.DialTimeout("tcp", address, dialTimeout)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	_ = conn.Close()
+	return nil
+}
+
+// renderValidation prints the validation and returns code: in table format
+// the effective values, then the checks
+func renderValidation(env *commandEnv, v configValidation, code int) int {
+	if env.output == output.FormatJSON || env.output == output.FormatYAML {
+		if rc := env.render(v); rc != 0 {
+			return rc
+		}
+		return code
+	}
+	if len(v.Settings) > 0 {
+		if rc := env.render(v.Settings); rc != 0 {
+			return rc
+		}
+		fmt.Println()
+	}
+	if rc := env.render(v.Checks); rc != 0 {
+		return rc
+	}
+	if env.output == output.FormatTable {
+		if v.Valid {
+			fmt.Printf("\n%sConfiguration valid%s\n", greenBold, reset)
+		} else {
+			fmt.Printf("\n%sConfiguration invalid%s\n", redBold, reset)
+		}
+	}
+	return code
+}
+
+// configMigrateCommand renames the deprecated settings of a .env file, .env by
+// default, to their replacements. The original is kept as FILE.bak; with
+// --dry-run it only lists the lines it would change.
+func configMigrateCommand(env *commandEnv) int {
+	path, dryRun := ".env", false
+	var files []string
+	for _, arg := range env.args[1:] {
+		if arg == "--dry-run" {
+			dryRun = true
+		} else {
+			files = append(files, arg)
+		}
+	}
+	if len(files) > 1 {
+		fmt.Fprintf(os.Stderr, "%sError:%s unexpected argument %q\nusage: sync %s\n", redBold, reset, files[1], configUsage)
+		return 2
+	}
+	if len(files) == 1 {
+		path = files[0]
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+		return exitConfig
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+		return exitConfig
+	}
+	migrated, migrations := config.MigrateEnv(data)
+	if migrations == nil {
+		migrations = []config.Migration{}
+	}
+	if len(migrations) > 0 && !dryRun {
+		if err := replaceEnvFile(path, data, migrated, info.Mode().Perm()); err != nil {
+			fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+			return 1
+		}
+	}
+
+	if rc := env.render(migrations); rc != 0 {
+		return rc
+	}
+	if env.output == output.FormatTable {
+		switch {
+		case len(migrations) == 0:
+			fmt.Printf("%s%s uses no deprecated setting%s\n", greenBold, path, reset)
+		case dryRun:
+			fmt.Printf("\n%s%d settings to migrate in %s, run without --dry-run to rewrite it%s\n", yellowBold, len(migrations), path, reset)
+		default:
+			fmt.Printf("\n%s%s migrated, the original is in %s.bak%s\n", greenBold, path, path, reset)
+		}
+	}
+	return 0
+}
+
+// replaceEnvFile saves original as path.bak, then replaces path with data
+// through a temporary file, so an interrupted migration leaves one of them whole
+func replaceEnvFile(path string, original, data []byte, perm os.FileMode) error {
+	if err := os.WriteFile(path+".bak", original, perm); err != nil {
+		return fmt.Errorf("error writing backup: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing %s: %w", path, err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error replacing %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// defaultBlobMaxBytes is the largest BLOB synced when BLOB_MAX_BYTES is not set
+const defaultBlobMaxBytes = 16 << 20
+
+// parseBlobColumns parses BLOB_COLUMNS, "FIREBIRD:MYSQL" pairs of TB_ESTOQUE
+// BLOB columns, or bare names when equal, e.g. "FOTO,OBSERVACAO:DESCRICAO_LONGA".
+// Columns the product sync writes, or reverse sync reads, are refused.
+func parseBlobColumns(s string, cfg Config) ([]ColumnMapping, error) {
+	columns, err := parseColumnMappings(s)
+	if err != nil {
+		return nil, err
+	}
+
+	written := append(cfg.ProductColumnNames(), cfg.ProductColumn(ProductKey))
+	for _, c := range columns {
+		if slices.ContainsFunc(written, func(w string) bool { return strings.EqualFold(w, c.Target) }) {
+			return nil, fmt.Errorf("%s is written to TB_ESTOQUE by the product sync", c.Target)
+		}
+		if slices.ContainsFunc(cfg.ReverseColumns, func(r ColumnMapping) bool { return strings.EqualFold(r.Source, c.Target) }) {
+			return nil, fmt.Errorf("%s is pushed back into Firebird by REVERSE_SYNC_COLUMNS", c.Target)
+		}
+	}
+	return columns, nil
+}
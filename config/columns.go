@@ -0,0 +1,240 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/waldirborbajr/sync/compare"
+	"github.com/waldirborbajr/sync/expr"
+)
+
+// skipColumn in PRODUCT_COLUMN_MAP leaves a built-in column out of reads and writes
+const skipColumn = "-"
+
+// ProductKey is the built-in key field of TB_ESTOQUE
+const ProductKey = "ID_ESTOQUE"
+
+// ProductFields are the built-in TB_ESTOQUE fields, named after their default columns
+var ProductFields = []string{ProductKey, "DESCRICAO", "QTD_ATUAL", "PRC_CUSTO", "PRC_DOLAR", "PRC_VENDA", "PRC_3X", "PRC_6X", "PRC_10X"}
+
+// ExpressionVars are the variables available to PRODUCT_EXTRA_COLUMNS and PRODUCT_TRANSFORMS expressions:
+// the built-in fields with their final (calculated) values, ID_GRUPO and STATUS
+var ExpressionVars = append(slices.Clone(ProductFields), "ID_GRUPO", "STATUS")
+
+// ExtraColumn is an additional TB_ESTOQUE column computed from the product row
+type ExtraColumn struct {
+	Target string
+	Expr   *expr.Expr
+}
+
+// String returns the column as written in PRODUCT_EXTRA_COLUMNS
+func (c ExtraColumn) String() string {
+	return c.Target + "=" + c.Expr.String()
+}
+
+// MarshalText makes the column log as its PRODUCT_EXTRA_COLUMNS entry
+func (c ExtraColumn) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// Transform replaces the calculated value of a built-in product field by an
+// expression before the row is compared and written. Transforms run in
+// order, each seeing the values the previous ones produced.
+type Transform struct {
+	Field string // One of ProductFields but the key
+	Expr  *expr.Expr
+}
+
+// String returns the transform as written in PRODUCT_TRANSFORMS
+func (t Transform) String() string {
+	return t.Field + "=" + t.Expr.String()
+}
+
+// MarshalText makes the transform log as its PRODUCT_TRANSFORMS entry
+func (t Transform) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// ProductColumn returns the TB_ESTOQUE column of a built-in product field,
+// or "" when PRODUCT_COLUMN_MAP leaves it out
+func (c Config) ProductColumn(field string) string {
+	column, ok := c.ProductColumns[field]
+	if !ok {
+		return field
+	}
+	if column == skipColumn {
+		return ""
+	}
+	return column
+}
+
+// parseProductColumns parses "FIELD:COLUMN" pairs separated by commas, where
+// FIELD is one of ProductFields and COLUMN is the target column or "-" to
+// leave the field out, e.g. "DESCRICAO:NOME,PRC_DOLAR:-"
+func parseProductColumns(s string) (map[string]string, error) {
+	columns := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		field, column, ok := strings.Cut(pair, ":")
+		field, column = strings.ToUpper(strings.TrimSpace(field)), strings.TrimSpace(column)
+		if !ok || !slices.Contains(ProductFields, field) {
+			return nil, fmt.Errorf("invalid product column %q: expected FIELD:COLUMN with FIELD one of %s", pair, strings.Join(ProductFields, ", "))
+		}
+		if column == skipColumn && field == ProductKey {
+			return nil, fmt.Errorf("the %s key column cannot be left out", ProductKey)
+		}
+		if column != skipColumn && !IsValidIdentifier(column) {
+			return nil, fmt.Errorf("invalid column name in product column %q", pair)
+		}
+		columns[field] = column
+	}
+	return columns, nil
+}
+
+// parseExtraColumns parses "COLUMN=EXPRESSION" entries separated by ';',
+// e.g. "PRC_PROMO=PRC_VENDA * 0.9;ORIGEM='ERP'"
+func parseExtraColumns(s string) ([]ExtraColumn, error) {
+	var columns []ExtraColumn
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		target, source, ok := strings.Cut(entry, "=")
+		target = strings.TrimSpace(target)
+		if !ok || !IsValidIdentifier(target) {
+			return nil, fmt.Errorf("invalid extra column %q: expected COLUMN=EXPRESSION", entry)
+		}
+		e, err := expr.Parse(strings.TrimSpace(source))
+		if err != nil {
+			return nil, fmt.Errorf("extra column %s: %w", target, err)
+		}
+		for _, v := range e.Vars() {
+			if !slices.Contains(ExpressionVars, v) {
+				return nil, fmt.Errorf("extra column %s: unknown variable %s (available: %s)", target, v, strings.Join(ExpressionVars, ", "))
+			}
+		}
+		columns = append(columns, ExtraColumn{Target: target, Expr: e})
+	}
+	return columns, nil
+}
+
+// parseTransforms parses "FIELD=EXPRESSION" entries separated by ';', e.g.
+// "DESCRICAO=UPPER(TRIM(DESCRICAO));PRC_VENDA=IF(ID_GRUPO = 7, PRC_VENDA * 0.95, PRC_VENDA)"
+func parseTransforms(s string) ([]Transform, error) {
+	var transforms []Transform
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		field, source, ok := strings.Cut(entry, "=")
+		field = strings.ToUpper(strings.TrimSpace(field))
+		if !ok || field == ProductKey || !slices.Contains(ProductFields, field) {
+			return nil, fmt.Errorf("invalid transform %q: expected FIELD=EXPRESSION with FIELD one of %s", entry, strings.Join(ProductFields[1:], ", "))
+		}
+		e, err := expr.Parse(strings.TrimSpace(source))
+		if err != nil {
+			return nil, fmt.Errorf("transform of %s: %w", field, err)
+		}
+		for _, v := range e.Vars() {
+			if !slices.Contains(ExpressionVars, v) {
+				return nil, fmt.Errorf("transform of %s: unknown variable %s (available: %s)", field, v, strings.Join(ExpressionVars, ", "))
+			}
+		}
+		transforms = append(transforms, Transform{Field: field, Expr: e})
+	}
+	return transforms, nil
+}
+
+// ProductColumnNames returns the TB_ESTOQUE columns read and written for the
+// configuration, besides the key
+func (c Config) ProductColumnNames() []string {
+	var names []string
+	for _, field := range ProductFields[1:] {
+		if column := c.ProductColumn(field); column != "" {
+			names = append(names, column)
+		}
+	}
+	if c.StockPolicy == StockHide {
+		names = append(names, c.StockVisibilityColumn)
+	}
+	if c.SoftDeleteColumn != "" {
+		names = append(names, c.SoftDeleteColumn)
+	}
+	if len(c.StatusMap) > 0 {
+		names = append(names, c.StatusColumn)
+	}
+	for _, extra := range c.ProductExtraColumns {
+		names = append(names, extra.Target)
+	}
+	return names
+}
+
+// parseComparators parses "COLUMN:COMPARATOR" pairs separated by commas, where
+// COMPARATOR is a compare spec and COLUMN one of columns, e.g.
+// "DESCRICAO:casefold,PRC_VENDA:tolerance=0.01,QTD_ATUAL:ignore"
+func parseComparators(s string, columns []string) (map[string]string, error) {
+	comparators := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		column, spec, ok := strings.Cut(pair, ":")
+		column, spec = strings.TrimSpace(column), strings.TrimSpace(spec)
+		if !ok || spec == "" {
+			return nil, fmt.Errorf("invalid comparator %q: expected COLUMN:COMPARATOR", pair)
+		}
+		if !slices.Contains(columns, column) {
+			return nil, fmt.Errorf("comparator for unknown column %s (columns: %s)", column, strings.Join(columns, ", "))
+		}
+		if _, err := compare.New(spec); err != nil {
+			return nil, fmt.Errorf("column %s: %w", column, err)
+		}
+		comparators[column] = spec
+	}
+	return comparators, nil
+}
+
+// validateHashPreload returns why the configuration cannot compare stored rows
+// by hash (MYSQL_PRELOAD=hash), nil when it can. A hash only tells whether a
+// row changed: features reading the stored values are unavailable, and columns
+// must be compared exactly (or ignored), which PRODUCT_EXTRA_COLUMNS, compared
+// at the stored scale by default, must state in PRODUCT_COMPARATORS.
+func validateHashPreload(c Config) error {
+	switch {
+	case c.PriceHistoryEnabled:
+		return fmt.Errorf("MYSQL_PRELOAD=%s cannot be combined with PRICE_HISTORY_ENABLED, which records the stored prices", PreloadHash)
+	case c.AuditEnabled:
+		return fmt.Errorf("MYSQL_PRELOAD=%s cannot be combined with AUDIT_ENABLED, which records the stored values", PreloadHash)
+	case c.DiffReportFile != "":
+		return fmt.Errorf("MYSQL_PRELOAD=%s cannot be combined with DIFF_REPORT_FILE, which lists the stored values", PreloadHash)
+	case c.ProtectedRowsQuery != "":
+		return fmt.Errorf("MYSQL_PRELOAD=%s cannot be combined with PROTECTED_ROWS_QUERY, which keeps the stored sale prices", PreloadHash)
+	case c.MaxPriceDrop > 0:
+		return fmt.Errorf("MYSQL_PRELOAD=%s cannot be combined with PRICE_MAX_DROP, which compares against the stored sale price", PreloadHash)
+	}
+
+	for field, p := range c.NullPolicies {
+		if p.Update == NullKeep {
+			return fmt.Errorf("MYSQL_PRELOAD=%s cannot be combined with NULL_POLICIES %s:%s/%s, which keeps the stored value", PreloadHash, field, p.Insert, p.Update)
+		}
+	}
+
+	for column, spec := range c.ProductComparators {
+		if !strings.EqualFold(spec, "exact") && !strings.EqualFold(spec, "ignore") {
+			return fmt.Errorf("MYSQL_PRELOAD=%s compares columns exactly: comparator %s of %s is not supported, use exact or ignore", PreloadHash, spec, column)
+		}
+	}
+	for _, extra := range c.ProductExtraColumns {
+		if _, ok := c.ProductComparators[extra.Target]; !ok {
+			return fmt.Errorf("MYSQL_PRELOAD=%s compares columns exactly: set PRODUCT_COMPARATORS %s:exact (rounding the expression to the column scale) or %s:ignore", PreloadHash, extra.Target, extra.Target)
+		}
+	}
+	return nil
+}
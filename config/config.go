@@ -3,34 +3,449 @@ package config
 import (
 	"fmt"
 	"os"
+	"regexp"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/waldirborbajr/sync/exchange"
+	"github.com/waldirborbajr/sync/flags"
 	"github.com/waldirborbajr/sync/logger"
+	"github.com/waldirborbajr/sync/money"
+	"github.com/waldirborbajr/sync/notify"
+	"github.com/waldirborbajr/sync/partition"
+	"github.com/waldirborbajr/sync/replay"
 )
 
-// Config holds database connection parameters and pricing configuration
+// Price constraint policies
+const (
+	ConstraintClamp = "clamp" // Raise violating prices to the lowest allowed value
+	ConstraintFlag  = "flag"  // Keep the calculated price and only report the violation
+)
+
+// What installing an update does about the runs in progress (UPDATE_ACTIVE_RUNS)
+const (
+	ActiveRunsDefer  = "defer"  // Wait for them to finish, or leave the install to the next check
+	ActiveRunsCancel = "cancel" // Ask them to stop, then install
+)
+
+// defaultActiveRunsWait is how long an install waits for the runs in progress by default
+const defaultActiveRunsWait = time.Minute
+
+// Stock policies for rows with QTD_ATUAL <= 0
+const (
+	StockAsIs = "asis" // Write the quantity unchanged
+	StockZero = "zero" // Write zero instead of negative quantities
+	StockHide = "hide" // Write zero and clear the visibility flag column
+	StockSkip = "skip" // Neither insert nor update the row
+)
+
+// Soft delete styles of SOFT_DELETE_COLUMN
+const (
+	SoftDeleteFlag      = "flag"      // 1 for active rows, 0 for deleted ones
+	SoftDeleteTimestamp = "timestamp" // NULL for active rows, the deletion time for deleted ones
+)
+
+// Sync modes
+const (
+	SyncFull      = "full"      // Compute prices and write every TB_ESTOQUE column
+	SyncQuantity  = "quantity"  // Only compare and write QTD_ATUAL of existing products
+	SyncReconcile = "reconcile" // Full sync reading every row despite INCREMENTAL_COLUMN
+)
+
+// Process priorities (PROCESS_PRIORITY)
+const (
+	PriorityNormal = "normal" // Leave the priority unchanged
+	PriorityLow    = "low"    // nice 10 and best-effort low I/O, below normal class on Windows
+	PriorityIdle   = "idle"   // nice 19 and idle I/O, idle class on Windows
+)
+
+// MySQL preload modes: what is kept in memory of each existing TB_ESTOQUE row
+const (
+	PreloadColumns = "columns" // The compared columns
+	PreloadHash    = "hash"    // A hash of the compared columns, see validateHashPreload
+)
+
+// Diff report formats (DIFF_REPORT_FORMAT)
+const (
+	DiffReportCSV  = "csv"  // One line per changed column
+	DiffReportJSON = "json" // An array with one object per row
+)
+
+// WhatsApp notification providers (NOTIFY_WHATSAPP_PROVIDER)
+const (
+	WhatsAppTwilio  = "twilio"  // Twilio Messages API
+	WhatsAppWebhook = "webhook" // A gateway taking {"to", "from", "text"} JSON
+)
+
+// Firebird read transaction isolation (FIREBIRD_ISOLATION); empty reads
+// each source query in its own driver transaction
+const (
+	IsolationSnapshot      = "snapshot"       // One snapshot for the whole extract, the ERP writes during it are not seen
+	IsolationReadCommitted = "read_committed" // One read-only transaction seeing every commit as the extract goes
+)
+
+// syncModes lists the valid SYNC_MODE values
+var syncModes = []string{SyncFull, SyncQuantity, SyncReconcile}
+
+// validSyncMode reports whether mode is a valid SYNC_MODE
+func validSyncMode(mode string) bool {
+	return slices.Contains(syncModes, mode)
+}
+
+// defaultStateFile is the state file used when STATE_FILE is not set
+const defaultStateFile = "sync_state.json"
+
+// defaultDiskMinFreeMB is the free space kept when DISK_MIN_FREE_MB is not set
+const defaultDiskMinFreeMB = 100
+
+// identifierPattern matches SQL identifiers accepted in configuration
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// IsValidIdentifier reports whether s can be used as a column or table name
+func IsValidIdentifier(s string) bool {
+	return identifierPattern.MatchString(s)
+}
+
+// Config holds database connection parameters and pricing configuration.
+// Each field is tagged with the environment variable it is read from;
+// ",secret" marks values that are masked when the configuration is shown.
 type Config struct {
-	FirebirdUser     string
-	FirebirdPassword string
-	FirebirdHost     string
-	FirebirdPath     string
-	MySQLUser        string
-	MySQLPassword    string
-	MySQLHost        string
-	MySQLPort        string
-	MySQLDatabase    string
-	Lucro            float64
-	Parc3x           float64
-	Parc6x           float64
-	Parc10x          float64
-	DebugMode        bool // Novo campo para modo debug
-	DevMode          bool // Use SQLite mocks instead of real databases
+	FirebirdUser     string  `env:"FIREBIRD_USER"`
+	FirebirdPassword string  `env:"FIREBIRD_PASSWORD,secret"`
+	FirebirdHost     string  `env:"FIREBIRD_HOST"`
+	FirebirdPath     string  `env:"FIREBIRD_PATH"`
+	MySQLUser        string  `env:"MYSQL_USER"`
+	MySQLPassword    string  `env:"MYSQL_PASSWORD,secret"`
+	MySQLHost        string  `env:"MYSQL_HOST"`
+	MySQLPort        string  `env:"MYSQL_PORT"`
+	MySQLDatabase    string  `env:"MYSQL_DATABASE"`
+	Lucro            float64 `env:"LUCRO"`
+	Parc3x           float64 `env:"PARC3X"`
+	Parc6x           float64 `env:"PARC6X"`
+	Parc10x          float64 `env:"PARC10X"`
+	DebugMode        bool    `env:"DEBUG_MODE"` // Novo campo para modo debug
+
+	// SyncFull, or SyncQuantity for a lightweight run scheduled more often than the
+	// full one: prices are neither computed nor compared, new products are left to
+	// the next full run and only mappings with QUANTITY_COLUMNS are synced.
+	// SyncReconcile is a full run that ignores the incremental watermark.
+	SyncMode string `env:"SYNC_MODE"`
+	DevMode  bool   `env:"DEV_MODE"` // Use SQLite mocks instead of real databases
+
+	// Draw the progress of "sync run" on the terminal instead of its log lines
+	TUI bool `env:"PROGRESS_TUI"`
+	// Keep a progress line with rows/s and ETA under the log lines of "sync run" on a terminal
+	ProgressLine bool `env:"PROGRESS_LINE"`
 
 	// Update settings
-	UpdateCheckURL    string // Endpoint returning latest version info (JSON: {"version":"v1.2.3","url":"https://..."})
-	AutoUpdate        bool   // If true, will attempt to download the update automatically
-	UpdateDownloadDir string // Directory to save downloaded update
+	UpdateCheckURL    string `env:"UPDATE_CHECK_URL"`    // Endpoint returning latest version info (JSON: {"version":"v1.2.3","url":"https://..."})
+	AutoUpdate        bool   `env:"AUTO_UPDATE"`         // If true, will attempt to download the update automatically
+	UpdateDownloadDir string `env:"UPDATE_DOWNLOAD_DIR"` // Directory to save downloaded update
+	// The executable is never replaced during a run: ActiveRunsDefer or
+	// ActiveRunsCancel, and how long to wait for the runs to finish or stop
+	UpdateActiveRuns     string        `env:"UPDATE_ACTIVE_RUNS"`
+	UpdateActiveRunsWait time.Duration `env:"UPDATE_ACTIVE_RUNS_WAIT"`
+
+	// Price history settings
+	PriceHistoryEnabled       bool `env:"PRICE_HISTORY_ENABLED"`        // Record every price change into TB_PRECO_HISTORICO
+	PriceHistoryRetentionDays int  `env:"PRICE_HISTORY_RETENTION_DAYS"` // Delete history rows older than this many days (0 keeps everything)
+
+	// Audit settings
+	AuditEnabled       bool `env:"AUDIT_ENABLED"`        // Record every TB_ESTOQUE insert and update into TB_ESTOQUE_SYNC_AUDIT
+	AuditRetentionDays int  `env:"AUDIT_RETENTION_DAYS"` // Delete audit rows older than this many days (0 keeps everything)
+
+	// Diff report: a file per run listing every row inserted or updated with
+	// the values of its changed columns before and after, "{run_id}" in the
+	// path being replaced by the run ID
+	DiffReportFile   string `env:"DIFF_REPORT_FILE"`
+	DiffReportFormat string `env:"DIFF_REPORT_FORMAT"` // DiffReportCSV or DiffReportJSON
+
+	// Replay log: every statement committed to MySQL, appended to a rotated
+	// and compressed file to rebuild the tables from a backup ("sync replay")
+	ReplayLogFile      string `env:"REPLAY_LOG_FILE"`
+	ReplayLogFormat    string `env:"REPLAY_LOG_FORMAT"`      // replay.FormatSQL or replay.FormatBinary
+	ReplayLogMaxSizeMB int    `env:"REPLAY_LOG_MAX_SIZE_MB"` // Size a replay log grows to before it is rotated
+
+	// Price constraints applied after calculation
+	MinMargin             float64             `env:"PRICE_MIN_MARGIN"`        // Minimum PRC_VENDA margin over cost, in percent (0 disables)
+	MaxPriceDrop          float64             `env:"PRICE_MAX_DROP"`          // Maximum PRC_VENDA reduction in a single run, in percent (0 disables)
+	CategoryFloors        map[int]money.Cents `env:"PRICE_FLOORS"`            // Minimum PRC_VENDA per product group (ID_GRUPO)
+	PriceConstraintPolicy string              `env:"PRICE_CONSTRAINT_POLICY"` // ConstraintClamp or ConstraintFlag
+
+	// Margin sets selected per product instead of the global margins, see PricingRule
+	PricingRulesFile      string `env:"PRICING_RULES_FILE"`
+	PricingSupplierColumn string `env:"PRICING_SUPPLIER_COLUMN"` // Firebird TB_ESTOQUE column tested as SUPPLIER
+	PricingRules          []PricingRule
+
+	// MySQL query returning ID_ESTOQUE values whose sale prices must not be overwritten (e.g. promotions)
+	ProtectedRowsQuery string `env:"PROTECTED_ROWS_QUERY"`
+
+	// MySQL query returning ID_ESTOQUE and reserved quantity, subtracted from QTD_ATUAL before writing
+	ReservationsQuery string `env:"RESERVATIONS_QUERY"`
+
+	// Handling of QTD_ATUAL <= 0
+	StockPolicy           string `env:"STOCK_POLICY"`            // StockAsIs, StockZero, StockHide or StockSkip
+	StockVisibilityColumn string `env:"STOCK_VISIBILITY_COLUMN"` // TB_ESTOQUE column set to 0/1 by StockHide
+
+	// TB_ESTOQUE column marking the rows missing from Firebird, or no longer
+	// active there, as deleted; rows are never deleted. Empty leaves them as they are.
+	SoftDeleteColumn string `env:"SOFT_DELETE_COLUMN"`
+	SoftDeleteStyle  string `env:"SOFT_DELETE_STYLE"` // SoftDeleteFlag or SoftDeleteTimestamp
+
+	// Firebird query returning the CustomerColumns, synced into TB_CLIENTE
+	CustomerQuery string `env:"CUSTOMER_QUERY"`
+
+	// Firebird query returning the product group ID and description, synced into TB_CATEGORIA
+	CategoryQuery string `env:"CATEGORY_QUERY"`
+
+	// Firebird query returning ID_ESTOQUE, ID_DEPOSITO and quantity, synced into TB_ESTOQUE_DEPOSITO
+	WarehouseQuery string `env:"WAREHOUSE_QUERY"`
+
+	// Firebird query returning the stock movements after the movement ID bound
+	// to its '?', in ID order, appended to TB_MOVIMENTO_ESTOQUE
+	MovementQuery string `env:"MOVEMENT_QUERY"`
+
+	// Lifecycle status translation: Firebird STATUS -> value written to StatusColumn.
+	// When empty, and no ROW_FILTERS rule tests STATUS, only STATUS = 'A' products are synced.
+	StatusMap    map[string]string `env:"STATUS_MAP"`
+	StatusColumn string            `env:"STATUS_COLUMN"`
+
+	// Rules every synced Firebird product must satisfy, see RowFilter
+	RowFilters    []RowFilter `env:"ROW_FILTERS"`
+	RowFilterMode string      `env:"ROW_FILTER_MODE"` // FilterSQL or FilterScan
+
+	// EAN/SKU cross-reference against the webshop catalog.
+	// CatalogSourceQuery runs on Firebird and returns ID_ESTOQUE and the code.
+	CatalogSourceQuery string `env:"CATALOG_SOURCE_QUERY"`
+	CatalogTable       string `env:"CATALOG_TABLE"`
+	CatalogIDColumn    string `env:"CATALOG_ID_COLUMN"`
+	CatalogCodeColumn  string `env:"CATALOG_CODE_COLUMN"`
+	CatalogAutoCreate  bool   `env:"CATALOG_AUTO_CREATE"` // Insert minimal (ID, code) catalog rows for missing products
+
+	// Procedure called with a comma-separated list of changed ID_ESTOQUE values
+	// instead of the full-table UpdateQtdVirtual, ProcedureBatchSize IDs per call
+	ChangedIDsProcedure string `env:"CHANGED_IDS_PROCEDURE"`
+	ProcedureBatchSize  int    `env:"PROCEDURE_BATCH_SIZE"`
+
+	// Changed keys handoff: keys written by the run are stored in TB_SYNC_ALTERADOS
+	// (RUN_ID, ID_ESTOQUE) before PostSyncSQL runs
+	ChangedKeysEnabled bool   `env:"CHANGED_KEYS_ENABLED"`
+	PostSyncSQL        string `env:"POST_SYNC_SQL"` // ';'-separated MySQL statements run after the procedures; {run_id} is substituted
+
+	// Metrics pushed at the end of each run (empty URL disables)
+	MetricsPushURL    string `env:"METRICS_PUSH_URL"`          // Pushgateway base URL or InfluxDB write URL
+	MetricsPushFormat string `env:"METRICS_PUSH_FORMAT"`       // "prometheus" or "influx"
+	MetricsPushToken  string `env:"METRICS_PUSH_TOKEN,secret"` // Sent as "Authorization: Token ..." when set
+	MetricsJob        string `env:"METRICS_JOB"`               // Pushgateway job / InfluxDB job tag
+
+	// Run outcomes sent to a Telegram chat (empty token disables), see package notify
+	NotifyTelegramToken    string `env:"NOTIFY_TELEGRAM_TOKEN,secret"`
+	NotifyTelegramChatID   string `env:"NOTIFY_TELEGRAM_CHAT_ID"`
+	NotifyTelegramSeverity string `env:"NOTIFY_TELEGRAM_SEVERITY"` // Lowest severity sent: info, warning or error
+	NotifyTelegramTemplate string `env:"NOTIFY_TELEGRAM_TEMPLATE"` // text/template over notify.Event
+	NotifyTelegramAPIURL   string `env:"NOTIFY_TELEGRAM_API_URL"`
+
+	// Run outcomes sent over WhatsApp through Twilio or a webhook gateway
+	// (empty recipient disables)
+	NotifyWhatsAppProvider   string `env:"NOTIFY_WHATSAPP_PROVIDER"` // WhatsAppTwilio or WhatsAppWebhook
+	NotifyWhatsAppURL        string `env:"NOTIFY_WHATSAPP_URL"`      // Gateway URL, or Twilio API base URL
+	NotifyWhatsAppAccountSID string `env:"NOTIFY_WHATSAPP_ACCOUNT_SID"`
+	NotifyWhatsAppToken      string `env:"NOTIFY_WHATSAPP_TOKEN,secret"` // Twilio auth token, or gateway bearer token
+	NotifyWhatsAppFrom       string `env:"NOTIFY_WHATSAPP_FROM"`
+	NotifyWhatsAppTo         string `env:"NOTIFY_WHATSAPP_TO"`
+	NotifyWhatsAppSeverity   string `env:"NOTIFY_WHATSAPP_SEVERITY"`
+	NotifyWhatsAppTemplate   string `env:"NOTIFY_WHATSAPP_TEMPLATE"`
+
+	// The largest PRC_VENDA and QTD_ATUAL changes listed in notifications (0
+	// lists none); a change of at least the threshold, in percent of the
+	// stored value, makes the run a warning
+	NotifyTopChanges      int     `env:"NOTIFY_TOP_CHANGES"`
+	NotifyChangeThreshold float64 `env:"NOTIFY_CHANGE_THRESHOLD"`
+
+	// Terminate the process when no row is read and no batch committed for this long (0 disables)
+	WatchdogTimeout time.Duration `env:"WATCHDOG_TIMEOUT"`
+
+	// When the heap grows past HEAP_LIMIT_MB (0 disables), checked every
+	// HEAP_CHECK_INTERVAL, a heap profile is written to the log directory and
+	// the writers allowed at once are halved
+	HeapLimitMB       int           `env:"HEAP_LIMIT_MB"`
+	HeapCheckInterval time.Duration `env:"HEAP_CHECK_INTERVAL"`
+
+	// Recovery runs after a failure with a retryable error class (connection, deadlock, timeout)
+	RecoveryAttempts int           `env:"RECOVERY_ATTEMPTS"` // 0 disables
+	RecoveryBackoff  time.Duration `env:"RECOVERY_BACKOFF"`  // Wait before the first recovery run, doubled for each further attempt
+
+	// Runs failing because Firebird is shut down, in backup or read-only are
+	// repeated every MaintenanceRetry for up to MaintenanceWait, then deferred
+	MaintenanceWait  time.Duration `env:"FIREBIRD_MAINTENANCE_WAIT"` // 0 defers at once
+	MaintenanceRetry time.Duration `env:"FIREBIRD_MAINTENANCE_RETRY"`
+
+	// Batch writes failing with a retryable error class are repeated within the run
+	BatchRetries      int           `env:"BATCH_RETRIES"`       // Retries per batch, 0 disables
+	BatchRetryBackoff time.Duration `env:"BATCH_RETRY_BACKOFF"` // Wait before the first retry, doubled for each further one
+
+	// Each statement of a batch runs under a savepoint; rows MySQL refuses
+	// (constraint or conversion errors) are isolated by splitting the statement
+	// and left out instead of failing the whole batch
+	BatchIsolateErrors bool `env:"BATCH_ISOLATE_ERRORS"`
+
+	// New and changed products are written together by the same multi-row
+	// INSERT ... ON DUPLICATE KEY UPDATE statements; the comparison with the
+	// preloaded rows still decides what is written and how it is counted
+	UpsertWrites bool `env:"UPSERT_WRITES"`
+
+	// How product rows are handed to the write workers: "" for a shared queue
+	// any idle worker takes from, or a partition strategy (partition.Modulo,
+	// partition.Range, partition.Jump) giving each worker its own keys
+	WorkerRouting string `env:"WORKER_ROUTING"`
+
+	// JSON file holding persisted operational state (maintenance flag)
+	StateFile string `env:"STATE_FILE"`
+
+	// Root of the per-run temporary workspaces (spill and staging files,
+	// diagnostic dumps), the system temporary directory when empty. Failed
+	// runs keep theirs, removed by later runs once older than WorkDirRetention.
+	WorkDir          string        `env:"WORK_DIR"`
+	WorkDirRetention time.Duration `env:"WORK_DIR_RETENTION"`
+
+	// Observer mode for support staff: MySQL sessions are read-only, and runs,
+	// the daemon, maintenance changes and update installs are refused
+	ReadOnly bool `env:"READ_ONLY"`
+
+	// Incremental sync: Firebird TB_ESTOQUE column holding the last modification time.
+	// Only rows modified after the persisted watermark (minus the overlap) are read.
+	IncrementalColumn  string        `env:"INCREMENTAL_COLUMN"`
+	IncrementalOverlap time.Duration `env:"INCREMENTAL_OVERLAP"`
+
+	// Firebird rows are read in ID_ESTOQUE ranges of this many keys, each its
+	// own query retried on its own; 0 reads them with a single query
+	SourceChunkSize int `env:"SOURCE_CHUNK_SIZE"`
+
+	// Isolation of the transaction reading the Firebird products, whose ID is
+	// recorded in TB_SYNC_INSTANCIAS; empty keeps a transaction per query
+	FirebirdIsolation string `env:"FIREBIRD_ISOLATION"` // IsolationSnapshot or IsolationReadCommitted
+
+	// Products are read by this many readers at once, each a contiguous span
+	// of ID_ESTOQUE; with FIREBIRD_ISOLATION they share its transaction, so
+	// with snapshot every span is read as of the same instant
+	SourceReaders int `env:"SOURCE_READERS"`
+
+	// The product read stops after this many source rows, for smoke tests
+	// against production data; 0 reads every row
+	RowLimit int `env:"ROW_LIMIT"`
+
+	// Largest difference tolerated between the clocks of the host, Firebird
+	// and MySQL at run start, 0 disables the check; strict mode fails the run
+	ClockSkewMax    time.Duration `env:"CLOCK_SKEW_MAX"`
+	ClockSkewStrict bool          `env:"CLOCK_SKEW_STRICT"`
+
+	// USD/BRL rate deriving PRC_DOLAR from PRC_CUSTO for products without a
+	// TB_EST_INDEXADOR value; an empty provider writes 0 as before. Fetched rates
+	// are cached in the state file for ExchangeRateCacheTTL; when fetching fails
+	// the cached rate, however old, or else ExchangeRateFallback is used.
+	ExchangeRateProvider string        `env:"EXCHANGE_RATE_PROVIDER"` // exchange.ProviderBCB or exchange.ProviderExchangeRate
+	ExchangeRateURL      string        `env:"EXCHANGE_RATE_URL"`      // Provider endpoint, its default when empty
+	ExchangeRateCacheTTL time.Duration `env:"EXCHANGE_RATE_CACHE_TTL"`
+	ExchangeRateFallback float64       `env:"EXCHANGE_RATE_FALLBACK"` // 0 writes 0 when no rate is available
+
+	// Notation of numeric settings and imported values, e.g. "," and "." for 1.234,56
+	DecimalSeparator   string `env:"NUMBER_DECIMAL_SEPARATOR"`
+	ThousandsSeparator string `env:"NUMBER_THOUSANDS_SEPARATOR"`
+
+	// Fail on unknown SYNC_* variables, unparseable values and out-of-range
+	// percentages, listing every problem, instead of warning and using defaults
+	StrictConfig bool `env:"CONFIG_STRICT"`
+
+	// Additional tables synced before TB_ESTOQUE, see TableMapping
+	Tables []TableMapping `env:"SYNC_TABLES"`
+
+	// TB_ESTOQUE layout: columns of the built-in fields (see ProductColumn) and
+	// additional columns computed by expressions
+	ProductColumns      map[string]string `env:"PRODUCT_COLUMN_MAP"`
+	ProductExtraColumns []ExtraColumn     `env:"PRODUCT_EXTRA_COLUMNS"`
+	ProductComparators  map[string]string `env:"PRODUCT_COMPARATORS"` // Comparator spec per TB_ESTOQUE column, see package compare
+	ProductTransforms   []Transform       `env:"PRODUCT_TRANSFORMS"`
+
+	// What NULL Firebird prices are written as, per nullable field, see NullPolicy
+	NullPolicies map[string]NullPolicy `env:"NULL_POLICIES"`
+
+	// Scheduled runs of "sync daemon", see Job
+	Jobs []Job `env:"SYNC_JOBS"`
+
+	// "sync daemon" waits for Firebird and MySQL at startup, polling with a
+	// backoff doubling from DaemonStartupBackoff, before giving up
+	DaemonStartupWait    time.Duration `env:"DAEMON_STARTUP_WAIT"` // 0 checks once
+	DaemonStartupBackoff time.Duration `env:"DAEMON_STARTUP_BACKOFF"`
+
+	// Parts of the run synced (--tables), see Syncs: those listed in
+	// SYNC_ONLY and those of the SYNC_SCOPE preset (--scope); nil for all
+	SyncOnly  []string `env:"SYNC_ONLY"`
+	SyncScope string   `env:"SYNC_SCOPE"`
+
+	// Resource limits for servers shared with the point of sale, see package limits
+	MaxProcs        int    `env:"MAX_PROCS"`        // CPUs used (GOMAXPROCS), 0 for all
+	MaxWorkers      int    `env:"MAX_WORKERS"`      // Worker pool cap, 0 for the automatic size
+	ProcessPriority string `env:"PROCESS_PRIORITY"` // PriorityNormal, PriorityLow or PriorityIdle
+
+	// Free space kept on the disks a run writes to, on top of what it is
+	// estimated to write there, see package preflight
+	DiskMinFreeMB int `env:"DISK_MIN_FREE_MB"`
+
+	// Post-sync spot checks: TB_ESTOQUE rows read back once the batches are
+	// committed and compared with the values the run computed for them
+	SpotCheckIDs    []int `env:"SPOT_CHECK_IDS"`    // Keys always checked
+	SpotCheckSample int   `env:"SPOT_CHECK_SAMPLE"` // Rows picked at random among those written
+
+	// Percent of the written rows read back after commit, each row with that
+	// probability, to estimate the error rate of the run; 0 disables
+	VerifySample float64 `env:"VERIFY_SAMPLE"`
+
+	// PreloadColumns, or PreloadHash to keep only the key and a hash of the
+	// compared columns of each TB_ESTOQUE row in memory on large catalogs
+	MySQLPreload string `env:"MYSQL_PRELOAD"`
+
+	// Behaviors switched on or off at run time, see package flags. The remote
+	// flags served at FeatureFlagsURL override the local ones; the last ones
+	// fetched are kept in the state file for when the endpoint is unreachable.
+	FeatureFlags    flags.Set `env:"FEATURE_FLAGS"`
+	FeatureFlagsURL string    `env:"FEATURE_FLAGS_URL"`
+
+	// TB_ESTOQUE columns managed in MySQL (Source) pushed back into the
+	// Firebird columns (Target) by full runs. A row whose Firebird values
+	// changed since they were last pushed is a conflict, see ReverseConflict*.
+	ReverseColumns  []ColumnMapping `env:"REVERSE_SYNC_COLUMNS"`
+	ReverseConflict string          `env:"REVERSE_SYNC_CONFLICT"`
+
+	// Firebird TB_ESTOQUE BLOB columns (Source) copied into MySQL TB_ESTOQUE
+	// columns (Target) after the products, BLOBs larger than BlobMaxBytes skipped
+	BlobColumns  []ColumnMapping `env:"BLOB_COLUMNS"`
+	BlobMaxBytes int             `env:"BLOB_MAX_BYTES"`
+
+	// Web orders exported from MySQL into Firebird TB_PEDIDO and TB_PEDIDO_ITEM
+	// as pending orders: OrderExportQuery returns the orders (ID, customer,
+	// date, total, notes), OrderItemsQuery the items (product, quantity,
+	// price) of the order ID bound to its '?'. Empty disables the export.
+	OrderExportQuery   string `env:"ORDER_EXPORT_QUERY"`
+	OrderItemsQuery    string `env:"ORDER_ITEMS_QUERY"`
+	OrderPendingStatus string `env:"ORDER_PENDING_STATUS"` // Firebird TB_PEDIDO.STATUS of the exported orders
+}
+
+// QuantityOnly reports whether the run only syncs quantities (SYNC_MODE=quantity)
+func (c Config) QuantityOnly() bool {
+	return c.SyncMode == SyncQuantity
+}
+
+// HashPreload reports whether stored rows are compared by hash (MYSQL_PRELOAD=hash)
+func (c Config) HashPreload() bool {
+	return c.MySQLPreload == PreloadHash
+}
+
+// Reconcile reports whether the run reads every row despite INCREMENTAL_COLUMN (SYNC_MODE=reconcile)
+func (c Config) Reconcile() bool {
+	return c.SyncMode == SyncReconcile
 }
 
 // LoadConfig loads environment variables from .env file
@@ -44,84 +459,602 @@ func LoadConfig() (Config, error) {
 	}
 	log.Info().Msg(".env file loaded successfully")
 
-	// Parse float values with defaults
-	lucro, err := strconv.ParseFloat(os.Getenv("LUCRO"), 64)
+	loadMu.Lock()
+	defer loadMu.Unlock()
+	return load(true)
+}
+
+// Parse builds the configuration from values, keyed like the .env file,
+// instead of the process environment, for programs embedding the sync.
+// Unset keys take their defaults. The connection settings are only required
+// by connections, so callers passing open databases may leave them out.
+func Parse(values map[string]string) (Config, error) {
+	loadMu.Lock()
+	defer loadMu.Unlock()
+
+	getenv = func(key string) string { return values[key] }
+	environ = func() []string {
+		env := make([]string, 0, len(values))
+		for k, v := range values {
+			env = append(env, k+"="+v)
+		}
+		return env
+	}
+	defer func() { getenv, environ = os.Getenv, os.Environ }()
+	return load(false)
+}
+
+// load reads and validates the settings through getenv; connections
+// requires the Firebird and MySQL connection settings
+func load(connections bool) (Config, error) {
+	log := logger.GetLogger()
+
+	// Settings renamed for the structured configuration are still read by their old names
+	read := getenv
+	getenv = withDeprecations(read)
+	defer func() { getenv = read }()
+	warnDeprecated(environ())
+
+	if err := initParsing(); err != nil {
+		log.Error().Err(err).Msg("Invalid number format settings")
+		return Config{}, err
+	}
+
+	// Parse float values with defaults (written with NUMBER_DECIMAL_SEPARATOR)
+	lucro := getEnvFloat("LUCRO", 0)
+	parc3x := getEnvFloat("PARC3X", 0)
+	parc6x := getEnvFloat("PARC6X", 0)
+	parc10x := getEnvFloat("PARC10X", 0)
+
+	debugMode := getEnvBool("DEBUG_MODE", false)
+	devMode := getEnvBool("DEV_MODE", false)
+	autoUpdate := getEnvBool("AUTO_UPDATE", false)
+
+	// Set defaults if not provided
+	if lucro == 0 {
+		lucro = 40.00
+	}
+	if parc3x == 0 {
+		parc3x = 5.00
+	}
+	if parc6x == 0 {
+		parc6x = 10.00
+	}
+	if parc10x == 0 {
+		parc10x = 15.00
+	}
+
+	updateDir := getenv("UPDATE_DOWNLOAD_DIR")
+	if updateDir == "" {
+		updateDir = "."
+	}
+
+	floors, err := parseCategoryFloors(getenv("PRICE_FLOORS"))
 	if err != nil {
-		log.Warn().Err(err).Msg("Invalid LUCRO value, using default")
+		log.Error().Err(err).Msg("Invalid PRICE_FLOORS value")
+		return Config{}, err
 	}
-	parc3x, err := strconv.ParseFloat(os.Getenv("PARC3X"), 64)
+
+	spotCheckIDs, err := parseKeys(getenv("SPOT_CHECK_IDS"))
 	if err != nil {
-		log.Warn().Err(err).Msg("Invalid PARC3X value, using default")
+		log.Error().Err(err).Msg("Invalid SPOT_CHECK_IDS value")
+		return Config{}, err
+	}
+	verifySample := getEnvFloat("VERIFY_SAMPLE", 0)
+	if verifySample < 0 || verifySample > 100 {
+		log.Error().Float64("VERIFY_SAMPLE", verifySample).Msg("Invalid VERIFY_SAMPLE value")
+		return Config{}, fmt.Errorf("invalid VERIFY_SAMPLE %v: expected a percentage between 0 and 100", verifySample)
 	}
-	parc6x, err := strconv.ParseFloat(os.Getenv("PARC6X"), 64)
+
+	policy := strings.ToLower(getEnvString("PRICE_CONSTRAINT_POLICY", ConstraintClamp))
+	if policy != ConstraintClamp && policy != ConstraintFlag {
+		log.Error().Str("PRICE_CONSTRAINT_POLICY", policy).Msg("Invalid PRICE_CONSTRAINT_POLICY value")
+		return Config{}, fmt.Errorf("invalid PRICE_CONSTRAINT_POLICY %q: must be %q or %q", policy, ConstraintClamp, ConstraintFlag)
+	}
+
+	activeRuns, err := parseActiveRunsPolicy(getEnvString("UPDATE_ACTIVE_RUNS", ActiveRunsDefer))
 	if err != nil {
-		log.Warn().Err(err).Msg("Invalid PARC6X value, using default")
+		log.Error().Err(err).Msg("Invalid UPDATE_ACTIVE_RUNS value")
+		return Config{}, err
 	}
-	parc10x, err := strconv.ParseFloat(os.Getenv("PARC10X"), 64)
+
+	stockPolicy := strings.ToLower(getEnvString("STOCK_POLICY", StockAsIs))
+	switch stockPolicy {
+	case StockAsIs, StockZero, StockHide, StockSkip:
+	default:
+		log.Error().Str("STOCK_POLICY", stockPolicy).Msg("Invalid STOCK_POLICY value")
+		return Config{}, fmt.Errorf("invalid STOCK_POLICY %q: must be one of asis, zero, hide, skip", stockPolicy)
+	}
+
+	syncMode := strings.ToLower(getEnvString("SYNC_MODE", SyncFull))
+	if !validSyncMode(syncMode) {
+		log.Error().Str("SYNC_MODE", syncMode).Msg("Invalid SYNC_MODE value")
+		return Config{}, fmt.Errorf("invalid SYNC_MODE %q: must be one of %s", syncMode, strings.Join(syncModes, ", "))
+	}
+
+	routing := strings.ToLower(getEnvString("WORKER_ROUTING", ""))
+	if routing != "" && !slices.Contains(partition.Strategies, routing) {
+		log.Error().Str("WORKER_ROUTING", routing).Msg("Invalid WORKER_ROUTING value")
+		return Config{}, fmt.Errorf("invalid WORKER_ROUTING %q: must be empty or one of %s", routing, strings.Join(partition.Strategies, ", "))
+	}
+
+	isolation := strings.ToLower(getEnvString("FIREBIRD_ISOLATION", ""))
+	if isolation != "" && isolation != IsolationSnapshot && isolation != IsolationReadCommitted {
+		log.Error().Str("FIREBIRD_ISOLATION", isolation).Msg("Invalid FIREBIRD_ISOLATION value")
+		return Config{}, fmt.Errorf("invalid FIREBIRD_ISOLATION %q: must be empty, snapshot or read_committed", isolation)
+	}
+
+	priority := strings.ToLower(getEnvString("PROCESS_PRIORITY", PriorityNormal))
+	if priority != PriorityNormal && priority != PriorityLow && priority != PriorityIdle {
+		log.Error().Str("PROCESS_PRIORITY", priority).Msg("Invalid PROCESS_PRIORITY value")
+		return Config{}, fmt.Errorf("invalid PROCESS_PRIORITY %q: must be one of normal, low, idle", priority)
+	}
+
+	preload := strings.ToLower(getEnvString("MYSQL_PRELOAD", PreloadColumns))
+	if preload != PreloadColumns && preload != PreloadHash {
+		log.Error().Str("MYSQL_PRELOAD", preload).Msg("Invalid MYSQL_PRELOAD value")
+		return Config{}, fmt.Errorf("invalid MYSQL_PRELOAD %q: must be %q or %q", preload, PreloadColumns, PreloadHash)
+	}
+
+	visibilityColumn := getEnvString("STOCK_VISIBILITY_COLUMN", "VISIVEL")
+	if !IsValidIdentifier(visibilityColumn) {
+		log.Error().Str("STOCK_VISIBILITY_COLUMN", visibilityColumn).Msg("Invalid STOCK_VISIBILITY_COLUMN value")
+		return Config{}, fmt.Errorf("invalid STOCK_VISIBILITY_COLUMN %q", visibilityColumn)
+	}
+
+	softDeleteColumn := getEnvString("SOFT_DELETE_COLUMN", "")
+	if softDeleteColumn != "" && !IsValidIdentifier(softDeleteColumn) {
+		log.Error().Str("SOFT_DELETE_COLUMN", softDeleteColumn).Msg("Invalid SOFT_DELETE_COLUMN value")
+		return Config{}, fmt.Errorf("invalid SOFT_DELETE_COLUMN %q", softDeleteColumn)
+	}
+	softDeleteStyle := strings.ToLower(getEnvString("SOFT_DELETE_STYLE", SoftDeleteFlag))
+	if softDeleteStyle != SoftDeleteFlag && softDeleteStyle != SoftDeleteTimestamp {
+		log.Error().Str("SOFT_DELETE_STYLE", softDeleteStyle).Msg("Invalid SOFT_DELETE_STYLE value")
+		return Config{}, fmt.Errorf("invalid SOFT_DELETE_STYLE %q: must be %q or %q", softDeleteStyle, SoftDeleteFlag, SoftDeleteTimestamp)
+	}
+
+	statusMap, err := parseStatusMap(getenv("STATUS_MAP"))
 	if err != nil {
-		log.Warn().Err(err).Msg("Invalid PARC10X value, using default")
+		log.Error().Err(err).Msg("Invalid STATUS_MAP value")
+		return Config{}, err
 	}
 
-	// Parse debug mode
-	debugMode, err := strconv.ParseBool(os.Getenv("DEBUG_MODE"))
+	rowFilters, err := parseRowFilters(getenv("ROW_FILTERS"))
 	if err != nil {
-		log.Warn().Err(err).Str("DEBUG_MODE", os.Getenv("DEBUG_MODE")).Msg("Invalid DEBUG_MODE value, defaulting to false")
+		log.Error().Err(err).Msg("Invalid ROW_FILTERS value")
+		return Config{}, err
+	}
+
+	var pricingRules []PricingRule
+	pricingRulesFile := getEnvString("PRICING_RULES_FILE", "")
+	if pricingRulesFile != "" {
+		global := Margins{Lucro: lucro, Parc3x: parc3x, Parc6x: parc6x, Parc10x: parc10x}
+		if pricingRules, err = loadPricingRules(pricingRulesFile, global); err != nil {
+			log.Error().Err(err).Msg("Invalid PRICING_RULES_FILE")
+			return Config{}, fmt.Errorf("invalid PRICING_RULES_FILE: %w", err)
+		}
+	}
+	supplierColumn := getEnvString("PRICING_SUPPLIER_COLUMN", "")
+	if supplierColumn != "" && !IsValidIdentifier(supplierColumn) {
+		log.Error().Str("PRICING_SUPPLIER_COLUMN", supplierColumn).Msg("Invalid PRICING_SUPPLIER_COLUMN value")
+		return Config{}, fmt.Errorf("invalid PRICING_SUPPLIER_COLUMN %q", supplierColumn)
+	}
+
+	exchangeProvider := strings.ToLower(getEnvString("EXCHANGE_RATE_PROVIDER", ""))
+	if exchangeProvider != "" && !slices.Contains(exchange.Providers, exchangeProvider) {
+		log.Error().Str("EXCHANGE_RATE_PROVIDER", exchangeProvider).Msg("Invalid EXCHANGE_RATE_PROVIDER value")
+		return Config{}, fmt.Errorf("invalid EXCHANGE_RATE_PROVIDER %q: must be one of %s", exchangeProvider, strings.Join(exchange.Providers, ", "))
 	}
 
-	// Parse dev mode
-	devMode, err := strconv.ParseBool(os.Getenv("DEV_MODE"))
+	rowFilterMode := strings.ToLower(getEnvString("ROW_FILTER_MODE", FilterSQL))
+	if rowFilterMode != FilterSQL && rowFilterMode != FilterScan {
+		log.Error().Str("ROW_FILTER_MODE", rowFilterMode).Msg("Invalid ROW_FILTER_MODE value")
+		return Config{}, fmt.Errorf("invalid ROW_FILTER_MODE %q: must be %q or %q", rowFilterMode, FilterSQL, FilterScan)
+	}
+
+	statusColumn := getEnvString("STATUS_COLUMN", "STATUS")
+	if !IsValidIdentifier(statusColumn) {
+		log.Error().Str("STATUS_COLUMN", statusColumn).Msg("Invalid STATUS_COLUMN value")
+		return Config{}, fmt.Errorf("invalid STATUS_COLUMN %q", statusColumn)
+	}
+
+	catalogTable := getEnvString("CATALOG_TABLE", "")
+	catalogIDColumn := getEnvString("CATALOG_ID_COLUMN", "ID_ESTOQUE")
+	catalogCodeColumn := getEnvString("CATALOG_CODE_COLUMN", "EAN")
+	if (catalogTable != "" && !IsValidIdentifier(catalogTable)) || !IsValidIdentifier(catalogIDColumn) || !IsValidIdentifier(catalogCodeColumn) {
+		log.Error().
+			Str("CATALOG_TABLE", catalogTable).
+			Str("CATALOG_ID_COLUMN", catalogIDColumn).
+			Str("CATALOG_CODE_COLUMN", catalogCodeColumn).
+			Msg("Invalid catalog table or column name")
+		return Config{}, fmt.Errorf("invalid catalog table or column name")
+	}
+
+	changedIDsProcedure := getEnvString("CHANGED_IDS_PROCEDURE", "")
+	if changedIDsProcedure != "" && !IsValidIdentifier(changedIDsProcedure) {
+		log.Error().Str("CHANGED_IDS_PROCEDURE", changedIDsProcedure).Msg("Invalid CHANGED_IDS_PROCEDURE value")
+		return Config{}, fmt.Errorf("invalid CHANGED_IDS_PROCEDURE %q", changedIDsProcedure)
+	}
+	procedureBatchSize := getEnvInt("PROCEDURE_BATCH_SIZE", 500)
+	if procedureBatchSize <= 0 {
+		log.Warn().Int("PROCEDURE_BATCH_SIZE", procedureBatchSize).Msg("PROCEDURE_BATCH_SIZE must be positive, using 500")
+		procedureBatchSize = 500
+	}
+
+	metricsFormat := strings.ToLower(getEnvString("METRICS_PUSH_FORMAT", "prometheus"))
+	if metricsFormat != "prometheus" && metricsFormat != "influx" {
+		log.Error().Str("METRICS_PUSH_FORMAT", metricsFormat).Msg("Invalid METRICS_PUSH_FORMAT value")
+		return Config{}, fmt.Errorf("invalid METRICS_PUSH_FORMAT %q: expected prometheus or influx", metricsFormat)
+	}
+
+	telegramSeverity, err := notify.ParseSeverity(getEnvString("NOTIFY_TELEGRAM_SEVERITY", notify.SeverityWarning))
 	if err != nil {
-		log.Warn().Err(err).Str("DEV_MODE", os.Getenv("DEV_MODE")).Msg("Invalid DEV_MODE value, defaulting to false")
+		log.Error().Err(err).Msg("Invalid NOTIFY_TELEGRAM_SEVERITY value")
+		return Config{}, fmt.Errorf("invalid NOTIFY_TELEGRAM_SEVERITY: %w", err)
+	}
+	if _, err := notify.ParseTemplate("NOTIFY_TELEGRAM_TEMPLATE", getenv("NOTIFY_TELEGRAM_TEMPLATE")); err != nil {
+		log.Error().Err(err).Msg("Invalid NOTIFY_TELEGRAM_TEMPLATE value")
+		return Config{}, err
+	}
+	if getenv("NOTIFY_TELEGRAM_TOKEN") != "" && getenv("NOTIFY_TELEGRAM_CHAT_ID") == "" {
+		log.Error().Msg("Invalid NOTIFY_TELEGRAM_CHAT_ID value")
+		return Config{}, fmt.Errorf("NOTIFY_TELEGRAM_CHAT_ID is required with NOTIFY_TELEGRAM_TOKEN")
 	}
 
-	// Parse update settings
-	autoUpdate, err := strconv.ParseBool(os.Getenv("AUTO_UPDATE"))
+	whatsAppProvider := strings.ToLower(getEnvString("NOTIFY_WHATSAPP_PROVIDER", WhatsAppTwilio))
+	if whatsAppProvider != WhatsAppTwilio && whatsAppProvider != WhatsAppWebhook {
+		log.Error().Str("NOTIFY_WHATSAPP_PROVIDER", whatsAppProvider).Msg("Invalid NOTIFY_WHATSAPP_PROVIDER value")
+		return Config{}, fmt.Errorf("invalid NOTIFY_WHATSAPP_PROVIDER %q: expected twilio or webhook", whatsAppProvider)
+	}
+	whatsAppSeverity, err := notify.ParseSeverity(getEnvString("NOTIFY_WHATSAPP_SEVERITY", notify.SeverityWarning))
 	if err != nil {
-		log.Warn().Err(err).Str("AUTO_UPDATE", os.Getenv("AUTO_UPDATE")).Msg("Invalid AUTO_UPDATE value, defaulting to false")
+		log.Error().Err(err).Msg("Invalid NOTIFY_WHATSAPP_SEVERITY value")
+		return Config{}, fmt.Errorf("invalid NOTIFY_WHATSAPP_SEVERITY: %w", err)
+	}
+	if _, err := notify.ParseTemplate("NOTIFY_WHATSAPP_TEMPLATE", getenv("NOTIFY_WHATSAPP_TEMPLATE")); err != nil {
+		log.Error().Err(err).Msg("Invalid NOTIFY_WHATSAPP_TEMPLATE value")
+		return Config{}, err
+	}
+	if getenv("NOTIFY_WHATSAPP_TO") != "" {
+		switch {
+		case whatsAppProvider == WhatsAppWebhook && getenv("NOTIFY_WHATSAPP_URL") == "":
+			log.Error().Msg("Invalid NOTIFY_WHATSAPP_URL value")
+			return Config{}, fmt.Errorf("NOTIFY_WHATSAPP_URL is required with NOTIFY_WHATSAPP_PROVIDER=webhook")
+		case whatsAppProvider == WhatsAppTwilio && (getenv("NOTIFY_WHATSAPP_ACCOUNT_SID") == "" || getenv("NOTIFY_WHATSAPP_TOKEN") == "" || getenv("NOTIFY_WHATSAPP_FROM") == ""):
+			log.Error().Msg("Invalid NOTIFY_WHATSAPP_ACCOUNT_SID value")
+			return Config{}, fmt.Errorf("NOTIFY_WHATSAPP_ACCOUNT_SID, NOTIFY_WHATSAPP_TOKEN and NOTIFY_WHATSAPP_FROM are required with NOTIFY_WHATSAPP_PROVIDER=twilio")
+		}
 	}
 
-	// Set defaults if not provided
-	if lucro == 0 {
-		lucro = 40.00
+	maintenanceRetry := getEnvDuration("FIREBIRD_MAINTENANCE_RETRY", time.Minute)
+	if maintenanceRetry <= 0 {
+		log.Error().Dur("FIREBIRD_MAINTENANCE_RETRY", maintenanceRetry).Msg("Invalid FIREBIRD_MAINTENANCE_RETRY value")
+		return Config{}, fmt.Errorf("invalid FIREBIRD_MAINTENANCE_RETRY %s: must be positive", maintenanceRetry)
 	}
-	if parc3x == 0 {
-		parc3x = 5.00
+
+	diffFormat := strings.ToLower(getEnvString("DIFF_REPORT_FORMAT", DiffReportCSV))
+	if diffFormat != DiffReportCSV && diffFormat != DiffReportJSON {
+		log.Error().Str("DIFF_REPORT_FORMAT", diffFormat).Msg("Invalid DIFF_REPORT_FORMAT value")
+		return Config{}, fmt.Errorf("invalid DIFF_REPORT_FORMAT %q: expected csv or json", diffFormat)
 	}
-	if parc6x == 0 {
-		parc6x = 10.00
+
+	replayFormat := strings.ToLower(getEnvString("REPLAY_LOG_FORMAT", replay.FormatSQL))
+	if replayFormat != replay.FormatSQL && replayFormat != replay.FormatBinary {
+		log.Error().Str("REPLAY_LOG_FORMAT", replayFormat).Msg("Invalid REPLAY_LOG_FORMAT value")
+		return Config{}, fmt.Errorf("invalid REPLAY_LOG_FORMAT %q: expected sql or binary", replayFormat)
 	}
-	if parc10x == 0 {
-		parc10x = 15.00
+	replayMaxSize := getEnvInt("REPLAY_LOG_MAX_SIZE_MB", 100)
+	if replayMaxSize <= 0 {
+		log.Error().Int("REPLAY_LOG_MAX_SIZE_MB", replayMaxSize).Msg("Invalid REPLAY_LOG_MAX_SIZE_MB value")
+		return Config{}, fmt.Errorf("invalid REPLAY_LOG_MAX_SIZE_MB %d: must be positive", replayMaxSize)
+	}
+	workDirRetention := getEnvDuration("WORK_DIR_RETENTION", 7*24*time.Hour)
+	if workDirRetention <= 0 {
+		log.Error().Dur("WORK_DIR_RETENTION", workDirRetention).Msg("Invalid WORK_DIR_RETENTION value")
+		return Config{}, fmt.Errorf("invalid WORK_DIR_RETENTION %s: must be positive, or the workspaces of running syncs could be removed", workDirRetention)
 	}
 
-	updateDir := os.Getenv("UPDATE_DOWNLOAD_DIR")
-	if updateDir == "" {
-		updateDir = "."
+	incrementalColumn := getEnvString("INCREMENTAL_COLUMN", "")
+	if incrementalColumn != "" && !IsValidIdentifier(incrementalColumn) {
+		log.Error().Str("INCREMENTAL_COLUMN", incrementalColumn).Msg("Invalid INCREMENTAL_COLUMN value")
+		return Config{}, fmt.Errorf("invalid INCREMENTAL_COLUMN %q", incrementalColumn)
+	}
+
+	tables, err := parseTableMappings(getenv("SYNC_TABLES"))
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid table mapping")
+		return Config{}, err
+	}
+
+	syncOnly, err := parseScope(getenv("SYNC_ONLY"), getenv("SYNC_SCOPE"), tables)
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid SYNC_ONLY or SYNC_SCOPE value")
+		return Config{}, err
+	}
+
+	jobs, err := parseJobs(getenv("SYNC_JOBS"), syncMode, tables)
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid job schedule")
+		return Config{}, err
+	}
+
+	productColumns, err := parseProductColumns(getenv("PRODUCT_COLUMN_MAP"))
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid PRODUCT_COLUMN_MAP value")
+		return Config{}, err
+	}
+	extraColumns, err := parseExtraColumns(getenv("PRODUCT_EXTRA_COLUMNS"))
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid PRODUCT_EXTRA_COLUMNS value")
+		return Config{}, err
+	}
+	transforms, err := parseTransforms(getenv("PRODUCT_TRANSFORMS"))
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid PRODUCT_TRANSFORMS value")
+		return Config{}, err
 	}
 
 	cfg := Config{
-		FirebirdUser:      os.Getenv("FIREBIRD_USER"),
-		FirebirdPassword:  os.Getenv("FIREBIRD_PASSWORD"),
-		FirebirdHost:      os.Getenv("FIREBIRD_HOST"),
-		FirebirdPath:      os.Getenv("FIREBIRD_PATH"),
-		MySQLUser:         os.Getenv("MYSQL_USER"),
-		MySQLPassword:     os.Getenv("MYSQL_PASSWORD"),
-		MySQLHost:         os.Getenv("MYSQL_HOST"),
-		MySQLPort:         os.Getenv("MYSQL_PORT"),
-		MySQLDatabase:     os.Getenv("MYSQL_DATABASE"),
+		FirebirdUser:      getenv("FIREBIRD_USER"),
+		FirebirdPassword:  getenv("FIREBIRD_PASSWORD"),
+		FirebirdHost:      getenv("FIREBIRD_HOST"),
+		FirebirdPath:      getenv("FIREBIRD_PATH"),
+		MySQLUser:         getenv("MYSQL_USER"),
+		MySQLPassword:     getenv("MYSQL_PASSWORD"),
+		MySQLHost:         getenv("MYSQL_HOST"),
+		MySQLPort:         getenv("MYSQL_PORT"),
+		MySQLDatabase:     getenv("MYSQL_DATABASE"),
 		Lucro:             lucro,
+		SyncMode:          syncMode,
 		Parc3x:            parc3x,
 		Parc6x:            parc6x,
 		Parc10x:           parc10x,
 		DebugMode:         debugMode,
 		DevMode:           devMode,
-		UpdateCheckURL:    os.Getenv("UPDATE_CHECK_URL"),
+		TUI:               getEnvBool("PROGRESS_TUI", false),
+		ProgressLine:      getEnvBool("PROGRESS_LINE", true),
+		UpdateCheckURL:    getenv("UPDATE_CHECK_URL"),
 		AutoUpdate:        autoUpdate,
 		UpdateDownloadDir: updateDir,
+
+		UpdateActiveRuns:     activeRuns,
+		UpdateActiveRunsWait: max(getEnvDuration("UPDATE_ACTIVE_RUNS_WAIT", defaultActiveRunsWait), 0),
+
+		PriceHistoryEnabled:       getEnvBool("PRICE_HISTORY_ENABLED", false),
+		PriceHistoryRetentionDays: getEnvInt("PRICE_HISTORY_RETENTION_DAYS", 0),
+		AuditEnabled:              getEnvBool("AUDIT_ENABLED", false),
+		AuditRetentionDays:        getEnvInt("AUDIT_RETENTION_DAYS", 0),
+		DiffReportFile:            getEnvString("DIFF_REPORT_FILE", ""),
+		DiffReportFormat:          diffFormat,
+
+		ReplayLogFile:      getEnvString("REPLAY_LOG_FILE", ""),
+		ReplayLogFormat:    replayFormat,
+		ReplayLogMaxSizeMB: replayMaxSize,
+
+		MinMargin:             getEnvFloat("PRICE_MIN_MARGIN", 0),
+		MaxPriceDrop:          getEnvFloat("PRICE_MAX_DROP", 0),
+		CategoryFloors:        floors,
+		PriceConstraintPolicy: policy,
+
+		PricingRulesFile:      pricingRulesFile,
+		PricingSupplierColumn: supplierColumn,
+		PricingRules:          pricingRules,
+
+		ProtectedRowsQuery: getenv("PROTECTED_ROWS_QUERY"),
+		ReservationsQuery:  getenv("RESERVATIONS_QUERY"),
+
+		StockPolicy:           stockPolicy,
+		StockVisibilityColumn: visibilityColumn,
+
+		SoftDeleteColumn: softDeleteColumn,
+		SoftDeleteStyle:  softDeleteStyle,
+
+		CustomerQuery:  strings.TrimSpace(getenv("CUSTOMER_QUERY")),
+		CategoryQuery:  strings.TrimSpace(getenv("CATEGORY_QUERY")),
+		WarehouseQuery: strings.TrimSpace(getenv("WAREHOUSE_QUERY")),
+		MovementQuery:  strings.TrimSpace(getenv("MOVEMENT_QUERY")),
+
+		StatusMap:     statusMap,
+		RowFilters:    rowFilters,
+		RowFilterMode: rowFilterMode,
+		StatusColumn:  statusColumn,
+
+		CatalogSourceQuery: strings.TrimSpace(getenv("CATALOG_SOURCE_QUERY")),
+		CatalogTable:       catalogTable,
+		CatalogIDColumn:    catalogIDColumn,
+		CatalogCodeColumn:  catalogCodeColumn,
+		CatalogAutoCreate:  getEnvBool("CATALOG_AUTO_CREATE", false),
+
+		ChangedIDsProcedure: changedIDsProcedure,
+		ProcedureBatchSize:  procedureBatchSize,
+
+		PostSyncSQL: strings.TrimSpace(getenv("POST_SYNC_SQL")),
+
+		MetricsPushURL:    getEnvString("METRICS_PUSH_URL", ""),
+		MetricsPushFormat: metricsFormat,
+		MetricsPushToken:  getenv("METRICS_PUSH_TOKEN"),
+		MetricsJob:        getEnvString("METRICS_JOB", "sync"),
+
+		NotifyTelegramToken:    getenv("NOTIFY_TELEGRAM_TOKEN"),
+		NotifyTelegramChatID:   getEnvString("NOTIFY_TELEGRAM_CHAT_ID", ""),
+		NotifyTelegramSeverity: telegramSeverity,
+		NotifyTelegramTemplate: getenv("NOTIFY_TELEGRAM_TEMPLATE"),
+		NotifyTelegramAPIURL:   getEnvString("NOTIFY_TELEGRAM_API_URL", ""),
+
+		NotifyWhatsAppProvider:   whatsAppProvider,
+		NotifyWhatsAppURL:        getEnvString("NOTIFY_WHATSAPP_URL", ""),
+		NotifyWhatsAppAccountSID: getEnvString("NOTIFY_WHATSAPP_ACCOUNT_SID", ""),
+		NotifyWhatsAppToken:      getenv("NOTIFY_WHATSAPP_TOKEN"),
+		NotifyWhatsAppFrom:       getEnvString("NOTIFY_WHATSAPP_FROM", ""),
+		NotifyWhatsAppTo:         getEnvString("NOTIFY_WHATSAPP_TO", ""),
+		NotifyWhatsAppSeverity:   whatsAppSeverity,
+		NotifyWhatsAppTemplate:   getenv("NOTIFY_WHATSAPP_TEMPLATE"),
+
+		NotifyTopChanges:      max(getEnvInt("NOTIFY_TOP_CHANGES", 5), 0),
+		NotifyChangeThreshold: max(getEnvFloat("NOTIFY_CHANGE_THRESHOLD", 50), 0),
+
+		WatchdogTimeout: getEnvDuration("WATCHDOG_TIMEOUT", 30*time.Minute),
+
+		HeapLimitMB:       max(getEnvInt("HEAP_LIMIT_MB", 0), 0),
+		HeapCheckInterval: max(getEnvDuration("HEAP_CHECK_INTERVAL", time.Second), 100*time.Millisecond),
+
+		RecoveryAttempts: max(getEnvInt("RECOVERY_ATTEMPTS", 0), 0),
+		RecoveryBackoff:  getEnvDuration("RECOVERY_BACKOFF", time.Minute),
+
+		MaintenanceWait:  max(getEnvDuration("FIREBIRD_MAINTENANCE_WAIT", 0), 0),
+		MaintenanceRetry: maintenanceRetry,
+
+		BatchRetries:      max(getEnvInt("BATCH_RETRIES", 3), 0),
+		BatchRetryBackoff: getEnvDuration("BATCH_RETRY_BACKOFF", 200*time.Millisecond),
+
+		BatchIsolateErrors: getEnvBool("BATCH_ISOLATE_ERRORS", false),
+		UpsertWrites:       getEnvBool("UPSERT_WRITES", false),
+		WorkerRouting:      routing,
+
+		StateFile:        getEnvString("STATE_FILE", defaultStateFile),
+		WorkDir:          getEnvString("WORK_DIR", ""),
+		WorkDirRetention: workDirRetention,
+		ReadOnly:         getEnvBool("READ_ONLY", false),
+
+		IncrementalColumn:  incrementalColumn,
+		IncrementalOverlap: getEnvDuration("INCREMENTAL_OVERLAP", time.Minute),
+		SourceChunkSize:    max(getEnvInt("SOURCE_CHUNK_SIZE", 0), 0),
+		FirebirdIsolation:  isolation,
+		SourceReaders:      max(getEnvInt("SOURCE_READERS", 1), 1),
+		RowLimit:           max(getEnvInt("ROW_LIMIT", 0), 0),
+		ClockSkewMax:       max(getEnvDuration("CLOCK_SKEW_MAX", time.Minute), 0),
+		ClockSkewStrict:    getEnvBool("CLOCK_SKEW_STRICT", false),
+
+		ExchangeRateProvider: exchangeProvider,
+		ExchangeRateURL:      getEnvString("EXCHANGE_RATE_URL", ""),
+		ExchangeRateCacheTTL: max(getEnvDuration("EXCHANGE_RATE_CACHE_TTL", 6*time.Hour), 0),
+		ExchangeRateFallback: max(getEnvFloat("EXCHANGE_RATE_FALLBACK", 0), 0),
+
+		DecimalSeparator:   numberFormat.Decimal,
+		ThousandsSeparator: numberFormat.Thousands,
+		StrictConfig:       strictConfig,
+
+		Tables: tables,
+
+		SyncOnly:  syncOnly,
+		SyncScope: strings.ToLower(strings.TrimSpace(getenv("SYNC_SCOPE"))),
+
+		ProductColumns:      productColumns,
+		ProductExtraColumns: extraColumns,
+		ProductTransforms:   transforms,
+
+		Jobs:                 jobs,
+		DaemonStartupWait:    max(getEnvDuration("DAEMON_STARTUP_WAIT", 10*time.Minute), 0),
+		DaemonStartupBackoff: max(getEnvDuration("DAEMON_STARTUP_BACKOFF", 5*time.Second), time.Second),
+
+		MaxProcs:        max(getEnvInt("MAX_PROCS", 0), 0),
+		MaxWorkers:      max(getEnvInt("MAX_WORKERS", 0), 0),
+		ProcessPriority: priority,
+
+		DiskMinFreeMB: max(getEnvInt("DISK_MIN_FREE_MB", defaultDiskMinFreeMB), 0),
+
+		SpotCheckIDs:    spotCheckIDs,
+		SpotCheckSample: max(getEnvInt("SPOT_CHECK_SAMPLE", 0), 0),
+		VerifySample:    verifySample,
+
+		MySQLPreload: preload,
+
+		FeatureFlagsURL: getEnvString("FEATURE_FLAGS_URL", ""),
+	}
+	cfg.ChangedKeysEnabled = getEnvBool("CHANGED_KEYS_ENABLED", cfg.PostSyncSQL != "")
+
+	if (cfg.QuantityOnly() || slices.ContainsFunc(jobs, func(j Job) bool { return j.Mode == SyncQuantity })) && cfg.ProductColumn("QTD_ATUAL") == "" {
+		log.Error().Msg("SYNC_MODE=quantity with QTD_ATUAL left out by PRODUCT_COLUMN_MAP")
+		return Config{}, fmt.Errorf("SYNC_MODE=%s needs the QTD_ATUAL column, which PRODUCT_COLUMN_MAP leaves out", SyncQuantity)
+	}
+
+	cfg.ProductComparators, err = parseComparators(getenv("PRODUCT_COMPARATORS"), cfg.ProductColumnNames())
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid PRODUCT_COMPARATORS value")
+		return Config{}, err
+	}
+
+	if cfg.NullPolicies, err = parseNullPolicies(getenv("NULL_POLICIES")); err != nil {
+		log.Error().Err(err).Msg("Invalid NULL_POLICIES value")
+		return Config{}, fmt.Errorf("invalid NULL_POLICIES: %w", err)
+	}
+	for field := range cfg.NullPolicies {
+		if cfg.ProductColumn(field) == "" {
+			log.Error().Str("field", field).Msg("NULL_POLICIES entry for a column left out by PRODUCT_COLUMN_MAP")
+			return Config{}, fmt.Errorf("invalid NULL_POLICIES: %s is left out by PRODUCT_COLUMN_MAP", field)
+		}
+	}
+
+	if cfg.HashPreload() {
+		if err := validateHashPreload(cfg); err != nil {
+			log.Error().Err(err).Msg("Invalid MYSQL_PRELOAD value")
+			return Config{}, err
+		}
+	}
+
+	if cfg.UsesSupplier() && cfg.PricingSupplierColumn == "" {
+		log.Error().Msg("PRICING_RULES_FILE tests SUPPLIER but PRICING_SUPPLIER_COLUMN is not set")
+		return Config{}, fmt.Errorf("PRICING_RULES_FILE tests SUPPLIER: set PRICING_SUPPLIER_COLUMN")
+	}
+
+	if cfg.ReverseColumns, err = parseReverseColumns(getenv("REVERSE_SYNC_COLUMNS"), cfg); err != nil {
+		log.Error().Err(err).Msg("Invalid REVERSE_SYNC_COLUMNS value")
+		return Config{}, fmt.Errorf("invalid REVERSE_SYNC_COLUMNS: %w", err)
+	}
+	cfg.ReverseConflict = strings.ToLower(getEnvString("REVERSE_SYNC_CONFLICT", ReverseConflictSkip))
+	if cfg.ReverseConflict != ReverseConflictSkip && cfg.ReverseConflict != ReverseConflictMySQL {
+		log.Error().Str("REVERSE_SYNC_CONFLICT", cfg.ReverseConflict).Msg("Invalid REVERSE_SYNC_CONFLICT value")
+		return Config{}, fmt.Errorf("invalid REVERSE_SYNC_CONFLICT %q: must be %q or %q", cfg.ReverseConflict, ReverseConflictSkip, ReverseConflictMySQL)
+	}
+
+	if cfg.BlobColumns, err = parseBlobColumns(getenv("BLOB_COLUMNS"), cfg); err != nil {
+		log.Error().Err(err).Msg("Invalid BLOB_COLUMNS value")
+		return Config{}, fmt.Errorf("invalid BLOB_COLUMNS: %w", err)
+	}
+	cfg.BlobMaxBytes = getEnvInt("BLOB_MAX_BYTES", defaultBlobMaxBytes)
+	if cfg.BlobMaxBytes <= 0 {
+		log.Error().Int("BLOB_MAX_BYTES", cfg.BlobMaxBytes).Msg("Invalid BLOB_MAX_BYTES value")
+		return Config{}, fmt.Errorf("invalid BLOB_MAX_BYTES %d: must be positive", cfg.BlobMaxBytes)
+	}
+
+	if cfg.MovementQuery != "" && strings.Count(cfg.MovementQuery, "?") != 1 {
+		log.Error().Str("MOVEMENT_QUERY", cfg.MovementQuery).Msg("Invalid MOVEMENT_QUERY value")
+		return Config{}, fmt.Errorf("MOVEMENT_QUERY must have one '?' for the last movement ID synced")
+	}
+
+	cfg.OrderExportQuery = strings.TrimSpace(getenv("ORDER_EXPORT_QUERY"))
+	cfg.OrderItemsQuery = strings.TrimSpace(getenv("ORDER_ITEMS_QUERY"))
+	cfg.OrderPendingStatus = getEnvString("ORDER_PENDING_STATUS", "P")
+	if cfg.OrderExportQuery != "" && strings.Count(cfg.OrderItemsQuery, "?") != 1 {
+		log.Error().Str("ORDER_ITEMS_QUERY", cfg.OrderItemsQuery).Msg("Invalid ORDER_ITEMS_QUERY value")
+		return Config{}, fmt.Errorf("ORDER_EXPORT_QUERY requires ORDER_ITEMS_QUERY with one '?' for the order ID")
+	}
+
+	featureFlags, err := flags.Parse(getenv("FEATURE_FLAGS"))
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid FEATURE_FLAGS value")
+		return Config{}, fmt.Errorf("invalid FEATURE_FLAGS: %w", err)
+	}
+	if cfg, err = cfg.WithFlags(featureFlags); err != nil {
+		log.Error().Err(err).Msg("Invalid FEATURE_FLAGS value")
+		return Config{}, fmt.Errorf("invalid FEATURE_FLAGS: %w", err)
+	}
+
+	if cfg.SourceReaders > 1 && cfg.FirebirdIsolation != IsolationSnapshot {
+		log.Warn().Int("SOURCE_READERS", cfg.SourceReaders).Msg("Parallel readers without FIREBIRD_ISOLATION=snapshot may read related rows before and after the same ERP change")
+	}
+
+	validatePercentages(cfg)
+	validateKeys(cfg, environ())
+	if len(envProblems) > 0 {
+		log.Error().Strs("problems", envProblems).Msg("Invalid configuration (CONFIG_STRICT)")
+		return Config{}, fmt.Errorf("invalid configuration (%d problems): %s", len(envProblems), strings.Join(envProblems, "; "))
 	}
 
 	// Validate required fields (skip validation in dev mode)
-	if !cfg.DevMode {
+	if cfg.DevMode {
+		log.Info().Msg("DEV_MODE enabled - using SQLite mocks for Firebird and MySQL")
+	} else if connections {
 		if cfg.FirebirdUser == "" || cfg.FirebirdPassword == "" || cfg.FirebirdHost == "" || cfg.FirebirdPath == "" {
 			log.Error().Msg("Missing required Firebird environment variables")
 			return Config{}, fmt.Errorf("missing required Firebird environment variables")
@@ -130,8 +1063,6 @@ func LoadConfig() (Config, error) {
 			log.Error().Msg("Missing required MySQL environment variables")
 			return Config{}, fmt.Errorf("missing required MySQL environment variables")
 		}
-	} else {
-		log.Info().Msg("DEV_MODE enabled - using SQLite mocks for Firebird and MySQL")
 	}
 
 	// Log loaded configuration for troubleshooting
@@ -148,16 +1079,130 @@ func LoadConfig() (Config, error) {
 		Float64("PARC6X", cfg.Parc6x).
 		Float64("PARC10X", cfg.Parc10x).
 		Bool("DEBUG_MODE", cfg.DebugMode).
+		Str("SYNC_MODE", cfg.SyncMode).
 		Bool("DEV_MODE", cfg.DevMode).
+		Bool("PROGRESS_TUI", cfg.TUI).
+		Bool("PROGRESS_LINE", cfg.ProgressLine).
 		Str("UPDATE_CHECK_URL", cfg.UpdateCheckURL).
 		Bool("AUTO_UPDATE", cfg.AutoUpdate).
 		Str("UPDATE_DOWNLOAD_DIR", cfg.UpdateDownloadDir).
+		Str("UPDATE_ACTIVE_RUNS", cfg.UpdateActiveRuns).
+		Dur("UPDATE_ACTIVE_RUNS_WAIT", cfg.UpdateActiveRunsWait).
+		Bool("PRICE_HISTORY_ENABLED", cfg.PriceHistoryEnabled).
+		Int("PRICE_HISTORY_RETENTION_DAYS", cfg.PriceHistoryRetentionDays).
+		Bool("AUDIT_ENABLED", cfg.AuditEnabled).
+		Int("AUDIT_RETENTION_DAYS", cfg.AuditRetentionDays).
+		Str("DIFF_REPORT_FILE", cfg.DiffReportFile).
+		Str("DIFF_REPORT_FORMAT", cfg.DiffReportFormat).
+		Str("REPLAY_LOG_FILE", cfg.ReplayLogFile).
+		Str("REPLAY_LOG_FORMAT", cfg.ReplayLogFormat).
+		Int("REPLAY_LOG_MAX_SIZE_MB", cfg.ReplayLogMaxSizeMB).
+		Float64("PRICE_MIN_MARGIN", cfg.MinMargin).
+		Float64("PRICE_MAX_DROP", cfg.MaxPriceDrop).
+		Int("PRICE_FLOORS", len(cfg.CategoryFloors)).
+		Str("PRICE_CONSTRAINT_POLICY", cfg.PriceConstraintPolicy).
+		Str("PRICING_RULES_FILE", cfg.PricingRulesFile).
+		Int("pricing_rules", len(cfg.PricingRules)).
+		Str("PRICING_SUPPLIER_COLUMN", cfg.PricingSupplierColumn).
+		Str("PROTECTED_ROWS_QUERY", cfg.ProtectedRowsQuery).
+		Str("RESERVATIONS_QUERY", cfg.ReservationsQuery).
+		Str("STOCK_POLICY", cfg.StockPolicy).
+		Str("STOCK_VISIBILITY_COLUMN", cfg.StockVisibilityColumn).
+		Str("SOFT_DELETE_COLUMN", cfg.SoftDeleteColumn).
+		Str("SOFT_DELETE_STYLE", cfg.SoftDeleteStyle).
+		Str("CUSTOMER_QUERY", cfg.CustomerQuery).
+		Str("CATEGORY_QUERY", cfg.CategoryQuery).
+		Str("WAREHOUSE_QUERY", cfg.WarehouseQuery).
+		Str("MOVEMENT_QUERY", cfg.MovementQuery).
+		Interface("STATUS_MAP", cfg.StatusMap).
+		Interface("ROW_FILTERS", cfg.RowFilters).
+		Str("ROW_FILTER_MODE", cfg.RowFilterMode).
+		Str("STATUS_COLUMN", cfg.StatusColumn).
+		Str("CATALOG_SOURCE_QUERY", cfg.CatalogSourceQuery).
+		Str("CATALOG_TABLE", cfg.CatalogTable).
+		Str("CATALOG_ID_COLUMN", cfg.CatalogIDColumn).
+		Str("CATALOG_CODE_COLUMN", cfg.CatalogCodeColumn).
+		Bool("CATALOG_AUTO_CREATE", cfg.CatalogAutoCreate).
+		Str("CHANGED_IDS_PROCEDURE", cfg.ChangedIDsProcedure).
+		Int("PROCEDURE_BATCH_SIZE", cfg.ProcedureBatchSize).
+		Bool("CHANGED_KEYS_ENABLED", cfg.ChangedKeysEnabled).
+		Str("POST_SYNC_SQL", cfg.PostSyncSQL).
+		Str("METRICS_PUSH_URL", cfg.MetricsPushURL).
+		Str("METRICS_PUSH_FORMAT", cfg.MetricsPushFormat).
+		Str("METRICS_JOB", cfg.MetricsJob).
+		Str("NOTIFY_TELEGRAM_CHAT_ID", cfg.NotifyTelegramChatID).
+		Str("NOTIFY_TELEGRAM_SEVERITY", cfg.NotifyTelegramSeverity).
+		Str("NOTIFY_WHATSAPP_PROVIDER", cfg.NotifyWhatsAppProvider).
+		Str("NOTIFY_WHATSAPP_TO", cfg.NotifyWhatsAppTo).
+		Str("NOTIFY_WHATSAPP_SEVERITY", cfg.NotifyWhatsAppSeverity).
+		Int("NOTIFY_TOP_CHANGES", cfg.NotifyTopChanges).
+		Float64("NOTIFY_CHANGE_THRESHOLD", cfg.NotifyChangeThreshold).
+		Dur("WATCHDOG_TIMEOUT", cfg.WatchdogTimeout).
+		Int("HEAP_LIMIT_MB", cfg.HeapLimitMB).
+		Dur("HEAP_CHECK_INTERVAL", cfg.HeapCheckInterval).
+		Int("RECOVERY_ATTEMPTS", cfg.RecoveryAttempts).
+		Dur("RECOVERY_BACKOFF", cfg.RecoveryBackoff).
+		Dur("FIREBIRD_MAINTENANCE_WAIT", cfg.MaintenanceWait).
+		Dur("FIREBIRD_MAINTENANCE_RETRY", cfg.MaintenanceRetry).
+		Int("BATCH_RETRIES", cfg.BatchRetries).
+		Dur("BATCH_RETRY_BACKOFF", cfg.BatchRetryBackoff).
+		Bool("BATCH_ISOLATE_ERRORS", cfg.BatchIsolateErrors).
+		Bool("UPSERT_WRITES", cfg.UpsertWrites).
+		Str("WORKER_ROUTING", cfg.WorkerRouting).
+		Str("STATE_FILE", cfg.StateFile).
+		Str("WORK_DIR", cfg.WorkDir).
+		Dur("WORK_DIR_RETENTION", cfg.WorkDirRetention).
+		Bool("READ_ONLY", cfg.ReadOnly).
+		Str("INCREMENTAL_COLUMN", cfg.IncrementalColumn).
+		Dur("INCREMENTAL_OVERLAP", cfg.IncrementalOverlap).
+		Int("SOURCE_CHUNK_SIZE", cfg.SourceChunkSize).
+		Str("FIREBIRD_ISOLATION", cfg.FirebirdIsolation).
+		Int("SOURCE_READERS", cfg.SourceReaders).
+		Int("ROW_LIMIT", cfg.RowLimit).
+		Dur("CLOCK_SKEW_MAX", cfg.ClockSkewMax).
+		Bool("CLOCK_SKEW_STRICT", cfg.ClockSkewStrict).
+		Str("EXCHANGE_RATE_PROVIDER", cfg.ExchangeRateProvider).
+		Str("EXCHANGE_RATE_URL", cfg.ExchangeRateURL).
+		Dur("EXCHANGE_RATE_CACHE_TTL", cfg.ExchangeRateCacheTTL).
+		Float64("EXCHANGE_RATE_FALLBACK", cfg.ExchangeRateFallback).
+		Str("NUMBER_DECIMAL_SEPARATOR", cfg.DecimalSeparator).
+		Str("NUMBER_THOUSANDS_SEPARATOR", cfg.ThousandsSeparator).
+		Bool("CONFIG_STRICT", cfg.StrictConfig).
+		Interface("SYNC_TABLES", cfg.Tables).
+		Interface("PRODUCT_COLUMN_MAP", cfg.ProductColumns).
+		Interface("PRODUCT_EXTRA_COLUMNS", cfg.ProductExtraColumns).
+		Interface("PRODUCT_TRANSFORMS", cfg.ProductTransforms).
+		Interface("PRODUCT_COMPARATORS", cfg.ProductComparators).
+		Interface("NULL_POLICIES", cfg.NullPolicies).
+		Interface("SYNC_JOBS", cfg.Jobs).
+		Dur("DAEMON_STARTUP_WAIT", cfg.DaemonStartupWait).
+		Dur("DAEMON_STARTUP_BACKOFF", cfg.DaemonStartupBackoff).
+		Strs("SYNC_ONLY", cfg.SyncOnly).
+		Str("SYNC_SCOPE", cfg.SyncScope).
+		Int("MAX_PROCS", cfg.MaxProcs).
+		Int("MAX_WORKERS", cfg.MaxWorkers).
+		Str("PROCESS_PRIORITY", cfg.ProcessPriority).
+		Int("DISK_MIN_FREE_MB", cfg.DiskMinFreeMB).
+		Ints("SPOT_CHECK_IDS", cfg.SpotCheckIDs).
+		Int("SPOT_CHECK_SAMPLE", cfg.SpotCheckSample).
+		Float64("VERIFY_SAMPLE", cfg.VerifySample).
+		Str("MYSQL_PRELOAD", cfg.MySQLPreload).
+		Stringer("FEATURE_FLAGS", cfg.FeatureFlags).
+		Str("FEATURE_FLAGS_URL", cfg.FeatureFlagsURL).
+		Interface("REVERSE_SYNC_COLUMNS", cfg.ReverseColumns).
+		Str("REVERSE_SYNC_CONFLICT", cfg.ReverseConflict).
+		Str("ORDER_EXPORT_QUERY", cfg.OrderExportQuery).
+		Str("ORDER_ITEMS_QUERY", cfg.OrderItemsQuery).
+		Str("ORDER_PENDING_STATUS", cfg.OrderPendingStatus).
+		Interface("BLOB_COLUMNS", cfg.BlobColumns).
+		Int("BLOB_MAX_BYTES", cfg.BlobMaxBytes).
 		Msg("Configuration loaded")
 
 	return cfg, nil
 }
 
-// LoadUpdateConfig loads only the update-related settings without validating DB config.
+// LoadUpdateConfig loads only the settings that need no database access
+// (updates and the state file) without validating DB config.
 func LoadUpdateConfig() (Config, error) {
 	log := logger.GetLogger()
 
@@ -175,21 +1220,108 @@ func LoadUpdateConfig() (Config, error) {
 		updateDir = "."
 	}
 
+	activeRuns, err := parseActiveRunsPolicy(getEnvString("UPDATE_ACTIVE_RUNS", ActiveRunsDefer))
+	if err != nil {
+		log.Warn().Err(err).Msg("Invalid UPDATE_ACTIVE_RUNS value, deferring installs")
+		activeRuns = ActiveRunsDefer
+	}
+
 	cfg := Config{
-		UpdateCheckURL:    os.Getenv("UPDATE_CHECK_URL"),
-		AutoUpdate:        autoUpdate,
-		UpdateDownloadDir: updateDir,
+		UpdateCheckURL:       os.Getenv("UPDATE_CHECK_URL"),
+		AutoUpdate:           autoUpdate,
+		UpdateDownloadDir:    updateDir,
+		UpdateActiveRuns:     activeRuns,
+		UpdateActiveRunsWait: max(getEnvDuration("UPDATE_ACTIVE_RUNS_WAIT", defaultActiveRunsWait), 0),
+		StateFile:            getEnvString("STATE_FILE", defaultStateFile),
+		ReadOnly:             getEnvBool("READ_ONLY", false),
+		DiskMinFreeMB:        max(getEnvInt("DISK_MIN_FREE_MB", defaultDiskMinFreeMB), 0),
 	}
 
 	log.Debug().
 		Str("UPDATE_CHECK_URL", cfg.UpdateCheckURL).
 		Bool("AUTO_UPDATE", cfg.AutoUpdate).
 		Str("UPDATE_DOWNLOAD_DIR", cfg.UpdateDownloadDir).
+		Str("UPDATE_ACTIVE_RUNS", cfg.UpdateActiveRuns).
+		Dur("UPDATE_ACTIVE_RUNS_WAIT", cfg.UpdateActiveRunsWait).
+		Str("STATE_FILE", cfg.StateFile).
+		Bool("READ_ONLY", cfg.ReadOnly).
+		Int("DISK_MIN_FREE_MB", cfg.DiskMinFreeMB).
 		Msg("Update configuration loaded")
 
 	return cfg, nil
 }
 
+// parseActiveRunsPolicy validates an UPDATE_ACTIVE_RUNS value
+func parseActiveRunsPolicy(s string) (string, error) {
+	policy := strings.ToLower(s)
+	if policy != ActiveRunsDefer && policy != ActiveRunsCancel {
+		return "", fmt.Errorf("invalid UPDATE_ACTIVE_RUNS %q: must be %q or %q", s, ActiveRunsDefer, ActiveRunsCancel)
+	}
+	return policy, nil
+}
+
+// parseCategoryFloors parses "ID_GRUPO:PRICE" pairs separated by commas, e.g. "1:50.00,7:9.90"
+func parseCategoryFloors(s string) (map[int]money.Cents, error) {
+	floors := make(map[int]money.Cents)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(pair, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid price floor %q: expected ID_GRUPO:PRICE", pair)
+		}
+		id, err := strconv.Atoi(strings.TrimSpace(k))
+		if err != nil {
+			return nil, fmt.Errorf("invalid product group in price floor %q: %w", pair, err)
+		}
+		price, err := money.Parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid price in price floor %q: %w", pair, err)
+		}
+		floors[id] = price
+	}
+	return floors, nil
+}
+
+// parseKeys parses ID_ESTOQUE values separated by commas, e.g. "17973,42"
+func parseKeys(s string) ([]int, error) {
+	var keys []int
+	for _, k := range strings.Split(s, ",") {
+		k = strings.TrimSpace(k)
+		if k == "" {
+			continue
+		}
+		id, err := strconv.Atoi(k)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %q: expected an integer ID_ESTOQUE", k)
+		}
+		if !slices.Contains(keys, id) {
+			keys = append(keys, id)
+		}
+	}
+	return keys, nil
+}
+
+// parseStatusMap parses "FIREBIRD:MYSQL" status pairs separated by commas, e.g. "A:ATIVO,I:INATIVO,B:BLOQUEADO"
+func parseStatusMap(s string) (map[string]string, error) {
+	statuses := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(pair, ":")
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if !ok || k == "" || v == "" {
+			return nil, fmt.Errorf("invalid status mapping %q: expected FIREBIRD_STATUS:MYSQL_STATUS", pair)
+		}
+		statuses[k] = v
+	}
+	return statuses, nil
+}
+
 // GetFirebirdDSN constructs the Firebird connection string
 func (c Config) GetFirebirdDSN() string {
 	return fmt.Sprintf("%s:%s@%s/%s", c.FirebirdUser, c.FirebirdPassword, c.FirebirdHost, c.FirebirdPath)
@@ -0,0 +1,29 @@
+package config
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	t.Setenv("LUCRO", "99") // The environment is not read
+
+	tests := []struct {
+		name   string
+		values map[string]string
+		lucro  float64
+		wantOK bool
+	}{
+		{"defaults", nil, 40, true},
+		{"values", map[string]string{"LUCRO": "35", "BATCH_RETRIES": "2"}, 35, true},
+		{"invalid", map[string]string{"LUCRO": "-5"}, 0, false},
+		{"misspelled", map[string]string{"Lucro": "35"}, 0, false},
+	}
+	for _, tt := range tests {
+		cfg, err := Parse(tt.values)
+		if (err == nil) != tt.wantOK {
+			t.Errorf("%s: Parse() error = %v; want ok %v", tt.name, err, tt.wantOK)
+			continue
+		}
+		if err == nil && cfg.Lucro != tt.lucro {
+			t.Errorf("%s: LUCRO = %v; want %v", tt.name, cfg.Lucro, tt.lucro)
+		}
+	}
+}
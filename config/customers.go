@@ -0,0 +1,29 @@
+package config
+
+// customerTable is the MySQL table customers are synced into
+const customerTable = "TB_CLIENTE"
+
+// CustomerColumns are the columns CUSTOMER_QUERY must return, by name, and
+// the TB_CLIENTE columns they are written to; ID_CLIENTE is the key
+var CustomerColumns = []string{"ID_CLIENTE", "NOME", "CPF_CNPJ", "EMAIL", "TELEFONE", "STATUS"}
+
+// CustomerMapping returns the mapping syncing the customers read by
+// CUSTOMER_QUERY into TB_CLIENTE through the SYNC_TABLES engine, and false
+// when customer sync is off. E-mail addresses differing only in case are
+// the same address.
+func (c Config) CustomerMapping() (TableMapping, bool) {
+	if c.CustomerQuery == "" {
+		return TableMapping{}, false
+	}
+	m := TableMapping{
+		Name:        "CUSTOMERS",
+		SourceQuery: c.CustomerQuery,
+		TargetTable: customerTable,
+		KeyColumns:  CustomerColumns[:1],
+		Comparators: map[string]string{"EMAIL": "casefold"},
+	}
+	for _, column := range CustomerColumns {
+		m.Columns = append(m.Columns, ColumnMapping{Source: column, Target: column})
+	}
+	return m, true
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/waldirborbajr/sync/logger"
+)
+
+// Deprecation is a setting renamed for the structured configuration: the old
+// name is still read, with a warning, until the version removing it
+type Deprecation struct {
+	Key         string `json:"key" yaml:"key"` // Old name
+	Replacement string `json:"replacement" yaml:"replacement"`
+	RemovedIn   string `json:"removed_in" yaml:"removed_in"`
+}
+
+// Deprecations are the renamed settings, grouped under the prefix of their
+// section: PRICE_ for the price constraints, PROGRESS_ for the progress view
+var Deprecations = []Deprecation{
+	{Key: "MIN_MARGIN", Replacement: "PRICE_MIN_MARGIN", RemovedIn: "2.0"},
+	{Key: "MAX_PRICE_DROP", Replacement: "PRICE_MAX_DROP", RemovedIn: "2.0"},
+	{Key: "TUI", Replacement: "PROGRESS_TUI", RemovedIn: "2.0"},
+}
+
+// deprecatedNames returns the old names of key, nil when it was never renamed
+func deprecatedNames(key string) []string {
+	var names []string
+	for _, d := range Deprecations {
+		if d.Replacement == key {
+			names = append(names, d.Key)
+		}
+	}
+	return names
+}
+
+// replacementOf returns the setting key was renamed to, key itself when it was not
+func replacementOf(key string) string {
+	for _, d := range Deprecations {
+		if d.Key == key {
+			return d.Replacement
+		}
+	}
+	return key
+}
+
+// withDeprecations wraps read so a setting left unset is read from its old
+// name; the new name wins when both are set
+func withDeprecations(read func(string) string) func(string) string {
+	return func(key string) string {
+		if v := read(key); v != "" {
+			return v
+		}
+		for _, old := range deprecatedNames(key) {
+			if v := read(old); v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+}
+
+// warnDeprecated logs a structured warning for each old name set in environ
+// (KEY=VALUE entries). Deprecations are never problems, strict mode included:
+// the configuration keeps working until the removal version.
+func warnDeprecated(environ []string) {
+	set := make(map[string]bool, len(environ))
+	for _, kv := range environ {
+		if key, value, _ := strings.Cut(kv, "="); strings.TrimSpace(value) != "" {
+			set[key] = true
+		}
+	}
+
+	log := logger.GetLogger()
+	for _, d := range Deprecations {
+		if !set[d.Key] {
+			continue
+		}
+		msg := "Deprecated setting, rename it or run 'sync config migrate'"
+		if set[d.Replacement] {
+			msg = fmt.Sprintf("Deprecated setting ignored, %s is set", d.Replacement)
+		}
+		log.Warn().Str("deprecated_key", d.Key).Str("replacement", d.Replacement).Str("removed_in", d.RemovedIn).Msg(msg)
+	}
+}
+
+// Migration is a line of a .env file rewritten by MigrateEnv
+type Migration struct {
+	Line        int    `json:"line" yaml:"line"`
+	Key         string `json:"key" yaml:"key"`
+	Replacement string `json:"replacement" yaml:"replacement"`
+	Action      string `json:"action" yaml:"action"` // renamed, or commented out when the replacement is already set
+}
+
+// Migration actions
+const (
+	MigrationRenamed   = "renamed"
+	MigrationCommented = "commented out"
+)
+
+// MigrateEnv rewrites the deprecated settings of the .env file data to their
+// replacements, keeping the comments, the order and the values. An old name
+// whose replacement the file also sets is commented out instead, since the
+// replacement already wins. It returns the new contents and the lines changed.
+func MigrateEnv(data []byte) ([]byte, []Migration) {
+	lines := strings.Split(string(data), "\n")
+
+	assigned := make(map[string]bool)
+	for _, line := range lines {
+		if key, _, ok := envAssignment(line); ok {
+			assigned[key] = true
+		}
+	}
+
+	var migrations []Migration
+	for i, line := range lines {
+		key, at, ok := envAssignment(line)
+		if !ok {
+			continue
+		}
+		replacement := replacementOf(key)
+		if replacement == key {
+			continue
+		}
+		m := Migration{Line: i + 1, Key: key, Replacement: replacement, Action: MigrationRenamed}
+		if assigned[replacement] {
+			m.Action = MigrationCommented
+			lines[i] = fmt.Sprintf("# %s (deprecated, replaced by %s)", line, replacement)
+		} else {
+			lines[i] = line[:at] + replacement + line[at+len(key):]
+		}
+		migrations = append(migrations, m)
+	}
+	return []byte(strings.Join(lines, "\n")), migrations
+}
+
+// envAssignment returns the key a .env line assigns, with optional export
+// prefix, and its offset in the line; ok is false for comments and blanks
+func envAssignment(line string) (key string, at int, ok bool) {
+	rest := strings.TrimLeft(line, " \t")
+	if after, found := strings.CutPrefix(rest, "export "); found {
+		rest = strings.TrimLeft(after, " \t")
+	}
+	key, _, found := strings.Cut(rest, "=")
+	key = strings.TrimRight(key, " \t")
+	if !found || key == "" || strings.HasPrefix(key, "#") || strings.ContainsAny(key, " \t") {
+		return "", 0, false
+	}
+	return key, len(line) - len(rest), true
+}
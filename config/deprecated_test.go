@@ -0,0 +1,67 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDeprecatedKeys(t *testing.T) {
+	tests := []struct {
+		name      string
+		values    map[string]string
+		minMargin float64
+	}{
+		{"new name", map[string]string{"PRICE_MIN_MARGIN": "10"}, 10},
+		{"old name", map[string]string{"MIN_MARGIN": "12"}, 12},
+		{"new name wins", map[string]string{"MIN_MARGIN": "12", "PRICE_MIN_MARGIN": "10"}, 10},
+	}
+	for _, tt := range tests {
+		cfg, err := Parse(tt.values) // Strict: old names are not unknown settings
+		if err != nil {
+			t.Errorf("%s: Parse() error = %v", tt.name, err)
+			continue
+		}
+		if cfg.MinMargin != tt.minMargin {
+			t.Errorf("%s: PRICE_MIN_MARGIN = %v; want %v", tt.name, cfg.MinMargin, tt.minMargin)
+		}
+	}
+}
+
+func TestMigrateEnv(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		want  string
+		lines []Migration
+	}{
+		{
+			name: "renamed",
+			data: "# Margins\nMIN_MARGIN=5\nexport TUI=true\nLUCRO=40\n",
+			want: "# Margins\nPRICE_MIN_MARGIN=5\nexport PROGRESS_TUI=true\nLUCRO=40\n",
+			lines: []Migration{
+				{Line: 2, Key: "MIN_MARGIN", Replacement: "PRICE_MIN_MARGIN", Action: MigrationRenamed},
+				{Line: 3, Key: "TUI", Replacement: "PROGRESS_TUI", Action: MigrationRenamed},
+			},
+		},
+		{
+			name:  "replacement set",
+			data:  "MAX_PRICE_DROP=20\nPRICE_MAX_DROP=15",
+			want:  "# MAX_PRICE_DROP=20 (deprecated, replaced by PRICE_MAX_DROP)\nPRICE_MAX_DROP=15",
+			lines: []Migration{{Line: 1, Key: "MAX_PRICE_DROP", Replacement: "PRICE_MAX_DROP", Action: MigrationCommented}},
+		},
+		{
+			name: "comments and values kept",
+			data: "# MIN_MARGIN=5 is commented out\nNOTE=MIN_MARGIN\n",
+			want: "# MIN_MARGIN=5 is commented out\nNOTE=MIN_MARGIN\n",
+		},
+	}
+	for _, tt := range tests {
+		got, lines := MigrateEnv([]byte(tt.data))
+		if string(got) != tt.want {
+			t.Errorf("%s: MigrateEnv() = %q; want %q", tt.name, got, tt.want)
+		}
+		if !reflect.DeepEqual(lines, tt.lines) {
+			t.Errorf("%s: MigrateEnv() lines = %+v; want %+v", tt.name, lines, tt.lines)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/waldirborbajr/sync/number"
+)
+
+// getenv and environ read the settings: the process environment, or the
+// values Parse was given while it loads them
+var (
+	getenv  = os.Getenv
+	environ = os.Environ
+)
+
+// loadMu serializes the loads, which share getenv, environ and the parsing state
+var loadMu sync.Mutex
+
+// numberFormat is the notation of numeric settings (NUMBER_DECIMAL_SEPARATOR / NUMBER_THOUSANDS_SEPARATOR)
+var numberFormat = number.Default
+
+// initParsing reads the settings that govern how the others are parsed and
+// resets the collected problems; it must run before any other setting is read
+func initParsing() error {
+	strictConfig = false // CONFIG_STRICT itself is parsed leniently
+	envProblems = nil
+	strictConfig = getEnvBool("CONFIG_STRICT", true)
+
+	format, err := number.NewFormat(getEnvString("NUMBER_DECIMAL_SEPARATOR", "."), getenv("NUMBER_THOUSANDS_SEPARATOR"))
+	if err != nil {
+		return fmt.Errorf("invalid number format: %w", err)
+	}
+	numberFormat = format
+	return nil
+}
+
+// getEnvBool parses a boolean environment variable, returning def when unset or invalid
+func getEnvBool(key string, def bool) bool {
+	s := strings.TrimSpace(getenv(key))
+	if s == "" {
+		return def
+	}
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		invalidSetting(key, s, err, "Invalid boolean value, using default")
+		return def
+	}
+	return v
+}
+
+// getEnvInt parses an integer environment variable, returning def when unset or invalid
+func getEnvInt(key string, def int) int {
+	s := strings.TrimSpace(getenv(key))
+	if s == "" {
+		return def
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		invalidSetting(key, s, err, "Invalid integer value, using default")
+		return def
+	}
+	return v
+}
+
+// getEnvFloat parses a float environment variable written in numberFormat,
+// returning def when unset or invalid
+func getEnvFloat(key string, def float64) float64 {
+	s := strings.TrimSpace(getenv(key))
+	if s == "" {
+		return def
+	}
+	v, err := numberFormat.ParseFloat(s)
+	if err != nil {
+		invalidSetting(key, s, err, "Invalid numeric value, using default")
+		return def
+	}
+	return v
+}
+
+// getEnvString returns the trimmed environment variable, or def when unset
+func getEnvString(key, def string) string {
+	s := strings.TrimSpace(getenv(key))
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+// getEnvDuration parses a duration environment variable ("30m", "1h30m"), returning def when unset or invalid
+func getEnvDuration(key string, def time.Duration) time.Duration {
+	s := strings.TrimSpace(getenv(key))
+	if s == "" {
+		return def
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		invalidSetting(key, s, err, "Invalid duration value, using default")
+		return def
+	}
+	return v
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/waldirborbajr/sync/flags"
+)
+
+// WithFlags returns the configuration with the behaviors gated by the
+// feature flags of set switched accordingly; flags that are not set leave
+// their settings alone. set becomes the effective FeatureFlags. A flag that
+// cannot take effect with the rest of the configuration is left off and
+// reported.
+func (c Config) WithFlags(set flags.Set) (Config, error) {
+	c.FeatureFlags = set
+
+	var err error
+	if on, ok := set[flags.StreamingCompare]; ok {
+		c.MySQLPreload = PreloadColumns
+		if on {
+			hashed := c
+			hashed.MySQLPreload = PreloadHash
+			if err = validateHashPreload(hashed); err != nil {
+				err = fmt.Errorf("feature flag %s left off: %w", flags.StreamingCompare, err)
+				c.FeatureFlags = set.Merge(flags.Set{flags.StreamingCompare: false})
+			} else {
+				c = hashed
+			}
+		}
+	}
+	if on, ok := set[flags.IsolateErrors]; ok {
+		c.BatchIsolateErrors = on
+	}
+	return c, err
+}
@@ -0,0 +1,187 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Where ROW_FILTERS are applied
+const (
+	FilterSQL  = "sql"  // In the WHERE clause of the Firebird query
+	FilterScan = "scan" // To the rows read, counting the ones filtered out
+)
+
+// filterColumns are the Firebird product columns ROW_FILTERS may test,
+// mapped to whether they are numeric
+var filterColumns = map[string]bool{
+	"ID_ESTOQUE": true,
+	"ID_GRUPO":   true,
+	"QTD_ATUAL":  true,
+	"PRC_CUSTO":  true,
+	"PRC_DOLAR":  true,
+	"STATUS":     false,
+	"DESCRICAO":  false,
+}
+
+// filterOps are the ROW_FILTERS operators, longest first for parsing
+var filterOps = []string{"NOT IN", "IN", "!=", "<>", "<=", ">=", "=", "<", ">"}
+
+// RowFilter is a ROW_FILTERS rule selecting the Firebird products synced,
+// e.g. "ID_GRUPO IN 1|7", "PRC_CUSTO >= 5" or "STATUS != B". Like in SQL,
+// a NULL value matches no rule.
+type RowFilter struct {
+	Column  string
+	Op      string    // =, !=, <, <=, >, >=, IN or NOT IN
+	Values  []string  // Text values, one unless Op is IN or NOT IN
+	Numbers []float64 // Values of a numeric column
+}
+
+// String returns the rule as written in ROW_FILTERS
+func (f RowFilter) String() string {
+	return f.Column + " " + f.Op + " " + strings.Join(f.Values, "|")
+}
+
+// MarshalText makes the configuration dump show rules as written
+func (f RowFilter) MarshalText() ([]byte, error) {
+	return []byte(f.String()), nil
+}
+
+// Numeric reports whether the rule tests a numeric column
+func (f RowFilter) Numeric() bool {
+	return filterColumns[f.Column]
+}
+
+// MatchNumber reports whether the numeric value v satisfies the rule
+func (f RowFilter) MatchNumber(v float64) bool {
+	return f.match(func(i int) int {
+		switch {
+		case v < f.Numbers[i]:
+			return -1
+		case v > f.Numbers[i]:
+			return 1
+		}
+		return 0
+	})
+}
+
+// MatchText reports whether the text value v satisfies the rule
+func (f RowFilter) MatchText(v string) bool {
+	return f.match(func(i int) int { return strings.Compare(v, f.Values[i]) })
+}
+
+// match applies the operator given the comparison of the value with Values[i]
+func (f RowFilter) match(cmp func(i int) int) bool {
+	switch f.Op {
+	case "IN", "NOT IN":
+		in := false
+		for i := range f.Values {
+			if cmp(i) == 0 {
+				in = true
+				break
+			}
+		}
+		return in == (f.Op == "IN")
+	case "=":
+		return cmp(0) == 0
+	case "!=":
+		return cmp(0) != 0
+	case "<":
+		return cmp(0) < 0
+	case "<=":
+		return cmp(0) <= 0
+	case ">":
+		return cmp(0) > 0
+	case ">=":
+		return cmp(0) >= 0
+	}
+	return false
+}
+
+// FiltersStatus reports whether a rule tests STATUS, replacing the default
+// STATUS = 'A' selection
+func (c Config) FiltersStatus() bool {
+	return slices.ContainsFunc(c.RowFilters, func(f RowFilter) bool { return f.Column == "STATUS" })
+}
+
+// parseRowFilters parses rules separated by semicolons, e.g.
+// "ID_GRUPO IN 1|7|12; PRC_CUSTO >= 5". Numbers are written in numberFormat.
+func parseRowFilters(s string) ([]RowFilter, error) {
+	var filters []RowFilter
+	for _, rule := range strings.Split(s, ";") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		f, err := parseRowFilter(rule, filterColumns)
+		if err != nil {
+			return nil, fmt.Errorf("invalid row filter %q: %w", rule, err)
+		}
+		filters = append(filters, f)
+	}
+	return filters, nil
+}
+
+// parseRowFilter parses a "COLUMN OP VALUE" rule testing one of columns,
+// mapped to whether they are numeric
+func parseRowFilter(rule string, columns map[string]bool) (RowFilter, error) {
+	end := strings.IndexFunc(rule, func(r rune) bool { return r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+	if end < 0 {
+		end = len(rule)
+	}
+	column, rest := strings.ToUpper(rule[:end]), strings.TrimSpace(rule[end:])
+	numeric, ok := columns[column]
+	if !ok {
+		names := make([]string, 0, len(columns))
+		for name := range columns {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		return RowFilter{}, fmt.Errorf("column must be one of %s", strings.Join(names, ", "))
+	}
+
+	f := RowFilter{Column: column}
+	for _, op := range filterOps {
+		if len(rest) < len(op) || !strings.EqualFold(rest[:len(op)], op) {
+			continue
+		}
+		after := rest[len(op):]
+		if (op == "IN" || op == "NOT IN") && !strings.HasPrefix(after, " ") {
+			continue
+		}
+		f.Op, rest = op, strings.TrimSpace(after)
+		break
+	}
+	if f.Op == "" {
+		return f, fmt.Errorf("operator must be one of %s", strings.Join(filterOps, ", "))
+	}
+	if f.Op == "<>" {
+		f.Op = "!="
+	}
+
+	if f.Op == "IN" || f.Op == "NOT IN" {
+		for _, v := range strings.Split(rest, "|") {
+			f.Values = append(f.Values, strings.TrimSpace(v))
+		}
+	} else {
+		f.Values = []string{rest}
+	}
+	for _, v := range f.Values {
+		if v == "" {
+			return f, fmt.Errorf("missing value")
+		}
+		if !numeric {
+			if !slices.Contains([]string{"=", "!=", "IN", "NOT IN"}, f.Op) {
+				return f, fmt.Errorf("%s only supports =, !=, IN and NOT IN", column)
+			}
+			continue
+		}
+		n, err := numberFormat.ParseFloat(v)
+		if err != nil {
+			return f, fmt.Errorf("%s value %q is not a number: %w", column, v, err)
+		}
+		f.Numbers = append(f.Numbers, n)
+	}
+	return f, nil
+}
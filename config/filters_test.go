@@ -0,0 +1,59 @@
+package config
+
+import "testing"
+
+func TestParseRowFilters(t *testing.T) {
+	filters, err := parseRowFilters("ID_GRUPO IN 1|7 | 12; prc_custo>=5;STATUS <> B; DESCRICAO not in X|Y;")
+	if err != nil {
+		t.Fatalf("parseRowFilters returned error: %v", err)
+	}
+	want := []string{"ID_GRUPO IN 1|7|12", "PRC_CUSTO >= 5", "STATUS != B", "DESCRICAO NOT IN X|Y"}
+	if len(filters) != len(want) {
+		t.Fatalf("parseRowFilters = %v; want %v", filters, want)
+	}
+	for i, f := range filters {
+		if f.String() != want[i] {
+			t.Errorf("filter %d = %q; want %q", i, f, want[i])
+		}
+	}
+
+	for _, bad := range []string{"FOO = 1", "ID_GRUPO", "ID_GRUPO = ", "ID_GRUPO = abc", "STATUS > A", "ID_GRUPO INX 1", "ID_GRUPO IN 1||2"} {
+		if _, err := parseRowFilters(bad); err == nil {
+			t.Errorf("parseRowFilters(%q) expected error", bad)
+		}
+	}
+}
+
+func TestRowFilterMatch(t *testing.T) {
+	tests := []struct {
+		rule   string
+		number float64
+		text   string
+		want   bool
+	}{
+		{"ID_GRUPO IN 1|7", 7, "", true},
+		{"ID_GRUPO IN 1|7", 2, "", false},
+		{"ID_GRUPO NOT IN 1|7", 2, "", true},
+		{"PRC_CUSTO >= 5", 5, "", true},
+		{"PRC_CUSTO > 5", 5, "", false},
+		{"PRC_CUSTO < 5.5", 5.49, "", true},
+		{"QTD_ATUAL != 0", 0, "", false},
+		{"STATUS = A", 0, "A", true},
+		{"STATUS IN A|P", 0, "I", false},
+		{"STATUS != B", 0, "A", true},
+	}
+	for _, tt := range tests {
+		f, err := parseRowFilter(tt.rule, filterColumns)
+		if err != nil {
+			t.Errorf("parseRowFilter(%q) returned error: %v", tt.rule, err)
+			continue
+		}
+		got := f.MatchText(tt.text)
+		if f.Numeric() {
+			got = f.MatchNumber(tt.number)
+		}
+		if got != tt.want {
+			t.Errorf("%q matching %v/%q = %v; want %v", tt.rule, tt.number, tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// Fingerprint returns a short hash of the effective configuration, with
+// credentials excluded, so runs can be grouped by the exact settings they used
+// and unintended configuration drift between machines becomes visible.
+func Fingerprint(cfg Config) string {
+	cfg.FirebirdPassword = ""
+	cfg.MySQLPassword = ""
+	cfg.MetricsPushToken = ""
+
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:6])
+}
@@ -0,0 +1,169 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/waldirborbajr/sync/schedule"
+)
+
+// jobKeyPrefix prefixes the per-job settings of the jobs listed in SYNC_JOBS
+const jobKeyPrefix = "SYNC_JOB_"
+
+// noTables in SYNC_JOB_<NAME>_TABLES limits a job to TB_ESTOQUE
+const noTables = "-"
+
+// Catch-up policies for an occurrence missed while the daemon was not running
+const (
+	CatchUpSkip = "skip" // Wait for the next occurrence
+	CatchUpRun  = "run"  // Run at startup, however old the missed occurrence is
+)
+
+// Overlap rules for a job falling due while another run is active
+const (
+	OverlapSkip  = "skip"  // Drop the missed occurrence
+	OverlapQueue = "queue" // Run once as soon as the active run finishes
+)
+
+// Job is a scheduled run of "sync daemon". It is configured with
+// SYNC_JOBS=NAME,... and, for each NAME:
+//
+//	SYNC_JOB_<NAME>_CRON     cron expression (required), see package schedule
+//	SYNC_JOB_<NAME>_MODE     SYNC_MODE of the runs (defaults to SYNC_MODE)
+//	SYNC_JOB_<NAME>_TABLES   SYNC_TABLES mappings synced, all by default, "-" for none
+//	SYNC_JOB_<NAME>_ONLY     SYNC_ONLY of the runs (defaults to SYNC_ONLY)
+//	SYNC_JOB_<NAME>_SCOPE    SYNC_SCOPE of the runs; with _ONLY, replaces SYNC_ONLY and SYNC_SCOPE
+//	SYNC_JOB_<NAME>_OVERLAP  OverlapSkip (default) or OverlapQueue
+//	SYNC_JOB_<NAME>_CATCHUP  CatchUpSkip (default), CatchUpRun or a duration: run
+//	                         at startup when the missed occurrence is at most that old
+//	SYNC_JOB_<NAME>_JITTER   random delay of up to this duration added to each occurrence
+type Job struct {
+	Name     string
+	Schedule *schedule.Spec
+	Mode     string
+	Tables   []string // Mapping names; nil syncs every mapping
+	Parts    []string // SyncOnly of the runs; nil keeps the configured one
+	Overlap  string
+	CatchUp  time.Duration // Oldest missed occurrence run at startup, schedule.CatchUpAlways for any
+	Jitter   time.Duration
+}
+
+// String returns the job name, as listed in SYNC_JOBS
+func (j Job) String() string {
+	return j.Name
+}
+
+// Apply returns the configuration of the job's runs
+func (j Job) Apply(cfg Config) Config {
+	cfg.SyncMode = j.Mode
+	if j.Tables != nil {
+		var tables []TableMapping
+		for _, m := range cfg.Tables {
+			if slices.Contains(j.Tables, m.Name) {
+				tables = append(tables, m)
+			}
+		}
+		cfg.Tables = tables
+	}
+	if j.Parts != nil {
+		cfg.SyncOnly = j.Parts
+	}
+	return cfg
+}
+
+// JobKey returns the environment variable holding a setting of job name
+func JobKey(name, setting string) string {
+	return jobKeyPrefix + name + "_" + setting
+}
+
+// parseJobs reads the job of every name listed in names; mode is the default
+// SYNC_MODE and tables the configured mappings
+func parseJobs(names, mode string, tables []TableMapping) ([]Job, error) {
+	var jobs []Job
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(names, ",") {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !IsValidIdentifier(name) {
+			return nil, fmt.Errorf("invalid job name %q in SYNC_JOBS", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("job %s listed twice in SYNC_JOBS", name)
+		}
+		seen[name] = true
+
+		j, err := parseJob(name, mode, tables)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, nil
+}
+
+// parseJob reads and validates the SYNC_JOB_<name>_* settings
+func parseJob(name, mode string, tables []TableMapping) (Job, error) {
+	j := Job{
+		Name:    name,
+		Mode:    strings.ToLower(getEnvString(JobKey(name, "MODE"), mode)),
+		Overlap: strings.ToLower(getEnvString(JobKey(name, "OVERLAP"), OverlapSkip)),
+	}
+
+	cron := strings.TrimSpace(getenv(JobKey(name, "CRON")))
+	if cron == "" {
+		return j, fmt.Errorf("%s is required", JobKey(name, "CRON"))
+	}
+	spec, err := schedule.Parse(cron)
+	if err != nil {
+		return j, fmt.Errorf("invalid %s: %w", JobKey(name, "CRON"), err)
+	}
+	j.Schedule = spec
+
+	if !validSyncMode(j.Mode) {
+		return j, fmt.Errorf("invalid %s %q: must be one of %s", JobKey(name, "MODE"), j.Mode, strings.Join(syncModes, ", "))
+	}
+	if j.Overlap != OverlapSkip && j.Overlap != OverlapQueue {
+		return j, fmt.Errorf("invalid %s %q: must be %q or %q", JobKey(name, "OVERLAP"), j.Overlap, OverlapSkip, OverlapQueue)
+	}
+
+	switch catchUp := strings.ToLower(getEnvString(JobKey(name, "CATCHUP"), CatchUpSkip)); catchUp {
+	case CatchUpSkip:
+	case CatchUpRun:
+		j.CatchUp = schedule.CatchUpAlways
+	default:
+		if j.CatchUp, err = time.ParseDuration(catchUp); err != nil || j.CatchUp <= 0 {
+			return j, fmt.Errorf("invalid %s %q: must be %q, %q or a positive duration such as 6h", JobKey(name, "CATCHUP"), catchUp, CatchUpSkip, CatchUpRun)
+		}
+	}
+
+	if jitter := getEnvString(JobKey(name, "JITTER"), ""); jitter != "" {
+		if j.Jitter, err = time.ParseDuration(jitter); err != nil || j.Jitter < 0 {
+			return j, fmt.Errorf("invalid %s %q: must be a duration such as 10m", JobKey(name, "JITTER"), jitter)
+		}
+	}
+
+	if list := strings.TrimSpace(getenv(JobKey(name, "TABLES"))); list != "" {
+		j.Tables = []string{}
+		if list != noTables {
+			for _, table := range strings.Split(list, ",") {
+				table = strings.ToUpper(strings.TrimSpace(table))
+				if !slices.ContainsFunc(tables, func(m TableMapping) bool { return m.Name == table }) {
+					return j, fmt.Errorf("invalid %s: %s is not listed in SYNC_TABLES", JobKey(name, "TABLES"), table)
+				}
+				j.Tables = append(j.Tables, table)
+			}
+		}
+	}
+
+	only, scope := getenv(JobKey(name, "ONLY")), getenv(JobKey(name, "SCOPE"))
+	if strings.TrimSpace(only) != "" || strings.TrimSpace(scope) != "" {
+		if j.Parts, err = parseScope(only, scope, tables); err != nil {
+			return j, fmt.Errorf("job %s: %w", name, err)
+		}
+	}
+	return j, nil
+}
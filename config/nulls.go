@@ -0,0 +1,94 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// NULL_POLICIES actions. On insert a NULL Firebird value is written as NULL
+// (NullWrite) or as the default; on update the stored value is kept, overwritten
+// with NULL or replaced by the default.
+const (
+	NullWrite     = "null"
+	NullDefault   = "default"
+	NullKeep      = "keep"
+	NullOverwrite = "overwrite"
+)
+
+// NullableFields are the built-in product fields Firebird may leave NULL:
+// the cost and the indexer value, which products without an indexer lack
+var NullableFields = []string{"PRC_CUSTO", "PRC_DOLAR"}
+
+// NullPolicy is what is written to a TB_ESTOQUE column when its Firebird value is NULL
+type NullPolicy struct {
+	Insert  string  // NullWrite or NullDefault
+	Update  string  // NullKeep, NullOverwrite or NullDefault
+	Default float64 // Value written by NullDefault
+}
+
+// defaultNullPolicy writes zero, as runs always did
+var defaultNullPolicy = NullPolicy{Insert: NullDefault, Update: NullDefault}
+
+// String returns the policy as written in NULL_POLICIES
+func (p NullPolicy) String() string {
+	return p.Insert + "/" + p.Update + "/" + strconv.FormatFloat(p.Default, 'f', -1, 64)
+}
+
+// MarshalText makes the policy log as its NULL_POLICIES entry
+func (p NullPolicy) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// NullPolicy returns the NULL_POLICIES entry of a nullable product field, or
+// the default policy writing zero on inserts and updates
+func (c Config) NullPolicy(field string) NullPolicy {
+	if p, ok := c.NullPolicies[field]; ok {
+		return p
+	}
+	return defaultNullPolicy
+}
+
+// parseNullPolicies parses "FIELD:INSERT/UPDATE[/DEFAULT]" entries separated
+// by commas, where FIELD is one of NullableFields, INSERT null or default,
+// UPDATE keep, overwrite or default and DEFAULT the value written by default
+// (0 when left out), e.g. "PRC_DOLAR:null/keep,PRC_CUSTO:default/keep/0.01"
+func parseNullPolicies(s string) (map[string]NullPolicy, error) {
+	policies := make(map[string]NullPolicy)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		field, spec, ok := strings.Cut(entry, ":")
+		field = strings.ToUpper(strings.TrimSpace(field))
+		if !ok || !slices.Contains(NullableFields, field) {
+			return nil, fmt.Errorf("invalid NULL policy %q: expected FIELD:INSERT/UPDATE[/DEFAULT] with FIELD one of %s", entry, strings.Join(NullableFields, ", "))
+		}
+		if _, ok := policies[field]; ok {
+			return nil, fmt.Errorf("NULL policy of %s given twice", field)
+		}
+
+		parts := strings.Split(spec, "/")
+		if len(parts) < 2 || len(parts) > 3 {
+			return nil, fmt.Errorf("invalid NULL policy %q: expected FIELD:INSERT/UPDATE[/DEFAULT]", entry)
+		}
+		p := NullPolicy{Insert: strings.ToLower(strings.TrimSpace(parts[0])), Update: strings.ToLower(strings.TrimSpace(parts[1]))}
+		if p.Insert != NullWrite && p.Insert != NullDefault {
+			return nil, fmt.Errorf("NULL policy of %s: invalid insert action %q, must be %q or %q", field, p.Insert, NullWrite, NullDefault)
+		}
+		if p.Update != NullKeep && p.Update != NullOverwrite && p.Update != NullDefault {
+			return nil, fmt.Errorf("NULL policy of %s: invalid update action %q, must be %q, %q or %q", field, p.Update, NullKeep, NullOverwrite, NullDefault)
+		}
+		if len(parts) == 3 {
+			v, err := strconv.ParseFloat(strings.TrimSpace(parts[2]), 64)
+			if err != nil || v < 0 {
+				return nil, fmt.Errorf("NULL policy of %s: invalid default %q, expected a non-negative amount", field, parts[2])
+			}
+			p.Default = v
+		}
+		policies[field] = p
+	}
+	return policies, nil
+}
@@ -0,0 +1,32 @@
+package config
+
+import "testing"
+
+func TestParseNullPolicies(t *testing.T) {
+	policies, err := parseNullPolicies("prc_dolar:null/keep, PRC_CUSTO:default/Overwrite/0.01")
+	if err != nil {
+		t.Fatalf("parseNullPolicies returned error: %v", err)
+	}
+	want := map[string]string{"PRC_DOLAR": "null/keep/0", "PRC_CUSTO": "default/overwrite/0.01"}
+	if len(policies) != len(want) {
+		t.Fatalf("parseNullPolicies = %v; want %v", policies, want)
+	}
+	for field, p := range policies {
+		if p.String() != want[field] {
+			t.Errorf("policy of %s = %q; want %q", field, p, want[field])
+		}
+	}
+
+	for _, bad := range []string{"PRC_VENDA:null/keep", "PRC_DOLAR:null", "PRC_DOLAR:keep/keep", "PRC_DOLAR:null/zero", "PRC_DOLAR:null/keep/-1", "PRC_DOLAR:null/keep/x", "PRC_DOLAR:null/keep,PRC_DOLAR:default/keep"} {
+		if _, err := parseNullPolicies(bad); err == nil {
+			t.Errorf("parseNullPolicies(%q) expected error", bad)
+		}
+	}
+}
+
+func TestNullPolicyDefault(t *testing.T) {
+	var c Config
+	if p := c.NullPolicy("PRC_DOLAR"); p.Insert != NullDefault || p.Update != NullDefault || p.Default != 0 {
+		t.Errorf("NullPolicy without NULL_POLICIES = %v; want default/default/0", p)
+	}
+}
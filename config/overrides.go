@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// flagAliases are the short names of flags for common settings
+var flagAliases = map[string]string{
+	"debug":  "DEBUG_MODE",
+	"dev":    "DEV_MODE",
+	"limit":  "ROW_LIMIT",
+	"scope":  "SYNC_SCOPE",
+	"tables": "SYNC_ONLY",
+	"tui":    "PROGRESS_TUI",
+}
+
+// flagKeys holds the settings set by ApplyFlags, for Describe
+var flagKeys = make(map[string]struct{})
+
+// ApplyFlags sets the settings given as flags in args and returns the
+// other arguments. A setting is named after its variable in lower case with
+// dashes, --mysql-host for MYSQL_HOST, and takes its value as the next
+// argument or after '='; boolean settings given without '=' are turned on,
+// --debug for DEBUG_MODE=true. Deprecated names set their replacement,
+// --min-margin sets PRICE_MIN_MARGIN. The values go into the process environment,
+// which godotenv never overrides, so they win over the .env file.
+func ApplyFlags(args []string) ([]string, error) {
+	settings := flagSettings()
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		name, value, inline := strings.Cut(strings.TrimPrefix(args[i], "--"), "=")
+		key, ok := flagAliases[name]
+		if !ok {
+			key = replacementOf(strings.ToUpper(strings.ReplaceAll(name, "-", "_")))
+		}
+		kind, known := settings[key]
+		if !strings.HasPrefix(args[i], "--") || !known {
+			rest = append(rest, args[i])
+			continue
+		}
+
+		switch {
+		case inline:
+		case kind == reflect.Bool:
+			value = "true"
+		case i+1 < len(args):
+			i++
+			value = args[i]
+		default:
+			return nil, fmt.Errorf("--%s requires a value", name)
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return nil, fmt.Errorf("error setting %s: %w", key, err)
+		}
+		flagKeys[key] = struct{}{}
+	}
+	return rest, nil
+}
+
+// flagSettings returns the kind of every Config setting, by variable name
+func flagSettings() map[string]reflect.Kind {
+	settings := make(map[string]reflect.Kind)
+	rt := reflect.TypeOf(Config{})
+	for i := 0; i < rt.NumField(); i++ {
+		if tag := rt.Field(i).Tag.Get("env"); tag != "" {
+			key, _, _ := strings.Cut(tag, ",")
+			settings[key] = rt.Field(i).Type.Kind()
+		}
+	}
+	return settings
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestApplyFlags(t *testing.T) {
+	tests := []struct {
+		args []string
+		rest []string
+		env  map[string]string
+	}{
+		{[]string{"--mysql-host", "staging", "run"}, []string{"run"}, map[string]string{"MYSQL_HOST": "staging"}},
+		{[]string{"--lucro=35", "trace", "--id", "7"}, []string{"trace", "--id", "7"}, map[string]string{"LUCRO": "35"}},
+		{[]string{"--debug", "run"}, []string{"run"}, map[string]string{"DEBUG_MODE": "true"}},
+		{[]string{"--dev-mode=false"}, nil, map[string]string{"DEV_MODE": "false"}},
+		{[]string{"--limit", "100"}, nil, map[string]string{"ROW_LIMIT": "100"}},
+		{[]string{"backfill", "--from", "2024-01-01", "--dry-run"}, []string{"backfill", "--from", "2024-01-01", "--dry-run"}, nil},
+		{[]string{"status", "lucro"}, []string{"status", "lucro"}, nil},
+	}
+	for _, tt := range tests {
+		for key := range tt.env {
+			t.Setenv(key, "")
+		}
+		rest, err := ApplyFlags(tt.args)
+		if err != nil {
+			t.Errorf("ApplyFlags(%q) returned error: %v", tt.args, err)
+			continue
+		}
+		if !slices.Equal(rest, tt.rest) {
+			t.Errorf("ApplyFlags(%q) = %q; want %q", tt.args, rest, tt.rest)
+		}
+		for key, want := range tt.env {
+			if got := os.Getenv(key); got != want {
+				t.Errorf("ApplyFlags(%q): %s = %q; want %q", tt.args, key, got, want)
+			}
+		}
+	}
+
+	if _, err := ApplyFlags([]string{"run", "--mysql-host"}); err == nil {
+		t.Error("ApplyFlags with a missing value expected error")
+	}
+}
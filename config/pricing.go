@@ -0,0 +1,154 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"maps"
+	"os"
+	"strings"
+)
+
+// PricingSupplier is the pricing rule condition column holding the supplier
+// read from PRICING_SUPPLIER_COLUMN
+const PricingSupplier = "SUPPLIER"
+
+// pricingColumns are the columns pricing rule conditions may test: the
+// ROW_FILTERS columns and the supplier, compared as text
+var pricingColumns = func() map[string]bool {
+	columns := maps.Clone(filterColumns)
+	columns[PricingSupplier] = false
+	return columns
+}()
+
+// Margins are the percentages sale prices are calculated with:
+// PRC_VENDA = PRC_CUSTO * (1 + Lucro/100) and PRC_nX = PRC_VENDA * (1 + ParcnX/100) / n
+type Margins struct {
+	Lucro   float64
+	Parc3x  float64
+	Parc6x  float64
+	Parc10x float64
+}
+
+// Margins returns the global margins (LUCRO, PARC3X, PARC6X, PARC10X)
+func (c Config) Margins() Margins {
+	return Margins{Lucro: c.Lucro, Parc3x: c.Parc3x, Parc6x: c.Parc6x, Parc10x: c.Parc10x}
+}
+
+// PricingRule is a margin set for the products satisfying all its conditions.
+// Rules are read from PRICING_RULES_FILE, one per line:
+//
+//	# NAME: CONDITION; CONDITION => MARGIN=VALUE MARGIN=VALUE
+//	electronics: ID_GRUPO IN 7|12 => LUCRO=35 PARC3X=4
+//	cheap: PRC_CUSTO < 10 => LUCRO=120
+//	acme: SUPPLIER = 33; PRC_CUSTO >= 100 => LUCRO=25
+//
+// Conditions are written like ROW_FILTERS rules and may also test SUPPLIER; a
+// rule without conditions matches every product. The first rule matching a
+// product, in file order, prices it; products matching none use the global
+// margins. Margins a rule leaves out (LUCRO, PARC3X, PARC6X, PARC10X) keep
+// their global value.
+type PricingRule struct {
+	Name    string
+	When    []RowFilter
+	Margins Margins
+}
+
+// String returns the rule name
+func (r PricingRule) String() string {
+	return r.Name
+}
+
+// UsesSupplier reports whether a pricing rule tests the supplier
+func (c Config) UsesSupplier() bool {
+	for _, r := range c.PricingRules {
+		for _, f := range r.When {
+			if f.Column == PricingSupplier {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// loadPricingRules reads the pricing rules of path; margins are the global ones
+func loadPricingRules(path string, margins Margins) ([]PricingRule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rules []PricingRule
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r, err := parsePricingRule(line, margins)
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, n, err)
+		}
+		if seen[r.Name] {
+			return nil, fmt.Errorf("%s line %d: rule %s defined twice", path, n, r.Name)
+		}
+		seen[r.Name] = true
+		rules = append(rules, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// parsePricingRule parses a "NAME: CONDITIONS => MARGINS" line
+func parsePricingRule(line string, margins Margins) (PricingRule, error) {
+	name, rest, ok := strings.Cut(line, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" || strings.ContainsAny(name, " \t") {
+		return PricingRule{}, fmt.Errorf("rule must start with a name followed by a colon")
+	}
+	conditions, values, ok := strings.Cut(rest, "=>")
+	if !ok {
+		return PricingRule{}, fmt.Errorf("rule %s: missing => before the margins", name)
+	}
+
+	r := PricingRule{Name: name, Margins: margins}
+	for _, cond := range strings.Split(conditions, ";") {
+		cond = strings.TrimSpace(cond)
+		if cond == "" {
+			continue
+		}
+		f, err := parseRowFilter(cond, pricingColumns)
+		if err != nil {
+			return r, fmt.Errorf("rule %s: condition %q: %w", name, cond, err)
+		}
+		r.When = append(r.When, f)
+	}
+
+	fields := strings.Fields(values)
+	if len(fields) == 0 {
+		return r, fmt.Errorf("rule %s: no margins set", name)
+	}
+	for _, field := range fields {
+		key, value, _ := strings.Cut(field, "=")
+		v, err := numberFormat.ParseFloat(value)
+		if err != nil {
+			return r, fmt.Errorf("rule %s: %s value %q is not a number: %w", name, key, value, err)
+		}
+		switch strings.ToUpper(key) {
+		case "LUCRO":
+			r.Margins.Lucro = v
+		case "PARC3X":
+			r.Margins.Parc3x = v
+		case "PARC6X":
+			r.Margins.Parc6x = v
+		case "PARC10X":
+			r.Margins.Parc10x = v
+		default:
+			return r, fmt.Errorf("rule %s: margin must be one of LUCRO, PARC3X, PARC6X, PARC10X", name)
+		}
+	}
+	return r, nil
+}
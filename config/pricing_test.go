@@ -0,0 +1,61 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadPricingRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pricing.rules")
+	content := `# Margins per category
+electronics: ID_GRUPO IN 7|12 => LUCRO=35 PARC3X=4
+
+acme: supplier = 33; PRC_CUSTO >= 100 => lucro=25
+everything: => PARC10X=20
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	global := Margins{Lucro: 40, Parc3x: 5, Parc6x: 10, Parc10x: 15}
+	rules, err := loadPricingRules(path, global)
+	if err != nil {
+		t.Fatalf("loadPricingRules returned error: %v", err)
+	}
+	want := []struct {
+		name    string
+		when    int
+		margins Margins
+	}{
+		{"electronics", 1, Margins{Lucro: 35, Parc3x: 4, Parc6x: 10, Parc10x: 15}},
+		{"acme", 2, Margins{Lucro: 25, Parc3x: 5, Parc6x: 10, Parc10x: 15}},
+		{"everything", 0, Margins{Lucro: 40, Parc3x: 5, Parc6x: 10, Parc10x: 20}},
+	}
+	if len(rules) != len(want) {
+		t.Fatalf("loadPricingRules = %v; want %d rules", rules, len(want))
+	}
+	for i, w := range want {
+		r := rules[i]
+		if r.Name != w.name || len(r.When) != w.when || r.Margins != w.margins {
+			t.Errorf("rule %d = %s %v %+v; want %s with %d conditions %+v", i, r.Name, r.When, r.Margins, w.name, w.when, w.margins)
+		}
+	}
+	if rules[1].When[0].Column != PricingSupplier {
+		t.Errorf("acme tests %s; want %s", rules[1].When[0].Column, PricingSupplier)
+	}
+
+	for _, bad := range []string{
+		"ID_GRUPO = 7 => LUCRO=35",
+		"two words: ID_GRUPO = 7 => LUCRO=35",
+		"cheap: PRC_CUSTO < 10",
+		"cheap: PRC_CUSTO < 10 =>",
+		"cheap: PRC_CUSTO < 10 => MARKUP=5",
+		"cheap: PRC_CUSTO < 10 => LUCRO=abc",
+		"cheap: SUPPLIER > 10 => LUCRO=5",
+	} {
+		if _, err := parsePricingRule(bad, global); err == nil {
+			t.Errorf("parsePricingRule(%q) expected error", bad)
+		}
+	}
+}
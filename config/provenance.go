@@ -0,0 +1,126 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// Value sources, from lowest to highest precedence
+const (
+	SourceDefault = "default"
+	SourceFile    = "file" // .env file
+	SourceEnv     = "env"  // process environment
+	SourceFlag    = "flag" // command-line flag, see ApplyFlags
+)
+
+// secretMask replaces secret values in Describe output
+const secretMask = "********"
+
+// processEnv holds the variables present in the process environment at
+// startup, before godotenv.Load adds the .env entries, so env-provided values
+// can be told apart from file-provided ones.
+var processEnv = environKeys()
+
+// Setting is one effective configuration value and where it came from
+type Setting struct {
+	Key    string `json:"key" yaml:"key"`
+	Value  string `json:"value" yaml:"value"`
+	Source string `json:"source" yaml:"source"`
+}
+
+// Describe lists every configuration value of cfg in declaration order with
+// its source. Secrets are masked. godotenv never overrides variables already
+// set in the environment, so flags and the environment win over the .env file.
+func Describe(cfg Config) []Setting {
+	fileEnv, _ := godotenv.Read()
+
+	rv := reflect.ValueOf(cfg)
+	rt := rv.Type()
+	settings := make([]Setting, 0, rt.NumField())
+	for i := 0; i < rt.NumField(); i++ {
+		tag := rt.Field(i).Tag.Get("env")
+		if tag == "" {
+			continue
+		}
+		key, opts, _ := strings.Cut(tag, ",")
+
+		source := SourceDefault
+		for _, name := range append([]string{key}, deprecatedNames(key)...) {
+			if _, ok := flagKeys[name]; ok {
+				source = SourceFlag
+			} else if _, ok := processEnv[name]; ok {
+				source = SourceEnv
+			} else if v, ok := fileEnv[name]; ok && strings.TrimSpace(v) != "" {
+				source = SourceFile
+			} else {
+				continue
+			}
+			break
+		}
+
+		value := formatSetting(rv.Field(i))
+		if opts == "secret" && value != "" {
+			value = secretMask
+		}
+		settings = append(settings, Setting{Key: key, Value: value, Source: source})
+	}
+	return settings
+}
+
+// GivenSettings returns the settings given as flags or in the process
+// environment, leaving out those only the .env file sets
+func GivenSettings() map[string]string {
+	given := make(map[string]string)
+	for key := range flagSettings() {
+		_, flag := flagKeys[key]
+		if _, env := processEnv[key]; flag || env {
+			given[key] = os.Getenv(key)
+		}
+	}
+	return given
+}
+
+// formatSetting formats a configuration value the way it is written in .env
+func formatSetting(v reflect.Value) string {
+	if d, ok := v.Interface().(time.Duration); ok {
+		return d.String()
+	}
+	if v.Kind() == reflect.Slice {
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = fmt.Sprint(v.Index(i).Interface())
+		}
+		if v.Type().Elem() == reflect.TypeOf(ExtraColumn{}) {
+			return strings.Join(items, ";") // Expressions may contain commas
+		}
+		return strings.Join(items, ",")
+	}
+	if v.Kind() != reflect.Map {
+		return fmt.Sprint(v.Interface())
+	}
+
+	pairs := make([]string, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		pairs = append(pairs, fmt.Sprintf("%v:%v", iter.Key().Interface(), iter.Value().Interface()))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// environKeys returns the names of the variables set in the process environment
+func environKeys() map[string]struct{} {
+	keys := make(map[string]struct{})
+	for _, kv := range os.Environ() {
+		if k, _, ok := strings.Cut(kv, "="); ok {
+			keys[k] = struct{}{}
+		}
+	}
+	return keys
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// REVERSE_SYNC_CONFLICT values: what to do with a row whose Firebird columns
+// changed since they were last pushed while the MySQL ones differ
+const (
+	ReverseConflictSkip  = "skip"  // Leave both sides alone and report the row (default)
+	ReverseConflictMySQL = "mysql" // Push the MySQL values anyway
+)
+
+// reverseReadColumns are the Firebird TB_ESTOQUE columns the product query
+// reads, which reverse sync must not write
+var reverseReadColumns = []string{ProductKey, "DESCRICAO", "PRC_CUSTO", "ID_GRUPO", "STATUS"}
+
+// parseReverseColumns parses REVERSE_SYNC_COLUMNS, "MYSQL:FIREBIRD" pairs of
+// TB_ESTOQUE columns managed in MySQL and pushed back into Firebird, e.g.
+// "DESCRICAO_WEB,PUBLICADO:ATIVO_WEB". Columns the run writes to MySQL or
+// reads from Firebird would be overwritten on the next run and are refused.
+func parseReverseColumns(s string, cfg Config) ([]ColumnMapping, error) {
+	columns, err := parseColumnMappings(s)
+	if err != nil {
+		return nil, err
+	}
+
+	written := append(cfg.ProductColumnNames(), cfg.ProductColumn(ProductKey))
+	read := slices.Clone(reverseReadColumns)
+	if cfg.IncrementalColumn != "" {
+		read = append(read, cfg.IncrementalColumn)
+	}
+	if cfg.PricingSupplierColumn != "" {
+		read = append(read, cfg.PricingSupplierColumn)
+	}
+	for _, c := range columns {
+		if slices.ContainsFunc(written, func(w string) bool { return strings.EqualFold(w, c.Source) }) {
+			return nil, fmt.Errorf("%s is written to TB_ESTOQUE by the sync and cannot be pushed back", c.Source)
+		}
+		if slices.ContainsFunc(read, func(r string) bool { return strings.EqualFold(r, c.Target) }) {
+			return nil, fmt.Errorf("Firebird column %s is read by the sync and cannot be written back", c.Target)
+		}
+	}
+	return columns, nil
+}
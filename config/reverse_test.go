@@ -0,0 +1,31 @@
+package config
+
+import "testing"
+
+func TestParseReverseColumns(t *testing.T) {
+	cfg := Config{
+		ProductColumns:      map[string]string{"DESCRICAO": "NOME"},
+		ProductExtraColumns: []ExtraColumn{{Target: "PRC_PROMO"}},
+		IncrementalColumn:   "DT_ALTERACAO",
+	}
+	tests := []struct {
+		value string
+		want  int
+		ok    bool
+	}{
+		{"DESCRICAO_WEB,PUBLICADO:ATIVO_WEB", 2, true},
+		{"DESCRICAO_WEB:DESCRICAO", 0, false}, // Read from Firebird
+		{"NOME:NOME_WEB", 0, false},           // Written to MySQL through PRODUCT_COLUMN_MAP
+		{"prc_promo:PROMO", 0, false},         // Written to MySQL as an extra column
+		{"ALTERADO:DT_ALTERACAO", 0, false},   // INCREMENTAL_COLUMN
+		{"WEB:A,WEB2:A", 0, false},            // Firebird column mapped twice
+		{"", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseReverseColumns(tt.value, cfg)
+		if (err == nil) != tt.ok || len(got) != tt.want {
+			t.Errorf("parseReverseColumns(%q) = %v, %v; want %d columns (ok %v)", tt.value, got, err, tt.want, tt.ok)
+		}
+	}
+}
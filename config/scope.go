@@ -0,0 +1,72 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Parts of a run selectable with SYNC_ONLY (--tables), besides the names of
+// the SYNC_TABLES mappings
+const (
+	PartProducts   = "estoque"    // TB_ESTOQUE, with its BLOBs, soft deletes and procedures
+	PartCategories = "categorias" // CATEGORY_QUERY
+	PartCustomers  = "clientes"   // CUSTOMER_QUERY
+	PartWarehouses = "depositos"  // WAREHOUSE_QUERY
+	PartMovements  = "movimentos" // MOVEMENT_QUERY
+	PartOrders     = "pedidos"    // ORDER_EXPORT_QUERY
+	PartReverse    = "reverso"    // REVERSE_COLUMNS
+)
+
+// Parts lists the parts in the order a run syncs them
+var Parts = []string{PartCategories, PartCustomers, PartReverse, PartProducts, PartWarehouses, PartMovements, PartOrders}
+
+// Scopes are the presets of SYNC_SCOPE (--scope), each a set of parts
+var Scopes = map[string][]string{
+	"prices": {PartProducts, PartCategories},
+	"stock":  {PartProducts, PartWarehouses, PartMovements},
+	"orders": {PartCustomers, PartOrders},
+}
+
+// Syncs reports whether the run syncs part, a name of Parts or a SYNC_TABLES
+// mapping: every part unless SYNC_ONLY or SYNC_SCOPE select some
+func (c Config) Syncs(part string) bool {
+	return len(c.SyncOnly) == 0 || slices.Contains(c.SyncOnly, strings.ToLower(part))
+}
+
+// parseScope returns the parts selected by the SYNC_ONLY list only and the
+// SYNC_SCOPE preset scope, nil for every part
+func parseScope(only, scope string, tables []TableMapping) ([]string, error) {
+	var parts []string
+	if scope = strings.ToLower(strings.TrimSpace(scope)); scope != "" {
+		preset, ok := Scopes[scope]
+		if !ok {
+			names := make([]string, 0, len(Scopes))
+			for name := range Scopes {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("invalid SYNC_SCOPE %q: expected %s", scope, strings.Join(names, ", "))
+		}
+		parts = append(parts, preset...)
+	}
+
+	known := slices.Clone(Parts)
+	for _, m := range tables {
+		known = append(known, strings.ToLower(m.Name))
+	}
+	for _, part := range strings.Split(only, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if part == "" {
+			continue
+		}
+		if !slices.Contains(known, part) {
+			return nil, fmt.Errorf("invalid SYNC_ONLY part %q: expected %s", part, strings.Join(known, ", "))
+		}
+		if !slices.Contains(parts, part) {
+			parts = append(parts, part)
+		}
+	}
+	return parts, nil
+}
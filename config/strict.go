@@ -0,0 +1,140 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/waldirborbajr/sync/logger"
+)
+
+// syncKeyPrefix marks variables owned by this program; unknown ones are reported
+const syncKeyPrefix = "SYNC_"
+
+// Percentage bounds checked by validatePercentages
+const (
+	maxPercent     = 1000.0 // Markups and installment surcharges
+	maxDropPercent = 100.0  // A price cannot drop by more than all of it
+)
+
+// otherKeys are settings read outside Config (log rotation in the logger package)
+var otherKeys = []string{"LOG_MAX_SIZE_MB", "LOG_MAX_BACKUPS", "LOG_MAX_AGE_DAYS", "LOG_COMPRESS"}
+
+var (
+	// strictConfig makes invalid settings fail LoadConfig instead of falling back to defaults (CONFIG_STRICT)
+	strictConfig bool
+	// envProblems collects the invalid settings found in strict mode, reported all at once
+	envProblems []string
+)
+
+// invalidSetting records a setting that could not be parsed: in strict mode it
+// is reported by LoadConfig, otherwise a warning is logged and def is used
+func invalidSetting(key, value string, err error, msg string) {
+	if strictConfig {
+		envProblems = append(envProblems, fmt.Sprintf("%s=%q: %v", key, value, err))
+		return
+	}
+	log := logger.GetLogger()
+	log.Warn().Err(err).Str(key, value).Msg(msg)
+}
+
+// configProblem records a problem that is an error in strict mode and a warning otherwise
+func configProblem(problem string) {
+	if strictConfig {
+		envProblems = append(envProblems, problem)
+		return
+	}
+	log := logger.GetLogger()
+	log.Warn().Msg("Configuration: " + problem)
+}
+
+// percentSetting is a percentage setting checked by validatePercentages
+type percentSetting struct {
+	key   string
+	value float64
+	max   float64
+}
+
+// validatePercentages reports percentage settings outside their range
+func validatePercentages(cfg Config) {
+	percents := []percentSetting{
+		{"LUCRO", cfg.Lucro, maxPercent},
+		{"PARC3X", cfg.Parc3x, maxPercent},
+		{"PARC6X", cfg.Parc6x, maxPercent},
+		{"PARC10X", cfg.Parc10x, maxPercent},
+		{"PRICE_MIN_MARGIN", cfg.MinMargin, maxPercent},
+		{"PRICE_MAX_DROP", cfg.MaxPriceDrop, maxDropPercent},
+	}
+	for _, r := range cfg.PricingRules {
+		prefix := "PRICING_RULES_FILE " + r.Name + " "
+		percents = append(percents,
+			percentSetting{prefix + "LUCRO", r.Margins.Lucro, maxPercent},
+			percentSetting{prefix + "PARC3X", r.Margins.Parc3x, maxPercent},
+			percentSetting{prefix + "PARC6X", r.Margins.Parc6x, maxPercent},
+			percentSetting{prefix + "PARC10X", r.Margins.Parc10x, maxPercent},
+		)
+	}
+	for _, p := range percents {
+		if p.value < 0 || p.value > p.max {
+			configProblem(fmt.Sprintf("%s=%v: percentage out of range [0, %v]", p.key, p.value, p.max))
+		}
+	}
+}
+
+// validateKeys reports the variables of environ (KEY=VALUE entries) that look
+// like settings but are not read: SYNC_* variables nobody uses and variables
+// that differ from a known setting only in case, such as PARC6x
+func validateKeys(cfg Config, environ []string) {
+	known := knownKeys(cfg)
+	upper := make(map[string]string, len(known))
+	for k := range known {
+		upper[strings.ToUpper(k)] = k
+	}
+
+	var problems []string
+	for _, kv := range environ {
+		key, _, _ := strings.Cut(kv, "=")
+		if known[key] {
+			continue
+		}
+		if k, ok := upper[strings.ToUpper(key)]; ok {
+			problems = append(problems, fmt.Sprintf("%s: unknown setting, did you mean %s?", key, k))
+		} else if strings.HasPrefix(strings.ToUpper(key), syncKeyPrefix) {
+			problems = append(problems, fmt.Sprintf("%s: unknown setting", key))
+		}
+	}
+	sort.Strings(problems)
+	for _, p := range problems {
+		configProblem(p)
+	}
+}
+
+// knownKeys returns every variable read by the configuration of cfg
+func knownKeys(cfg Config) map[string]bool {
+	known := make(map[string]bool)
+	rt := reflect.TypeOf(cfg)
+	for i := 0; i < rt.NumField(); i++ {
+		if tag := rt.Field(i).Tag.Get("env"); tag != "" {
+			key, _, _ := strings.Cut(tag, ",")
+			known[key] = true
+		}
+	}
+	for _, key := range otherKeys {
+		known[key] = true
+	}
+	for _, d := range Deprecations {
+		known[d.Key] = true // Warned about by warnDeprecated
+	}
+	for _, m := range cfg.Tables {
+		for _, setting := range []string{"QUERY", "TARGET", "KEY", "COLUMNS", "COMPARE", "QUANTITY_COLUMNS", "BACKFILL_QUERY"} {
+			known[TableKey(m.Name, setting)] = true
+		}
+	}
+	for _, j := range cfg.Jobs {
+		for _, setting := range []string{"CRON", "MODE", "TABLES", "OVERLAP", "CATCHUP", "JITTER", "ONLY", "SCOPE"} {
+			known[JobKey(j.Name, setting)] = true
+		}
+	}
+	return known
+}
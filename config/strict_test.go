@@ -0,0 +1,41 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateKeys(t *testing.T) {
+	strictConfig, envProblems = true, nil
+	defer func() { strictConfig, envProblems = false, nil }()
+
+	cfg := Config{Tables: []TableMapping{{Name: "GRUPOS"}}}
+	validateKeys(cfg, []string{
+		"PATH=/usr/bin",
+		"PARC6X=10",
+		"PARC6x=10",
+		"SYNC_TABLES=GRUPOS",
+		"SYNC_TABLE_GRUPOS_QUERY=SELECT 1",
+		"SYNC_TABLE_CLIENTES_QUERY=SELECT 1",
+		"LOG_COMPRESS=true",
+	})
+
+	want := []string{
+		"PARC6x: unknown setting, did you mean PARC6X?",
+		"SYNC_TABLE_CLIENTES_QUERY: unknown setting",
+	}
+	if !reflect.DeepEqual(envProblems, want) {
+		t.Errorf("validateKeys problems = %q; want %q", envProblems, want)
+	}
+}
+
+func TestValidatePercentages(t *testing.T) {
+	strictConfig, envProblems = true, nil
+	defer func() { strictConfig, envProblems = false, nil }()
+
+	validatePercentages(Config{Lucro: 40, Parc3x: -1, Parc10x: 1500, MaxPriceDrop: 100})
+
+	if len(envProblems) != 2 {
+		t.Errorf("validatePercentages problems = %q; want PARC3X and PARC10X", envProblems)
+	}
+}
@@ -0,0 +1,245 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// tableKeyPrefix prefixes the per-table settings of the tables listed in SYNC_TABLES
+const tableKeyPrefix = "SYNC_TABLE_"
+
+// TableMapping describes an additional table synced besides TB_ESTOQUE.
+// It is configured with SYNC_TABLES=NAME,... and, for each NAME:
+//
+//	SYNC_TABLE_<NAME>_QUERY             Firebird query returning the source rows (required)
+//	SYNC_TABLE_<NAME>_TARGET            MySQL table written (defaults to NAME), schema.table
+//	                                    for a table of another schema of the MySQL server
+//	SYNC_TABLE_<NAME>_KEY               target columns identifying a row, comma separated (required)
+//	SYNC_TABLE_<NAME>_COLUMNS           SOURCE:TARGET pairs, or bare names when equal (required)
+//	SYNC_TABLE_<NAME>_COMPARE           TARGET:COMPARATOR pairs, see package compare
+//	SYNC_TABLE_<NAME>_QUANTITY_COLUMNS  target columns synced by SYNC_MODE=quantity runs,
+//	                                    which skip the table when empty
+//	SYNC_TABLE_<NAME>_BACKFILL_QUERY    Firebird query returning the source rows of a
+//	                                    date range, its start and end bound to its two '?'
+//	                                    (start included, end excluded); see "sync backfill"
+type TableMapping struct {
+	Name            string
+	SourceQuery     string
+	TargetSchema    string // Schema of TargetTable, empty for MYSQL_DATABASE
+	TargetTable     string
+	KeyColumns      []string // Several for a composite key, in SYNC_TABLE_<NAME>_KEY order
+	Columns         []ColumnMapping
+	Comparators     map[string]string // Comparator spec per target column, exact when absent
+	QuantityColumns []string          // Target columns besides the key synced in quantity mode
+	BackfillQuery   string            // Empty when the table cannot be backfilled
+}
+
+// ColumnMapping maps a column of the source query to a target column
+type ColumnMapping struct {
+	Source string
+	Target string
+}
+
+// String returns the mapping name, as listed in SYNC_TABLES
+func (m TableMapping) String() string {
+	return m.Name
+}
+
+// Target returns the target table as configured, schema.table or table
+func (m TableMapping) Target() string {
+	if m.TargetSchema == "" {
+		return m.TargetTable
+	}
+	return m.TargetSchema + "." + m.TargetTable
+}
+
+// QuotedTarget returns the target table quoted for SQL, qualified with its
+// schema when it has one
+func (m TableMapping) QuotedTarget() string {
+	if m.TargetSchema == "" {
+		return QuoteIdentifier(m.TargetTable)
+	}
+	return QuoteIdentifier(m.TargetSchema) + "." + QuoteIdentifier(m.TargetTable)
+}
+
+// QuoteIdentifier quotes a MySQL identifier with backticks, which SQLite
+// accepts too
+func QuoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// KeyIndexes returns the positions of the key columns in Columns, -1 for
+// a key column that is not mapped
+func (m TableMapping) KeyIndexes() []int {
+	indexes := make([]int, len(m.KeyColumns))
+	for i, key := range m.KeyColumns {
+		indexes[i] = slices.IndexFunc(m.Columns, func(c ColumnMapping) bool { return c.Target == key })
+	}
+	return indexes
+}
+
+// IsKey reports whether the target column is part of the key
+func (m TableMapping) IsKey(column string) bool {
+	return slices.Contains(m.KeyColumns, column)
+}
+
+// QuantitySubset returns the mapping restricted to the key and QuantityColumns,
+// and false when the table is not synced in quantity mode
+func (m TableMapping) QuantitySubset() (TableMapping, bool) {
+	if len(m.QuantityColumns) == 0 {
+		return m, false
+	}
+	subset := m
+	subset.Columns = nil
+	for _, c := range m.Columns {
+		if m.IsKey(c.Target) || slices.Contains(m.QuantityColumns, c.Target) {
+			subset.Columns = append(subset.Columns, c)
+		}
+	}
+	return subset, true
+}
+
+// SyncedTables returns the table mappings synced in the configured SYNC_MODE
+// and SYNC_ONLY scope
+func (c Config) SyncedTables() []TableMapping {
+	var tables []TableMapping
+	for _, m := range c.Tables {
+		if !c.Syncs(m.Name) {
+			continue
+		}
+		if !c.QuantityOnly() {
+			tables = append(tables, m)
+		} else if subset, ok := m.QuantitySubset(); ok {
+			tables = append(tables, subset)
+		}
+	}
+	return tables
+}
+
+// TableKey returns the environment variable holding a setting of table name
+func TableKey(name, setting string) string {
+	return tableKeyPrefix + name + "_" + setting
+}
+
+// parseTableMappings reads the mapping of every table listed in names
+func parseTableMappings(names string) ([]TableMapping, error) {
+	var mappings []TableMapping
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(names, ",") {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !IsValidIdentifier(name) {
+			return nil, fmt.Errorf("invalid table name %q in SYNC_TABLES", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("table %s listed twice in SYNC_TABLES", name)
+		}
+		seen[name] = true
+
+		m, err := parseTableMapping(name)
+		if err != nil {
+			return nil, err
+		}
+		mappings = append(mappings, m)
+	}
+	return mappings, nil
+}
+
+// parseTableMapping reads and validates the SYNC_TABLE_<name>_* settings
+func parseTableMapping(name string) (TableMapping, error) {
+	m := TableMapping{
+		Name:        name,
+		SourceQuery: strings.TrimSpace(getenv(TableKey(name, "QUERY"))),
+		TargetTable: getEnvString(TableKey(name, "TARGET"), name),
+	}
+	if m.SourceQuery == "" {
+		return m, fmt.Errorf("%s is required", TableKey(name, "QUERY"))
+	}
+	if schema, table, ok := strings.Cut(m.TargetTable, "."); ok {
+		m.TargetSchema, m.TargetTable = schema, table
+	}
+	if !IsValidIdentifier(m.TargetTable) || (m.TargetSchema != "" && !IsValidIdentifier(m.TargetSchema)) {
+		return m, fmt.Errorf("invalid %s %q: expected table or schema.table", TableKey(name, "TARGET"), m.Target())
+	}
+
+	columns, err := parseColumnMappings(getenv(TableKey(name, "COLUMNS")))
+	if err != nil {
+		return m, fmt.Errorf("invalid %s: %w", TableKey(name, "COLUMNS"), err)
+	}
+	if len(columns) == 0 {
+		return m, fmt.Errorf("%s is required", TableKey(name, "COLUMNS"))
+	}
+	m.Columns = columns
+
+	for _, key := range strings.Split(getenv(TableKey(name, "KEY")), ",") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		if !slices.ContainsFunc(columns, func(c ColumnMapping) bool { return c.Target == key }) {
+			return m, fmt.Errorf("%s %q must be one of the target columns in %s", TableKey(name, "KEY"), key, TableKey(name, "COLUMNS"))
+		}
+		if m.IsKey(key) {
+			return m, fmt.Errorf("%s lists %s twice", TableKey(name, "KEY"), key)
+		}
+		m.KeyColumns = append(m.KeyColumns, key)
+	}
+	if len(m.KeyColumns) == 0 {
+		return m, fmt.Errorf("%s is required", TableKey(name, "KEY"))
+	}
+
+	targets := make([]string, len(columns))
+	for i, c := range columns {
+		targets[i] = c.Target
+	}
+	if m.Comparators, err = parseComparators(getenv(TableKey(name, "COMPARE")), targets); err != nil {
+		return m, fmt.Errorf("invalid %s: %w", TableKey(name, "COMPARE"), err)
+	}
+
+	for _, column := range strings.Split(getenv(TableKey(name, "QUANTITY_COLUMNS")), ",") {
+		column = strings.TrimSpace(column)
+		if column == "" || m.IsKey(column) {
+			continue
+		}
+		if !slices.Contains(targets, column) {
+			return m, fmt.Errorf("invalid %s: %s is not a target column in %s", TableKey(name, "QUANTITY_COLUMNS"), column, TableKey(name, "COLUMNS"))
+		}
+		m.QuantityColumns = append(m.QuantityColumns, column)
+	}
+
+	m.BackfillQuery = strings.TrimSpace(getenv(TableKey(name, "BACKFILL_QUERY")))
+	if m.BackfillQuery != "" && strings.Count(m.BackfillQuery, "?") != 2 {
+		return m, fmt.Errorf("%s must have two '?', for the start and the end of the range", TableKey(name, "BACKFILL_QUERY"))
+	}
+	return m, nil
+}
+
+// parseColumnMappings parses "SOURCE:TARGET" pairs separated by commas; a bare
+// name maps a column to the target column of the same name, e.g. "ID_CLIENTE:ID,NOME,EMAIL"
+func parseColumnMappings(s string) ([]ColumnMapping, error) {
+	var columns []ColumnMapping
+	targets := make(map[string]bool)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		src, dst, ok := strings.Cut(pair, ":")
+		src, dst = strings.TrimSpace(src), strings.TrimSpace(dst)
+		if !ok {
+			dst = src
+		}
+		if !IsValidIdentifier(src) || !IsValidIdentifier(dst) {
+			return nil, fmt.Errorf("invalid column mapping %q: expected SOURCE:TARGET", pair)
+		}
+		if targets[dst] {
+			return nil, fmt.Errorf("target column %s mapped twice", dst)
+		}
+		targets[dst] = true
+		columns = append(columns, ColumnMapping{Source: src, Target: dst})
+	}
+	return columns, nil
+}
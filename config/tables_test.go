@@ -0,0 +1,138 @@
+package config
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseTableMappingKeys(t *testing.T) {
+	tests := []struct {
+		key     string
+		want    []string
+		indexes []int
+		ok      bool
+	}{
+		{"PART_NUMBER", []string{"PART_NUMBER"}, []int{1}, true},
+		{"ID_EMPRESA, PART_NUMBER", []string{"ID_EMPRESA", "PART_NUMBER"}, []int{0, 1}, true},
+		{"PART_NUMBER,ID_EMPRESA", []string{"PART_NUMBER", "ID_EMPRESA"}, []int{1, 0}, true},
+		{"ID_EMPRESA,ID_EMPRESA", nil, nil, false},
+		{"CODPECA", nil, nil, false}, // Source name, not the target
+		{"", nil, nil, false},
+	}
+
+	t.Setenv(TableKey("PECAS", "QUERY"), "SELECT ID_EMPRESA, CODPECA, DESCRICAO FROM TB_PECA")
+	t.Setenv(TableKey("PECAS", "COLUMNS"), "ID_EMPRESA,CODPECA:PART_NUMBER,DESCRICAO")
+	for _, tt := range tests {
+		t.Setenv(TableKey("PECAS", "KEY"), tt.key)
+		m, err := parseTableMapping("PECAS")
+		if (err == nil) != tt.ok {
+			t.Errorf("KEY=%q: error = %v; want ok %v", tt.key, err, tt.ok)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if !slices.Equal(m.KeyColumns, tt.want) || !slices.Equal(m.KeyIndexes(), tt.indexes) {
+			t.Errorf("KEY=%q: key columns %v at %v; want %v at %v", tt.key, m.KeyColumns, m.KeyIndexes(), tt.want, tt.indexes)
+		}
+	}
+}
+
+func TestParseTableMappingTarget(t *testing.T) {
+	tests := []struct {
+		target, quoted string
+		ok             bool
+	}{
+		{"", "`PECAS`", true},
+		{"TB_PECA", "`TB_PECA`", true},
+		{"catalogo.TB_PECA", "`catalogo`.`TB_PECA`", true},
+		{"catalogo.", "", false},
+		{"a.b.c", "", false},
+		{"catalogo.TB PECA", "", false},
+	}
+
+	t.Setenv(TableKey("PECAS", "QUERY"), "SELECT CODPECA FROM TB_PECA")
+	t.Setenv(TableKey("PECAS", "COLUMNS"), "CODPECA")
+	t.Setenv(TableKey("PECAS", "KEY"), "CODPECA")
+	for _, tt := range tests {
+		t.Setenv(TableKey("PECAS", "TARGET"), tt.target)
+		m, err := parseTableMapping("PECAS")
+		if (err == nil) != tt.ok {
+			t.Errorf("TARGET=%q: error = %v; want ok %v", tt.target, err, tt.ok)
+			continue
+		}
+		if err == nil && m.QuotedTarget() != tt.quoted {
+			t.Errorf("TARGET=%q: QuotedTarget() = %q; want %q", tt.target, m.QuotedTarget(), tt.quoted)
+		}
+	}
+}
+
+func TestQuantitySubsetKeepsKeys(t *testing.T) {
+	m := TableMapping{
+		KeyColumns:      []string{"ID_EMPRESA", "PART_NUMBER"},
+		Columns:         []ColumnMapping{{"ID_EMPRESA", "ID_EMPRESA"}, {"CODPECA", "PART_NUMBER"}, {"DESCRICAO", "DESCRICAO"}, {"QTD", "QTD"}},
+		QuantityColumns: []string{"QTD"},
+	}
+	subset, ok := m.QuantitySubset()
+	if !ok {
+		t.Fatal("QuantitySubset() = false; want the quantity columns")
+	}
+	var targets []string
+	for _, c := range subset.Columns {
+		targets = append(targets, c.Target)
+	}
+	if want := []string{"ID_EMPRESA", "PART_NUMBER", "QTD"}; !slices.Equal(targets, want) {
+		t.Errorf("QuantitySubset() columns = %v; want %v", targets, want)
+	}
+}
+
+func TestCustomerMapping(t *testing.T) {
+	if _, ok := (Config{}).CustomerMapping(); ok {
+		t.Error("CustomerMapping() without CUSTOMER_QUERY = true; want customer sync off")
+	}
+	m, ok := Config{CustomerQuery: "SELECT * FROM TB_CLIENTE"}.CustomerMapping()
+	if !ok {
+		t.Fatal("CustomerMapping() = false; want the TB_CLIENTE mapping")
+	}
+	if m.TargetTable != "TB_CLIENTE" || !slices.Equal(m.KeyIndexes(), []int{0}) || len(m.Columns) != len(CustomerColumns) {
+		t.Errorf("CustomerMapping() = %+v", m)
+	}
+}
+
+func TestParseScope(t *testing.T) {
+	tables := []TableMapping{{Name: "GRUPOS"}, {Name: "MARCAS"}}
+	tests := []struct {
+		only, scope string
+		want        []string
+	}{
+		{"", "", nil},
+		{"estoque, Clientes", "", []string{"estoque", "clientes"}},
+		{"grupos", "", []string{"grupos"}},
+		{"", "prices", []string{"estoque", "categorias"}},
+		{"estoque,pedidos", "orders", []string{"clientes", "pedidos", "estoque"}},
+	}
+	for _, tt := range tests {
+		got, err := parseScope(tt.only, tt.scope, tables)
+		if err != nil {
+			t.Errorf("parseScope(%q, %q) returned error: %v", tt.only, tt.scope, err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("parseScope(%q, %q) = %v; want %v", tt.only, tt.scope, got, tt.want)
+		}
+	}
+
+	for _, bad := range [][2]string{{"produtos", ""}, {"", "everything"}} {
+		if _, err := parseScope(bad[0], bad[1], tables); err == nil {
+			t.Errorf("parseScope(%q, %q) expected error", bad[0], bad[1])
+		}
+	}
+
+	cfg := Config{Tables: tables, SyncOnly: []string{"marcas"}}
+	if cfg.Syncs(PartProducts) || !cfg.Syncs("MARCAS") {
+		t.Errorf("Syncs with SYNC_ONLY=marcas: estoque %v, MARCAS %v", cfg.Syncs(PartProducts), cfg.Syncs("MARCAS"))
+	}
+	if synced := cfg.SyncedTables(); len(synced) != 1 || synced[0].Name != "MARCAS" {
+		t.Errorf("SyncedTables() = %v; want [MARCAS]", synced)
+	}
+}
@@ -0,0 +1,37 @@
+package config
+
+import "strings"
+
+// FillEnv returns the .env template with the values of the keys in values
+// replacing those of their assignments, comments and order kept. Placeholder
+// values made only of '*', left for credentials, are cleared so a template
+// never passes for a configured installation.
+func FillEnv(template []byte, values map[string]string) []byte {
+	lines := strings.Split(string(template), "\n")
+	for i, line := range lines {
+		key, at, ok := envAssignment(line)
+		if !ok {
+			continue
+		}
+		value, set := values[key]
+		if _, current, _ := strings.Cut(line[at:], "="); !set && (current == "" || strings.Trim(current, "*") != "") {
+			continue
+		}
+		lines[i] = line[:at] + key + "=" + quoteEnvValue(value)
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// quoteEnvValue quotes value when godotenv would not read it back as is:
+// single quotes keep everything literally, double quotes are used when the
+// value holds a single quote itself
+func quoteEnvValue(value string) string {
+	if !strings.ContainsAny(value, " \t#'\"\\$") {
+		return value
+	}
+	if !strings.Contains(value, "'") {
+		return "'" + value + "'"
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`)
+	return `"` + r.Replace(value) + `"`
+}
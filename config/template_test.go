@@ -0,0 +1,45 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/joho/godotenv"
+)
+
+func TestFillEnv(t *testing.T) {
+	template := "# Firebird\nFIREBIRD_USER=****\nFIREBIRD_HOST=***\n# MYSQL_PORT=3307\nMYSQL_PORT=3306\nLUCRO=00.00\n"
+
+	tests := []struct {
+		name   string
+		values map[string]string
+		want   string
+	}{
+		{
+			name: "placeholders cleared",
+			want: "# Firebird\nFIREBIRD_USER=\nFIREBIRD_HOST=\n# MYSQL_PORT=3307\nMYSQL_PORT=3306\nLUCRO=00.00\n",
+		},
+		{
+			name:   "values set",
+			values: map[string]string{"FIREBIRD_HOST": "erp.local", "MYSQL_PORT": "3307", "LUCRO": "35"},
+			want:   "# Firebird\nFIREBIRD_USER=\nFIREBIRD_HOST=erp.local\n# MYSQL_PORT=3307\nMYSQL_PORT=3307\nLUCRO=35\n",
+		},
+	}
+	for _, tt := range tests {
+		if got := string(FillEnv([]byte(template), tt.values)); got != tt.want {
+			t.Errorf("%s: FillEnv() = %q; want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestQuoteEnvValue(t *testing.T) {
+	for _, value := range []string{"plain", "with space", "p#ss", "it's", `"quoted"`, `back\slash`, "$HOME", `it's $HOME "x" \n`} {
+		env, err := godotenv.Unmarshal("KEY=" + quoteEnvValue(value))
+		if err != nil {
+			t.Errorf("%q: Unmarshal() error = %v", value, err)
+			continue
+		}
+		if env["KEY"] != value {
+			t.Errorf("%q: read back as %q", value, env["KEY"])
+		}
+	}
+}
@@ -0,0 +1,514 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/db"
+	"github.com/waldirborbajr/sync/logger"
+	"github.com/waldirborbajr/sync/metrics"
+	"github.com/waldirborbajr/sync/notify"
+	"github.com/waldirborbajr/sync/preflight"
+	"github.com/waldirborbajr/sync/schedule"
+	"github.com/waldirborbajr/sync/state"
+)
+
+// daemonUsage documents the daemon subcommand
+const daemonUsage = "daemon [--every INTERVAL]"
+
+// intervalJob names the job "sync daemon --every" runs, in logs and notifications
+const intervalJob = "EVERY"
+
+// daemonCommand runs the SYNC_JOBS on their schedules until interrupted, or
+// the whole sync every --every interval. Runs are sequential and share their
+// database connections; a job falling due during another run follows its
+// SYNC_JOB_<NAME>_OVERLAP rule and one missed while the daemon was not
+// running its SYNC_JOB_<NAME>_CATCHUP policy.
+func daemonCommand(env *commandEnv) int {
+	log := logger.GetLogger()
+
+	every, err := parseDaemonArgs(env.args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\nusage: sync %s\n", redBold, reset, err, daemonUsage)
+		return 2
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+		return exitConfig
+	}
+	if err := db.CheckWritable(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v - the daemon cannot run\n", redBold, reset, err)
+		return 1
+	}
+	if len(cfg.Jobs) == 0 && every == 0 {
+		fmt.Fprintln(os.Stderr, "no jobs configured: set SYNC_JOBS and SYNC_JOB_<NAME>_CRON, or use --every")
+		return 2
+	}
+	if err := setupIdentity(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+		return 1
+	}
+	log = logger.GetLogger()
+	applyLimits(cfg)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Started at boot, the daemon may come up before the databases
+	waited, err := waitForDatabases(ctx, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+		return 1
+	}
+	host, _ := os.Hostname()
+	log.Info().Dur("waited", waited).Msg("Daemon ready: Firebird and MySQL reachable")
+	sendNotification(notifyChannels(cfg), notify.Event{
+		Severity: notify.SeverityInfo,
+		Title:    "Sync daemon ready on " + host,
+		Text:     fmt.Sprintf("Firebird and MySQL reachable after %s", waited.Round(time.Second)),
+		Host:     host,
+		At:       time.Now(),
+	})
+
+	conns := &dbConns{}
+	defer conns.Close()
+	totals := &daemonTotals{started: time.Now()}
+	if every > 0 {
+		return runEvery(ctx, cfg, every, conns, totals)
+	}
+
+	st, err := state.Load(cfg.StateFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+		return 1
+	}
+	sched := schedule.NewScheduler(scheduleEntries(cfg.Jobs, st), time.Now())
+	for _, j := range cfg.Jobs {
+		if missed, caughtUp := sched.Missed(j.Name); caughtUp {
+			log.Info().Str("job", j.Name).Time("missed", missed).Msg("Catching up on a run missed while the daemon was not running")
+		} else if !missed.IsZero() {
+			log.Warn().Str("job", j.Name).Time("missed", missed).Msg("Run missed while the daemon was not running, outside the catch-up window")
+		}
+	}
+	log.Info().Strs("jobs", jobNames(cfg.Jobs)).Time("next_run", sched.Next()).Msg("Daemon started")
+
+	for {
+		entry, skipped, ok := sched.Pop(time.Now())
+		for _, e := range skipped {
+			log.Warn().Str("job", e.Name).Msg("Job fell due during another run, occurrence skipped")
+		}
+		if ok {
+			runJob(ctx, cfg, findJob(cfg.Jobs, entry.Name), conns, totals)
+			log.Info().Time("next_run", sched.Next()).Msg("Waiting for the next job")
+			continue
+		}
+
+		next := sched.Next()
+		if next.IsZero() {
+			log.Error().Msg("No job has a future run time, stopping")
+			return 1
+		}
+		select {
+		case <-ctx.Done():
+			totals.log("Daemon stopped")
+			return 0
+		case <-time.After(time.Until(next)):
+		}
+	}
+}
+
+// runEvery runs the sync every interval until ctx is cancelled, the first run
+// at once. A run outlasting the interval is followed by the next right away.
+func runEvery(ctx context.Context, cfg config.Config, every time.Duration, conns *dbConns, totals *daemonTotals) int {
+	log := logger.GetLogger()
+	if len(cfg.Jobs) > 0 {
+		log.Warn().Strs("jobs", jobNames(cfg.Jobs)).Msg("SYNC_JOBS ignored: --every runs the whole sync on its interval")
+	}
+	log.Info().Dur("every", every).Msg("Daemon started")
+
+	job := config.Job{Name: intervalJob, Mode: cfg.SyncMode}
+	for {
+		start := time.Now()
+		runJob(ctx, cfg, job, conns, totals)
+
+		next := start.Add(every)
+		if time.Now().After(next) {
+			log.Warn().Dur("every", every).Dur("took", time.Since(start)).Msg("Run outlasted the interval, starting the next one now")
+			next = time.Now()
+		} else {
+			log.Info().Time("next_run", next).Msg("Waiting for the next run")
+		}
+		select {
+		case <-ctx.Done():
+			totals.log("Daemon stopped")
+			return 0
+		case <-time.After(time.Until(next)):
+		}
+	}
+}
+
+// parseDaemonArgs returns the --every interval, 0 to run the SYNC_JOBS
+func parseDaemonArgs(args []string) (every time.Duration, err error) {
+	for i := 0; i < len(args); i++ {
+		name, value, inline := strings.Cut(args[i], "=")
+		if name != "--every" {
+			return 0, fmt.Errorf("unexpected argument %q", args[i])
+		}
+		if !inline {
+			if i+1 >= len(args) {
+				return 0, fmt.Errorf("%s requires a value", name)
+			}
+			i++
+			value = args[i]
+		}
+		if every, err = time.ParseDuration(value); err != nil || every < time.Second {
+			return 0, fmt.Errorf("invalid --every %q: expected a duration of at least 1s, e.g. 5m", value)
+		}
+	}
+	return every, nil
+}
+
+// dbConns keeps the Firebird and MySQL connections of the daemon open between
+// its runs; a nil *dbConns opens and closes them in every run
+type dbConns struct {
+	firebird, mysql *sql.DB
+}
+
+// open returns the connections of a run and the function releasing them.
+// Shared connections failing a ping are replaced.
+func (c *dbConns) open(cfg config.Config) (firebirdConn, mysqlConn *sql.DB, release func(), err error) {
+	if c == nil {
+		if firebirdConn, err = db.ConnectFirebird(cfg); err != nil {
+			return nil, nil, nil, fmt.Errorf("%w: %w", errFirebirdUnreachable, err)
+		}
+		if mysqlConn, err = db.ConnectMySQL(cfg); err != nil {
+			closeConn(firebirdConn, "Firebird")
+			return nil, nil, nil, fmt.Errorf("%w: %w", errMySQLUnreachable, err)
+		}
+		return firebirdConn, mysqlConn, func() {
+			closeConn(firebirdConn, "Firebird")
+			closeConn(mysqlConn, "MySQL")
+		}, nil
+	}
+
+	if c.firebird != nil && c.firebird.Ping() != nil {
+		closeConn(c.firebird, "Firebird")
+		c.firebird = nil
+	}
+	if c.firebird == nil {
+		if c.firebird, err = db.ConnectFirebird(cfg); err != nil {
+			return nil, nil, nil, fmt.Errorf("%w: %w", errFirebirdUnreachable, err)
+		}
+	}
+	if c.mysql != nil && c.mysql.Ping() != nil {
+		closeConn(c.mysql, "MySQL")
+		c.mysql = nil
+	}
+	if c.mysql == nil {
+		if c.mysql, err = db.ConnectMySQL(cfg); err != nil {
+			return nil, nil, nil, fmt.Errorf("%w: %w", errMySQLUnreachable, err)
+		}
+	}
+	return c.firebird, c.mysql, func() {}, nil
+}
+
+// Close closes the shared connections
+func (c *dbConns) Close() {
+	if c.firebird != nil {
+		closeConn(c.firebird, "Firebird")
+	}
+	if c.mysql != nil {
+		closeConn(c.mysql, "MySQL")
+	}
+	c.firebird, c.mysql = nil, nil
+}
+
+// closeConn closes conn, logging a failure
+func closeConn(conn *sql.DB, name string) {
+	if err := conn.Close(); err != nil {
+		log := logger.GetLogger()
+		log.Error().Err(err).Msgf("Error closing %s database connection", name)
+	}
+}
+
+// daemonTotals accumulates the outcome of the daemon runs since it started
+type daemonTotals struct {
+	started                    time.Time
+	runs, failed, deferred     int
+	inserted, updated, ignored int
+	elapsed                    time.Duration
+}
+
+// add accounts for a run
+func (t *daemonTotals) add(inserted, updated, ignored int, elapsed time.Duration, err error) {
+	t.runs++
+	switch {
+	case errors.Is(err, errRunDeferred):
+		t.deferred++
+	case err != nil:
+		t.failed++
+	default:
+		t.inserted += inserted
+		t.updated += updated
+		t.ignored += ignored
+		t.elapsed += elapsed
+	}
+}
+
+// log logs the totals with msg
+func (t *daemonTotals) log(msg string) {
+	log := logger.GetLogger()
+	log.Info().
+		Dur("uptime", time.Since(t.started).Round(time.Second)).
+		Int("runs", t.runs).
+		Int("failed", t.failed).
+		Int("deferred", t.deferred).
+		Int("inserted", t.inserted).
+		Int("updated", t.updated).
+		Int("ignored", t.ignored).
+		Dur("sync_time", t.elapsed).
+		Msg(msg)
+}
+
+// samples returns the totals as metrics, pushed along with those of each run
+func (t *daemonTotals) samples() []metrics.Sample {
+	return []metrics.Sample{
+		{Name: "sync_daemon_uptime_seconds", Help: "Time since the daemon started", Value: time.Since(t.started).Seconds()},
+		{Name: "sync_daemon_runs_total", Help: "Runs since the daemon started", Value: float64(t.runs)},
+		{Name: "sync_daemon_runs_failed_total", Help: "Failed runs since the daemon started", Value: float64(t.failed)},
+		{Name: "sync_daemon_runs_deferred_total", Help: "Runs deferred by Firebird maintenance since the daemon started", Value: float64(t.deferred)},
+		{Name: "sync_daemon_rows_inserted_total", Help: "Rows inserted since the daemon started", Value: float64(t.inserted)},
+		{Name: "sync_daemon_rows_updated_total", Help: "Rows updated since the daemon started", Value: float64(t.updated)},
+		{Name: "sync_daemon_rows_ignored_total", Help: "Rows left unchanged since the daemon started", Value: float64(t.ignored)},
+		{Name: "sync_daemon_sync_seconds_total", Help: "Time spent in successful runs since the daemon started", Value: t.elapsed.Seconds()},
+	}
+}
+
+// daemonMaxBackoff caps the wait between two startup connection attempts
+const daemonMaxBackoff = time.Minute
+
+// waitForDatabases connects to Firebird and MySQL until both succeed, waiting
+// DAEMON_STARTUP_BACKOFF, then twice as long each time, for at most
+// DAEMON_STARTUP_WAIT. It returns how long it waited.
+func waitForDatabases(ctx context.Context, cfg config.Config) (time.Duration, error) {
+	log := logger.GetLogger()
+	start := time.Now()
+	backoff := cfg.DaemonStartupBackoff
+	for attempt := 1; ; attempt++ {
+		err := pingDatabases(cfg)
+		if err == nil {
+			return time.Since(start), nil
+		}
+		if time.Since(start)+backoff > cfg.DaemonStartupWait {
+			return time.Since(start), fmt.Errorf("databases unreachable after %d attempts in %s: %w", attempt, time.Since(start).Round(time.Second), err)
+		}
+		log.Warn().Err(err).Int("attempt", attempt).Dur("retry_in", backoff).Msg("Databases not reachable yet, waiting")
+		select {
+		case <-ctx.Done():
+			return time.Since(start), ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, daemonMaxBackoff)
+	}
+}
+
+// pingDatabases opens and closes a connection to each database
+func pingDatabases(cfg config.Config) error {
+	firebirdConn, err := db.ConnectFirebird(cfg)
+	if err != nil {
+		return err
+	}
+	_ = firebirdConn.Close()
+	mysqlConn, err := db.ConnectMySQL(cfg)
+	if err != nil {
+		return err
+	}
+	_ = mysqlConn.Close()
+	return nil
+}
+
+// runJob runs one occurrence of job on conns, logging its outcome instead of
+// failing the daemon and adding it to totals. The run stops when ctx, the
+// daemon's, is cancelled by a signal.
+func runJob(ctx context.Context, cfg config.Config, job config.Job, conns *dbConns, totals *daemonTotals) {
+	pop := logger.PushField("job", job.Name)
+	defer pop()
+	log := logger.GetLogger()
+
+	st, err := state.Load(cfg.StateFile)
+	if err != nil {
+		log.Error().Err(err).Msg("Error loading state, job skipped")
+		return
+	}
+	if st.Maintenance {
+		log.Warn().Time("since", st.MaintenanceSince).Str("reason", st.MaintenanceReason).Msg("Maintenance mode is on, job skipped")
+		return
+	}
+	if err := preflight.Check(cfg, preflight.Logs()); err != nil {
+		log.Error().Err(err).Msg("Disk space preflight failed, job skipped")
+		return
+	}
+
+	if _, err := state.Update(cfg.StateFile, func(s *state.State) {
+		if s.JobRuns == nil {
+			s.JobRuns = make(map[string]time.Time)
+		}
+		s.JobRuns[job.Name] = time.Now()
+	}); err != nil {
+		log.Warn().Err(err).Msg("Could not record the job run")
+	}
+
+	jobCfg := job.Apply(applyFeatureFlags(cfg))
+	log.Info().Str("mode", job.Mode).Strs("tables", tableNames(jobCfg.SyncedTables())).Strs("only", jobCfg.SyncOnly).Msg("Job started")
+
+	inserted, updated, ignored, _, stats, elapsed, _, _, err := runWithRecovery(ctx, jobCfg, conns)
+	totals.add(inserted, updated, ignored, elapsed, err)
+	defer totals.log("Daemon totals")
+	samples := append(runMetrics(inserted, updated, ignored, stats, elapsed, err), totals.samples()...)
+	if pushErr := metrics.Push(context.Background(), jobCfg, samples); pushErr != nil {
+		log.Warn().Err(pushErr).Msg("Could not push run metrics")
+	}
+	notifyRun(jobCfg, job.Name, inserted, updated, ignored, stats, elapsed, err)
+	if errors.Is(err, errRunDeferred) {
+		log.Warn().Err(err).Msg("Job deferred due to source maintenance")
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Job failed")
+		return
+	}
+	log.Info().
+		Str("run_id", stats.RunID).
+		Int("inserted", inserted).
+		Int("updated", updated).
+		Int("ignored", ignored).
+		Dur("elapsed", elapsed).
+		Msg("Job finished")
+}
+
+// scheduleEntries returns the scheduler entries of jobs, in SYNC_JOBS order,
+// with the last runs recorded in st
+func scheduleEntries(jobs []config.Job, st state.State) []schedule.Entry {
+	entries := make([]schedule.Entry, len(jobs))
+	for i, j := range jobs {
+		entries[i] = schedule.Entry{
+			Name:    j.Name,
+			Spec:    j.Schedule,
+			Queue:   j.Overlap == config.OverlapQueue,
+			Jitter:  j.Jitter,
+			LastRun: st.JobRuns[j.Name],
+			CatchUp: j.CatchUp,
+		}
+	}
+	return entries
+}
+
+// findJob returns the job called name
+func findJob(jobs []config.Job, name string) config.Job {
+	for _, j := range jobs {
+		if j.Name == name {
+			return j
+		}
+	}
+	return config.Job{}
+}
+
+// jobNames returns the names of jobs
+func jobNames(jobs []config.Job) []string {
+	names := make([]string, len(jobs))
+	for i, j := range jobs {
+		names[i] = j.Name
+	}
+	return names
+}
+
+// tableNames returns the names of table mappings
+func tableNames(tables []config.TableMapping) []string {
+	names := make([]string, len(tables))
+	for i, m := range tables {
+		names[i] = m.Name
+	}
+	return names
+}
+
+// scheduleInfo is a row of "sync schedule list"
+type scheduleInfo struct {
+	Job     string    `json:"job" yaml:"job"`
+	Cron    string    `json:"cron" yaml:"cron"`
+	Mode    string    `json:"mode" yaml:"mode"`
+	Tables  string    `json:"tables" yaml:"tables"` // SYNC_TABLES mappings synced besides TB_ESTOQUE
+	Only    string    `json:"only" yaml:"only"`     // Parts synced, SYNC_ONLY and SYNC_SCOPE applied
+	Overlap string    `json:"overlap" yaml:"overlap"`
+	CatchUp string    `json:"catchup" yaml:"catchup"`
+	Jitter  string    `json:"jitter" yaml:"jitter"`
+	LastRun time.Time `json:"last_run,omitzero" yaml:"last_run,omitempty"`
+	NextRun time.Time `json:"next_run,omitzero" yaml:"next_run,omitempty"` // Jitter included; a caught up run is due now
+}
+
+// scheduleCommand lists the daemon jobs with what they sync and when they run next
+func scheduleCommand(env *commandEnv) int {
+	if len(env.args) != 1 || env.args[0] != "list" {
+		fmt.Fprintln(os.Stderr, "usage: sync schedule list")
+		return 2
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+		return exitConfig
+	}
+
+	st, err := state.Load(cfg.StateFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+		return 1
+	}
+
+	sched := schedule.NewScheduler(scheduleEntries(cfg.Jobs, st), time.Now())
+	rows := make([]scheduleInfo, 0, len(cfg.Jobs))
+	for _, j := range cfg.Jobs {
+		jobCfg := j.Apply(cfg)
+		tables := strings.Join(tableNames(jobCfg.SyncedTables()), ",")
+		if tables == "" {
+			tables = "-"
+		}
+		only := strings.Join(jobCfg.SyncOnly, ",")
+		if only == "" {
+			only = "all"
+		}
+		rows = append(rows, scheduleInfo{
+			Job:     j.Name,
+			Cron:    j.Schedule.String(),
+			Mode:    j.Mode,
+			Tables:  tables,
+			Only:    only,
+			Overlap: j.Overlap,
+			CatchUp: catchUpPolicy(j.CatchUp),
+			Jitter:  j.Jitter.String(),
+			LastRun: st.JobRuns[j.Name],
+			NextRun: sched.Pending(j.Name),
+		})
+	}
+	return env.render(rows)
+}
+
+// catchUpPolicy describes a SYNC_JOB_<NAME>_CATCHUP window
+func catchUpPolicy(window time.Duration) string {
+	switch window {
+	case 0:
+		return config.CatchUpSkip
+	case schedule.CatchUpAlways:
+		return config.CatchUpRun
+	}
+	return "within " + window.String()
+}
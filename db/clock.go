@@ -0,0 +1,58 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/waldirborbajr/sync/config"
+)
+
+// Current time queries, cast to text so every driver returns the server's
+// wall clock as is, without time zone conversion
+const (
+	firebirdNowQuery = "SELECT CAST(CURRENT_TIMESTAMP AS VARCHAR(24)) FROM RDB$DATABASE"
+	mysqlNowQuery    = "SELECT CAST(NOW(3) AS CHAR)"
+	devNowQuery      = "SELECT strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime')"
+)
+
+// serverTimeLayout parses the wall clocks returned by the current time queries
+const serverTimeLayout = "2006-01-02 15:04:05.999999999"
+
+// ClockSkew returns how far the wall clock of a database server is ahead of
+// the host's (negative when behind). The server time is compared with the
+// host time halfway through the query, both read in the host's time zone,
+// so a server configured for another zone shows as skewed too.
+func ClockSkew(ctx context.Context, db *sql.DB, cfg config.Config, firebird bool) (time.Duration, error) {
+	query := mysqlNowQuery
+	if firebird {
+		query = firebirdNowQuery
+	}
+	if cfg.DevMode {
+		query = devNowQuery
+	}
+
+	var s string
+	sent := time.Now()
+	if err := db.QueryRowContext(ctx, query).Scan(&s); err != nil {
+		return 0, err
+	}
+	host := sent.Add(time.Since(sent) / 2)
+
+	server, err := parseServerTime(s)
+	if err != nil {
+		return 0, err
+	}
+	return server.Sub(host), nil
+}
+
+// parseServerTime parses a server wall clock in the host's time zone
+func parseServerTime(s string) (time.Time, error) {
+	t, err := time.ParseInLocation(serverTimeLayout, strings.TrimSpace(s), time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unexpected server time %q: %w", s, err)
+	}
+	return t, nil
+}
@@ -0,0 +1,27 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseServerTime(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"2024-03-05 14:07:09", time.Date(2024, 3, 5, 14, 7, 9, 0, time.Local)},
+		{"2024-03-05 14:07:09.123", time.Date(2024, 3, 5, 14, 7, 9, 123e6, time.Local)},
+		{"2024-03-05 14:07:09.1230 ", time.Date(2024, 3, 5, 14, 7, 9, 123e6, time.Local)}, // Firebird VARCHAR(24)
+	}
+	for _, tt := range tests {
+		got, err := parseServerTime(tt.in)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("parseServerTime(%q) = %s, %v; want %s", tt.in, got, err, tt.want)
+		}
+	}
+
+	if _, err := parseServerTime("05/03/2024 14:07"); err == nil {
+		t.Errorf("parseServerTime accepted a date in another layout")
+	}
+}
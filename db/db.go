@@ -9,6 +9,7 @@ import (
 	_ "github.com/nakagami/firebirdsql"
 	"github.com/waldirborbajr/sync/config"
 	"github.com/waldirborbajr/sync/logger"
+	"github.com/waldirborbajr/sync/replay"
 )
 
 // ConnectFirebird establishes an optimized connection to the Firebird database
@@ -55,7 +56,11 @@ func ConnectMySQL(cfg config.Config) (*sql.DB, error) {
 
 	// Add connection parameters for better performance
 	dsn := cfg.GetMySQLDSN() + "&writeTimeout=10s&readTimeout=30s&timeout=5s"
-	db, err := sql.Open("mysql", dsn)
+	if cfg.ReadOnly {
+		// Every session starts read-only, so the server refuses any write
+		dsn += "&transaction_read_only=1"
+	}
+	db, err := openMySQL(cfg, "mysql", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("error opening MySQL connection: %w", err)
 	}
@@ -139,32 +144,11 @@ func GetSemaphoreSize(db *sql.DB) (semaphoreSize, maxConnections int, maxAllowed
 	return semaphoreSize, maxConnections, maxAllowedPacket, nil
 }
 
-// PrepareStatements prepares MySQL update and insert statements with new fields
-func PrepareStatements(db *sql.DB) (*sql.Stmt, *sql.Stmt, error) {
-	log := logger.GetLogger()
-
-	updateStmt, err := db.Prepare(`
-        UPDATE TB_ESTOQUE
-        SET DESCRICAO = ?, QTD_ATUAL = ?, PRC_CUSTO = ?, PRC_DOLAR = ?, 
-            PRC_VENDA = ?, PRC_3X = ?, PRC_6X = ?, PRC_10X = ?
-        WHERE ID_ESTOQUE = ?
-    `)
-	if err != nil {
-		return nil, nil, fmt.Errorf("error preparing MySQL update statement: %w", err)
+// openMySQL opens the MySQL database, or its development mock, recording the
+// statements committed through it in REPLAY_LOG_FILE when one is set
+func openMySQL(cfg config.Config, driverName, dsn string) (*sql.DB, error) {
+	if cfg.ReplayLogFile == "" || cfg.ReadOnly {
+		return sql.Open(driverName, dsn)
 	}
-
-	insertStmt, err := db.Prepare(`
-        INSERT INTO TB_ESTOQUE (ID_ESTOQUE, DESCRICAO, QTD_ATUAL, PRC_CUSTO, PRC_DOLAR, 
-                               PRC_VENDA, PRC_3X, PRC_6X, PRC_10X)
-        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
-    `)
-	if err != nil {
-		if closeErr := updateStmt.Close(); closeErr != nil {
-			log.Error().Err(closeErr).Msg("Error closing MySQL update statement")
-		}
-		return nil, nil, fmt.Errorf("error preparing MySQL insert statement: %w", err)
-	}
-
-	log.Debug().Msg("MySQL statements prepared successfully")
-	return updateStmt, insertStmt, nil
+	return replay.OpenDB(driverName, dsn, replay.Open(cfg.ReplayLogFile, cfg.ReplayLogFormat, cfg.ReplayLogMaxSizeMB))
 }
@@ -26,7 +26,8 @@ func ConnectFirebirdDev(cfg config.Config) (*sql.DB, error) {
 	// _busy_timeout: Wait up to 5 seconds if database is locked
 	// _journal_mode=WAL: Write-Ahead Logging for better concurrent performance
 	// _sync=NORMAL: Faster writes (acceptable for dev/test)
-	dsn := dbPath + "?_busy_timeout=5000&_journal_mode=WAL&_sync=NORMAL"
+	// _time_format=sqlite: Store time.Time values in a sortable SQLite format
+	dsn := dbPath + "?_busy_timeout=5000&_journal_mode=WAL&_sync=NORMAL&_time_format=sqlite"
 	db, err := sql.Open("sqlite", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("error opening SQLite Firebird mock: %w", err)
@@ -76,8 +77,16 @@ func ConnectMySQLDev(cfg config.Config) (*sql.DB, error) {
 	// _busy_timeout: Wait up to 5 seconds if database is locked
 	// _journal_mode=WAL: Write-Ahead Logging for better concurrent performance
 	// _sync=NORMAL: Faster writes (acceptable for dev/test)
-	dsn := dbPath + "?_busy_timeout=5000&_journal_mode=WAL&_sync=NORMAL"
-	db, err := sql.Open("sqlite", dsn)
+	// _time_format=sqlite: Store time.Time values in a sortable SQLite format
+	dsn := dbPath + "?_busy_timeout=5000&_journal_mode=WAL&_sync=NORMAL&_time_format=sqlite"
+	if cfg.ReadOnly {
+		if !dbExists {
+			return nil, fmt.Errorf("SQLite MySQL mock %s does not exist and cannot be created: %w", dbPath, ErrReadOnly)
+		}
+		// query_only mirrors the read-only MySQL session
+		dsn += "&_pragma=query_only(1)"
+	}
+	db, err := openMySQL(cfg, "sqlite", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("error opening SQLite MySQL mock: %w", err)
 	}
@@ -137,7 +146,14 @@ func initFirebirdSchema(db *sql.DB) error {
 		ID_ESTOQUE INTEGER PRIMARY KEY,
 		DESCRICAO TEXT NOT NULL,
 		PRC_CUSTO REAL,
-		STATUS TEXT DEFAULT 'A'
+		STATUS TEXT DEFAULT 'A',
+		ID_GRUPO INTEGER,
+		DT_ALTERACAO DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS TB_GRUPO (
+		ID_GRUPO INTEGER PRIMARY KEY,
+		DESCRICAO TEXT NOT NULL
 	);
 
 	CREATE TABLE IF NOT EXISTS TB_EST_PRODUTO (
@@ -160,14 +176,21 @@ func initFirebirdSchema(db *sql.DB) error {
 	// Insert minimal sample data
 	sampleData := `
 	-- Sample products
-	INSERT INTO TB_ESTOQUE (ID_ESTOQUE, DESCRICAO, PRC_CUSTO, STATUS) VALUES
-		(1, 'Product A - Sample Item', 100.00, 'A'),
-		(2, 'Product B - Test Widget', 250.50, 'A'),
-		(3, 'Product C - Development Kit', 500.00, 'A'),
-		(4, 'Product D - Mock Component', 75.25, 'A'),
-		(5, 'Product E - Testing Tool', 150.00, 'A'),
-		(17973, 'Special Test Product', 1000.00, 'A'),
-		(100, 'Inactive Product', 200.00, 'I');
+	INSERT INTO TB_ESTOQUE (ID_ESTOQUE, DESCRICAO, PRC_CUSTO, STATUS, ID_GRUPO) VALUES
+		(1, 'Product A - Sample Item', 100.00, 'A', 1),
+		(2, 'Product B - Test Widget', 250.50, 'A', 1),
+		(3, 'Product C - Development Kit', 500.00, 'A', 2),
+		(4, 'Product D - Mock Component', 75.25, 'A', 2),
+		(5, 'Product E - Testing Tool', 150.00, 'A', 3),
+		(17973, 'Special Test Product', 1000.00, 'A', 17),
+		(100, 'Inactive Product', 200.00, 'I', 3);
+
+	-- Product groups
+	INSERT INTO TB_GRUPO (ID_GRUPO, DESCRICAO) VALUES
+		(1, 'Group 1'),
+		(2, 'Group 2'),
+		(3, 'Group 3'),
+		(17, 'Special Test Group');
 
 	-- Quantities
 	INSERT INTO TB_EST_PRODUTO (ID_IDENTIFICADOR, QTD_ATUAL) VALUES
@@ -229,7 +252,11 @@ func initMySQLSchema(db *sql.DB) error {
 		PRC_VENDA REAL DEFAULT 0,
 		PRC_3X REAL DEFAULT 0,
 		PRC_6X REAL DEFAULT 0,
-		PRC_10X REAL DEFAULT 0
+		PRC_10X REAL DEFAULT 0,
+		VISIVEL INTEGER DEFAULT 1,
+		STATUS TEXT,
+		ATIVO INTEGER DEFAULT 1,
+		DELETED_AT DATETIME
 	);
 	`
 
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/waldirborbajr/sync/config"
+)
+
+// firebirdPort is the port the Firebird driver connects to when FIREBIRD_HOST has none
+const firebirdPort = "3050"
+
+// DSNProblems returns what keeps the Firebird and MySQL connection strings
+// built from cfg from reaching the configured server, database and user,
+// nil when both parse back to their settings. Problems never quote secrets.
+func DSNProblems(cfg config.Config) []string {
+	var problems []string
+
+	if !firebirdDSNParses(cfg) {
+		// Not the parse error: it quotes the DSN, password included
+		problems = append(problems, "Firebird connection string does not parse back to FIREBIRD_USER, FIREBIRD_PASSWORD and FIREBIRD_HOST: check them for :, /, ? and #")
+	} else if _, port, err := net.SplitHostPort(FirebirdAddress(cfg)); err != nil || !validPort(port) {
+		problems = append(problems, fmt.Sprintf("FIREBIRD_HOST %q is not a host or host:port", cfg.FirebirdHost))
+	}
+
+	if !validPort(cfg.MySQLPort) {
+		problems = append(problems, fmt.Sprintf("MYSQL_PORT %q is not a port number (1-65535)", cfg.MySQLPort))
+	}
+	parsed, err := mysql.ParseDSN(cfg.GetMySQLDSN())
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("MySQL connection string does not parse: %v", err))
+	} else if parsed.User != cfg.MySQLUser || parsed.Passwd != cfg.MySQLPassword || parsed.DBName != cfg.MySQLDatabase || parsed.Addr != MySQLAddress(cfg) {
+		problems = append(problems, "MySQL connection string does not parse back to MYSQL_USER, MYSQL_PASSWORD, MYSQL_HOST and MYSQL_DATABASE: check them for :, @, / and parentheses")
+	}
+	return problems
+}
+
+// firebirdDSNParses reports whether the Firebird driver, which parses its DSN
+// as the URL firebird://DSN, reads back the user, password and host of cfg
+func firebirdDSNParses(cfg config.Config) bool {
+	u, err := url.Parse("firebird://" + cfg.GetFirebirdDSN())
+	if err != nil {
+		return false
+	}
+	password, _ := u.User.Password()
+	return u.User.Username() == cfg.FirebirdUser && password == cfg.FirebirdPassword && u.Host == cfg.FirebirdHost
+}
+
+// FirebirdAddress returns the host:port the Firebird driver connects to
+func FirebirdAddress(cfg config.Config) string {
+	if _, _, err := net.SplitHostPort(cfg.FirebirdHost); err == nil {
+		return cfg.FirebirdHost
+	}
+	return net.JoinHostPort(cfg.FirebirdHost, firebirdPort)
+}
+
+// MySQLAddress returns the host:port the MySQL driver connects to
+func MySQLAddress(cfg config.Config) string {
+	return net.JoinHostPort(cfg.MySQLHost, cfg.MySQLPort)
+}
+
+// validPort reports whether port is a TCP port number
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n <= 65535
+}
@@ -0,0 +1,45 @@
+package db
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/waldirborbajr/sync/config"
+)
+
+func TestDSNProblems(t *testing.T) {
+	valid := config.Config{
+		FirebirdUser: "SYSDBA", FirebirdPassword: "masterkey", FirebirdHost: "erp.local", FirebirdPath: "/data/erp.fdb",
+		MySQLUser: "shop", MySQLPassword: "s3cret!", MySQLHost: "db.local", MySQLPort: "3306", MySQLDatabase: "shop",
+	}
+	tests := []struct {
+		name   string
+		change func(*config.Config)
+		want   []string // Substrings of the problems, in order
+	}{
+		{"valid", func(*config.Config) {}, nil},
+		{"firebird port", func(c *config.Config) { c.FirebirdHost = "erp.local:3051" }, nil},
+		{"firebird bad port", func(c *config.Config) { c.FirebirdHost = "erp.local:fb" }, []string{"Firebird connection string does not parse"}},
+		{"firebird password", func(c *config.Config) { c.FirebirdPassword = "pass?word" }, []string{"Firebird connection string"}},
+		{"firebird empty port", func(c *config.Config) { c.FirebirdHost = "erp.local:" }, []string{"FIREBIRD_HOST"}},
+		{"mysql port", func(c *config.Config) { c.MySQLPort = "70000" }, []string{"MYSQL_PORT"}},
+		{"mysql user", func(c *config.Config) { c.MySQLUser = "shop:web" }, []string{"MySQL connection string does not parse back"}},
+	}
+	for _, tt := range tests {
+		cfg := valid
+		tt.change(&cfg)
+		got := DSNProblems(cfg)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: DSNProblems() = %q; want %d problems", tt.name, got, len(tt.want))
+			continue
+		}
+		for i := range got {
+			if !strings.Contains(got[i], tt.want[i]) {
+				t.Errorf("%s: problem %q; want it to mention %q", tt.name, got[i], tt.want[i])
+			}
+			if strings.Contains(got[i], cfg.FirebirdPassword) || strings.Contains(got[i], cfg.MySQLPassword) {
+				t.Errorf("%s: problem %q quotes a password", tt.name, got[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// ErrorClass groups database errors by how a run should react to them
+type ErrorClass string
+
+const (
+	ClassConnection  ErrorClass = "connection"  // Server unreachable or connection dropped
+	ClassDeadlock    ErrorClass = "deadlock"    // Deadlock or lock conflict, safe to retry
+	ClassTimeout     ErrorClass = "timeout"     // Lock wait or operation timeout
+	ClassReadOnly    ErrorClass = "read_only"   // Write refused by a read-only session or server
+	ClassMaintenance ErrorClass = "maintenance" // Firebird shut down, in backup or made read-only by its DBA
+	ClassConstraint  ErrorClass = "constraint"  // Duplicate key, foreign key, NOT NULL or CHECK violation
+	ClassConversion  ErrorClass = "conversion"  // Value out of range, truncated or of the wrong type
+	ClassOther       ErrorClass = "other"       // Syntax, permission or configuration errors
+)
+
+// MySQL server error numbers used for classification
+const (
+	mysqlErrLockWaitTimeout   = 1205
+	mysqlErrDeadlock          = 1213
+	mysqlErrTooManyConns      = 1040
+	mysqlErrServerShutdown    = 1053
+	mysqlErrConnectionKilled  = 1927
+	mysqlErrQueryInterrupted  = 1317
+	mysqlErrServerGoneAway    = 2006
+	mysqlErrServerLostConnect = 2013
+	mysqlErrOptionPrevents    = 1290 // --read-only / --super-read-only server
+	mysqlErrReadOnlyTx        = 1792
+
+	mysqlErrBadNull         = 1048
+	mysqlErrDupEntry        = 1062
+	mysqlErrNoDefault       = 1364
+	mysqlErrRowIsReferenced = 1451
+	mysqlErrNoReferencedRow = 1452
+	mysqlErrCheckViolated   = 3819
+
+	mysqlErrOutOfRange       = 1264
+	mysqlErrDataTruncated    = 1265
+	mysqlErrTruncatedValue   = 1292
+	mysqlErrIncorrectValue   = 1366
+	mysqlErrDataTooLong      = 1406
+	mysqlErrDivisionByZero   = 1365
+	mysqlErrIncorrectDecimal = 1367
+)
+
+// Classify returns the class of err. Driver error codes are checked first and
+// message matching is used for drivers that only expose text (Firebird, SQLite).
+func Classify(err error) ErrorClass {
+	if err == nil {
+		return ""
+	}
+
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		switch myErr.Number {
+		case mysqlErrDeadlock:
+			return ClassDeadlock
+		case mysqlErrLockWaitTimeout, mysqlErrQueryInterrupted:
+			return ClassTimeout
+		case mysqlErrTooManyConns, mysqlErrServerShutdown, mysqlErrConnectionKilled, mysqlErrServerGoneAway, mysqlErrServerLostConnect:
+			return ClassConnection
+		case mysqlErrOptionPrevents, mysqlErrReadOnlyTx:
+			return ClassReadOnly
+		case mysqlErrBadNull, mysqlErrDupEntry, mysqlErrNoDefault, mysqlErrRowIsReferenced, mysqlErrNoReferencedRow, mysqlErrCheckViolated:
+			return ClassConstraint
+		case mysqlErrOutOfRange, mysqlErrDataTruncated, mysqlErrTruncatedValue, mysqlErrIncorrectValue, mysqlErrDataTooLong, mysqlErrDivisionByZero, mysqlErrIncorrectDecimal:
+			return ClassConversion
+		}
+		return ClassOther
+	}
+
+	if errors.Is(err, ErrReadOnly) {
+		return ClassReadOnly
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ClassTimeout
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return ClassConnection
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return ClassTimeout
+		}
+		return ClassConnection
+	}
+
+	msg := strings.ToLower(err.Error())
+	switch {
+	// Before the connection errors: a shutdown ends the attachments with
+	// "connection shutdown" followed by "database <path> shutdown"
+	case strings.Contains(msg, "database") && strings.Contains(msg, "shutdown"),
+		strings.Contains(msg, "single-user maintenance"), strings.Contains(msg, "physical backup"), strings.Contains(msg, "on read-only database"):
+		return ClassMaintenance
+	case strings.Contains(msg, "deadlock"), strings.Contains(msg, "lock conflict"), strings.Contains(msg, "update conflicts with concurrent update"):
+		return ClassDeadlock
+	case strings.Contains(msg, "database is locked"), strings.Contains(msg, "lock time-out"), strings.Contains(msg, "timeout"):
+		return ClassTimeout
+	case strings.Contains(msg, "readonly database"), strings.Contains(msg, "read-only transaction"):
+		return ClassReadOnly
+	case strings.Contains(msg, "connection refused"), strings.Contains(msg, "connection reset"), strings.Contains(msg, "broken pipe"), strings.Contains(msg, "connection shutdown"):
+		return ClassConnection
+	case strings.Contains(msg, "constraint failed"), strings.Contains(msg, "violation of primary or unique key"), strings.Contains(msg, "violation of foreign key"),
+		strings.Contains(msg, "validation error for column"), strings.Contains(msg, "duplicate entry"):
+		return ClassConstraint
+	case strings.Contains(msg, "scan error"), strings.Contains(msg, "converting"), strings.Contains(msg, "conversion error"),
+		strings.Contains(msg, "arithmetic exception"), strings.Contains(msg, "string right truncation"), strings.Contains(msg, "out of range"):
+		return ClassConversion
+	}
+	return ClassOther
+}
+
+// IsRetryable reports whether err is transient, so repeating the operation may succeed
+func IsRetryable(err error) bool {
+	switch Classify(err) {
+	case ClassConnection, ClassDeadlock, ClassTimeout:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		err  error
+		want ErrorClass
+	}{
+		{nil, ""},
+		{&mysql.MySQLError{Number: 1213, Message: "Deadlock found"}, ClassDeadlock},
+		{fmt.Errorf("update failed: %w", &mysql.MySQLError{Number: 1205}), ClassTimeout},
+		{&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}, ClassConstraint},
+		{errors.New("UNIQUE constraint failed: TB_ESTOQUE.ID_ESTOQUE"), ClassConstraint},
+		{&mysql.MySQLError{Number: 1264, Message: "Out of range value for column 'QTD_ATUAL'"}, ClassConversion},
+		{errors.New(`sql: Scan error on column index 2, name "QTD_ATUAL": converting NULL to float64 is unsupported`), ClassConversion},
+		{fmt.Errorf("bulk insert failed: %w", driver.ErrBadConn), ClassConnection},
+		{context.DeadlineExceeded, ClassTimeout},
+		{errors.New("lock conflict on no wait transaction"), ClassDeadlock},
+		{errors.New("database is locked (5) (SQLITE_BUSY)"), ClassTimeout},
+		{errors.New("dial tcp 10.0.0.1:3050: connect: connection refused"), ClassConnection},
+		{&mysql.MySQLError{Number: 1792, Message: "Cannot execute statement in a READ ONLY transaction."}, ClassReadOnly},
+		{fmt.Errorf("sync run: %w", ErrReadOnly), ClassReadOnly},
+		{errors.New("attempt to write a readonly database (8)"), ClassReadOnly},
+		{errors.New("database /data/loja.fdb shutdown"), ClassMaintenance},
+		{errors.New("connection shutdown\ndatabase /data/loja.fdb shutdown in progress"), ClassMaintenance},
+		{errors.New("Database is in single-user maintenance mode"), ClassMaintenance},
+		{errors.New("attempted update on read-only database"), ClassMaintenance},
+		{errors.New("connection shutdown"), ClassConnection},
+		{errors.New("invalid STATUS_MAP value"), ClassOther},
+	}
+
+	for _, tt := range tests {
+		if got := Classify(tt.err); got != tt.want {
+			t.Errorf("Classify(%v) = %q; want %q", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/logger"
+)
+
+// HeartbeatInterval is how often a running instance refreshes DT_HEARTBEAT.
+// Instances without a heartbeat for two intervals are considered gone.
+const HeartbeatInterval = 30 * time.Second
+
+// instancesDDL creates the registry of machines syncing into this database
+const instancesDDL = `
+	CREATE TABLE IF NOT EXISTS TB_SYNC_INSTANCIAS (
+		MACHINE_ID VARCHAR(64) NOT NULL PRIMARY KEY,
+		HOSTNAME VARCHAR(255) NULL,
+		RUN_ID VARCHAR(64) NULL,
+		CONFIG_FINGERPRINT VARCHAR(64) NULL,
+		VERSAO VARCHAR(64) NULL,
+		DT_INICIO DATETIME NULL,
+		DT_HEARTBEAT DATETIME NULL,
+		DT_FIM DATETIME NULL,
+		ID_TRANSACAO_FIREBIRD BIGINT NULL
+	)`
+
+// instancesDDLDev creates the instance registry on the SQLite mock
+const instancesDDLDev = `
+	CREATE TABLE IF NOT EXISTS TB_SYNC_INSTANCIAS (
+		MACHINE_ID TEXT NOT NULL PRIMARY KEY,
+		HOSTNAME TEXT,
+		RUN_ID TEXT,
+		CONFIG_FINGERPRINT TEXT,
+		VERSAO TEXT,
+		DT_INICIO DATETIME,
+		DT_HEARTBEAT DATETIME,
+		DT_FIM DATETIME,
+		ID_TRANSACAO_FIREBIRD INTEGER
+	)`
+
+// Instance is a machine registered in TB_SYNC_INSTANCIAS
+type Instance struct {
+	MachineID   string
+	Hostname    string
+	RunID       string
+	Fingerprint string
+	Version     string
+	Heartbeat   time.Time
+}
+
+// RegisterInstance marks this machine's run as active and returns the other
+// machines that currently have an active run against the same database.
+func RegisterInstance(ctx context.Context, db *sql.DB, cfg config.Config, self Instance) ([]Instance, error) {
+	ddl := instancesDDL
+	if cfg.DevMode {
+		ddl = instancesDDLDev
+	}
+	if _, err := db.ExecContext(ctx, ddl); err != nil {
+		return nil, fmt.Errorf("error creating TB_SYNC_INSTANCIAS: %w", err)
+	}
+	if err := addTransactionColumn(ctx, db); err != nil {
+		return nil, fmt.Errorf("error adding ID_TRANSACAO_FIREBIRD to TB_SYNC_INSTANCIAS: %w", err)
+	}
+
+	now := time.Now().UTC()
+	res, err := db.ExecContext(ctx, `UPDATE TB_SYNC_INSTANCIAS
+		SET HOSTNAME = ?, RUN_ID = ?, CONFIG_FINGERPRINT = ?, VERSAO = ?, DT_INICIO = ?, DT_HEARTBEAT = ?, DT_FIM = NULL,
+			ID_TRANSACAO_FIREBIRD = NULL
+		WHERE MACHINE_ID = ?`,
+		self.Hostname, self.RunID, self.Fingerprint, self.Version, now, now, self.MachineID)
+	if err != nil {
+		return nil, fmt.Errorf("error registering instance: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		if _, err := db.ExecContext(ctx, `INSERT INTO TB_SYNC_INSTANCIAS
+			(MACHINE_ID, HOSTNAME, RUN_ID, CONFIG_FINGERPRINT, VERSAO, DT_INICIO, DT_HEARTBEAT) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			self.MachineID, self.Hostname, self.RunID, self.Fingerprint, self.Version, now, now); err != nil {
+			return nil, fmt.Errorf("error registering instance: %w", err)
+		}
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT MACHINE_ID, HOSTNAME, RUN_ID, CONFIG_FINGERPRINT, VERSAO, DT_HEARTBEAT
+		FROM TB_SYNC_INSTANCIAS
+		WHERE MACHINE_ID <> ? AND DT_FIM IS NULL AND DT_HEARTBEAT >= ?`,
+		self.MachineID, now.Add(-2*HeartbeatInterval))
+	if err != nil {
+		return nil, fmt.Errorf("error checking concurrent instances: %w", err)
+	}
+	defer rows.Close()
+
+	var others []Instance
+	for rows.Next() {
+		var in Instance
+		var host, runID, fingerprint, version sql.NullString
+		if err := rows.Scan(&in.MachineID, &host, &runID, &fingerprint, &version, &in.Heartbeat); err != nil {
+			return nil, fmt.Errorf("error reading TB_SYNC_INSTANCIAS: %w", err)
+		}
+		in.Hostname, in.RunID, in.Fingerprint, in.Version = host.String, runID.String, fingerprint.String, version.String
+		others = append(others, in)
+	}
+	return others, rows.Err()
+}
+
+// addTransactionColumn adds ID_TRANSACAO_FIREBIRD to registries created
+// before it existed
+func addTransactionColumn(ctx context.Context, db *sql.DB) error {
+	rows, err := db.QueryContext(ctx, "SELECT ID_TRANSACAO_FIREBIRD FROM TB_SYNC_INSTANCIAS WHERE 1 = 0")
+	if err == nil {
+		return rows.Close()
+	}
+	_, err = db.ExecContext(ctx, "ALTER TABLE TB_SYNC_INSTANCIAS ADD COLUMN ID_TRANSACAO_FIREBIRD BIGINT")
+	return err
+}
+
+// RecordFirebirdTransaction records the ID of the Firebird transaction the
+// machine's active run reads the products in (FIREBIRD_ISOLATION)
+func RecordFirebirdTransaction(ctx context.Context, db *sql.DB, machineID string, transaction int64) error {
+	_, err := db.ExecContext(ctx, "UPDATE TB_SYNC_INSTANCIAS SET ID_TRANSACAO_FIREBIRD = ? WHERE MACHINE_ID = ?", transaction, machineID)
+	return err
+}
+
+// HeartbeatInstance refreshes DT_HEARTBEAT for the machine's active run
+func HeartbeatInstance(ctx context.Context, db *sql.DB, machineID string) error {
+	_, err := db.ExecContext(ctx, "UPDATE TB_SYNC_INSTANCIAS SET DT_HEARTBEAT = ? WHERE MACHINE_ID = ?", time.Now().UTC(), machineID)
+	return err
+}
+
+// FinishInstance marks the machine's run as finished
+func FinishInstance(db *sql.DB, machineID string) {
+	now := time.Now().UTC()
+	if _, err := db.Exec("UPDATE TB_SYNC_INSTANCIAS SET DT_HEARTBEAT = ?, DT_FIM = ? WHERE MACHINE_ID = ?", now, now, machineID); err != nil {
+		log := logger.GetLogger()
+		log.Warn().Err(err).Msg("Could not mark instance run as finished")
+	}
+}
@@ -0,0 +1,51 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+// TablePrivileges is what the MySQL user may do on a table the sync writes
+type TablePrivileges struct {
+	Select bool
+	Insert bool
+	Update bool
+	Err    error // First refusal, nil when every privilege is granted
+}
+
+// Missing returns the privileges refused, in SELECT, INSERT, UPDATE order
+func (p TablePrivileges) Missing() []string {
+	var missing []string
+	for _, priv := range []struct {
+		name    string
+		granted bool
+	}{{"SELECT", p.Select}, {"INSERT", p.Insert}, {"UPDATE", p.Update}} {
+		if !priv.granted {
+			missing = append(missing, priv.name)
+		}
+	}
+	return missing
+}
+
+// CheckTablePrivileges tries reading, inserting into and updating table, a
+// quoted and possibly schema-qualified name, without changing a row: EXPLAIN
+// needs the privileges of the statement it explains. column is any column of
+// the table, the key usually.
+func CheckTablePrivileges(ctx context.Context, conn *sql.DB, table, column string) TablePrivileges {
+	var p TablePrivileges
+	try := func(query string) bool {
+		rows, err := conn.QueryContext(ctx, query)
+		if err == nil {
+			err = rows.Close()
+		}
+		if err != nil && p.Err == nil {
+			p.Err = err
+		}
+		return err == nil
+	}
+
+	p.Select = try("SELECT * FROM " + table + " WHERE 1 = 0")
+	p.Insert = try("EXPLAIN INSERT INTO " + table + " SELECT * FROM " + table + " WHERE 1 = 0")
+	p.Update = try("EXPLAIN UPDATE " + table + " SET " + column + " = " + column + " WHERE 1 = 0")
+	return p
+}
@@ -0,0 +1,21 @@
+package db
+
+import (
+	"errors"
+
+	"github.com/waldirborbajr/sync/config"
+)
+
+// ErrReadOnly is returned for operations refused in observer mode (READ_ONLY)
+var ErrReadOnly = errors.New("observer mode (READ_ONLY) is on")
+
+// CheckWritable returns ErrReadOnly in observer mode. Commands that write call
+// it before starting so support staff get a clear refusal; MySQL sessions are
+// opened read-only as well, so a write path missing the check still fails.
+// Firebird is only ever read.
+func CheckWritable(cfg config.Config) error {
+	if cfg.ReadOnly {
+		return ErrReadOnly
+	}
+	return nil
+}
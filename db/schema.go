@@ -0,0 +1,449 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/logger"
+)
+
+// priceHistoryDDL creates the price history table on MySQL
+const priceHistoryDDL = `
+	CREATE TABLE IF NOT EXISTS TB_PRECO_HISTORICO (
+		ID BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+		ID_ESTOQUE INT NOT NULL,
+		PRC_CUSTO_ANTERIOR DECIMAL(15,2) NULL,
+		PRC_CUSTO_NOVO DECIMAL(15,2) NULL,
+		PRC_VENDA_ANTERIOR DECIMAL(15,2) NULL,
+		PRC_VENDA_NOVO DECIMAL(15,2) NULL,
+		PRC_3X_ANTERIOR DECIMAL(15,2) NULL,
+		PRC_3X_NOVO DECIMAL(15,2) NULL,
+		PRC_6X_ANTERIOR DECIMAL(15,2) NULL,
+		PRC_6X_NOVO DECIMAL(15,2) NULL,
+		PRC_10X_ANTERIOR DECIMAL(15,2) NULL,
+		PRC_10X_NOVO DECIMAL(15,2) NULL,
+		RUN_ID VARCHAR(64) NOT NULL,
+		DT_ALTERACAO DATETIME NOT NULL,
+		KEY IDX_PRECO_HISTORICO_ESTOQUE (ID_ESTOQUE, DT_ALTERACAO),
+		KEY IDX_PRECO_HISTORICO_DATA (DT_ALTERACAO)
+	)`
+
+// priceHistoryDDLDev creates the price history table on the SQLite mock
+const priceHistoryDDLDev = `
+	CREATE TABLE IF NOT EXISTS TB_PRECO_HISTORICO (
+		ID INTEGER PRIMARY KEY AUTOINCREMENT,
+		ID_ESTOQUE INTEGER NOT NULL,
+		PRC_CUSTO_ANTERIOR REAL,
+		PRC_CUSTO_NOVO REAL,
+		PRC_VENDA_ANTERIOR REAL,
+		PRC_VENDA_NOVO REAL,
+		PRC_3X_ANTERIOR REAL,
+		PRC_3X_NOVO REAL,
+		PRC_6X_ANTERIOR REAL,
+		PRC_6X_NOVO REAL,
+		PRC_10X_ANTERIOR REAL,
+		PRC_10X_NOVO REAL,
+		RUN_ID TEXT NOT NULL,
+		DT_ALTERACAO DATETIME NOT NULL
+	)`
+
+// auditDDL creates the table recording every TB_ESTOQUE row the sync writes.
+// QTD_ORIGEM and QTD_RESERVADA explain a QTD_ATUAL lowered by RESERVATIONS_QUERY.
+const auditDDL = `
+	CREATE TABLE IF NOT EXISTS TB_ESTOQUE_SYNC_AUDIT (
+		ID BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+		ID_ESTOQUE INT NOT NULL,
+		OPERACAO CHAR(1) NOT NULL,
+		COLUNAS VARCHAR(1024) NOT NULL,
+		VALORES_ANTERIORES TEXT NULL,
+		VALORES_NOVOS TEXT NOT NULL,
+		QTD_ORIGEM DECIMAL(15,3) NULL,
+		QTD_RESERVADA DECIMAL(15,3) NULL,
+		RUN_ID VARCHAR(64) NOT NULL,
+		DT_ALTERACAO DATETIME NOT NULL,
+		KEY IDX_SYNC_AUDIT_ESTOQUE (ID_ESTOQUE, DT_ALTERACAO),
+		KEY IDX_SYNC_AUDIT_DATA (DT_ALTERACAO),
+		KEY IDX_SYNC_AUDIT_RUN (RUN_ID)
+	)`
+
+// auditDDLDev creates the audit table on the SQLite mock
+const auditDDLDev = `
+	CREATE TABLE IF NOT EXISTS TB_ESTOQUE_SYNC_AUDIT (
+		ID INTEGER PRIMARY KEY AUTOINCREMENT,
+		ID_ESTOQUE INTEGER NOT NULL,
+		OPERACAO TEXT NOT NULL,
+		COLUNAS TEXT NOT NULL,
+		VALORES_ANTERIORES TEXT,
+		VALORES_NOVOS TEXT NOT NULL,
+		QTD_ORIGEM REAL,
+		QTD_RESERVADA REAL,
+		RUN_ID TEXT NOT NULL,
+		DT_ALTERACAO DATETIME NOT NULL
+	)`
+
+// categoryDDL creates the product category table on MySQL
+const categoryDDL = `
+	CREATE TABLE IF NOT EXISTS TB_CATEGORIA (
+		ID_CATEGORIA INT NOT NULL PRIMARY KEY,
+		DESCRICAO VARCHAR(100) NOT NULL
+	)`
+
+// categoryDDLDev creates the product category table on the SQLite mock
+const categoryDDLDev = `
+	CREATE TABLE IF NOT EXISTS TB_CATEGORIA (
+		ID_CATEGORIA INTEGER NOT NULL PRIMARY KEY,
+		DESCRICAO TEXT NOT NULL
+	)`
+
+// customerDDL creates the customer table on MySQL
+const customerDDL = `
+	CREATE TABLE IF NOT EXISTS TB_CLIENTE (
+		ID_CLIENTE INT NOT NULL PRIMARY KEY,
+		NOME VARCHAR(150),
+		CPF_CNPJ VARCHAR(20),
+		EMAIL VARCHAR(150),
+		TELEFONE VARCHAR(30),
+		STATUS VARCHAR(20),
+		INDEX IDX_CLIENTE_CPF_CNPJ (CPF_CNPJ)
+	)`
+
+// customerDDLDev creates the customer table on the SQLite mock
+const customerDDLDev = `
+	CREATE TABLE IF NOT EXISTS TB_CLIENTE (
+		ID_CLIENTE INTEGER NOT NULL PRIMARY KEY,
+		NOME TEXT,
+		CPF_CNPJ TEXT,
+		EMAIL TEXT,
+		TELEFONE TEXT,
+		STATUS TEXT
+	)`
+
+// warehouseDDL creates the per-warehouse quantity table on MySQL.
+// Child rows follow their product through ON DELETE CASCADE.
+const warehouseDDL = `
+	CREATE TABLE IF NOT EXISTS TB_ESTOQUE_DEPOSITO (
+		ID_ESTOQUE INT NOT NULL,
+		ID_DEPOSITO INT NOT NULL,
+		QTD_ATUAL DECIMAL(15,3) NOT NULL DEFAULT 0,
+		PRIMARY KEY (ID_ESTOQUE, ID_DEPOSITO),
+		CONSTRAINT FK_ESTOQUE_DEPOSITO_ESTOQUE FOREIGN KEY (ID_ESTOQUE)
+			REFERENCES TB_ESTOQUE (ID_ESTOQUE) ON DELETE CASCADE
+	)`
+
+// warehouseDDLDev creates the per-warehouse quantity table on the SQLite mock
+const warehouseDDLDev = `
+	CREATE TABLE IF NOT EXISTS TB_ESTOQUE_DEPOSITO (
+		ID_ESTOQUE INTEGER NOT NULL,
+		ID_DEPOSITO INTEGER NOT NULL,
+		QTD_ATUAL REAL NOT NULL DEFAULT 0,
+		PRIMARY KEY (ID_ESTOQUE, ID_DEPOSITO),
+		FOREIGN KEY (ID_ESTOQUE) REFERENCES TB_ESTOQUE (ID_ESTOQUE) ON DELETE CASCADE
+	)`
+
+// changedKeysDDL creates the table handing the keys written by each run to post-sync SQL
+const changedKeysDDL = `
+	CREATE TABLE IF NOT EXISTS TB_SYNC_ALTERADOS (
+		RUN_ID VARCHAR(64) NOT NULL,
+		ID_ESTOQUE INT NOT NULL,
+		PRIMARY KEY (RUN_ID, ID_ESTOQUE)
+	)`
+
+// changedKeysDDLDev creates the changed keys table on the SQLite mock
+const changedKeysDDLDev = `
+	CREATE TABLE IF NOT EXISTS TB_SYNC_ALTERADOS (
+		RUN_ID TEXT NOT NULL,
+		ID_ESTOQUE INTEGER NOT NULL,
+		PRIMARY KEY (RUN_ID, ID_ESTOQUE)
+	)`
+
+// orderExportDDL creates the table recording the web orders exported into Firebird
+const orderExportDDL = `
+	CREATE TABLE IF NOT EXISTS TB_SYNC_PEDIDOS (
+		ID_PEDIDO_WEB BIGINT NOT NULL PRIMARY KEY,
+		ID_PEDIDO_FIREBIRD BIGINT NOT NULL,
+		RUN_ID VARCHAR(64) NOT NULL,
+		DT_EXPORTACAO DATETIME NOT NULL
+	)`
+
+// orderExportDDLDev creates the order export table on the SQLite mock
+const orderExportDDLDev = `
+	CREATE TABLE IF NOT EXISTS TB_SYNC_PEDIDOS (
+		ID_PEDIDO_WEB INTEGER NOT NULL PRIMARY KEY,
+		ID_PEDIDO_FIREBIRD INTEGER NOT NULL,
+		RUN_ID TEXT NOT NULL,
+		DT_EXPORTACAO DATETIME NOT NULL
+	)`
+
+// movementDDL creates the stock movement history read by the web dashboard
+const movementDDL = `
+	CREATE TABLE IF NOT EXISTS TB_MOVIMENTO_ESTOQUE (
+		ID_MOVIMENTO BIGINT NOT NULL PRIMARY KEY,
+		ID_ESTOQUE INT NOT NULL,
+		TIPO VARCHAR(10) NOT NULL,
+		QUANTIDADE DECIMAL(15,4) NOT NULL,
+		DT_MOVIMENTO DATETIME NOT NULL,
+		DOCUMENTO VARCHAR(60) NULL,
+		INDEX IDX_MOVIMENTO_PRODUTO (ID_ESTOQUE, DT_MOVIMENTO)
+	)`
+
+// movementDDLDev creates the stock movement history on the SQLite mock
+const movementDDLDev = `
+	CREATE TABLE IF NOT EXISTS TB_MOVIMENTO_ESTOQUE (
+		ID_MOVIMENTO INTEGER NOT NULL PRIMARY KEY,
+		ID_ESTOQUE INTEGER NOT NULL,
+		TIPO TEXT NOT NULL,
+		QUANTIDADE REAL NOT NULL,
+		DT_MOVIMENTO DATETIME NOT NULL,
+		DOCUMENTO TEXT NULL
+	)`
+
+// reverseDDL creates the table remembering the values last pushed back into Firebird
+const reverseDDL = `
+	CREATE TABLE IF NOT EXISTS TB_SYNC_REVERSO (
+		ID_ESTOQUE INT NOT NULL PRIMARY KEY,
+		HASH VARCHAR(16) NOT NULL,
+		DT_ENVIO DATETIME NOT NULL
+	)`
+
+// reverseDDLDev creates the reverse sync table on the SQLite mock
+const reverseDDLDev = `
+	CREATE TABLE IF NOT EXISTS TB_SYNC_REVERSO (
+		ID_ESTOQUE INTEGER NOT NULL PRIMARY KEY,
+		HASH TEXT NOT NULL,
+		DT_ENVIO DATETIME NOT NULL
+	)`
+
+// blobDDL creates the table remembering the length and hash of the BLOBs last copied
+const blobDDL = `
+	CREATE TABLE IF NOT EXISTS TB_SYNC_BLOBS (
+		ID_ESTOQUE INT NOT NULL,
+		COLUNA VARCHAR(64) NOT NULL,
+		TAMANHO BIGINT NULL,
+		HASH VARCHAR(64) NOT NULL,
+		DT_ENVIO DATETIME NOT NULL,
+		PRIMARY KEY (ID_ESTOQUE, COLUNA)
+	)`
+
+// blobDDLDev creates the BLOB sync table on the SQLite mock
+const blobDDLDev = `
+	CREATE TABLE IF NOT EXISTS TB_SYNC_BLOBS (
+		ID_ESTOQUE INTEGER NOT NULL,
+		COLUNA TEXT NOT NULL,
+		TAMANHO INTEGER,
+		HASH TEXT NOT NULL,
+		DT_ENVIO DATETIME NOT NULL,
+		PRIMARY KEY (ID_ESTOQUE, COLUNA)
+	)`
+
+// EnsurePriceHistoryTable creates TB_PRECO_HISTORICO when price history is enabled
+func EnsurePriceHistoryTable(db *sql.DB, cfg config.Config) error {
+	if !cfg.PriceHistoryEnabled {
+		return nil
+	}
+
+	ddl := priceHistoryDDL
+	if cfg.DevMode {
+		ddl = priceHistoryDDLDev
+	}
+	if _, err := db.Exec(ddl); err != nil {
+		return fmt.Errorf("error creating TB_PRECO_HISTORICO: %w", err)
+	}
+
+	log := logger.GetLogger()
+	log.Debug().Msg("TB_PRECO_HISTORICO table ready")
+	return nil
+}
+
+// EnsureAuditTable creates TB_ESTOQUE_SYNC_AUDIT when the audit is enabled
+func EnsureAuditTable(db *sql.DB, cfg config.Config) error {
+	if !cfg.AuditEnabled {
+		return nil
+	}
+
+	ddl := auditDDL
+	if cfg.DevMode {
+		ddl = auditDDLDev
+	}
+	if _, err := db.Exec(ddl); err != nil {
+		return fmt.Errorf("error creating TB_ESTOQUE_SYNC_AUDIT: %w", err)
+	}
+	if err := addAuditReservationColumns(db, cfg); err != nil {
+		return fmt.Errorf("error adding the reservation columns to TB_ESTOQUE_SYNC_AUDIT: %w", err)
+	}
+
+	log := logger.GetLogger()
+	log.Debug().Msg("TB_ESTOQUE_SYNC_AUDIT table ready")
+	return nil
+}
+
+// addAuditReservationColumns adds QTD_ORIGEM and QTD_RESERVADA to audit
+// tables created before they existed
+func addAuditReservationColumns(db *sql.DB, cfg config.Config) error {
+	rows, err := db.Query("SELECT QTD_ORIGEM, QTD_RESERVADA FROM TB_ESTOQUE_SYNC_AUDIT WHERE 1 = 0")
+	if err == nil {
+		return rows.Close()
+	}
+	kind := "DECIMAL(15,3) NULL"
+	if cfg.DevMode {
+		kind = "REAL"
+	}
+	for _, column := range []string{"QTD_ORIGEM", "QTD_RESERVADA"} {
+		if _, err := db.Exec("ALTER TABLE TB_ESTOQUE_SYNC_AUDIT ADD COLUMN " + column + " " + kind); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EnsureCategoryTable creates TB_CATEGORIA when category sync is enabled
+func EnsureCategoryTable(db *sql.DB, cfg config.Config) error {
+	if cfg.CategoryQuery == "" {
+		return nil
+	}
+
+	ddl := categoryDDL
+	if cfg.DevMode {
+		ddl = categoryDDLDev
+	}
+	if _, err := db.Exec(ddl); err != nil {
+		return fmt.Errorf("error creating TB_CATEGORIA: %w", err)
+	}
+
+	log := logger.GetLogger()
+	log.Debug().Msg("TB_CATEGORIA table ready")
+	return nil
+}
+
+// EnsureCustomerTable creates TB_CLIENTE when customer sync is enabled
+func EnsureCustomerTable(db *sql.DB, cfg config.Config) error {
+	if cfg.CustomerQuery == "" {
+		return nil
+	}
+
+	ddl := customerDDL
+	if cfg.DevMode {
+		ddl = customerDDLDev
+	}
+	if _, err := db.Exec(ddl); err != nil {
+		return fmt.Errorf("error creating TB_CLIENTE: %w", err)
+	}
+
+	log := logger.GetLogger()
+	log.Debug().Msg("TB_CLIENTE table ready")
+	return nil
+}
+
+// EnsureWarehouseTable creates TB_ESTOQUE_DEPOSITO when warehouse sync is enabled
+func EnsureWarehouseTable(db *sql.DB, cfg config.Config) error {
+	if cfg.WarehouseQuery == "" {
+		return nil
+	}
+
+	ddl := warehouseDDL
+	if cfg.DevMode {
+		ddl = warehouseDDLDev
+	}
+	if _, err := db.Exec(ddl); err != nil {
+		return fmt.Errorf("error creating TB_ESTOQUE_DEPOSITO: %w", err)
+	}
+
+	log := logger.GetLogger()
+	log.Debug().Msg("TB_ESTOQUE_DEPOSITO table ready")
+	return nil
+}
+
+// EnsureChangedKeysTable creates TB_SYNC_ALTERADOS when the changed keys handoff is enabled
+func EnsureChangedKeysTable(db *sql.DB, cfg config.Config) error {
+	if !cfg.ChangedKeysEnabled {
+		return nil
+	}
+
+	ddl := changedKeysDDL
+	if cfg.DevMode {
+		ddl = changedKeysDDLDev
+	}
+	if _, err := db.Exec(ddl); err != nil {
+		return fmt.Errorf("error creating TB_SYNC_ALTERADOS: %w", err)
+	}
+
+	log := logger.GetLogger()
+	log.Debug().Msg("TB_SYNC_ALTERADOS table ready")
+	return nil
+}
+
+// EnsureReverseTable creates TB_SYNC_REVERSO when columns are pushed back into Firebird
+func EnsureReverseTable(db *sql.DB, cfg config.Config) error {
+	if len(cfg.ReverseColumns) == 0 {
+		return nil
+	}
+
+	ddl := reverseDDL
+	if cfg.DevMode {
+		ddl = reverseDDLDev
+	}
+	if _, err := db.Exec(ddl); err != nil {
+		return fmt.Errorf("error creating TB_SYNC_REVERSO: %w", err)
+	}
+
+	log := logger.GetLogger()
+	log.Debug().Msg("TB_SYNC_REVERSO table ready")
+	return nil
+}
+
+// EnsureOrderExportTable creates TB_SYNC_PEDIDOS when web orders are exported
+func EnsureOrderExportTable(db *sql.DB, cfg config.Config) error {
+	if cfg.OrderExportQuery == "" {
+		return nil
+	}
+
+	ddl := orderExportDDL
+	if cfg.DevMode {
+		ddl = orderExportDDLDev
+	}
+	if _, err := db.Exec(ddl); err != nil {
+		return fmt.Errorf("error creating TB_SYNC_PEDIDOS: %w", err)
+	}
+
+	log := logger.GetLogger()
+	log.Debug().Msg("TB_SYNC_PEDIDOS table ready")
+	return nil
+}
+
+// EnsureMovementTable creates TB_MOVIMENTO_ESTOQUE when stock movements are synced
+func EnsureMovementTable(db *sql.DB, cfg config.Config) error {
+	if cfg.MovementQuery == "" {
+		return nil
+	}
+
+	ddl := movementDDL
+	if cfg.DevMode {
+		ddl = movementDDLDev
+	}
+	if _, err := db.Exec(ddl); err != nil {
+		return fmt.Errorf("error creating TB_MOVIMENTO_ESTOQUE: %w", err)
+	}
+
+	log := logger.GetLogger()
+	log.Debug().Msg("TB_MOVIMENTO_ESTOQUE table ready")
+	return nil
+}
+
+// EnsureBlobTable creates TB_SYNC_BLOBS when BLOB columns are synced
+func EnsureBlobTable(db *sql.DB, cfg config.Config) error {
+	if len(cfg.BlobColumns) == 0 {
+		return nil
+	}
+
+	ddl := blobDDL
+	if cfg.DevMode {
+		ddl = blobDDLDev
+	}
+	if _, err := db.Exec(ddl); err != nil {
+		return fmt.Errorf("error creating TB_SYNC_BLOBS: %w", err)
+	}
+
+	log := logger.GetLogger()
+	log.Debug().Msg("TB_SYNC_BLOBS table ready")
+	return nil
+}
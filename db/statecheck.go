@@ -0,0 +1,153 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/state"
+)
+
+// readable reports whether the columns of table can be read, i.e. whether
+// the table exists with them
+func readable(ctx context.Context, db *sql.DB, table, columns string) bool {
+	rows, err := db.QueryContext(ctx, "SELECT "+columns+" FROM "+table+" WHERE 1 = 0")
+	if err != nil {
+		return false
+	}
+	_ = rows.Close()
+	return true
+}
+
+// CheckStateTables verifies the MySQL tables holding run state: the
+// TB_SYNC_INSTANCIAS registry, its schema and the runs a crashed process
+// left active, and the TB_SYNC_BLOBS digests, which must be well formed
+// and belong to existing products. With repair set the issues are fixed.
+func CheckStateTables(ctx context.Context, db *sql.DB, cfg config.Config, repair bool) ([]state.Issue, error) {
+	var issues []state.Issue
+
+	if readable(ctx, db, "TB_SYNC_INSTANCIAS", "MACHINE_ID") {
+		if !readable(ctx, db, "TB_SYNC_INSTANCIAS", "ID_TRANSACAO_FIREBIRD") {
+			issues = append(issues, state.Issue{Store: "TB_SYNC_INSTANCIAS", Item: "ID_TRANSACAO_FIREBIRD", Problem: "column missing, the table predates it", Fix: "add the column"})
+			if repair {
+				if err := addTransactionColumn(ctx, db); err != nil {
+					return issues, fmt.Errorf("error adding ID_TRANSACAO_FIREBIRD to TB_SYNC_INSTANCIAS: %w", err)
+				}
+			}
+		}
+
+		stale, err := staleInstances(ctx, db)
+		if err != nil {
+			return issues, fmt.Errorf("error reading TB_SYNC_INSTANCIAS: %w", err)
+		}
+		issues = append(issues, stale...)
+		if repair && len(stale) > 0 {
+			now := time.Now().UTC()
+			if _, err := db.ExecContext(ctx, `UPDATE TB_SYNC_INSTANCIAS SET DT_FIM = COALESCE(DT_HEARTBEAT, ?)
+				WHERE DT_FIM IS NULL AND (DT_HEARTBEAT IS NULL OR DT_HEARTBEAT < ?)`, now, now.Add(-2*HeartbeatInterval)); err != nil {
+				return issues, fmt.Errorf("error finishing stale runs: %w", err)
+			}
+		}
+	}
+
+	if readable(ctx, db, "TB_SYNC_BLOBS", "ID_ESTOQUE") {
+		checks := []struct {
+			where, problem string
+		}{
+			{"HASH IS NULL OR LENGTH(HASH) <> 64 OR TAMANHO < 0", "malformed digests"},
+			{"ID_ESTOQUE NOT IN (SELECT " + cfg.ProductColumn(config.ProductKey) + " FROM TB_ESTOQUE WHERE " + cfg.ProductColumn(config.ProductKey) + " IS NOT NULL)", "digests of products no longer in TB_ESTOQUE"},
+		}
+		for _, c := range checks {
+			var n int
+			if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM TB_SYNC_BLOBS WHERE "+c.where).Scan(&n); err != nil {
+				return issues, fmt.Errorf("error checking TB_SYNC_BLOBS: %w", err)
+			}
+			if n == 0 {
+				continue
+			}
+			issues = append(issues, state.Issue{Store: "TB_SYNC_BLOBS", Item: "digests", Problem: fmt.Sprintf("%d %s", n, c.problem), Fix: "delete them; the BLOBs are written again"})
+			if repair {
+				if _, err := db.ExecContext(ctx, "DELETE FROM TB_SYNC_BLOBS WHERE "+c.where); err != nil {
+					return issues, fmt.Errorf("error cleaning TB_SYNC_BLOBS: %w", err)
+				}
+			}
+		}
+	}
+	return issues, nil
+}
+
+// staleInstances returns the runs registered as active whose heartbeat
+// stopped, left behind by a process that crashed or was killed
+func staleInstances(ctx context.Context, db *sql.DB) ([]state.Issue, error) {
+	rows, err := db.QueryContext(ctx, `SELECT MACHINE_ID, RUN_ID, DT_HEARTBEAT FROM TB_SYNC_INSTANCIAS
+		WHERE DT_FIM IS NULL AND (DT_HEARTBEAT IS NULL OR DT_HEARTBEAT < ?) ORDER BY MACHINE_ID`,
+		time.Now().UTC().Add(-2*HeartbeatInterval))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var issues []state.Issue
+	for rows.Next() {
+		var machineID string
+		var runID sql.NullString
+		var heartbeat sql.NullTime
+		if err := rows.Scan(&machineID, &runID, &heartbeat); err != nil {
+			return nil, err
+		}
+		problem := "run " + runID.String + " still marked active without a heartbeat"
+		if heartbeat.Valid {
+			problem += " since " + heartbeat.Time.Format(time.RFC3339)
+		}
+		issues = append(issues, state.Issue{Store: "TB_SYNC_INSTANCIAS", Item: machineID, Problem: problem, Fix: "mark it finished at its last heartbeat"})
+	}
+	return issues, rows.Err()
+}
+
+// ActiveRun reports whether a run of the machine is still heartbeating in
+// TB_SYNC_INSTANCIAS, and its run ID
+func ActiveRun(ctx context.Context, db *sql.DB, machineID string) (runID string, active bool, err error) {
+	if !readable(ctx, db, "TB_SYNC_INSTANCIAS", "MACHINE_ID") {
+		return "", false, nil
+	}
+	var id sql.NullString
+	err = db.QueryRowContext(ctx, `SELECT RUN_ID FROM TB_SYNC_INSTANCIAS
+		WHERE MACHINE_ID = ? AND DT_FIM IS NULL AND DT_HEARTBEAT >= ?`,
+		machineID, time.Now().UTC().Add(-2*HeartbeatInterval)).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return id.String, true, nil
+}
+
+// ResetInstance marks every run of the machine in TB_SYNC_INSTANCIAS
+// finished and returns the number of rows changed
+func ResetInstance(ctx context.Context, db *sql.DB, machineID string) (int64, error) {
+	if !readable(ctx, db, "TB_SYNC_INSTANCIAS", "MACHINE_ID") {
+		return 0, nil
+	}
+	now := time.Now().UTC()
+	res, err := db.ExecContext(ctx, "UPDATE TB_SYNC_INSTANCIAS SET DT_FIM = ? WHERE MACHINE_ID = ? AND DT_FIM IS NULL", now, machineID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// ResetBlobDigests empties TB_SYNC_BLOBS, so that the next run writes every
+// BLOB again, and returns the number of digests removed
+func ResetBlobDigests(ctx context.Context, db *sql.DB) (int64, error) {
+	if !readable(ctx, db, "TB_SYNC_BLOBS", "ID_ESTOQUE") {
+		return 0, nil
+	}
+	res, err := db.ExecContext(ctx, "DELETE FROM TB_SYNC_BLOBS")
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
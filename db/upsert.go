@@ -0,0 +1,72 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/logger"
+)
+
+// DefaultMaxAllowedPacket is assumed when max_allowed_packet cannot be read and in DEV_MODE
+const DefaultMaxAllowedPacket = 4 * 1024 * 1024
+
+// MaxAllowedPacket returns the MySQL max_allowed_packet in bytes
+func MaxAllowedPacket(db *sql.DB, cfg config.Config) int {
+	if cfg.DevMode {
+		return DefaultMaxAllowedPacket
+	}
+
+	var name string
+	var size int
+	if err := db.QueryRow("SHOW VARIABLES LIKE 'max_allowed_packet'").Scan(&name, &size); err != nil || size <= 0 {
+		log := logger.GetLogger()
+		log.Warn().Err(err).Msg("Could not read max_allowed_packet, assuming 4MB")
+		return DefaultMaxAllowedPacket
+	}
+	return size
+}
+
+// HasUniqueKey reports whether column alone is the primary key or a unique
+// index of table, which INSERT ... ON DUPLICATE KEY UPDATE relies on
+func HasUniqueKey(db *sql.DB, cfg config.Config, table, column string) (bool, error) {
+	var n int
+	var err error
+	if cfg.DevMode {
+		err = db.QueryRow(`
+			SELECT (SELECT COUNT(*) FROM pragma_table_info(?1) WHERE name = ?2 AND pk = 1
+			        AND (SELECT COUNT(*) FROM pragma_table_info(?1) WHERE pk > 0) = 1)
+			     + (SELECT COUNT(*) FROM pragma_index_list(?1) il
+			        WHERE il."unique" = 1
+			          AND (SELECT COUNT(*) FROM pragma_index_info(il.name)) = 1
+			          AND (SELECT name FROM pragma_index_info(il.name)) = ?2)`,
+			table, column).Scan(&n)
+	} else {
+		err = db.QueryRow(`
+			SELECT COUNT(*) FROM (
+				SELECT INDEX_NAME FROM information_schema.STATISTICS
+				WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND NON_UNIQUE = 0
+				GROUP BY INDEX_NAME
+				HAVING COUNT(*) = 1 AND MAX(COLUMN_NAME) = ?
+			) u`, table, column).Scan(&n)
+	}
+	return n > 0, err
+}
+
+// UpsertClause returns the clause turning a multi-row INSERT into an upsert on
+// key, overwriting columns: ON DUPLICATE KEY UPDATE on MySQL and ON CONFLICT
+// on the DEV_MODE SQLite mock
+func UpsertClause(cfg config.Config, key string, columns []string) string {
+	set := make([]string, len(columns))
+	for i, c := range columns {
+		if cfg.DevMode {
+			set[i] = c + " = excluded." + c
+		} else {
+			set[i] = c + " = VALUES(" + c + ")"
+		}
+	}
+	if cfg.DevMode {
+		return " ON CONFLICT(" + key + ") DO UPDATE SET " + strings.Join(set, ", ")
+	}
+	return " ON DUPLICATE KEY UPDATE " + strings.Join(set, ", ")
+}
@@ -12,15 +12,67 @@
 DROP TABLE IF EXISTS TB_EST_INDEXADOR;
 DROP TABLE IF EXISTS TB_EST_PRODUTO;
 DROP TABLE IF EXISTS TB_ESTOQUE;
+DROP TABLE IF EXISTS TB_GRUPO;
+DROP TABLE IF EXISTS TB_CLIENTE;
+DROP TABLE IF EXISTS TB_PEDIDO_ITEM;
+DROP TABLE IF EXISTS TB_PEDIDO;
+DROP TABLE IF EXISTS TB_EST_MOVIMENTO;
 
 -- Create tables
 CREATE TABLE TB_ESTOQUE (
     ID_ESTOQUE INTEGER PRIMARY KEY,
     DESCRICAO TEXT NOT NULL,
     PRC_CUSTO REAL,
+    STATUS TEXT DEFAULT 'A',
+    ID_GRUPO INTEGER,
+    DT_ALTERACAO DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE TB_GRUPO (
+    ID_GRUPO INTEGER PRIMARY KEY,
+    DESCRICAO TEXT NOT NULL
+);
+
+CREATE TABLE TB_CLIENTE (
+    ID_CLIENTE INTEGER PRIMARY KEY,
+    NOME TEXT NOT NULL,
+    CPF_CNPJ TEXT,
+    EMAIL TEXT,
+    TELEFONE TEXT,
     STATUS TEXT DEFAULT 'A'
 );
 
+CREATE TABLE TB_PEDIDO (
+    ID_PEDIDO INTEGER PRIMARY KEY AUTOINCREMENT,
+    CHAVE_WEB TEXT UNIQUE,
+    STATUS TEXT NOT NULL,
+    ID_CLIENTE INTEGER,
+    DT_PEDIDO DATETIME,
+    VALOR_TOTAL REAL,
+    OBSERVACAO TEXT
+);
+
+CREATE TABLE TB_PEDIDO_ITEM (
+    ID_PEDIDO INTEGER NOT NULL,
+    ITEM INTEGER NOT NULL,
+    ID_ESTOQUE INTEGER NOT NULL,
+    QUANTIDADE REAL NOT NULL,
+    PRC_UNITARIO REAL NOT NULL,
+    PRIMARY KEY (ID_PEDIDO, ITEM),
+    FOREIGN KEY (ID_PEDIDO) REFERENCES TB_PEDIDO(ID_PEDIDO),
+    FOREIGN KEY (ID_ESTOQUE) REFERENCES TB_ESTOQUE(ID_ESTOQUE)
+);
+
+CREATE TABLE TB_EST_MOVIMENTO (
+    ID_MOVIMENTO INTEGER PRIMARY KEY,
+    ID_ESTOQUE INTEGER NOT NULL,
+    TIPO TEXT NOT NULL,
+    QUANTIDADE REAL NOT NULL,
+    DT_MOVIMENTO DATETIME NOT NULL,
+    DOCUMENTO TEXT,
+    FOREIGN KEY (ID_ESTOQUE) REFERENCES TB_ESTOQUE(ID_ESTOQUE)
+);
+
 CREATE TABLE TB_EST_PRODUTO (
     ID_IDENTIFICADOR INTEGER PRIMARY KEY,
     QTD_ATUAL REAL DEFAULT 0,
@@ -310,3 +362,43 @@ INSERT INTO TB_EST_INDEXADOR (ID_ESTOQUE, VALOR) VALUES
 --   17970-17999: Special Test Products (5 products)
 --   9000-9099: Inactive/Discontinued (5 products)
 -- ============================================================================
+
+-- ============================================================================
+-- PRODUCT GROUPS
+-- ============================================================================
+-- Each sample section is its own group: 1 = electronics, 2 = smartphones, ...
+-- 17 = special test products
+UPDATE TB_ESTOQUE SET ID_GRUPO = ID_ESTOQUE / 1000;
+
+INSERT INTO TB_GRUPO (ID_GRUPO, DESCRICAO) VALUES
+    (1, 'Eletrônicos e Informática'),
+    (2, 'Smartphones e Acessórios'),
+    (3, 'Eletrodomésticos'),
+    (4, 'Móveis e Decoração'),
+    (5, 'Esporte e Fitness'),
+    (6, 'Moda e Vestuário'),
+    (7, 'Brinquedos e Jogos'),
+    (9, 'Descontinuados'),
+    (17, 'Produtos de Teste');
+
+-- ============================================================================
+-- CUSTOMERS
+-- ============================================================================
+INSERT INTO TB_CLIENTE (ID_CLIENTE, NOME, CPF_CNPJ, EMAIL, TELEFONE, STATUS) VALUES
+    (1, 'Maria da Silva', '123.456.789-09', 'maria.silva@example.com', '(11) 98765-4321', 'A'),
+    (2, 'João Pereira', '987.654.321-00', 'Joao.Pereira@Example.com', '(21) 99876-5432', 'A'),
+    (3, 'Comércio Souza Ltda', '12.345.678/0001-95', 'compras@souza.example.com', '(31) 3456-7890', 'A'),
+    (4, 'Ana Costa', '111.444.777-35', NULL, '(41) 91234-5678', 'I'),
+    (5, 'Distribuidora Norte S.A.', '98.765.432/0001-10', 'financeiro@norte.example.com', NULL, 'B');
+
+-- ============================================================================
+-- STOCK MOVEMENTS
+-- ============================================================================
+-- E = entry, S = exit
+INSERT INTO TB_EST_MOVIMENTO (ID_MOVIMENTO, ID_ESTOQUE, TIPO, QUANTIDADE, DT_MOVIMENTO, DOCUMENTO) VALUES
+    (1, 1001, 'E', 20, '2026-10-01 08:00:00', 'NF 1520'),
+    (2, 1002, 'E', 15, '2026-10-01 08:05:00', 'NF 1520'),
+    (3, 1001, 'S', 2, '2026-10-03 11:20:00', 'PEDIDO 88'),
+    (4, 2001, 'E', 40, '2026-10-07 09:00:00', 'NF 1604'),
+    (5, 2001, 'S', 5, '2026-10-10 15:45:00', 'PEDIDO 93'),
+    (7, 1002, 'S', 1, '2026-10-14 10:30:00', 'AJUSTE INVENTARIO');
@@ -11,8 +11,10 @@
 --   rm dev_mysql.db && sqlite3 dev_mysql.db < dev_mysql_data.sql
 -- ============================================================================
 
--- Drop existing table if it exists
+-- Drop existing tables if they exist
 DROP TABLE IF EXISTS TB_ESTOQUE;
+DROP TABLE IF EXISTS TB_PEDIDO_WEB_ITEM;
+DROP TABLE IF EXISTS TB_PEDIDO_WEB;
 
 -- Create MySQL target table structure
 CREATE TABLE TB_ESTOQUE (
@@ -24,7 +26,11 @@ CREATE TABLE TB_ESTOQUE (
     PRC_VENDA REAL DEFAULT 0,
     PRC_3X REAL DEFAULT 0,
     PRC_6X REAL DEFAULT 0,
-    PRC_10X REAL DEFAULT 0
+    PRC_10X REAL DEFAULT 0,
+    VISIVEL INTEGER DEFAULT 1,
+    STATUS TEXT,
+    ATIVO INTEGER DEFAULT 1,
+    DELETED_AT DATETIME
 );
 
 -- ============================================================================
@@ -80,3 +86,37 @@ INSERT INTO TB_ESTOQUE (ID_ESTOQUE, DESCRICAO, QTD_ATUAL, PRC_CUSTO, PRC_DOLAR,
 --   Run sync twice -> Second run should ignore all records
 -- 
 -- ============================================================================
+
+-- ============================================================================
+-- Web orders placed on the e-commerce side, exported into Firebird with
+--   ORDER_EXPORT_QUERY=SELECT ID_PEDIDO_WEB, ID_CLIENTE, DT_PEDIDO, VALOR_TOTAL, OBSERVACAO FROM TB_PEDIDO_WEB WHERE PAGO = 1
+--   ORDER_ITEMS_QUERY=SELECT ID_ESTOQUE, QUANTIDADE, PRC_UNITARIO FROM TB_PEDIDO_WEB_ITEM WHERE ID_PEDIDO_WEB = ?
+-- Order 503 is not paid yet and 504 has no items yet
+-- ============================================================================
+CREATE TABLE TB_PEDIDO_WEB (
+    ID_PEDIDO_WEB INTEGER PRIMARY KEY,
+    ID_CLIENTE INTEGER,
+    DT_PEDIDO DATETIME NOT NULL,
+    VALOR_TOTAL REAL NOT NULL,
+    OBSERVACAO TEXT,
+    PAGO INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE TB_PEDIDO_WEB_ITEM (
+    ID_PEDIDO_WEB INTEGER NOT NULL,
+    ID_ESTOQUE INTEGER NOT NULL,
+    QUANTIDADE REAL NOT NULL,
+    PRC_UNITARIO REAL NOT NULL
+);
+
+INSERT INTO TB_PEDIDO_WEB (ID_PEDIDO_WEB, ID_CLIENTE, DT_PEDIDO, VALOR_TOTAL, OBSERVACAO, PAGO) VALUES
+    (501, 1, '2026-10-15 10:12:00', 4585.70, NULL, 1),
+    (502, 2, '2026-10-15 14:40:00', 689.90, 'Entregar após as 18h', 1),
+    (503, 3, '2026-10-16 08:05:00', 1245.00, NULL, 0),
+    (504, 1, '2026-10-16 09:30:00', 425.50, NULL, 1);
+
+INSERT INTO TB_PEDIDO_WEB_ITEM (ID_PEDIDO_WEB, ID_ESTOQUE, QUANTIDADE, PRC_UNITARIO) VALUES
+    (501, 1001, 1, 3990.00),
+    (501, 1002, 1, 595.70),
+    (502, 1003, 1, 689.90),
+    (503, 1004, 1, 1245.00);
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/db"
+	"github.com/waldirborbajr/sync/output"
+)
+
+// Statuses of a doctor check
+const (
+	checkOK      = "ok"
+	checkWarning = "warning" // Runs work, but not as well as they could
+	checkFailed  = "failed"
+	checkSkipped = "skipped"
+)
+
+// recommendedPacket is the max_allowed_packet under which doctor warns:
+// statements are sized to it, so a small one means many round trips
+const recommendedPacket = 16 << 20
+
+// doctorCheck is a check of "sync doctor" with what to do when it fails
+type doctorCheck struct {
+	Check  string `json:"check" yaml:"check"`
+	Status string `json:"status" yaml:"status"`
+	Detail string `json:"detail,omitempty" yaml:"detail,omitempty"`
+	Tip    string `json:"tip,omitempty" yaml:"tip,omitempty"`
+}
+
+// doctor collects the checks and the exit status of the first failure
+type doctor struct {
+	checks []doctorCheck
+	code   int
+}
+
+// add records a check; a failure sets the exit status unless one did before
+func (d *doctor) add(c doctorCheck, failure int) {
+	if c.Status == checkFailed && d.code == 0 {
+		d.code = failure
+	}
+	d.checks = append(d.checks, c)
+}
+
+// doctorCommand checks everything a run needs, the databases, their tables,
+// procedures and privileges, and says how to fix what is missing
+func doctorCommand(env *commandEnv) int {
+	if len(env.args) > 0 {
+		fmt.Fprintf(os.Stderr, "%sError:%s unexpected argument %q\nusage: sync doctor\n", redBold, reset, env.args[0])
+		return 2
+	}
+
+	d := &doctor{}
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		d.add(doctorCheck{Check: "configuration", Status: checkFailed, Detail: err.Error(), Tip: configTip(err)}, exitConfig)
+		return d.render(env)
+	}
+	d.add(doctorCheck{Check: "configuration", Status: checkOK}, exitConfig)
+
+	ctx := context.Background()
+	if firebirdConn, err := db.ConnectFirebird(cfg); err != nil {
+		d.add(doctorCheck{Check: "Firebird connection", Status: checkFailed, Detail: err.Error(),
+			Tip: "check the Firebird service runs and is reachable on " + db.FirebirdAddress(cfg) + " (isql with the same host and FIREBIRD_PATH), and FIREBIRD_USER / FIREBIRD_PASSWORD"}, exitFirebird)
+	} else {
+		d.add(doctorCheck{Check: "Firebird connection", Status: checkOK}, exitFirebird)
+		d.firebirdTables(ctx, firebirdConn)
+		_ = firebirdConn.Close()
+	}
+
+	mysqlConn, err := db.ConnectMySQL(cfg)
+	if err != nil {
+		d.add(doctorCheck{Check: "MySQL connection", Status: checkFailed, Detail: err.Error(),
+			Tip: "check MySQL runs on " + db.MySQLAddress(cfg) + ", accepts connections from this host (bind-address, firewall) and MYSQL_USER / MYSQL_PASSWORD / MYSQL_DATABASE"}, exitMySQL)
+		return d.render(env)
+	}
+	defer func() { _ = mysqlConn.Close() }()
+	d.add(doctorCheck{Check: "MySQL connection", Status: checkOK}, exitMySQL)
+
+	d.mysqlColumns(ctx, mysqlConn, cfg)
+	d.privileges(mysqlConn, cfg)
+	d.procedures(ctx, mysqlConn, cfg)
+	d.packet(mysqlConn, cfg)
+	return d.render(env)
+}
+
+// firebirdTables checks the Firebird product table can be read
+func (d *doctor) firebirdTables(ctx context.Context, conn *sql.DB) {
+	c := doctorCheck{Check: "Firebird TB_ESTOQUE", Status: checkOK}
+	if err := queryNothing(ctx, conn, "SELECT ID_ESTOQUE FROM TB_ESTOQUE WHERE 1 = 0"); err != nil {
+		c.Status, c.Detail = checkFailed, err.Error()
+		c.Tip = "FIREBIRD_PATH may point at another database, or FIREBIRD_USER lacks SELECT on TB_ESTOQUE (GRANT SELECT ON TB_ESTOQUE TO <user>)"
+	}
+	d.add(c, 1)
+}
+
+// mysqlColumns checks TB_ESTOQUE has every column the sync writes
+func (d *doctor) mysqlColumns(ctx context.Context, conn *sql.DB, cfg config.Config) {
+	c := doctorCheck{Check: "MySQL TB_ESTOQUE columns", Status: checkOK}
+	defer func() { d.add(c, 1) }()
+
+	rows, err := conn.QueryContext(ctx, "SELECT * FROM TB_ESTOQUE WHERE 1 = 0")
+	if err != nil {
+		c.Status, c.Detail = checkFailed, err.Error()
+		c.Tip = "create TB_ESTOQUE in MYSQL_DATABASE, or check the database is the webshop's"
+		return
+	}
+	columns, err := rows.Columns()
+	_ = rows.Close()
+	if err != nil {
+		c.Status, c.Detail = checkFailed, err.Error()
+		return
+	}
+
+	var missing []string
+	for _, want := range append([]string{cfg.ProductColumn(config.ProductKey)}, cfg.ProductColumnNames()...) {
+		if !slices.ContainsFunc(columns, func(have string) bool { return strings.EqualFold(have, want) }) {
+			missing = append(missing, want)
+		}
+	}
+	if len(missing) > 0 {
+		c.Status, c.Detail = checkFailed, "missing "+strings.Join(missing, ", ")
+		c.Tip = "add the columns to TB_ESTOQUE, or map the fields to existing ones with PRODUCT_COLUMN_MAP (FIELD:COLUMN, or FIELD:- to leave one out)"
+	}
+}
+
+// privileges checks the MySQL user may read and write the tables it syncs
+func (d *doctor) privileges(conn *sql.DB, cfg config.Config) {
+	for _, p := range tablePrivileges(conn, cfg) {
+		table := p.Table
+		if p.Schema != "" {
+			table = p.Schema + "." + p.Table
+		}
+		c := doctorCheck{Check: "privileges on " + table, Status: checkOK}
+		if len(p.Missing) > 0 {
+			c.Status, c.Detail = checkFailed, p.String()
+			c.Tip = fmt.Sprintf("GRANT %s ON %s TO '%s'@'<host>'", strings.Join(p.Missing, ", "), table, cfg.MySQLUser)
+		}
+		d.add(c, 1)
+	}
+}
+
+// procedures checks the stored procedures runs call exist
+func (d *doctor) procedures(ctx context.Context, conn *sql.DB, cfg config.Config) {
+	if cfg.DevMode {
+		d.add(doctorCheck{Check: "stored procedures", Status: checkSkipped, Detail: "DEV_MODE: SQLite has none, runs skip them"}, 1)
+		return
+	}
+	if !cfg.Syncs(config.PartProducts) {
+		d.add(doctorCheck{Check: "stored procedures", Status: checkSkipped, Detail: "products are outside SYNC_ONLY, no procedure is called"}, 1)
+		return
+	}
+
+	quantity := "UpdateQtdVirtual"
+	if cfg.ChangedIDsProcedure != "" {
+		quantity = cfg.ChangedIDsProcedure
+	}
+	for _, name := range []string{quantity, "SP_ATUALIZAR_PART_NUMBER"} {
+		c := doctorCheck{Check: "procedure " + name, Status: checkOK}
+		var n int
+		err := conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM information_schema.ROUTINES
+			WHERE ROUTINE_SCHEMA = DATABASE() AND ROUTINE_TYPE = 'PROCEDURE' AND ROUTINE_NAME = ?`, name).Scan(&n)
+		switch {
+		case err != nil:
+			c.Status, c.Detail = checkFailed, err.Error()
+		case n == 0:
+			c.Status, c.Detail = checkFailed, "not found in "+cfg.MySQLDatabase
+			c.Tip = "create the procedure from the webshop's schema scripts; runs call it after writing the products"
+			if name == quantity && cfg.ChangedIDsProcedure != "" {
+				c.Tip = "create it, or unset CHANGED_IDS_PROCEDURE to call UpdateQtdVirtual"
+			}
+		}
+		d.add(c, 1)
+	}
+}
+
+// packet checks max_allowed_packet leaves room for large statements
+func (d *doctor) packet(conn *sql.DB, cfg config.Config) {
+	if cfg.DevMode {
+		d.add(doctorCheck{Check: "max_allowed_packet", Status: checkSkipped, Detail: "DEV_MODE assumes 4 MB"}, 1)
+		return
+	}
+	size := db.MaxAllowedPacket(conn, cfg)
+	c := doctorCheck{Check: "max_allowed_packet", Status: checkOK, Detail: fmt.Sprintf("%d MB", size>>20)}
+	if size < recommendedPacket {
+		c.Status = checkWarning
+		c.Tip = fmt.Sprintf("batches are split into statements under it; SET PERSIST max_allowed_packet = %d for fewer round trips", 64<<20)
+	}
+	d.add(c, 1)
+}
+
+// queryNothing runs a query returning no rows, only to see it is accepted
+func queryNothing(ctx context.Context, conn *sql.DB, query string) error {
+	rows, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// configTip suggests how to fix a configuration error
+func configTip(err error) string {
+	switch msg := err.Error(); {
+	case strings.Contains(msg, "missing required MySQL"):
+		return "set MYSQL_USER, MYSQL_PASSWORD, MYSQL_HOST, MYSQL_PORT and MYSQL_DATABASE in .env or the environment"
+	case strings.Contains(msg, "missing required Firebird"):
+		return "set FIREBIRD_USER, FIREBIRD_PASSWORD, FIREBIRD_HOST and FIREBIRD_PATH in .env or the environment"
+	case strings.Contains(msg, ".env"):
+		return "run sync from the directory holding .env, or create one with 'sync init'"
+	}
+	return "fix the setting named in the error; 'sync config validate' lists the effective values"
+}
+
+// render prints the checks and returns the exit status
+func (d *doctor) render(env *commandEnv) int {
+	if rc := env.render(d.checks); rc != 0 {
+		return rc
+	}
+	if env.output == output.FormatTable {
+		switch {
+		case d.code != 0:
+			fmt.Printf("\n%sProblems found, see the tips above%s\n", redBold, reset)
+		case slices.ContainsFunc(d.checks, func(c doctorCheck) bool { return c.Status == checkWarning }):
+			fmt.Printf("\n%sReady to sync, with warnings%s\n", yellowBold, reset)
+		default:
+			fmt.Printf("\n%sReady to sync%s\n", greenBold, reset)
+		}
+	}
+	return d.code
+}
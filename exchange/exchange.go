@@ -0,0 +1,129 @@
+// Package exchange fetches the USD/BRL exchange rate used to derive PRC_DOLAR
+// for products without a TB_EST_INDEXADOR value.
+package exchange
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Supported rate providers
+const (
+	ProviderBCB          = "bcb"          // Banco Central do Brasil PTAX (Olinda API), latest selling rate
+	ProviderExchangeRate = "exchangerate" // exchangerate.host and compatible APIs ({"rates":{"BRL":...}} or {"quotes":{"USDBRL":...}})
+)
+
+// Providers lists the supported providers
+var Providers = []string{ProviderBCB, ProviderExchangeRate}
+
+// DefaultExchangeRateURL is queried by ProviderExchangeRate when no URL is configured
+const DefaultExchangeRateURL = "https://api.exchangerate.host/latest?base=USD&symbols=BRL"
+
+// bcbLookback is how far back PTAX quotes are requested, so weekends and
+// holidays still return the last business day's rate
+const bcbLookback = 7 * 24 * time.Hour
+
+// Fetch returns the current USD/BRL rate from provider, querying url or the
+// provider's default endpoint when url is empty
+func Fetch(ctx context.Context, provider, url string) (float64, error) {
+	if url == "" {
+		switch provider {
+		case ProviderBCB:
+			url = bcbURL(time.Now())
+		default:
+			url = DefaultExchangeRateURL
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error creating exchange rate request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("error fetching exchange rate: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected status code %d fetching exchange rate", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return 0, fmt.Errorf("error reading exchange rate: %w", err)
+	}
+
+	var rate float64
+	if provider == ProviderBCB {
+		rate, err = parseBCB(body)
+	} else {
+		rate, err = parseRates(body)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if rate <= 0 {
+		return 0, fmt.Errorf("exchange rate %v is not positive", rate)
+	}
+	return rate, nil
+}
+
+// bcbURL returns the PTAX query for the quotes of the week up to now
+func bcbURL(now time.Time) string {
+	const layout = "01-02-2006"
+	return "https://olinda.bcb.gov.br/olinda/servico/PTAX/versao/v1/odata/" +
+		"CotacaoDolarPeriodo(dataInicial=@dataInicial,dataFinalCotacao=@dataFinalCotacao)" +
+		"?@dataInicial='" + now.Add(-bcbLookback).Format(layout) + "'" +
+		"&@dataFinalCotacao='" + now.Format(layout) + "'" +
+		"&$orderby=dataHoraCotacao%20desc&$top=1&$format=json"
+}
+
+// parseBCB returns the selling rate of the latest PTAX quote in body
+func parseBCB(body []byte) (float64, error) {
+	var resp struct {
+		Value []struct {
+			Venda float64 `json:"cotacaoVenda"`
+			Hora  string  `json:"dataHoraCotacao"`
+		} `json:"value"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return 0, fmt.Errorf("error decoding PTAX response: %w", err)
+	}
+	if len(resp.Value) == 0 {
+		return 0, fmt.Errorf("PTAX response holds no quote")
+	}
+	latest := resp.Value[0]
+	for _, q := range resp.Value[1:] {
+		if q.Hora > latest.Hora {
+			latest = q
+		}
+	}
+	return latest.Venda, nil
+}
+
+// parseRates returns the BRL rate of an exchangerate.host style response
+func parseRates(body []byte) (float64, error) {
+	var resp struct {
+		Rates  map[string]float64 `json:"rates"`
+		Quotes map[string]float64 `json:"quotes"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return 0, fmt.Errorf("error decoding exchange rate response: %w", err)
+	}
+	if rate, ok := resp.Rates["BRL"]; ok {
+		return rate, nil
+	}
+	if rate, ok := resp.Quotes["USDBRL"]; ok {
+		return rate, nil
+	}
+	return 0, fmt.Errorf("exchange rate response holds no BRL rate")
+}
@@ -0,0 +1,43 @@
+package exchange
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		provider string
+		body     string
+		want     float64
+		ok       bool
+	}{
+		{ProviderBCB, `{"value":[{"cotacaoCompra":5.0,"cotacaoVenda":5.01,"dataHoraCotacao":"2024-01-04 13:02:21.1"},{"cotacaoCompra":4.9,"cotacaoVenda":4.91,"dataHoraCotacao":"2024-01-05 13:04:26.7"}]}`, 4.91, true},
+		{ProviderBCB, `{"value":[]}`, 0, false},
+		{ProviderExchangeRate, `{"success":true,"base":"USD","rates":{"BRL":4.8734}}`, 4.8734, true},
+		{ProviderExchangeRate, `{"success":true,"source":"USD","quotes":{"USDBRL":5.12}}`, 5.12, true},
+		{ProviderExchangeRate, `{"rates":{"EUR":0.9}}`, 0, false},
+		{ProviderExchangeRate, `not json`, 0, false},
+	}
+
+	for _, tt := range tests {
+		parse := parseRates
+		if tt.provider == ProviderBCB {
+			parse = parseBCB
+		}
+		got, err := parse([]byte(tt.body))
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("parse %s %s = %v, %v; want %v (ok %v)", tt.provider, tt.body, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestBCBURL(t *testing.T) {
+	url := bcbURL(time.Date(2024, 1, 8, 10, 0, 0, 0, time.UTC))
+	for _, want := range []string{"@dataInicial='01-01-2024'", "@dataFinalCotacao='01-08-2024'", "$format=json"} {
+		if !strings.Contains(url, want) {
+			t.Errorf("bcbURL = %s; want it to contain %s", url, want)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/waldirborbajr/sync/processor"
+)
+
+// Exit statuses telling failures apart for wrapper scripts, besides 0 for
+// success, 1 for any other failure and 2 for an invalid command line.
+// Their values are kept across releases.
+const (
+	exitHung        = 3 // The watchdog terminated a hung run
+	exitDrift       = 4 // Rows out of sync: verify past --max-drift, or a run's spot checks failing
+	exitStateIssues = 5 // "sync state verify" found problems it left unrepaired
+	exitConfig      = 6 // The configuration could not be loaded or is invalid
+	exitFirebird    = 7 // Firebird could not be reached
+	exitMySQL       = 8 // MySQL could not be reached
+	exitPartial     = 9 // The run completed but rows were refused or batches failed
+)
+
+// exitStatuses documents the exit statuses in the help and the manual page
+var exitStatuses = []struct {
+	code    int
+	meaning string
+}{
+	{0, "success"},
+	{1, "failure not covered below"},
+	{2, "invalid command line"},
+	{exitHung, "hung run terminated by the watchdog (WATCHDOG_TIMEOUT)"},
+	{exitDrift, "rows out of sync: 'sync verify' over --max-drift, or run spot checks (SPOT_CHECK_*, VERIFY_SAMPLE) failing"},
+	{exitStateIssues, "'sync state verify' found problems left unrepaired"},
+	{exitConfig, "configuration error"},
+	{exitFirebird, "Firebird unreachable"},
+	{exitMySQL, "MySQL unreachable"},
+	{exitPartial, "partial failure: rows refused by MySQL or batches not written"},
+}
+
+var (
+	// errFirebirdUnreachable and errMySQLUnreachable wrap the errors of a
+	// run that could not connect
+	errFirebirdUnreachable = errors.New("firebird unreachable")
+	errMySQLUnreachable    = errors.New("mysql unreachable")
+)
+
+// runExitStatus returns the exit status of a run that ended with err and,
+// when it completed, stats
+func runExitStatus(err error, stats *processor.ProcessingStats) int {
+	switch {
+	case errors.Is(err, errFirebirdUnreachable):
+		return exitFirebird
+	case errors.Is(err, errMySQLUnreachable):
+		return exitMySQL
+	case err != nil:
+		return 1
+	case stats.UnwrittenRows > 0 || stats.BatchRetriesExhausted > 0 || len(stats.RejectedRows) > 0:
+		return exitPartial
+	case stats.SpotChecks != nil && !stats.SpotChecks.OK():
+		return exitDrift
+	}
+	return 0
+}
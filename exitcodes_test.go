@@ -0,0 +1,31 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/waldirborbajr/sync/processor"
+)
+
+func TestRunExitStatus(t *testing.T) {
+	tests := []struct {
+		name  string
+		err   error
+		stats *processor.ProcessingStats
+		want  int
+	}{
+		{"success", nil, &processor.ProcessingStats{}, 0},
+		{"unwritten rows", nil, &processor.ProcessingStats{UnwrittenRows: 500}, exitPartial},
+		{"rejected rows", nil, &processor.ProcessingStats{RejectedRows: []int{7}}, exitPartial},
+		{"retries exhausted", nil, &processor.ProcessingStats{BatchRetriesExhausted: 1}, exitPartial},
+		{"firebird unreachable", fmt.Errorf("%w: refused", errFirebirdUnreachable), nil, exitFirebird},
+		{"mysql unreachable", fmt.Errorf("%w: refused", errMySQLUnreachable), nil, exitMySQL},
+		{"other failure", errors.New("boom"), nil, 1},
+	}
+	for _, tt := range tests {
+		if got := runExitStatus(tt.err, tt.stats); got != tt.want {
+			t.Errorf("%s: runExitStatus() = %d; want %d", tt.name, got, tt.want)
+		}
+	}
+}
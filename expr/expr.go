@@ -0,0 +1,602 @@
+// Package expr evaluates the small expressions used in column mappings and
+// transforms, e.g. PRC_VENDA * 0.9, 'ERP', (PRC_CUSTO + 10) / 2,
+// UPPER(TRIM(DESCRICAO)) or IF(ID_GRUPO = 7 AND PRC_CUSTO > 100, PRC_VENDA * 0.95, PRC_VENDA).
+//
+// Values are numbers (float64), strings or, from comparisons, booleans.
+// Identifiers name variables supplied at evaluation time, or functions when
+// followed by parentheses (see functions); + concatenates when either side
+// is a string. Comparisons (=, !=, <>, <, <=, >, >=) combine with AND, OR
+// and NOT; as in SQL a NULL operand makes them unknown, which IF treats as false.
+package expr
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Expr is a parsed expression
+type Expr struct {
+	src  string
+	root node
+}
+
+// node is an element of the expression tree
+type node interface {
+	eval(vars map[string]interface{}) (interface{}, error)
+}
+
+type (
+	literal  struct{ value interface{} }
+	variable struct{ name string }
+	negate   struct{ x node }
+	binary   struct {
+		op   byte
+		l, r node
+	}
+	comparison struct {
+		op   string
+		l, r node
+	}
+	logical struct {
+		and  bool // AND, otherwise OR
+		l, r node
+	}
+	not    struct{ x node }
+	ifNode struct{ cond, then, els node }
+	call   struct {
+		name string
+		fn   function
+		args []node
+	}
+)
+
+// Parse parses s into an expression
+func Parse(s string) (*Expr, error) {
+	p := &parser{src: s}
+	p.next()
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", s, err)
+	}
+	if p.tok.kind != tokEOF {
+		return nil, fmt.Errorf("invalid expression %q: unexpected %q", s, p.tok.text)
+	}
+	return &Expr{src: s, root: root}, nil
+}
+
+// String returns the source of the expression
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Vars returns the sorted names of the variables the expression references
+func (e *Expr) Vars() []string {
+	seen := make(map[string]bool)
+	var walk func(n node)
+	walk = func(n node) {
+		switch x := n.(type) {
+		case variable:
+			seen[x.name] = true
+		case negate:
+			walk(x.x)
+		case binary:
+			walk(x.l)
+			walk(x.r)
+		case comparison:
+			walk(x.l)
+			walk(x.r)
+		case logical:
+			walk(x.l)
+			walk(x.r)
+		case not:
+			walk(x.x)
+		case ifNode:
+			walk(x.cond)
+			walk(x.then)
+			walk(x.els)
+		case call:
+			for _, a := range x.args {
+				walk(a)
+			}
+		}
+	}
+	walk(e.root)
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Eval evaluates the expression. Variables must hold strings, integers or
+// floats; nil (NULL) operands make the result nil, except for COALESCE and
+// the branch IF does not take.
+func (e *Expr) Eval(vars map[string]interface{}) (interface{}, error) {
+	return e.root.eval(vars)
+}
+
+func (l literal) eval(map[string]interface{}) (interface{}, error) {
+	return l.value, nil
+}
+
+func (v variable) eval(vars map[string]interface{}) (interface{}, error) {
+	value, ok := vars[v.name]
+	if !ok {
+		return nil, fmt.Errorf("unknown variable %s", v.name)
+	}
+	switch x := value.(type) {
+	case nil, string, float64:
+		return x, nil
+	case int:
+		return float64(x), nil
+	case int64:
+		return float64(x), nil
+	case float32:
+		return float64(x), nil
+	}
+	return nil, fmt.Errorf("variable %s has unsupported type %T", v.name, value)
+}
+
+func (n negate) eval(vars map[string]interface{}) (interface{}, error) {
+	x, err := n.x.eval(vars)
+	if err != nil || x == nil {
+		return nil, err
+	}
+	f, ok := x.(float64)
+	if !ok {
+		return nil, fmt.Errorf("cannot negate string %q", x)
+	}
+	return -f, nil
+}
+
+func (b binary) eval(vars map[string]interface{}) (interface{}, error) {
+	l, err := b.l.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	r, err := b.r.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	if l == nil || r == nil {
+		return nil, nil
+	}
+
+	lf, lNum := l.(float64)
+	rf, rNum := r.(float64)
+	if !lNum || !rNum {
+		_, lBool := l.(bool)
+		_, rBool := r.(bool)
+		if b.op == '+' && !lBool && !rBool {
+			return toString(l) + toString(r), nil
+		}
+		return nil, fmt.Errorf("operator %c needs numbers, got %q and %q", b.op, toString(l), toString(r))
+	}
+
+	switch b.op {
+	case '+':
+		return lf + rf, nil
+	case '-':
+		return lf - rf, nil
+	case '*':
+		return lf * rf, nil
+	default:
+		if rf == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return lf / rf, nil
+	}
+}
+
+func (c comparison) eval(vars map[string]interface{}) (interface{}, error) {
+	l, err := c.l.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	r, err := c.r.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	if l == nil || r == nil {
+		return nil, nil
+	}
+
+	var cmp int
+	switch lv := l.(type) {
+	case float64:
+		rv, ok := r.(float64)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare %v with %q", lv, toString(r))
+		}
+		switch {
+		case lv < rv:
+			cmp = -1
+		case lv > rv:
+			cmp = 1
+		}
+	case string:
+		rv, ok := r.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare %q with %v", lv, toString(r))
+		}
+		cmp = strings.Compare(lv, rv)
+	case bool:
+		rv, ok := r.(bool)
+		if !ok || c.op != "=" && c.op != "!=" {
+			return nil, fmt.Errorf("cannot compare %v with %v using %s", lv, toString(r), c.op)
+		}
+		if lv != rv {
+			cmp = 1
+		}
+	}
+
+	switch c.op {
+	case "=":
+		return cmp == 0, nil
+	case "!=":
+		return cmp != 0, nil
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	default:
+		return cmp >= 0, nil
+	}
+}
+
+func (l logical) eval(vars map[string]interface{}) (interface{}, error) {
+	lv, err := evalCondition(l.l, vars)
+	if err != nil {
+		return nil, err
+	}
+	// FALSE AND x and TRUE OR x are decided by the left side alone
+	if lv != nil && lv.(bool) != l.and {
+		return lv, nil
+	}
+	rv, err := evalCondition(l.r, vars)
+	if err != nil {
+		return nil, err
+	}
+	if rv != nil && rv.(bool) != l.and {
+		return rv, nil
+	}
+	if lv == nil || rv == nil {
+		return nil, nil
+	}
+	return l.and, nil
+}
+
+func (n not) eval(vars map[string]interface{}) (interface{}, error) {
+	v, err := evalCondition(n.x, vars)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	return !v.(bool), nil
+}
+
+func (i ifNode) eval(vars map[string]interface{}) (interface{}, error) {
+	cond, err := evalCondition(i.cond, vars)
+	if err != nil {
+		return nil, err
+	}
+	if cond == true {
+		return i.then.eval(vars)
+	}
+	return i.els.eval(vars)
+}
+
+func (c call) eval(vars map[string]interface{}) (interface{}, error) {
+	args := make([]interface{}, len(c.args))
+	for i, a := range c.args {
+		v, err := a.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		if v == nil && !c.fn.nullable {
+			return nil, nil
+		}
+		args[i] = v
+	}
+	v, err := c.fn.call(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", c.name, err)
+	}
+	return v, nil
+}
+
+// evalCondition evaluates n, which must be a boolean or nil (unknown)
+func evalCondition(n node, vars map[string]interface{}) (interface{}, error) {
+	v, err := n.eval(vars)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	if _, ok := v.(bool); !ok {
+		return nil, fmt.Errorf("condition %q is not a comparison", toString(v))
+	}
+	return v, nil
+}
+
+// toString formats a value for string concatenation
+func toString(v interface{}) string {
+	if f, ok := v.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// Token kinds
+const (
+	tokEOF = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokOp
+)
+
+type token struct {
+	kind int
+	text string
+}
+
+// parser is a recursive-descent parser over the tokens of src
+type parser struct {
+	src string
+	pos int
+	tok token
+	err error
+}
+
+// next advances to the following token
+func (p *parser) next() {
+	for p.pos < len(p.src) && p.src[p.pos] == ' ' {
+		p.pos++
+	}
+	if p.pos >= len(p.src) {
+		p.tok = token{kind: tokEOF}
+		return
+	}
+
+	start := p.pos
+	c := p.src[p.pos]
+	switch {
+	case c >= '0' && c <= '9' || c == '.':
+		for p.pos < len(p.src) && (p.src[p.pos] >= '0' && p.src[p.pos] <= '9' || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		p.tok = token{kind: tokNumber, text: p.src[start:p.pos]}
+	case c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z':
+		for p.pos < len(p.src) && isIdentChar(p.src[p.pos]) {
+			p.pos++
+		}
+		p.tok = token{kind: tokIdent, text: p.src[start:p.pos]}
+	case c == '\'':
+		end := strings.IndexByte(p.src[start+1:], '\'')
+		if end < 0 {
+			p.err = fmt.Errorf("unterminated string")
+			p.tok = token{kind: tokEOF}
+			return
+		}
+		p.pos = start + end + 2
+		p.tok = token{kind: tokString, text: p.src[start+1 : p.pos-1]}
+	default:
+		p.pos++
+		if p.pos < len(p.src) {
+			switch two := p.src[start : p.pos+1]; two {
+			case "<=", ">=", "!=", "<>":
+				p.pos++
+				p.tok = token{kind: tokOp, text: two}
+				return
+			}
+		}
+		p.tok = token{kind: tokOp, text: string(c)}
+	}
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9'
+}
+
+// keyword reports whether the current token is the keyword kw (any case)
+func (p *parser) keyword(kw string) bool {
+	return p.tok.kind == tokIdent && strings.EqualFold(p.tok.text, kw)
+}
+
+// parseOr parses and ('OR' and)*
+func (p *parser) parseOr() (node, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("OR") {
+		p.next()
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = logical{and: false, l: l, r: r}
+	}
+	return l, nil
+}
+
+// parseAnd parses not ('AND' not)*
+func (p *parser) parseAnd() (node, error) {
+	l, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("AND") {
+		p.next()
+		r, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l = logical{and: true, l: l, r: r}
+	}
+	return l, nil
+}
+
+// parseNot parses 'NOT' not | comparison
+func (p *parser) parseNot() (node, error) {
+	if p.keyword("NOT") {
+		p.next()
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return not{x}, nil
+	}
+	return p.parseComparison()
+}
+
+// parseComparison parses sum (op sum)?
+func (p *parser) parseComparison() (node, error) {
+	l, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokOp {
+		return l, nil
+	}
+	op := p.tok.text
+	switch op {
+	case "=", "!=", "<>", "<", "<=", ">", ">=":
+	default:
+		return l, nil
+	}
+	if op == "<>" {
+		op = "!="
+	}
+	p.next()
+	r, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	return comparison{op: op, l: l, r: r}, nil
+}
+
+// parseCall parses the parenthesized arguments of the function name
+func (p *parser) parseCall(name string) (node, error) {
+	fn, ok := functions[strings.ToUpper(name)]
+	if !ok && !strings.EqualFold(name, "IF") {
+		return nil, fmt.Errorf("unknown function %s", name)
+	}
+	p.next() // (
+
+	var args []node
+	for !(p.tok.kind == tokOp && p.tok.text == ")") {
+		if len(args) > 0 {
+			if p.tok.kind != tokOp || p.tok.text != "," {
+				return nil, fmt.Errorf("expected , or ) in %s()", name)
+			}
+			p.next()
+		}
+		a, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, a)
+	}
+	p.next() // )
+
+	if strings.EqualFold(name, "IF") {
+		if len(args) != 3 {
+			return nil, fmt.Errorf("IF takes a condition and 2 values, got %d arguments", len(args))
+		}
+		return ifNode{cond: args[0], then: args[1], els: args[2]}, nil
+	}
+	if len(args) < fn.minArgs || fn.maxArgs >= 0 && len(args) > fn.maxArgs {
+		return nil, fmt.Errorf("%s takes %s arguments, got %d", strings.ToUpper(name), fn.arity(), len(args))
+	}
+	return call{name: strings.ToUpper(name), fn: fn, args: args}, nil
+}
+
+// parseSum parses term (('+' | '-') term)*
+func (p *parser) parseSum() (node, error) {
+	l, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokOp && (p.tok.text == "+" || p.tok.text == "-") {
+		op := p.tok.text[0]
+		p.next()
+		r, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		l = binary{op: op, l: l, r: r}
+	}
+	return l, nil
+}
+
+// parseProduct parses factor (('*' | '/') factor)*
+func (p *parser) parseProduct() (node, error) {
+	l, err := p.parseFactor()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokOp && (p.tok.text == "*" || p.tok.text == "/") {
+		op := p.tok.text[0]
+		p.next()
+		r, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		l = binary{op: op, l: l, r: r}
+	}
+	return l, nil
+}
+
+// parseFactor parses a number, string, identifier, negation or parenthesized expression
+func (p *parser) parseFactor() (node, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	tok := p.tok
+	switch tok.kind {
+	case tokNumber:
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", tok.text)
+		}
+		p.next()
+		return literal{f}, nil
+	case tokString:
+		p.next()
+		return literal{tok.text}, nil
+	case tokIdent:
+		p.next()
+		if p.tok.kind == tokOp && p.tok.text == "(" {
+			return p.parseCall(tok.text)
+		}
+		return variable{tok.text}, nil
+	case tokOp:
+		switch tok.text {
+		case "-":
+			p.next()
+			x, err := p.parseFactor()
+			if err != nil {
+				return nil, err
+			}
+			return negate{x}, nil
+		case "(":
+			p.next()
+			x, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if p.tok.kind != tokOp || p.tok.text != ")" {
+				return nil, fmt.Errorf("missing )")
+			}
+			p.next()
+			return x, nil
+		}
+		return nil, fmt.Errorf("unexpected %q", tok.text)
+	}
+	return nil, fmt.Errorf("unexpected end of expression")
+}
@@ -0,0 +1,142 @@
+package expr
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	vars := map[string]interface{}{
+		"PRC_VENDA": 100.0,
+		"QTD_ATUAL": int64(3),
+		"DESCRICAO": "Cabo",
+		"PRC_DOLAR": nil,
+	}
+
+	tests := []struct {
+		input string
+		want  interface{}
+	}{
+		{"42", 42.0},
+		{"'ERP'", "ERP"},
+		{"PRC_VENDA * 0.9", 90.0},
+		{"PRC_VENDA + QTD_ATUAL * 2", 106.0},
+		{"(PRC_VENDA + QTD_ATUAL) * 2", 206.0},
+		{"-QTD_ATUAL + 1", -2.0},
+		{"PRC_VENDA / 4 - 5", 20.0},
+		{"DESCRICAO + ' USB'", "Cabo USB"},
+		{"'#' + QTD_ATUAL", "#3"},
+		{"PRC_DOLAR * 5", nil},
+	}
+
+	for _, tt := range tests {
+		e, err := Parse(tt.input)
+		if err != nil {
+			t.Errorf("Parse(%q) returned error: %v", tt.input, err)
+			continue
+		}
+		got, err := e.Eval(vars)
+		if err != nil {
+			t.Errorf("Eval(%q) returned error: %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Eval(%q) = %v; want %v", tt.input, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", "1 +", "(1", "'open", "1 ) 2", "PRC_VENDA % 2"} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("Parse(%q) expected error", bad)
+		}
+	}
+
+	for _, bad := range []string{"DESCRICAO * 2", "PRC_VENDA / 0", "MISSING + 1"} {
+		e, err := Parse(bad)
+		if err != nil {
+			t.Errorf("Parse(%q) returned error: %v", bad, err)
+			continue
+		}
+		if _, err := e.Eval(vars); err == nil {
+			t.Errorf("Eval(%q) expected error", bad)
+		}
+	}
+}
+
+func TestVars(t *testing.T) {
+	e, err := Parse("(PRC_VENDA + PRC_CUSTO) / 2 + PRC_VENDA")
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if got, want := e.Vars(), []string{"PRC_CUSTO", "PRC_VENDA"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Vars() = %v; want %v", got, want)
+	}
+}
+
+func TestEvalConditionsAndFunctions(t *testing.T) {
+	vars := map[string]interface{}{
+		"PRC_VENDA": 100.0,
+		"ID_GRUPO":  7,
+		"DESCRICAO": "  cabo usb ",
+		"STATUS":    "A",
+		"PRC_DOLAR": nil,
+	}
+
+	tests := []struct {
+		input string
+		want  interface{}
+	}{
+		{"ID_GRUPO = 7", true},
+		{"ID_GRUPO <> 7", false},
+		{"PRC_VENDA >= 100 AND STATUS = 'A'", true},
+		{"ID_GRUPO < 5 OR STATUS != 'A'", false},
+		{"NOT ID_GRUPO > 5", false},
+		{"IF(ID_GRUPO = 7, PRC_VENDA * 0.9, PRC_VENDA)", 90.0},
+		{"IF(PRC_DOLAR > 0, PRC_DOLAR, PRC_VENDA)", 100.0}, // Unknown is false
+		{"IF(ID_GRUPO = 1, PRC_VENDA / 0, 1)", 1.0},        // The branch not taken is not evaluated
+		{"PRC_DOLAR > 0 OR ID_GRUPO = 7", true},
+		{"PRC_DOLAR > 0 AND ID_GRUPO = 7", nil},
+		{"UPPER(TRIM(DESCRICAO))", "CABO USB"},
+		{"trim_prefix(TRIM(DESCRICAO), 'cabo ')", "usb"},
+		{"REPLACE(DESCRICAO, ' ', '')", "cabousb"},
+		{"LEFT(TRIM(DESCRICAO), 4)", "cabo"},
+		{"STARTS_WITH(TRIM(DESCRICAO), 'cabo')", true},
+		{"ROUND(PRC_VENDA / 3, 2)", 33.33},
+		{"MAX(PRC_VENDA * 0.5, 60, 55)", 60.0},
+		{"COALESCE(PRC_DOLAR, PRC_VENDA)", 100.0},
+		{"UPPER(PRC_DOLAR)", nil},
+	}
+
+	for _, tt := range tests {
+		e, err := Parse(tt.input)
+		if err != nil {
+			t.Errorf("Parse(%q) returned error: %v", tt.input, err)
+			continue
+		}
+		got, err := e.Eval(vars)
+		if err != nil {
+			t.Errorf("Eval(%q) returned error: %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Eval(%q) = %v; want %v", tt.input, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"FOO(1)", "IF(1, 2)", "ROUND()", "UPPER(1, 2)", "MAX(1 2)", "LEFT(1,"} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("Parse(%q) expected error", bad)
+		}
+	}
+
+	for _, bad := range []string{"IF(PRC_VENDA, 1, 2)", "STATUS > 1", "ID_GRUPO = 7 AND 1", "(ID_GRUPO = 7) + 1", "ROUND(STATUS)"} {
+		e, err := Parse(bad)
+		if err != nil {
+			t.Errorf("Parse(%q) returned error: %v", bad, err)
+			continue
+		}
+		if _, err := e.Eval(vars); err == nil {
+			t.Errorf("Eval(%q) expected error", bad)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package expr
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// function is a built-in function callable from expressions
+type function struct {
+	minArgs, maxArgs int  // maxArgs -1 for any number
+	nullable         bool // Called with nil arguments instead of returning nil
+	call             func(args []interface{}) (interface{}, error)
+}
+
+// arity describes the number of arguments fn takes
+func (fn function) arity() string {
+	switch {
+	case fn.maxArgs < 0:
+		return fmt.Sprintf("at least %d", fn.minArgs)
+	case fn.minArgs == fn.maxArgs:
+		return strconv.Itoa(fn.minArgs)
+	}
+	return fmt.Sprintf("%d to %d", fn.minArgs, fn.maxArgs)
+}
+
+// functions are the built-in functions by name; IF(cond, then, else) is
+// parsed apart since it only evaluates the branch taken
+var functions = map[string]function{
+	"UPPER":       stringFunc(1, func(s []string) interface{} { return strings.ToUpper(s[0]) }),
+	"LOWER":       stringFunc(1, func(s []string) interface{} { return strings.ToLower(s[0]) }),
+	"TRIM":        stringFunc(1, func(s []string) interface{} { return strings.TrimSpace(s[0]) }),
+	"REPLACE":     stringFunc(3, func(s []string) interface{} { return strings.ReplaceAll(s[0], s[1], s[2]) }),
+	"TRIM_PREFIX": stringFunc(2, func(s []string) interface{} { return strings.TrimPrefix(s[0], s[1]) }),
+	"TRIM_SUFFIX": stringFunc(2, func(s []string) interface{} { return strings.TrimSuffix(s[0], s[1]) }),
+	"CONTAINS":    stringFunc(2, func(s []string) interface{} { return strings.Contains(s[0], s[1]) }),
+	"STARTS_WITH": stringFunc(2, func(s []string) interface{} { return strings.HasPrefix(s[0], s[1]) }),
+	"LEFT": {minArgs: 2, maxArgs: 2, call: func(args []interface{}) (interface{}, error) {
+		n, ok := args[1].(float64)
+		if !ok || n < 0 {
+			return nil, fmt.Errorf("length must be a non-negative number")
+		}
+		r := []rune(toString(args[0]))
+		return string(r[:min(int(n), len(r))]), nil
+	}},
+	"ROUND": {minArgs: 1, maxArgs: 2, call: func(args []interface{}) (interface{}, error) {
+		nums, err := numbers(args)
+		if err != nil {
+			return nil, err
+		}
+		scale := 1.0
+		if len(nums) == 2 {
+			scale = math.Pow(10, math.Trunc(nums[1]))
+		}
+		return math.Round(nums[0]*scale) / scale, nil
+	}},
+	"MIN": {minArgs: 2, maxArgs: -1, call: func(args []interface{}) (interface{}, error) {
+		nums, err := numbers(args)
+		if err != nil {
+			return nil, err
+		}
+		m := nums[0]
+		for _, n := range nums[1:] {
+			m = math.Min(m, n)
+		}
+		return m, nil
+	}},
+	"MAX": {minArgs: 2, maxArgs: -1, call: func(args []interface{}) (interface{}, error) {
+		nums, err := numbers(args)
+		if err != nil {
+			return nil, err
+		}
+		m := nums[0]
+		for _, n := range nums[1:] {
+			m = math.Max(m, n)
+		}
+		return m, nil
+	}},
+	"COALESCE": {minArgs: 1, maxArgs: -1, nullable: true, call: func(args []interface{}) (interface{}, error) {
+		for _, a := range args {
+			if a != nil {
+				return a, nil
+			}
+		}
+		return nil, nil
+	}},
+}
+
+// stringFunc returns a function of n arguments formatted as strings
+func stringFunc(n int, fn func(s []string) interface{}) function {
+	return function{minArgs: n, maxArgs: n, call: func(args []interface{}) (interface{}, error) {
+		s := make([]string, len(args))
+		for i, a := range args {
+			s[i] = toString(a)
+		}
+		return fn(s), nil
+	}}
+}
+
+// numbers returns args, which must all be numbers
+func numbers(args []interface{}) ([]float64, error) {
+	nums := make([]float64, len(args))
+	for i, a := range args {
+		f, ok := a.(float64)
+		if !ok {
+			return nil, fmt.Errorf("argument %d must be a number, got %q", i+1, toString(a))
+		}
+		nums[i] = f
+	}
+	return nums, nil
+}
@@ -0,0 +1,149 @@
+// Package flags switches behaviors on and off at run time, from the local
+// configuration (FEATURE_FLAGS) and an optional remote endpoint
+// (FEATURE_FLAGS_URL), so they can be enabled store by store and switched off
+// again without redeploying.
+package flags
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Known flags. A flag that is not set leaves the behavior to its own setting.
+const (
+	StreamingCompare = "streaming_compare" // Compare stored rows by hash (MYSQL_PRELOAD=hash) instead of keeping them
+	BatchedUpserts   = "batched_upserts"   // Write updates as multi-row upserts when the TB_ESTOQUE key is unique
+	IsolateErrors    = "isolate_errors"    // Leave out the rows MySQL refuses (BATCH_ISOLATE_ERRORS)
+)
+
+// Known lists the flags in documentation order
+var Known = []string{StreamingCompare, BatchedUpserts, IsolateErrors}
+
+// Set holds the flags that are set, on or off
+type Set map[string]bool
+
+// Enabled returns the state of flag name, def when it is not set
+func (s Set) Enabled(name string, def bool) bool {
+	if on, ok := s[name]; ok {
+		return on
+	}
+	return def
+}
+
+// Merge returns s with the flags of over replacing its own
+func (s Set) Merge(over Set) Set {
+	merged := make(Set, len(s)+len(over))
+	for name, on := range s {
+		merged[name] = on
+	}
+	for name, on := range over {
+		merged[name] = on
+	}
+	return merged
+}
+
+// String lists the flags set, e.g. "batched_upserts=off,streaming_compare=on"
+func (s Set) String() string {
+	parts := make([]string, 0, len(s))
+	for name, on := range s {
+		state := "off"
+		if on {
+			state = "on"
+		}
+		parts = append(parts, name+"="+state)
+	}
+	slices.Sort(parts)
+	return strings.Join(parts, ",")
+}
+
+// MarshalText makes the set log and fingerprint as written in FEATURE_FLAGS
+func (s Set) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Parse reads "name=on,name=off" pairs separated by commas; on/off may also
+// be written true/false or 1/0, and a bare name is on. Only Known flags are accepted.
+func Parse(s string) (Set, error) {
+	set := make(Set)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, hasValue := strings.Cut(pair, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !slices.Contains(Known, name) {
+			return nil, fmt.Errorf("unknown feature flag %q: must be one of %s", name, strings.Join(Known, ", "))
+		}
+		on := true
+		if hasValue {
+			switch strings.ToLower(strings.TrimSpace(value)) {
+			case "on", "true", "1":
+			case "off", "false", "0":
+				on = false
+			default:
+				return nil, fmt.Errorf("feature flag %s: value %q must be on or off", name, value)
+			}
+		}
+		set[name] = on
+	}
+	return set, nil
+}
+
+// Fetch reads the flags served at endpoint for the installation machineID,
+// passed as the machine_id query parameter so the server can answer store by
+// store. The answer is a JSON object of flag names to booleans; flags this
+// version does not know are returned too and ignored by the caller.
+func Fetch(ctx context.Context, endpoint, machineID string) (Set, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid FEATURE_FLAGS_URL: %w", err)
+	}
+	if machineID != "" {
+		q := u.Query()
+		q.Set("machine_id", machineID)
+		u.RawQuery = q.Encode()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating feature flags request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching feature flags: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d fetching feature flags", resp.StatusCode)
+	}
+	var set Set
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("error decoding feature flags: %w", err)
+	}
+	return set, nil
+}
+
+// Unknown returns the names in s that are not Known, sorted
+func (s Set) Unknown() []string {
+	var names []string
+	for name := range s {
+		if !slices.Contains(Known, name) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
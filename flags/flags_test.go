@@ -0,0 +1,41 @@
+package flags
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	set, err := Parse(" streaming_compare=on, BATCHED_UPSERTS=false,isolate_errors ")
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if got, want := set.String(), "batched_upserts=off,isolate_errors=on,streaming_compare=on"; got != want {
+		t.Errorf("Parse = %s; want %s", got, want)
+	}
+
+	for _, bad := range []string{"turbo=on", "streaming_compare=maybe"} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("Parse(%q) expected error", bad)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	local := Set{StreamingCompare: true, IsolateErrors: true}
+	remote := Set{StreamingCompare: false, "adaptive_workers": true}
+
+	merged := local.Merge(remote)
+	if merged.Enabled(StreamingCompare, true) {
+		t.Errorf("remote %s=off did not override the local flag", StreamingCompare)
+	}
+	if !merged.Enabled(IsolateErrors, false) {
+		t.Errorf("local %s=on was lost", IsolateErrors)
+	}
+	if !merged.Enabled(BatchedUpserts, true) || merged.Enabled(BatchedUpserts, false) {
+		t.Errorf("unset %s must return the default", BatchedUpserts)
+	}
+	if unknown := merged.Unknown(); len(unknown) != 1 || unknown[0] != "adaptive_workers" {
+		t.Errorf("Unknown() = %v; want [adaptive_workers]", unknown)
+	}
+	if !local[StreamingCompare] {
+		t.Errorf("Merge modified its receiver")
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/waldirborbajr/sync/output"
+)
+
+// completeOutput completes the value of --output with the formats
+func completeOutput(*cobra.Command, []string, string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	return []cobra.Completion{output.FormatTable, output.FormatJSON, output.FormatYAML, output.FormatCSV}, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeArgs completes the arguments of a command parsing its own: the
+// first with words, the value of --output with the formats, the others with
+// file names
+func completeArgs(words []string) cobra.CompletionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+		if n := len(args); n > 0 && (args[n-1] == "-o" || args[n-1] == "--output") {
+			return completeOutput(cmd, args, toComplete)
+		}
+		if strings.HasPrefix(toComplete, "-") {
+			return []cobra.Completion{"--output", "--help"}, cobra.ShellCompDirectiveNoFileComp
+		}
+		if len(args) == 0 && len(words) > 0 {
+			return words, cobra.ShellCompDirectiveNoFileComp
+		}
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+}
+
+// manCommand prints a sync(1) manual page generated from the command line,
+// cobra's help and completion commands included
+func manCommand(env *commandEnv) int {
+	root := newRootCommand(env.output, new(int))
+	root.InitDefaultHelpCmd()
+	root.InitDefaultCompletionCmd()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, ".TH SYNC 1 %q %q \"SynC Manual\"\n", time.Now().Format("2006-01-02"), "sync "+version)
+	b.WriteString(".SH NAME\nsync \\- synchronize Firebird products, stock and prices into MySQL\n")
+	b.WriteString(".SH SYNOPSIS\n.B sync\n[\\fIcommand\\fR] [\\fB\\-\\-output\\fR \\fItable|json|yaml|csv\\fR]\n")
+	b.WriteString(".SH DESCRIPTION\nWithout a command, a single synchronization run is executed using the settings in \\fI.env\\fR and the environment.\n")
+	b.WriteString("Run \\fBsync config show\\fR to see the effective configuration.\n")
+	b.WriteString(".SH COMMANDS\n")
+	for _, cmd := range root.Commands() {
+		if !cmd.IsAvailableCommand() {
+			continue
+		}
+		fmt.Fprintf(&b, ".TP\n.B sync %s\n%s.\n", roffEscape(cmd.Use), roffEscape(strings.TrimSuffix(cmd.Short, ".")))
+		for _, ex := range strings.Split(cmd.Example, "\n") {
+			if ex = strings.TrimSpace(ex); ex != "" {
+				fmt.Fprintf(&b, ".br\n\\fIExample:\\fR %s\n", roffEscape(ex))
+			}
+		}
+	}
+	b.WriteString(".SH OPTIONS\n.TP\n.BR \\-o \", \" \\-\\-output \" \" \\fIformat\\fR\nOutput format of informational commands: table (default), json, yaml or csv.\n")
+	b.WriteString(".SH EXIT STATUS\n")
+	for _, s := range exitStatuses {
+		fmt.Fprintf(&b, ".TP\n.B %d\n%s\n", s.code, roffEscape(s.meaning))
+	}
+	b.WriteString(".SH FILES\n.TP\n.I .env\nConfiguration; see \\fI.env.example\\fR for every setting.\n.TP\n.I sync_state.json\nPersisted state (maintenance flag, machine ID, incremental watermarks); see STATE_FILE.\n")
+	fmt.Print(b.String())
+	return 0
+}
+
+// roffEscape escapes backslashes and leading dashes for roff
+func roffEscape(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "\\", "\\e"), "-", "\\-")
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/output"
+	"github.com/waldirborbajr/sync/tui"
+)
+
+// envTemplate is .env.example, every setting with its default and documentation
+//
+//go:embed .env.example
+var envTemplate []byte
+
+// initUsage documents the init command
+const initUsage = "init [--file FILE] [--force] [--defaults]"
+
+// initPrompts are the settings "sync init" asks for on a terminal: the
+// connections, which have no default, and the margins every store sets
+var initPrompts = []string{
+	"FIREBIRD_HOST", "FIREBIRD_PATH", "FIREBIRD_USER", "FIREBIRD_PASSWORD",
+	"MYSQL_HOST", "MYSQL_PORT", "MYSQL_DATABASE", "MYSQL_USER", "MYSQL_PASSWORD",
+	"LUCRO", "PARC3X", "PARC6X", "PARC10X",
+}
+
+// initInfo is the output of "sync init" in json and yaml
+type initInfo struct {
+	File   string   `json:"file" yaml:"file"`
+	Backup string   `json:"backup,omitempty" yaml:"backup,omitempty"` // The replaced file, with --force
+	Set    []string `json:"set" yaml:"set"`                           // Settings given a value, the others keep the template's
+}
+
+// initCommand writes a .env holding every setting with its default and
+// documentation. Settings given as flags or in the environment fill it in;
+// on a terminal, the connections and margins left are asked for, unless
+// --defaults. An existing file is only replaced with --force, kept as FILE.bak.
+func initCommand(env *commandEnv) int {
+	path, force, defaults := ".env", false, false
+	for i := 0; i < len(env.args); i++ {
+		switch arg := env.args[i]; {
+		case arg == "--force":
+			force = true
+		case arg == "--defaults":
+			defaults = true
+		case arg == "--file" && i+1 < len(env.args):
+			i++
+			path = env.args[i]
+		case strings.HasPrefix(arg, "--file="):
+			path = strings.TrimPrefix(arg, "--file=")
+		default:
+			fmt.Fprintf(os.Stderr, "%sError:%s unexpected argument %q\nusage: sync %s\n", redBold, reset, arg, initUsage)
+			return 2
+		}
+	}
+
+	existing, err := os.ReadFile(path)
+	switch {
+	case err == nil && !force:
+		fmt.Fprintf(os.Stderr, "%sError:%s %s already exists, add --force to replace it (kept as %s.bak)\n", redBold, reset, path, path)
+		return 1
+	case err != nil && !errors.Is(err, fs.ErrNotExist):
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+		return 1
+	}
+
+	values := config.GivenSettings()
+	if !defaults && tui.Interactive(os.Stdin) && env.output == output.FormatTable {
+		if err := promptSettings(values); err != nil {
+			fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+			return 1
+		}
+	}
+
+	info := initInfo{File: path, Set: append([]string{}, slices.Sorted(maps.Keys(values))...)}
+	data := config.FillEnv(envTemplate, values)
+	if existing != nil {
+		info.Backup = path + ".bak"
+		err = replaceEnvFile(path, existing, data, 0o600)
+	} else {
+		err = os.WriteFile(path, data, 0o600) // Holds the database passwords
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+		return 1
+	}
+
+	if env.output != output.FormatTable {
+		return env.render(info)
+	}
+	fmt.Printf("%sWrote %s%s with every setting and its default", greenBold, path, reset)
+	if info.Backup != "" {
+		fmt.Printf(", the previous file is kept as %s", info.Backup)
+	}
+	fmt.Printf("\nFill in what is left empty, then check it with 'sync config validate --reachable' and 'sync doctor'\n")
+	return 0
+}
+
+// promptSettings asks for each of initPrompts not in values, showing the
+// template's value; an empty answer keeps it
+func promptSettings(values map[string]string) error {
+	template := config.FillEnv(envTemplate, nil)
+	defaults := make(map[string]string)
+	for _, line := range strings.Split(string(template), "\n") {
+		if key, value, ok := strings.Cut(line, "="); ok && !strings.HasPrefix(key, "#") {
+			defaults[key] = value
+		}
+	}
+
+	in := bufio.NewScanner(os.Stdin)
+	for _, key := range initPrompts {
+		if _, ok := values[key]; ok {
+			continue
+		}
+		if d := defaults[key]; d != "" {
+			fmt.Printf("%s [%s]: ", key, d)
+		} else {
+			fmt.Printf("%s: ", key)
+		}
+		if !in.Scan() {
+			if err := in.Err(); err != nil {
+				return fmt.Errorf("error reading %s: %w", key, err)
+			}
+			fmt.Println()
+			return nil // End of input keeps the template for the rest
+		}
+		if answer := strings.TrimSpace(in.Text()); answer != "" {
+			values[key] = answer
+		}
+	}
+	fmt.Println()
+	return nil
+}
@@ -0,0 +1,42 @@
+// Package limits keeps the sync from competing with the point of sale on
+// shared servers: it caps the CPUs used (GOMAXPROCS) and the worker pool, and
+// lowers the process CPU and I/O priority.
+package limits
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/waldirborbajr/sync/config"
+)
+
+// Worker pool bounds when MAX_WORKERS does not set a lower cap
+const (
+	minWorkers = 4
+	maxWorkers = 20
+)
+
+// Apply sets GOMAXPROCS to MAX_PROCS and the process priority to
+// PROCESS_PRIORITY. It is called once per process, before any run starts.
+func Apply(cfg config.Config) error {
+	if cfg.MaxProcs > 0 && cfg.MaxProcs < runtime.NumCPU() {
+		runtime.GOMAXPROCS(cfg.MaxProcs)
+	}
+	if cfg.ProcessPriority == config.PriorityNormal {
+		return nil
+	}
+	if err := setPriority(cfg.ProcessPriority); err != nil {
+		return fmt.Errorf("error setting PROCESS_PRIORITY=%s: %w", cfg.ProcessPriority, err)
+	}
+	return nil
+}
+
+// Workers returns the size of the worker pool: two per usable CPU within
+// [4, 20], capped by MAX_WORKERS
+func Workers(cfg config.Config) int {
+	n := min(max(runtime.GOMAXPROCS(0)*2, minWorkers), maxWorkers)
+	if cfg.MaxWorkers > 0 {
+		n = min(n, cfg.MaxWorkers)
+	}
+	return n
+}
@@ -0,0 +1,44 @@
+package limits
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+
+	"github.com/waldirborbajr/sync/config"
+)
+
+// ioprio_set(2) arguments
+const (
+	ioprioWhoProcess   = 1
+	ioprioClassShift   = 13
+	ioprioClassBestEff = 2
+	ioprioClassIdle    = 3
+)
+
+// setPriority renices the process and lowers its I/O class. Linux keeps both
+// per thread, so every thread is changed; threads started later inherit them.
+func setPriority(priority string) error {
+	nice, ioprio := 10, ioprioClassBestEff<<ioprioClassShift|7
+	if priority == config.PriorityIdle {
+		nice, ioprio = 19, ioprioClassIdle<<ioprioClassShift
+	}
+
+	tasks, err := os.ReadDir("/proc/self/task")
+	if err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		tid, err := strconv.Atoi(task.Name())
+		if err != nil {
+			continue
+		}
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, tid, nice); err != nil {
+			return err
+		}
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), uintptr(ioprio)); errno != 0 {
+			return errno
+		}
+	}
+	return nil
+}
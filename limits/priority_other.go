@@ -0,0 +1,13 @@
+//go:build !unix && !windows
+
+package limits
+
+import (
+	"errors"
+	"runtime"
+)
+
+// setPriority is not supported on this platform
+func setPriority(priority string) error {
+	return errors.New("process priority is not supported on " + runtime.GOOS)
+}
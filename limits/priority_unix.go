@@ -0,0 +1,18 @@
+//go:build unix && !linux
+
+package limits
+
+import (
+	"syscall"
+
+	"github.com/waldirborbajr/sync/config"
+)
+
+// setPriority renices the process; its I/O priority is left unchanged
+func setPriority(priority string) error {
+	nice := 10
+	if priority == config.PriorityIdle {
+		nice = 19
+	}
+	return syscall.Setpriority(syscall.PRIO_PROCESS, 0, nice)
+}
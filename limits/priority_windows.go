@@ -0,0 +1,32 @@
+package limits
+
+import (
+	"syscall"
+
+	"github.com/waldirborbajr/sync/config"
+)
+
+// Windows priority classes
+const (
+	idlePriorityClass        = 0x00000040
+	belowNormalPriorityClass = 0x00004000
+)
+
+var procSetPriorityClass = syscall.NewLazyDLL("kernel32.dll").NewProc("SetPriorityClass")
+
+// setPriority sets the priority class of the process, which Windows also
+// applies to its I/O
+func setPriority(priority string) error {
+	class := uintptr(belowNormalPriorityClass)
+	if priority == config.PriorityIdle {
+		class = idlePriorityClass
+	}
+	process, err := syscall.GetCurrentProcess()
+	if err != nil {
+		return err
+	}
+	if ok, _, err := procSetPriorityClass.Call(uintptr(process), class); ok == 0 {
+		return err
+	}
+	return nil
+}
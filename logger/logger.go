@@ -2,6 +2,7 @@ package logger
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -17,17 +18,53 @@ import (
 var (
 	once     sync.Once
 	instance zerolog.Logger
+
+	dir         = "logs"
+	maxFileSize int64 // Bytes a log file grows to before it is rotated
+
+	console = &consoleOutput{out: os.Stdout, level: zerolog.TraceLevel}
 )
 
+// consoleOutput is where the formatted console lines go, redirected by SetConsole
+type consoleOutput struct {
+	mu    sync.Mutex
+	out   io.Writer
+	level zerolog.Level // Lines below it are left out of the console
+}
+
+// Write writes a formatted line to the current output
+func (c *consoleOutput) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.out.Write(p)
+}
+
+// consoleLevels formats the console lines of at least the console level
+type consoleLevels struct {
+	zerolog.ConsoleWriter
+}
+
+// WriteLevel formats p unless its level is below the console level
+func (w consoleLevels) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	console.mu.Lock()
+	skip := level < console.level
+	console.mu.Unlock()
+	if skip {
+		return len(p), nil
+	}
+	return w.Write(p)
+}
+
 // InitLogger initializes the logger with configurations for console and file output
 func InitLogger(debug bool) zerolog.Logger {
 	once.Do(func() {
 		// Ensure logs directory exists
-		logsDir := "logs"
+		logsDir := dir
 		if err := os.MkdirAll(logsDir, 0755); err != nil {
 			fmt.Fprintf(os.Stderr, "failed to create logs dir: %v\n", err)
 			logsDir = "."
 		}
+		dir = logsDir
 
 		// Generate timestamped log file name
 		t := time.Now()
@@ -36,7 +73,7 @@ func InitLogger(debug bool) zerolog.Logger {
 
 		// Configure console output
 		consoleWriter := zerolog.ConsoleWriter{
-			Out:        os.Stdout,
+			Out:        console,
 			TimeFormat: time.RFC3339,
 			FormatLevel: func(i interface{}) string {
 				s := strings.ToUpper(fmt.Sprint(i))
@@ -72,6 +109,7 @@ func InitLogger(debug bool) zerolog.Logger {
 				maxSizeMB = v
 			}
 		}
+		maxFileSize = int64(maxSizeMB) << 20
 		maxBackups := 7
 		if s := os.Getenv("LOG_MAX_BACKUPS"); s != "" {
 			if v, err := strconv.Atoi(s); err == nil && v >= 0 {
@@ -100,7 +138,7 @@ func InitLogger(debug bool) zerolog.Logger {
 		}
 
 		// MultiWriter: runtime logs go to both console and rotating file
-		multiWriter := zerolog.MultiLevelWriter(consoleWriter, lumberjackLogger)
+		multiWriter := zerolog.MultiLevelWriter(consoleLevels{consoleWriter}, lumberjackLogger)
 
 		// Configure logger level and fields based on debug mode
 		level := zerolog.InfoLevel
@@ -111,8 +149,8 @@ func InitLogger(debug bool) zerolog.Logger {
 		zerolog.SetGlobalLevel(level)
 		zerolog.TimeFieldFormat = time.RFC3339
 
+		// No logger level: the global level governs, so SetLevel takes effect
 		baseLogger := zerolog.New(multiWriter).
-			Level(level).
 			With().
 			Str("app", "sync").
 			Str("goos", runtime.GOOS).
@@ -185,6 +223,51 @@ func cleanOldLogs(dir string, days int) {
 	}
 }
 
+// Dir returns the directory the log files are written to
+func Dir() string {
+	return dir
+}
+
+// MaxFileSize returns the size in bytes a log file reaches before it is rotated
+func MaxFileSize() int64 {
+	return maxFileSize
+}
+
+// SetConsole sends the console lines of at least level to out instead of
+// stdout, e.g. while a display owns the terminal, until restore is called.
+// The log file is not affected.
+func SetConsole(out io.Writer, level zerolog.Level) (restore func()) {
+	console.mu.Lock()
+	defer console.mu.Unlock()
+	previousOut, previousLevel := console.out, console.level
+	console.out, console.level = out, level
+	return func() {
+		console.mu.Lock()
+		defer console.mu.Unlock()
+		console.out, console.level = previousOut, previousLevel
+	}
+}
+
+// SetLevel changes the minimum level logged from here on
+func SetLevel(level zerolog.Level) {
+	zerolog.SetGlobalLevel(level)
+}
+
+// AddField attaches a field to every subsequent log line. Call it during
+// startup, before loggers are handed to other goroutines.
+func AddField(key, value string) {
+	instance = instance.With().Str(key, value).Logger()
+}
+
+// PushField attaches a field to every log line until the returned pop
+// function is called, e.g. the job of the daemon run under way. Like
+// AddField, call it while no other goroutine logs.
+func PushField(key, value string) (pop func()) {
+	previous := instance
+	instance = instance.With().Str(key, value).Logger()
+	return func() { instance = previous }
+}
+
 // GetLogger returns the logger instance
 func GetLogger() zerolog.Logger {
 	return instance
@@ -2,31 +2,72 @@ package main
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"maps"
+	"math"
+	"os"
+	"path/filepath"
 	"runtime"
+	"runtime/pprof"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/rs/zerolog"
 	"github.com/waldirborbajr/sync/config"
 	"github.com/waldirborbajr/sync/db"
+	"github.com/waldirborbajr/sync/flags"
+	"github.com/waldirborbajr/sync/limits"
 	"github.com/waldirborbajr/sync/logger"
+	"github.com/waldirborbajr/sync/metrics"
+	"github.com/waldirborbajr/sync/money"
+	"github.com/waldirborbajr/sync/notify"
+	"github.com/waldirborbajr/sync/output"
+	"github.com/waldirborbajr/sync/preflight"
 	"github.com/waldirborbajr/sync/processor"
+	"github.com/waldirborbajr/sync/run"
+	"github.com/waldirborbajr/sync/state"
+	"github.com/waldirborbajr/sync/tui"
 	"github.com/waldirborbajr/sync/updater"
 )
 
 // version is set at build time using -ldflags="-X main.version=VERSION"
 var version string
 
+// changeBreakdownLimit is how many columns and change reasons the report lists
+const changeBreakdownLimit = 5
+
 // ANSI color codes
 const (
-	redBold   = "\033[1;31m"
-	greenBold = "\033[1;32m"
-	reset     = "\033[0m"
+	redBold    = "\033[1;31m"
+	greenBold  = "\033[1;32m"
+	yellowBold = "\033[1;33m"
+	reset      = "\033[0m"
 )
 
 func main() {
 	// Initialize logger with default debug false
-	log := logger.InitLogger(false)
+	logger.InitLogger(false)
+
+	os.Exit(dispatchCommand(os.Args[1:]))
+}
+
+// runCommand executes a single synchronization run, what sync does without
+// a command: the update check, then the run and its report. Its exit status
+// tells the failure classes apart, see exitStatuses.
+func runCommand(env *commandEnv) int {
+	if len(env.args) > 0 {
+		fmt.Fprintf(os.Stderr, "%sError:%s unexpected argument %q\nusage: sync run\n", redBold, reset, env.args[0])
+		return 2
+	}
+	// With --output json, yaml or csv, stdout only gets the report
+	structured := env.output != output.FormatTable
+	if structured {
+		defer logger.SetConsole(os.Stderr, zerolog.TraceLevel)()
+	}
+	log := logger.GetLogger()
 
 	// Check for updates first
 	ctx := context.Background()
@@ -34,8 +75,21 @@ func main() {
 	if err != nil {
 		log.Warn().Err(err).Msg("Error loading update configuration")
 	}
-	downloaded, path, info, err := updater.RunUpdateFlow(ctx, version, cfgForUpdate)
-	if err != nil {
+	if err := db.CheckWritable(cfgForUpdate); err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v - synchronization runs are disabled\n", redBold, reset, err)
+		return 1
+	}
+	needs := append([]preflight.Need{preflight.Logs()}, preflight.Update(cfgForUpdate)...)
+	if err := preflight.Check(cfgForUpdate, needs...); err != nil {
+		log.Error().Err(err).Msg("Disk space preflight failed, run aborted")
+		fmt.Fprintf(os.Stderr, "%sError:%s %v - free some space before running\n", redBold, reset, err)
+		return 1
+	}
+	downloaded, path, info, err := updater.RunUpdateFlow(ctx, version, cfgForUpdate, runRegistry(cfgForUpdate))
+	var deferred *updater.DeferredError
+	if errors.As(err, &deferred) {
+		log.Info().Str("latest", info.Version).Str("file", path).Str("active_run", deferred.Runs[0].ID).Msg("Update downloaded, install deferred until the runs in progress end")
+	} else if err != nil {
 		log.Warn().Err(err).Msg("Error while checking updates")
 	} else if info.URL != "" {
 		if downloaded {
@@ -48,65 +102,285 @@ func main() {
 	// Load configuration from .env
 	cfg, err := config.LoadConfig()
 	if err != nil {
-		log.Fatal().Err(err).Msg("Error loading configuration")
+		log.Error().Err(err).Msg("Error loading configuration")
+		return exitConfig
 	}
 
-	// Update logger level if debug mode is enabled
+	// DEBUG_MODE (or --debug) lowers the level after the logger is created
 	if cfg.DebugMode {
-		// Since logger is initialized with once, we can set the global level
-		// Note: This may not affect already created loggers, but for simplicity
-		// we'll assume it's ok or handle differently if needed
+		logger.SetLevel(zerolog.DebugLevel)
+	}
+
+	if !structured {
+		fmt.Printf("\nSynC Firebird x MySQL v%s (Optimized Worker Pool)\n\n", version)
+	}
+
+	// Scheduled runs are skipped while maintenance mode is on
+	st, err := state.Load(cfg.StateFile)
+	if err != nil {
+		log.Error().Err(err).Msg("Error loading state")
+		return 1
+	}
+	if st.Maintenance {
+		log.Warn().Time("since", st.MaintenanceSince).Str("reason", st.MaintenanceReason).Msg("Maintenance mode is on, run skipped")
+		if structured {
+			return env.render(runReport{Status: runSkipped, Recommendations: []string{}})
+		}
+		fmt.Printf("%s - run skipped. Use 'sync maintenance off' to resume.\n", maintenanceStatus(st))
+		return 0
+	}
+
+	if err := setupIdentity(cfg); err != nil {
+		log.Error().Err(err).Msg("Error loading machine ID")
+		return 1
 	}
+	log = logger.GetLogger()
+	applyLimits(cfg)
+	cfg = applyFeatureFlags(cfg)
 
-	fmt.Printf("\nSynC Firebird x MySQL v%s (Optimized Worker Pool)\n\n", version)
+	// On a terminal, PROGRESS_TUI (or --tui) draws the progress instead of the log
+	// lines, and PROGRESS_LINE keeps a progress line under them
+	runCtx := ctx
+	stopProgress := func() {}
+	switch interactive := !structured && tui.Interactive(os.Stdout); {
+	case cfg.TUI && interactive:
+		progress := run.NewProgress()
+		runCtx = run.WithProgress(ctx, progress)
+		stopProgress = tui.Start(os.Stdout, "sync run", progress, 200*time.Millisecond).Stop
+	case cfg.TUI:
+		log.Info().Msg("TUI needs a terminal and the table output, logging instead")
+	case cfg.ProgressLine && interactive:
+		progress := run.NewProgress()
+		runCtx = run.WithProgress(ctx, progress)
+		stopProgress = tui.StartLine(os.Stdout, progress, 500*time.Millisecond).Stop
+	}
 
 	// Run main processing and print a summarized report
-	insertedCount, updatedCount, ignoredCount, batchSize, stats, elapsedTime, maxConnections, maxAllowedPacket, err := runProcessing(cfg)
+	insertedCount, updatedCount, ignoredCount, batchSize, stats, elapsedTime, maxConnections, maxAllowedPacket, err := runWithRecovery(runCtx, cfg, nil)
+	stopProgress()
+	if pushErr := metrics.Push(ctx, cfg, runMetrics(insertedCount, updatedCount, ignoredCount, stats, elapsedTime, err)); pushErr != nil {
+		log.Warn().Err(pushErr).Msg("Could not push run metrics")
+	}
+	notifyRun(cfg, "", insertedCount, updatedCount, ignoredCount, stats, elapsedTime, err)
+	if structured {
+		report := newRunReport(insertedCount, updatedCount, ignoredCount, batchSize, stats, elapsedTime, limits.Workers(cfg), maxConnections, maxAllowedPacket, err)
+		if errors.Is(err, errRunDeferred) {
+			report.Status = runDeferred
+		}
+		if code := env.render(report); code != 0 {
+			return code
+		}
+	}
+	if errors.Is(err, errRunDeferred) {
+		if !structured {
+			fmt.Printf("%s%v%s\n", yellowBold, err, reset)
+		}
+		return 0
+	}
 	if err != nil {
-		log.Fatal().Err(err).Msg("Error processing rows")
+		log.Error().Err(err).Msg("Error processing rows")
+		return runExitStatus(err, nil)
 	}
 
-	// Calculate number of workers used
-	numWorkers := runtime.NumCPU() * 2
-	if numWorkers > 20 {
-		numWorkers = 20
+	if !structured {
+		printSummary(insertedCount, updatedCount, ignoredCount, batchSize, stats, elapsedTime, limits.Workers(cfg), maxConnections, maxAllowedPacket)
 	}
-	if numWorkers < 4 {
-		numWorkers = 4
+	return runExitStatus(nil, stats)
+}
+
+// setupIdentity loads the stable installation identity and attaches it to
+// every log line from here on
+func setupIdentity(cfg config.Config) error {
+	machineID, err := state.EnsureMachineID(cfg.StateFile)
+	if err != nil {
+		return err
 	}
+	run.SetMachineID(machineID)
+	logger.AddField("machine_id", machineID)
 
-	printSummary(insertedCount, updatedCount, ignoredCount, batchSize, stats, elapsedTime, numWorkers, maxConnections, maxAllowedPacket)
+	log := logger.GetLogger()
+	log.Info().Str("config_fingerprint", config.Fingerprint(cfg)).Msg("Machine identity loaded")
+	return nil
 }
 
-// runProcessing orchestrates DB connections with optimized worker pool processing
-func runProcessing(cfg config.Config) (inserted, updated, ignored, batchSize int, stats *processor.ProcessingStats, elapsed time.Duration, maxConnections int, maxAllowedPacket int, err error) {
+// applyFeatureFlags returns cfg with the flags served at FEATURE_FLAGS_URL
+// merged over the local FEATURE_FLAGS. Fetched flags are kept in the state
+// file, and the last ones are used while the endpoint cannot be reached, so
+// a behavior switched off remotely stays off.
+func applyFeatureFlags(cfg config.Config) config.Config {
+	if cfg.FeatureFlagsURL == "" {
+		return cfg
+	}
 	log := logger.GetLogger()
 
-	// Connect to Firebird with optimized settings
-	firebirdConn, err := db.ConnectFirebird(cfg)
+	remote, err := flags.Fetch(context.Background(), cfg.FeatureFlagsURL, run.MachineID())
+	if err == nil {
+		if unknown := remote.Unknown(); len(unknown) > 0 {
+			log.Warn().Strs("flags", unknown).Msg("Ignoring remote feature flags unknown to this version")
+			for _, name := range unknown {
+				delete(remote, name)
+			}
+		}
+		if _, err := state.Update(cfg.StateFile, func(s *state.State) {
+			s.Caches.FeatureFlags, s.Caches.FeatureFlagsAt = remote, time.Now()
+		}); err != nil {
+			log.Warn().Err(err).Msg("Could not keep the feature flags")
+		}
+	} else {
+		st, stErr := state.Load(cfg.StateFile)
+		if stErr != nil || st.Caches.FeatureFlagsAt.IsZero() {
+			log.Warn().Err(err).Msg("Could not fetch feature flags, using the local ones")
+			return cfg
+		}
+		log.Warn().Err(err).Time("fetched_at", st.Caches.FeatureFlagsAt).Msg("Could not fetch feature flags, using the last fetched ones")
+		remote = st.Caches.FeatureFlags
+	}
+
+	flagged, err := cfg.WithFlags(cfg.FeatureFlags.Merge(remote))
 	if err != nil {
-		return 0, 0, 0, 0, nil, 0, 0, 0, err
+		log.Warn().Err(err).Msg("Feature flag not applied")
 	}
-	defer func() {
-		if firebirdConn != nil {
-			if closeErr := firebirdConn.Close(); closeErr != nil {
-				log.Error().Err(closeErr).Msg("Error closing Firebird database connection")
+	log.Info().Stringer("flags", flagged.FeatureFlags).Msg("Feature flags applied")
+	return flagged
+}
+
+// applyLimits applies the resource limits of cfg to the process; a priority
+// the platform refuses is logged and the run goes on
+func applyLimits(cfg config.Config) {
+	log := logger.GetLogger()
+	if err := limits.Apply(cfg); err != nil {
+		log.Warn().Err(err).Msg("Could not apply resource limits")
+		return
+	}
+	if cfg.MaxProcs > 0 || cfg.MaxWorkers > 0 || cfg.ProcessPriority != config.PriorityNormal {
+		log.Info().
+			Int("cpus", runtime.GOMAXPROCS(0)).
+			Int("workers", limits.Workers(cfg)).
+			Str("priority", cfg.ProcessPriority).
+			Msg("Resource limits applied")
+	}
+}
+
+// runWithRecovery runs the sync and, when it fails with a retryable error
+// class, schedules up to RECOVERY_ATTEMPTS recovery runs with exponential
+// backoff. Every attempt gets its own run ID; the failed ones are kept in
+// stats.RetryChain. conns, when not nil, holds connections shared with
+// other runs. Cancelling parent stops the waits between attempts too.
+func runWithRecovery(parent context.Context, cfg config.Config, conns *dbConns) (inserted, updated, ignored, batchSize int, stats *processor.ProcessingStats, elapsed time.Duration, maxConnections int, maxAllowedPacket int, err error) {
+	log := logger.GetLogger()
+
+	var (
+		chain            []string
+		maintenanceSince time.Time
+	)
+	backoff := cfg.RecoveryBackoff
+	for attempt := 0; ; {
+		runID := run.NewID()
+		run.ProgressFrom(parent).Reset()
+		ctx := run.WithID(parent, runID)
+		ws, wsErr := run.NewWorkspace(cfg.WorkDir, runID, cfg.WorkDirRetention)
+		if wsErr != nil {
+			return 0, 0, 0, 0, nil, 0, 0, 0, wsErr
+		}
+		ctx = run.WithWorkspace(ctx, ws)
+		// Registered so an update is not installed until the run ends, or cancels it
+		ctx, endRun, regErr := runRegistry(cfg).Begin(ctx, runID)
+		if regErr != nil {
+			closeWorkspace(ws, runID, true)
+			return 0, 0, 0, 0, nil, 0, 0, 0, regErr
+		}
+
+		inserted, updated, ignored, batchSize, stats, elapsed, maxConnections, maxAllowedPacket, err = runProcessing(ctx, cfg, conns)
+		cancelled := errors.Is(context.Cause(ctx), run.ErrCancelled)
+		endRun()
+		closeWorkspace(ws, runID, err == nil)
+		if err == nil {
+			stats.RetryChain = chain
+			if len(chain) > 0 {
+				log.Info().Str("run_id", runID).Strs("failed_runs", chain).Msg("Recovery run succeeded")
 			}
+			return inserted, updated, ignored, batchSize, stats, elapsed, maxConnections, maxAllowedPacket, nil
 		}
-	}()
 
-	// Connect to MySQL with optimized settings
-	mysqlConn, err := db.ConnectMySQL(cfg)
+		chain = append(chain, runID)
+		if cancelled {
+			log.Warn().Str("run_id", runID).Msg("Run cancelled to install an update")
+			return 0, 0, 0, 0, nil, 0, 0, 0, fmt.Errorf("%w: %w", run.ErrCancelled, err)
+		}
+
+		// Firebird maintenance ends on its own: wait for it, then give the run up
+		if db.Classify(err) == db.ClassMaintenance {
+			if maintenanceSince.IsZero() {
+				maintenanceSince = time.Now()
+			}
+			if time.Since(maintenanceSince)+cfg.MaintenanceRetry > cfg.MaintenanceWait {
+				log.Warn().Err(err).Str("run_id", runID).Dur("waited", time.Since(maintenanceSince).Round(time.Second)).Msg("Firebird still under maintenance, run deferred")
+				return 0, 0, 0, 0, nil, 0, 0, 0, fmt.Errorf("%w: %w", errRunDeferred, err)
+			}
+			log.Warn().Err(err).Str("run_id", runID).Dur("retry_in", cfg.MaintenanceRetry).Msg("Firebird under maintenance, waiting")
+			select {
+			case <-parent.Done():
+				return 0, 0, 0, 0, nil, 0, 0, 0, err
+			case <-time.After(cfg.MaintenanceRetry):
+			}
+			continue
+		}
+
+		if attempt >= cfg.RecoveryAttempts || !db.IsRetryable(err) {
+			if len(chain) > 1 {
+				log.Error().Strs("failed_runs", chain).Msg("Recovery attempts exhausted")
+			}
+			return 0, 0, 0, 0, nil, 0, 0, 0, err
+		}
+
+		log.Warn().
+			Err(err).
+			Str("run_id", runID).
+			Str("error_class", string(db.Classify(err))).
+			Int("attempt", attempt+1).
+			Int("max_attempts", cfg.RecoveryAttempts).
+			Dur("backoff", backoff).
+			Msg("Run failed with a retryable error, scheduling recovery run")
+		select {
+		case <-parent.Done():
+			return 0, 0, 0, 0, nil, 0, 0, 0, err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		attempt++
+	}
+}
+
+// runRegistry returns the registry of the runs in progress on this
+// installation, kept next to the state file
+func runRegistry(cfg config.Config) *run.Registry {
+	return run.NewRegistry(cfg.StateFile + ".runs")
+}
+
+// closeWorkspace removes the workspace of a successful run and reports the
+// one a failed run keeps
+func closeWorkspace(ws *run.Workspace, runID string, succeeded bool) {
+	log := logger.GetLogger()
+	kept, err := ws.Close(succeeded)
+	if err != nil {
+		log.Warn().Err(err).Str("workspace", ws.Dir()).Msg("Could not remove the run workspace")
+		return
+	}
+	if kept != "" {
+		log.Warn().Str("run_id", runID).Str("workspace", kept).Msg("Run workspace kept for inspection")
+	}
+}
+
+// runProcessing orchestrates DB connections with optimized worker pool processing
+func runProcessing(ctx context.Context, cfg config.Config, conns *dbConns) (inserted, updated, ignored, batchSize int, stats *processor.ProcessingStats, elapsed time.Duration, maxConnections int, maxAllowedPacket int, err error) {
+	log := logger.GetLogger()
+
+	// Connect to Firebird and MySQL with optimized settings
+	firebirdConn, mysqlConn, release, err := conns.open(cfg)
 	if err != nil {
 		return 0, 0, 0, 0, nil, 0, 0, 0, err
 	}
-	defer func() {
-		if mysqlConn != nil {
-			if closeErr := mysqlConn.Close(); closeErr != nil {
-				log.Error().Err(closeErr).Msg("Error closing MySQL database connection")
-			}
-		}
-	}()
+	defer release()
 
 	// MySQL optimizations (skip for SQLite in DEV_MODE)
 	if !cfg.DevMode {
@@ -140,23 +414,31 @@ func runProcessing(cfg config.Config) (inserted, updated, ignored, batchSize int
 		maxAllowedPacket = 4 * 1024 * 1024
 	}
 
-	// Calculate optimal worker count
-	numWorkers := runtime.NumCPU() * 2
-	if numWorkers > 20 {
-		numWorkers = 20 // Cap at 20 for safety
+	numWorkers := limits.Workers(cfg)
+
+	// Processing with optimized worker pool
+	runID := run.IDFrom(ctx)
+	if cfg.WatchdogTimeout > 0 {
+		wd := startWatchdog(cfg, runID, run.WorkspaceFrom(ctx))
+		defer wd.Stop()
+		ctx = run.WithWatchdog(ctx, wd)
 	}
-	if numWorkers < 4 {
-		numWorkers = 4 // Minimum workers
+	if cfg.HeapLimitMB > 0 {
+		guard := startHeapGuard(cfg, runID, numWorkers)
+		defer guard.Stop()
+		ctx = run.WithHeapGuard(ctx, guard)
 	}
 
+	stopInstance := registerInstance(ctx, cfg, mysqlConn, runID)
+	defer stopInstance()
+
 	log.Info().
+		Str("run_id", runID).
 		Int("num_workers", numWorkers).
 		Int("max_connections", maxConnections).
 		Int("max_allowed_packet_mb", maxAllowedPacket/(1024*1024)).
 		Msg("Starting optimized sync with worker pool")
 
-	// Processing with optimized worker pool
-	ctx := context.Background()
 	stats = &processor.ProcessingStats{}
 	startTime := time.Now()
 
@@ -181,6 +463,346 @@ func runProcessing(cfg config.Config) (inserted, updated, ignored, batchSize int
 	return inserted, updated, ignored, batchSize, stats, elapsed, maxConnections, maxAllowedPacket, nil
 }
 
+// registerInstance records this machine's run in TB_SYNC_INSTANCIAS, warns
+// loudly when another machine is syncing into the same database and keeps a
+// heartbeat until the returned stop function is called. Registry failures
+// are logged and never fail the run.
+func registerInstance(ctx context.Context, cfg config.Config, mysqlConn *sql.DB, runID string) (stop func()) {
+	log := logger.GetLogger()
+
+	hostname, _ := os.Hostname()
+	self := db.Instance{
+		MachineID:   run.MachineID(),
+		Hostname:    hostname,
+		RunID:       runID,
+		Fingerprint: config.Fingerprint(cfg),
+		Version:     version,
+	}
+	others, err := db.RegisterInstance(ctx, mysqlConn, cfg, self)
+	if err != nil {
+		log.Warn().Err(err).Msg("Could not register instance")
+		return func() {}
+	}
+	for _, other := range others {
+		log.Error().
+			Str("other_machine_id", other.MachineID).
+			Str("other_hostname", other.Hostname).
+			Str("other_run_id", other.RunID).
+			Str("other_config_fingerprint", other.Fingerprint).
+			Time("other_heartbeat", other.Heartbeat).
+			Msg("ANOTHER MACHINE IS SYNCING INTO THIS DATABASE CONCURRENTLY")
+		// Stderr keeps the banner out of a --output json or yaml report
+		fmt.Fprintf(os.Stderr, "%sWARNING: machine %s (%s) is running a sync against the same destination database!%s\n", redBold, other.MachineID, other.Hostname, reset)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(db.HeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := db.HeartbeatInstance(ctx, mysqlConn, self.MachineID); err != nil {
+					log.Warn().Err(err).Msg("Could not refresh instance heartbeat")
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		db.FinishInstance(mysqlConn, self.MachineID)
+	}
+}
+
+// errRunHung is reported when the watchdog terminates a run
+var errRunHung = errors.New("run hung: no progress within WATCHDOG_TIMEOUT")
+
+// errRunDeferred is reported when Firebird stays under maintenance past
+// FIREBIRD_MAINTENANCE_WAIT; the run is put off, not failed
+var errRunDeferred = errors.New("run deferred due to source maintenance")
+
+// startWatchdog terminates the process when the run stops making progress,
+// so a hung driver call cannot keep database locks held indefinitely. The
+// goroutine stacks are dumped into the run workspace, which the failed run
+// keeps, or logged when there is none.
+func startWatchdog(cfg config.Config, runID string, ws *run.Workspace) *run.Watchdog {
+	wd := run.NewWatchdog(cfg.WatchdogTimeout)
+	wd.Start(func(idle time.Duration) {
+		log := logger.GetLogger()
+
+		buf := make([]byte, 1<<20)
+		n := runtime.Stack(buf, true)
+		event := log.Error().Str("run_id", runID).Dur("idle", idle)
+		if ws == nil {
+			event = event.Str("goroutines", string(buf[:n]))
+		} else if path, err := ws.WriteFile("goroutines.txt", buf[:n]); err != nil {
+			event = event.Str("goroutines", string(buf[:n])).AnErr("dump_error", err)
+		} else {
+			event = event.Str("goroutines_dump", path)
+		}
+		event.Msg("Watchdog: run hung, terminating")
+
+		if err := metrics.Push(context.Background(), cfg, runMetrics(0, 0, 0, nil, 0, errRunHung)); err != nil {
+			log.Warn().Err(err).Msg("Could not push run metrics")
+		}
+		notifyRun(cfg, "", 0, 0, 0, nil, 0, errRunHung)
+		os.Exit(exitHung)
+	})
+	return wd
+}
+
+// startHeapGuard starts the HEAP_LIMIT_MB guard of a run: each time the heap
+// crosses the limit a heap profile is written to the log directory, for the
+// out-of-memory kills that otherwise leave nothing behind, and the writers
+// allowed at once are halved
+func startHeapGuard(cfg config.Config, runID string, workers int) *run.HeapGuard {
+	guard := run.NewHeapGuard(uint64(cfg.HeapLimitMB)<<20, workers)
+	var profiles int
+	guard.Start(cfg.HeapCheckInterval, func(heap uint64, writers int) {
+		log := logger.GetLogger()
+		profiles++
+		event := log.Warn().Str("run_id", runID).Uint64("heap_mb", heap>>20).Int("limit_mb", cfg.HeapLimitMB).Int("writers", writers)
+
+		path := filepath.Join(logger.Dir(), fmt.Sprintf("heap-%s-%d.pb.gz", runID, profiles))
+		if err := writeHeapProfile(path); err != nil {
+			event = event.AnErr("profile_error", err)
+		} else {
+			event = event.Str("heap_profile", path)
+		}
+		event.Msg("Heap over HEAP_LIMIT_MB, writers reduced")
+	})
+	return guard
+}
+
+// writeHeapProfile writes the heap profile, for go tool pprof, to path
+func writeHeapProfile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// runMetrics builds the samples pushed at the end of a run; stats is nil when the run failed
+func runMetrics(inserted, updated, ignored int, stats *processor.ProcessingStats, elapsed time.Duration, runErr error) []metrics.Sample {
+	success := 1.0
+	if runErr != nil {
+		success = 0
+	}
+	deferred := 0.0
+	if errors.Is(runErr, errRunDeferred) {
+		deferred = 1
+	}
+	samples := []metrics.Sample{
+		{Name: "sync_last_run_success", Help: "1 if the last run succeeded, 0 otherwise", Value: success},
+		{Name: "sync_last_run_deferred", Help: "1 if the last run was deferred because Firebird was under maintenance", Value: deferred},
+		{Name: "sync_last_run_timestamp_seconds", Help: "Unix time the last run finished", Value: float64(time.Now().Unix())},
+	}
+	if stats == nil {
+		return samples
+	}
+
+	samples = append(samples,
+		metrics.Sample{Name: "sync_run_duration_seconds", Help: "Duration of the last run", Value: elapsed.Seconds()},
+		metrics.Sample{Name: "sync_rows_inserted", Help: "Rows inserted by the last run", Value: float64(inserted)},
+		metrics.Sample{Name: "sync_rows_updated", Help: "Rows updated by the last run", Value: float64(updated)},
+		metrics.Sample{Name: "sync_rows_ignored", Help: "Rows left unchanged by the last run", Value: float64(ignored)},
+		metrics.Sample{Name: "sync_rows_total", Help: "Source rows read by the last run", Value: float64(stats.TotalRows)},
+		metrics.Sample{Name: "sync_query_seconds", Help: "Firebird query time", Value: stats.QueryTime.Seconds()},
+		metrics.Sample{Name: "sync_procedure_seconds", Help: "MySQL procedure time", Value: stats.ProcedureTime.Seconds()},
+		metrics.Sample{Name: "sync_price_history_rows", Help: "Price history rows written", Value: float64(stats.PriceHistoryRows)},
+		metrics.Sample{Name: "sync_audit_rows", Help: "Audit rows written", Value: float64(stats.AuditRows)},
+		metrics.Sample{Name: "sync_recovery_attempts", Help: "Failed attempts before the last successful run", Value: float64(len(stats.RetryChain))},
+		metrics.Sample{Name: "sync_batch_retries", Help: "Batch writes retried after a transient error", Value: float64(batchRetries(stats))},
+		metrics.Sample{Name: "sync_rows_rejected", Help: "Rows MySQL refused, left out of their batches", Value: float64(len(stats.RejectedRows))},
+		metrics.Sample{Name: "sync_deadlock_retries", Help: "Batch writes retried after a deadlock or lock conflict", Value: float64(stats.BatchRetries[string(db.ClassDeadlock)])},
+	)
+	for _, class := range []db.ErrorClass{db.ClassConnection, db.ClassDeadlock, db.ClassTimeout, db.ClassConstraint, db.ClassConversion, db.ClassReadOnly, db.ClassMaintenance, db.ClassOther} {
+		samples = append(samples, metrics.Sample{Name: "sync_errors_" + string(class), Help: "Errors of class " + string(class) + " met by the last run, retried ones included", Value: float64(stats.Errors[string(class)])})
+	}
+	if er := stats.ExchangeRate; er != nil {
+		samples = append(samples, metrics.Sample{Name: "sync_exchange_rate", Help: "USD/BRL rate PRC_DOLAR was derived with, 0 when unavailable", Value: er.Rate})
+	}
+	if rs := stats.Reverse; rs != nil {
+		samples = append(samples,
+			metrics.Sample{Name: "sync_reverse_rows_pushed", Help: "Firebird rows updated with MySQL-managed columns", Value: float64(rs.Pushed)},
+			metrics.Sample{Name: "sync_reverse_conflicts", Help: "Rows changed on both sides since their columns were last pushed", Value: float64(len(rs.Conflicts))},
+		)
+	}
+	if sc := stats.SpotChecks; sc != nil {
+		samples = append(samples, metrics.Sample{Name: "sync_spot_check_failures", Help: "Spot-checked rows missing or holding other values than computed", Value: float64(len(sc.Missing) + len(sc.Mismatches))})
+	}
+	return samples
+}
+
+// notifyChannels returns the notification channels configured in cfg
+func notifyChannels(cfg config.Config) []notify.Channel {
+	var channels []notify.Channel
+	if cfg.NotifyTelegramToken != "" {
+		t, _ := notify.ParseTemplate("NOTIFY_TELEGRAM_TEMPLATE", cfg.NotifyTelegramTemplate)
+		channels = append(channels, notify.Channel{
+			Name:        "telegram",
+			MinSeverity: cfg.NotifyTelegramSeverity,
+			Template:    t,
+			Sender:      notify.Telegram{APIURL: cfg.NotifyTelegramAPIURL, Token: cfg.NotifyTelegramToken, ChatID: cfg.NotifyTelegramChatID},
+		})
+	}
+	if cfg.NotifyWhatsAppTo != "" {
+		var sender notify.Sender = notify.TwilioWhatsApp{APIURL: cfg.NotifyWhatsAppURL, AccountSID: cfg.NotifyWhatsAppAccountSID, AuthToken: cfg.NotifyWhatsAppToken, From: cfg.NotifyWhatsAppFrom, To: cfg.NotifyWhatsAppTo}
+		if cfg.NotifyWhatsAppProvider == config.WhatsAppWebhook {
+			sender = notify.WebhookWhatsApp{URL: cfg.NotifyWhatsAppURL, Token: cfg.NotifyWhatsAppToken, From: cfg.NotifyWhatsAppFrom, To: cfg.NotifyWhatsAppTo}
+		}
+		t, _ := notify.ParseTemplate("NOTIFY_WHATSAPP_TEMPLATE", cfg.NotifyWhatsAppTemplate)
+		channels = append(channels, notify.Channel{Name: "whatsapp", MinSeverity: cfg.NotifyWhatsAppSeverity, Template: t, Sender: sender})
+	}
+	return channels
+}
+
+// notifyRun sends the outcome of a run to the configured channels; stats is
+// nil when the run failed. A run that needed recoveries, retries or left
+// rows out is reported as a warning.
+func notifyRun(cfg config.Config, job string, inserted, updated, ignored int, stats *processor.ProcessingStats, elapsed time.Duration, runErr error) {
+	channels := notifyChannels(cfg)
+	if len(channels) == 0 {
+		return
+	}
+	host, _ := os.Hostname()
+	e := notify.Event{Severity: notify.SeverityInfo, Host: host, Job: job, At: time.Now()}
+	if stats != nil {
+		e.RunID = stats.RunID
+	}
+
+	switch {
+	case errors.Is(runErr, errRunDeferred):
+		e.Severity = notify.SeverityWarning
+		e.Title = "Sync deferred on " + host
+		e.Text = runErr.Error()
+	case runErr != nil:
+		e.Severity = notify.SeverityError
+		e.Title = "Sync failed on " + host
+		e.Text = runErr.Error()
+	default:
+		e.Title = "Sync finished on " + host
+		e.Text = fmt.Sprintf("%d inserted, %d updated, %d unchanged in %s", inserted, updated, ignored, elapsed.Round(time.Second))
+		var issues []string
+		if n := len(stats.RetryChain); n > 0 {
+			issues = append(issues, fmt.Sprintf("%d failed attempts before this one", n))
+		}
+		if n := batchRetries(stats); n > 0 {
+			issues = append(issues, fmt.Sprintf("%d batch retries", n))
+		}
+		if n := len(stats.RejectedRows); n > 0 {
+			issues = append(issues, fmt.Sprintf("%d rows rejected by MySQL", n))
+		}
+		if sc := stats.SpotChecks; sc != nil && !sc.OK() {
+			issues = append(issues, fmt.Sprintf("%d spot checks failed", len(sc.Missing)+len(sc.Mismatches)))
+		}
+		if rs := stats.Reverse; rs != nil && len(rs.Conflicts) > 0 {
+			issues = append(issues, fmt.Sprintf("%d reverse sync conflicts", len(rs.Conflicts)))
+		}
+		if lc := stats.LargeChanges; lc != nil && lc.Count > 0 {
+			issues = append(issues, fmt.Sprintf("%d changes of %g%% or more", lc.Count, lc.Threshold))
+		}
+		if len(issues) > 0 {
+			e.Severity = notify.SeverityWarning
+			e.Text += "; " + strings.Join(issues, ", ")
+		}
+		if lc := stats.LargeChanges; lc != nil {
+			for _, c := range lc.Top {
+				e.Text += "\n" + formatLargeChange(c)
+			}
+		}
+	}
+	if job != "" {
+		e.Title += " (job " + job + ")"
+	}
+
+	sendNotification(channels, e)
+}
+
+// sendNotification sends e to channels, logging the deliveries that failed
+func sendNotification(channels []notify.Channel, e notify.Event) {
+	if err := notify.Send(context.Background(), channels, e); err != nil {
+		log := logger.GetLogger()
+		log.Warn().Err(err).Msg("Could not send notifications")
+	}
+}
+
+// formatLargeChange describes a large change in a notification
+func formatLargeChange(c processor.LargeChange) string {
+	from, to := fmt.Sprintf("%g", c.Old), fmt.Sprintf("%g", c.New)
+	if c.Column == "PRC_VENDA" {
+		from, to = "R$ "+money.FromFloat(c.Old).String(), "R$ "+money.FromFloat(c.New).String()
+	}
+	return fmt.Sprintf("%d %s %s: %s -> %s (%+.0f%%)", c.IDEstoque, c.Descricao, c.Column, from, to, math.Copysign(c.Percent(), c.New-c.Old))
+}
+
+// rejectedReportLimit caps the rejected keys listed in the report
+const rejectedReportLimit = 10
+
+// conflictReportLimit caps the reverse sync conflicts listed in the report
+const conflictReportLimit = 10
+
+// spotCheckReportLimit caps the spot check mismatches listed in the report
+const spotCheckReportLimit = 10
+
+// printSpotChecks prints the rows read back after the run committed
+func printSpotChecks(sc *processor.SpotCheckStats) {
+	if sc.OK() {
+		fmt.Printf("  Spot checks: \033[1;32m%d rows match\033[0m\n", sc.Checked)
+	} else {
+		fmt.Printf("  Spot checks: %d rows, \033[1;31m%d missing\033[0m, \033[1;31m%d column mismatches\033[0m\n", sc.Checked, len(sc.Missing), len(sc.Mismatches))
+	}
+	for _, id := range sc.Missing {
+		fmt.Printf("    %d: not found in TB_ESTOQUE\n", id)
+	}
+	for i, m := range sc.Mismatches {
+		if i == spotCheckReportLimit {
+			fmt.Printf("    ... %d more\n", len(sc.Mismatches)-i)
+			break
+		}
+		fmt.Printf("    %d %s: stored %q, expected %q\n", m.IDEstoque, m.Column, m.Stored, m.Expected)
+	}
+	if len(sc.NotSynced) > 0 {
+		fmt.Printf("    Not synced this run: %v\n", sc.NotSynced)
+	}
+	if sc.SamplePercent > 0 {
+		rate, low, high := sc.ErrorRate()
+		color := "\033[1;32m"
+		if sc.Failed > 0 {
+			color = "\033[1;31m"
+		}
+		fmt.Printf("  Verify sample: %d of %d written rows (%g%%), %s%d failing\033[0m\n", sc.Sampled, sc.Written, sc.SamplePercent, color, sc.Failed)
+		fmt.Printf("    Estimated error rate: %.2f%% (95%% confidence %.2f%% to %.2f%%, up to %d rows)\n", rate*100, low*100, high*100, int(math.Ceil(high*float64(sc.Written))))
+	}
+}
+
+// batchRetries returns the batch retries of all error classes
+func batchRetries(stats *processor.ProcessingStats) int {
+	total := 0
+	for _, n := range stats.BatchRetries {
+		total += n
+	}
+	return total
+}
+
+// classCounts formats counts per error class, e.g. "deadlock 3, timeout 1"
+func classCounts(counts map[string]int) string {
+	classes := make([]string, 0, len(counts))
+	for class, n := range counts {
+		classes = append(classes, fmt.Sprintf("%s %d", class, n))
+	}
+	sort.Strings(classes)
+	return strings.Join(classes, ", ")
+}
+
 // printSummary prints the performance report
 func printSummary(inserted, updated, ignored int, batchSize int, stats *processor.ProcessingStats, elapsed time.Duration, numWorkers, maxConnections, maxAllowedPacket int) {
 	// Keep the printing logic minimal here — same formatting as before
@@ -201,19 +823,73 @@ func fmtPrintReport(inserted, updated, ignored int, batchSize int, stats *proces
 	fmt.Println("SYNCHRONIZATION PERFORMANCE REPORT")
 	fmt.Println(strings.Repeat(".", 20))
 
+	if stats.RunID != "" {
+		fmt.Printf("Run ID: %s\n", stats.RunID)
+		if len(stats.RetryChain) > 0 {
+			fmt.Printf("Recovered after %d failed attempt(s): %s\n", len(stats.RetryChain), strings.Join(stats.RetryChain, ", "))
+		}
+		fmt.Println()
+	}
+
 	// Database Configuration
 	fmt.Println("DATABASE CONFIGURATION:")
 	fmt.Printf("  MySQL max_connections: \033[1;32m%d\033[0m\n", maxConnections)
 	fmt.Printf("  MySQL max_allowed_packet: \033[1;32m%d MB\033[0m\n", maxAllowedPacket/(1024*1024))
 	fmt.Printf("  Worker pool size: \033[1;32m%d workers\033[0m\n", numWorkers)
+	if procs := runtime.GOMAXPROCS(0); procs < runtime.NumCPU() {
+		fmt.Printf("  CPUs used: \033[1;33m%d of %d\033[0m (MAX_PROCS)\n", procs, runtime.NumCPU())
+	}
 	fmt.Printf("  Batch size: \033[1;32m%d rows\033[0m\n", batchSize)
+	if stats.QuantityOnly {
+		fmt.Println("  Sync mode: \033[1;33mquantity only\033[0m (prices not computed)")
+	}
+	if len(stats.Scope) > 0 {
+		fmt.Printf("  Scope: \033[1;33m%s\033[0m (other parts not synced)\n", strings.Join(stats.Scope, ", "))
+	}
+	if stats.RowLimit > 0 {
+		fmt.Printf("  Row limit: \033[1;33m%d source rows\033[0m (read stopped, no soft delete or watermark)\n", stats.RowLimit)
+	}
+	if stats.HashPreload {
+		fmt.Println("  MySQL preload: \033[1;32mkey + hash\033[0m (changed columns and price changes not tracked)")
+	}
+	if stats.UpsertWrites {
+		fmt.Println("  Write path: \033[1;32mmulti-row upsert\033[0m (inserts and updates, UPSERT_WRITES)")
+	} else if stats.BatchedUpserts {
+		fmt.Println("  Update path: \033[1;32mmulti-row upsert\033[0m")
+	} else if !stats.FeatureFlags.Enabled(flags.BatchedUpserts, true) {
+		fmt.Println("  Update path: \033[1;33mrow by row\033[0m (switched off by feature flag)")
+	} else if !stats.QuantityOnly {
+		fmt.Println("  Update path: \033[1;33mrow by row\033[0m (TB_ESTOQUE key is not unique)")
+	}
+	if len(stats.FeatureFlags) > 0 {
+		fmt.Printf("  Feature flags: %s\n", stats.FeatureFlags)
+	}
+	if stats.FirebirdIsolation != "" {
+		if stats.FirebirdTransaction > 0 {
+			fmt.Printf("  Firebird read: %s transaction %d\n", stats.FirebirdIsolation, stats.FirebirdTransaction)
+		} else {
+			fmt.Printf("  Firebird read: %s transaction\n", stats.FirebirdIsolation)
+		}
+	}
 
 	// Performance Metrics
 	fmt.Println("\nPERFORMANCE METRICS:")
 	fmt.Printf("  Data loading time: \033[1;36m%s\033[0m\n", stats.LoadTime.Round(time.Millisecond))
 	fmt.Printf("  Query execution time: \033[1;36m%s\033[0m\n", stats.QueryTime.Round(time.Millisecond))
+	if stats.SourceChunks > 0 {
+		fmt.Printf("  Firebird key ranges read: %d\n", stats.SourceChunks)
+	}
+	if stats.SourceReaders > 0 {
+		fmt.Printf("  Firebird readers: %d\n", stats.SourceReaders)
+	}
 	fmt.Printf("  Processing time: \033[1;36m%s\033[0m\n", stats.ProcessingTime.Round(time.Millisecond))
 	fmt.Printf("  Procedure time: \033[1;36m%s\033[0m\n", stats.ProcedureTime.Round(time.Millisecond))
+	if stats.ProcedureBatches > 0 {
+		fmt.Printf("  Changed IDs procedure: %d IDs in %d calls\n", stats.ChangedIDs, stats.ProcedureBatches)
+	}
+	if stats.HooksExecuted > 0 {
+		fmt.Printf("  Post-sync hooks executed: %d\n", stats.HooksExecuted)
+	}
 	fmt.Printf("  Total elapsed time: \033[1;36m%s\033[0m\n", elapsed.Round(time.Millisecond))
 
 	// Throughput
@@ -229,6 +905,136 @@ func fmtPrintReport(inserted, updated, ignored int, batchSize int, stats *proces
 	fmt.Printf("  Rows inserted: \033[1;32m%d\033[0m\n", inserted)
 	fmt.Printf("  Rows updated: \033[1;33m%d\033[0m\n", updated)
 	fmt.Printf("  Rows ignored: \033[1;34m%d\033[0m\n", ignored)
+	if stats.ProtectedSkipped > 0 {
+		fmt.Printf("  Protected price updates skipped: \033[1;34m%d\033[0m\n", stats.ProtectedSkipped)
+	}
+	if stats.ReservationAdjusted > 0 {
+		fmt.Printf("  Quantities adjusted for reservations: \033[1;34m%d\033[0m (%.2f units reserved)\n", stats.ReservationAdjusted, stats.ReservedQuantity)
+	}
+	if stats.NonPositiveStock > 0 {
+		fmt.Printf("  Zero/negative stock rows handled by policy: \033[1;34m%d\033[0m (skipped: %d)\n", stats.NonPositiveStock, stats.StockSkipped)
+	}
+	if c := stats.Categories; c.Inserted+c.Updated+c.Stale > 0 {
+		fmt.Printf("  Categories: \033[1;32m%d inserted\033[0m, \033[1;33m%d updated\033[0m, %d no longer in Firebird (kept)\n", c.Inserted, c.Updated, c.Stale)
+	}
+	if w := stats.Warehouses; w.Inserted+w.Updated+w.Deleted+w.Orphans > 0 {
+		fmt.Printf("  Warehouse rows: \033[1;32m%d inserted\033[0m, \033[1;33m%d updated\033[0m, \033[1;31m%d deleted\033[0m, %d orphans skipped\n", w.Inserted, w.Updated, w.Deleted, w.Orphans)
+	}
+	if c := stats.Catalog; c != nil {
+		fmt.Printf("  Catalog codes checked: %d (\033[1;33m%d missing from catalog\033[0m, \033[1;33m%d catalog orphans\033[0m, \033[1;32m%d created\033[0m)\n", c.Checked, c.SourceOrphans, c.CatalogOrphans, c.Created)
+	}
+	if len(stats.StatusCounts) > 0 || stats.UnmappedStatus > 0 {
+		statuses := make([]string, 0, len(stats.StatusCounts))
+		for status := range stats.StatusCounts {
+			statuses = append(statuses, status)
+		}
+		sort.Strings(statuses)
+		for _, status := range statuses {
+			fmt.Printf("  Status %s: \033[1;32m%d\033[0m\n", status, stats.StatusCounts[status])
+		}
+		if stats.UnmappedStatus > 0 {
+			fmt.Printf("  Rows skipped with unmapped status: \033[1;33m%d\033[0m\n", stats.UnmappedStatus)
+		}
+	}
+	if stats.Filtered > 0 {
+		fmt.Printf("  Rows left out by ROW_FILTERS: %d\n", stats.Filtered)
+	}
+	if stats.SoftDeleted+stats.Reactivated > 0 {
+		fmt.Printf("  Rows missing from Firebird marked deleted: \033[1;31m%d\033[0m, reactivated: \033[1;32m%d\033[0m\n", stats.SoftDeleted, stats.Reactivated)
+	}
+	if er := stats.ExchangeRate; er != nil {
+		if er.Rate > 0 {
+			fmt.Printf("  Exchange rate: %.4f BRL/USD (%s), PRC_DOLAR derived for %d rows\n", er.Rate, er.Source, er.Converted)
+		} else {
+			fmt.Printf("  Exchange rate: \033[1;33munavailable\033[0m, PRC_DOLAR without an indexer value written per NULL_POLICIES (0 by default)\n")
+		}
+	}
+	if len(stats.PricingRules) > 0 {
+		rules := maps.Clone(stats.PricingRules)
+		if n, ok := rules[""]; ok {
+			delete(rules, "")
+			rules["global margins"] = n
+		}
+		fmt.Printf("  Rows priced by rule: %s\n", classCounts(rules))
+	}
+	if stats.Incremental {
+		if stats.Since.IsZero() && stats.Mode == config.SyncReconcile {
+			fmt.Printf("  Incremental: full reconciliation read, watermark now %s\n", stats.Watermark.Format(time.RFC3339))
+		} else if stats.Since.IsZero() {
+			fmt.Printf("  Incremental: full read (no watermark yet), watermark now %s\n", stats.Watermark.Format(time.RFC3339))
+		} else {
+			fmt.Printf("  Incremental: rows modified since %s\n", stats.Since.Format(time.RFC3339))
+		}
+	}
+	if stats.NewDeferred > 0 {
+		fmt.Printf("  New products left to the next full sync: \033[1;33m%d\033[0m\n", stats.NewDeferred)
+	}
+	if stats.ExpressionErrors > 0 {
+		fmt.Printf("  Rows with failed expressions (extra columns, transforms): \033[1;33m%d\033[0m\n", stats.ExpressionErrors)
+	}
+	for _, t := range stats.Tables {
+		fmt.Printf("  Table %s -> %s: %d rows, \033[1;32m%d inserted\033[0m, \033[1;33m%d updated\033[0m, %d unchanged (%.2fs)\n", t.Name, t.Target, t.Rows, t.Inserted, t.Updated, t.Ignored, t.Duration.Seconds())
+		if t.NullKeys > 0 {
+			fmt.Printf("    Rows skipped with NULL key: \033[1;33m%d\033[0m\n", t.NullKeys)
+		}
+	}
+	if c := stats.Customers; c != nil {
+		fmt.Printf("  Customers -> %s: %d rows, \033[1;32m%d inserted\033[0m, \033[1;33m%d updated\033[0m, %d unchanged (%.2fs)\n", c.Target, c.Rows, c.Inserted, c.Updated, c.Ignored, c.Duration.Seconds())
+		if c.NullKeys > 0 {
+			fmt.Printf("    Rows skipped with NULL key: \033[1;33m%d\033[0m\n", c.NullKeys)
+		}
+	}
+	if rs := stats.Reverse; rs != nil {
+		fmt.Printf("  Pushed back to Firebird: %d rows, \033[1;32m%d pushed\033[0m, %d unchanged, %d missing from Firebird (%.2fs)\n", rs.Rows, rs.Pushed, rs.Unchanged, rs.Missing, rs.Duration.Seconds())
+		if n := len(rs.Conflicts); n > 0 {
+			fmt.Printf("    Conflicts: \033[1;33m%d\033[0m (%d overwritten) %v\n", n, rs.Overwritten, rs.Conflicts[:min(n, conflictReportLimit)])
+		}
+	}
+	if m := stats.Movements; m != nil {
+		fmt.Printf("  Stock movements: \033[1;32m%d inserted\033[0m, last ID %d (%.2fs)\n", m.Inserted, m.Last, m.Duration.Seconds())
+	}
+	if o := stats.Orders; o != nil {
+		fmt.Printf("  Web orders exported to Firebird: \033[1;32m%d\033[0m (%d items) of %d pending, %d recovered, %d without items\n", o.Exported, o.Items, o.Pending, o.Recovered, o.Empty)
+		if n := len(o.Refused); n > 0 {
+			fmt.Printf("    Refused by Firebird: \033[1;31m%d\033[0m %v\n", n, o.Refused[:min(n, conflictReportLimit)])
+		}
+	}
+	if bs := stats.Blobs; bs != nil {
+		fmt.Printf("  BLOBs: %d rows, \033[1;32m%d written\033[0m (%.1f MB), %d unchanged, %d missing from MySQL (%.2fs)\n", bs.Rows, bs.Written, float64(bs.Bytes)/(1<<20), bs.Unchanged, bs.Missing, bs.Duration.Seconds())
+		if n := len(bs.Oversized); n > 0 {
+			fmt.Printf("    Over BLOB_MAX_BYTES: \033[1;33m%d\033[0m %v\n", n, bs.Oversized[:min(n, conflictReportLimit)])
+		}
+	}
+	if sc := stats.SpotChecks; sc != nil {
+		printSpotChecks(sc)
+	}
+	if stats.PriceHistoryRows > 0 {
+		fmt.Printf("  Price history rows: \033[1;32m%d\033[0m\n", stats.PriceHistoryRows)
+	}
+	if stats.AuditRows > 0 {
+		fmt.Printf("  Audit rows: \033[1;32m%d\033[0m\n", stats.AuditRows)
+	}
+	if stats.DiffReport != "" {
+		fmt.Printf("  Diff report: %d rows in %s\n", stats.DiffReportRows, stats.DiffReport)
+	}
+	if len(stats.BatchRetries) > 0 {
+		fmt.Printf("  Batch retries: \033[1;33m%d\033[0m (%s)\n", batchRetries(stats), classCounts(stats.BatchRetries))
+	}
+	if len(stats.Errors) > 0 {
+		fmt.Printf("  Errors by class: \033[1;33m%s\033[0m\n", classCounts(stats.Errors))
+	}
+	if n := len(stats.RejectedRows); n > 0 {
+		fmt.Printf("  Rows rejected by MySQL and left out: \033[1;31m%d\033[0m %v\n", n, stats.RejectedRows[:min(n, rejectedReportLimit)])
+	}
+	if stats.BatchRetriesExhausted > 0 {
+		fmt.Printf("  Batches failed after retries: \033[1;31m%d\033[0m\n", stats.BatchRetriesExhausted)
+	}
+	if stats.UnwrittenRows > 0 {
+		fmt.Printf("  Rows of failed batches not written: \033[1;31m%d\033[0m\n", stats.UnwrittenRows)
+	}
+	if stats.UnscannedRows > 0 {
+		fmt.Printf("  Firebird rows that failed to scan: \033[1;31m%d\033[0m\n", stats.UnscannedRows)
+	}
 
 	// Memory usage
 	var m runtime.MemStats
@@ -242,35 +1048,63 @@ func fmtPrintReport(inserted, updated, ignored int, batchSize int, stats *proces
 		fmt.Printf("  GC pause: \033[1;36m%.2fms\033[0m\n", float64(m.PauseTotalNs)/float64(m.NumGC)/1000000)
 	}
 
+	printBusinessMetrics(stats)
+
 	fmt.Println(strings.Repeat("-", 20))
 
 	// Performance recommendations
 	fmt.Println("PERFORMANCE RECOMMENDATIONS:")
-	recommendationCount := 0
-
-	if stats.LoadTime > 2*time.Second {
-		fmt.Println(redBold + "  ⚡ Consider adding indexes to MySQL TB_ESTOQUE table" + reset)
-		recommendationCount++
-	}
-	if stats.ProcessingTime > 5*time.Second {
-		fmt.Println(redBold + "  ⚡ Consider increasing MySQL max_connections" + reset)
-		recommendationCount++
+	advice := recommendations(updated, totalRows, stats, m.NumGC)
+	for _, a := range advice {
+		fmt.Println(redBold + "  ⚡ " + a + reset)
 	}
-	if float64(updated)/float64(totalRows) > 0.7 {
-		fmt.Println(redBold + "  ⚡ High update rate - consider optimizing comparison logic" + reset)
-		recommendationCount++
-	}
-	if m.NumGC > 10 {
-		fmt.Println(redBold + "  ⚡ High GC pressure - consider reducing memory allocation" + reset)
-		recommendationCount++
-	}
-
-	if recommendationCount == 0 {
+	if len(advice) == 0 {
 		fmt.Println(greenBold + "  ✅ 0 issues found – running at optimal performance" + reset)
 	} else {
-		fmt.Printf(redBold+"  ❌ %d issues found – please review the recommendations above"+reset+"\n", recommendationCount)
+		fmt.Printf(redBold+"  ❌ %d issues found – please review the recommendations above"+reset+"\n", len(advice))
 	}
 
 	fmt.Println(strings.Repeat("-", 20))
 	fmt.Printf("Synchronization completed successfully in %s!", elapsed.Round(time.Millisecond))
 }
+
+// printBusinessMetrics prints inventory value, margin, price change and price constraint metrics
+func printBusinessMetrics(stats *processor.ProcessingStats) {
+	a := &stats.Analytics
+	fmt.Println("\nBUSINESS METRICS:")
+	if stats.QuantityOnly {
+		fmt.Println("  Price metrics are only computed by full syncs")
+	} else {
+		fmt.Printf("  Inventory value at cost: \033[1;32mR$ %s\033[0m\n", a.InventoryCost)
+		fmt.Printf("  Inventory value at sale price: \033[1;32mR$ %s\033[0m\n", a.InventorySale)
+		fmt.Printf("  Average margin: \033[1;32m%.2f%%\033[0m\n", a.AverageMargin())
+		fmt.Printf("  Items with zero cost: \033[1;33m%d\033[0m\n", a.ZeroCost)
+		fmt.Printf("  Items with zero sale price: \033[1;33m%d\033[0m\n", a.ZeroPrice)
+	}
+
+	if len(a.TopChanges) > 0 {
+		fmt.Printf("  Top %d price changes:\n", len(a.TopChanges))
+		for _, c := range a.TopChanges {
+			fmt.Printf("    %d %-40.40s R$ %s → R$ %s\n", c.IDEstoque, c.Descricao, c.OldPrice, c.NewPrice)
+		}
+	}
+
+	if ch := stats.Changes; ch.Updates > 0 {
+		fmt.Println("  Columns driving updates:")
+		for _, c := range ch.TopColumns(changeBreakdownLimit) {
+			fmt.Printf("    %-24s \033[1;33m%5.1f%%\033[0m (%d)\n", c.Name, c.Percent, c.Count)
+		}
+		fmt.Println("  Change reasons:")
+		for _, c := range ch.TopReasons(changeBreakdownLimit) {
+			fmt.Printf("    %-48s \033[1;33m%5.1f%%\033[0m (%d)\n", c.Name, c.Percent, c.Count)
+		}
+	}
+
+	if c := stats.Constraints; c.Clamped+c.Flagged > 0 {
+		fmt.Println("  Price constraint violations:")
+		fmt.Printf("    Below minimum margin: \033[1;33m%d\033[0m\n", c.BelowMinMargin)
+		fmt.Printf("    Below category floor: \033[1;33m%d\033[0m\n", c.BelowFloor)
+		fmt.Printf("    Exceeded maximum drop: \033[1;33m%d\033[0m\n", c.ExceededDrop)
+		fmt.Printf("    Clamped: \033[1;33m%d\033[0m, flagged: \033[1;33m%d\033[0m\n", c.Clamped, c.Flagged)
+	}
+}
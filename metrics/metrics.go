@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/logger"
+	"github.com/waldirborbajr/sync/run"
+)
+
+// Supported push formats
+const (
+	FormatPrometheus = "prometheus" // Prometheus Pushgateway text exposition format
+	FormatInflux     = "influx"     // InfluxDB line protocol
+)
+
+// Sample is a single gauge value reported at the end of a run
+type Sample struct {
+	Name  string
+	Help  string
+	Value float64
+}
+
+// Push delivers samples to the configured Pushgateway or InfluxDB endpoint.
+// Short-lived cron runs cannot be scraped, so every run pushes its final values.
+func Push(ctx context.Context, cfg config.Config, samples []Sample) error {
+	if cfg.MetricsPushURL == "" {
+		return nil
+	}
+	log := logger.GetLogger()
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	instance, _ := os.Hostname()
+	machineID := run.MachineID()
+
+	var (
+		method, target string
+		body           []byte
+	)
+	switch cfg.MetricsPushFormat {
+	case FormatInflux:
+		method, target = http.MethodPost, cfg.MetricsPushURL
+		body = InfluxLines(cfg.MetricsJob, instance, machineID, samples, time.Now())
+	default:
+		// PUT replaces the whole group, so metrics that disappeared are not left stale
+		method = http.MethodPut
+		target = strings.TrimRight(cfg.MetricsPushURL, "/") + "/metrics/job/" + url.PathEscape(cfg.MetricsJob)
+		if instance != "" {
+			target += "/instance/" + url.PathEscape(instance)
+		}
+		if machineID != "" {
+			target += "/machine_id/" + url.PathEscape(machineID)
+		}
+		body = PrometheusText(samples)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating metrics request: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if cfg.MetricsPushToken != "" {
+		req.Header.Set("Authorization", "Token "+cfg.MetricsPushToken)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error pushing metrics: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code %d pushing metrics", resp.StatusCode)
+	}
+
+	log.Debug().Str("format", cfg.MetricsPushFormat).Int("samples", len(samples)).Msg("Metrics pushed")
+	return nil
+}
+
+// PrometheusText renders samples as gauges in the text exposition format
+func PrometheusText(samples []Sample) []byte {
+	var b bytes.Buffer
+	for _, s := range samples {
+		if s.Help != "" {
+			fmt.Fprintf(&b, "# HELP %s %s\n", s.Name, s.Help)
+		}
+		fmt.Fprintf(&b, "# TYPE %s gauge\n", s.Name)
+		fmt.Fprintf(&b, "%s %s\n", s.Name, formatValue(s.Value))
+	}
+	return b.Bytes()
+}
+
+// InfluxLines renders samples as one line protocol point in the "sync" measurement
+func InfluxLines(job, instance, machineID string, samples []Sample, at time.Time) []byte {
+	var b bytes.Buffer
+	b.WriteString("sync,job=" + escapeTag(job))
+	if instance != "" {
+		b.WriteString(",instance=" + escapeTag(instance))
+	}
+	if machineID != "" {
+		b.WriteString(",machine_id=" + escapeTag(machineID))
+	}
+	for i, s := range samples {
+		if i == 0 {
+			b.WriteByte(' ')
+		} else {
+			b.WriteByte(',')
+		}
+		b.WriteString(escapeTag(s.Name) + "=" + formatValue(s.Value))
+	}
+	fmt.Fprintf(&b, " %d\n", at.UnixNano())
+	return b.Bytes()
+}
+
+// formatValue formats v with the shortest exact representation
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// escapeTag escapes the characters line protocol reserves in tag keys and values
+func escapeTag(s string) string {
+	return strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `).Replace(s)
+}
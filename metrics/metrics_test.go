@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPrometheusText(t *testing.T) {
+	samples := []Sample{
+		{Name: "sync_rows_inserted", Help: "Rows inserted", Value: 12},
+		{Name: "sync_run_duration_seconds", Value: 1.5},
+	}
+
+	want := "# HELP sync_rows_inserted Rows inserted\n" +
+		"# TYPE sync_rows_inserted gauge\n" +
+		"sync_rows_inserted 12\n" +
+		"# TYPE sync_run_duration_seconds gauge\n" +
+		"sync_run_duration_seconds 1.5\n"
+
+	if got := string(PrometheusText(samples)); got != want {
+		t.Errorf("PrometheusText() = %q; want %q", got, want)
+	}
+}
+
+func TestInfluxLines(t *testing.T) {
+	samples := []Sample{
+		{Name: "rows_inserted", Value: 12},
+		{Name: "success", Value: 1},
+	}
+	at := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		job, instance, machineID string
+		want                     string
+	}{
+		{"sync", "host1", "", "sync,job=sync,instance=host1 rows_inserted=12,success=1 1700000000000000000\n"},
+		{"my job", "", "", "sync,job=my\\ job rows_inserted=12,success=1 1700000000000000000\n"},
+		{"sync", "host1", "ab12", "sync,job=sync,instance=host1,machine_id=ab12 rows_inserted=12,success=1 1700000000000000000\n"},
+	}
+
+	for _, tt := range tests {
+		if got := string(InfluxLines(tt.job, tt.instance, tt.machineID, samples, at)); got != tt.want {
+			t.Errorf("InfluxLines(%q, %q, %q) = %q; want %q", tt.job, tt.instance, tt.machineID, got, tt.want)
+		}
+	}
+}
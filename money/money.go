@@ -0,0 +1,177 @@
+package money
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Cents represents a monetary value as an integer number of cents.
+// Prices are carried as Cents from scan through calculation, comparison and
+// write, and only converted at the SQL boundary, so no float rounding drift
+// can cause spurious updates.
+type Cents int64
+
+// Parse converts a decimal string ("1234.56", "-0.5", "12") into Cents.
+// Digits beyond the second decimal place are rounded half away from zero.
+func Parse(s string) (Cents, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty monetary value")
+	}
+
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, fmt.Errorf("invalid monetary value %q", s)
+	}
+	return fromRat(r), nil
+}
+
+// FromFloat converts a float64 into Cents using its shortest decimal
+// representation, so 1.005 becomes 101 cents instead of 100.
+func FromFloat(f float64) Cents {
+	c, err := Parse(strconv.FormatFloat(f, 'f', -1, 64))
+	if err != nil {
+		return 0
+	}
+	return c
+}
+
+// Float64 returns the value in currency units. Use only for reporting.
+func (c Cents) Float64() float64 {
+	return float64(c) / 100
+}
+
+// String formats the value with exactly two decimal places ("-12.05").
+func (c Cents) String() string {
+	sign := ""
+	v := int64(c)
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, v/100, v%100)
+}
+
+// Value implements driver.Valuer. The value is bound as an exact decimal
+// string, which MySQL DECIMAL columns and SQLite REAL columns both accept.
+func (c Cents) Value() (driver.Value, error) {
+	return c.String(), nil
+}
+
+// Scale returns c multiplied by (1 + p/100) for every percentage in percents
+// and divided by divisor, rounded half away from zero once at the end.
+// It is used for markups and installment prices, e.g.
+// Scale(cost, 3, lucro, parc3x) is the value of each of three installments.
+func Scale(c Cents, divisor int64, percents ...float64) Cents {
+	if divisor == 0 {
+		return 0
+	}
+
+	r := new(big.Rat).SetInt64(int64(c))
+	hundred := big.NewRat(100, 1)
+	for _, p := range percents {
+		pr, ok := new(big.Rat).SetString(strconv.FormatFloat(p, 'f', -1, 64))
+		if !ok {
+			continue
+		}
+		factor := new(big.Rat).Quo(pr, hundred)
+		factor.Add(factor, big.NewRat(1, 1))
+		r.Mul(r, factor)
+	}
+	r.Quo(r, big.NewRat(divisor, 1))
+
+	// r is expressed in cents already; round to the nearest integer.
+	return roundRat(r)
+}
+
+// Convert returns c divided by the exchange rate (units of c per unit of the
+// target currency), rounded half away from zero once; 0 for a rate <= 0.
+// Convert(custo, 5.25) is the dollar value of a cost in reais at 5.25 BRL/USD.
+func Convert(c Cents, rate float64) Cents {
+	if rate <= 0 {
+		return 0
+	}
+	r, ok := new(big.Rat).SetString(strconv.FormatFloat(rate, 'f', -1, 64))
+	if !ok {
+		return 0
+	}
+	return roundRat(new(big.Rat).Quo(new(big.Rat).SetInt64(int64(c)), r))
+}
+
+// fromRat converts a value expressed in currency units into Cents.
+func fromRat(r *big.Rat) Cents {
+	return roundRat(new(big.Rat).Mul(r, big.NewRat(100, 1)))
+}
+
+// roundRat rounds r to the nearest integer, half away from zero.
+func roundRat(r *big.Rat) Cents {
+	num := new(big.Int).Abs(r.Num())
+	den := r.Denom()
+
+	q, m := new(big.Int).QuoRem(num, den, new(big.Int))
+	if m.Lsh(m, 1).Cmp(den) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	if r.Sign() < 0 {
+		q.Neg(q)
+	}
+	return Cents(q.Int64())
+}
+
+// NullCents is a nullable Cents usable as a sql.Scanner destination.
+type NullCents struct {
+	Cents Cents
+	Valid bool
+}
+
+// Scan implements sql.Scanner. It accepts the representations the drivers in
+// use return for numeric columns: float64 and int64 (SQLite), []byte and
+// string (MySQL DECIMAL) and decimal types implementing fmt.Stringer
+// (Firebird NUMERIC).
+func (n *NullCents) Scan(src interface{}) error {
+	if src == nil {
+		n.Cents, n.Valid = 0, false
+		return nil
+	}
+
+	var err error
+	switch v := src.(type) {
+	case int64:
+		n.Cents = Cents(v * 100)
+	case float64:
+		n.Cents = FromFloat(v)
+	case []byte:
+		n.Cents, err = Parse(string(v))
+	case string:
+		n.Cents, err = Parse(v)
+	case fmt.Stringer:
+		n.Cents, err = Parse(v.String())
+	default:
+		return fmt.Errorf("cannot scan %T into money.NullCents", src)
+	}
+	if err != nil {
+		n.Cents, n.Valid = 0, false
+		return err
+	}
+	n.Valid = true
+	return nil
+}
+
+// Value implements driver.Valuer.
+func (n NullCents) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return n.Cents.Value()
+}
+
+// OrZero returns the value, or zero when NULL.
+func (n NullCents) OrZero() Cents {
+	if !n.Valid {
+		return 0
+	}
+	return n.Cents
+}
@@ -0,0 +1,149 @@
+package money
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Cents
+	}{
+		{"0", 0},
+		{"12", 1200},
+		{"12.3", 1230},
+		{"1234.56", 123456},
+		{"-0.05", -5},
+		{"1.005", 101},
+		{"1.004", 100},
+		{"-1.005", -101},
+		{" 7.50 ", 750},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.input)
+		if err != nil {
+			t.Errorf("Parse(%q) returned error: %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Parse(%q) = %d; want %d", tt.input, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", "abc", "1,50"} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("Parse(%q) expected error", bad)
+		}
+	}
+}
+
+func TestFromFloat(t *testing.T) {
+	tests := []struct {
+		input float64
+		want  Cents
+	}{
+		{0, 0},
+		{1.005, 101},
+		{0.1 + 0.2, 30},
+		{2850.00, 285000},
+		{-3.335, -334},
+	}
+
+	for _, tt := range tests {
+		if got := FromFloat(tt.input); got != tt.want {
+			t.Errorf("FromFloat(%v) = %d; want %d", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		input Cents
+		want  string
+	}{
+		{0, "0.00"},
+		{5, "0.05"},
+		{-5, "-0.05"},
+		{123456, "1234.56"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.input.String(); got != tt.want {
+			t.Errorf("Cents(%d).String() = %q; want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestScale(t *testing.T) {
+	tests := []struct {
+		cost     Cents
+		divisor  int64
+		percents []float64
+		want     Cents
+	}{
+		{10000, 1, []float64{40}, 14000},
+		{10000, 3, []float64{40, 5}, 4900},
+		{10000, 6, []float64{40, 10}, 2567},
+		{10000, 10, []float64{40, 15}, 1610},
+		{285000, 1, []float64{40}, 399000},
+		{1, 3, nil, 0},
+		{2, 3, nil, 1},
+		{100, 0, nil, 0},
+	}
+
+	for _, tt := range tests {
+		if got := Scale(tt.cost, tt.divisor, tt.percents...); got != tt.want {
+			t.Errorf("Scale(%d, %d, %v) = %d; want %d", tt.cost, tt.divisor, tt.percents, got, tt.want)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		c    Cents
+		rate float64
+		want Cents
+	}{
+		{52500, 5.25, 10000},
+		{10000, 5.4321, 1841},
+		{1, 3, 0},
+		{2, 3, 1},
+		{10000, 0, 0},
+		{10000, -1, 0},
+	}
+
+	for _, tt := range tests {
+		if got := Convert(tt.c, tt.rate); got != tt.want {
+			t.Errorf("Convert(%d, %v) = %d; want %d", tt.c, tt.rate, got, tt.want)
+		}
+	}
+}
+
+type stringer string
+
+func (s stringer) String() string { return string(s) }
+
+func TestNullCentsScan(t *testing.T) {
+	tests := []struct {
+		src       interface{}
+		want      Cents
+		wantValid bool
+	}{
+		{nil, 0, false},
+		{int64(12), 1200, true},
+		{float64(99.99), 9999, true},
+		{[]byte("10.50"), 1050, true},
+		{"0.01", 1, true},
+		{stringer("123.456"), 12346, true},
+	}
+
+	for _, tt := range tests {
+		var n NullCents
+		if err := n.Scan(tt.src); err != nil {
+			t.Errorf("Scan(%v) returned error: %v", tt.src, err)
+			continue
+		}
+		if n.Valid != tt.wantValid || n.Cents != tt.want {
+			t.Errorf("Scan(%v) = (%d, %v); want (%d, %v)", tt.src, n.Cents, n.Valid, tt.want, tt.wantValid)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+// Package notify sends run outcomes to the chat apps store managers follow,
+// Telegram and WhatsApp, each channel with its own severity filter and
+// message template.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Severities, from the lowest
+const (
+	SeverityInfo    = "info"    // Run finished normally
+	SeverityWarning = "warning" // Run finished but needed retries or a recovery
+	SeverityError   = "error"   // Run failed
+)
+
+// Severities lists the severities from the lowest
+var Severities = []string{SeverityInfo, SeverityWarning, SeverityError}
+
+// DefaultTemplate is used by channels without a template of their own
+const DefaultTemplate = "[{{.Severity}}] {{.Title}}\n{{.Text}}"
+
+// sendTimeout bounds each delivery, so an unreachable gateway never holds up a run
+const sendTimeout = 10 * time.Second
+
+// Event is the outcome of a run; its fields are available to templates
+type Event struct {
+	Severity string
+	Title    string // "Sync failed on pdv-01"
+	Text     string // Counts or the error
+	Host     string
+	Job      string // Daemon job name, empty for one-off runs
+	RunID    string
+	At       time.Time
+}
+
+// Sender delivers a message through a chat service. Telegram, TwilioWhatsApp
+// and WebhookWhatsApp are the built-in ones.
+type Sender interface {
+	Send(ctx context.Context, text string) error
+}
+
+// Channel is a Sender with the events it receives and how they are worded
+type Channel struct {
+	Name        string
+	MinSeverity string // Events below it are not sent
+	Template    *template.Template
+	Sender      Sender
+}
+
+// Accepts reports whether an event of severity is sent through c
+func (c Channel) Accepts(severity string) bool {
+	return slices.Index(Severities, severity) >= slices.Index(Severities, c.MinSeverity)
+}
+
+// ParseSeverity checks s is one of Severities
+func ParseSeverity(s string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if !slices.Contains(Severities, s) {
+		return "", fmt.Errorf("unknown severity %q: expected %s", s, strings.Join(Severities, ", "))
+	}
+	return s, nil
+}
+
+// ParseTemplate parses a message template over Event, DefaultTemplate when text is empty
+func ParseTemplate(name, text string) (*template.Template, error) {
+	if text == "" {
+		text = DefaultTemplate
+	}
+	t, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s template: %w", name, err)
+	}
+	// Catch references to unknown fields now rather than on the first failure
+	if err := t.Execute(io.Discard, Event{}); err != nil {
+		return nil, fmt.Errorf("invalid %s template: %w", name, err)
+	}
+	return t, nil
+}
+
+// Send delivers e through the channels accepting its severity. A channel
+// failing does not stop the others; their errors are returned together.
+func Send(ctx context.Context, channels []Channel, e Event) error {
+	var errs []error
+	for _, c := range channels {
+		if !c.Accepts(e.Severity) {
+			continue
+		}
+		var text bytes.Buffer
+		if err := c.Template.Execute(&text, e); err != nil {
+			errs = append(errs, fmt.Errorf("%s: error rendering message: %w", c.Name, err))
+			continue
+		}
+		sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+		err := c.Sender.Send(sendCtx, text.String())
+		cancel()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", c.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// post sends body to url and checks the response status
+func post(ctx context.Context, url, contentType string, body []byte, auth func(*http.Request)) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating notification request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if auth != nil {
+		auth(req)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending notification: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code %d sending notification", resp.StatusCode)
+	}
+	return nil
+}
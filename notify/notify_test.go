@@ -0,0 +1,88 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestChannelAccepts(t *testing.T) {
+	tests := []struct {
+		min, severity string
+		want          bool
+	}{
+		{SeverityInfo, SeverityInfo, true},
+		{SeverityInfo, SeverityError, true},
+		{SeverityWarning, SeverityInfo, false},
+		{SeverityWarning, SeverityWarning, true},
+		{SeverityError, SeverityWarning, false},
+		{SeverityError, SeverityError, true},
+	}
+	for _, tt := range tests {
+		if got := (Channel{MinSeverity: tt.min}).Accepts(tt.severity); got != tt.want {
+			t.Errorf("Channel{MinSeverity: %q}.Accepts(%q) = %v; want %v", tt.min, tt.severity, got, tt.want)
+		}
+	}
+}
+
+func TestParseTemplate(t *testing.T) {
+	for _, text := range []string{"", "{{.Title}} {{.Host}} {{.Job}} {{.RunID}} {{.At.Format \"15:04\"}}"} {
+		if _, err := ParseTemplate("T", text); err != nil {
+			t.Errorf("ParseTemplate(%q) returned error: %v", text, err)
+		}
+	}
+	for _, bad := range []string{"{{.Title", "{{.Store}}"} {
+		if _, err := ParseTemplate("T", bad); err == nil {
+			t.Errorf("ParseTemplate(%q) expected error", bad)
+		}
+	}
+}
+
+func TestSend(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/sendMessage"):
+			var m map[string]string
+			_ = json.Unmarshal(body, &m)
+			got = append(got, r.URL.Path+" "+m["chat_id"]+" "+m["text"])
+		case strings.HasSuffix(r.URL.Path, "/Messages.json"):
+			form, _ := url.ParseQuery(string(body))
+			user, pass, _ := r.BasicAuth()
+			got = append(got, r.URL.Path+" "+user+":"+pass+" "+form.Get("To")+" "+form.Get("Body"))
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	tmpl, _ := ParseTemplate("T", "{{.Severity}}: {{.Title}}")
+	channels := []Channel{
+		{Name: "telegram", MinSeverity: SeverityInfo, Template: tmpl, Sender: Telegram{APIURL: srv.URL, Token: "T0K", ChatID: "-42"}},
+		{Name: "twilio", MinSeverity: SeverityError, Template: tmpl, Sender: TwilioWhatsApp{APIURL: srv.URL, AccountSID: "AC1", AuthToken: "secret", From: "+551100", To: "+551199"}},
+		{Name: "webhook", MinSeverity: SeverityError, Template: tmpl, Sender: WebhookWhatsApp{URL: srv.URL + "/gateway", To: "+551199"}},
+	}
+
+	if err := Send(context.Background(), channels, Event{Severity: SeverityInfo, Title: "ok"}); err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+	err := Send(context.Background(), channels, Event{Severity: SeverityError, Title: "down"})
+	if err == nil || !strings.Contains(err.Error(), "webhook: unexpected status code 502") {
+		t.Errorf("Send error = %v; want the webhook failure", err)
+	}
+
+	want := []string{
+		"/botT0K/sendMessage -42 info: ok",
+		"/botT0K/sendMessage -42 error: down",
+		"/2010-04-01/Accounts/AC1/Messages.json AC1:secret whatsapp:+551199 error: down",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("requests = %q; want %q", got, want)
+	}
+}
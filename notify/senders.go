@@ -0,0 +1,92 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultTelegramAPIURL is the Bot API used when no URL is configured
+const DefaultTelegramAPIURL = "https://api.telegram.org"
+
+// DefaultTwilioAPIURL is the Twilio REST API used when no URL is configured
+const DefaultTwilioAPIURL = "https://api.twilio.com"
+
+// Telegram sends messages to a chat through a bot
+type Telegram struct {
+	APIURL string // DefaultTelegramAPIURL when empty
+	Token  string // Bot token from @BotFather
+	ChatID string // Chat, group or channel the bot posts to
+}
+
+// Send posts text to the chat with sendMessage
+func (t Telegram) Send(ctx context.Context, text string) error {
+	base := t.APIURL
+	if base == "" {
+		base = DefaultTelegramAPIURL
+	}
+	body, err := json.Marshal(map[string]string{"chat_id": t.ChatID, "text": text})
+	if err != nil {
+		return fmt.Errorf("error encoding Telegram message: %w", err)
+	}
+	return post(ctx, strings.TrimRight(base, "/")+"/bot"+t.Token+"/sendMessage", "application/json", body, nil)
+}
+
+// TwilioWhatsApp sends WhatsApp messages through the Twilio Messages API
+type TwilioWhatsApp struct {
+	APIURL     string // DefaultTwilioAPIURL when empty
+	AccountSID string
+	AuthToken  string
+	From       string // Twilio WhatsApp sender number, +5511...
+	To         string // Recipient number, +5511...
+}
+
+// Send creates a Twilio message from From to To
+func (t TwilioWhatsApp) Send(ctx context.Context, text string) error {
+	base := t.APIURL
+	if base == "" {
+		base = DefaultTwilioAPIURL
+	}
+	form := url.Values{
+		"From": {whatsAppAddress(t.From)},
+		"To":   {whatsAppAddress(t.To)},
+		"Body": {text},
+	}
+	target := strings.TrimRight(base, "/") + "/2010-04-01/Accounts/" + url.PathEscape(t.AccountSID) + "/Messages.json"
+	return post(ctx, target, "application/x-www-form-urlencoded", []byte(form.Encode()), func(req *http.Request) {
+		req.SetBasicAuth(t.AccountSID, t.AuthToken)
+	})
+}
+
+// whatsAppAddress prefixes a phone number with Twilio's WhatsApp scheme
+func whatsAppAddress(number string) string {
+	if strings.HasPrefix(number, "whatsapp:") {
+		return number
+	}
+	return "whatsapp:" + number
+}
+
+// WebhookWhatsApp posts messages to a WhatsApp gateway (Z-API, Evolution
+// API, a self-hosted bridge...) as {"to", "from", "text"} JSON
+type WebhookWhatsApp struct {
+	URL   string
+	Token string // Sent as "Authorization: Bearer ..." when set
+	From  string
+	To    string
+}
+
+// Send posts text to the gateway
+func (w WebhookWhatsApp) Send(ctx context.Context, text string) error {
+	body, err := json.Marshal(map[string]string{"to": w.To, "from": w.From, "text": text})
+	if err != nil {
+		return fmt.Errorf("error encoding WhatsApp message: %w", err)
+	}
+	return post(ctx, w.URL, "application/json", body, func(req *http.Request) {
+		if w.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+w.Token)
+		}
+	})
+}
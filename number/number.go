@@ -0,0 +1,75 @@
+package number
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Format describes how decimal numbers are written, e.g. "1,234.56" or "1.234,56"
+type Format struct {
+	Decimal   string // Decimal separator: "." or ","
+	Thousands string // Thousands separator, empty when not used
+}
+
+// Default is the plain Go/SQL notation: "1234.56"
+var Default = Format{Decimal: "."}
+
+// canonical matches a number after separators were normalized
+var canonical = regexp.MustCompile(`^[+-]?[0-9]+(\.[0-9]+)?$`)
+
+// NewFormat validates the separators and returns the corresponding Format
+func NewFormat(decimal, thousands string) (Format, error) {
+	if decimal != "." && decimal != "," {
+		return Format{}, fmt.Errorf("invalid decimal separator %q: expected \".\" or \",\"", decimal)
+	}
+	switch thousands {
+	case "", ".", ",", " ", "'":
+	default:
+		return Format{}, fmt.Errorf("invalid thousands separator %q", thousands)
+	}
+	if thousands == decimal {
+		return Format{}, fmt.Errorf("decimal and thousands separators must differ")
+	}
+	return Format{Decimal: decimal, Thousands: thousands}, nil
+}
+
+// Normalize rewrites s in canonical "1234.56" form. A trailing "%" is
+// accepted for percentages. Thousands separators must group exactly three
+// digits, so "40.5" is not read as 405 when "." groups thousands. Anything
+// that is not a plain number in this format is rejected instead of being
+// partially parsed.
+func (f Format) Normalize(s string) (string, error) {
+	orig := s
+	s = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "%"))
+
+	intPart, fracPart, hasFrac := strings.Cut(s, f.Decimal)
+	if f.Thousands != "" && strings.Contains(intPart, f.Thousands) {
+		groups := strings.Split(strings.TrimLeft(intPart, "+-"), f.Thousands)
+		for i, g := range groups {
+			if (i == 0 && (len(g) == 0 || len(g) > 3)) || (i > 0 && len(g) != 3) {
+				return "", fmt.Errorf("invalid number %q: misplaced thousands separator %q", orig, f.Thousands)
+			}
+		}
+		intPart = strings.ReplaceAll(intPart, f.Thousands, "")
+	}
+
+	s = intPart
+	if hasFrac {
+		s += "." + fracPart
+	}
+	if !canonical.MatchString(s) {
+		return "", fmt.Errorf("invalid number %q for decimal separator %q", orig, f.Decimal)
+	}
+	return s, nil
+}
+
+// ParseFloat parses s written in this format
+func (f Format) ParseFloat(s string) (float64, error) {
+	n, err := f.Normalize(s)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(n, 64)
+}
@@ -0,0 +1,62 @@
+package number
+
+import "testing"
+
+func TestParseFloat(t *testing.T) {
+	br := Format{Decimal: ",", Thousands: "."}
+	us := Format{Decimal: ".", Thousands: ","}
+
+	tests := []struct {
+		format Format
+		input  string
+		want   float64
+	}{
+		{Default, "40", 40},
+		{Default, "40.5", 40.5},
+		{Default, " -3.25 ", -3.25},
+		{Default, "15%", 15},
+		{br, "1.234,56", 1234.56},
+		{br, "40,5", 40.5},
+		{br, "1.000", 1000},
+		{us, "1,234.56", 1234.56},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.format.ParseFloat(tt.input)
+		if err != nil {
+			t.Errorf("%+v.ParseFloat(%q) returned error: %v", tt.format, tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%+v.ParseFloat(%q) = %v; want %v", tt.format, tt.input, got, tt.want)
+		}
+	}
+
+	bad := []struct {
+		format Format
+		input  string
+	}{
+		{Default, "40,5"},
+		{Default, "1.234,56"},
+		{Default, ""},
+		{Default, "abc"},
+		{br, "40.5"},
+		{Default, "1e3"},
+	}
+	for _, tt := range bad {
+		if _, err := tt.format.ParseFloat(tt.input); err == nil {
+			t.Errorf("%+v.ParseFloat(%q) expected error", tt.format, tt.input)
+		}
+	}
+}
+
+func TestNewFormat(t *testing.T) {
+	if _, err := NewFormat(",", "."); err != nil {
+		t.Errorf("NewFormat(\",\", \".\") returned error: %v", err)
+	}
+	for _, tt := range [][2]string{{";", ""}, {",", ","}, {".", "x"}} {
+		if _, err := NewFormat(tt[0], tt[1]); err == nil {
+			t.Errorf("NewFormat(%q, %q) expected error", tt[0], tt[1])
+		}
+	}
+}
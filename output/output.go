@@ -0,0 +1,167 @@
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Supported output formats
+const (
+	FormatTable = "table"
+	FormatJSON  = "json"
+	FormatYAML  = "yaml"
+	FormatCSV   = "csv"
+)
+
+// ValidFormat reports whether format is a supported output format
+func ValidFormat(format string) bool {
+	switch format {
+	case FormatTable, FormatJSON, FormatYAML, FormatCSV:
+		return true
+	}
+	return false
+}
+
+// Render writes v in the requested format. JSON and YAML use the json/yaml
+// struct tags; tables are built from exported struct fields, labelled with
+// their json tag: a struct renders as FIELD/VALUE rows and a slice of structs
+// as one row per element. CSV holds the rows of the table.
+func Render(w io.Writer, format string, v any) error {
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case FormatYAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		return enc.Close()
+	case FormatTable, "":
+		return renderTable(w, v)
+	case FormatCSV:
+		return renderCSV(w, v)
+	}
+	return fmt.Errorf("unsupported output format %q (expected table, json, yaml or csv)", format)
+}
+
+// renderTable writes v as aligned columns
+func renderTable(w io.Writer, v any) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	header, rows := tableRows(v)
+	if header != nil {
+		fmt.Fprintln(tw, strings.ToUpper(strings.Join(header, "\t")))
+	}
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// renderCSV writes the rows of the table of v as comma-separated values
+func renderCSV(w io.Writer, v any) error {
+	cw := csv.NewWriter(w)
+	header, rows := tableRows(v)
+	if header != nil {
+		rows = append([][]string{header}, rows...)
+	}
+	return cw.WriteAll(rows)
+}
+
+// tableRows returns the cells of the table of v and, for a slice of structs,
+// the header naming its columns
+func tableRows(v any) (header []string, rows [][]string) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		if rv.Len() == 0 {
+			return nil, nil
+		}
+		elem := reflect.Indirect(rv.Index(0))
+		if elem.Kind() != reflect.Struct {
+			for i := 0; i < rv.Len(); i++ {
+				rows = append(rows, []string{formatCell(rv.Index(i))})
+			}
+			return nil, rows
+		}
+		header, _ = structFields(elem)
+		for i := 0; i < rv.Len(); i++ {
+			_, values := structFields(reflect.Indirect(rv.Index(i)))
+			rows = append(rows, values)
+		}
+	case reflect.Struct:
+		names, values := structFields(rv)
+		for i := range names {
+			rows = append(rows, []string{names[i], values[i]})
+		}
+	default:
+		rows = append(rows, []string{formatCell(rv)})
+	}
+	return header, rows
+}
+
+// structFields returns the labels and formatted values of the exported fields of rv
+func structFields(rv reflect.Value) (names, values []string) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := f.Name
+		if tag, _, _ := strings.Cut(f.Tag.Get("json"), ","); tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+		names = append(names, name)
+		values = append(values, formatCell(rv.Field(i)))
+	}
+	return names, values
+}
+
+// formatCell formats a single table value
+func formatCell(v reflect.Value) string {
+	if !v.IsValid() {
+		return ""
+	}
+	switch x := v.Interface().(type) {
+	case time.Time:
+		if x.IsZero() {
+			return "-"
+		}
+		return x.Format(time.RFC3339)
+	case time.Duration:
+		return x.String()
+	case fmt.Stringer:
+		return x.String()
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return "-"
+		}
+		return formatCell(v.Elem())
+	case reflect.Slice, reflect.Array:
+		parts := make([]string, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			parts = append(parts, formatCell(v.Index(i)))
+		}
+		return strings.Join(parts, ", ")
+	case reflect.String:
+		if v.String() == "" {
+			return "-"
+		}
+	}
+	return fmt.Sprint(v.Interface())
+}
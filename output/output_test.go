@@ -0,0 +1,43 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+)
+
+type item struct {
+	Name    string `json:"name" yaml:"name"`
+	Count   int    `json:"count" yaml:"count"`
+	Secret  string `json:"-" yaml:"-"`
+	private int
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		format string
+		value  any
+		want   string
+	}{
+		{FormatTable, item{Name: "a", Count: 2}, "name   a\ncount  2\n"},
+		{FormatTable, []item{{Name: "a", Count: 2}, {Count: 10}}, "NAME  COUNT\na     2\n-     10\n"},
+		{FormatJSON, item{Name: "a", Count: 2}, "{\n  \"name\": \"a\",\n  \"count\": 2\n}\n"},
+		{FormatYAML, item{Name: "a", Count: 2}, "name: a\ncount: 2\n"},
+		{FormatCSV, item{Name: "a", Count: 2}, "name,a\ncount,2\n"},
+		{FormatCSV, []item{{Name: "a, b", Count: 2}, {Count: 10}}, "name,count\n\"a, b\",2\n-,10\n"},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := Render(&buf, tt.format, tt.value); err != nil {
+			t.Errorf("Render(%s, %+v) returned error: %v", tt.format, tt.value, err)
+			continue
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("Render(%s, %+v) = %q; want %q", tt.format, tt.value, got, tt.want)
+		}
+	}
+
+	if err := Render(&bytes.Buffer{}, "xml", item{}); err == nil {
+		t.Errorf("Render(xml) expected error")
+	}
+}
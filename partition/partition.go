@@ -0,0 +1,120 @@
+// Package partition routes integer keys to a fixed number of partitions,
+// deterministically: a key lands on the same partition in every run, so
+// whatever is keyed by partition (a worker, a destination) always sees the
+// same keys. Three strategies are available:
+//
+//	modulo  key mod n: even for dense keys, but nearly every key moves when n changes
+//	range   n contiguous spans of [first, last]: each partition holds neighbouring
+//	        keys, which suits index-ordered writes and reads split by key range
+//	jump    jump consistent hash: even for any keys, and only about 1/n of them
+//	        move when a partition is added
+package partition
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Strategies
+const (
+	Modulo = "modulo"
+	Range  = "range"
+	Jump   = "jump"
+)
+
+// Strategies lists the supported strategies
+var Strategies = []string{Modulo, Range, Jump}
+
+// Partitioner maps keys to partitions 0 to N()-1
+type Partitioner interface {
+	Partition(key int) int
+	N() int
+}
+
+// New returns the partitioner of strategy over n partitions. first and last
+// bound the keys of the range strategy and are ignored by the others.
+func New(strategy string, n, first, last int) (Partitioner, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("partition count %d must be at least 1", n)
+	}
+	switch strategy {
+	case Modulo:
+		return modulo(n), nil
+	case Range:
+		if last < first {
+			return nil, fmt.Errorf("range partitioning needs first key %d <= last key %d", first, last)
+		}
+		return newKeyRange(n, first, last), nil
+	case Jump:
+		return jump(n), nil
+	}
+	return nil, fmt.Errorf("unknown partitioning strategy %q: must be one of %s", strategy, strings.Join(Strategies, ", "))
+}
+
+// modulo partitions keys by their remainder
+type modulo int
+
+func (m modulo) Partition(key int) int {
+	p := key % int(m)
+	if p < 0 {
+		p += int(m)
+	}
+	return p
+}
+
+func (m modulo) N() int { return int(m) }
+
+// keyRange partitions [first, last] in n spans of equal width; keys outside
+// the bounds go to the first or last partition
+type keyRange struct {
+	n     int
+	first int
+	width uint64 // Keys per partition, rounded up
+}
+
+func newKeyRange(n, first, last int) keyRange {
+	keys := uint64(last-first) + 1
+	return keyRange{n: n, first: first, width: (keys + uint64(n) - 1) / uint64(n)}
+}
+
+func (r keyRange) Partition(key int) int {
+	if key <= r.first {
+		return 0
+	}
+	return int(min(uint64(key-r.first)/r.width, uint64(r.n-1)))
+}
+
+func (r keyRange) N() int { return r.n }
+
+// jump partitions keys with JumpHash
+type jump int
+
+func (j jump) Partition(key int) int {
+	return JumpHash(mix(uint64(key)), int(j))
+}
+
+func (j jump) N() int { return int(j) }
+
+// JumpHash returns the bucket of key among buckets, as in "A Fast, Minimal
+// Memory, Consistent Hash Algorithm" (Lamping and Veach): growing buckets
+// from n to n+1 moves only the keys that land in the new bucket.
+func JumpHash(key uint64, buckets int) int {
+	var b, j int64 = -1, 0
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
+
+// mix spreads keys over 64 bits (the splitmix64 finalizer), so sequential
+// keys reach JumpHash as unrelated values
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,113 @@
+package partition
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		strategy    string
+		n           int
+		first, last int
+		ok          bool
+	}{
+		{Modulo, 4, 0, 0, true},
+		{Range, 4, 1, 1000, true},
+		{Range, 4, 1000, 1, false},
+		{Jump, 1, 0, 0, true},
+		{Jump, 0, 0, 0, false},
+		{"hash", 4, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		p, err := New(tt.strategy, tt.n, tt.first, tt.last)
+		if (err == nil) != tt.ok {
+			t.Errorf("New(%q, %d, %d, %d) error = %v; want ok %v", tt.strategy, tt.n, tt.first, tt.last, err, tt.ok)
+		}
+		if err == nil && p.N() != tt.n {
+			t.Errorf("New(%q, %d).N() = %d", tt.strategy, tt.n, p.N())
+		}
+	}
+}
+
+// TestDistribution checks that every partition gets close to its share of
+// dense keys and of sparse, strided ones
+func TestDistribution(t *testing.T) {
+	const keys, n = 100000, 8
+	for _, strategy := range Strategies {
+		for _, stride := range []int{1, 16} {
+			if strategy == Modulo && stride == 16 {
+				continue // Strided keys share their remainder, the known weakness of modulo
+			}
+			first, last := 1000, 1000+(keys-1)*stride
+			p, err := New(strategy, n, first, last)
+			if err != nil {
+				t.Fatal(err)
+			}
+			counts := make([]int, n)
+			for k := first; k <= last; k += stride {
+				counts[p.Partition(k)]++
+			}
+			if dev := maxDeviation(counts, keys); dev > 0.03 {
+				t.Errorf("%s stride %d: partition sizes %v deviate %.1f%% from the mean", strategy, stride, counts, dev*100)
+			}
+		}
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	for _, strategy := range Strategies {
+		a, _ := New(strategy, 5, -50, 50)
+		b, _ := New(strategy, 5, -50, 50)
+		for k := -100; k <= 100; k++ {
+			pa, pb := a.Partition(k), b.Partition(k)
+			if pa != pb || pa < 0 || pa >= 5 {
+				t.Fatalf("%s: key %d -> %d and %d; want the same partition in [0, 5)", strategy, k, pa, pb)
+			}
+		}
+	}
+}
+
+func TestRangeContiguous(t *testing.T) {
+	p, _ := New(Range, 4, 1, 100)
+	want := map[int]int{-5: 0, 1: 0, 25: 0, 26: 1, 50: 1, 51: 2, 76: 3, 100: 3, 500: 3}
+	for key, part := range want {
+		if got := p.Partition(key); got != part {
+			t.Errorf("range Partition(%d) = %d; want %d", key, got, part)
+		}
+	}
+}
+
+// TestJumpConsistent checks that adding a partition only moves keys to it,
+// about 1/(n+1) of them
+func TestJumpConsistent(t *testing.T) {
+	const keys, n = 100000, 8
+	before, _ := New(Jump, n, 0, 0)
+	after, _ := New(Jump, n+1, 0, 0)
+
+	moved := 0
+	for k := 0; k < keys; k++ {
+		from, to := before.Partition(k), after.Partition(k)
+		if from == to {
+			continue
+		}
+		if to != n {
+			t.Fatalf("key %d moved from %d to %d; want it to move only to the new partition %d", k, from, to, n)
+		}
+		moved++
+	}
+	if share := float64(moved) / keys; math.Abs(share-1.0/(n+1)) > 0.01 {
+		t.Errorf("%.3f of the keys moved; want about %.3f", share, 1.0/(n+1))
+	}
+}
+
+// maxDeviation returns the largest relative distance of a count from the mean
+func maxDeviation(counts []int, total int) float64 {
+	mean := float64(total) / float64(len(counts))
+	worst := 0.0
+	for _, c := range counts {
+		worst = max(worst, math.Abs(float64(c)-mean)/mean)
+	}
+	return worst
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package preflight
+
+import (
+	"errors"
+	"runtime"
+)
+
+// free is not supported on this platform
+func free(dir string) (uint64, error) {
+	return 0, errors.New("free disk space is not supported on " + runtime.GOOS)
+}
@@ -0,0 +1,103 @@
+// Package preflight checks, before a run starts, that the disks it writes to
+// have room for it, so a run is refused with a clear message instead of
+// failing midway with ENOSPC.
+package preflight
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/logger"
+)
+
+// Need is the space a run is estimated to write to a directory
+type Need struct {
+	Name  string // What is written there, for messages
+	Dir   string
+	Bytes int64
+}
+
+// freeSpace returns the bytes available to the process on the filesystem of dir
+var freeSpace = free
+
+// Logs returns the need of the log directory: a log file grows to
+// LOG_MAX_SIZE_MB before it is rotated
+func Logs() Need {
+	return Need{Name: "logs", Dir: logger.Dir(), Bytes: logger.MaxFileSize()}
+}
+
+// Update returns the needs of an automatic update: the download in
+// UPDATE_DOWNLOAD_DIR and the copy installed next to the executable, each
+// estimated at the size of the running executable. None when AUTO_UPDATE is off.
+func Update(cfg config.Config) []Need {
+	if !cfg.AutoUpdate {
+		return nil
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return nil
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	info, err := os.Stat(exe)
+	if err != nil {
+		return nil
+	}
+	return []Need{
+		{Name: "update download", Dir: cfg.UpdateDownloadDir, Bytes: info.Size()},
+		{Name: "update install", Dir: filepath.Dir(exe), Bytes: info.Size()},
+	}
+}
+
+// Check verifies that every directory of needs has its estimated need plus
+// DISK_MIN_FREE_MB available. Needs on the same filesystem are not added up;
+// the minimum covers them. A directory whose free space cannot be read is
+// logged and let through.
+func Check(cfg config.Config, needs ...Need) error {
+	log := logger.GetLogger()
+	reserve := int64(cfg.DiskMinFreeMB) << 20
+
+	var short []string
+	for _, n := range needs {
+		dir := existingDir(n.Dir)
+		avail, err := freeSpace(dir)
+		if err != nil {
+			log.Warn().Err(err).Str("dir", dir).Str("for", n.Name).Msg("Could not read the free disk space, not checked")
+			continue
+		}
+		want := n.Bytes + reserve
+		log.Debug().Str("dir", dir).Str("for", n.Name).Int64("free_mb", int64(avail>>20)).Int64("needed_mb", want>>20).Msg("Disk space checked")
+		if int64(avail) < want {
+			short = append(short, fmt.Sprintf("%s (%s): %d MB free, %d MB needed (%d MB estimated + DISK_MIN_FREE_MB=%d)",
+				n.Name, dir, avail>>20, want>>20, n.Bytes>>20, cfg.DiskMinFreeMB))
+		}
+	}
+	if len(short) > 0 {
+		return errors.New("not enough disk space for " + strings.Join(short, "; "))
+	}
+	return nil
+}
+
+// existingDir returns dir or its nearest existing parent, the directory a
+// file written under dir would be created in once the missing ones are
+func existingDir(dir string) string {
+	if dir == "" {
+		dir = "."
+	}
+	dir = filepath.Clean(dir)
+	for {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
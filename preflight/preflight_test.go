@@ -0,0 +1,45 @@
+package preflight
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/waldirborbajr/sync/config"
+)
+
+func TestCheck(t *testing.T) {
+	const mb = 1 << 20
+	dir := t.TempDir()
+	avail := map[string]uint64{dir: 150 * mb}
+	freeSpace = func(d string) (uint64, error) {
+		if v, ok := avail[d]; ok {
+			return v, nil
+		}
+		return 0, errors.New("unknown filesystem")
+	}
+	defer func() { freeSpace = free }()
+
+	tests := []struct {
+		minFree int
+		needs   []Need
+		short   string // Need reported as short, "" when the check passes
+	}{
+		{100, []Need{{Name: "logs", Dir: dir, Bytes: 50 * mb}}, ""},
+		{100, []Need{{Name: "logs", Dir: dir, Bytes: 51 * mb}}, "logs"},
+		{0, []Need{{Name: "logs", Dir: dir, Bytes: 150 * mb}}, ""},
+		{200, []Need{{Name: "logs", Dir: dir}}, "logs"},
+		{100, []Need{{Name: "download", Dir: filepath.Join(dir, "missing", "updates"), Bytes: 60 * mb}}, "download"}, // Checked on the nearest existing parent
+		{100, []Need{{Name: "logs", Dir: "/elsewhere", Bytes: 1 << 40}}, ""},                                         // Unreadable free space is let through
+	}
+	for _, tt := range tests {
+		err := Check(config.Config{DiskMinFreeMB: tt.minFree}, tt.needs...)
+		if tt.short == "" && err != nil {
+			t.Errorf("Check(min %d, %v) returned error: %v", tt.minFree, tt.needs, err)
+		}
+		if tt.short != "" && (err == nil || !strings.Contains(err.Error(), tt.short+" (")) {
+			t.Errorf("Check(min %d, %v) = %v; want %s reported", tt.minFree, tt.needs, err, tt.short)
+		}
+	}
+}
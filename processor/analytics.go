@@ -0,0 +1,93 @@
+package processor
+
+import (
+	"math"
+	"sort"
+
+	"github.com/waldirborbajr/sync/money"
+)
+
+// topChangesLimit is how many price changes the analytics summary keeps
+const topChangesLimit = 10
+
+// PriceChange describes a PRC_VENDA change applied during the run
+type PriceChange struct {
+	IDEstoque int
+	Descricao string
+	OldPrice  money.Cents
+	NewPrice  money.Cents
+}
+
+// Delta returns the absolute size of the change
+func (c PriceChange) Delta() money.Cents {
+	d := c.NewPrice - c.OldPrice
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// Analytics holds business metrics computed while rows are processed
+type Analytics struct {
+	InventoryCost money.Cents // Σ QTD_ATUAL × PRC_CUSTO for items in stock
+	InventorySale money.Cents // Σ QTD_ATUAL × PRC_VENDA for items in stock
+	ZeroCost      int         // Items without a cost price
+	ZeroPrice     int         // Items without a sale price
+	TopChanges    []PriceChange
+
+	marginSum   float64
+	marginCount int
+}
+
+// AverageMargin returns the mean margin over cost, in percent
+func (a *Analytics) AverageMargin() float64 {
+	if a.marginCount == 0 {
+		return 0
+	}
+	return a.marginSum / float64(a.marginCount)
+}
+
+// observe accounts for a processed row, whatever operation it resulted in
+func (a *Analytics) observe(op RowOperation) {
+	if op.PrcCusto == 0 {
+		a.ZeroCost++
+	}
+	if op.PrcVenda == 0 {
+		a.ZeroPrice++
+	}
+
+	if op.QtdAtual > 0 {
+		a.InventoryCost += money.Cents(math.Round(op.QtdAtual * float64(op.PrcCusto)))
+		a.InventorySale += money.Cents(math.Round(op.QtdAtual * float64(op.PrcVenda)))
+	}
+
+	if op.PrcCusto > 0 && op.PrcVenda > 0 {
+		a.marginSum += float64(op.PrcVenda-op.PrcCusto) / float64(op.PrcCusto) * 100
+		a.marginCount++
+	}
+
+	if op.Type == OpUpdate && op.existing != nil {
+		old := op.existing.PrcVenda.OrZero()
+		if old != op.PrcVenda {
+			a.addChange(PriceChange{IDEstoque: op.IDEstoque, Descricao: op.Descricao, OldPrice: old, NewPrice: op.PrcVenda})
+		}
+	}
+}
+
+// addChange keeps TopChanges sorted by descending delta and capped at topChangesLimit
+func (a *Analytics) addChange(c PriceChange) {
+	if len(a.TopChanges) == topChangesLimit && c.Delta() <= a.TopChanges[len(a.TopChanges)-1].Delta() {
+		return
+	}
+
+	i := sort.Search(len(a.TopChanges), func(i int) bool {
+		return a.TopChanges[i].Delta() < c.Delta()
+	})
+	a.TopChanges = append(a.TopChanges, PriceChange{})
+	copy(a.TopChanges[i+1:], a.TopChanges[i:])
+	a.TopChanges[i] = c
+
+	if len(a.TopChanges) > topChangesLimit {
+		a.TopChanges = a.TopChanges[:topChangesLimit]
+	}
+}
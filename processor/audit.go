@@ -0,0 +1,181 @@
+package processor
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/waldirborbajr/sync/compare"
+	"github.com/waldirborbajr/sync/logger"
+	"github.com/waldirborbajr/sync/money"
+)
+
+// auditChunk is the number of rows per multi-value insert into TB_ESTOQUE_SYNC_AUDIT
+const auditChunk = 100
+
+// Operations recorded in TB_ESTOQUE_SYNC_AUDIT.OPERACAO
+const (
+	auditInsert = "I"
+	auditUpdate = "U"
+)
+
+// recordAudit writes a TB_ESTOQUE_SYNC_AUDIT row per op when the audit is
+// enabled and returns the number of rows, counted once tx commits
+func (w *writer) recordAudit(tx *sql.Tx, ops []RowOperation) (int, error) {
+	if !w.cfg.AuditEnabled || len(ops) == 0 {
+		return 0, nil
+	}
+
+	n, err := insertAudit(tx, w.runID, w.columns, ops, time.Now())
+	if err != nil {
+		log := logger.GetLogger()
+		log.Error().Err(err).Int("count", len(ops)).Msg("Audit insert failed")
+		return 0, err
+	}
+	return n, nil
+}
+
+// insertAudit records ops into TB_ESTOQUE_SYNC_AUDIT inside the writer
+// transaction: every column of an insert, the columns an update changes with
+// their stored and new values, as JSON objects keyed by column, and the source
+// and reserved quantities of the rows RESERVATIONS_QUERY adjusted
+func insertAudit(tx *sql.Tx, runID string, columns []productColumn, ops []RowOperation, at time.Time) (int, error) {
+	count := 0
+	for start := 0; start < len(ops); start += auditChunk {
+		end := min(start+auditChunk, len(ops))
+
+		values := make([]interface{}, 0, 9*(end-start))
+		rows := 0
+		for _, op := range ops[start:end] {
+			operation, before, after, changed, err := auditEntry(op, columns)
+			if err != nil {
+				return count, err
+			}
+			if changed == "" {
+				continue // An update rewriting the stored values
+			}
+			source, reserved := auditReservation(op)
+			values = append(values, op.IDEstoque, operation, changed, before, after, source, reserved, runID, at)
+			rows++
+		}
+		if rows == 0 {
+			continue
+		}
+
+		query := "INSERT INTO TB_ESTOQUE_SYNC_AUDIT (ID_ESTOQUE, OPERACAO, COLUNAS, VALORES_ANTERIORES, VALORES_NOVOS, QTD_ORIGEM, QTD_RESERVADA, RUN_ID, DT_ALTERACAO) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)" +
+			strings.Repeat(", (?, ?, ?, ?, ?, ?, ?, ?, ?)", rows-1)
+		if _, err := tx.Exec(query, values...); err != nil {
+			return count, fmt.Errorf("audit insert failed: %w", err)
+		}
+		count += rows
+	}
+	return count, nil
+}
+
+// auditEntry returns the audit values of op: its operation, the stored and
+// written values of the columns it changes (before is NULL for inserts) and
+// their names, comma separated
+func auditEntry(op RowOperation, columns []productColumn) (operation string, before, after interface{}, changed string, err error) {
+	operation = auditInsert
+	if op.existing != nil {
+		operation = auditUpdate
+	}
+
+	cs := columnChanges(&op, columns)
+	if len(cs) == 0 {
+		return operation, nil, nil, "", nil
+	}
+	names := make([]string, len(cs))
+	old := make(map[string]interface{})
+	current := make(map[string]interface{})
+	for i, c := range cs {
+		names[i] = c.column
+		if op.existing != nil {
+			old[c.column] = auditValue(c.before)
+		}
+		current[c.column] = auditValue(c.after)
+	}
+
+	if op.existing != nil {
+		if before, err = auditJSON(old); err != nil {
+			return "", nil, nil, "", err
+		}
+	}
+	if after, err = auditJSON(current); err != nil {
+		return "", nil, nil, "", err
+	}
+	return operation, before, after, strings.Join(names, ","), nil
+}
+
+// auditReservation returns the source and reserved quantities of op, both
+// NULL when no reservation lowered its quantity
+func auditReservation(op RowOperation) (source, reserved interface{}) {
+	if op.QtdReservada == 0 {
+		return nil, nil
+	}
+	return op.QtdOrigem, op.QtdReservada
+}
+
+// columnChange is a column written by an operation with its stored value,
+// nil for inserts, and its new one
+type columnChange struct {
+	column        string
+	before, after interface{}
+}
+
+// columnChanges returns the columns of op differing from its stored row,
+// every column for inserts
+func columnChanges(op *RowOperation, columns []productColumn) []columnChange {
+	var cs []columnChange
+	for _, c := range columns {
+		v := c.value(op)
+		if op.existing == nil {
+			cs = append(cs, columnChange{column: c.column, after: v})
+			continue
+		}
+		stored := c.stored(op.existing)
+		if compare.Key(stored) != compare.Key(v) {
+			cs = append(cs, columnChange{column: c.column, before: stored, after: v})
+		}
+	}
+	return cs
+}
+
+// auditValue returns v as it is encoded in the audit: amounts as decimal numbers
+func auditValue(v interface{}) interface{} {
+	if c, ok := v.(money.Cents); ok {
+		return json.Number(c.String())
+	}
+	return v
+}
+
+// auditJSON encodes audited values
+func auditJSON(values map[string]interface{}) (string, error) {
+	b, err := json.Marshal(values)
+	if err != nil {
+		return "", fmt.Errorf("error encoding audit values: %w", err)
+	}
+	return string(b), nil
+}
+
+// purgeAudit deletes audit rows older than retentionDays
+func purgeAudit(db *sql.DB, retentionDays int) (int64, error) {
+	if retentionDays <= 0 {
+		return 0, nil
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	res, err := db.Exec("DELETE FROM TB_ESTOQUE_SYNC_AUDIT WHERE DT_ALTERACAO < ?", cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("error purging TB_ESTOQUE_SYNC_AUDIT: %w", err)
+	}
+
+	purged, _ := res.RowsAffected()
+	if purged > 0 {
+		log := logger.GetLogger()
+		log.Info().Int64("rows", purged).Int("retention_days", retentionDays).Msg("Purged old audit rows")
+	}
+	return purged, nil
+}
@@ -0,0 +1,131 @@
+package processor
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/logger"
+	"github.com/waldirborbajr/sync/transfer"
+)
+
+// BenchResult is how one comparison strategy fared in Bench
+type BenchResult struct {
+	Preload     string        // MYSQL_PRELOAD the stored rows were compared with
+	Records     int           // Stored rows loaded
+	LoadTime    time.Duration // Spent loading the stored rows
+	CompareTime time.Duration // Spent comparing every source row against them
+	HeapBytes   uint64        // Heap held by the loaded rows once garbage is collected
+	AllocBytes  uint64        // Bytes allocated loading and comparing, garbage included
+	Inserts     int
+	Updates     int
+	Unchanged   int
+}
+
+// Bench compares the Firebird rows against the stored rows once per
+// configuration of cfgs, which differ in MYSQL_PRELOAD, without writing
+// anything. The source is read a single time with cfgs[0], so every
+// strategy compares the same snapshot; reading it is left out of the
+// measurements. PRC_DOLAR is not derived from an exchange rate, which would
+// fetch and cache one.
+func Bench(ctx context.Context, firebirdDB, mysqlDB *sql.DB, cfgs []config.Config) ([]BenchResult, error) {
+	log := logger.GetLogger()
+	if len(cfgs) == 0 {
+		return nil, nil
+	}
+	cfg := cfgs[0]
+
+	var since time.Time
+	if incremental(cfg) && !cfg.Reconcile() {
+		var err error
+		if since, err = loadWatermark(cfg); err != nil {
+			return nil, fmt.Errorf("error loading incremental watermark: %w", err)
+		}
+	}
+	var rows []sourceRow
+	err := readSource(ctx, firebirdDB, cfg, since, transfer.NewRetrier(cfg), &ProcessingStats{}, func(src sourceRow) error {
+		if !filteredOut(cfg, src) {
+			rows = append(rows, src)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Info().Int("rows", len(rows)).Msg("Firebird rows read for the benchmark")
+
+	var protected map[int]struct{}
+	if !cfg.QuantityOnly() {
+		if protected, err = loadProtectedKeys(ctx, mysqlDB, cfg.ProtectedRowsQuery); err != nil {
+			return nil, err
+		}
+	}
+	reserved, err := loadReservations(ctx, mysqlDB, cfg.ReservationsQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]BenchResult, 0, len(cfgs))
+	for _, c := range cfgs {
+		r, err := benchStrategy(mysqlDB, c, rows, protected, reserved)
+		if err != nil {
+			return nil, fmt.Errorf("MYSQL_PRELOAD=%s: %w", c.MySQLPreload, err)
+		}
+		log.Info().Str("preload", r.Preload).Dur("load", r.LoadTime).Dur("compare", r.CompareTime).Uint64("heap_bytes", r.HeapBytes).Msg("Strategy benchmarked")
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// benchStrategy loads the stored rows as cfg.MySQLPreload says and compares rows against them
+func benchStrategy(mysqlDB *sql.DB, cfg config.Config, rows []sourceRow, protected map[int]struct{}, reserved map[int]float64) (BenchResult, error) {
+	r := BenchResult{Preload: cfg.MySQLPreload}
+
+	// Collect the previous strategy's garbage so it is not counted here
+	var before, loaded, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	lk := &lookups{columns: productColumns(cfg), protected: protected, reserved: reserved}
+	var loading stageTimer
+	if err := loading.measure(func() error { return lk.load(mysqlDB, cfg) }); err != nil {
+		return r, fmt.Errorf("error loading MySQL records: %w", err)
+	}
+	r.LoadTime = loading.total
+	r.Records = lk.len()
+
+	runtime.GC()
+	runtime.ReadMemStats(&loaded)
+	if loaded.HeapAlloc > before.HeapAlloc {
+		r.HeapBytes = loaded.HeapAlloc - before.HeapAlloc
+	}
+
+	var comparing stageTimer
+	comparing.start()
+	for _, src := range rows {
+		var op RowOperation
+		if cfg.QuantityOnly() {
+			op = processQuantityRow(lk, src, cfg)
+		} else {
+			op = processRowOptimized(lk, src, cfg)
+		}
+		switch op.Type {
+		case OpInsert:
+			r.Inserts++
+		case OpUpdate:
+			r.Updates++
+		default:
+			r.Unchanged++
+		}
+	}
+	comparing.stop()
+	r.CompareTime = comparing.total
+
+	runtime.ReadMemStats(&after)
+	r.AllocBytes = after.TotalAlloc - before.TotalAlloc
+	runtime.KeepAlive(lk)
+	return r, nil
+}
@@ -0,0 +1,291 @@
+package processor
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/db"
+	"github.com/waldirborbajr/sync/logger"
+	"github.com/waldirborbajr/sync/run"
+	"github.com/waldirborbajr/sync/transfer"
+)
+
+// blobBatchBytes is the BLOB data written per MySQL transaction
+const blobBatchBytes = 8 << 20
+
+// blobBatchRows is the most BLOBs written per MySQL transaction
+const blobBatchRows = 100
+
+// BlobStats reports the Firebird BLOB columns copied into MySQL (BLOB_COLUMNS)
+type BlobStats struct {
+	Rows      int   // Firebird rows read
+	Written   int   // BLOBs written, cleared ones included
+	Bytes     int64 // Size of the BLOBs written
+	Unchanged int   // BLOBs of the length and hash last written
+	Oversized []int // Keys with a BLOB over BLOB_MAX_BYTES, left alone
+	Missing   int   // Firebird rows whose key is not in MySQL
+	Duration  time.Duration
+}
+
+// blobKey identifies a BLOB: a row and its MySQL column
+type blobKey struct {
+	id     int
+	column string
+}
+
+// blobDigest is the length and SHA-256 of a BLOB, an invalid size for NULL
+type blobDigest struct {
+	size sql.NullInt64
+	hash string
+}
+
+// digestBlob returns the digest of data, NULL when null
+func digestBlob(data []byte, null bool) blobDigest {
+	if null {
+		return blobDigest{}
+	}
+	sum := sha256.Sum256(data)
+	return blobDigest{size: sql.NullInt64{Int64: int64(len(data)), Valid: true}, hash: hex.EncodeToString(sum[:])}
+}
+
+// blobWrite is a BLOB to write into MySQL TB_ESTOQUE
+type blobWrite struct {
+	key    blobKey
+	data   []byte // Nil for NULL
+	digest blobDigest
+}
+
+// blobWriter batches the BLOB updates and records their digests in TB_SYNC_BLOBS
+type blobWriter struct {
+	mysqlDB *sql.DB
+	cfg     config.Config
+	retry   *transfer.Retrier
+	pending []blobWrite
+	bytes   int
+	stats   *BlobStats
+}
+
+// syncBlobs copies the BLOB_COLUMNS of Firebird TB_ESTOQUE into the MySQL
+// rows, one Firebird row at a time. A BLOB is written only when its length
+// or SHA-256 differs from the last one written, kept in TB_SYNC_BLOBS; BLOBs
+// over BLOB_MAX_BYTES (or the MySQL packet size) are not even fetched. With
+// since set only the rows modified after it are read.
+func syncBlobs(ctx context.Context, firebirdDB, mysqlDB *sql.DB, cfg config.Config, since time.Time, retry *transfer.Retrier) (*BlobStats, error) {
+	log := logger.GetLogger()
+	start := time.Now()
+	bs := &BlobStats{}
+
+	stored, err := loadBlobDigests(ctx, mysqlDB)
+	if err != nil {
+		return nil, fmt.Errorf("error loading TB_SYNC_BLOBS: %w", err)
+	}
+	keys, err := loadProductKeys(ctx, mysqlDB, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error loading MySQL keys: %w", err)
+	}
+	limit := min(cfg.BlobMaxBytes, transfer.StatementLimit(db.MaxAllowedPacket(mysqlDB, cfg)))
+
+	query, args := buildBlobQuery(cfg, since, limit)
+	rows, err := firebirdDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying Firebird BLOBs: %w", err)
+	}
+	defer rows.Close()
+
+	w := &blobWriter{mysqlDB: mysqlDB, cfg: cfg, retry: retry, stats: bs}
+	sizes := make([]sql.NullInt64, len(cfg.BlobColumns))
+	data := make([][]byte, len(cfg.BlobColumns))
+	for rows.Next() {
+		var id int
+		dest := []interface{}{&id}
+		for i := range cfg.BlobColumns {
+			dest = append(dest, &sizes[i], &data[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("error scanning Firebird BLOBs: %w", err)
+		}
+		bs.Rows++
+		if _, ok := keys[id]; !ok {
+			bs.Missing++
+			continue
+		}
+
+		oversized := false
+		for i, c := range cfg.BlobColumns {
+			if sizes[i].Valid && sizes[i].Int64 > int64(limit) {
+				log.Warn().Int("id_estoque", id).Str("column", c.Source).Int64("bytes", sizes[i].Int64).Int("limit", limit).Msg("BLOB too large, not synced")
+				oversized = true
+				continue
+			}
+			if sizes[i].Valid && data[i] == nil {
+				data[i] = []byte{} // Empty, not NULL
+			}
+			key := blobKey{id: id, column: c.Target}
+			digest := digestBlob(data[i], !sizes[i].Valid)
+			last, ok := stored[key]
+			if ok && last == digest || !ok && !digest.size.Valid {
+				bs.Unchanged++
+				continue
+			}
+			w.add(blobWrite{key: key, data: data[i], digest: digest})
+		}
+		if oversized {
+			bs.Oversized = append(bs.Oversized, id)
+		}
+
+		if len(w.pending) >= blobBatchRows || w.bytes >= blobBatchBytes {
+			if err := w.flush(ctx); err != nil {
+				return nil, err
+			}
+		}
+		run.Touch(ctx)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := w.flush(ctx); err != nil {
+		return nil, err
+	}
+
+	bs.Duration = time.Since(start)
+	log.Info().
+		Int("rows", bs.Rows).
+		Int("written", bs.Written).
+		Int64("bytes", bs.Bytes).
+		Int("unchanged", bs.Unchanged).
+		Int("oversized", len(bs.Oversized)).
+		Int("missing", bs.Missing).
+		Dur("duration", bs.Duration).
+		Msg("BLOB columns synced")
+	return bs, nil
+}
+
+// buildBlobQuery returns the Firebird query of the BLOB columns and its
+// arguments: the key, then the length and the BLOB of each column, the BLOB
+// being NULL past limit so that it is not transferred
+func buildBlobQuery(cfg config.Config, since time.Time, limit int) (string, []interface{}) {
+	selects := []string{"ID_ESTOQUE"}
+	var args []interface{}
+	for _, c := range cfg.BlobColumns {
+		selects = append(selects, "OCTET_LENGTH("+c.Source+")", "CASE WHEN OCTET_LENGTH("+c.Source+") <= ? THEN "+c.Source+" END")
+		args = append(args, limit)
+	}
+	query := "SELECT " + strings.Join(selects, ", ") + " FROM TB_ESTOQUE"
+	if incremental(cfg) && !since.IsZero() {
+		query += " WHERE " + cfg.IncrementalColumn + " > ?"
+		args = append(args, since)
+	}
+	return query, args
+}
+
+// loadBlobDigests returns the digest of the BLOBs last written
+func loadBlobDigests(ctx context.Context, mysqlDB *sql.DB) (map[blobKey]blobDigest, error) {
+	rows, err := mysqlDB.QueryContext(ctx, "SELECT ID_ESTOQUE, COLUNA, TAMANHO, HASH FROM TB_SYNC_BLOBS")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	digests := make(map[blobKey]blobDigest)
+	for rows.Next() {
+		var key blobKey
+		var d blobDigest
+		if err := rows.Scan(&key.id, &key.column, &d.size, &d.hash); err != nil {
+			return nil, err
+		}
+		digests[key] = d
+	}
+	return digests, rows.Err()
+}
+
+// loadProductKeys returns the keys of the MySQL TB_ESTOQUE rows
+func loadProductKeys(ctx context.Context, mysqlDB *sql.DB, cfg config.Config) (map[int]struct{}, error) {
+	key := productKeyColumn(cfg)
+	rows, err := mysqlDB.QueryContext(ctx, "SELECT "+key+" FROM TB_ESTOQUE WHERE "+key+" IS NOT NULL")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make(map[int]struct{})
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		keys[id] = struct{}{}
+	}
+	return keys, rows.Err()
+}
+
+// add queues a BLOB for the next flush
+func (w *blobWriter) add(b blobWrite) {
+	w.pending = append(w.pending, b)
+	w.bytes += len(b.data)
+}
+
+// flush writes the pending BLOBs and their digests in one transaction
+func (w *blobWriter) flush(ctx context.Context) error {
+	if len(w.pending) == 0 {
+		return nil
+	}
+	if err := w.retry.Do(ctx, "BLOB update", len(w.pending), func() error { return w.write(ctx) }); err != nil {
+		return err
+	}
+
+	w.stats.Written += len(w.pending)
+	w.stats.Bytes += int64(w.bytes)
+	w.pending, w.bytes = w.pending[:0], 0
+	run.Touch(ctx)
+	return nil
+}
+
+// write updates the BLOB columns of the pending rows, a prepared statement
+// per column, and upserts their digests into TB_SYNC_BLOBS
+func (w *blobWriter) write(ctx context.Context) error {
+	tx, err := w.mysqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmts := make(map[string]*sql.Stmt, len(w.cfg.BlobColumns))
+	for _, c := range w.cfg.BlobColumns {
+		stmt, err := tx.PrepareContext(ctx, "UPDATE TB_ESTOQUE SET "+c.Target+" = ? WHERE "+productKeyColumn(w.cfg)+" = ?")
+		if err != nil {
+			return fmt.Errorf("error preparing BLOB update of %s: %w", c.Target, err)
+		}
+		defer stmt.Close()
+		stmts[c.Target] = stmt
+	}
+
+	now := time.Now()
+	values := make([]interface{}, 0, 5*len(w.pending))
+	for _, b := range w.pending {
+		var data interface{}
+		if b.digest.size.Valid {
+			data = b.data
+		}
+		if _, err := stmts[b.key.column].ExecContext(ctx, data, b.key.id); err != nil {
+			return fmt.Errorf("BLOB update of %s for ID_ESTOQUE %d failed: %w", b.key.column, b.key.id, err)
+		}
+		values = append(values, b.key.id, b.key.column, b.digest.size, b.digest.hash, now)
+	}
+
+	query := "INSERT INTO TB_SYNC_BLOBS (ID_ESTOQUE, COLUNA, TAMANHO, HASH, DT_ENVIO) VALUES (?, ?, ?, ?, ?)" + strings.Repeat(", (?, ?, ?, ?, ?)", len(w.pending)-1) +
+		db.UpsertClause(w.cfg, "ID_ESTOQUE, COLUNA", []string{"TAMANHO", "HASH", "DT_ENVIO"})
+	if _, err := tx.ExecContext(ctx, query, values...); err != nil {
+		return fmt.Errorf("error writing TB_SYNC_BLOBS: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("BLOB update commit failed: %w", err)
+	}
+	return nil
+}
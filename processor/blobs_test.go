@@ -0,0 +1,60 @@
+package processor
+
+import "testing"
+
+func TestBlobSyncCopiesChangedBlobs(t *testing.T) {
+	cfg, firebirdDB, mysqlDB := devDatabases(t, map[string]string{"BLOB_COLUMNS": "FOTO", "BLOB_MAX_BYTES": "10"})
+	execAll(t, firebirdDB,
+		"ALTER TABLE TB_ESTOQUE ADD COLUMN FOTO BLOB",
+		"UPDATE TB_ESTOQUE SET FOTO = x'0102' WHERE ID_ESTOQUE = 1",
+		"UPDATE TB_ESTOQUE SET FOTO = zeroblob(20) WHERE ID_ESTOQUE = 2",
+	)
+	execAll(t, mysqlDB, "ALTER TABLE TB_ESTOQUE ADD COLUMN FOTO BLOB")
+
+	_, _, _, stats := syncDev(t, cfg, firebirdDB, mysqlDB)
+	bs := stats.Blobs
+	if bs == nil || bs.Written != 1 || len(bs.Oversized) != 1 || bs.Oversized[0] != 2 {
+		t.Fatalf("blob stats = %+v; want product 1 written, product 2 oversized", bs)
+	}
+	if n := countRows(t, mysqlDB, "SELECT COUNT(*) FROM TB_ESTOQUE WHERE ID_ESTOQUE = 1 AND FOTO = x'0102'"); n != 1 {
+		t.Error("BLOB of product 1 not copied")
+	}
+	if n := countRows(t, mysqlDB, "SELECT COUNT(*) FROM TB_ESTOQUE WHERE FOTO IS NOT NULL"); n != 1 {
+		t.Errorf("%d BLOBs stored; want only the one within BLOB_MAX_BYTES", n)
+	}
+
+	// Unchanged BLOBs are not written again, changed ones are
+	execAll(t, firebirdDB, "UPDATE TB_ESTOQUE SET FOTO = x'03' WHERE ID_ESTOQUE = 3")
+	_, _, _, stats = syncDev(t, cfg, firebirdDB, mysqlDB)
+	if bs := stats.Blobs; bs.Written != 1 || bs.Unchanged == 0 {
+		t.Errorf("second run blob stats = %+v; want only product 3 written", bs)
+	}
+	if n := countRows(t, mysqlDB, "SELECT COUNT(*) FROM TB_ESTOQUE WHERE ID_ESTOQUE = 3 AND FOTO = x'03'"); n != 1 {
+		t.Error("changed BLOB of product 3 not copied")
+	}
+}
+
+func TestBlobSyncDisabled(t *testing.T) {
+	for _, values := range []map[string]string{
+		{},
+		{"BLOB_COLUMNS": "FOTO", "SYNC_MODE": "quantity"},
+	} {
+		cfg, firebirdDB, mysqlDB := devDatabases(t, values)
+		execAll(t, firebirdDB,
+			"ALTER TABLE TB_ESTOQUE ADD COLUMN FOTO BLOB",
+			"UPDATE TB_ESTOQUE SET FOTO = x'0102'",
+		)
+		execAll(t, mysqlDB,
+			"ALTER TABLE TB_ESTOQUE ADD COLUMN FOTO BLOB",
+			"INSERT INTO TB_ESTOQUE (ID_ESTOQUE, DESCRICAO) VALUES (1, 'stored')",
+		)
+
+		_, _, _, stats := syncDev(t, cfg, firebirdDB, mysqlDB)
+		if stats.Blobs != nil {
+			t.Errorf("%v: BLOB sync ran: %+v", values, stats.Blobs)
+		}
+		if n := countRows(t, mysqlDB, "SELECT COUNT(*) FROM TB_ESTOQUE WHERE FOTO IS NOT NULL"); n != 0 {
+			t.Errorf("%v: %d BLOBs written", values, n)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package processor
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/logger"
+)
+
+// catalogSampleLimit caps how many orphan codes are logged per side
+const catalogSampleLimit = 20
+
+// CatalogStats is the result of cross-referencing products against the webshop catalog by EAN/SKU
+type CatalogStats struct {
+	Checked        int // Distinct source codes checked
+	SourceOrphans  int // Source codes missing from the catalog
+	CatalogOrphans int // Catalog codes with no source product
+	Created        int // Minimal catalog entries created with CATALOG_AUTO_CREATE
+}
+
+// validateCatalog compares the codes returned by CATALOG_SOURCE_QUERY
+// (ID_ESTOQUE and EAN/SKU, read from Firebird) with the codes present in
+// CATALOG_TABLE, logs orphans on either side and optionally creates minimal
+// catalog entries for products the webshop does not know yet.
+func validateCatalog(ctx context.Context, firebirdDB, mysqlDB *sql.DB, cfg config.Config) (CatalogStats, error) {
+	var cs CatalogStats
+	log := logger.GetLogger()
+
+	source, err := loadSourceCodes(ctx, firebirdDB, cfg.CatalogSourceQuery)
+	if err != nil {
+		return cs, fmt.Errorf("error running CATALOG_SOURCE_QUERY: %w", err)
+	}
+	catalog, err := loadCatalogCodes(ctx, mysqlDB, cfg)
+	if err != nil {
+		return cs, fmt.Errorf("error loading %s: %w", cfg.CatalogTable, err)
+	}
+	cs.Checked = len(source)
+
+	var missing, extra []string
+	for code := range source {
+		if _, ok := catalog[code]; !ok {
+			missing = append(missing, code)
+		}
+	}
+	for code := range catalog {
+		if _, ok := source[code]; !ok {
+			extra = append(extra, code)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(extra)
+	cs.SourceOrphans = len(missing)
+	cs.CatalogOrphans = len(extra)
+
+	if len(missing) > 0 {
+		log.Warn().
+			Int("count", len(missing)).
+			Strs("codes", sample(missing, catalogSampleLimit)).
+			Msg("Products missing from the webshop catalog")
+	}
+	if len(extra) > 0 {
+		log.Warn().
+			Int("count", len(extra)).
+			Strs("codes", sample(extra, catalogSampleLimit)).
+			Msg("Catalog entries without a source product")
+	}
+
+	if cfg.CatalogAutoCreate && len(missing) > 0 {
+		cs.Created, err = createCatalogEntries(ctx, mysqlDB, cfg, missing, source)
+		if err != nil {
+			return cs, err
+		}
+	}
+
+	log.Info().
+		Int("checked", cs.Checked).
+		Int("source_orphans", cs.SourceOrphans).
+		Int("catalog_orphans", cs.CatalogOrphans).
+		Int("created", cs.Created).
+		Msg("Catalog cross-reference validated")
+	return cs, nil
+}
+
+// createCatalogEntries inserts (ID, code) rows into the catalog table for the given codes
+func createCatalogEntries(ctx context.Context, db *sql.DB, cfg config.Config, codes []string, source map[string]int) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, fmt.Sprintf("INSERT INTO %s (%s, %s) VALUES (?, ?)", cfg.CatalogTable, cfg.CatalogIDColumn, cfg.CatalogCodeColumn))
+	if err != nil {
+		return 0, fmt.Errorf("error preparing catalog insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, code := range codes {
+		if _, err := stmt.ExecContext(ctx, source[code], code); err != nil {
+			return 0, fmt.Errorf("catalog insert failed for code %s: %w", code, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("catalog insert commit failed: %w", err)
+	}
+	return len(codes), nil
+}
+
+// loadSourceCodes reads (ID_ESTOQUE, code) rows into a code -> ID_ESTOQUE map, ignoring empty codes
+func loadSourceCodes(ctx context.Context, db *sql.DB, query string) (map[string]int, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	codes := make(map[string]int)
+	for rows.Next() {
+		var id int
+		var code sql.NullString
+		if err := rows.Scan(&id, &code); err != nil {
+			return nil, fmt.Errorf("expected ID_ESTOQUE and code columns: %w", err)
+		}
+		if c := strings.TrimSpace(code.String); c != "" {
+			codes[c] = id
+		}
+	}
+	return codes, rows.Err()
+}
+
+// loadCatalogCodes returns the non-empty codes present in the catalog table
+func loadCatalogCodes(ctx context.Context, db *sql.DB, cfg config.Config) (map[string]struct{}, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM %s", cfg.CatalogCodeColumn, cfg.CatalogTable))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	codes := make(map[string]struct{})
+	for rows.Next() {
+		var code sql.NullString
+		if err := rows.Scan(&code); err != nil {
+			return nil, err
+		}
+		if c := strings.TrimSpace(code.String); c != "" {
+			codes[c] = struct{}{}
+		}
+	}
+	return codes, rows.Err()
+}
+
+// sample returns at most n leading items
+func sample(items []string, n int) []string {
+	if len(items) > n {
+		return items[:n]
+	}
+	return items
+}
+
+// catalogValidationEnabled reports whether both the source query and catalog table are configured
+func catalogValidationEnabled(cfg config.Config) bool {
+	return cfg.CatalogSourceQuery != "" && cfg.CatalogTable != ""
+}
@@ -0,0 +1,135 @@
+package processor
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/waldirborbajr/sync/logger"
+	"github.com/waldirborbajr/sync/transfer"
+)
+
+// CategoryStats counts the TB_CATEGORIA changes applied during the run
+type CategoryStats struct {
+	Read     int
+	Inserted int
+	Updated  int
+	Stale    int // MySQL categories no longer in Firebird, kept because products may reference them
+}
+
+// syncCategories syncs the Firebird product groups into TB_CATEGORIA. It
+// runs before any TB_ESTOQUE write so that products never reference a
+// category that does not exist yet; for the same reason categories missing
+// from Firebird are counted but never deleted.
+func syncCategories(ctx context.Context, firebirdDB, mysqlDB *sql.DB, query string, retrier *transfer.Retrier) (CategoryStats, error) {
+	var cs CategoryStats
+	log := logger.GetLogger()
+
+	source, err := loadCategories(ctx, firebirdDB, query)
+	if err != nil {
+		return cs, fmt.Errorf("error running CATEGORY_QUERY: %w", err)
+	}
+	target, err := loadCategories(ctx, mysqlDB, "SELECT ID_CATEGORIA, DESCRICAO FROM TB_CATEGORIA")
+	if err != nil {
+		return cs, fmt.Errorf("error loading TB_CATEGORIA: %w", err)
+	}
+	cs.Read = len(source)
+	for id := range target {
+		if _, ok := source[id]; !ok {
+			cs.Stale++
+		}
+	}
+
+	// Written in key order, so that reruns after a failure touch the same rows first
+	ids := make([]int, 0, len(source))
+	for id, name := range source {
+		if current, ok := target[id]; !ok || current != name {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		log.Info().Int("read", cs.Read).Int("stale", cs.Stale).Msg("Categories already in sync")
+		return cs, nil
+	}
+	sort.Ints(ids)
+
+	err = retrier.Do(ctx, "category", len(ids), func() error {
+		var attempt CategoryStats
+		tx, err := mysqlDB.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("error starting transaction: %w", err)
+		}
+		defer tx.Rollback()
+
+		insertStmt, err := tx.PrepareContext(ctx, "INSERT INTO TB_CATEGORIA (ID_CATEGORIA, DESCRICAO) VALUES (?, ?)")
+		if err != nil {
+			return fmt.Errorf("error preparing category insert: %w", err)
+		}
+		defer insertStmt.Close()
+
+		updateStmt, err := tx.PrepareContext(ctx, "UPDATE TB_CATEGORIA SET DESCRICAO = ? WHERE ID_CATEGORIA = ?")
+		if err != nil {
+			return fmt.Errorf("error preparing category update: %w", err)
+		}
+		defer updateStmt.Close()
+
+		for _, id := range ids {
+			if _, exists := target[id]; !exists {
+				if _, err := insertStmt.ExecContext(ctx, id, source[id]); err != nil {
+					return fmt.Errorf("category insert failed for ID %d: %w", id, err)
+				}
+				attempt.Inserted++
+				continue
+			}
+			if _, err := updateStmt.ExecContext(ctx, source[id], id); err != nil {
+				return fmt.Errorf("category update failed for ID %d: %w", id, err)
+			}
+			attempt.Updated++
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("category sync commit failed: %w", err)
+		}
+		cs.Inserted, cs.Updated = attempt.Inserted, attempt.Updated
+		return nil
+	})
+	if err != nil {
+		return cs, err
+	}
+
+	log.Info().
+		Int("read", cs.Read).
+		Int("inserted", cs.Inserted).
+		Int("updated", cs.Updated).
+		Int("stale", cs.Stale).
+		Msg("Categories synced")
+	return cs, nil
+}
+
+// loadCategories reads (ID, DESCRICAO) rows into a map. Firebird CHAR
+// descriptions are padded with blanks, which are not part of the name.
+func loadCategories(ctx context.Context, db *sql.DB, query string) (map[int]string, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var name sql.NullString
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, fmt.Errorf("expected category ID and description columns: %w", err)
+		}
+		result[id] = strings.TrimSpace(name.String)
+	}
+	return result, rows.Err()
+}
+
+// categorySyncEnabled reports whether a category query is configured
+func categorySyncEnabled(query string) bool {
+	return strings.TrimSpace(query) != ""
+}
@@ -0,0 +1,47 @@
+package processor
+
+import "testing"
+
+func TestCategorySyncRoundTrip(t *testing.T) {
+	cfg, firebirdDB, mysqlDB := devDatabases(t, map[string]string{"CATEGORY_QUERY": "SELECT ID_GRUPO, DESCRICAO FROM TB_GRUPO"})
+	_, _, _, stats := syncDev(t, cfg, firebirdDB, mysqlDB)
+	if cs := stats.Categories; cs.Read != 4 || cs.Inserted != 4 {
+		t.Errorf("first run category stats = %+v; want the 4 groups inserted", cs)
+	}
+
+	// A category renamed in MySQL is written back, one missing from Firebird is kept
+	execAll(t, mysqlDB,
+		"UPDATE TB_CATEGORIA SET DESCRICAO = 'renamed' WHERE ID_CATEGORIA = 2",
+		"INSERT INTO TB_CATEGORIA (ID_CATEGORIA, DESCRICAO) VALUES (99, 'stale')",
+	)
+
+	_, _, _, stats = syncDev(t, cfg, firebirdDB, mysqlDB)
+	if cs := stats.Categories; cs.Read != 4 || cs.Inserted != 0 || cs.Updated != 1 || cs.Stale != 1 {
+		t.Errorf("category stats = %+v; want 4 read, 1 updated, 1 stale", cs)
+	}
+	if n := countRows(t, mysqlDB, "SELECT COUNT(*) FROM TB_CATEGORIA WHERE ID_CATEGORIA = 2 AND DESCRICAO = 'Group 2'"); n != 1 {
+		t.Error("renamed category not written back")
+	}
+	// Products may still reference the stale category
+	if n := countRows(t, mysqlDB, "SELECT COUNT(*) FROM TB_CATEGORIA"); n != 5 {
+		t.Errorf("%d categories; want the 4 of Firebird and the stale one kept", n)
+	}
+}
+
+func TestCategorySyncDisabled(t *testing.T) {
+	for _, values := range []map[string]string{
+		{},
+		{"CATEGORY_QUERY": "SELECT ID_GRUPO, DESCRICAO FROM TB_GRUPO", "SYNC_MODE": "quantity"},
+	} {
+		cfg, firebirdDB, mysqlDB := devDatabases(t, values)
+		execAll(t, mysqlDB, "CREATE TABLE IF NOT EXISTS TB_CATEGORIA (ID_CATEGORIA INTEGER NOT NULL PRIMARY KEY, DESCRICAO TEXT NOT NULL)")
+
+		_, _, _, stats := syncDev(t, cfg, firebirdDB, mysqlDB)
+		if stats.Categories != (CategoryStats{}) {
+			t.Errorf("%v: category sync ran: %+v", values, stats.Categories)
+		}
+		if n := countRows(t, mysqlDB, "SELECT COUNT(*) FROM TB_CATEGORIA"); n != 0 {
+			t.Errorf("%v: %d categories written", values, n)
+		}
+	}
+}
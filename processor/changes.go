@@ -0,0 +1,65 @@
+package processor
+
+import (
+	"sort"
+	"strings"
+)
+
+// ChangeStats breaks updates down by the columns that actually differed
+type ChangeStats struct {
+	Updates int
+	Columns map[string]int // Updates per changed column
+	Reasons map[string]int // Updates per set of changed columns, e.g. "PRC_VENDA+QTD_ATUAL"
+}
+
+// ChangeCount is a column or column set and the number of updates it drove
+type ChangeCount struct {
+	Name    string
+	Count   int
+	Percent float64 // Share of all updates
+}
+
+// observe accounts for the changed columns of an update
+func (c *ChangeStats) observe(op RowOperation) {
+	if op.Type != OpUpdate || len(op.changedColumns) == 0 {
+		return
+	}
+	if c.Columns == nil {
+		c.Columns = make(map[string]int)
+		c.Reasons = make(map[string]int)
+	}
+
+	c.Updates++
+	for _, column := range op.changedColumns {
+		c.Columns[column]++
+	}
+	c.Reasons[strings.Join(op.changedColumns, "+")]++
+}
+
+// TopColumns returns the n columns changed most often
+func (c ChangeStats) TopColumns(n int) []ChangeCount {
+	return c.top(c.Columns, n)
+}
+
+// TopReasons returns the n most frequent sets of changed columns
+func (c ChangeStats) TopReasons(n int) []ChangeCount {
+	return c.top(c.Reasons, n)
+}
+
+// top returns the n largest counts of m, ties ordered by name
+func (c ChangeStats) top(m map[string]int, n int) []ChangeCount {
+	counts := make([]ChangeCount, 0, len(m))
+	for name, count := range m {
+		counts = append(counts, ChangeCount{Name: name, Count: count, Percent: float64(count) / float64(c.Updates) * 100})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Name < counts[j].Name
+	})
+	if len(counts) > n {
+		counts = counts[:n]
+	}
+	return counts
+}
@@ -0,0 +1,75 @@
+package processor
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/db"
+	"github.com/waldirborbajr/sync/logger"
+)
+
+// ClockSkew is the difference between two clocks measured at run start
+type ClockSkew struct {
+	Clock    string        // "firebird", "mysql" or "firebird-mysql"
+	Skew     time.Duration // How far Clock is ahead of the host (of MySQL for "firebird-mysql")
+	Exceeded bool          // Beyond CLOCK_SKEW_MAX
+}
+
+// checkClockSkew compares the clocks of the host, Firebird and MySQL. A skew
+// beyond CLOCK_SKEW_MAX is logged, and fails the run with CLOCK_SKEW_STRICT.
+// A server whose time cannot be read is logged and left out.
+func checkClockSkew(ctx context.Context, firebirdDB, mysqlDB *sql.DB, cfg config.Config) ([]ClockSkew, error) {
+	if cfg.ClockSkewMax <= 0 {
+		return nil, nil
+	}
+	log := logger.GetLogger()
+
+	fb, fbErr := db.ClockSkew(ctx, firebirdDB, cfg, true)
+	if fbErr != nil {
+		log.Warn().Err(fbErr).Msg("Could not read the Firebird clock")
+	}
+	my, myErr := db.ClockSkew(ctx, mysqlDB, cfg, false)
+	if myErr != nil {
+		log.Warn().Err(myErr).Msg("Could not read the MySQL clock")
+	}
+
+	var skews []ClockSkew
+	if fbErr == nil {
+		skews = append(skews, ClockSkew{Clock: "firebird", Skew: fb})
+	}
+	if myErr == nil {
+		skews = append(skews, ClockSkew{Clock: "mysql", Skew: my})
+	}
+	if fbErr == nil && myErr == nil {
+		skews = append(skews, ClockSkew{Clock: "firebird-mysql", Skew: fb - my})
+	}
+
+	var exceeded []string
+	for i := range skews {
+		s := &skews[i]
+		s.Exceeded = s.Skew.Abs() > cfg.ClockSkewMax
+		if s.Exceeded {
+			exceeded = append(exceeded, s.String())
+			log.Warn().Str("clock", s.Clock).Dur("skew", s.Skew).Dur("max", cfg.ClockSkewMax).Msg("Clock skew beyond CLOCK_SKEW_MAX")
+		} else {
+			log.Debug().Str("clock", s.Clock).Dur("skew", s.Skew).Msg("Clock skew checked")
+		}
+	}
+	if len(exceeded) > 0 && cfg.ClockSkewStrict {
+		return skews, fmt.Errorf("clock skew beyond CLOCK_SKEW_MAX=%s (%s) with CLOCK_SKEW_STRICT on", cfg.ClockSkewMax, strings.Join(exceeded, ", "))
+	}
+	return skews, nil
+}
+
+// String returns the clock and its signed skew, rounded to the millisecond
+func (s ClockSkew) String() string {
+	d := s.Skew.Round(time.Millisecond)
+	if d >= 0 {
+		return s.Clock + " +" + d.String()
+	}
+	return s.Clock + " " + d.String()
+}
@@ -0,0 +1,329 @@
+package processor
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/waldirborbajr/sync/compare"
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/logger"
+	"github.com/waldirborbajr/sync/money"
+)
+
+// productField is a TB_ESTOQUE column besides the key: how it is read into a
+// mysqlRecord and taken from a RowOperation. Stored and current values are
+// compared by the column's comparator (PRODUCT_COMPARATORS, exact by default).
+type productField struct {
+	name   string                             // Built-in field name, see config.ProductFields
+	dest   func(rec *mysqlRecord) interface{} // Scan destination
+	stored func(rec *mysqlRecord) interface{} // Stored value, nil for NULL
+	value  func(op *RowOperation) interface{} // Value written
+	cmp    compare.Comparator                 // Default comparator
+}
+
+// builtinFields are the fixed product columns, in write order
+var builtinFields = []productField{
+	{
+		name:   "DESCRICAO",
+		dest:   func(rec *mysqlRecord) interface{} { return &rec.Descricao },
+		stored: func(rec *mysqlRecord) interface{} { return nullString(rec.Descricao) },
+		value:  func(op *RowOperation) interface{} { return op.Descricao },
+	},
+	{
+		name:   "QTD_ATUAL",
+		dest:   func(rec *mysqlRecord) interface{} { return &rec.Quantidade },
+		stored: func(rec *mysqlRecord) interface{} { return nullFloat(rec.Quantidade) },
+		value:  func(op *RowOperation) interface{} { return op.QtdAtual },
+	},
+	// A NULL price is current when the calculated price is zero, unless
+	// NULL_POLICIES handles the column (see nullableField.storedValue)
+	{
+		name:   "PRC_CUSTO",
+		dest:   func(rec *mysqlRecord) interface{} { return &rec.ValorCusto },
+		stored: func(rec *mysqlRecord) interface{} { return rec.ValorCusto.OrZero() },
+		value:  func(op *RowOperation) interface{} { return nullPrice(op.PrcCusto, op.PrcCustoNull) },
+	},
+	{
+		name:   "PRC_DOLAR",
+		dest:   func(rec *mysqlRecord) interface{} { return &rec.ValorUsd },
+		stored: func(rec *mysqlRecord) interface{} { return rec.ValorUsd.OrZero() },
+		value:  func(op *RowOperation) interface{} { return nullPrice(op.PrcDolar, op.PrcDolarNull) },
+	},
+	{
+		name:   "PRC_VENDA",
+		dest:   func(rec *mysqlRecord) interface{} { return &rec.PrcVenda },
+		stored: func(rec *mysqlRecord) interface{} { return rec.PrcVenda.OrZero() },
+		value:  func(op *RowOperation) interface{} { return op.PrcVenda },
+	},
+	{
+		name:   "PRC_3X",
+		dest:   func(rec *mysqlRecord) interface{} { return &rec.Prc3x },
+		stored: func(rec *mysqlRecord) interface{} { return rec.Prc3x.OrZero() },
+		value:  func(op *RowOperation) interface{} { return op.Prc3x },
+	},
+	{
+		name:   "PRC_6X",
+		dest:   func(rec *mysqlRecord) interface{} { return &rec.Prc6x },
+		stored: func(rec *mysqlRecord) interface{} { return rec.Prc6x.OrZero() },
+		value:  func(op *RowOperation) interface{} { return op.Prc6x },
+	},
+	{
+		name:   "PRC_10X",
+		dest:   func(rec *mysqlRecord) interface{} { return &rec.Prc10x },
+		stored: func(rec *mysqlRecord) interface{} { return rec.Prc10x.OrZero() },
+		value:  func(op *RowOperation) interface{} { return op.Prc10x },
+	},
+}
+
+// visibilityField is the STOCK_POLICY=hide visibility flag
+var visibilityField = productField{
+	name:   "VISIVEL",
+	dest:   func(rec *mysqlRecord) interface{} { return &rec.Visivel },
+	stored: func(rec *mysqlRecord) interface{} { return nullInt(rec.Visivel) },
+	value:  func(op *RowOperation) interface{} { return op.Visivel },
+}
+
+// statusField is the lifecycle status written with STATUS_MAP
+var statusField = productField{
+	name:   "STATUS",
+	dest:   func(rec *mysqlRecord) interface{} { return &rec.Status },
+	stored: func(rec *mysqlRecord) interface{} { return nullString(rec.Status) },
+	value:  func(op *RowOperation) interface{} { return op.Status },
+}
+
+// activeField is the SOFT_DELETE_STYLE=flag column, 1 for every row read from Firebird
+var activeField = productField{
+	name:   "ATIVO",
+	dest:   func(rec *mysqlRecord) interface{} { return &rec.Ativo },
+	stored: func(rec *mysqlRecord) interface{} { return nullInt(rec.Ativo) },
+	value:  func(op *RowOperation) interface{} { return 1 },
+}
+
+// deletedAtField is the SOFT_DELETE_STYLE=timestamp column, NULL for every row read from Firebird
+var deletedAtField = productField{
+	name:   "DELETED_AT",
+	dest:   func(rec *mysqlRecord) interface{} { return &rec.DeletedAt },
+	stored: func(rec *mysqlRecord) interface{} { return nullString(rec.DeletedAt) },
+	value:  func(op *RowOperation) interface{} { return nil },
+}
+
+// productColumn is a productField bound to its TB_ESTOQUE column and comparator
+type productColumn struct {
+	productField
+	column string
+}
+
+// equal reports whether the stored value of the column is current
+func (c productColumn) equal(rec *mysqlRecord, op *RowOperation) bool {
+	return c.cmp.Equal(c.stored(rec), c.value(op))
+}
+
+// productColumns returns the columns read, compared and written for the
+// configuration, in cfg.ProductColumnNames order: the built-in fields not
+// left out by PRODUCT_COLUMN_MAP, the policy columns and the PRODUCT_EXTRA_COLUMNS.
+// Quantity-only runs use QTD_ATUAL, the stock visibility flag and the soft
+// delete column alone.
+func productColumns(cfg config.Config) []productColumn {
+	var columns []productColumn
+	add := func(f productField, column string) {
+		if f.cmp == nil {
+			f.cmp = compare.Exact
+		}
+		if spec, ok := cfg.ProductComparators[column]; ok {
+			// Specs are validated by config.LoadConfig
+			if c, err := compare.New(spec); err == nil {
+				f.cmp = c
+			}
+		}
+		columns = append(columns, productColumn{productField: f, column: column})
+	}
+
+	for _, f := range builtinFields {
+		if column := cfg.ProductColumn(f.name); column != "" && (!cfg.QuantityOnly() || f.name == "QTD_ATUAL") {
+			if _, ok := cfg.NullPolicies[f.name]; ok {
+				i := slices.IndexFunc(nullableFields, func(n nullableField) bool { return n.name == f.name })
+				f.stored = nullableFields[i].storedValue
+			}
+			add(f, column)
+		}
+	}
+	if hidesStock(cfg) {
+		add(visibilityField, cfg.StockVisibilityColumn)
+	}
+	if cfg.SoftDeleteColumn != "" {
+		add(softDeleteField(cfg), cfg.SoftDeleteColumn)
+	}
+	if cfg.QuantityOnly() {
+		return columns
+	}
+	if mapsStatus(cfg) {
+		add(statusField, cfg.StatusColumn)
+	}
+	for i, extra := range cfg.ProductExtraColumns {
+		add(extraField(i), extra.Target)
+	}
+	return columns
+}
+
+// extraField is the i-th PRODUCT_EXTRA_COLUMNS entry, compared at the stored scale by default
+func extraField(i int) productField {
+	return productField{
+		name:   "EXTRA",
+		dest:   func(rec *mysqlRecord) interface{} { return &rec.Extra[i] },
+		stored: func(rec *mysqlRecord) interface{} { return normalizeValue(rec.Extra[i]) },
+		value:  func(op *RowOperation) interface{} { return op.Extra[i] },
+		cmp:    compare.StoredScale,
+	}
+}
+
+// nullString returns the value of s, or nil for NULL
+func nullString(s sql.NullString) interface{} {
+	if !s.Valid {
+		return nil
+	}
+	return s.String
+}
+
+// nullFloat returns the value of f, or nil for NULL
+func nullFloat(f sql.NullFloat64) interface{} {
+	if !f.Valid {
+		return nil
+	}
+	return f.Float64
+}
+
+// nullInt returns the value of i, or nil for NULL
+func nullInt(i sql.NullInt64) interface{} {
+	if !i.Valid {
+		return nil
+	}
+	return i.Int64
+}
+
+// productKeyColumn returns the TB_ESTOQUE key column
+func productKeyColumn(cfg config.Config) string {
+	return cfg.ProductColumn(config.ProductKey)
+}
+
+// expressionVars returns the variables of PRODUCT_EXTRA_COLUMNS and
+// PRODUCT_TRANSFORMS expressions for op. Quantity-only runs calculate no
+// prices, so theirs are NULL, as are the prices NULL_POLICIES writes as NULL.
+func expressionVars(op *RowOperation, src sourceRow, cfg config.Config) map[string]interface{} {
+	vars := map[string]interface{}{
+		config.ProductKey: op.IDEstoque,
+		"DESCRICAO":       op.Descricao,
+		"QTD_ATUAL":       op.QtdAtual,
+		"PRC_CUSTO":       op.PrcCusto.Float64(),
+		"PRC_DOLAR":       op.PrcDolar.Float64(),
+		"PRC_VENDA":       op.PrcVenda.Float64(),
+		"PRC_3X":          op.Prc3x.Float64(),
+		"PRC_6X":          op.Prc6x.Float64(),
+		"PRC_10X":         op.Prc10x.Float64(),
+		"ID_GRUPO":        op.IDGrupo,
+		"STATUS":          src.Status,
+	}
+	if op.PrcCustoNull {
+		vars["PRC_CUSTO"] = nil
+	}
+	if op.PrcDolarNull {
+		vars["PRC_DOLAR"] = nil
+	}
+	if cfg.QuantityOnly() {
+		for _, field := range []string{"PRC_CUSTO", "PRC_DOLAR", "PRC_VENDA", "PRC_3X", "PRC_6X", "PRC_10X"} {
+			vars[field] = nil
+		}
+	}
+	return vars
+}
+
+// evalExtraColumns computes the PRODUCT_EXTRA_COLUMNS values of op from its
+// final values. A failing expression writes NULL and reports false.
+func evalExtraColumns(op *RowOperation, src sourceRow, cfg config.Config) bool {
+	if len(cfg.ProductExtraColumns) == 0 {
+		return true
+	}
+
+	vars := expressionVars(op, src, cfg)
+	ok := true
+	op.Extra = make([]interface{}, len(cfg.ProductExtraColumns))
+	for i, extra := range cfg.ProductExtraColumns {
+		v, err := extra.Expr.Eval(vars)
+		if err != nil {
+			log := logger.GetLogger()
+			log.Warn().Err(err).Int("id_estoque", op.IDEstoque).Str("column", extra.Target).Msg("Extra column expression failed, writing NULL")
+			ok = false
+			continue
+		}
+		op.Extra[i] = v
+	}
+	return ok
+}
+
+// applyTransforms replaces the calculated values of op by the
+// PRODUCT_TRANSFORMS results, in order. Quantity-only runs only apply the
+// QTD_ATUAL transforms. A transform failing, or yielding NULL or a value of
+// the wrong type, keeps the value it would replace and reports false.
+func applyTransforms(op *RowOperation, src sourceRow, cfg config.Config) bool {
+	if len(cfg.ProductTransforms) == 0 {
+		return true
+	}
+
+	vars := expressionVars(op, src, cfg)
+	ok := true
+	for _, t := range cfg.ProductTransforms {
+		if cfg.QuantityOnly() && t.Field != "QTD_ATUAL" {
+			continue
+		}
+		v, err := t.Expr.Eval(vars)
+		if err == nil {
+			err = setField(op, t.Field, v)
+		}
+		if err != nil {
+			log := logger.GetLogger()
+			log.Warn().Err(err).Int("id_estoque", op.IDEstoque).Str("field", t.Field).Msg("Transform failed, keeping the calculated value")
+			ok = false
+			continue
+		}
+		vars[t.Field] = v
+	}
+	return ok
+}
+
+// setField sets a built-in field of op to the expression result v
+func setField(op *RowOperation, field string, v interface{}) error {
+	if v == nil {
+		return fmt.Errorf("transform of %s yielded NULL", field)
+	}
+	if field == "DESCRICAO" {
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("transform of DESCRICAO yielded %v, not a string", v)
+		}
+		op.Descricao = s
+		return nil
+	}
+
+	f, ok := v.(float64)
+	if !ok || math.IsNaN(f) || math.IsInf(f, 0) {
+		return fmt.Errorf("transform of %s yielded %v, not a number", field, v)
+	}
+	switch field {
+	case "QTD_ATUAL":
+		op.QtdAtual = f
+	case "PRC_CUSTO":
+		op.PrcCusto, op.PrcCustoNull = money.FromFloat(f), false
+	case "PRC_DOLAR":
+		op.PrcDolar, op.PrcDolarNull = money.FromFloat(f), false
+	case "PRC_VENDA":
+		op.PrcVenda = money.FromFloat(f)
+	case "PRC_3X":
+		op.Prc3x = money.FromFloat(f)
+	case "PRC_6X":
+		op.Prc6x = money.FromFloat(f)
+	case "PRC_10X":
+		op.Prc10x = money.FromFloat(f)
+	}
+	return nil
+}
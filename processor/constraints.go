@@ -0,0 +1,102 @@
+package processor
+
+import (
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/logger"
+	"github.com/waldirborbajr/sync/money"
+)
+
+// constraintViolation flags which price constraints a row violated
+type constraintViolation uint8
+
+const (
+	violationMinMargin constraintViolation = 1 << iota
+	violationFloor
+	violationMaxDrop
+)
+
+// ConstraintStats counts price constraint violations found during the run
+type ConstraintStats struct {
+	BelowMinMargin int // PRC_VENDA below cost plus PRICE_MIN_MARGIN
+	BelowFloor     int // PRC_VENDA below the PRICE_FLOORS value of its group
+	ExceededDrop   int // PRC_VENDA reduced by more than PRICE_MAX_DROP
+	Clamped        int // Rows whose prices were raised to the allowed minimum
+	Flagged        int // Rows reported but written with the calculated price
+}
+
+// observe accounts for the violations recorded on op
+func (s *ConstraintStats) observe(op RowOperation, policy string) {
+	if op.violations == 0 {
+		return
+	}
+	if op.violations&violationMinMargin != 0 {
+		s.BelowMinMargin++
+	}
+	if op.violations&violationFloor != 0 {
+		s.BelowFloor++
+	}
+	if op.violations&violationMaxDrop != 0 {
+		s.ExceededDrop++
+	}
+	if policy == config.ConstraintFlag {
+		s.Flagged++
+	} else {
+		s.Clamped++
+	}
+}
+
+// applyPriceConstraints enforces the minimum margin, category floor and
+// maximum drop on the calculated PRC_VENDA of op. Rows without a calculated
+// price are left alone. With the clamp policy the sale price is raised to the
+// lowest allowed value and the installment prices are derived from it again.
+func applyPriceConstraints(op *RowOperation, cfg config.Config) {
+	if op.PrcVenda == 0 {
+		return
+	}
+
+	var minimum money.Cents
+	raise := func(v constraintViolation, limit money.Cents) {
+		if op.PrcVenda < limit {
+			op.violations |= v
+			if limit > minimum {
+				minimum = limit
+			}
+		}
+	}
+
+	if cfg.MinMargin > 0 && op.PrcCusto > 0 {
+		raise(violationMinMargin, money.Scale(op.PrcCusto, 1, cfg.MinMargin))
+	}
+	if floor, ok := cfg.CategoryFloors[op.IDGrupo]; ok {
+		raise(violationFloor, floor)
+	}
+	if cfg.MaxPriceDrop > 0 && op.existing != nil {
+		if old := op.existing.PrcVenda.OrZero(); old > 0 {
+			raise(violationMaxDrop, money.Scale(old, 1, -cfg.MaxPriceDrop))
+		}
+	}
+
+	if op.violations == 0 {
+		return
+	}
+
+	log := logger.GetLogger()
+	if cfg.PriceConstraintPolicy == config.ConstraintFlag {
+		log.Warn().
+			Int("id_estoque", op.IDEstoque).
+			Str("prc_venda", op.PrcVenda.String()).
+			Str("minimum", minimum.String()).
+			Msg("Calculated price violates price constraints")
+		return
+	}
+
+	log.Debug().
+		Int("id_estoque", op.IDEstoque).
+		Str("prc_venda", op.PrcVenda.String()).
+		Str("clamped_to", minimum.String()).
+		Msg("Calculated price clamped by price constraints")
+	op.PrcVenda = minimum
+	op.Prc3x = money.Scale(minimum, 3, op.margins.Parc3x)
+	op.Prc6x = money.Scale(minimum, 6, op.margins.Parc6x)
+	op.Prc10x = money.Scale(minimum, 10, op.margins.Parc10x)
+}
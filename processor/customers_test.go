@@ -0,0 +1,61 @@
+package processor
+
+import (
+	"database/sql"
+	"testing"
+)
+
+// customerQuery reads the customers firebirdCustomers adds to the Firebird mock
+const customerQuery = "SELECT ID_CLIENTE, NOME, CPF_CNPJ, EMAIL, TELEFONE, STATUS FROM TB_CLIENTE"
+
+// firebirdCustomers adds TB_CLIENTE with customers 1 and 2 to the Firebird mock
+func firebirdCustomers(t *testing.T, firebirdDB *sql.DB) {
+	t.Helper()
+	execAll(t, firebirdDB,
+		"CREATE TABLE TB_CLIENTE (ID_CLIENTE INTEGER PRIMARY KEY, NOME TEXT, CPF_CNPJ TEXT, EMAIL TEXT, TELEFONE TEXT, STATUS TEXT)",
+		"INSERT INTO TB_CLIENTE VALUES (1, 'Ana', '111', 'ana@example.com', '555-0001', 'A'), (2, 'Bruno', '222', 'bruno@example.com', NULL, 'A')",
+	)
+}
+
+func TestCustomerSyncRoundTrip(t *testing.T) {
+	cfg, firebirdDB, mysqlDB := devDatabases(t, map[string]string{"CUSTOMER_QUERY": customerQuery})
+	firebirdCustomers(t, firebirdDB)
+
+	_, _, _, stats := syncDev(t, cfg, firebirdDB, mysqlDB)
+	if cs := stats.Customers; cs == nil || cs.Inserted != 2 {
+		t.Fatalf("customer stats = %+v; want 2 inserted", cs)
+	}
+	if n := countRows(t, mysqlDB, "SELECT COUNT(*) FROM TB_CLIENTE WHERE ID_CLIENTE = 2 AND NOME = 'Bruno' AND TELEFONE IS NULL"); n != 1 {
+		t.Error("customer 2 not copied")
+	}
+
+	// E-mails compare case-insensitively; a renamed customer is updated
+	execAll(t, mysqlDB, "UPDATE TB_CLIENTE SET EMAIL = 'ANA@EXAMPLE.COM' WHERE ID_CLIENTE = 1")
+	execAll(t, firebirdDB, "UPDATE TB_CLIENTE SET NOME = 'Bruno Souza' WHERE ID_CLIENTE = 2")
+	_, _, _, stats = syncDev(t, cfg, firebirdDB, mysqlDB)
+	if cs := stats.Customers; cs.Inserted != 0 || cs.Updated != 1 || cs.Ignored != 1 {
+		t.Errorf("second run customer stats = %+v; want 1 updated, 1 ignored", cs)
+	}
+	if n := countRows(t, mysqlDB, "SELECT COUNT(*) FROM TB_CLIENTE WHERE ID_CLIENTE = 2 AND NOME = 'Bruno Souza'"); n != 1 {
+		t.Error("renamed customer not updated")
+	}
+}
+
+func TestCustomerSyncDisabled(t *testing.T) {
+	for _, values := range []map[string]string{
+		{},
+		{"CUSTOMER_QUERY": customerQuery, "SYNC_MODE": "quantity"},
+	} {
+		cfg, firebirdDB, mysqlDB := devDatabases(t, values)
+		firebirdCustomers(t, firebirdDB)
+		execAll(t, mysqlDB, "CREATE TABLE IF NOT EXISTS TB_CLIENTE (ID_CLIENTE INTEGER NOT NULL PRIMARY KEY, NOME TEXT, CPF_CNPJ TEXT, EMAIL TEXT, TELEFONE TEXT, STATUS TEXT)")
+
+		_, _, _, stats := syncDev(t, cfg, firebirdDB, mysqlDB)
+		if stats.Customers != nil {
+			t.Errorf("%v: customer sync ran: %+v", values, stats.Customers)
+		}
+		if n := countRows(t, mysqlDB, "SELECT COUNT(*) FROM TB_CLIENTE"); n != 0 {
+			t.Errorf("%v: %d customers written", values, n)
+		}
+	}
+}
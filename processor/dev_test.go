@@ -0,0 +1,92 @@
+package processor
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/db"
+	"github.com/waldirborbajr/sync/transfer"
+)
+
+// devDatabases opens the SQLite mocks of Firebird and MySQL (DEV_MODE) in a
+// temporary directory: Firebird with its seven sample products, MySQL with
+// an empty TB_ESTOQUE. values are the settings on top of the defaults.
+func devDatabases(t *testing.T, values map[string]string) (config.Config, *sql.DB, *sql.DB) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+
+	settings := map[string]string{"DEV_MODE": "true"}
+	for k, v := range values {
+		settings[k] = v
+	}
+	cfg, err := config.Parse(settings)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	firebirdDB, err := db.ConnectFirebird(cfg)
+	if err != nil {
+		t.Fatalf("ConnectFirebird() error = %v", err)
+	}
+	t.Cleanup(func() { _ = firebirdDB.Close() })
+	mysqlDB, err := db.ConnectMySQL(cfg)
+	if err != nil {
+		t.Fatalf("ConnectMySQL() error = %v", err)
+	}
+	t.Cleanup(func() { _ = mysqlDB.Close() })
+	return cfg, firebirdDB, mysqlDB
+}
+
+// execAll runs the statements on conn, failing the test on the first error
+func execAll(t *testing.T, conn *sql.DB, statements ...string) {
+	t.Helper()
+	for _, stmt := range statements {
+		if _, err := conn.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+}
+
+// countRows returns the result of a SELECT COUNT(*) query
+func countRows(t *testing.T, conn *sql.DB, query string, args ...interface{}) int {
+	t.Helper()
+	var n int
+	if err := conn.QueryRow(query, args...).Scan(&n); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return n
+}
+
+// devWriter returns a writer of the MySQL mock's TB_ESTOQUE
+func devWriter(cfg config.Config, mysqlDB *sql.DB) *writer {
+	return &writer{db: mysqlDB, cfg: cfg, key: productKeyColumn(cfg), columns: productColumns(cfg), retry: transfer.NewRetrier(cfg)}
+}
+
+// writeOps has a worker write ops with w and returns the rows it inserted
+// and updated
+func writeOps(w *writer, ops ...RowOperation) (inserted, updated int64) {
+	work := make(chan []RowOperation, 1)
+	work <- ops
+	close(work)
+
+	var insertedCount, updatedCount, ignoredCount atomic.Int64
+	var wg sync.WaitGroup
+	wg.Add(1)
+	worker(context.Background(), 0, work, w, &insertedCount, &updatedCount, &ignoredCount, &wg)
+	return insertedCount.Load(), updatedCount.Load()
+}
+
+// syncDev runs ProcessRows from the Firebird mock into the MySQL mock and
+// returns the rows inserted, updated and ignored
+func syncDev(t *testing.T, cfg config.Config, firebirdDB, mysqlDB *sql.DB) (inserted, updated, ignored int, stats *ProcessingStats) {
+	t.Helper()
+	inserted, updated, ignored, _, stats, err := ProcessRows(context.Background(), firebirdDB, mysqlDB, 2, cfg)
+	if err != nil {
+		t.Fatalf("ProcessRows() error = %v", err)
+	}
+	return inserted, updated, ignored, stats
+}
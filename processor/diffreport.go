@@ -0,0 +1,212 @@
+package processor
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/waldirborbajr/sync/compare"
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/logger"
+)
+
+// diffHeader is the header line of a CSV diff report
+var diffHeader = []string{"run_id", "id_estoque", "operation", "column", "before", "after"}
+
+// diffRow is a row of a JSON diff report
+type diffRow struct {
+	RunID     string                 `json:"run_id"`
+	IDEstoque int                    `json:"id_estoque"`
+	Operation string                 `json:"operation"`
+	Before    map[string]interface{} `json:"before,omitempty"`
+	After     map[string]interface{} `json:"after"`
+}
+
+// diffReport writes DIFF_REPORT_FILE as batches commit, into a temporary
+// file renamed over the report once the run succeeds, so readers never see
+// a partial report. Failing to write it is logged and never fails the run.
+type diffReport struct {
+	mu      sync.Mutex
+	path    string
+	runID   string
+	format  string
+	columns []productColumn
+	tmp     *os.File
+	buf     *bufio.Writer
+	csv     *csv.Writer // Nil for JSON
+	rows    int
+	err     error // First write error, after which nothing more is written
+}
+
+// newDiffReport starts the diff report of the run, nil without DIFF_REPORT_FILE
+func newDiffReport(cfg config.Config, runID string, columns []productColumn) *diffReport {
+	if cfg.DiffReportFile == "" {
+		return nil
+	}
+
+	d := &diffReport{
+		path:    strings.ReplaceAll(cfg.DiffReportFile, "{run_id}", runID),
+		runID:   runID,
+		format:  cfg.DiffReportFormat,
+		columns: columns,
+	}
+	dir := filepath.Dir(d.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		d.fail(fmt.Errorf("error creating diff report directory: %w", err))
+		return d
+	}
+	tmp, err := os.CreateTemp(dir, ".diff-*")
+	if err != nil {
+		d.fail(fmt.Errorf("error creating diff report: %w", err))
+		return d
+	}
+	d.tmp, d.buf = tmp, bufio.NewWriter(tmp)
+	if err := tmp.Chmod(0o644); err != nil {
+		d.fail(fmt.Errorf("error creating diff report: %w", err))
+		return d
+	}
+
+	if d.format == config.DiffReportJSON {
+		_, err = d.buf.WriteString("[")
+	} else {
+		d.csv = csv.NewWriter(d.buf)
+		err = d.csv.Write(diffHeader)
+	}
+	if err != nil {
+		d.fail(fmt.Errorf("error writing diff report: %w", err))
+	}
+	return d
+}
+
+// add writes the changed columns of ops, committed inserts and updates
+func (d *diffReport) add(ops []RowOperation) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.err != nil {
+		return
+	}
+
+	for i := range ops {
+		op := &ops[i]
+		cs := columnChanges(op, d.columns)
+		if len(cs) == 0 {
+			continue
+		}
+		operation := "insert"
+		if op.existing != nil {
+			operation = "update"
+		}
+
+		var err error
+		if d.csv != nil {
+			err = d.writeCSV(op.IDEstoque, operation, cs)
+		} else {
+			err = d.writeJSON(op.IDEstoque, operation, cs)
+		}
+		if err != nil {
+			d.fail(fmt.Errorf("error writing diff report: %w", err))
+			return
+		}
+		d.rows++
+	}
+}
+
+// writeCSV writes a line per changed column
+func (d *diffReport) writeCSV(key int, operation string, cs []columnChange) error {
+	id := strconv.Itoa(key)
+	for _, c := range cs {
+		if err := d.csv.Write([]string{d.runID, id, operation, c.column, compare.Format(c.before), compare.Format(c.after)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeJSON writes the row as an element of the array
+func (d *diffReport) writeJSON(key int, operation string, cs []columnChange) error {
+	row := diffRow{RunID: d.runID, IDEstoque: key, Operation: operation, After: make(map[string]interface{}, len(cs))}
+	if operation == "update" {
+		row.Before = make(map[string]interface{}, len(cs))
+	}
+	for _, c := range cs {
+		if row.Before != nil {
+			row.Before[c.column] = auditValue(c.before)
+		}
+		row.After[c.column] = auditValue(c.after)
+	}
+	b, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+
+	sep := ",\n  "
+	if d.rows == 0 {
+		sep = "\n  "
+	}
+	if _, err := d.buf.WriteString(sep); err != nil {
+		return err
+	}
+	_, err = d.buf.Write(b)
+	return err
+}
+
+// fail records the first error and gives up on the report
+func (d *diffReport) fail(err error) {
+	d.err = err
+	log := logger.GetLogger()
+	log.Error().Err(err).Str("path", d.path).Msg("Diff report abandoned")
+}
+
+// close completes the report and moves it into place, returning its path
+// and the number of rows it lists
+func (d *diffReport) close() (path string, rows int, err error) {
+	if d == nil {
+		return "", 0, nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	defer d.discard()
+	if d.err != nil {
+		return "", 0, d.err
+	}
+
+	if d.csv != nil {
+		d.csv.Flush()
+		err = d.csv.Error()
+	} else {
+		_, err = d.buf.WriteString("\n]\n")
+	}
+	if err == nil {
+		err = d.buf.Flush()
+	}
+	if closeErr := d.tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(d.tmp.Name(), d.path)
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("error writing diff report %s: %w", d.path, err)
+	}
+	d.tmp = nil
+	return d.path, d.rows, nil
+}
+
+// discard removes the temporary file of a report not moved into place
+func (d *diffReport) discard() {
+	if d == nil || d.tmp == nil {
+		return
+	}
+	d.tmp.Close()
+	os.Remove(d.tmp.Name())
+	d.tmp = nil
+}
@@ -0,0 +1,71 @@
+package processor
+
+import (
+	"context"
+	"time"
+
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/exchange"
+	"github.com/waldirborbajr/sync/logger"
+	"github.com/waldirborbajr/sync/state"
+)
+
+// How the exchange rate of a run was obtained
+const (
+	RateCached   = "cache"       // Fetched by an earlier run within EXCHANGE_RATE_CACHE_TTL
+	RateFetched  = "fetched"     // Fetched by this run
+	RateStale    = "stale cache" // Fetching failed, the last fetched rate is older than EXCHANGE_RATE_CACHE_TTL
+	RateFallback = "fallback"    // Fetching failed and no rate was ever fetched: EXCHANGE_RATE_FALLBACK
+)
+
+// ExchangeRateStats is the USD/BRL rate PRC_DOLAR was derived with for
+// products without a TB_EST_INDEXADOR value
+type ExchangeRateStats struct {
+	Rate      float64   // 0 when no rate was available
+	Source    string    // One of the Rate* constants, "" when no rate was available
+	FetchedAt time.Time // When the rate was fetched, zero for the fallback
+	Converted int       // Rows whose PRC_DOLAR was derived from PRC_CUSTO
+}
+
+// loadExchangeRate returns the rate of the run, nil when
+// EXCHANGE_RATE_PROVIDER is not set. A cached rate younger than
+// EXCHANGE_RATE_CACHE_TTL is used as is; otherwise the rate is fetched and
+// cached, falling back to the cached rate or EXCHANGE_RATE_FALLBACK. A run
+// never fails for want of a rate.
+func loadExchangeRate(ctx context.Context, cfg config.Config) *ExchangeRateStats {
+	if cfg.ExchangeRateProvider == "" {
+		return nil
+	}
+	log := logger.GetLogger()
+
+	st, err := state.Load(cfg.StateFile)
+	if err != nil {
+		log.Warn().Err(err).Msg("Could not read the cached exchange rate")
+	}
+	if st.Caches.ExchangeRate > 0 && time.Since(st.Caches.ExchangeRateAt) < cfg.ExchangeRateCacheTTL {
+		return &ExchangeRateStats{Rate: st.Caches.ExchangeRate, Source: RateCached, FetchedAt: st.Caches.ExchangeRateAt}
+	}
+
+	rate, err := exchange.Fetch(ctx, cfg.ExchangeRateProvider, cfg.ExchangeRateURL)
+	if err == nil {
+		now := time.Now()
+		if _, saveErr := state.Update(cfg.StateFile, func(s *state.State) {
+			s.Caches.ExchangeRate, s.Caches.ExchangeRateAt = rate, now
+		}); saveErr != nil {
+			log.Warn().Err(saveErr).Msg("Could not cache the exchange rate")
+		}
+		log.Info().Float64("rate", rate).Str("provider", cfg.ExchangeRateProvider).Msg("USD/BRL exchange rate fetched")
+		return &ExchangeRateStats{Rate: rate, Source: RateFetched, FetchedAt: now}
+	}
+
+	switch {
+	case st.Caches.ExchangeRate > 0:
+		log.Warn().Err(err).Float64("rate", st.Caches.ExchangeRate).Time("fetched_at", st.Caches.ExchangeRateAt).Msg("Could not fetch the exchange rate, using the last fetched one")
+		return &ExchangeRateStats{Rate: st.Caches.ExchangeRate, Source: RateStale, FetchedAt: st.Caches.ExchangeRateAt}
+	case cfg.ExchangeRateFallback > 0:
+		log.Warn().Err(err).Float64("rate", cfg.ExchangeRateFallback).Msg("Could not fetch the exchange rate, using EXCHANGE_RATE_FALLBACK")
+		return &ExchangeRateStats{Rate: cfg.ExchangeRateFallback, Source: RateFallback}
+	}
+	log.Warn().Err(err).Msg("Could not fetch the exchange rate and no fallback is set, PRC_DOLAR stays 0 without an indexer value")
+	return &ExchangeRateStats{}
+}
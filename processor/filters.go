@@ -0,0 +1,45 @@
+package processor
+
+import "github.com/waldirborbajr/sync/config"
+
+// filteredOut reports whether src fails a ROW_FILTERS rule applied after
+// the scan (ROW_FILTER_MODE=scan)
+func filteredOut(cfg config.Config, src sourceRow) bool {
+	if cfg.RowFilterMode != config.FilterScan {
+		return false
+	}
+	return !matchesAll(cfg.RowFilters, src)
+}
+
+// matchesAll reports whether src satisfies every rule of filters
+func matchesAll(filters []config.RowFilter, src sourceRow) bool {
+	for _, f := range filters {
+		if !matches(f, src) {
+			return false
+		}
+	}
+	return true
+}
+
+// matches reports whether src satisfies f; a NULL value matches no rule
+func matches(f config.RowFilter, src sourceRow) bool {
+	switch f.Column {
+	case "ID_ESTOQUE":
+		return f.MatchNumber(float64(src.IDEstoque))
+	case "ID_GRUPO":
+		return src.HasGrupo && f.MatchNumber(float64(src.IDGrupo))
+	case "QTD_ATUAL":
+		return f.MatchNumber(src.QtdAtual)
+	case "PRC_CUSTO":
+		return src.PrcCusto.Valid && f.MatchNumber(src.PrcCusto.Cents.Float64())
+	case "PRC_DOLAR":
+		return src.PrcDolar.Valid && f.MatchNumber(src.PrcDolar.Cents.Float64())
+	case "STATUS":
+		return src.HasStatus && f.MatchText(src.Status)
+	case "DESCRICAO":
+		return f.MatchText(src.Descricao)
+	case config.PricingSupplier:
+		return src.HasSupplier && f.MatchText(src.Supplier)
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package processor
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/waldirborbajr/sync/logger"
+)
+
+// priceChanged reports whether an operation changes any price column.
+// Inserts always count, since they record the initial price of a product.
+func priceChanged(op RowOperation) bool {
+	if op.Type == OpInsert {
+		return true
+	}
+	if op.existing == nil {
+		return false
+	}
+	rec := op.existing
+	return rec.ValorCusto.OrZero() != op.PrcCusto ||
+		rec.PrcVenda.OrZero() != op.PrcVenda ||
+		rec.Prc3x.OrZero() != op.Prc3x ||
+		rec.Prc6x.OrZero() != op.Prc6x ||
+		rec.Prc10x.OrZero() != op.Prc10x
+}
+
+// insertPriceHistory records the price changes of ops into TB_PRECO_HISTORICO
+// using a single multi-value INSERT inside the writer transaction
+func insertPriceHistory(tx *sql.Tx, runID string, ops []RowOperation, at time.Time) (int, error) {
+	var sb strings.Builder
+	sb.WriteString(`INSERT INTO TB_PRECO_HISTORICO (ID_ESTOQUE,
+		PRC_CUSTO_ANTERIOR, PRC_CUSTO_NOVO, PRC_VENDA_ANTERIOR, PRC_VENDA_NOVO,
+		PRC_3X_ANTERIOR, PRC_3X_NOVO, PRC_6X_ANTERIOR, PRC_6X_NOVO,
+		PRC_10X_ANTERIOR, PRC_10X_NOVO, RUN_ID, DT_ALTERACAO) VALUES `)
+
+	values := make([]interface{}, 0, len(ops)*13)
+	count := 0
+	for _, op := range ops {
+		if !priceChanged(op) {
+			continue
+		}
+
+		var prev mysqlRecord
+		if op.existing != nil {
+			prev = *op.existing
+		}
+
+		if count > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString("(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+		values = append(values, op.IDEstoque,
+			prev.ValorCusto, nullPrice(op.PrcCusto, op.PrcCustoNull), prev.PrcVenda, op.PrcVenda,
+			prev.Prc3x, op.Prc3x, prev.Prc6x, op.Prc6x,
+			prev.Prc10x, op.Prc10x, runID, at)
+		count++
+	}
+
+	if count == 0 {
+		return 0, nil
+	}
+
+	if _, err := tx.Exec(sb.String(), values...); err != nil {
+		return 0, fmt.Errorf("price history insert failed: %w", err)
+	}
+	return count, nil
+}
+
+// purgePriceHistory deletes history rows older than retentionDays
+func purgePriceHistory(db *sql.DB, retentionDays int) (int64, error) {
+	if retentionDays <= 0 {
+		return 0, nil
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	res, err := db.Exec("DELETE FROM TB_PRECO_HISTORICO WHERE DT_ALTERACAO < ?", cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("error purging TB_PRECO_HISTORICO: %w", err)
+	}
+
+	purged, _ := res.RowsAffected()
+	if purged > 0 {
+		log := logger.GetLogger()
+		log.Info().Int64("rows", purged).Int("retention_days", retentionDays).Msg("Purged old price history")
+	}
+	return purged, nil
+}
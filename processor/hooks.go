@@ -0,0 +1,78 @@
+package processor
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/waldirborbajr/sync/logger"
+)
+
+// runIDPlaceholder is replaced with the quoted run ID in POST_SYNC_SQL statements
+const runIDPlaceholder = "{run_id}"
+
+// changedKeysChunk is the number of rows per multi-value insert into TB_SYNC_ALTERADOS
+const changedKeysChunk = 500
+
+// writeChangedKeys stores the keys written by this run in TB_SYNC_ALTERADOS
+// under runID, replacing the rows of previous runs, so downstream SQL can
+// join on it (WHERE RUN_ID = '{run_id}') instead of scanning TB_ESTOQUE.
+func writeChangedKeys(ctx context.Context, db *sql.DB, runID string, ids []int) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM TB_SYNC_ALTERADOS WHERE RUN_ID <> ?", runID); err != nil {
+		return fmt.Errorf("error clearing TB_SYNC_ALTERADOS: %w", err)
+	}
+
+	for start := 0; start < len(ids); start += changedKeysChunk {
+		end := min(start+changedKeysChunk, len(ids))
+
+		values := make([]interface{}, 0, 2*(end-start))
+		for _, id := range ids[start:end] {
+			values = append(values, runID, id)
+		}
+		query := "INSERT INTO TB_SYNC_ALTERADOS (RUN_ID, ID_ESTOQUE) VALUES (?, ?)" + strings.Repeat(", (?, ?)", end-start-1)
+		if _, err := tx.ExecContext(ctx, query, values...); err != nil {
+			return fmt.Errorf("error writing TB_SYNC_ALTERADOS: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("changed keys commit failed: %w", err)
+	}
+
+	log := logger.GetLogger()
+	log.Debug().Str("run_id", runID).Int("keys", len(ids)).Msg("Changed keys written to TB_SYNC_ALTERADOS")
+	return nil
+}
+
+// runPostSyncHooks executes the POST_SYNC_SQL statements in order, with
+// {run_id} replaced by the quoted run ID. It returns the number executed.
+func runPostSyncHooks(ctx context.Context, db *sql.DB, runID, script string) (int, error) {
+	log := logger.GetLogger()
+
+	executed := 0
+	for _, stmt := range strings.Split(script, ";") {
+		stmt = strings.TrimSpace(stmt)
+		if stmt == "" {
+			continue
+		}
+		stmt = strings.ReplaceAll(stmt, runIDPlaceholder, "'"+runID+"'")
+
+		res, err := db.ExecContext(ctx, stmt)
+		if err != nil {
+			log.Error().Err(err).Str("statement", stmt).Msg("Post-sync hook failed")
+			return executed, fmt.Errorf("post-sync hook %d failed: %w", executed+1, err)
+		}
+		executed++
+
+		affected, _ := res.RowsAffected()
+		log.Debug().Str("statement", stmt).Int64("rows", affected).Msg("Post-sync hook executed")
+	}
+	return executed, nil
+}
@@ -0,0 +1,66 @@
+package processor
+
+import (
+	"time"
+
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/logger"
+	"github.com/waldirborbajr/sync/state"
+)
+
+// productsWatermark is the state key of the TB_ESTOQUE incremental watermark
+const productsWatermark = "TB_ESTOQUE"
+
+// incremental reports whether only rows modified since the last successful run
+// are read. Quantity-only runs read every row and leave the watermark alone, so
+// the next full run still picks up the price changes.
+func incremental(cfg config.Config) bool {
+	return cfg.IncrementalColumn != "" && !cfg.QuantityOnly()
+}
+
+// loadWatermark returns the lower bound for the incremental query: the last
+// persisted watermark minus INCREMENTAL_OVERLAP, so rows committed late with
+// an earlier timestamp are still picked up. A zero time means a full run.
+func loadWatermark(cfg config.Config) (time.Time, error) {
+	st, err := state.Load(cfg.StateFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+	wm, ok := st.Watermarks[productsWatermark]
+	if !ok || wm.IsZero() {
+		return time.Time{}, nil
+	}
+	return wm.Add(-cfg.IncrementalOverlap), nil
+}
+
+// advanceWatermark saves the watermark of the run unless rows it read were
+// not synced: unwritten by a failed batch, rejected by MySQL or unscanned.
+// Their modification times are behind the watermark, so saving it would
+// leave them out of every later incremental read.
+func advanceWatermark(cfg config.Config, stats *ProcessingStats) error {
+	if stats.UnwrittenRows > 0 || len(stats.RejectedRows) > 0 || stats.UnscannedRows > 0 {
+		log := logger.GetLogger()
+		log.Warn().Int("unwritten", stats.UnwrittenRows).Int("rejected", len(stats.RejectedRows)).
+			Int("unscanned", stats.UnscannedRows).Msg("Rows not synced, incremental watermark kept for the next run to read them again")
+		return nil
+	}
+	return saveWatermark(cfg, stats.Watermark)
+}
+
+// saveWatermark persists the highest modification time seen by a successful run.
+// The watermark only moves forward and comes from Firebird data, not the local
+// clock, so clock differences between hosts cannot skip rows.
+func saveWatermark(cfg config.Config, wm time.Time) error {
+	if wm.IsZero() {
+		return nil
+	}
+	_, err := state.Update(cfg.StateFile, func(s *state.State) {
+		if s.Watermarks == nil {
+			s.Watermarks = make(map[string]time.Time)
+		}
+		if wm.After(s.Watermarks[productsWatermark]) {
+			s.Watermarks[productsWatermark] = wm
+		}
+	})
+	return err
+}
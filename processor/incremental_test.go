@@ -0,0 +1,52 @@
+package processor
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/waldirborbajr/sync/config"
+)
+
+func TestWatermarkKeptForRowsNotSynced(t *testing.T) {
+	start := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	failedAt, lastAt := start.Add(time.Hour), start.Add(2*time.Hour)
+
+	tests := []struct {
+		name   string
+		failed ProcessingStats
+	}{
+		{"failed batch", ProcessingStats{UnwrittenRows: 1}},
+		{"rejected row", ProcessingStats{RejectedRows: []int{1001}}},
+		{"unscanned row", ProcessingStats{UnscannedRows: 1}},
+	}
+	for _, tt := range tests {
+		cfg := config.Config{IncrementalColumn: "DT_ALTERACAO", StateFile: filepath.Join(t.TempDir(), "state.json")}
+		if err := saveWatermark(cfg, start); err != nil {
+			t.Fatalf("%s: saveWatermark() error = %v", tt.name, err)
+		}
+
+		// The run reads a row changed at failedAt it does not sync, and one
+		// changed at lastAt it does
+		stats := tt.failed
+		stats.Watermark = lastAt
+		if err := advanceWatermark(cfg, &stats); err != nil {
+			t.Fatalf("%s: advanceWatermark() error = %v", tt.name, err)
+		}
+		since, err := loadWatermark(cfg)
+		if err != nil {
+			t.Fatalf("%s: loadWatermark() error = %v", tt.name, err)
+		}
+		if !since.Before(failedAt) {
+			t.Errorf("%s: next run reads rows after %v; want the row changed at %v read again", tt.name, since, failedAt)
+		}
+
+		// The next run syncs it: the watermark moves on
+		if err := advanceWatermark(cfg, &ProcessingStats{Watermark: lastAt}); err != nil {
+			t.Fatalf("%s: advanceWatermark() error = %v", tt.name, err)
+		}
+		if since, _ := loadWatermark(cfg); !since.Equal(lastAt) {
+			t.Errorf("%s: watermark after a clean run = %v; want %v", tt.name, since, lastAt)
+		}
+	}
+}
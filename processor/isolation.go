@@ -0,0 +1,48 @@
+package processor
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/db"
+	"github.com/waldirborbajr/sync/logger"
+	"github.com/waldirborbajr/sync/run"
+)
+
+// beginSourceTx starts the Firebird transaction the products are read in
+// with FIREBIRD_ISOLATION, nil without it, and returns its ID, recorded in
+// TB_SYNC_INSTANCIAS. The SQLite mock has no transaction IDs, 0 there.
+func beginSourceTx(ctx context.Context, firebirdDB, mysqlDB *sql.DB, cfg config.Config) (*sql.Tx, int64, error) {
+	if cfg.FirebirdIsolation == "" {
+		return nil, 0, nil
+	}
+
+	// The driver starts Firebird's SNAPSHOT (concurrency) transactions for
+	// repeatable read; read-only transactions are always read committed
+	opts := &sql.TxOptions{Isolation: sql.LevelRepeatableRead}
+	if cfg.FirebirdIsolation == config.IsolationReadCommitted {
+		opts = &sql.TxOptions{Isolation: sql.LevelReadCommitted, ReadOnly: true}
+	}
+	tx, err := firebirdDB.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error starting Firebird %s transaction: %w", cfg.FirebirdIsolation, err)
+	}
+	if cfg.DevMode {
+		return tx, 0, nil
+	}
+
+	var id int64
+	if err := tx.QueryRowContext(ctx, "SELECT CURRENT_TRANSACTION FROM RDB$DATABASE").Scan(&id); err != nil {
+		tx.Rollback()
+		return nil, 0, fmt.Errorf("error reading Firebird transaction ID: %w", err)
+	}
+
+	log := logger.GetLogger()
+	log.Info().Str("isolation", cfg.FirebirdIsolation).Int64("transaction", id).Msg("Reading Firebird products in one transaction")
+	if err := db.RecordFirebirdTransaction(ctx, mysqlDB, run.MachineID(), id); err != nil {
+		log.Warn().Err(err).Int64("transaction", id).Msg("Could not record Firebird transaction in TB_SYNC_INSTANCIAS")
+	}
+	return tx, id, nil
+}
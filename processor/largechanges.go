@@ -0,0 +1,89 @@
+package processor
+
+import (
+	"math"
+	"sort"
+
+	"github.com/waldirborbajr/sync/config"
+)
+
+// LargeChange is a PRC_VENDA or QTD_ATUAL update of at least
+// NOTIFY_CHANGE_THRESHOLD percent of the stored value
+type LargeChange struct {
+	IDEstoque int
+	Descricao string
+	Column    string  // "PRC_VENDA" or "QTD_ATUAL"
+	Old       float64 // Prices in reais
+	New       float64
+}
+
+// Percent returns the size of the change relative to the stored value,
+// taken as at least 1 so changes from zero stay finite
+func (c LargeChange) Percent() float64 {
+	return math.Abs(c.New-c.Old) / max(math.Abs(c.Old), 1) * 100
+}
+
+// LargeChangeStats holds the largest price and quantity changes of the run,
+// a fat-fingered price in Firebird being the usual cause
+type LargeChangeStats struct {
+	Threshold float64       // NOTIFY_CHANGE_THRESHOLD
+	Count     int           // Changes at or above Threshold
+	Top       []LargeChange // The NOTIFY_TOP_CHANGES largest, by descending Percent
+}
+
+// largeChangeTracker collects the LargeChangeStats of the run
+type largeChangeTracker struct {
+	limit        int
+	quantityOnly bool // Prices are not computed, only quantities are compared
+	stats        LargeChangeStats
+}
+
+// newLargeChangeTracker returns a tracker for cfg, nil when NOTIFY_TOP_CHANGES is 0
+func newLargeChangeTracker(cfg config.Config) *largeChangeTracker {
+	if cfg.NotifyTopChanges <= 0 {
+		return nil
+	}
+	return &largeChangeTracker{limit: cfg.NotifyTopChanges, quantityOnly: cfg.QuantityOnly(), stats: LargeChangeStats{Threshold: cfg.NotifyChangeThreshold}}
+}
+
+// observe accounts for the price and quantity of an update. Rows compared
+// by hash (MYSQL_PRELOAD=hash) carry no stored values and are not tracked.
+func (t *largeChangeTracker) observe(op RowOperation) {
+	if t == nil || op.Type != OpUpdate || op.existing == nil {
+		return
+	}
+	if q := op.existing.Quantidade; q.Valid {
+		t.add(LargeChange{IDEstoque: op.IDEstoque, Descricao: op.Descricao, Column: "QTD_ATUAL", Old: q.Float64, New: op.QtdAtual})
+	}
+	if p := op.existing.PrcVenda; p.Valid && !t.quantityOnly {
+		t.add(LargeChange{IDEstoque: op.IDEstoque, Descricao: op.Descricao, Column: "PRC_VENDA", Old: p.Cents.Float64(), New: op.PrcVenda.Float64()})
+	}
+}
+
+// add keeps Top sorted by descending Percent and capped at the limit
+func (t *largeChangeTracker) add(c LargeChange) {
+	if c.Old == c.New || c.Percent() < t.stats.Threshold {
+		return
+	}
+	t.stats.Count++
+	top := t.stats.Top
+	if len(top) == t.limit && c.Percent() <= top[len(top)-1].Percent() {
+		return
+	}
+
+	i := sort.Search(len(top), func(i int) bool {
+		return top[i].Percent() < c.Percent()
+	})
+	top = append(top, LargeChange{})
+	copy(top[i+1:], top[i:])
+	top[i] = c
+	t.stats.Top = top[:min(len(top), t.limit)]
+}
+
+// result returns the collected stats, nil when tracking is off
+func (t *largeChangeTracker) result() *LargeChangeStats {
+	if t == nil {
+		return nil
+	}
+	return &t.stats
+}
@@ -0,0 +1,138 @@
+package processor
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/waldirborbajr/sync/logger"
+	"github.com/waldirborbajr/sync/run"
+	"github.com/waldirborbajr/sync/transfer"
+)
+
+// movementBatchSize is the number of movements inserted per transaction
+const movementBatchSize = 500
+
+// movementColumns are the TB_MOVIMENTO_ESTOQUE columns, in MOVEMENT_QUERY order
+var movementColumns = []string{"ID_MOVIMENTO", "ID_ESTOQUE", "TIPO", "QUANTIDADE", "DT_MOVIMENTO", "DOCUMENTO"}
+
+// MovementStats reports the stock movements copied into TB_MOVIMENTO_ESTOQUE
+type MovementStats struct {
+	Since    int64 // Watermark: highest movement ID already in MySQL when the run started
+	Last     int64 // Highest movement ID in MySQL after the run
+	Inserted int
+	Duration time.Duration
+}
+
+// syncMovements copies the Firebird stock movements newer than the last
+// one synced into TB_MOVIMENTO_ESTOQUE. The ledger only grows, so the
+// highest ID_MOVIMENTO in MySQL is the watermark: MOVEMENT_QUERY binds it
+// to its '?' and returns the later movements in ID order. Each batch is
+// committed with its rows, so the watermark never gets ahead of them and a
+// failed run resumes after the last batch written.
+func syncMovements(ctx context.Context, firebirdDB, mysqlDB *sql.DB, query string, retry *transfer.Retrier) (*MovementStats, error) {
+	log := logger.GetLogger()
+	start := time.Now()
+	ms := &MovementStats{}
+
+	if err := mysqlDB.QueryRowContext(ctx, "SELECT COALESCE(MAX(ID_MOVIMENTO), 0) FROM TB_MOVIMENTO_ESTOQUE").Scan(&ms.Since); err != nil {
+		return nil, fmt.Errorf("error reading the TB_MOVIMENTO_ESTOQUE watermark: %w", err)
+	}
+	ms.Last = ms.Since
+
+	rows, err := firebirdDB.QueryContext(ctx, query, ms.Since)
+	if err != nil {
+		return nil, fmt.Errorf("error running MOVEMENT_QUERY: %w", err)
+	}
+	defer rows.Close()
+
+	var batch [][]interface{}
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := retry.Do(ctx, "movement", len(batch), func() error {
+			return insertMovements(ctx, mysqlDB, batch)
+		})
+		if err != nil {
+			return err
+		}
+		ms.Inserted += len(batch)
+		ms.Last = batch[len(batch)-1][0].(int64)
+		batch = batch[:0]
+		run.Touch(ctx)
+		return nil
+	}
+
+	previous := ms.Since
+	for rows.Next() {
+		var id int64
+		values := make([]interface{}, len(movementColumns)-1)
+		dest := []interface{}{&id}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("expected %s columns from MOVEMENT_QUERY: %w", strings.Join(movementColumns, ", "), err)
+		}
+		// Out of order IDs would move the watermark past movements not yet read
+		if id <= previous {
+			return nil, fmt.Errorf("MOVEMENT_QUERY returned movement %d after %d: it must return the movements after '?' in ID_MOVIMENTO order", id, previous)
+		}
+		previous = id
+
+		row := []interface{}{id}
+		for _, v := range values {
+			row = append(row, normalizeValue(v))
+		}
+		batch = append(batch, row)
+		if len(batch) >= movementBatchSize {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading MOVEMENT_QUERY rows: %w", err)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	ms.Duration = time.Since(start)
+	log.Info().
+		Int64("since", ms.Since).
+		Int64("last", ms.Last).
+		Int("inserted", ms.Inserted).
+		Dur("duration", ms.Duration).
+		Msg("Stock movements synced")
+	return ms, nil
+}
+
+// insertMovements inserts a batch of movements in one statement and transaction
+func insertMovements(ctx context.Context, mysqlDB *sql.DB, batch [][]interface{}) error {
+	placeholders := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(movementColumns)), ", ") + ")"
+	values := make([]string, len(batch))
+	args := make([]interface{}, 0, len(batch)*len(movementColumns))
+	for i, row := range batch {
+		values[i] = placeholders
+		args = append(args, row...)
+	}
+
+	tx, err := mysqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := "INSERT INTO TB_MOVIMENTO_ESTOQUE (" + strings.Join(movementColumns, ", ") + ") VALUES " + strings.Join(values, ", ")
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("movement insert failed from ID %v: %w", batch[0][0], err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("movement insert commit failed: %w", err)
+	}
+	return nil
+}
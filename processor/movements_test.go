@@ -0,0 +1,54 @@
+package processor
+
+import "testing"
+
+// movementQuery reads the movements the tests add to the Firebird mock
+const movementQuery = "SELECT ID_MOVIMENTO, ID_ESTOQUE, TIPO, QUANTIDADE, DT_MOVIMENTO, DOCUMENTO FROM TB_EST_MOVIMENTO WHERE ID_MOVIMENTO > ? ORDER BY ID_MOVIMENTO"
+
+func TestMovementSyncRoundTrip(t *testing.T) {
+	cfg, firebirdDB, mysqlDB := devDatabases(t, map[string]string{"MOVEMENT_QUERY": movementQuery})
+	execAll(t, firebirdDB,
+		"CREATE TABLE TB_EST_MOVIMENTO (ID_MOVIMENTO INTEGER PRIMARY KEY, ID_ESTOQUE INTEGER, TIPO TEXT, QUANTIDADE REAL, DT_MOVIMENTO TEXT, DOCUMENTO TEXT)",
+		"INSERT INTO TB_EST_MOVIMENTO VALUES (10, 1, 'E', 5, '2026-03-02 08:00:00', 'NF-1'), (11, 1, 'S', 2, '2026-03-02 09:00:00', NULL)",
+	)
+
+	_, _, _, stats := syncDev(t, cfg, firebirdDB, mysqlDB)
+	if ms := stats.Movements; ms == nil || ms.Since != 0 || ms.Last != 11 || ms.Inserted != 2 {
+		t.Fatalf("movement stats = %+v; want movements 10 and 11 inserted", ms)
+	}
+
+	// The next run reads only the movements after the highest one in MySQL
+	execAll(t, firebirdDB, "INSERT INTO TB_EST_MOVIMENTO VALUES (12, 2, 'E', 1, '2026-03-03 08:00:00', 'NF-2')")
+	_, _, _, stats = syncDev(t, cfg, firebirdDB, mysqlDB)
+	if ms := stats.Movements; ms.Since != 11 || ms.Last != 12 || ms.Inserted != 1 {
+		t.Errorf("second run movement stats = %+v; want movement 12 inserted", ms)
+	}
+	if n := countRows(t, mysqlDB, "SELECT COUNT(*) FROM TB_MOVIMENTO_ESTOQUE"); n != 3 {
+		t.Errorf("%d movements in MySQL; want 3", n)
+	}
+	if n := countRows(t, mysqlDB, "SELECT COUNT(*) FROM TB_MOVIMENTO_ESTOQUE WHERE ID_MOVIMENTO = 11 AND TIPO = 'S' AND QUANTIDADE = 2 AND DOCUMENTO IS NULL"); n != 1 {
+		t.Error("movement 11 not copied as read")
+	}
+}
+
+func TestMovementSyncDisabled(t *testing.T) {
+	for _, values := range []map[string]string{
+		{},
+		{"MOVEMENT_QUERY": movementQuery, "SYNC_ONLY": "estoque"},
+	} {
+		cfg, firebirdDB, mysqlDB := devDatabases(t, values)
+		execAll(t, firebirdDB,
+			"CREATE TABLE TB_EST_MOVIMENTO (ID_MOVIMENTO INTEGER PRIMARY KEY, ID_ESTOQUE INTEGER, TIPO TEXT, QUANTIDADE REAL, DT_MOVIMENTO TEXT, DOCUMENTO TEXT)",
+			"INSERT INTO TB_EST_MOVIMENTO VALUES (10, 1, 'E', 5, '2026-03-02 08:00:00', 'NF-1')",
+		)
+		execAll(t, mysqlDB, "CREATE TABLE IF NOT EXISTS TB_MOVIMENTO_ESTOQUE (ID_MOVIMENTO INTEGER NOT NULL PRIMARY KEY, ID_ESTOQUE INTEGER NOT NULL, TIPO TEXT NOT NULL, QUANTIDADE REAL NOT NULL, DT_MOVIMENTO DATETIME NOT NULL, DOCUMENTO TEXT NULL)")
+
+		_, _, _, stats := syncDev(t, cfg, firebirdDB, mysqlDB)
+		if stats.Movements != nil {
+			t.Errorf("%v: movement sync ran: %+v", values, stats.Movements)
+		}
+		if n := countRows(t, mysqlDB, "SELECT COUNT(*) FROM TB_MOVIMENTO_ESTOQUE"); n != 0 {
+			t.Errorf("%v: %d movements written", values, n)
+		}
+	}
+}
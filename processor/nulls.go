@@ -0,0 +1,73 @@
+package processor
+
+import (
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/money"
+)
+
+// nullableField is a price Firebird may leave NULL, as handled by NULL_POLICIES
+type nullableField struct {
+	name   string                                 // One of config.NullableFields
+	source func(src sourceRow) money.NullCents    // Firebird value
+	stored func(rec *mysqlRecord) money.NullCents // Stored value
+	value  func(op *RowOperation) (*money.Cents, *bool)
+}
+
+// nullableFields are the fields of config.NullableFields
+var nullableFields = []nullableField{
+	{
+		name:   "PRC_CUSTO",
+		source: func(src sourceRow) money.NullCents { return src.PrcCusto },
+		stored: func(rec *mysqlRecord) money.NullCents { return rec.ValorCusto },
+		value:  func(op *RowOperation) (*money.Cents, *bool) { return &op.PrcCusto, &op.PrcCustoNull },
+	},
+	{
+		name:   "PRC_DOLAR",
+		source: func(src sourceRow) money.NullCents { return src.PrcDolar },
+		stored: func(rec *mysqlRecord) money.NullCents { return rec.ValorUsd },
+		value:  func(op *RowOperation) (*money.Cents, *bool) { return &op.PrcDolar, &op.PrcDolarNull },
+	},
+}
+
+// applyNullPolicies sets the prices Firebird left NULL as their NULL_POLICIES
+// entries say, for an insert or for an update of the stored row. A PRC_DOLAR
+// derived from the exchange rate is not NULL. Sale prices and the derived
+// PRC_DOLAR are calculated from the Firebird cost whatever its policy.
+func applyNullPolicies(op *RowOperation, src sourceRow, cfg config.Config, exists bool) {
+	for _, f := range nullableFields {
+		if f.source(src).Valid || (f.name == "PRC_DOLAR" && op.dollarDerived) {
+			continue
+		}
+		p := cfg.NullPolicy(f.name)
+		value, null := f.value(op)
+
+		action := p.Insert
+		if exists {
+			action = p.Update
+		}
+		switch action {
+		case config.NullWrite, config.NullOverwrite:
+			*value, *null = 0, true
+		case config.NullKeep:
+			stored := f.stored(op.existing)
+			*value, *null = stored.Cents, !stored.Valid
+		default:
+			*value, *null = money.FromFloat(p.Default), false
+		}
+	}
+}
+
+// storedValue returns the stored value of the field, nil for NULL. Columns
+// with a NULL_POLICIES entry compare it, NULL and zero differing.
+func (f nullableField) storedValue(rec *mysqlRecord) interface{} {
+	n := f.stored(rec)
+	return nullPrice(n.Cents, !n.Valid)
+}
+
+// nullPrice returns c, or nil when null
+func nullPrice(c money.Cents, null bool) interface{} {
+	if null {
+		return nil
+	}
+	return c
+}
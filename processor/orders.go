@@ -0,0 +1,257 @@
+package processor
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/db"
+	"github.com/waldirborbajr/sync/logger"
+	"github.com/waldirborbajr/sync/run"
+	"github.com/waldirborbajr/sync/transfer"
+)
+
+// orderKeyPrefix prefixes the web order ID in Firebird TB_PEDIDO.CHAVE_WEB,
+// the idempotency key of the export
+const orderKeyPrefix = "WEB-"
+
+// orderLookupChunk is the number of order IDs looked up in TB_SYNC_PEDIDOS per query
+const orderLookupChunk = 500
+
+// OrderStats reports the web orders exported into Firebird (ORDER_EXPORT_QUERY)
+type OrderStats struct {
+	Pending   int     // Orders returned by ORDER_EXPORT_QUERY not yet exported
+	Exported  int     // Orders inserted into Firebird TB_PEDIDO
+	Items     int     // TB_PEDIDO_ITEM rows of the exported orders
+	Recovered int     // Orders already in Firebird, left by a run that failed before recording them
+	Empty     int     // Orders without items yet, exported by a later run
+	Refused   []int64 // Orders Firebird refused, tried again by the next run
+	Duration  time.Duration
+}
+
+// webOrder is a MySQL order to export
+type webOrder struct {
+	id     int64
+	header []interface{}   // ID_CLIENTE, DT_PEDIDO, VALOR_TOTAL, OBSERVACAO
+	items  [][]interface{} // ID_ESTOQUE, QUANTIDADE, PRC_UNITARIO
+}
+
+// exportOrders inserts the web orders not exported yet into Firebird as
+// pending orders, each with its items in one Firebird transaction, and then
+// records it in TB_SYNC_PEDIDOS. The order is keyed in TB_PEDIDO.CHAVE_WEB:
+// an order a failed run committed in Firebird without recording it is found
+// by its key and only recorded, never inserted twice.
+func exportOrders(ctx context.Context, firebirdDB, mysqlDB *sql.DB, cfg config.Config, retry *transfer.Retrier) (*OrderStats, error) {
+	log := logger.GetLogger()
+	start := time.Now()
+	es := &OrderStats{}
+
+	orders, err := loadWebOrders(ctx, mysqlDB, cfg.OrderExportQuery)
+	if err != nil {
+		return nil, fmt.Errorf("error running ORDER_EXPORT_QUERY: %w", err)
+	}
+	es.Pending = len(orders)
+	run.Touch(ctx)
+
+	for _, o := range orders {
+		if o.items, err = loadOrderItems(ctx, mysqlDB, cfg.OrderItemsQuery, o.id); err != nil {
+			return es, fmt.Errorf("error running ORDER_ITEMS_QUERY for web order %d: %w", o.id, err)
+		}
+		if len(o.items) == 0 {
+			es.Empty++
+			continue
+		}
+
+		var firebirdID int64
+		var recovered bool
+		err := retry.Do(ctx, "Firebird TB_PEDIDO insert", 1, func() error {
+			var err error
+			firebirdID, recovered, err = insertOrder(ctx, firebirdDB, cfg, o)
+			return err
+		})
+		if err != nil {
+			if db.IsRetryable(err) {
+				return es, fmt.Errorf("error exporting web order %d: %w", o.id, err)
+			}
+			log.Error().Err(err).Int64("order", o.id).Msg("Firebird refused web order, it is tried again on the next run")
+			es.Refused = append(es.Refused, o.id)
+			continue
+		}
+
+		err = retry.Do(ctx, "TB_SYNC_PEDIDOS insert", 1, func() error {
+			_, err := mysqlDB.ExecContext(ctx, "INSERT INTO TB_SYNC_PEDIDOS (ID_PEDIDO_WEB, ID_PEDIDO_FIREBIRD, RUN_ID, DT_EXPORTACAO) VALUES (?, ?, ?, ?)",
+				o.id, firebirdID, run.IDFrom(ctx), time.Now().UTC())
+			return err
+		})
+		if err != nil {
+			// The next run finds the order by its key and records it then
+			return es, fmt.Errorf("error recording web order %d as exported: %w", o.id, err)
+		}
+		if recovered {
+			es.Recovered++
+		} else {
+			es.Exported++
+			es.Items += len(o.items)
+		}
+		run.Touch(ctx)
+	}
+
+	es.Duration = time.Since(start)
+	log.Info().
+		Int("pending", es.Pending).
+		Int("exported", es.Exported).
+		Int("items", es.Items).
+		Int("recovered", es.Recovered).
+		Int("empty", es.Empty).
+		Int("refused", len(es.Refused)).
+		Dur("duration", es.Duration).
+		Msg("Web orders exported to Firebird")
+	return es, nil
+}
+
+// loadWebOrders runs the order query and returns the orders not recorded
+// in TB_SYNC_PEDIDOS, oldest ID first
+func loadWebOrders(ctx context.Context, mysqlDB *sql.DB, query string) ([]webOrder, error) {
+	values, err := queryValues(ctx, mysqlDB, 5, "ID, ID_CLIENTE, DT_PEDIDO, VALOR_TOTAL and OBSERVACAO", query)
+	if err != nil {
+		return nil, err
+	}
+	orders := make([]webOrder, 0, len(values))
+	for _, row := range values {
+		id, err := strconv.ParseInt(fmt.Sprint(row[0]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid order ID %v", row[0])
+		}
+		orders = append(orders, webOrder{id: id, header: row[1:]})
+	}
+
+	exported, err := exportedOrders(ctx, mysqlDB, orders)
+	if err != nil {
+		return nil, err
+	}
+	orders = slices.DeleteFunc(orders, func(o webOrder) bool {
+		_, ok := exported[o.id]
+		return ok
+	})
+	sort.Slice(orders, func(i, j int) bool { return orders[i].id < orders[j].id })
+	return orders, nil
+}
+
+// exportedOrders returns the IDs of the orders recorded in TB_SYNC_PEDIDOS,
+// looking up only those returned by the order query
+func exportedOrders(ctx context.Context, mysqlDB *sql.DB, orders []webOrder) (map[int64]struct{}, error) {
+	exported := make(map[int64]struct{})
+	for start := 0; start < len(orders); start += orderLookupChunk {
+		chunk := orders[start:min(start+orderLookupChunk, len(orders))]
+		args := make([]interface{}, len(chunk))
+		for i, o := range chunk {
+			args[i] = o.id
+		}
+		rows, err := mysqlDB.QueryContext(ctx, "SELECT ID_PEDIDO_WEB FROM TB_SYNC_PEDIDOS WHERE ID_PEDIDO_WEB IN (?"+strings.Repeat(", ?", len(chunk)-1)+")", args...)
+		if err != nil {
+			return nil, fmt.Errorf("error loading TB_SYNC_PEDIDOS: %w", err)
+		}
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			exported[id] = struct{}{}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return exported, nil
+}
+
+// loadOrderItems returns the items of a web order
+func loadOrderItems(ctx context.Context, mysqlDB *sql.DB, query string, id int64) ([][]interface{}, error) {
+	return queryValues(ctx, mysqlDB, 3, "ID_ESTOQUE, QUANTIDADE and PRC_UNITARIO", query, id)
+}
+
+// queryValues returns the rows of a query expected to return n columns,
+// described by columns, normalized to plain Go values
+func queryValues(ctx context.Context, db *sql.DB, n int, columns, query string, args ...interface{}) ([][]interface{}, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if len(names) != n {
+		return nil, fmt.Errorf("expected %d columns (%s), got %d", n, columns, len(names))
+	}
+	var result [][]interface{}
+	for rows.Next() {
+		values := make([]interface{}, n)
+		dest := make([]interface{}, n)
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		for i := range values {
+			values[i] = normalizeValue(values[i])
+		}
+		result = append(result, values)
+	}
+	return result, rows.Err()
+}
+
+// insertOrder inserts the order and its items into Firebird in one
+// transaction and returns the TB_PEDIDO ID; recovered is true when the
+// order was already there
+func insertOrder(ctx context.Context, firebirdDB *sql.DB, cfg config.Config, o webOrder) (id int64, recovered bool, err error) {
+	tx, err := firebirdDB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, false, fmt.Errorf("error starting Firebird transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	key := orderKeyPrefix + strconv.FormatInt(o.id, 10)
+	err = tx.QueryRowContext(ctx, "SELECT ID_PEDIDO FROM TB_PEDIDO WHERE CHAVE_WEB = ?", key).Scan(&id)
+	if err == nil {
+		return id, true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, false, fmt.Errorf("error looking up TB_PEDIDO: %w", err)
+	}
+
+	args := append([]interface{}{key, cfg.OrderPendingStatus}, o.header...)
+	if err := tx.QueryRowContext(ctx, `INSERT INTO TB_PEDIDO (CHAVE_WEB, STATUS, ID_CLIENTE, DT_PEDIDO, VALOR_TOTAL, OBSERVACAO)
+		VALUES (?, ?, ?, ?, ?, ?) RETURNING ID_PEDIDO`, args...).Scan(&id); err != nil {
+		return 0, false, fmt.Errorf("TB_PEDIDO insert failed: %w", err)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, "INSERT INTO TB_PEDIDO_ITEM (ID_PEDIDO, ITEM, ID_ESTOQUE, QUANTIDADE, PRC_UNITARIO) VALUES (?, ?, ?, ?, ?)")
+	if err != nil {
+		return 0, false, fmt.Errorf("error preparing TB_PEDIDO_ITEM insert: %w", err)
+	}
+	defer stmt.Close()
+	for i, item := range o.items {
+		if _, err := stmt.ExecContext(ctx, append([]interface{}{id, i + 1}, item...)...); err != nil {
+			return 0, false, fmt.Errorf("TB_PEDIDO_ITEM insert failed for item %d: %w", i+1, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, false, fmt.Errorf("TB_PEDIDO commit failed: %w", err)
+	}
+	return id, false, nil
+}
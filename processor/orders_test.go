@@ -0,0 +1,88 @@
+package processor
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/db"
+	"github.com/waldirborbajr/sync/transfer"
+)
+
+// orderDatabases opens the dev mocks with web orders 1 and 2 in MySQL, two
+// items each, and the empty Firebird order tables
+func orderDatabases(t *testing.T) (config.Config, *sql.DB, *sql.DB) {
+	t.Helper()
+	cfg, firebirdDB, mysqlDB := devDatabases(t, map[string]string{
+		"ORDER_EXPORT_QUERY": "SELECT ID, ID_CLIENTE, DT_PEDIDO, VALOR_TOTAL, OBSERVACAO FROM PEDIDO_WEB",
+		"ORDER_ITEMS_QUERY":  "SELECT ID_ESTOQUE, QUANTIDADE, PRC_UNITARIO FROM PEDIDO_WEB_ITEM WHERE ID_PEDIDO = ?",
+	})
+	if err := db.EnsureOrderExportTable(mysqlDB, cfg); err != nil {
+		t.Fatal(err)
+	}
+	execAll(t, mysqlDB,
+		"CREATE TABLE PEDIDO_WEB (ID INTEGER PRIMARY KEY, ID_CLIENTE INTEGER, DT_PEDIDO TEXT, VALOR_TOTAL REAL, OBSERVACAO TEXT)",
+		"CREATE TABLE PEDIDO_WEB_ITEM (ID_PEDIDO INTEGER, ID_ESTOQUE INTEGER, QUANTIDADE REAL, PRC_UNITARIO REAL)",
+		"INSERT INTO PEDIDO_WEB VALUES (1, 10, '2026-03-02', 30, 'first'), (2, 20, '2026-03-03', 12, 'second')",
+		"INSERT INTO PEDIDO_WEB_ITEM VALUES (1, 1, 1, 10), (1, 2, 2, 10), (2, 3, 3, 2), (2, 4, 1, 6)",
+	)
+	execAll(t, firebirdDB,
+		"CREATE TABLE TB_PEDIDO (ID_PEDIDO INTEGER PRIMARY KEY AUTOINCREMENT, CHAVE_WEB TEXT, STATUS TEXT, ID_CLIENTE INTEGER, DT_PEDIDO TEXT, VALOR_TOTAL REAL, OBSERVACAO TEXT)",
+		"CREATE TABLE TB_PEDIDO_ITEM (ID_PEDIDO INTEGER, ITEM INTEGER, ID_ESTOQUE INTEGER, QUANTIDADE REAL, PRC_UNITARIO REAL)",
+	)
+	return cfg, firebirdDB, mysqlDB
+}
+
+func TestExportOrdersRerun(t *testing.T) {
+	cfg, firebirdDB, mysqlDB := orderDatabases(t)
+
+	es, err := exportOrders(context.Background(), firebirdDB, mysqlDB, cfg, transfer.NewRetrier(cfg))
+	if err != nil {
+		t.Fatalf("exportOrders() error = %v", err)
+	}
+	if es.Pending != 2 || es.Exported != 2 || es.Items != 4 {
+		t.Errorf("pending, exported, items = %d, %d, %d; want 2, 2, 4", es.Pending, es.Exported, es.Items)
+	}
+	if n := countRows(t, firebirdDB, "SELECT COUNT(*) FROM TB_PEDIDO WHERE STATUS = ?", cfg.OrderPendingStatus); n != 2 {
+		t.Errorf("%d pending orders in Firebird; want 2", n)
+	}
+
+	// The orders recorded in TB_SYNC_PEDIDOS are not exported again
+	es, err = exportOrders(context.Background(), firebirdDB, mysqlDB, cfg, transfer.NewRetrier(cfg))
+	if err != nil {
+		t.Fatalf("second exportOrders() error = %v", err)
+	}
+	if es.Pending != 0 || es.Exported != 0 {
+		t.Errorf("second run pending, exported = %d, %d; want 0, 0", es.Pending, es.Exported)
+	}
+	if n := countRows(t, firebirdDB, "SELECT COUNT(*) FROM TB_PEDIDO"); n != 2 {
+		t.Errorf("%d Firebird orders after the second run; want 2", n)
+	}
+	if n := countRows(t, firebirdDB, "SELECT COUNT(*) FROM TB_PEDIDO_ITEM"); n != 4 {
+		t.Errorf("%d Firebird order items after the second run; want 4", n)
+	}
+}
+
+func TestExportOrdersRecoversCommittedOrder(t *testing.T) {
+	cfg, firebirdDB, mysqlDB := orderDatabases(t)
+	// A failed run committed order 1 in Firebird without recording it
+	execAll(t, firebirdDB, "INSERT INTO TB_PEDIDO (ID_PEDIDO, CHAVE_WEB, STATUS) VALUES (50, 'WEB-1', 'P')")
+
+	es, err := exportOrders(context.Background(), firebirdDB, mysqlDB, cfg, transfer.NewRetrier(cfg))
+	if err != nil {
+		t.Fatalf("exportOrders() error = %v", err)
+	}
+	if es.Recovered != 1 || es.Exported != 1 || es.Items != 2 {
+		t.Errorf("recovered, exported, items = %d, %d, %d; want 1, 1, 2", es.Recovered, es.Exported, es.Items)
+	}
+	if n := countRows(t, firebirdDB, "SELECT COUNT(*) FROM TB_PEDIDO WHERE CHAVE_WEB = 'WEB-1'"); n != 1 {
+		t.Errorf("%d Firebird orders keyed WEB-1; want 1", n)
+	}
+	if n := countRows(t, firebirdDB, "SELECT COUNT(*) FROM TB_PEDIDO_ITEM WHERE ID_PEDIDO = 50"); n != 0 {
+		t.Errorf("%d items inserted again for the recovered order", n)
+	}
+	if n := countRows(t, mysqlDB, "SELECT COUNT(*) FROM TB_SYNC_PEDIDOS WHERE ID_PEDIDO_WEB = 1 AND ID_PEDIDO_FIREBIRD = 50"); n != 1 {
+		t.Error("recovered order not recorded with its Firebird ID")
+	}
+}
@@ -0,0 +1,148 @@
+package processor
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"hash/fnv"
+	"strings"
+
+	"github.com/waldirborbajr/sync/compare"
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/logger"
+	"github.com/waldirborbajr/sync/run"
+)
+
+// load reads the existing TB_ESTOQUE rows: their compared columns, or with
+// MYSQL_PRELOAD=hash only a hash of them, which takes about a tenth of the
+// memory on large catalogs. Rows are still read in full and hashed as they
+// are scanned, so the hash is the same whatever the database.
+func (lk *lookups) load(db *sql.DB, cfg config.Config) error {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM TB_ESTOQUE WHERE " + productKeyColumn(cfg) + " IS NOT NULL").Scan(&count)
+	if err != nil {
+		return err
+	}
+	lk.progress.Begin(run.PhaseLoad, count)
+
+	if !cfg.HashPreload() {
+		lk.existing = make(map[int]mysqlRecord, count)
+		return scanMySQLRecords(db, cfg, lk.columns, func(key int, rec *mysqlRecord) {
+			lk.existing[key] = *rec
+			lk.progress.Advance(run.PhaseLoad, 1)
+		})
+	}
+
+	// Ignored columns never make a row differ
+	for _, c := range lk.columns {
+		if !strings.EqualFold(cfg.ProductComparators[c.column], "ignore") {
+			lk.hashed = append(lk.hashed, c)
+		}
+	}
+	lk.hashes = make(map[int]uint64, count)
+	values := make([]interface{}, len(lk.hashed))
+	return scanMySQLRecords(db, cfg, lk.columns, func(key int, rec *mysqlRecord) {
+		for i, c := range lk.hashed {
+			values[i] = c.stored(rec)
+		}
+		lk.hashes[key] = hashValues(values)
+		lk.progress.Advance(run.PhaseLoad, 1)
+	})
+}
+
+// len returns the number of existing rows loaded
+func (lk *lookups) len() int {
+	if lk.hashes != nil {
+		return len(lk.hashes)
+	}
+	return len(lk.existing)
+}
+
+// find returns the stored record of key, nil when only its hash is loaded,
+// and whether the key exists in MySQL
+func (lk *lookups) find(key int) (*mysqlRecord, bool) {
+	if lk.hashes != nil {
+		_, ok := lk.hashes[key]
+		return nil, ok
+	}
+	rec, ok := lk.existing[key]
+	if !ok {
+		return nil, false
+	}
+	return &rec, true
+}
+
+// changed reports whether the values of op differ from its stored row and
+// records the differing columns, which a hash comparison cannot tell
+func (lk *lookups) changed(op *RowOperation) bool {
+	if lk.hashes != nil {
+		values := make([]interface{}, len(lk.hashed))
+		for i, c := range lk.hashed {
+			values[i] = c.value(op)
+		}
+		return hashValues(values) != lk.hashes[op.IDEstoque]
+	}
+
+	for _, c := range lk.columns {
+		if !c.equal(op.existing, op) {
+			op.changedColumns = append(op.changedColumns, c.column)
+		}
+	}
+	return len(op.changedColumns) > 0
+}
+
+// hashValues returns the FNV-1a hash of values in their compare.Key form, so
+// values compare.Exact reports as equal hash the same
+func hashValues(values []interface{}) uint64 {
+	h := fnv.New64a()
+	var size [binary.MaxVarintLen64]byte
+	for _, v := range values {
+		key := compare.Key(v)
+		h.Write(size[:binary.PutUvarint(size[:], uint64(len(key)))])
+		h.Write([]byte(key))
+	}
+	return h.Sum64()
+}
+
+// scanMySQLRecords reads the given columns of the existing TB_ESTOQUE rows,
+// calling fn with each key and record
+func scanMySQLRecords(db *sql.DB, cfg config.Config, columns []productColumn, fn func(key int, rec *mysqlRecord)) error {
+	log := logger.GetLogger()
+	key := productKeyColumn(cfg)
+
+	rows, err := db.Query(selectProducts(cfg, columns) + " WHERE " + key + " IS NOT NULL")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Error().Err(err).Msg("Error closing MySQL rows")
+		}
+	}()
+	return scanProducts(rows, cfg, columns, fn)
+}
+
+// selectProducts returns a SELECT of the key and the given columns of TB_ESTOQUE
+func selectProducts(cfg config.Config, columns []productColumn) string {
+	names := []string{productKeyColumn(cfg)}
+	for _, c := range columns {
+		names = append(names, c.column)
+	}
+	return "SELECT " + strings.Join(names, ", ") + " FROM TB_ESTOQUE"
+}
+
+// scanProducts calls fn with the key and record of each row of a selectProducts query
+func scanProducts(rows *sql.Rows, cfg config.Config, columns []productColumn, fn func(key int, rec *mysqlRecord)) error {
+	for rows.Next() {
+		var idClipp int
+		rec := mysqlRecord{Extra: make([]interface{}, len(cfg.ProductExtraColumns))}
+		dest := []interface{}{&idClipp}
+		for _, c := range columns {
+			dest = append(dest, c.dest(&rec))
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		fn(idClipp, &rec)
+	}
+	return rows.Err()
+}
@@ -0,0 +1,25 @@
+package processor
+
+import "testing"
+
+func TestHashPreloadMatchesColumns(t *testing.T) {
+	for _, preload := range []string{"columns", "hash"} {
+		cfg, firebirdDB, mysqlDB := devDatabases(t, map[string]string{"MYSQL_PRELOAD": preload, "LUCRO": "40"})
+
+		if inserted, _, _, _ := syncDev(t, cfg, firebirdDB, mysqlDB); inserted != 6 {
+			t.Errorf("%s: first run inserted %d; want the 6 active products", preload, inserted)
+		}
+		if _, updated, ignored, _ := syncDev(t, cfg, firebirdDB, mysqlDB); updated != 0 || ignored != 6 {
+			t.Errorf("%s: unchanged run updated %d, ignored %d; want 0, 6", preload, updated, ignored)
+		}
+
+		// A price changed in MySQL differs from the calculated one again
+		execAll(t, mysqlDB, "UPDATE TB_ESTOQUE SET PRC_VENDA = 1 WHERE ID_ESTOQUE = 2")
+		if _, updated, ignored, _ := syncDev(t, cfg, firebirdDB, mysqlDB); updated != 1 || ignored != 5 {
+			t.Errorf("%s: run after the change updated %d, ignored %d; want 1, 5", preload, updated, ignored)
+		}
+		if n := countRows(t, mysqlDB, "SELECT COUNT(*) FROM TB_ESTOQUE WHERE PRC_VENDA = 1"); n != 0 {
+			t.Errorf("%s: changed price not written back", preload)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package processor
+
+import "github.com/waldirborbajr/sync/config"
+
+// pricingRule returns the name and margins of the first PRICING_RULES_FILE
+// rule src matches, or "" and the global margins when none does
+func pricingRule(cfg config.Config, src sourceRow) (string, config.Margins) {
+	for _, r := range cfg.PricingRules {
+		if matchesAll(r.When, src) {
+			return r.Name, r.Margins
+		}
+	}
+	return "", cfg.Margins()
+}
@@ -0,0 +1,69 @@
+package processor
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/logger"
+)
+
+// changedKeys collects the ID_ESTOQUE values written during the run.
+// Workers append after each committed batch.
+type changedKeys struct {
+	mu  sync.Mutex
+	ids []int
+}
+
+// add records the keys of a committed batch
+func (c *changedKeys) add(ops []RowOperation) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, op := range ops {
+		c.ids = append(c.ids, op.IDEstoque)
+	}
+}
+
+// sorted returns a sorted copy of the recorded keys
+func (c *changedKeys) sorted() []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ids := append([]int(nil), c.ids...)
+	sort.Ints(ids)
+	return ids
+}
+
+// callChangedIDsProcedure calls the configured procedure with the changed
+// keys as a comma-separated list, batchSize keys per call, so the virtual
+// quantity is recomputed only for affected rows. It returns the number of calls.
+func callChangedIDsProcedure(db *sql.DB, cfg config.Config, ids []int) (int, error) {
+	log := logger.GetLogger()
+
+	calls := 0
+	for start := 0; start < len(ids); start += cfg.ProcedureBatchSize {
+		end := min(start+cfg.ProcedureBatchSize, len(ids))
+
+		list := make([]string, 0, end-start)
+		for _, id := range ids[start:end] {
+			list = append(list, strconv.Itoa(id))
+		}
+
+		callStart := time.Now()
+		if _, err := db.Exec("CALL "+cfg.ChangedIDsProcedure+"(?)", strings.Join(list, ",")); err != nil {
+			log.Error().Err(err).Str("procedure", cfg.ChangedIDsProcedure).Int("ids", end-start).Msg("Error calling changed IDs procedure")
+			return calls, fmt.Errorf("error calling %s procedure: %w", cfg.ChangedIDsProcedure, err)
+		}
+		calls++
+		log.Debug().
+			Str("procedure", cfg.ChangedIDsProcedure).
+			Int("ids", end-start).
+			Dur("duration", time.Since(callStart)).
+			Msg("Changed IDs procedure batch executed")
+	}
+	return calls, nil
+}
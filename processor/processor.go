@@ -3,38 +3,143 @@ package processor
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"math"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/db"
+	"github.com/waldirborbajr/sync/flags"
 	"github.com/waldirborbajr/sync/logger"
+	"github.com/waldirborbajr/sync/money"
+	"github.com/waldirborbajr/sync/run"
+	"github.com/waldirborbajr/sync/transfer"
 )
 
 // mysqlRecord define a estrutura dos registros do MySQL
 type mysqlRecord struct {
 	Descricao  sql.NullString
 	Quantidade sql.NullFloat64
-	ValorCusto sql.NullFloat64
-	ValorUsd   sql.NullFloat64
-	PrcVenda   sql.NullFloat64
-	Prc3x      sql.NullFloat64
-	Prc6x      sql.NullFloat64
-	Prc10x     sql.NullFloat64
+	ValorCusto money.NullCents
+	ValorUsd   money.NullCents
+	PrcVenda   money.NullCents
+	Prc3x      money.NullCents
+	Prc6x      money.NullCents
+	Prc10x     money.NullCents
+	Visivel    sql.NullInt64  // Only loaded with STOCK_POLICY=hide
+	Status     sql.NullString // Only loaded with STATUS_MAP
+	Ativo      sql.NullInt64  // Only loaded with SOFT_DELETE_STYLE=flag
+	DeletedAt  sql.NullString // Only loaded with SOFT_DELETE_STYLE=timestamp
+	Extra      []interface{}  // PRODUCT_EXTRA_COLUMNS values
 }
 
 // ProcessingStats para métricas de performance
 type ProcessingStats struct {
-	LoadTime       time.Duration
-	QueryTime      time.Duration
-	ProcessingTime time.Duration
-	ProcedureTime  time.Duration
-	TotalRows      int
+	RunID            string
+	LoadTime         time.Duration
+	QueryTime        time.Duration // Spent executing the Firebird query and fetching its rows, part of ProcessingTime
+	SourceChunks     int           // Key ranges read with SOURCE_CHUNK_SIZE, 0 for a single query
+	SourceReaders    int           // Parallel readers of the products (SOURCE_READERS), 0 for one
+	RowLimit         int           // ROW_LIMIT the product read stopped at, 0 when every row was read
+	Scope            []string      // Parts synced (SYNC_ONLY, SYNC_SCOPE), nil for all
+	ProcessingTime   time.Duration // From the first row read to the last batch committed
+	ProcedureTime    time.Duration
+	TotalRows        int
+	PriceHistoryRows int // Rows written to TB_PRECO_HISTORICO
+	AuditRows        int // Rows written to TB_ESTOQUE_SYNC_AUDIT
+	Analytics        Analytics
+	Constraints      ConstraintStats
+	ProtectedSkipped int // Price updates skipped because the row is protected
+
+	ReservationAdjusted int     // Rows whose QTD_ATUAL was reduced by reservations
+	ReservedQuantity    float64 // Total quantity subtracted for reservations
+
+	NonPositiveStock int // Rows with QTD_ATUAL <= 0 handled by STOCK_POLICY
+	StockSkipped     int // Rows not written because of STOCK_POLICY=skip
+
+	Categories CategoryStats
+	Warehouses WarehouseStats
+
+	StatusCounts   map[string]int // Rows per mapped lifecycle status
+	UnmappedStatus int            // Rows skipped because their STATUS is not in STATUS_MAP
+	Filtered       int            // Rows read but failing ROW_FILTERS (ROW_FILTER_MODE=scan)
+
+	PricingRules map[string]int // Rows priced per PRICING_RULES_FILE rule, "" for the global margins
+
+	ExchangeRate *ExchangeRateStats // Nil unless EXCHANGE_RATE_PROVIDER is set
+
+	Catalog *CatalogStats // Nil unless catalog validation is configured
+
+	ChangedIDs       int // Keys written this run, handed to CHANGED_IDS_PROCEDURE
+	ProcedureBatches int // CHANGED_IDS_PROCEDURE calls made
+	HooksExecuted    int // POST_SYNC_SQL statements executed
+
+	RetryChain []string // Run IDs of failed attempts preceding this successful recovery run
+
+	FirebirdIsolation   string // FIREBIRD_ISOLATION the products were read with, "" for a transaction per query
+	FirebirdTransaction int64  // ID of the Firebird transaction the products were read in, 0 when unknown
+
+	Incremental bool      // Only rows modified after Since were read
+	Since       time.Time // Zero on the first (full) incremental run
+	Watermark   time.Time // Highest modification time read, persisted after success
+
+	Tables []TableStats // Configured table mappings (SYNC_TABLES), in sync order
+
+	Customers *TableStats // Nil unless CUSTOMER_QUERY is set and prices are synced
+
+	Movements *MovementStats // Nil unless MOVEMENT_QUERY is set
+
+	Orders *OrderStats // Nil unless ORDER_EXPORT_QUERY is set
+
+	Reverse *ReverseStats // Nil unless REVERSE_SYNC_COLUMNS is set and prices are synced
+
+	Blobs *BlobStats // Nil unless BLOB_COLUMNS is set and prices are synced
+
+	DiffReport     string // DIFF_REPORT_FILE written, "" when none was
+	DiffReportRows int    // Rows listed in the diff report
+
+	ExpressionErrors int // Rows with a PRODUCT_EXTRA_COLUMNS or PRODUCT_TRANSFORMS expression that failed
+
+	Changes ChangeStats // Columns driving the updates
+
+	BatchedUpserts bool      // Updates were written as multi-row INSERT ... ON DUPLICATE KEY UPDATE
+	UpsertWrites   bool      // Inserts were written by the same upserts (UPSERT_WRITES)
+	FeatureFlags   flags.Set // Effective feature flags of the run
+
+	Mode         string // SYNC_MODE of the run
+	QuantityOnly bool   // SYNC_MODE=quantity: prices were neither computed nor written
+	NewDeferred  int    // Products missing from MySQL left to the next full run
+
+	BatchRetries          map[string]int // Batch writes repeated after a transient error, per error class
+	BatchRetriesExhausted int            // Batches that still failed after BATCH_RETRIES retries
+	UnwrittenRows         int            // Rows of the batches that failed, not written by the run
+	RejectedRows          []int          // Keys MySQL refused, left out of their batches (BATCH_ISOLATE_ERRORS)
+	UnscannedRows         int            // Firebird rows that failed to scan, skipped by the run
+	Errors                map[string]int // Errors the run met and survived, per db.ErrorClass; retried attempts included
+
+	SpotChecks *SpotCheckStats // Nil unless SPOT_CHECK_IDS or SPOT_CHECK_SAMPLE is configured
+
+	LargeChanges *LargeChangeStats // Nil unless NOTIFY_TOP_CHANGES is set
+
+	ClockSkews []ClockSkew // Clocks compared at run start, nil with CLOCK_SKEW_MAX=0
+
+	HashPreload bool // MYSQL_PRELOAD=hash: stored rows were compared by hash, changed columns are unknown
+
+	SoftDeleted int // Rows missing from Firebird marked deleted in SOFT_DELETE_COLUMN
+	Reactivated int // Rows marked deleted read from Firebird again
 }
 
+// pipelineChunk is the number of operations handed to a worker at once.
+// The work channel holds up to two chunks per worker, so the Firebird
+// cursor is read no further ahead than the writers can keep up with.
+const pipelineChunk = 100
+
+// errRowLimit stops the product read once ROW_LIMIT rows were handled
+var errRowLimit = errors.New("row limit reached")
+
 // Operation types
 type OperationType int
 
@@ -44,179 +149,614 @@ const (
 	OpIgnore
 )
 
-// RowOperation represents a single database operation
+// RowOperation represents a single database operation.
+// Monetary fields are carried as cents and converted only when bound to SQL.
 type RowOperation struct {
 	Type      OperationType
 	IDEstoque int
+	IDGrupo   int
 	Descricao string
 	QtdAtual  float64
-	PrcCusto  float64
-	PrcDolar  float64
-	PrcVenda  float64
-	Prc3x     float64
-	Prc6x     float64
-	Prc10x    float64
+	PrcCusto  money.Cents
+	PrcDolar  money.Cents
+	PrcVenda  money.Cents
+	Prc3x     money.Cents
+	Prc6x     money.Cents
+	Prc10x    money.Cents
+
+	// NULL written instead of PrcCusto and PrcDolar (NULL_POLICIES)
+	PrcCustoNull, PrcDolarNull bool
+
+	// Quantity audit: QtdAtual = QtdOrigem - QtdReservada when reservations apply
+	QtdOrigem    float64
+	QtdReservada float64
+
+	Visivel int           // Visibility flag written with STOCK_POLICY=hide
+	Status  string        // Lifecycle status written with STATUS_MAP
+	Extra   []interface{} // PRODUCT_EXTRA_COLUMNS values
+
+	existing       *mysqlRecord // Current MySQL values for updates, nil for inserts and with MYSQL_PRELOAD=hash
+	violations     constraintViolation
+	priceProtected bool // A sale price change was suppressed by PROTECTED_ROWS_QUERY
+	deferred       bool // A new product was left to the next full run (SYNC_MODE=quantity)
+	stockPolicy    bool // STOCK_POLICY changed how the row is written
+	stockSkipped   bool // STOCK_POLICY=skip left the row untouched
+	statusUnmapped bool // The Firebird STATUS has no STATUS_MAP entry
+	exprFailed     bool // A PRODUCT_EXTRA_COLUMNS or PRODUCT_TRANSFORMS expression failed
+	dollarDerived  bool // PRC_DOLAR was derived from PRC_CUSTO and the exchange rate
+
+	pricingRule string         // PRICING_RULES_FILE rule the prices were calculated with, "" for the global margins
+	margins     config.Margins // Margins the prices were calculated with
+
+	changedColumns []string // Columns that differ from the stored row, for updates
+}
+
+// sourceRow is a product row as read from Firebird
+type sourceRow struct {
+	IDEstoque int
+	IDGrupo   int
+	Descricao string
+	QtdAtual  float64
+	PrcCusto  money.NullCents
+	PrcDolar  money.NullCents
+	Status    string
+	Modified  time.Time // INCREMENTAL_COLUMN, zero outside incremental mode
+	Supplier  string    // PRICING_SUPPLIER_COLUMN, read when a pricing rule tests SUPPLIER
+
+	HasGrupo, HasStatus, HasSupplier bool // ID_GRUPO, STATUS and the supplier are not NULL
+}
+
+// lookups holds the MySQL-side data rows are compared against
+type lookups struct {
+	columns   []productColumn // TB_ESTOQUE columns compared and written
+	existing  map[int]mysqlRecord
+	hashes    map[int]uint64   // Stored row hashes instead of existing with MYSQL_PRELOAD=hash
+	hashed    []productColumn  // Columns folded into the hashes
+	protected map[int]struct{} // Keys whose sale prices must not be overwritten
+	reserved  map[int]float64  // Quantities reserved by the webshop, per key
+	usdRate   float64          // USD/BRL rate deriving PRC_DOLAR without an indexer value, 0 for none
+	progress  *run.Progress    // Reported the rows loaded, nil for none
+}
+
+// writer holds what the batch writers share across workers
+type writer struct {
+	db           *sql.DB
+	cfg          config.Config
+	key          string          // TB_ESTOQUE key column
+	columns      []productColumn // TB_ESTOQUE columns written besides the key
+	upsert       bool            // Updates are written as multi-row upserts (the key is unique)
+	upsertAll    bool            // Inserts are written with the updates (UPSERT_WRITES)
+	packetLimit  int             // Bytes a single statement may use, from max_allowed_packet
+	runID        string
+	retry        *transfer.Retrier
+	historyCount atomic.Int64
+	auditCount   atomic.Int64
+	unwritten    atomic.Int64 // Rows of batches still failing after their retries, or dropped by a cancelled run
+	diff         *diffReport  // DIFF_REPORT_FILE, nil when not written
+	changed      changedKeys
+	rejected     changedKeys // Keys left out by BATCH_ISOLATE_ERRORS
 }
 
 // ProcessRows - High-performance version using worker pool pattern
 func ProcessRows(ctx context.Context, firebirdDB, mysqlDB *sql.DB, numWorkers int, cfg config.Config) (inserted, updated, ignored int, batchSize int, stats *ProcessingStats, err error) {
 	log := logger.GetLogger()
-	stats = &ProcessingStats{}
+	stats = &ProcessingStats{RunID: run.IDFrom(ctx), Mode: cfg.SyncMode, QuantityOnly: cfg.QuantityOnly(), FeatureFlags: cfg.FeatureFlags, Scope: cfg.SyncOnly}
 
-	// Load MySQL records into memory
-	startLoad := time.Now()
-	existingRecords, err := loadMySQLRecords(mysqlDB)
-	if err != nil {
-		return 0, 0, 0, 0, nil, fmt.Errorf("error loading MySQL records: %w", err)
+	if err := db.EnsurePriceHistoryTable(mysqlDB, cfg); err != nil {
+		return 0, 0, 0, 0, nil, err
+	}
+	if err := db.EnsureAuditTable(mysqlDB, cfg); err != nil {
+		return 0, 0, 0, 0, nil, err
+	}
+	if err := db.EnsureCustomerTable(mysqlDB, cfg); err != nil {
+		return 0, 0, 0, 0, nil, err
+	}
+	if err := db.EnsureCategoryTable(mysqlDB, cfg); err != nil {
+		return 0, 0, 0, 0, nil, err
+	}
+	if err := db.EnsureWarehouseTable(mysqlDB, cfg); err != nil {
+		return 0, 0, 0, 0, nil, err
+	}
+	if err := db.EnsureChangedKeysTable(mysqlDB, cfg); err != nil {
+		return 0, 0, 0, 0, nil, err
+	}
+	if err := db.EnsureReverseTable(mysqlDB, cfg); err != nil {
+		return 0, 0, 0, 0, nil, err
+	}
+	if err := db.EnsureMovementTable(mysqlDB, cfg); err != nil {
+		return 0, 0, 0, 0, nil, err
+	}
+	if err := db.EnsureOrderExportTable(mysqlDB, cfg); err != nil {
+		return 0, 0, 0, 0, nil, err
+	}
+	if err := db.EnsureBlobTable(mysqlDB, cfg); err != nil {
+		return 0, 0, 0, 0, nil, err
 	}
-	stats.LoadTime = time.Since(startLoad)
-	log.Info().Int("records", len(existingRecords)).Msg("MySQL records loaded")
 
-	// Query Firebird
-	query := `
-        SELECT 
-            e.ID_ESTOQUE, 
-            e.DESCRICAO, 
-            p.QTD_ATUAL, 
-            e.PRC_CUSTO, 
-            i.VALOR AS PRC_DOLAR
-        FROM TB_ESTOQUE e
-        JOIN TB_EST_PRODUTO p 
-            ON e.ID_ESTOQUE = p.ID_IDENTIFICADOR
-        LEFT JOIN TB_EST_INDEXADOR i 
-            ON i.ID_ESTOQUE = e.ID_ESTOQUE
-        WHERE e.STATUS = 'A'
-    `
-
-	startQuery := time.Now()
-	rows, err := firebirdDB.QueryContext(ctx, query)
-	if err != nil {
-		return 0, 0, 0, 0, nil, fmt.Errorf("error querying Firebird: %w", err)
+	// Watermarks and change times are only comparable between agreeing clocks
+	if stats.ClockSkews, err = checkClockSkew(ctx, firebirdDB, mysqlDB, cfg); err != nil {
+		return 0, 0, 0, 0, nil, err
+	}
+
+	retrier := transfer.NewRetrier(cfg)
+	// Parent rows first: products reference their category
+	if categorySyncEnabled(cfg.CategoryQuery) && !cfg.QuantityOnly() && cfg.Syncs(config.PartCategories) {
+		if stats.Categories, err = syncCategories(ctx, firebirdDB, mysqlDB, cfg.CategoryQuery, retrier); err != nil {
+			return 0, 0, 0, 0, nil, err
+		}
+	}
+	if tables := cfg.SyncedTables(); len(tables) > 0 {
+		stats.Tables, err = syncTables(ctx, firebirdDB, mysqlDB, tables, retrier, transfer.StatementLimit(db.MaxAllowedPacket(mysqlDB, cfg)))
+		if err != nil {
+			return 0, 0, 0, 0, nil, err
+		}
+	}
+
+	// Customers are independent of the products; quantity-only runs leave them alone
+	if m, ok := cfg.CustomerMapping(); ok && !cfg.QuantityOnly() && cfg.Syncs(config.PartCustomers) {
+		customers, err := syncTables(ctx, firebirdDB, mysqlDB, []config.TableMapping{m}, retrier, transfer.StatementLimit(db.MaxAllowedPacket(mysqlDB, cfg)))
+		if err != nil {
+			return 0, 0, 0, 0, nil, err
+		}
+		stats.Customers = &customers[0]
+	}
+
+	// MySQL-managed columns go back to Firebird on full runs only, they are
+	// not read by the product query so the order does not matter
+	if len(cfg.ReverseColumns) > 0 && !cfg.QuantityOnly() && cfg.Syncs(config.PartReverse) {
+		if stats.Reverse, err = syncReverse(ctx, firebirdDB, mysqlDB, cfg, retrier); err != nil {
+			return 0, 0, 0, 0, nil, fmt.Errorf("error pushing columns back into Firebird: %w", err)
+		}
 	}
-	defer rows.Close()
-	stats.QueryTime = time.Since(startQuery)
-	log.Info().Msg("Firebird query executed")
 
 	// Calculate batch size
 	batchSize = 500 // Optimal batch size for bulk operations
 
-	// Channel for work distribution
-	workChan := make(chan RowOperation, batchSize*2) // Buffered channel
+	var changed []int
+	if cfg.Syncs(config.PartProducts) {
+		if inserted, updated, ignored, changed, err = syncProducts(ctx, firebirdDB, mysqlDB, numWorkers, cfg, retrier, stats); err != nil {
+			return 0, 0, 0, 0, nil, err
+		}
+	} else {
+		log.Info().Strs("scope", cfg.SyncOnly).Msg("Products are outside the run's scope, TB_ESTOQUE left alone")
+	}
+	reportRetries(retrier, stats)
+
+	// Child rows are synced once every parent row has been written
+	if warehouseSyncEnabled(cfg.WarehouseQuery) && cfg.Syncs(config.PartWarehouses) {
+		stats.Warehouses, err = syncWarehouses(ctx, firebirdDB, mysqlDB, cfg.WarehouseQuery, productKeyColumn(cfg))
+		if err != nil {
+			return 0, 0, 0, 0, nil, err
+		}
+	}
+
+	// The ledger only grows, so new movements are cheap to read in every mode
+	if cfg.MovementQuery != "" && cfg.Syncs(config.PartMovements) {
+		if stats.Movements, err = syncMovements(ctx, firebirdDB, mysqlDB, cfg.MovementQuery, retrier); err != nil {
+			return 0, 0, 0, 0, nil, err
+		}
+	}
+
+	// Orders go the other way in every mode: they cannot wait for a full run
+	if cfg.OrderExportQuery != "" && cfg.Syncs(config.PartOrders) {
+		if stats.Orders, err = exportOrders(ctx, firebirdDB, mysqlDB, cfg, retrier); err != nil {
+			return 0, 0, 0, 0, nil, err
+		}
+	}
+
+	if catalogValidationEnabled(cfg) && !cfg.QuantityOnly() && cfg.Syncs(config.PartProducts) {
+		cs, err := validateCatalog(ctx, firebirdDB, mysqlDB, cfg)
+		if err != nil {
+			return 0, 0, 0, 0, nil, err
+		}
+		stats.Catalog = &cs
+	}
+
+	if cfg.PriceHistoryEnabled {
+		if _, err := purgePriceHistory(mysqlDB, cfg.PriceHistoryRetentionDays); err != nil {
+			log.Warn().Err(err).Msg("Could not apply price history retention")
+		}
+	}
+	if cfg.AuditEnabled {
+		if _, err := purgeAudit(mysqlDB, cfg.AuditRetentionDays); err != nil {
+			log.Warn().Err(err).Msg("Could not apply audit retention")
+		}
+	}
+
+	// Run post-processing procedures, which work on the TB_ESTOQUE rows
+	run.Touch(ctx)
+	stats.ChangedIDs = len(changed)
+	if cfg.Syncs(config.PartProducts) {
+		if cfg.ChangedKeysEnabled {
+			if err := writeChangedKeys(ctx, mysqlDB, stats.RunID, changed); err != nil {
+				return 0, 0, 0, 0, nil, err
+			}
+		}
+		progress := run.ProgressFrom(ctx)
+		progress.Begin(run.PhaseProcedure, 0)
+		err := runPostProcessing(mysqlDB, stats, cfg, changed)
+		progress.End(run.PhaseProcedure)
+		if err != nil {
+			return 0, 0, 0, 0, nil, err
+		}
+	}
+
+	if cfg.PostSyncSQL != "" {
+		stats.HooksExecuted, err = runPostSyncHooks(ctx, mysqlDB, stats.RunID, cfg.PostSyncSQL)
+		if err != nil {
+			return 0, 0, 0, 0, nil, err
+		}
+	}
+
+	// Rows past the limit were not read, the next run must still read them
+	if incremental(cfg) && cfg.Syncs(config.PartProducts) && stats.RowLimit == 0 {
+		if err := advanceWatermark(cfg, stats); err != nil {
+			return 0, 0, 0, 0, nil, fmt.Errorf("error saving incremental watermark: %w", err)
+		}
+	}
+
+	return inserted, updated, ignored, batchSize, stats, nil
+}
+
+// syncProducts syncs TB_ESTOQUE: it reads the Firebird products, writes
+// the rows that changed with the worker pool, soft deletes the missing ones,
+// syncs their BLOBs and reads the spot-checked rows back. It returns the keys
+// written, for the procedures.
+func syncProducts(ctx context.Context, firebirdDB, mysqlDB *sql.DB, numWorkers int, cfg config.Config, retrier *transfer.Retrier, stats *ProcessingStats) (inserted, updated, ignored int, changed []int, err error) {
+	log := logger.GetLogger()
+
+	// Load MySQL records into memory
+	progress := run.ProgressFrom(ctx)
+	lk := &lookups{columns: productColumns(cfg), progress: progress}
+	var loading stageTimer
+	if err := loading.measure(func() error { return lk.load(mysqlDB, cfg) }); err != nil {
+		return 0, 0, 0, nil, fmt.Errorf("error loading MySQL records: %w", err)
+	}
+	progress.End(run.PhaseLoad)
+	stats.LoadTime = loading.total
+	stats.HashPreload = lk.hashes != nil
+	log.Info().Int("records", lk.len()).Bool("hash", stats.HashPreload).Msg("MySQL records loaded")
+	run.Touch(ctx)
+
+	// Protection and PRC_DOLAR only concern prices, which quantity-only runs leave alone
+	if !cfg.QuantityOnly() {
+		if stats.ExchangeRate = loadExchangeRate(ctx, cfg); stats.ExchangeRate != nil {
+			lk.usdRate = stats.ExchangeRate.Rate
+		}
+		if lk.protected, err = loadProtectedKeys(ctx, mysqlDB, cfg.ProtectedRowsQuery); err != nil {
+			return 0, 0, 0, nil, err
+		}
+	}
+	if lk.reserved, err = loadReservations(ctx, mysqlDB, cfg.ReservationsQuery); err != nil {
+		return 0, 0, 0, nil, err
+	}
+
+	// Query Firebird
+	var since time.Time
+	if incremental(cfg) {
+		// Reconciliation runs read every row and still advance the watermark
+		if !cfg.Reconcile() {
+			if since, err = loadWatermark(cfg); err != nil {
+				return 0, 0, 0, nil, fmt.Errorf("error loading incremental watermark: %w", err)
+			}
+		}
+		stats.Incremental = true
+		stats.Since = since
+		log.Info().Str("column", cfg.IncrementalColumn).Time("since", since).Bool("full", since.IsZero()).Msg("Incremental sync")
+	}
+
+	// With FIREBIRD_ISOLATION the key bounds and every product row are read
+	// in one transaction, ended once the extract is done
+	var source sourceQuerier = firebirdDB
+	sourceTx, txID, err := beginSourceTx(ctx, firebirdDB, mysqlDB, cfg)
+	if err != nil {
+		return 0, 0, 0, nil, err
+	}
+	if sourceTx != nil {
+		defer sourceTx.Rollback()
+		source = sourceTx
+		stats.FirebirdIsolation, stats.FirebirdTransaction = cfg.FirebirdIsolation, txID
+	}
+
+	// Queues for work distribution, bounded so reading waits for the writers
+	queues, err := newWorkQueues(ctx, source, cfg, numWorkers)
+	if err != nil {
+		return 0, 0, 0, nil, err
+	}
+	if cfg.WorkerRouting != "" {
+		log.Info().Str("routing", cfg.WorkerRouting).Int("workers", numWorkers).Msg("Routing product keys to workers")
+	}
 
 	// Atomic counters for thread-safe counting
 	var insertedCount, updatedCount, ignoredCount atomic.Int64
 
 	// Worker pool
 	var wg sync.WaitGroup
-	processingStart := time.Now()
-
-	// Start workers
+	w := &writer{db: mysqlDB, cfg: cfg, key: productKeyColumn(cfg), columns: lk.columns, runID: stats.RunID, retry: retrier}
+	w.packetLimit = transfer.StatementLimit(db.MaxAllowedPacket(mysqlDB, cfg))
+	// Quantity-only updates write too few columns to insert a row, which an
+	// upsert must be able to do, so they update in place
+	if !cfg.FeatureFlags.Enabled(flags.BatchedUpserts, true) {
+		log.Info().Str("flag", flags.BatchedUpserts).Msg("Batched upserts switched off by feature flag, updating row by row")
+	} else if !cfg.QuantityOnly() {
+		w.upsert, err = db.HasUniqueKey(mysqlDB, cfg, "TB_ESTOQUE", w.key)
+		if err != nil || !w.upsert {
+			log.Warn().Err(err).Str("key", w.key).Msg("TB_ESTOQUE key is not a unique index, updating row by row")
+		}
+	}
+	w.upsertAll = w.upsert && cfg.UpsertWrites
+	if cfg.UpsertWrites && !w.upsertAll && !cfg.QuantityOnly() {
+		log.Warn().Msg("UPSERT_WRITES needs batched upserts, inserting and updating separately")
+	}
+	stats.BatchedUpserts, stats.UpsertWrites = w.upsert, w.upsertAll
+	w.diff = newDiffReport(cfg, stats.RunID, lk.columns)
+	defer w.diff.discard()
+
+	// Start workers; processing spans reading, computing and writing every
+	// row, the Firebird reads (QueryTime) being part of it
+	var processing stageTimer
+	processing.start()
+	// Rows to read, estimated from the rows already synced; unknown for incremental reads
+	estimate := lk.len()
+	if !since.IsZero() {
+		estimate = 0
+	} else if cfg.RowLimit > 0 {
+		estimate = min(estimate, cfg.RowLimit)
+	}
+	progress.Begin(run.PhaseQuery, estimate)
+	progress.Begin(run.PhaseProcess, estimate)
 	for i := 0; i < numWorkers; i++ {
 		wg.Add(1)
-		go worker(ctx, i, workChan, mysqlDB, &insertedCount, &updatedCount, &ignoredCount, &wg, cfg)
+		go worker(ctx, i, queues.queue(i), w, &insertedCount, &updatedCount, &ignoredCount, &wg)
 	}
 
-	// Feed workers from Firebird query
-	rowCount := 0
-	for rows.Next() {
-		var idEstoque int
-		var descricao string
-		var qtdAtual float64
-		var prcCusto, prcDolar sql.NullFloat64
+	// Rows a full read misses are soft deleted, which an incremental read cannot tell
+	var read map[int]struct{}
+	if cfg.SoftDeleteColumn != "" {
+		if since.IsZero() {
+			read = make(map[int]struct{}, lk.len())
+		} else {
+			log.Info().Str("column", cfg.SoftDeleteColumn).Msg("Incremental read, rows missing from Firebird are not marked deleted")
+		}
+	}
 
-		if err := rows.Scan(&idEstoque, &descricao, &qtdAtual, &prcCusto, &prcDolar); err != nil {
-			log.Error().Err(err).Int("id_estoque", idEstoque).Msg("Error scanning Firebird row")
-			continue
+	// Feed workers from Firebird query, a chunk of operations at a time
+	spot := newSpotChecker(cfg)
+	large := newLargeChangeTracker(cfg)
+	var sourceRows int
+	handle := func(src sourceRow) error {
+		if cfg.RowLimit > 0 && sourceRows == cfg.RowLimit {
+			stats.RowLimit = cfg.RowLimit
+			return errRowLimit
+		}
+		sourceRows++
+		progress.Advance(run.PhaseQuery, 1)
+		if src.Modified.After(stats.Watermark) {
+			stats.Watermark = src.Modified
+		}
+		if read != nil {
+			read[src.IDEstoque] = struct{}{}
+		}
+		if filteredOut(cfg, src) {
+			stats.Filtered++
+			return nil
 		}
 
 		// Process row
-		op := processRowOptimized(existingRecords, idEstoque, descricao, qtdAtual, prcCusto, prcDolar, cfg)
+		var op RowOperation
+		if cfg.QuantityOnly() {
+			op = processQuantityRow(lk, src, cfg)
+		} else {
+			op = processRowOptimized(lk, src, cfg)
+			stats.Analytics.observe(op)
+			if len(cfg.PricingRules) > 0 {
+				if stats.PricingRules == nil {
+					stats.PricingRules = make(map[string]int)
+				}
+				stats.PricingRules[op.pricingRule]++
+			}
+		}
+		stats.Changes.observe(op)
+		spot.observe(op)
+		large.observe(op)
+		stats.Constraints.observe(op, cfg.PriceConstraintPolicy)
+		if op.priceProtected {
+			stats.ProtectedSkipped++
+		}
+		if op.QtdReservada != 0 {
+			stats.ReservationAdjusted++
+			stats.ReservedQuantity += op.QtdReservada
+		}
+		if op.stockPolicy {
+			stats.NonPositiveStock++
+		}
+		if op.stockSkipped {
+			stats.StockSkipped++
+		}
+		if op.exprFailed {
+			stats.ExpressionErrors++
+		}
+		if op.dollarDerived {
+			stats.ExchangeRate.Converted++
+		}
+		if op.deferred {
+			stats.NewDeferred++
+		}
+		if op.Type == OpUpdate && softDeleted(cfg, op.existing) {
+			stats.Reactivated++
+		}
+		if op.statusUnmapped {
+			stats.UnmappedStatus++
+		} else if op.Status != "" {
+			if stats.StatusCounts == nil {
+				stats.StatusCounts = make(map[string]int)
+			}
+			stats.StatusCounts[op.Status]++
+		}
 
-		select {
-		case workChan <- op:
-			rowCount++
-		case <-ctx.Done():
-			close(workChan)
-			return 0, 0, 0, 0, nil, ctx.Err()
+		run.Touch(ctx)
+		return queues.add(ctx, op)
+	}
+	err = readSource(ctx, source, cfg, since, retrier, stats, handle)
+	progress.End(run.PhaseQuery)
+	if errors.Is(err, errRowLimit) {
+		log.Warn().Int("limit", stats.RowLimit).Msg("ROW_LIMIT reached, product read stopped")
+		err = nil
+	}
+	if err == nil && sourceTx != nil {
+		// Read-only work: committing only ends the transaction
+		if err = sourceTx.Commit(); err != nil {
+			err = fmt.Errorf("error ending Firebird read transaction: %w", err)
 		}
 	}
+	if err == nil {
+		err = queues.flush(ctx)
+	}
 
-	// Close work channel and wait for workers
-	close(workChan)
+	// Close the work queues and wait for workers
+	queues.close()
 	wg.Wait()
+	progress.End(run.PhaseProcess)
+	stats.UnwrittenRows = int(w.unwritten.Load())
 
-	if err = rows.Err(); err != nil {
-		return 0, 0, 0, 0, nil, err
+	if err != nil {
+		return 0, 0, 0, nil, err
 	}
 
-	stats.ProcessingTime = time.Since(processingStart)
-	stats.TotalRows = rowCount
+	processing.stop()
+	stats.ProcessingTime = processing.total
+	stats.TotalRows = queues.sent
+	stats.PriceHistoryRows = int(w.historyCount.Load())
+	stats.AuditRows = int(w.auditCount.Load())
+	if path, rows, diffErr := w.diff.close(); diffErr != nil {
+		log.Error().Err(diffErr).Msg("Diff report not written")
+	} else if path != "" {
+		stats.DiffReport, stats.DiffReportRows = path, rows
+		log.Info().Str("path", path).Int("rows", rows).Msg("Diff report written")
+	}
+	stats.RejectedRows = w.rejected.sorted()
+	stats.LargeChanges = large.result()
+
+	// With nothing read the source is more likely broken than empty
+	if read != nil && stats.RowLimit > 0 {
+		log.Warn().Str("column", cfg.SoftDeleteColumn).Msg("Read stopped by ROW_LIMIT, rows not read are not marked deleted")
+	} else if read != nil && len(read) == 0 {
+		log.Warn().Str("column", cfg.SoftDeleteColumn).Msg("No products read from Firebird, not marking every row deleted")
+	} else if read != nil {
+		if stats.SoftDeleted, err = softDeleteMissing(ctx, mysqlDB, cfg, retrier, lk.missingKeys(cfg, read)); err != nil {
+			return 0, 0, 0, nil, err
+		}
+	}
 
-	// Run post-processing procedures
-	if err := runPostProcessing(mysqlDB, stats, cfg); err != nil {
-		return 0, 0, 0, 0, nil, err
+	// BLOBs go into the rows the batches wrote; quantity-only runs stay cheap
+	if len(cfg.BlobColumns) > 0 && !cfg.QuantityOnly() {
+		if stats.Blobs, err = syncBlobs(ctx, firebirdDB, mysqlDB, cfg, since, retrier); err != nil {
+			return 0, 0, 0, nil, fmt.Errorf("error syncing BLOB columns: %w", err)
+		}
+	}
+
+	// Every batch is committed: read the spot-checked rows back before
+	// procedures and hooks get a chance to change them
+	if stats.SpotChecks, err = spot.check(ctx, mysqlDB, cfg, lk.columns); err != nil {
+		return 0, 0, 0, nil, err
 	}
 
-	return int(insertedCount.Load()), int(updatedCount.Load()), int(ignoredCount.Load()), batchSize, stats, nil
+	return int(insertedCount.Load()), int(updatedCount.Load()), int(ignoredCount.Load()), w.changed.sorted(), nil
 }
 
-// worker processes operations from the work channel in batches
-func worker(ctx context.Context, id int, workChan <-chan RowOperation, db *sql.DB, insertedCount, updatedCount, ignoredCount *atomic.Int64, wg *sync.WaitGroup, cfg config.Config) {
+// worker processes chunks of operations from the work channel in batches
+func worker(ctx context.Context, id int, workChan <-chan []RowOperation, w *writer, insertedCount, updatedCount, ignoredCount *atomic.Int64, wg *sync.WaitGroup) {
 	defer wg.Done()
 	log := logger.GetLogger()
+	progress := run.ProgressFrom(ctx)
 
 	const batchSize = 500
 	insertBatch := make([]RowOperation, 0, batchSize)
 	updateBatch := make([]RowOperation, 0, batchSize)
 
+	// Batches are written in ID_ESTOQUE order so concurrent writers lock rows
+	// in the same order and cannot deadlock on each other. A batch still
+	// failing after its retries is counted as unwritten and dropped, never
+	// sent again with the next rows; the other batch is written regardless.
 	flushBatches := func() error {
+		release := run.AcquireWrite(ctx)
+		defer release()
+
+		var insertErr, updateErr error
 		if len(insertBatch) > 0 {
-			if err := executeBulkInsert(db, insertBatch); err != nil {
-				log.Error().Err(err).Int("worker", id).Msg("Error executing bulk insert")
+			sortByKey(insertBatch)
+			var written int
+			insertErr = w.retry.Do(ctx, "insert", len(insertBatch), func() (err error) {
+				written, err = w.executeBulkInsert(ctx, insertBatch)
 				return err
+			})
+			if insertErr != nil {
+				log.Error().Err(insertErr).Int("worker", id).Int("rows", len(insertBatch)).Msg("Error executing bulk insert")
+				w.unwritten.Add(int64(len(insertBatch)))
+			} else {
+				insertedCount.Add(int64(written))
+				progress.Written(written, 0, 0)
 			}
-			insertedCount.Add(int64(len(insertBatch)))
 			insertBatch = insertBatch[:0]
+			run.Touch(ctx)
 		}
 
 		if len(updateBatch) > 0 {
-			if err := executeBulkUpdate(db, updateBatch); err != nil {
-				log.Error().Err(err).Int("worker", id).Msg("Error executing bulk update")
+			sortByKey(updateBatch)
+			var inserted, updated int
+			updateErr = w.retry.Do(ctx, "update", len(updateBatch), func() (err error) {
+				inserted, updated, err = w.executeBulkUpdate(ctx, updateBatch)
 				return err
+			})
+			if updateErr != nil {
+				log.Error().Err(updateErr).Int("worker", id).Int("rows", len(updateBatch)).Msg("Error executing bulk update")
+				w.unwritten.Add(int64(len(updateBatch)))
+			} else {
+				insertedCount.Add(int64(inserted))
+				updatedCount.Add(int64(updated))
+				progress.Written(inserted, updated, 0)
 			}
-			updatedCount.Add(int64(len(updateBatch)))
 			updateBatch = updateBatch[:0]
+			run.Touch(ctx)
 		}
-		return nil
+		return errors.Join(insertErr, updateErr)
 	}
 
 	// Process work items
-	for op := range workChan {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-		}
-
-		switch op.Type {
-		case OpInsert:
-			insertBatch = append(insertBatch, op)
-			if len(insertBatch) >= batchSize {
-				if err := flushBatches(); err != nil {
-					log.Error().Err(err).Msg("Error flushing insert batch")
+	for chunk := range workChan {
+		// A cancelled run writes no more chunks; the channel is drained so its
+		// rows count as unwritten, keeping the run partial and its watermark
+		if ctx.Err() != nil {
+			w.unwritten.Add(int64(len(chunk)))
+			continue
+		}
+
+		for _, op := range chunk {
+			switch op.Type {
+			case OpInsert:
+				if w.upsertAll {
+					updateBatch = append(updateBatch, op)
+				} else {
+					insertBatch = append(insertBatch, op)
+				}
+				if len(insertBatch) >= batchSize || len(updateBatch) >= batchSize {
+					if err := flushBatches(); err != nil {
+						log.Error().Err(err).Msg("Error flushing insert batch")
+					}
 				}
-			}
 
-		case OpUpdate:
-			updateBatch = append(updateBatch, op)
-			if len(updateBatch) >= batchSize {
-				if err := flushBatches(); err != nil {
-					log.Error().Err(err).Msg("Error flushing update batch")
+			case OpUpdate:
+				updateBatch = append(updateBatch, op)
+				if len(updateBatch) >= batchSize {
+					if err := flushBatches(); err != nil {
+						log.Error().Err(err).Msg("Error flushing update batch")
+					}
 				}
-			}
 
-		case OpIgnore:
-			ignoredCount.Add(1)
+			case OpIgnore:
+				ignoredCount.Add(1)
+				progress.Written(0, 0, 1)
+			}
 		}
 	}
 
@@ -226,209 +766,313 @@ func worker(ctx context.Context, id int, workChan <-chan RowOperation, db *sql.D
 	}
 }
 
-// processRowOptimized determines what operation to perform on a row
-func processRowOptimized(existingRecords map[int]mysqlRecord, idEstoque int, descricao string, qtdAtual float64, prcCusto, prcDolar sql.NullFloat64, cfg config.Config) RowOperation {
-	// Calculate prices
-	prcVenda, prc3x, prc6x, prc10x := calculatePrices(prcCusto, cfg)
-	custo := roundFloat(prcCusto)
-	dolar := roundFloat(prcDolar)
+// processRowOptimized determines what operation to perform on a row.
+// Ignored rows keep their values so run analytics can account for them.
+func processRowOptimized(lk *lookups, src sourceRow, cfg config.Config) RowOperation {
+	// Calculate prices with the margins of the first matching pricing rule
+	rule, margins := pricingRule(cfg, src)
+	prcVenda, prc3x, prc6x, prc10x := calculatePrices(src.PrcCusto, margins)
+
+	op := RowOperation{
+		Type:      OpInsert,
+		IDEstoque: src.IDEstoque,
+		IDGrupo:   src.IDGrupo,
+		Descricao: src.Descricao,
+		QtdAtual:  src.QtdAtual,
+		PrcCusto:  src.PrcCusto.OrZero(),
+		PrcDolar:  src.PrcDolar.OrZero(),
+		PrcVenda:  prcVenda,
+		Prc3x:     prc3x,
+		Prc6x:     prc6x,
+		Prc10x:    prc10x,
 
-	rec, exists := existingRecords[idEstoque]
+		pricingRule: rule,
+		margins:     margins,
+	}
+
+	// Without an indexer value PRC_DOLAR is the cost at the current exchange rate
+	if op.PrcDolar == 0 && op.PrcCusto > 0 && lk.usdRate > 0 {
+		op.PrcDolar = money.Convert(op.PrcCusto, lk.usdRate)
+		op.dollarDerived = true
+	}
+
+	if r, ok := lk.reserved[src.IDEstoque]; ok {
+		applyReservation(&op, r)
+	}
+
+	op.stockPolicy = applyStockPolicy(&op, cfg)
+
+	if mapsStatus(cfg) {
+		status, ok := cfg.StatusMap[src.Status]
+		if !ok {
+			op.statusUnmapped = true
+			op.Type = OpIgnore
+			return op
+		}
+		op.Status = status
+	}
+
+	var exists bool
+	op.existing, exists = lk.find(src.IDEstoque)
+
+	if op.stockSkipped {
+		op.Type = OpIgnore
+		return op
+	}
+	applyNullPolicies(&op, src, cfg, exists)
+
+	// Transformed prices are still subject to protection and constraints
+	transformed := applyTransforms(&op, src, cfg)
+	if _, ok := lk.protected[src.IDEstoque]; ok && exists {
+		protectPrices(&op)
+	} else {
+		applyPriceConstraints(&op, cfg)
+	}
+	op.exprFailed = !evalExtraColumns(&op, src, cfg) || !transformed
 
 	// New record
 	if !exists {
-		return RowOperation{
-			Type:      OpInsert,
-			IDEstoque: idEstoque,
-			Descricao: descricao,
-			QtdAtual:  qtdAtual,
-			PrcCusto:  custo,
-			PrcDolar:  dolar,
-			PrcVenda:  prcVenda,
-			Prc3x:     prc3x,
-			Prc6x:     prc6x,
-			Prc10x:    prc10x,
-		}
+		return op
 	}
 
 	// Check if update needed
-	existingCusto := roundFloat(rec.ValorCusto)
-	existingDolar := roundFloat(rec.ValorUsd)
-	existingPrcVenda := roundFloat(rec.PrcVenda)
-	existingPrc3x := roundFloat(rec.Prc3x)
-	existingPrc6x := roundFloat(rec.Prc6x)
-	existingPrc10x := roundFloat(rec.Prc10x)
-
-	if rec.Descricao.Valid && rec.Quantidade.Valid &&
-		rec.Descricao.String == descricao &&
-		rec.Quantidade.Float64 == qtdAtual &&
-		existingCusto == custo &&
-		existingDolar == dolar &&
-		existingPrcVenda == prcVenda &&
-		existingPrc3x == prc3x &&
-		existingPrc6x == prc6x &&
-		existingPrc10x == prc10x {
-		return RowOperation{Type: OpIgnore}
-	}
-
-	// Update needed
-	return RowOperation{
-		Type:      OpUpdate,
-		IDEstoque: idEstoque,
-		Descricao: descricao,
-		QtdAtual:  qtdAtual,
-		PrcCusto:  custo,
-		PrcDolar:  dolar,
-		PrcVenda:  prcVenda,
-		Prc3x:     prc3x,
-		Prc6x:     prc6x,
-		Prc10x:    prc10x,
+	if !lk.changed(&op) {
+		op.Type = OpIgnore
+		return op
 	}
+	op.Type = OpUpdate
+	return op
 }
 
-// executeBulkInsert performs a true bulk INSERT with multi-value syntax
-func executeBulkInsert(db *sql.DB, ops []RowOperation) error {
+// executeBulkInsert performs a true bulk INSERT with multi-value syntax,
+// split into statements that fit max_allowed_packet. It returns the number
+// of rows written, fewer than ops when BATCH_ISOLATE_ERRORS left rows out.
+// ctx only carries the run ID into the replay log: a started batch is not cancelled.
+func (w *writer) executeBulkInsert(ctx context.Context, ops []RowOperation) (int, error) {
 	if len(ops) == 0 {
-		return nil
+		return 0, nil
 	}
 
 	log := logger.GetLogger()
 
-	// Build multi-value INSERT statement
-	var sb strings.Builder
-	sb.WriteString("INSERT INTO TB_ESTOQUE (ID_ESTOQUE, DESCRICAO, QTD_ATUAL, PRC_CUSTO, PRC_DOLAR, PRC_VENDA, PRC_3X, PRC_6X, PRC_10X) VALUES ")
+	tx, err := w.db.BeginTx(context.WithoutCancel(ctx), nil)
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %w", err)
+	}
 
-	values := make([]interface{}, 0, len(ops)*9)
-	for i, op := range ops {
-		if i > 0 {
-			sb.WriteString(", ")
+	var written []RowOperation
+	for _, chunk := range w.chunkOps(ops) {
+		ok, err := w.isolate(tx, chunk, func(rows []RowOperation) error {
+			query, values := w.multiRowInsert(rows)
+			_, err := tx.Exec(query, values...)
+			return err
+		})
+		if err != nil {
+			tx.Rollback()
+			log.Error().Err(err).Int("count", len(chunk)).Msg("Bulk insert failed")
+			return 0, fmt.Errorf("bulk insert failed: %w", err)
 		}
-		sb.WriteString("(?, ?, ?, ?, ?, ?, ?, ?, ?)")
-		values = append(values, op.IDEstoque, op.Descricao, op.QtdAtual, op.PrcCusto, op.PrcDolar, op.PrcVenda, op.Prc3x, op.Prc6x, op.Prc10x)
+		written = append(written, ok...)
 	}
 
-	_, err := db.Exec(sb.String(), values...)
+	history, err := w.recordPriceHistory(tx, written)
 	if err != nil {
-		log.Error().Err(err).Int("count", len(ops)).Msg("Bulk insert failed")
-		return fmt.Errorf("bulk insert failed: %w", err)
+		tx.Rollback()
+		return 0, err
+	}
+	audited, err := w.recordAudit(tx, written)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
 	}
 
-	log.Debug().Int("count", len(ops)).Msg("Bulk insert successful")
-	return nil
+	if err := tx.Commit(); err != nil {
+		log.Error().Err(err).Int("count", len(ops)).Msg("Bulk insert commit failed")
+		return 0, fmt.Errorf("bulk insert commit failed: %w", err)
+	}
+	w.changed.add(written)
+	w.rejected.add(rejectedFrom(ops, written))
+	w.historyCount.Add(int64(history))
+	w.auditCount.Add(int64(audited))
+	w.diff.add(written)
+
+	log.Debug().Int("count", len(written)).Msg("Bulk insert successful")
+	return len(written), nil
 }
 
-// executeBulkUpdate performs batch updates (MySQL doesn't support multi-row UPDATE well, so we use transaction)
-func executeBulkUpdate(db *sql.DB, ops []RowOperation) error {
+// executeBulkUpdate performs batch updates in one transaction: multi-row
+// upserts when the key is unique, one UPDATE per row otherwise. With
+// UPSERT_WRITES, ops holds the inserts too. It returns the number of rows
+// inserted and updated, fewer than ops when BATCH_ISOLATE_ERRORS left rows out.
+func (w *writer) executeBulkUpdate(ctx context.Context, ops []RowOperation) (inserted, updated int, err error) {
 	if len(ops) == 0 {
-		return nil
+		return 0, 0, nil
 	}
 
 	log := logger.GetLogger()
 
-	tx, err := db.Begin()
+	tx, err := w.db.BeginTx(context.WithoutCancel(ctx), nil)
 	if err != nil {
-		return fmt.Errorf("error starting transaction: %w", err)
+		return 0, 0, fmt.Errorf("error starting transaction: %w", err)
 	}
 
-	stmt, err := tx.Prepare(`
-		UPDATE TB_ESTOQUE 
-		SET DESCRICAO = ?, QTD_ATUAL = ?, PRC_CUSTO = ?, PRC_DOLAR = ?, 
-			PRC_VENDA = ?, PRC_3X = ?, PRC_6X = ?, PRC_10X = ?
-		WHERE ID_ESTOQUE = ?
-	`)
+	var written []RowOperation
+	if w.upsert {
+		written, err = w.execUpserts(tx, ops)
+	} else {
+		written, err = w.execRowUpdates(tx, ops)
+	}
 	if err != nil {
 		tx.Rollback()
-		return fmt.Errorf("error preparing update statement: %w", err)
+		return 0, 0, err
 	}
-	defer stmt.Close()
 
-	for _, op := range ops {
-		_, err := stmt.Exec(op.Descricao, op.QtdAtual, op.PrcCusto, op.PrcDolar, op.PrcVenda, op.Prc3x, op.Prc6x, op.Prc10x, op.IDEstoque)
-		if err != nil {
-			tx.Rollback()
-			log.Error().Err(err).Int("id_estoque", op.IDEstoque).Msg("Update failed")
-			return fmt.Errorf("update failed for ID %d: %w", op.IDEstoque, err)
-		}
+	history, err := w.recordPriceHistory(tx, written)
+	if err != nil {
+		tx.Rollback()
+		return 0, 0, err
+	}
+	audited, err := w.recordAudit(tx, written)
+	if err != nil {
+		tx.Rollback()
+		return 0, 0, err
 	}
 
 	if err := tx.Commit(); err != nil {
 		log.Error().Err(err).Int("count", len(ops)).Msg("Bulk update commit failed")
-		return fmt.Errorf("bulk update commit failed: %w", err)
+		return 0, 0, fmt.Errorf("bulk update commit failed: %w", err)
 	}
-
-	log.Debug().Int("count", len(ops)).Msg("Bulk update successful")
-	return nil
+	w.changed.add(written)
+	w.rejected.add(rejectedFrom(ops, written))
+	w.historyCount.Add(int64(history))
+	w.auditCount.Add(int64(audited))
+	w.diff.add(written)
+
+	for _, op := range written {
+		if op.Type == OpInsert {
+			inserted++
+		} else {
+			updated++
+		}
+	}
+	log.Debug().Int("count", len(written)).Bool("upsert", w.upsert).Msg("Bulk update successful")
+	return inserted, updated, nil
 }
 
-// loadMySQLRecords loads existing MySQL records into a map
-func loadMySQLRecords(db *sql.DB) (map[int]mysqlRecord, error) {
-	log := logger.GetLogger()
+// execUpserts writes ops with INSERT ... ON DUPLICATE KEY UPDATE statements,
+// returning the ops written
+func (w *writer) execUpserts(tx *sql.Tx, ops []RowOperation) ([]RowOperation, error) {
+	clause := db.UpsertClause(w.cfg, w.key, w.columnNames())
+	var written []RowOperation
+	for _, chunk := range w.chunkOps(ops) {
+		ok, err := w.isolate(tx, chunk, func(rows []RowOperation) error {
+			query, values := w.multiRowInsert(rows)
+			_, err := tx.Exec(query+clause, values...)
+			return err
+		})
+		if err != nil {
+			log := logger.GetLogger()
+			log.Error().Err(err).Int("count", len(chunk)).Msg("Bulk upsert failed")
+			return nil, fmt.Errorf("bulk upsert failed: %w", err)
+		}
+		written = append(written, ok...)
+	}
+	return written, nil
+}
 
-	var count int
-	err := db.QueryRow("SELECT COUNT(*) FROM TB_ESTOQUE WHERE ID_ESTOQUE IS NOT NULL").Scan(&count)
+// execRowUpdates writes ops with a prepared UPDATE per row, returning the ops written
+func (w *writer) execRowUpdates(tx *sql.Tx, ops []RowOperation) ([]RowOperation, error) {
+	stmt, err := tx.Prepare("UPDATE TB_ESTOQUE SET " + strings.Join(w.columnNames(), " = ?, ") + " = ? WHERE " + w.key + " = ?")
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("error preparing update statement: %w", err)
 	}
+	defer stmt.Close()
 
-	records := make(map[int]mysqlRecord, count)
+	return w.isolate(tx, ops, func(rows []RowOperation) error {
+		for _, op := range rows {
+			if _, err := stmt.Exec(append(w.productValues(op), op.IDEstoque)...); err != nil {
+				log := logger.GetLogger()
+				log.Error().Err(err).Int("id_estoque", op.IDEstoque).Msg("Update failed")
+				return fmt.Errorf("update failed for ID %d: %w", op.IDEstoque, err)
+			}
+		}
+		return nil
+	})
+}
 
-	rows, err := db.Query("SELECT ID_ESTOQUE, DESCRICAO, QTD_ATUAL, PRC_CUSTO, PRC_DOLAR, PRC_VENDA, PRC_3X, PRC_6X, PRC_10X FROM TB_ESTOQUE WHERE ID_ESTOQUE IS NOT NULL")
-	if err != nil {
-		return nil, err
+// multiRowInsert returns a multi-value INSERT of ops and its arguments
+func (w *writer) multiRowInsert(ops []RowOperation) (string, []interface{}) {
+	columns := append([]string{w.key}, w.columnNames()...)
+	values := make([]interface{}, 0, len(ops)*len(columns))
+	for _, op := range ops {
+		values = append(values, op.IDEstoque)
+		values = append(values, w.productValues(op)...)
 	}
-	defer func() {
-		if err := rows.Close(); err != nil {
-			log.Error().Err(err).Msg("Error closing MySQL rows")
-		}
-	}()
+	return transfer.InsertStatement("TB_ESTOQUE", columns, len(ops)), values
+}
 
-	for rows.Next() {
-		var idClipp int
-		var rec mysqlRecord
-		if err := rows.Scan(&idClipp, &rec.Descricao, &rec.Quantidade, &rec.ValorCusto, &rec.ValorUsd, &rec.PrcVenda, &rec.Prc3x, &rec.Prc6x, &rec.Prc10x); err != nil {
-			return nil, err
-		}
-		records[idClipp] = rec
+// columnNames returns the TB_ESTOQUE columns written besides the key
+func (w *writer) columnNames() []string {
+	names := make([]string, len(w.columns))
+	for i, c := range w.columns {
+		names[i] = c.column
 	}
-	return records, rows.Err()
+	return names
 }
 
-// calculatePrices calcula os novos preços baseado nas regras
-func calculatePrices(prcCusto sql.NullFloat64, cfg config.Config) (prcVenda, prc3x, prc6x, prc10x float64) {
-	if !prcCusto.Valid || prcCusto.Float64 == 0 {
+// productValues returns the values of op in columnNames order
+func (w *writer) productValues(op RowOperation) []interface{} {
+	values := make([]interface{}, len(w.columns))
+	for i, c := range w.columns {
+		values[i] = c.value(&op)
+	}
+	return values
+}
+
+// recordPriceHistory writes the price changes of ops when price history is
+// enabled and returns the number of history rows, counted once tx commits
+func (w *writer) recordPriceHistory(tx *sql.Tx, ops []RowOperation) (int, error) {
+	if !w.cfg.PriceHistoryEnabled || w.cfg.QuantityOnly() {
+		return 0, nil
+	}
+
+	n, err := insertPriceHistory(tx, w.runID, ops, time.Now())
+	if err != nil {
+		log := logger.GetLogger()
+		log.Error().Err(err).Int("count", len(ops)).Msg("Price history insert failed")
+		return 0, err
+	}
+	return n, nil
+}
+
+// calculatePrices calcula os novos preços baseado nas regras.
+// All arithmetic is exact and each price is rounded to cents only once.
+func calculatePrices(prcCusto money.NullCents, m config.Margins) (prcVenda, prc3x, prc6x, prc10x money.Cents) {
+	if !prcCusto.Valid || prcCusto.Cents == 0 {
 		return 0, 0, 0, 0
 	}
 
-	custo := prcCusto.Float64
+	custo := prcCusto.Cents
 
 	// PRC_VENDA = PRC_CUSTO * (1 + LUCRO/100)
-	prcVenda = custo * (1 + cfg.Lucro/100)
-	prcVenda = math.Round(prcVenda*100) / 100
+	prcVenda = money.Scale(custo, 1, m.Lucro)
 
 	// PRC_3X = (PRC_CUSTO * (1 + LUCRO/100) * (1 + PARC3X/100)) / 3
-	prc3x = (custo * (1 + cfg.Lucro/100) * (1 + cfg.Parc3x/100)) / 3
-	prc3x = math.Round(prc3x*100) / 100
+	prc3x = money.Scale(custo, 3, m.Lucro, m.Parc3x)
 
 	// PRC_6X = (PRC_CUSTO * (1 + LUCRO/100) * (1 + PARC6X/100)) / 6
-	prc6x = (custo * (1 + cfg.Lucro/100) * (1 + cfg.Parc6x/100)) / 6
-	prc6x = math.Round(prc6x*100) / 100
+	prc6x = money.Scale(custo, 6, m.Lucro, m.Parc6x)
 
 	// PRC_10X = (PRC_CUSTO * (1 + LUCRO/100) * (1 + PARC10X/100)) / 10
-	prc10x = (custo * (1 + cfg.Lucro/100) * (1 + cfg.Parc10x/100)) / 10
-	prc10x = math.Round(prc10x*100) / 100
+	prc10x = money.Scale(custo, 10, m.Lucro, m.Parc10x)
 
 	return prcVenda, prc3x, prc6x, prc10x
 }
 
-func roundFloat(value sql.NullFloat64) float64 {
-	if value.Valid {
-		return math.Round(value.Float64*100) / 100
-	}
-	return 0.0
-}
-
-// runPostProcessing executes DB procedures and updates stats
-func runPostProcessing(db *sql.DB, stats *ProcessingStats, cfg config.Config) error {
+// runPostProcessing executes DB procedures and updates stats.
+// With CHANGED_IDS_PROCEDURE set, the virtual quantity is recomputed only for
+// the changed keys instead of calling UpdateQtdVirtual for the whole table.
+func runPostProcessing(db *sql.DB, stats *ProcessingStats, cfg config.Config, changed []int) error {
 	log := logger.GetLogger()
 
 	// Skip stored procedures in dev mode - SQLite doesn't support them
@@ -437,22 +1081,34 @@ func runPostProcessing(db *sql.DB, stats *ProcessingStats, cfg config.Config) er
 		return nil
 	}
 
-	startProc := time.Now()
-	_, err := db.Exec("CALL UpdateQtdVirtual()")
-	if err != nil {
-		log.Error().Err(err).Msg("Error calling UpdateQtdVirtual procedure")
-		return fmt.Errorf("error calling UpdateQtdVirtual procedure: %w", err)
+	var procedures stageTimer
+	procedures.start()
+	defer func() {
+		procedures.stop()
+		stats.ProcedureTime += procedures.total
+	}()
+
+	if cfg.ChangedIDsProcedure != "" {
+		calls, err := callChangedIDsProcedure(db, cfg, changed)
+		stats.ProcedureBatches = calls
+		if err != nil {
+			return err
+		}
+		log.Debug().Int("ids", len(changed)).Int("calls", calls).Msg("Changed IDs procedure executed successfully")
+	} else {
+		_, err := db.Exec("CALL UpdateQtdVirtual()")
+		if err != nil {
+			log.Error().Err(err).Msg("Error calling UpdateQtdVirtual procedure")
+			return fmt.Errorf("error calling UpdateQtdVirtual procedure: %w", err)
+		}
+		log.Debug().Msg("UpdateQtdVirtual procedure executed successfully")
 	}
-	stats.ProcedureTime += time.Since(startProc)
-	log.Debug().Msg("UpdateQtdVirtual procedure executed successfully")
 
-	startProc = time.Now()
-	_, err = db.Exec("CALL SP_ATUALIZAR_PART_NUMBER()")
+	_, err := db.Exec("CALL SP_ATUALIZAR_PART_NUMBER()")
 	if err != nil {
 		log.Error().Err(err).Msg("Error calling SP_ATUALIZAR_PART_NUMBER procedure")
 		return fmt.Errorf("error calling SP_ATUALIZAR_PART_NUMBER procedure: %w", err)
 	}
-	stats.ProcedureTime += time.Since(startProc)
 	log.Debug().Msg("SP_ATUALIZAR_PART_NUMBER procedure executed successfully")
 	return nil
 }
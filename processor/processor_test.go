@@ -0,0 +1,90 @@
+package processor
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/waldirborbajr/sync/transfer"
+)
+
+func TestWorkerCountsFailedBatches(t *testing.T) {
+	cfg, _, mysqlDB := devDatabases(t, map[string]string{"INCREMENTAL_COLUMN": "DT_ALTERACAO", "BATCH_RETRIES": "0"})
+	// MySQL refuses key 7: the first insert batch fails, the next ones go through
+	execAll(t, mysqlDB,
+		"INSERT INTO TB_ESTOQUE (ID_ESTOQUE, DESCRICAO) VALUES (701, 'a'), (702, 'b'), (703, 'c')",
+		"CREATE TRIGGER refuse BEFORE INSERT ON TB_ESTOQUE WHEN NEW.ID_ESTOQUE = 7 BEGIN SELECT RAISE(ABORT, 'refused'); END",
+	)
+
+	// Three updates pending when the first insert batch fills up, then 200
+	// inserts left for the final flush
+	chunk := []RowOperation{
+		{Type: OpUpdate, IDEstoque: 701, Descricao: "A"},
+		{Type: OpUpdate, IDEstoque: 702, Descricao: "B"},
+		{Type: OpUpdate, IDEstoque: 703, Descricao: "C"},
+	}
+	for id := 1; id <= 700; id++ {
+		chunk = append(chunk, RowOperation{Type: OpInsert, IDEstoque: id, Descricao: "new"})
+	}
+	work := make(chan []RowOperation, 1)
+	work <- chunk
+	close(work)
+
+	w := &writer{db: mysqlDB, cfg: cfg, key: "ID_ESTOQUE", columns: productColumns(cfg), retry: transfer.NewRetrier(cfg)}
+	var inserted, updated, ignored atomic.Int64
+	var wg sync.WaitGroup
+	wg.Add(1)
+	worker(context.Background(), 0, work, w, &inserted, &updated, &ignored, &wg)
+
+	if got := w.unwritten.Load(); got != 500 {
+		t.Errorf("unwritten = %d; want the 500 rows of the failed batch", got)
+	}
+	if inserted.Load() != 200 || updated.Load() != 3 {
+		t.Errorf("inserted, updated = %d, %d; want 200, 3: the failed batch is not sent again and the updates are written", inserted.Load(), updated.Load())
+	}
+	if n := countRows(t, mysqlDB, "SELECT COUNT(*) FROM TB_ESTOQUE WHERE ID_ESTOQUE <= 500"); n != 0 {
+		t.Errorf("%d rows of the failed batch written", n)
+	}
+	if n := countRows(t, mysqlDB, "SELECT COUNT(*) FROM TB_ESTOQUE WHERE DESCRICAO IN ('A', 'B', 'C')"); n != 3 {
+		t.Errorf("%d of the 3 updates written", n)
+	}
+
+	// The run is partial: the watermark stays for the next run to read the rows again
+	before := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	if err := saveWatermark(cfg, before); err != nil {
+		t.Fatal(err)
+	}
+	stats := &ProcessingStats{UnwrittenRows: int(w.unwritten.Load()), Watermark: before.Add(time.Hour)}
+	if err := advanceWatermark(cfg, stats); err != nil {
+		t.Fatalf("advanceWatermark() error = %v", err)
+	}
+	if since, _ := loadWatermark(cfg); !since.Equal(before.Add(-cfg.IncrementalOverlap)) {
+		t.Errorf("watermark = %v; want %v kept", since, before)
+	}
+}
+
+func TestWorkerCountsChunksOfCancelledRun(t *testing.T) {
+	cfg, _, mysqlDB := devDatabases(t, nil)
+
+	work := make(chan []RowOperation, 2)
+	work <- []RowOperation{{Type: OpInsert, IDEstoque: 1, Descricao: "a"}, {Type: OpInsert, IDEstoque: 2, Descricao: "b"}}
+	work <- []RowOperation{{Type: OpInsert, IDEstoque: 3, Descricao: "c"}}
+	close(work)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w := &writer{db: mysqlDB, cfg: cfg, key: "ID_ESTOQUE", columns: productColumns(cfg), retry: transfer.NewRetrier(cfg)}
+	var inserted, updated, ignored atomic.Int64
+	var wg sync.WaitGroup
+	wg.Add(1)
+	worker(ctx, 0, work, w, &inserted, &updated, &ignored, &wg)
+
+	if got := w.unwritten.Load(); got != 3 {
+		t.Errorf("unwritten = %d; want the 3 rows of the chunks dropped", got)
+	}
+	if n := countRows(t, mysqlDB, "SELECT COUNT(*) FROM TB_ESTOQUE"); n != 0 {
+		t.Errorf("%d rows written by a cancelled run", n)
+	}
+}
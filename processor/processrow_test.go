@@ -0,0 +1,278 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/money"
+)
+
+// rowConfig parses values on top of the defaults; rules, when set, is
+// written as the PRICING_RULES_FILE
+func rowConfig(t *testing.T, values map[string]string, rules string) config.Config {
+	t.Helper()
+	settings := map[string]string{}
+	for k, v := range values {
+		settings[k] = v
+	}
+	if rules != "" {
+		path := filepath.Join(t.TempDir(), "pricing.rules")
+		if err := os.WriteFile(path, []byte(rules), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		settings["PRICING_RULES_FILE"] = path
+	}
+	cfg, err := config.Parse(settings)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	return cfg
+}
+
+// product returns a Firebird row of group 1 costing cost cents
+func product(id int, cost money.Cents, qty float64) sourceRow {
+	return sourceRow{
+		IDEstoque: id,
+		IDGrupo:   1,
+		Descricao: "product",
+		QtdAtual:  qty,
+		PrcCusto:  money.NullCents{Cents: cost, Valid: true},
+		Status:    "A",
+		HasGrupo:  true,
+		HasStatus: true,
+	}
+}
+
+// stored returns a MySQL row selling at venda cents
+func stored(venda money.Cents) mysqlRecord {
+	return mysqlRecord{PrcVenda: money.NullCents{Cents: venda, Valid: true}}
+}
+
+// rowLookups returns the lookups of cfg with the stored rows
+func rowLookups(cfg config.Config, existing map[int]mysqlRecord) *lookups {
+	if existing == nil {
+		existing = map[int]mysqlRecord{}
+	}
+	return &lookups{
+		columns:   productColumns(cfg),
+		existing:  existing,
+		protected: map[int]struct{}{},
+		reserved:  map[int]float64{},
+	}
+}
+
+func TestProcessRowPriceConstraints(t *testing.T) {
+	tests := []struct {
+		name       string
+		values     map[string]string
+		rules      string
+		src        sourceRow
+		existing   map[int]mysqlRecord
+		venda, p3x money.Cents
+		violations constraintViolation
+	}{
+		{
+			name:   "within the minimum margin",
+			values: map[string]string{"LUCRO": "40", "PARC3X": "5", "PRICE_MIN_MARGIN": "30"},
+			src:    product(1, 10000, 5),
+			venda:  14000, p3x: 4900,
+		},
+		{
+			name:   "raised to the minimum margin",
+			values: map[string]string{"LUCRO": "10", "PARC3X": "5", "PRICE_MIN_MARGIN": "30"},
+			src:    product(1, 10000, 5),
+			venda:  13000, p3x: 4550, violations: violationMinMargin,
+		},
+		{
+			name:   "flagged, not raised",
+			values: map[string]string{"LUCRO": "10", "PARC3X": "5", "PRICE_MIN_MARGIN": "30", "PRICE_CONSTRAINT_POLICY": "flag"},
+			src:    product(1, 10000, 5),
+			venda:  11000, p3x: 3850, violations: violationMinMargin,
+		},
+		{
+			name:   "raised to the group floor",
+			values: map[string]string{"LUCRO": "10", "PARC3X": "5", "PRICE_MIN_MARGIN": "30", "PRICE_FLOORS": "1:150.00"},
+			src:    product(1, 10000, 5),
+			venda:  15000, p3x: 5250, violations: violationMinMargin | violationFloor,
+		},
+		{
+			name:     "raised to the highest minimum",
+			values:   map[string]string{"LUCRO": "10", "PARC3X": "5", "PRICE_MIN_MARGIN": "30", "PRICE_MAX_DROP": "10"},
+			src:      product(1, 10000, 5),
+			existing: map[int]mysqlRecord{1: stored(20000)},
+			venda:    18000, p3x: 6300, violations: violationMinMargin | violationMaxDrop,
+		},
+		{
+			name:   "installments clamped with the margins of the pricing rule",
+			values: map[string]string{"LUCRO": "10", "PARC3X": "5", "PRICE_MIN_MARGIN": "30"},
+			rules:  "group1: ID_GRUPO = 1 => PARC3X=20\n",
+			src:    product(1, 10000, 5),
+			venda:  13000, p3x: 5200, violations: violationMinMargin,
+		},
+		{
+			name:   "installments clamped with the global margins",
+			values: map[string]string{"LUCRO": "10", "PARC3X": "5", "PRICE_MIN_MARGIN": "30"},
+			rules:  "group7: ID_GRUPO = 7 => PARC3X=20\n",
+			src:    product(1, 10000, 5),
+			venda:  13000, p3x: 4550, violations: violationMinMargin,
+		},
+	}
+	for _, tt := range tests {
+		cfg := rowConfig(t, tt.values, tt.rules)
+		op := processRowOptimized(rowLookups(cfg, tt.existing), tt.src, cfg)
+		if op.PrcVenda != tt.venda || op.Prc3x != tt.p3x {
+			t.Errorf("%s: PRC_VENDA, PRC_3X = %v, %v; want %v, %v", tt.name, op.PrcVenda, op.Prc3x, tt.venda, tt.p3x)
+		}
+		if op.violations != tt.violations {
+			t.Errorf("%s: violations = %b; want %b", tt.name, op.violations, tt.violations)
+		}
+	}
+}
+
+func TestProcessRowProtectedPrices(t *testing.T) {
+	cfg := rowConfig(t, map[string]string{"LUCRO": "10", "PARC3X": "5", "PRICE_MIN_MARGIN": "30"}, "")
+	tests := []struct {
+		name       string
+		protected  bool
+		existing   map[int]mysqlRecord
+		venda      money.Cents
+		violations constraintViolation
+		kept       bool
+	}{
+		{"protected row keeps its price, unconstrained", true, map[int]mysqlRecord{1: stored(9000)}, 9000, 0, true},
+		{"new protected key is constrained", true, nil, 13000, violationMinMargin, false},
+		{"unprotected row is constrained", false, map[int]mysqlRecord{1: stored(9000)}, 13000, violationMinMargin, false},
+	}
+	for _, tt := range tests {
+		lk := rowLookups(cfg, tt.existing)
+		if tt.protected {
+			lk.protected[1] = struct{}{}
+		}
+		op := processRowOptimized(lk, product(1, 10000, 5), cfg)
+		if op.PrcVenda != tt.venda || op.violations != tt.violations || op.priceProtected != tt.kept {
+			t.Errorf("%s: PRC_VENDA %v, violations %b, protected %v; want %v, %b, %v",
+				tt.name, op.PrcVenda, op.violations, op.priceProtected, tt.venda, tt.violations, tt.kept)
+		}
+	}
+}
+
+func TestProcessRowReservations(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   string
+		qty      float64
+		reserved float64
+		want     float64
+		visible  int
+	}{
+		{"in stock after the reservations", "asis", 10, 3, 7, 1},
+		{"reservations taking stock negative", "asis", 10, 12, -2, 1},
+		{"negative stock zeroed", "zero", 10, 12, 0, 1},
+		{"negative stock hidden", "hide", 10, 12, 0, 0},
+		{"reserved stock hidden", "hide", 10, 10, 0, 0},
+	}
+	for _, tt := range tests {
+		cfg := rowConfig(t, map[string]string{"STOCK_POLICY": tt.policy}, "")
+		lk := rowLookups(cfg, nil)
+		lk.reserved[1] = tt.reserved
+		op := processRowOptimized(lk, product(1, 10000, tt.qty), cfg)
+		if op.QtdAtual != tt.want || op.Visivel != tt.visible {
+			t.Errorf("%s: QTD_ATUAL %v, VISIVEL %d; want %v, %d", tt.name, op.QtdAtual, op.Visivel, tt.want, tt.visible)
+		}
+		if op.QtdOrigem != tt.qty || op.QtdReservada != tt.reserved {
+			t.Errorf("%s: source and reserved quantities = %v, %v; want %v, %v", tt.name, op.QtdOrigem, op.QtdReservada, tt.qty, tt.reserved)
+		}
+	}
+}
+
+func TestProcessRowStockPolicy(t *testing.T) {
+	tests := []struct {
+		policy  string
+		qty     float64
+		want    float64
+		visible int
+		applied bool
+		typ     OperationType
+	}{
+		{"asis", -2, -2, 1, false, OpInsert},
+		{"zero", -2, 0, 1, true, OpInsert},
+		{"zero", 0, 0, 1, true, OpInsert},
+		{"hide", -2, 0, 0, true, OpInsert},
+		{"hide", 5, 5, 1, false, OpInsert},
+		{"skip", 0, 0, 1, true, OpIgnore},
+		{"skip", 5, 5, 1, false, OpInsert},
+	}
+	for _, tt := range tests {
+		cfg := rowConfig(t, map[string]string{"STOCK_POLICY": tt.policy}, "")
+		op := processRowOptimized(rowLookups(cfg, nil), product(1, 10000, tt.qty), cfg)
+		if op.QtdAtual != tt.want || op.Visivel != tt.visible || op.stockPolicy != tt.applied || op.Type != tt.typ {
+			t.Errorf("%s with %v: QTD_ATUAL %v, VISIVEL %d, applied %v, type %v; want %v, %d, %v, %v",
+				tt.policy, tt.qty, op.QtdAtual, op.Visivel, op.stockPolicy, op.Type, tt.want, tt.visible, tt.applied, tt.typ)
+		}
+	}
+}
+
+func TestProcessRowStatusMap(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   string
+		policy   string
+		want     string
+		typ      OperationType
+		unmapped bool
+	}{
+		{"mapped status", "A", "asis", "ATIVO", OpInsert, false},
+		{"unmapped status", "X", "asis", "", OpIgnore, true},
+		{"unmapped status ahead of the skip policy", "X", "skip", "", OpIgnore, true},
+	}
+	for _, tt := range tests {
+		cfg := rowConfig(t, map[string]string{"STATUS_MAP": "A:ATIVO,I:INATIVO", "STOCK_POLICY": tt.policy}, "")
+		src := product(1, 10000, 0)
+		src.Status = tt.status
+		op := processRowOptimized(rowLookups(cfg, nil), src, cfg)
+		if op.Status != tt.want || op.Type != tt.typ || op.statusUnmapped != tt.unmapped {
+			t.Errorf("%s: status %q, type %v, unmapped %v; want %q, %v, %v", tt.name, op.Status, op.Type, op.statusUnmapped, tt.want, tt.typ, tt.unmapped)
+		}
+	}
+}
+
+// The rules apply in order: reservations, stock policy, status map, then
+// protected prices or price constraints
+func TestProcessRowRulesCombined(t *testing.T) {
+	cfg := rowConfig(t, map[string]string{
+		"LUCRO": "10", "PARC3X": "5", "PRICE_MIN_MARGIN": "30",
+		"STOCK_POLICY": "hide", "STATUS_MAP": "A:ATIVO",
+	}, "")
+	tests := []struct {
+		name       string
+		protected  bool
+		venda      money.Cents
+		violations constraintViolation
+	}{
+		{"protected", true, 9000, 0},
+		{"unprotected", false, 13000, violationMinMargin},
+	}
+	for _, tt := range tests {
+		lk := rowLookups(cfg, map[int]mysqlRecord{1: stored(9000)})
+		lk.reserved[1] = 12
+		if tt.protected {
+			lk.protected[1] = struct{}{}
+		}
+		op := processRowOptimized(lk, product(1, 10000, 10), cfg)
+		if op.Type != OpUpdate {
+			t.Fatalf("%s: type = %v; want an update", tt.name, op.Type)
+		}
+		if op.QtdAtual != 0 || op.Visivel != 0 || !op.stockPolicy {
+			t.Errorf("%s: QTD_ATUAL %v, VISIVEL %d; want the reserved stock hidden", tt.name, op.QtdAtual, op.Visivel)
+		}
+		if op.Status != "ATIVO" {
+			t.Errorf("%s: status = %q; want ATIVO", tt.name, op.Status)
+		}
+		if op.PrcVenda != tt.venda || op.violations != tt.violations || op.priceProtected != tt.protected {
+			t.Errorf("%s: PRC_VENDA %v, violations %b, protected %v; want %v, %b, %v",
+				tt.name, op.PrcVenda, op.violations, op.priceProtected, tt.venda, tt.violations, tt.protected)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package processor
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/waldirborbajr/sync/logger"
+)
+
+// loadProtectedKeys runs the configured protected rows query against MySQL.
+// The query must return ID_ESTOQUE values in its first column, e.g. the
+// products currently on promotion. An empty query protects nothing.
+func loadProtectedKeys(ctx context.Context, db *sql.DB, query string) (map[int]struct{}, error) {
+	keys := make(map[int]struct{})
+	if strings.TrimSpace(query) == "" {
+		return keys, nil
+	}
+
+	log := logger.GetLogger()
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error running PROTECTED_ROWS_QUERY: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id sql.NullInt64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("error scanning PROTECTED_ROWS_QUERY result (expected a single ID_ESTOQUE column): %w", err)
+		}
+		if id.Valid {
+			keys[int(id.Int64)] = struct{}{}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading PROTECTED_ROWS_QUERY result: %w", err)
+	}
+
+	log.Info().Int("protected", len(keys)).Msg("Protected rows loaded")
+	return keys, nil
+}
+
+// protectPrices keeps the current MySQL sale prices of op so promotions are
+// not overwritten. Cost, dollar value, quantity and description still sync.
+func protectPrices(op *RowOperation) {
+	rec := op.existing
+	venda, p3x, p6x, p10x := rec.PrcVenda.OrZero(), rec.Prc3x.OrZero(), rec.Prc6x.OrZero(), rec.Prc10x.OrZero()
+
+	op.priceProtected = op.PrcVenda != venda || op.Prc3x != p3x || op.Prc6x != p6x || op.Prc10x != p10x
+	op.PrcVenda, op.Prc3x, op.Prc6x, op.Prc10x = venda, p3x, p6x, p10x
+}
@@ -0,0 +1,48 @@
+package processor
+
+import "github.com/waldirborbajr/sync/config"
+
+// processQuantityRow determines what operation to perform on a row in
+// quantity-only mode (SYNC_MODE=quantity). Prices are not calculated and only
+// QTD_ATUAL (and the STOCK_POLICY=hide visibility flag) is compared, so the
+// run stays cheap enough to be scheduled every few minutes. Products missing
+// from MySQL are left to the next full run, which writes their prices too.
+func processQuantityRow(lk *lookups, src sourceRow, cfg config.Config) RowOperation {
+	op := RowOperation{
+		Type:      OpIgnore,
+		IDEstoque: src.IDEstoque,
+		IDGrupo:   src.IDGrupo,
+		Descricao: src.Descricao,
+		QtdAtual:  src.QtdAtual,
+	}
+
+	if r, ok := lk.reserved[src.IDEstoque]; ok {
+		applyReservation(&op, r)
+	}
+
+	op.stockPolicy = applyStockPolicy(&op, cfg)
+
+	// Rows the full run would not write are not written here either
+	if mapsStatus(cfg) {
+		if _, ok := cfg.StatusMap[src.Status]; !ok {
+			op.statusUnmapped = true
+			return op
+		}
+	}
+
+	var exists bool
+	if op.existing, exists = lk.find(src.IDEstoque); !exists {
+		op.deferred = !op.stockSkipped
+		return op
+	}
+
+	if op.stockSkipped {
+		return op
+	}
+
+	op.exprFailed = !applyTransforms(&op, src, cfg)
+	if lk.changed(&op) {
+		op.Type = OpUpdate
+	}
+	return op
+}
@@ -0,0 +1,104 @@
+package processor
+
+import (
+	"strings"
+	"time"
+
+	"github.com/waldirborbajr/sync/config"
+)
+
+// filterExprs are the source query expressions of the ROW_FILTERS columns
+var filterExprs = map[string]string{
+	"ID_ESTOQUE": "e.ID_ESTOQUE",
+	"ID_GRUPO":   "e.ID_GRUPO",
+	"QTD_ATUAL":  "p.QTD_ATUAL",
+	"PRC_CUSTO":  "e.PRC_CUSTO",
+	"PRC_DOLAR":  "i.VALOR",
+	"STATUS":     "e.STATUS",
+	"DESCRICAO":  "e.DESCRICAO",
+}
+
+// buildSourceQuery returns the Firebird product query for the configuration
+// and its arguments. Without a status map only active products (STATUS = 'A')
+// are read, unless a ROW_FILTERS rule selects statuses itself; with a status
+// map every product is read and its status is translated instead. With
+// ROW_FILTER_MODE=sql the ROW_FILTERS rules are conditions of the query. In incremental mode the modification column is selected
+// and, when since is set, only rows modified after it are read. The
+// PRICING_SUPPLIER_COLUMN is selected when a pricing rule tests it. With keys
+// only that range of ID_ESTOQUE is read.
+func buildSourceQuery(cfg config.Config, since time.Time, keys *keyRange) (string, []interface{}) {
+	query := `
+        SELECT 
+            e.ID_ESTOQUE, 
+            e.DESCRICAO, 
+            p.QTD_ATUAL, 
+            e.PRC_CUSTO, 
+            i.VALOR AS PRC_DOLAR,
+            e.ID_GRUPO,
+            e.STATUS`
+	if incremental(cfg) {
+		query += `,
+            e.` + cfg.IncrementalColumn
+	}
+	if cfg.UsesSupplier() {
+		query += `,
+            e.` + cfg.PricingSupplierColumn
+	}
+	query += `
+        FROM TB_ESTOQUE e
+        JOIN TB_EST_PRODUTO p 
+            ON e.ID_ESTOQUE = p.ID_IDENTIFICADOR
+        LEFT JOIN TB_EST_INDEXADOR i 
+            ON i.ID_ESTOQUE = e.ID_ESTOQUE
+    `
+
+	var conditions []string
+	var args []interface{}
+	if !mapsStatus(cfg) && !cfg.FiltersStatus() {
+		conditions = append(conditions, "e.STATUS = 'A'")
+	}
+	if cfg.RowFilterMode == config.FilterSQL {
+		for _, f := range cfg.RowFilters {
+			cond, fargs := filterCondition(f)
+			conditions = append(conditions, cond)
+			args = append(args, fargs...)
+		}
+	}
+	if incremental(cfg) && !since.IsZero() {
+		conditions = append(conditions, "e."+cfg.IncrementalColumn+" > ?")
+		args = append(args, since)
+	}
+	if keys != nil {
+		conditions = append(conditions, "e.ID_ESTOQUE BETWEEN ? AND ?")
+		args = append(args, keys.first, keys.last)
+	}
+	if len(conditions) > 0 {
+		query += "WHERE " + strings.Join(conditions, " AND ") + "\n"
+	}
+	return query, args
+}
+
+// mapsStatus reports whether Firebird statuses are translated into a MySQL column
+func mapsStatus(cfg config.Config) bool {
+	return len(cfg.StatusMap) > 0
+}
+
+// filterCondition returns the WHERE condition of a ROW_FILTERS rule and its arguments
+func filterCondition(f config.RowFilter) (string, []interface{}) {
+	args := make([]interface{}, len(f.Values))
+	for i, v := range f.Values {
+		args[i] = v
+		if f.Numeric() {
+			args[i] = f.Numbers[i]
+		}
+	}
+	column := filterExprs[f.Column]
+	if f.Op == "IN" || f.Op == "NOT IN" {
+		return column + " " + f.Op + " (?" + strings.Repeat(", ?", len(args)-1) + ")", args
+	}
+	op := f.Op
+	if op == "!=" {
+		op = "<>"
+	}
+	return column + " " + op + " ?", args
+}
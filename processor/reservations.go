@@ -0,0 +1,63 @@
+package processor
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/waldirborbajr/sync/logger"
+)
+
+// loadReservations runs the configured reservations query against MySQL.
+// The query must return ID_ESTOQUE and the reserved quantity, e.g.
+// SELECT ID_ESTOQUE, SUM(QTD) FROM TB_RESERVA GROUP BY ID_ESTOQUE.
+// An empty query disables the adjustment.
+func loadReservations(ctx context.Context, db *sql.DB, query string) (map[int]float64, error) {
+	reserved := make(map[int]float64)
+	if strings.TrimSpace(query) == "" {
+		return reserved, nil
+	}
+
+	log := logger.GetLogger()
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error running RESERVATIONS_QUERY: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id sql.NullInt64
+		var qty sql.NullFloat64
+		if err := rows.Scan(&id, &qty); err != nil {
+			return nil, fmt.Errorf("error scanning RESERVATIONS_QUERY result (expected ID_ESTOQUE and quantity columns): %w", err)
+		}
+		if id.Valid && qty.Valid && qty.Float64 != 0 {
+			reserved[int(id.Int64)] += qty.Float64
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading RESERVATIONS_QUERY result: %w", err)
+	}
+
+	log.Info().Int("products", len(reserved)).Msg("Stock reservations loaded")
+	return reserved, nil
+}
+
+// applyReservation subtracts the reserved quantity from the quantity to be
+// written, keeping the source and reserved quantities on the operation so the
+// calculation stays auditable; with AUDIT_ENABLED the audit rows record them.
+func applyReservation(op *RowOperation, reserved float64) {
+	op.QtdOrigem = op.QtdAtual
+	op.QtdReservada = reserved
+	op.QtdAtual -= reserved
+
+	log := logger.GetLogger()
+	log.Debug().
+		Int("id_estoque", op.IDEstoque).
+		Float64("qtd_origem", op.QtdOrigem).
+		Float64("qtd_reservada", op.QtdReservada).
+		Float64("qtd_atual", op.QtdAtual).
+		Msg("Quantity adjusted for stock reservations")
+}
@@ -0,0 +1,33 @@
+package processor
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/waldirborbajr/sync/compare"
+	"github.com/waldirborbajr/sync/transfer"
+)
+
+// reportRetries copies the retry and error counts of r into stats
+func reportRetries(r *transfer.Retrier, stats *ProcessingStats) {
+	stats.BatchRetries, stats.BatchRetriesExhausted, stats.Errors = r.Counts()
+}
+
+// sortByKey orders ops by ID_ESTOQUE. Writers touching rows in one consistent
+// order cannot deadlock each other; MySQL then only has to retry conflicts with
+// other applications (see transfer.Retrier).
+func sortByKey(ops []RowOperation) {
+	slices.SortFunc(ops, func(a, b RowOperation) int { return cmp.Compare(a.IDEstoque, b.IDEstoque) })
+}
+
+// sortRowsByKey orders table rows by their key columns in turn, like sortByKey
+func sortRowsByKey(rows [][]interface{}, keyIdx []int) {
+	slices.SortFunc(rows, func(a, b []interface{}) int {
+		for _, idx := range keyIdx {
+			if c := compare.Order(a[idx], b[idx]); c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+}
@@ -0,0 +1,274 @@
+package processor
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/db"
+	"github.com/waldirborbajr/sync/logger"
+	"github.com/waldirborbajr/sync/run"
+	"github.com/waldirborbajr/sync/transfer"
+)
+
+// reverseBatchSize is the number of Firebird rows updated per transaction by reverse sync
+const reverseBatchSize = 500
+
+// ReverseStats reports the MySQL-managed columns pushed back into Firebird (REVERSE_SYNC_COLUMNS)
+type ReverseStats struct {
+	Rows        int   // MySQL rows compared
+	Pushed      int   // Firebird rows updated, overwritten conflicts included
+	Unchanged   int   // Rows holding the same values on both sides
+	Conflicts   []int // Keys whose Firebird values changed since they were last pushed while MySQL differs
+	Overwritten int   // Conflicts pushed anyway (REVERSE_SYNC_CONFLICT=mysql)
+	Missing     int   // MySQL rows whose key is not in Firebird
+	Duration    time.Duration
+}
+
+// reversePush is a Firebird row to update with the MySQL values
+type reversePush struct {
+	key    int
+	values []interface{}
+	hash   string
+}
+
+// reverseWriter batches the Firebird updates of reverse sync and records
+// the values pushed in TB_SYNC_REVERSO
+type reverseWriter struct {
+	firebirdDB *sql.DB
+	mysqlDB    *sql.DB
+	cfg        config.Config
+	retry      *transfer.Retrier
+	pushes     []reversePush
+	agreed     []reversePush // Rows already equal on both sides whose TB_SYNC_REVERSO hash is outdated
+	stats      *ReverseStats
+}
+
+// syncReverse pushes the REVERSE_SYNC_COLUMNS of MySQL TB_ESTOQUE into
+// Firebird. Each row is compared through a hash of its values with the hash
+// last pushed, kept in TB_SYNC_REVERSO: rows where only MySQL changed are
+// pushed, rows where Firebird changed too are conflicts settled by
+// REVERSE_SYNC_CONFLICT. Rows never pushed take the MySQL values.
+func syncReverse(ctx context.Context, firebirdDB, mysqlDB *sql.DB, cfg config.Config, retry *transfer.Retrier) (*ReverseStats, error) {
+	log := logger.GetLogger()
+	start := time.Now()
+	rs := &ReverseStats{}
+
+	pushed, err := loadReverseHashes(ctx, mysqlDB)
+	if err != nil {
+		return nil, fmt.Errorf("error loading TB_SYNC_REVERSO: %w", err)
+	}
+	firebird, err := loadFirebirdReverse(ctx, firebirdDB, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error loading Firebird TB_ESTOQUE: %w", err)
+	}
+	run.Touch(ctx)
+
+	sources := make([]string, len(cfg.ReverseColumns))
+	for i, c := range cfg.ReverseColumns {
+		sources[i] = c.Source
+	}
+	key := productKeyColumn(cfg)
+	rows, err := mysqlDB.QueryContext(ctx, "SELECT "+key+", "+strings.Join(sources, ", ")+" FROM TB_ESTOQUE WHERE "+key+" IS NOT NULL")
+	if err != nil {
+		return nil, fmt.Errorf("error querying MySQL TB_ESTOQUE: %w", err)
+	}
+	defer rows.Close()
+
+	w := &reverseWriter{firebirdDB: firebirdDB, mysqlDB: mysqlDB, cfg: cfg, retry: retry, stats: rs}
+	for rows.Next() {
+		var id int
+		values := make([]interface{}, len(sources))
+		dest := make([]interface{}, 0, len(sources)+1)
+		dest = append(dest, &id)
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("error scanning MySQL row: %w", err)
+		}
+		for i := range values {
+			values[i] = normalizeValue(values[i])
+		}
+		rs.Rows++
+
+		current, ok := firebird[id]
+		if !ok {
+			rs.Missing++
+			continue
+		}
+		mysqlHash, firebirdHash := reverseHash(values), reverseHash(current)
+		last, wasPushed := pushed[id]
+		switch {
+		case mysqlHash == firebirdHash:
+			rs.Unchanged++
+			if last != mysqlHash {
+				w.agreed = append(w.agreed, reversePush{key: id, hash: mysqlHash})
+			}
+			continue
+		case wasPushed && firebirdHash != last:
+			rs.Conflicts = append(rs.Conflicts, id)
+			if cfg.ReverseConflict != config.ReverseConflictMySQL {
+				log.Warn().Int("id_estoque", id).Msg("Reverse sync conflict: Firebird values changed since they were pushed, row left alone")
+				continue
+			}
+			rs.Overwritten++
+		}
+		w.pushes = append(w.pushes, reversePush{key: id, values: values, hash: mysqlHash})
+
+		if len(w.pushes) >= reverseBatchSize || len(w.agreed) >= reverseBatchSize {
+			if err := w.flush(ctx); err != nil {
+				return nil, err
+			}
+		}
+		run.Touch(ctx)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := w.flush(ctx); err != nil {
+		return nil, err
+	}
+
+	rs.Duration = time.Since(start)
+	log.Info().
+		Int("rows", rs.Rows).
+		Int("pushed", rs.Pushed).
+		Int("conflicts", len(rs.Conflicts)).
+		Int("overwritten", rs.Overwritten).
+		Int("missing", rs.Missing).
+		Dur("duration", rs.Duration).
+		Msg("Columns pushed back into Firebird")
+	return rs, nil
+}
+
+// loadReverseHashes returns the hash of the values last pushed per key
+func loadReverseHashes(ctx context.Context, mysqlDB *sql.DB) (map[int]string, error) {
+	rows, err := mysqlDB.QueryContext(ctx, "SELECT ID_ESTOQUE, HASH FROM TB_SYNC_REVERSO")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hashes := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var hash string
+		if err := rows.Scan(&id, &hash); err != nil {
+			return nil, err
+		}
+		hashes[id] = hash
+	}
+	return hashes, rows.Err()
+}
+
+// loadFirebirdReverse returns the current Firebird values of the reverse columns per key
+func loadFirebirdReverse(ctx context.Context, firebirdDB *sql.DB, cfg config.Config) (map[int][]interface{}, error) {
+	targets := make([]string, len(cfg.ReverseColumns))
+	for i, c := range cfg.ReverseColumns {
+		targets[i] = c.Target
+	}
+	rows, err := firebirdDB.QueryContext(ctx, "SELECT ID_ESTOQUE, "+strings.Join(targets, ", ")+" FROM TB_ESTOQUE")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	current := make(map[int][]interface{})
+	for rows.Next() {
+		var id int
+		values := make([]interface{}, len(targets))
+		dest := make([]interface{}, 0, len(targets)+1)
+		dest = append(dest, &id)
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		for i := range values {
+			values[i] = normalizeValue(values[i])
+		}
+		current[id] = values
+	}
+	return current, rows.Err()
+}
+
+// reverseHash returns the hash stored in TB_SYNC_REVERSO for values
+func reverseHash(values []interface{}) string {
+	return fmt.Sprintf("%016x", hashValues(values))
+}
+
+// flush updates the pending Firebird rows in one transaction, then records
+// their hashes; a failure between the two leaves rows equal on both sides,
+// which the next run records as agreed
+func (w *reverseWriter) flush(ctx context.Context) error {
+	if len(w.pushes) > 0 {
+		err := w.retry.Do(ctx, "Firebird TB_ESTOQUE reverse update", len(w.pushes), func() error { return w.update(ctx) })
+		if err != nil {
+			return err
+		}
+		w.stats.Pushed += len(w.pushes)
+		run.Touch(ctx)
+	}
+
+	recorded := slices.Concat(w.pushes, w.agreed)
+	if len(recorded) > 0 {
+		err := w.retry.Do(ctx, "TB_SYNC_REVERSO upsert", len(recorded), func() error { return w.record(ctx, recorded) })
+		if err != nil {
+			return err
+		}
+	}
+	w.pushes, w.agreed = w.pushes[:0], w.agreed[:0]
+	return nil
+}
+
+// update writes the MySQL values of the pending rows into Firebird with a prepared statement
+func (w *reverseWriter) update(ctx context.Context) error {
+	set := make([]string, len(w.cfg.ReverseColumns))
+	for i, c := range w.cfg.ReverseColumns {
+		set[i] = c.Target + " = ?"
+	}
+
+	tx, err := w.firebirdDB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting Firebird transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, "UPDATE TB_ESTOQUE SET "+strings.Join(set, ", ")+" WHERE ID_ESTOQUE = ?")
+	if err != nil {
+		return fmt.Errorf("error preparing Firebird update statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, p := range w.pushes {
+		if _, err := stmt.ExecContext(ctx, append(p.values, p.key)...); err != nil {
+			return fmt.Errorf("Firebird update of ID_ESTOQUE %d failed: %w", p.key, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("Firebird reverse update commit failed: %w", err)
+	}
+	return nil
+}
+
+// record stores the hashes of rows in TB_SYNC_REVERSO
+func (w *reverseWriter) record(ctx context.Context, rows []reversePush) error {
+	now := time.Now()
+	values := make([]interface{}, 0, 3*len(rows))
+	for _, p := range rows {
+		values = append(values, p.key, p.hash, now)
+	}
+	query := "INSERT INTO TB_SYNC_REVERSO (ID_ESTOQUE, HASH, DT_ENVIO) VALUES (?, ?, ?)" + strings.Repeat(", (?, ?, ?)", len(rows)-1) +
+		db.UpsertClause(w.cfg, "ID_ESTOQUE", []string{"HASH", "DT_ENVIO"})
+	if _, err := w.mysqlDB.ExecContext(ctx, query, values...); err != nil {
+		return fmt.Errorf("error writing TB_SYNC_REVERSO: %w", err)
+	}
+	return nil
+}
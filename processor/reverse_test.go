@@ -0,0 +1,52 @@
+package processor
+
+import "testing"
+
+func TestReverseSyncPushesMySQLColumns(t *testing.T) {
+	cfg, firebirdDB, mysqlDB := devDatabases(t, map[string]string{"REVERSE_SYNC_COLUMNS": "DESCRICAO_WEB"})
+	execAll(t, firebirdDB, "ALTER TABLE TB_ESTOQUE ADD COLUMN DESCRICAO_WEB TEXT")
+	execAll(t, mysqlDB, "ALTER TABLE TB_ESTOQUE ADD COLUMN DESCRICAO_WEB TEXT")
+	syncDev(t, cfg, firebirdDB, mysqlDB)
+
+	execAll(t, mysqlDB, "UPDATE TB_ESTOQUE SET DESCRICAO_WEB = 'web' WHERE ID_ESTOQUE = 1")
+	_, _, _, stats := syncDev(t, cfg, firebirdDB, mysqlDB)
+	if rs := stats.Reverse; rs == nil || rs.Pushed != 1 || len(rs.Conflicts) != 0 {
+		t.Fatalf("reverse stats = %+v; want 1 row pushed", rs)
+	}
+	if n := countRows(t, firebirdDB, "SELECT COUNT(*) FROM TB_ESTOQUE WHERE ID_ESTOQUE = 1 AND DESCRICAO_WEB = 'web'"); n != 1 {
+		t.Error("MySQL value not pushed into Firebird")
+	}
+
+	// Both sides change: the conflict leaves Firebird alone
+	execAll(t, firebirdDB, "UPDATE TB_ESTOQUE SET DESCRICAO_WEB = 'erp' WHERE ID_ESTOQUE = 1")
+	execAll(t, mysqlDB, "UPDATE TB_ESTOQUE SET DESCRICAO_WEB = 'web 2' WHERE ID_ESTOQUE = 1")
+	_, _, _, stats = syncDev(t, cfg, firebirdDB, mysqlDB)
+	if rs := stats.Reverse; rs.Pushed != 0 || len(rs.Conflicts) != 1 || rs.Conflicts[0] != 1 {
+		t.Errorf("reverse stats = %+v; want the conflict of row 1 and nothing pushed", rs)
+	}
+	if n := countRows(t, firebirdDB, "SELECT COUNT(*) FROM TB_ESTOQUE WHERE ID_ESTOQUE = 1 AND DESCRICAO_WEB = 'erp'"); n != 1 {
+		t.Error("conflicting Firebird value overwritten")
+	}
+}
+
+func TestReverseSyncDisabled(t *testing.T) {
+	for _, values := range []map[string]string{
+		{},
+		{"REVERSE_SYNC_COLUMNS": "DESCRICAO_WEB", "SYNC_MODE": "quantity"},
+	} {
+		cfg, firebirdDB, mysqlDB := devDatabases(t, values)
+		execAll(t, firebirdDB, "ALTER TABLE TB_ESTOQUE ADD COLUMN DESCRICAO_WEB TEXT")
+		execAll(t, mysqlDB,
+			"ALTER TABLE TB_ESTOQUE ADD COLUMN DESCRICAO_WEB TEXT",
+			"INSERT INTO TB_ESTOQUE (ID_ESTOQUE, DESCRICAO, DESCRICAO_WEB) VALUES (1, 'stored', 'web')",
+		)
+
+		_, _, _, stats := syncDev(t, cfg, firebirdDB, mysqlDB)
+		if stats.Reverse != nil {
+			t.Errorf("%v: reverse sync ran: %+v", values, stats.Reverse)
+		}
+		if n := countRows(t, firebirdDB, "SELECT COUNT(*) FROM TB_ESTOQUE WHERE DESCRICAO_WEB IS NOT NULL"); n != 0 {
+			t.Errorf("%v: %d Firebird rows written", values, n)
+		}
+	}
+}
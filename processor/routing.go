@@ -0,0 +1,103 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/partition"
+)
+
+// workQueues hands the operations to the workers a chunk at a time: through
+// a single queue any idle worker takes from, or with WORKER_ROUTING through
+// one queue per worker, each key always going to the same worker. Queues
+// are bounded, so reading waits for the writers; with routing a worker
+// falling behind holds up the others too.
+type workQueues struct {
+	queues []chan []RowOperation
+	chunks [][]RowOperation      // Operations gathered for each queue
+	part   partition.Partitioner // Nil for the shared queue
+	sent   int                   // Operations handed to the workers
+}
+
+// newWorkQueues returns the queues of numWorkers workers. Range routing
+// splits the Firebird ID_ESTOQUE bounds between them.
+func newWorkQueues(ctx context.Context, firebirdDB sourceQuerier, cfg config.Config, numWorkers int) (*workQueues, error) {
+	q := &workQueues{}
+	if cfg.WorkerRouting == "" {
+		q.queues = []chan []RowOperation{make(chan []RowOperation, numWorkers*2)}
+	} else {
+		var first, last int
+		if cfg.WorkerRouting == partition.Range {
+			var err error
+			if first, last, _, err = sourceKeyBounds(ctx, firebirdDB); err != nil {
+				return nil, fmt.Errorf("error querying Firebird key range: %w", err)
+			}
+		}
+		part, err := partition.New(cfg.WorkerRouting, numWorkers, first, last)
+		if err != nil {
+			return nil, err
+		}
+		q.part = part
+		q.queues = make([]chan []RowOperation, numWorkers)
+		for i := range q.queues {
+			q.queues[i] = make(chan []RowOperation, 2)
+		}
+	}
+	q.chunks = make([][]RowOperation, len(q.queues))
+	return q, nil
+}
+
+// queue returns the queue worker reads from
+func (q *workQueues) queue(worker int) <-chan []RowOperation {
+	if q.part == nil {
+		return q.queues[0]
+	}
+	return q.queues[worker]
+}
+
+// add gathers op for its queue, sending the chunk once it is full
+func (q *workQueues) add(ctx context.Context, op RowOperation) error {
+	i := 0
+	if q.part != nil {
+		i = q.part.Partition(op.IDEstoque)
+	}
+	q.chunks[i] = append(q.chunks[i], op)
+	if len(q.chunks[i]) < pipelineChunk {
+		return nil
+	}
+	return q.send(ctx, i)
+}
+
+// flush sends the chunks not yet full
+func (q *workQueues) flush(ctx context.Context) error {
+	for i := range q.chunks {
+		if err := q.send(ctx, i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// send hands the chunk of queue i to its worker
+func (q *workQueues) send(ctx context.Context, i int) error {
+	chunk := q.chunks[i]
+	if len(chunk) == 0 {
+		return nil
+	}
+	select {
+	case q.queues[i] <- chunk:
+		q.sent += len(chunk)
+		q.chunks[i] = make([]RowOperation, 0, pipelineChunk)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// close tells the workers no more operations come
+func (q *workQueues) close() {
+	for _, c := range q.queues {
+		close(c)
+	}
+}
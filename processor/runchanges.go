@@ -0,0 +1,184 @@
+package processor
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/money"
+)
+
+// RunChanges is what TB_ESTOQUE_SYNC_AUDIT recorded of one run: the rows it
+// wrote and how it moved quantities, sale prices and the inventory value
+type RunChanges struct {
+	RunID          string
+	At             time.Time // First recorded change, zero when the run changed nothing
+	Inserted       int
+	Updated        int
+	QuantityChange float64     // Σ QTD_ATUAL after − before
+	CostValue      money.Cents // Σ QTD_ATUAL × PRC_CUSTO after − before
+	SaleValue      money.Cents // Σ QTD_ATUAL × PRC_VENDA after − before
+	Repriced       int         // Updates changing PRC_VENDA
+	PricesRaised   int
+	PricesLowered  int
+	SalePriceShift float64 // Mean relative PRC_VENDA change of the repriced rows, in percent
+}
+
+// stockValues are the quantity and prices of a row, each nil when unknown
+type stockValues struct {
+	quantity   *float64
+	cost, sale *money.Cents
+}
+
+// value returns quantity × price, zero when either is unknown
+func (v stockValues) value(price *money.Cents) money.Cents {
+	if v.quantity == nil || price == nil {
+		return 0
+	}
+	return money.Cents(math.Round(*v.quantity * float64(*price)))
+}
+
+// ReadRunChanges sums the audit rows of runID. The audit only holds the
+// columns a change wrote, so the quantity or price an update left alone is
+// taken from the current TB_ESTOQUE row; inserted rows count from zero.
+func ReadRunChanges(ctx context.Context, mysqlDB *sql.DB, cfg config.Config, runID string) (*RunChanges, error) {
+	if !cfg.AuditEnabled {
+		return nil, fmt.Errorf("comparing runs needs AUDIT_ENABLED: their changes are read from TB_ESTOQUE_SYNC_AUDIT")
+	}
+
+	quantity, cost, sale := cfg.ProductColumn("QTD_ATUAL"), cfg.ProductColumn("PRC_CUSTO"), cfg.ProductColumn("PRC_VENDA")
+	current := func(column string) string {
+		if column == "" {
+			return "NULL" // Not written to MySQL
+		}
+		return "e." + column
+	}
+	query := "SELECT a.OPERACAO, a.VALORES_ANTERIORES, a.VALORES_NOVOS, a.DT_ALTERACAO, " +
+		strings.Join([]string{current(quantity), current(cost), current(sale)}, ", ") +
+		" FROM TB_ESTOQUE_SYNC_AUDIT a LEFT JOIN TB_ESTOQUE e ON e." + productKeyColumn(cfg) + " = a.ID_ESTOQUE" +
+		" WHERE a.RUN_ID = ?"
+	rows, err := mysqlDB.QueryContext(ctx, query, runID)
+	if err != nil {
+		return nil, fmt.Errorf("error reading TB_ESTOQUE_SYNC_AUDIT: %w", err)
+	}
+	defer rows.Close()
+
+	rc := &RunChanges{RunID: runID}
+	var shifts float64
+	for rows.Next() {
+		var operation string
+		var before, after sql.NullString
+		var at sql.NullTime
+		var qty sql.NullFloat64
+		var costNow, saleNow money.NullCents
+		if err := rows.Scan(&operation, &before, &after, &at, &qty, &costNow, &saleNow); err != nil {
+			return nil, fmt.Errorf("error reading TB_ESTOQUE_SYNC_AUDIT: %w", err)
+		}
+		if at.Valid && (rc.At.IsZero() || at.Time.Before(rc.At)) {
+			rc.At = at.Time
+		}
+
+		now := stockValues{}
+		if qty.Valid {
+			now.quantity = &qty.Float64
+		}
+		if costNow.Valid {
+			now.cost = &costNow.Cents
+		}
+		if saleNow.Valid {
+			now.sale = &saleNow.Cents
+		}
+		newValues, err := decodeStockValues(after.String, quantity, cost, sale, now)
+		if err != nil {
+			return nil, err
+		}
+		var oldValues stockValues // An insert starts from nothing
+		if operation == auditUpdate {
+			rc.Updated++
+			if oldValues, err = decodeStockValues(before.String, quantity, cost, sale, now); err != nil {
+				return nil, err
+			}
+		} else {
+			rc.Inserted++
+		}
+
+		if newValues.quantity != nil {
+			rc.QuantityChange += *newValues.quantity
+		}
+		if oldValues.quantity != nil {
+			rc.QuantityChange -= *oldValues.quantity
+		}
+		rc.CostValue += newValues.value(newValues.cost) - oldValues.value(oldValues.cost)
+		rc.SaleValue += newValues.value(newValues.sale) - oldValues.value(oldValues.sale)
+
+		if operation == auditUpdate && oldValues.sale != nil && newValues.sale != nil && *oldValues.sale != *newValues.sale {
+			rc.Repriced++
+			if *newValues.sale > *oldValues.sale {
+				rc.PricesRaised++
+			} else {
+				rc.PricesLowered++
+			}
+			if *oldValues.sale != 0 {
+				shifts += float64(*newValues.sale-*oldValues.sale) / float64(*oldValues.sale) * 100
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading TB_ESTOQUE_SYNC_AUDIT: %w", err)
+	}
+	if rc.Repriced > 0 {
+		rc.SalePriceShift = shifts / float64(rc.Repriced)
+	}
+	return rc, nil
+}
+
+// decodeStockValues reads the quantity and prices of an audit JSON object,
+// those it does not hold taken from fallback
+func decodeStockValues(data, quantity, cost, sale string, fallback stockValues) (stockValues, error) {
+	v := fallback
+	if data == "" {
+		return v, nil
+	}
+	dec := json.NewDecoder(strings.NewReader(data))
+	dec.UseNumber()
+	var values map[string]interface{}
+	if err := dec.Decode(&values); err != nil {
+		return v, fmt.Errorf("error decoding audit values: %w", err)
+	}
+
+	// A written column replaces the fallback, NULL included
+	if n, written := auditNumber(values, quantity); written {
+		v.quantity = nil
+		if f, err := n.Float64(); err == nil {
+			v.quantity = &f
+		}
+	}
+	for _, p := range []struct {
+		column string
+		dst    **money.Cents
+	}{{cost, &v.cost}, {sale, &v.sale}} {
+		if n, written := auditNumber(values, p.column); written {
+			*p.dst = nil
+			if c, err := money.Parse(n.String()); err == nil {
+				*p.dst = &c
+			}
+		}
+	}
+	return v, nil
+}
+
+// auditNumber returns the audited value of column, empty unless a number,
+// and whether the change wrote the column
+func auditNumber(values map[string]interface{}, column string) (json.Number, bool) {
+	raw, ok := values[column]
+	if !ok || column == "" {
+		return "", false
+	}
+	n, _ := raw.(json.Number)
+	return n, true
+}
@@ -0,0 +1,79 @@
+package processor
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+
+	"github.com/waldirborbajr/sync/db"
+	"github.com/waldirborbajr/sync/logger"
+)
+
+// batchSavepoint is the savepoint each statement of a batch transaction runs
+// under with BATCH_ISOLATE_ERRORS. Statements run one after the other, so a
+// single name is enough: declaring it again replaces the previous one.
+const batchSavepoint = "sync_batch"
+
+// isolate runs exec on ops within tx. With BATCH_ISOLATE_ERRORS exec runs
+// under a savepoint: when it fails with a data error (constraint, conversion)
+// only its own work is rolled back, and ops are split in halves retried on
+// their own, down to the single rows MySQL refuses, which are left out. The
+// earlier statements of the transaction are kept. It returns the ops written.
+// Other errors are returned as is; retryable ones have usually cost the whole
+// transaction, which the batch retrier repeats.
+func (w *writer) isolate(tx *sql.Tx, ops []RowOperation, exec func([]RowOperation) error) ([]RowOperation, error) {
+	if !w.cfg.BatchIsolateErrors {
+		return ops, exec(ops)
+	}
+
+	if _, err := tx.Exec("SAVEPOINT " + batchSavepoint); err != nil {
+		return nil, fmt.Errorf("error creating savepoint: %w", err)
+	}
+	err := exec(ops)
+	if err == nil {
+		if _, err := tx.Exec("RELEASE SAVEPOINT " + batchSavepoint); err != nil {
+			return nil, fmt.Errorf("error releasing savepoint: %w", err)
+		}
+		return ops, nil
+	}
+	if class := db.Classify(err); class != db.ClassConstraint && class != db.ClassConversion {
+		return nil, err
+	}
+	if _, rbErr := tx.Exec("ROLLBACK TO SAVEPOINT " + batchSavepoint); rbErr != nil {
+		return nil, fmt.Errorf("error rolling back to savepoint after %v: %w", err, rbErr)
+	}
+
+	if len(ops) == 1 {
+		log := logger.GetLogger()
+		log.Warn().Err(err).Int("id_estoque", ops[0].IDEstoque).Msg("Row rejected by MySQL, left out of its batch")
+		return nil, nil
+	}
+	mid := len(ops) / 2
+	first, err := w.isolate(tx, ops[:mid], exec)
+	if err != nil {
+		return nil, err
+	}
+	second, err := w.isolate(tx, ops[mid:], exec)
+	if err != nil {
+		return nil, err
+	}
+	return slices.Concat(first, second), nil
+}
+
+// rejectedFrom returns the ops of batch missing from written
+func rejectedFrom(batch, written []RowOperation) []RowOperation {
+	if len(written) == len(batch) {
+		return nil
+	}
+	kept := make(map[int]bool, len(written))
+	for _, op := range written {
+		kept[op.IDEstoque] = true
+	}
+	var rejected []RowOperation
+	for _, op := range batch {
+		if !kept[op.IDEstoque] {
+			rejected = append(rejected, op)
+		}
+	}
+	return rejected
+}
@@ -0,0 +1,47 @@
+package processor
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestIsolateErrorsRejectsOnlyRefusedRows(t *testing.T) {
+	tests := []struct {
+		isolate   string
+		written   int
+		unwritten int64
+		rejected  []int
+	}{
+		{"false", 0, 5, nil},
+		{"true", 4, 0, []int{3}},
+	}
+	for _, tt := range tests {
+		cfg, _, mysqlDB := devDatabases(t, map[string]string{"BATCH_ISOLATE_ERRORS": tt.isolate, "BATCH_RETRIES": "0"})
+		// MySQL refuses row 3, described like a stored row
+		execAll(t, mysqlDB,
+			"CREATE UNIQUE INDEX UQ_DESCRICAO ON TB_ESTOQUE (DESCRICAO)",
+			"INSERT INTO TB_ESTOQUE (ID_ESTOQUE, DESCRICAO) VALUES (9, 'taken')",
+		)
+
+		var ops []RowOperation
+		for id := 1; id <= 5; id++ {
+			ops = append(ops, RowOperation{Type: OpInsert, IDEstoque: id, Descricao: fmt.Sprint("product ", id)})
+		}
+		ops[2].Descricao = "taken"
+		w := devWriter(cfg, mysqlDB)
+		inserted, _ := writeOps(w, ops...)
+
+		if inserted != int64(tt.written) || w.unwritten.Load() != tt.unwritten {
+			t.Errorf("isolate %s: inserted, unwritten = %d, %d; want %d, %d", tt.isolate, inserted, w.unwritten.Load(), tt.written, tt.unwritten)
+		}
+		if n := countRows(t, mysqlDB, "SELECT COUNT(*) FROM TB_ESTOQUE WHERE ID_ESTOQUE <= 5"); n != tt.written {
+			t.Errorf("isolate %s: %d rows stored; want %d", tt.isolate, n, tt.written)
+		}
+		if got := w.rejected.sorted(); fmt.Sprint(got) != fmt.Sprint(tt.rejected) {
+			t.Errorf("isolate %s: rejected = %v; want %v", tt.isolate, got, tt.rejected)
+		}
+		if n := countRows(t, mysqlDB, "SELECT COUNT(*) FROM TB_ESTOQUE WHERE ID_ESTOQUE = 9 AND DESCRICAO = 'taken'"); n != 1 {
+			t.Errorf("isolate %s: stored row changed", tt.isolate)
+		}
+	}
+}
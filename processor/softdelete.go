@@ -0,0 +1,101 @@
+package processor
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/logger"
+	"github.com/waldirborbajr/sync/transfer"
+)
+
+// softDeleteChunk is the number of keys marked deleted per UPDATE
+const softDeleteChunk = 500
+
+// softDeleteField returns the SOFT_DELETE_COLUMN field of the configured style.
+// Rows read from Firebird are written active, so a deleted row coming back
+// differs from its stored row and is reactivated by the update.
+func softDeleteField(cfg config.Config) productField {
+	if cfg.SoftDeleteStyle == config.SoftDeleteTimestamp {
+		return deletedAtField
+	}
+	return activeField
+}
+
+// softDeleted reports whether a stored row is marked deleted in SOFT_DELETE_COLUMN
+func softDeleted(cfg config.Config, rec *mysqlRecord) bool {
+	switch {
+	case cfg.SoftDeleteColumn == "" || rec == nil:
+		return false
+	case cfg.SoftDeleteStyle == config.SoftDeleteTimestamp:
+		return rec.DeletedAt.Valid
+	default:
+		return rec.Ativo.Valid && rec.Ativo.Int64 == 0
+	}
+}
+
+// missingKeys returns the sorted keys of the stored rows not in read and not
+// already marked deleted, as far as the loaded records tell
+func (lk *lookups) missingKeys(cfg config.Config, read map[int]struct{}) []int {
+	var keys []int
+	if lk.hashes != nil {
+		for key := range lk.hashes {
+			if _, ok := read[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+	} else {
+		for key, rec := range lk.existing {
+			if _, ok := read[key]; !ok && !softDeleted(cfg, &rec) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// softDeleteMissing marks the stored rows not read from Firebird as deleted
+// in SOFT_DELETE_COLUMN, leaving rows already marked alone, and returns the
+// number of rows marked
+func softDeleteMissing(ctx context.Context, mysqlDB *sql.DB, cfg config.Config, retrier *transfer.Retrier, keys []int) (int, error) {
+	column := cfg.SoftDeleteColumn
+	set, unmarked := column+" = 0", "("+column+" IS NULL OR "+column+" <> 0)"
+	var args []interface{}
+	if cfg.SoftDeleteStyle == config.SoftDeleteTimestamp {
+		set, unmarked = column+" = ?", column+" IS NULL"
+		args = append(args, time.Now())
+	}
+
+	marked := 0
+	for start := 0; start < len(keys); start += softDeleteChunk {
+		chunk := keys[start:min(start+softDeleteChunk, len(keys))]
+		query := "UPDATE TB_ESTOQUE SET " + set + " WHERE " + productKeyColumn(cfg) + " IN (?" + strings.Repeat(", ?", len(chunk)-1) + ") AND " + unmarked
+		values := slices.Clone(args)
+		for _, key := range chunk {
+			values = append(values, key)
+		}
+
+		var affected int64
+		err := retrier.Do(ctx, "soft delete", len(chunk), func() error {
+			res, err := mysqlDB.ExecContext(ctx, query, values...)
+			if err != nil {
+				return err
+			}
+			affected, err = res.RowsAffected()
+			return err
+		})
+		if err != nil {
+			return marked, fmt.Errorf("error marking rows deleted in %s: %w", column, err)
+		}
+		marked += int(affected)
+	}
+
+	log := logger.GetLogger()
+	log.Info().Str("column", column).Int("rows", marked).Msg("Rows missing from Firebird marked deleted")
+	return marked, nil
+}
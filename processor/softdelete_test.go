@@ -0,0 +1,33 @@
+package processor
+
+import "testing"
+
+func TestSoftDeleteMarksMissingRows(t *testing.T) {
+	tests := []struct {
+		column, style, marked string
+	}{
+		{"ATIVO", "flag", "ATIVO = 0"},
+		{"DELETED_AT", "timestamp", "DELETED_AT IS NOT NULL"},
+	}
+	for _, tt := range tests {
+		cfg, firebirdDB, mysqlDB := devDatabases(t, map[string]string{"SOFT_DELETE_COLUMN": tt.column, "SOFT_DELETE_STYLE": tt.style})
+		// Product 555 is no longer in Firebird
+		execAll(t, mysqlDB, "INSERT INTO TB_ESTOQUE (ID_ESTOQUE, DESCRICAO, ATIVO) VALUES (555, 'gone', 1)")
+
+		_, _, _, stats := syncDev(t, cfg, firebirdDB, mysqlDB)
+		if stats.SoftDeleted != 1 {
+			t.Errorf("%s: soft deleted %d rows; want 1", tt.style, stats.SoftDeleted)
+		}
+		if n := countRows(t, mysqlDB, "SELECT COUNT(*) FROM TB_ESTOQUE WHERE ID_ESTOQUE = 555 AND "+tt.marked); n != 1 {
+			t.Errorf("%s: missing row not kept and marked %s", tt.style, tt.marked)
+		}
+		if n := countRows(t, mysqlDB, "SELECT COUNT(*) FROM TB_ESTOQUE WHERE ID_ESTOQUE <> 555 AND "+tt.marked); n != 0 {
+			t.Errorf("%s: %d rows read from Firebird marked deleted", tt.style, n)
+		}
+
+		// A second run leaves the marked row alone
+		if _, _, _, stats := syncDev(t, cfg, firebirdDB, mysqlDB); stats.SoftDeleted != 0 {
+			t.Errorf("%s: second run soft deleted %d rows; want 0", tt.style, stats.SoftDeleted)
+		}
+	}
+}
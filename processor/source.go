@@ -0,0 +1,283 @@
+package processor
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/logger"
+	"github.com/waldirborbajr/sync/run"
+	"github.com/waldirborbajr/sync/transfer"
+)
+
+// sourceQuerier runs the source queries: the Firebird pool, or with
+// FIREBIRD_ISOLATION the transaction the extract reads in
+type sourceQuerier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// keyRange limits the source query to the ID_ESTOQUE keys from first to last
+type keyRange struct {
+	first, last int
+}
+
+// readSource reads the Firebird product rows of the run and hands each to fn.
+// With SOURCE_CHUNK_SIZE the keys are read one range at a time: a range is
+// buffered before its rows are handed on, so one failing with a retryable
+// error is read again on its own without handing any row twice. With
+// SOURCE_READERS the key bounds are split between readers reading at once,
+// fn being called by one of them at a time.
+func readSource(ctx context.Context, firebirdDB sourceQuerier, cfg config.Config, since time.Time, retrier *transfer.Retrier, stats *ProcessingStats, fn func(sourceRow) error) error {
+	log := logger.GetLogger()
+
+	if cfg.SourceChunkSize <= 0 && cfg.SourceReaders <= 1 {
+		r := &spanReader{firebirdDB: firebirdDB, cfg: cfg, since: since, retrier: retrier, fn: fn}
+		defer func() { stats.QueryTime, stats.UnscannedRows = r.reading.total, r.unscanned }()
+		return r.read(ctx, nil)
+	}
+
+	// Only the time spent waiting on Firebird is measured, not the handling
+	// of the rows in between
+	var bounds stageTimer
+	bounds.start()
+	first, last, ok, err := sourceKeyBounds(ctx, firebirdDB)
+	bounds.stop()
+	stats.QueryTime = bounds.total
+	if err != nil {
+		return fmt.Errorf("error querying Firebird key range: %w", err)
+	}
+	if !ok {
+		return nil
+	}
+
+	spans := splitKeys(first, last, cfg.SourceReaders)
+	if len(spans) == 1 {
+		r := &spanReader{firebirdDB: firebirdDB, cfg: cfg, since: since, retrier: retrier, fn: fn}
+		defer func() {
+			stats.QueryTime, stats.SourceChunks, stats.UnscannedRows = bounds.total+r.reading.total, r.chunks, r.unscanned
+		}()
+		if cfg.SourceChunkSize > 0 {
+			log.Info().Int("first", first).Int("last", last).Int("chunk_size", cfg.SourceChunkSize).Msg("Reading Firebird products in key ranges")
+		}
+		return r.read(ctx, &spans[0])
+	}
+	_, shared := firebirdDB.(*sql.Tx)
+	log.Info().Int("first", first).Int("last", last).Int("readers", len(spans)).Int("chunk_size", cfg.SourceChunkSize).
+		Bool("shared_transaction", shared).Msg("Reading Firebird products with parallel readers")
+
+	stats.SourceReaders = len(spans)
+
+	// A failing reader stops the others
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex
+	handle := func(src sourceRow) error {
+		mu.Lock()
+		defer mu.Unlock()
+		return fn(src)
+	}
+
+	readers := make([]*spanReader, len(spans))
+	errs := make([]error, len(spans))
+	var wg sync.WaitGroup
+	for i := range spans {
+		readers[i] = &spanReader{firebirdDB: firebirdDB, cfg: cfg, since: since, retrier: retrier, fn: handle}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// At ROW_LIMIT the others stop on their next row, a cancelled
+			// query would count as an error
+			if errs[i] = readers[i].read(ctx, &spans[i]); errs[i] != nil && !errors.Is(errs[i], errRowLimit) {
+				cancel()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// Readers wait on Firebird side by side: the slowest one's wait is the run's
+	var reading time.Duration
+	for _, r := range readers {
+		reading = max(reading, r.reading.total)
+		stats.SourceChunks += r.chunks
+		stats.UnscannedRows += r.unscanned
+	}
+	stats.QueryTime = bounds.total + reading
+
+	// The error that stopped the readers rather than their cancellation
+	var stopped error
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return err
+		}
+		if stopped == nil {
+			stopped = err
+		}
+	}
+	return stopped
+}
+
+// splitKeys splits the keys from first to last into at most n contiguous
+// spans of equal width, as range partitioning does
+func splitKeys(first, last, n int) []keyRange {
+	width := (uint64(last-first) + uint64(n)) / uint64(n)
+	spans := make([]keyRange, 0, n)
+	for lo := first; ; {
+		hi := last
+		if uint64(last-lo) >= width {
+			hi = lo + int(width) - 1
+		}
+		spans = append(spans, keyRange{first: lo, last: hi})
+		if hi == last {
+			return spans
+		}
+		lo = hi + 1
+	}
+}
+
+// spanReader reads the product rows of a span of keys
+type spanReader struct {
+	firebirdDB sourceQuerier
+	cfg        config.Config
+	since      time.Time
+	retrier    *transfer.Retrier
+	fn         func(sourceRow) error
+	reading    stageTimer // Time spent waiting on Firebird
+	chunks     int        // SOURCE_CHUNK_SIZE ranges read
+	unscanned  int        // Rows skipped because they failed to scan
+}
+
+// read hands the rows with keys in span, every row for nil, to fn: with a
+// single query, or one SOURCE_CHUNK_SIZE range at a time
+func (r *spanReader) read(ctx context.Context, span *keyRange) error {
+	log := logger.GetLogger()
+	cfg := r.cfg
+
+	if cfg.SourceChunkSize <= 0 {
+		query, args := buildSourceQuery(cfg, r.since, span)
+		r.reading.start()
+		rows, err := r.firebirdDB.QueryContext(ctx, query, args...)
+		r.reading.stop()
+		if err != nil {
+			return fmt.Errorf("error querying Firebird: %w", err)
+		}
+		defer rows.Close()
+		log.Info().Msg("Firebird query executed")
+		run.Touch(ctx)
+
+		for {
+			r.reading.start()
+			if !rows.Next() {
+				r.reading.stop()
+				break
+			}
+			src, err := scanSourceRow(rows, cfg)
+			r.reading.stop()
+			if err != nil {
+				r.retrier.Record(err)
+				log.Error().Err(err).Int("id_estoque", src.IDEstoque).Msg("Error scanning Firebird row")
+				r.unscanned++
+				continue
+			}
+			if err := r.fn(src); err != nil {
+				return err
+			}
+		}
+		return rows.Err()
+	}
+
+	var buf []sourceRow
+	var skipped int
+	for lo := span.first; lo <= span.last; lo += cfg.SourceChunkSize {
+		keys := &keyRange{first: lo, last: min(lo+cfg.SourceChunkSize-1, span.last)}
+		err := r.retrier.Do(ctx, "source", cfg.SourceChunkSize, func() error {
+			buf = buf[:0]
+			return r.reading.measure(func() (err error) {
+				skipped, err = readSourceRange(ctx, r.firebirdDB, cfg, r.since, keys, r.retrier, &buf)
+				return err
+			})
+		})
+		if err != nil {
+			return fmt.Errorf("error querying Firebird keys %d to %d: %w", keys.first, keys.last, err)
+		}
+		r.chunks++
+		r.unscanned += skipped
+		run.Touch(ctx)
+
+		for _, src := range buf {
+			if err := r.fn(src); err != nil {
+				return err
+			}
+		}
+		if keys.last == span.last {
+			break // lo would overflow past the largest key
+		}
+	}
+	return nil
+}
+
+// readSourceRange appends the product rows with keys in r to buf. It returns
+// the rows skipped because they failed to scan.
+func readSourceRange(ctx context.Context, firebirdDB sourceQuerier, cfg config.Config, since time.Time, r *keyRange, retrier *transfer.Retrier, buf *[]sourceRow) (skipped int, err error) {
+	log := logger.GetLogger()
+
+	query, args := buildSourceQuery(cfg, since, r)
+	rows, err := firebirdDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		src, err := scanSourceRow(rows, cfg)
+		if err != nil {
+			retrier.Record(err)
+			log.Error().Err(err).Int("id_estoque", src.IDEstoque).Msg("Error scanning Firebird row")
+			skipped++
+			continue
+		}
+		*buf = append(*buf, src)
+	}
+	return skipped, rows.Err()
+}
+
+// sourceKeyBounds returns the smallest and largest ID_ESTOQUE in Firebird,
+// ok false when TB_ESTOQUE is empty
+func sourceKeyBounds(ctx context.Context, firebirdDB sourceQuerier) (first, last int, ok bool, err error) {
+	var lo, hi sql.NullInt64
+	if err := firebirdDB.QueryRowContext(ctx, "SELECT MIN(ID_ESTOQUE), MAX(ID_ESTOQUE) FROM TB_ESTOQUE").Scan(&lo, &hi); err != nil {
+		return 0, 0, false, err
+	}
+	return int(lo.Int64), int(hi.Int64), lo.Valid && hi.Valid, nil
+}
+
+// scanSourceRow scans a row of the source query
+func scanSourceRow(rows *sql.Rows, cfg config.Config) (sourceRow, error) {
+	var src sourceRow
+	var idGrupo sql.NullInt64
+	var status sql.NullString
+	var modified sql.NullTime
+	var supplier sql.NullString
+
+	dest := []interface{}{&src.IDEstoque, &src.Descricao, &src.QtdAtual, &src.PrcCusto, &src.PrcDolar, &idGrupo, &status}
+	if incremental(cfg) {
+		dest = append(dest, &modified)
+	}
+	if cfg.UsesSupplier() {
+		dest = append(dest, &supplier)
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return src, err
+	}
+	src.IDGrupo, src.HasGrupo = int(idGrupo.Int64), idGrupo.Valid
+	src.Status, src.HasStatus = strings.TrimSpace(status.String), status.Valid
+	src.Modified = modified.Time
+	src.Supplier, src.HasSupplier = strings.TrimSpace(supplier.String), supplier.Valid
+	return src, nil
+}
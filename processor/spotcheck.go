@@ -0,0 +1,227 @@
+package processor
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"strings"
+
+	"github.com/waldirborbajr/sync/compare"
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/logger"
+)
+
+// spotCheckChunk caps the keys read back per query
+const spotCheckChunk = 500
+
+// confidenceZ is the normal quantile of the 95% confidence of the VERIFY_SAMPLE estimate
+const confidenceZ = 1.96
+
+// SpotCheckStats is the result of reading rows back after the run committed
+// (SPOT_CHECK_IDS and SPOT_CHECK_SAMPLE)
+type SpotCheckStats struct {
+	Checked    int            // Rows read back
+	Missing    []int          // Keys the run inserted or kept that are not in TB_ESTOQUE
+	Mismatches []SpotMismatch // Columns whose stored value differs from the computed one
+	NotSynced  []int          // SPOT_CHECK_IDS the run left alone (not read, skipped or deferred)
+
+	// VERIFY_SAMPLE: the rows written, those of them read back and those
+	// found missing or with a column not holding the computed value
+	SamplePercent float64
+	Written       int
+	Sampled       int
+	Failed        int
+}
+
+// ErrorRate estimates the share of the written rows not holding the computed
+// values from the VERIFY_SAMPLE rows, with its 95% Wilson score interval,
+// which stays within [0, 1] and is meaningful when no sampled row failed
+func (s SpotCheckStats) ErrorRate() (rate, low, high float64) {
+	if s.Sampled == 0 {
+		return 0, 0, 1
+	}
+	n := float64(s.Sampled)
+	rate = float64(s.Failed) / n
+	z2 := confidenceZ * confidenceZ
+	center := (rate + z2/(2*n)) / (1 + z2/n)
+	margin := confidenceZ / (1 + z2/n) * math.Sqrt(rate*(1-rate)/n+z2/(4*n*n))
+	if s.Failed == 0 {
+		return 0, 0, min(center+margin, 1) // center-margin is 0 but for rounding
+	}
+	return rate, max(center-margin, 0), min(center+margin, 1)
+}
+
+// OK reports whether every checked row holds the computed values
+func (s SpotCheckStats) OK() bool {
+	return len(s.Missing) == 0 && len(s.Mismatches) == 0
+}
+
+// SpotMismatch is a column of a checked row that does not hold the computed value
+type SpotMismatch struct {
+	IDEstoque int
+	Column    string
+	Stored    string
+	Expected  string
+}
+
+// spotChecker picks the operations to read back while rows are processed:
+// the configured keys, a uniform sample of the written rows kept by
+// reservoir sampling so the whole run need not be held in memory, and each
+// written row with the VERIFY_SAMPLE probability
+type spotChecker struct {
+	keys     map[int]bool
+	fixed    map[int]RowOperation
+	size     int
+	sample   []RowOperation
+	fraction float64
+	verified []RowOperation
+	written  int
+}
+
+// newSpotChecker returns the checker of cfg, nil when no spot check is configured
+func newSpotChecker(cfg config.Config) *spotChecker {
+	if len(cfg.SpotCheckIDs) == 0 && cfg.SpotCheckSample <= 0 && cfg.VerifySample <= 0 {
+		return nil
+	}
+	sc := &spotChecker{keys: make(map[int]bool), fixed: make(map[int]RowOperation), size: cfg.SpotCheckSample, fraction: cfg.VerifySample / 100}
+	for _, id := range cfg.SpotCheckIDs {
+		sc.keys[id] = true
+	}
+	return sc
+}
+
+// observe considers op for checking. Rows the run deliberately did not write
+// (unmapped status, STOCK_POLICY=skip, deferred new products) are not checked.
+func (sc *spotChecker) observe(op RowOperation) {
+	if sc == nil || op.statusUnmapped || op.stockSkipped || op.deferred {
+		return
+	}
+	if sc.keys[op.IDEstoque] {
+		sc.fixed[op.IDEstoque] = op
+		return
+	}
+	if op.Type == OpIgnore {
+		return
+	}
+
+	sc.written++
+	if sc.fraction > 0 && rand.Float64() < sc.fraction {
+		sc.verified = append(sc.verified, op)
+	}
+	if sc.size == 0 {
+		return
+	}
+	if len(sc.sample) < sc.size {
+		sc.sample = append(sc.sample, op)
+	} else if i := rand.N(sc.written); i < sc.size {
+		sc.sample[i] = op
+	}
+}
+
+// check reads the picked rows back from TB_ESTOQUE and compares each column
+// with the value computed for it, using the column comparators
+func (sc *spotChecker) check(ctx context.Context, db *sql.DB, cfg config.Config, columns []productColumn) (*SpotCheckStats, error) {
+	if sc == nil {
+		return nil, nil
+	}
+	log := logger.GetLogger()
+	stats := &SpotCheckStats{SamplePercent: sc.fraction * 100, Written: sc.written, Sampled: len(sc.verified)}
+
+	ops := make(map[int]RowOperation, len(sc.fixed)+len(sc.sample)+len(sc.verified))
+	for id, op := range sc.fixed {
+		ops[id] = op
+	}
+	for _, op := range sc.sample {
+		ops[op.IDEstoque] = op
+	}
+	for _, op := range sc.verified {
+		ops[op.IDEstoque] = op
+	}
+	for id := range sc.keys {
+		if _, ok := ops[id]; !ok {
+			stats.NotSynced = append(stats.NotSynced, id)
+		}
+	}
+	sort.Ints(stats.NotSynced)
+
+	keys := make([]int, 0, len(ops))
+	for id := range ops {
+		keys = append(keys, id)
+	}
+	sort.Ints(keys)
+
+	stored := make(map[int]mysqlRecord, len(keys))
+	for start := 0; start < len(keys); start += spotCheckChunk {
+		chunk := keys[start:min(start+spotCheckChunk, len(keys))]
+		if err := readBack(ctx, db, cfg, columns, chunk, stored); err != nil {
+			return nil, fmt.Errorf("error reading spot-checked rows: %w", err)
+		}
+	}
+
+	failed := make(map[int]bool)
+	for _, id := range keys {
+		op := ops[id]
+		rec, ok := stored[id]
+		stats.Checked++
+		if !ok {
+			stats.Missing = append(stats.Missing, id)
+			failed[id] = true
+			continue
+		}
+		for _, c := range columns {
+			if !c.equal(&rec, &op) {
+				stats.Mismatches = append(stats.Mismatches, SpotMismatch{
+					IDEstoque: id,
+					Column:    c.column,
+					Stored:    compare.Format(c.stored(&rec)),
+					Expected:  compare.Format(c.value(&op)),
+				})
+				failed[id] = true
+			}
+		}
+	}
+	for _, op := range sc.verified {
+		if failed[op.IDEstoque] {
+			stats.Failed++
+		}
+	}
+	if sc.fraction > 0 {
+		rate, low, high := stats.ErrorRate()
+		log.Info().
+			Int("written", stats.Written).
+			Int("sampled", stats.Sampled).
+			Int("failed", stats.Failed).
+			Float64("error_rate", rate).
+			Float64("error_rate_low", low).
+			Float64("error_rate_high", high).
+			Msg("Read-back verification sample checked")
+	}
+
+	if stats.OK() {
+		log.Info().Int("checked", stats.Checked).Msg("Spot checks passed")
+	} else {
+		log.Warn().Int("checked", stats.Checked).Ints("missing", stats.Missing).Int("mismatches", len(stats.Mismatches)).Msg("Spot checks found rows not holding the computed values")
+	}
+	return stats, nil
+}
+
+// readBack loads the given columns of the TB_ESTOQUE rows with keys into stored
+func readBack(ctx context.Context, db *sql.DB, cfg config.Config, columns []productColumn, keys []int, stored map[int]mysqlRecord) error {
+	args := make([]interface{}, len(keys))
+	for i, id := range keys {
+		args[i] = id
+	}
+	query := selectProducts(cfg, columns) + " WHERE " + productKeyColumn(cfg) + " IN (?" + strings.Repeat(", ?", len(keys)-1) + ")"
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	return scanProducts(rows, cfg, columns, func(key int, rec *mysqlRecord) {
+		stored[key] = *rec
+	})
+}
@@ -0,0 +1,36 @@
+package processor
+
+import "testing"
+
+func TestSpotCheckReadsRowsBack(t *testing.T) {
+	cfg, firebirdDB, mysqlDB := devDatabases(t, map[string]string{"SPOT_CHECK_IDS": "1,2,100", "VERIFY_SAMPLE": "100"})
+	// MySQL changes products 2 and 3 behind the run's back
+	execAll(t, mysqlDB, "CREATE TRIGGER tamper AFTER INSERT ON TB_ESTOQUE WHEN NEW.ID_ESTOQUE IN (2, 3) BEGIN UPDATE TB_ESTOQUE SET DESCRICAO = 'tampered' WHERE ID_ESTOQUE = NEW.ID_ESTOQUE; END")
+
+	_, _, _, stats := syncDev(t, cfg, firebirdDB, mysqlDB)
+	sc := stats.SpotChecks
+	if sc == nil {
+		t.Fatal("no spot check run")
+	}
+	if sc.OK() || len(sc.Missing) != 0 {
+		t.Errorf("OK() = %v, missing %v; want the tampered rows reported", sc.OK(), sc.Missing)
+	}
+	tampered := map[int]bool{}
+	for _, m := range sc.Mismatches {
+		if m.Column != "DESCRICAO" || m.Stored != "tampered" {
+			t.Errorf("mismatch %+v; want DESCRICAO stored as tampered", m)
+		}
+		tampered[m.IDEstoque] = true
+	}
+	if len(tampered) != 2 || !tampered[2] || !tampered[3] {
+		t.Errorf("mismatches %+v; want products 2 and 3", sc.Mismatches)
+	}
+	// Product 100 is inactive in Firebird and not read
+	if len(sc.NotSynced) != 1 || sc.NotSynced[0] != 100 {
+		t.Errorf("NotSynced = %v; want [100]", sc.NotSynced)
+	}
+	// VERIFY_SAMPLE=100 reads back every written row besides SPOT_CHECK_IDS
+	if sc.Written != 4 || sc.Sampled != 4 || sc.Failed != 1 {
+		t.Errorf("written, sampled, failed = %d, %d, %d; want 4, 4, 1", sc.Written, sc.Sampled, sc.Failed)
+	}
+}
@@ -0,0 +1,34 @@
+package processor
+
+import (
+	"github.com/waldirborbajr/sync/config"
+)
+
+// applyStockPolicy applies STOCK_POLICY to rows whose quantity is zero or
+// negative. It reports whether the row was handled by the policy. Under the
+// hide policy every row carries the visibility flag, so restocked products
+// become visible again.
+func applyStockPolicy(op *RowOperation, cfg config.Config) bool {
+	op.Visivel = 1
+	if op.QtdAtual > 0 {
+		return false
+	}
+
+	switch cfg.StockPolicy {
+	case config.StockZero:
+		op.QtdAtual = 0
+	case config.StockHide:
+		op.QtdAtual = 0
+		op.Visivel = 0
+	case config.StockSkip:
+		op.stockSkipped = true
+	default:
+		return false
+	}
+	return true
+}
+
+// hidesStock reports whether writes must include the visibility flag column
+func hidesStock(cfg config.Config) bool {
+	return cfg.StockPolicy == config.StockHide
+}
@@ -0,0 +1,384 @@
+package processor
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/waldirborbajr/sync/compare"
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/db"
+	"github.com/waldirborbajr/sync/logger"
+	"github.com/waldirborbajr/sync/run"
+	"github.com/waldirborbajr/sync/transfer"
+)
+
+// tableBatchSize is the number of rows per insert statement and update transaction of mapped tables
+const tableBatchSize = 500
+
+// TableStats reports the sync of one configured table mapping
+type TableStats struct {
+	Name     string
+	Target   string
+	Rows     int
+	Inserted int
+	Updated  int
+	Ignored  int // Rows already up to date
+	NullKeys int // Source rows skipped because a key column is NULL
+	Duration time.Duration
+}
+
+// tableWriter batches the inserts and updates of one mapped table
+type tableWriter struct {
+	db      *sql.DB
+	mapping config.TableMapping
+	inserts [][]interface{}
+	updates [][]interface{}
+	stats   *TableStats
+	retry   *transfer.Retrier
+	limit   int // Bytes of values a single insert statement may carry
+}
+
+// syncTables syncs every configured table mapping in order. They run before
+// TB_ESTOQUE so reference tables such as groups exist when products are written.
+// limit is the transfer.StatementLimit of MySQL.
+func syncTables(ctx context.Context, firebirdDB, mysqlDB *sql.DB, mappings []config.TableMapping, retry *transfer.Retrier, limit int) ([]TableStats, error) {
+	log := logger.GetLogger()
+
+	var all []TableStats
+	for _, m := range mappings {
+		ts, err := syncTable(ctx, firebirdDB, mysqlDB, m, m.SourceQuery, retry, limit)
+		if err != nil {
+			return all, fmt.Errorf("error syncing table %s: %w", m.Name, err)
+		}
+		log.Info().
+			Str("table", m.Name).
+			Str("target", m.Target()).
+			Int("rows", ts.Rows).
+			Int("inserted", ts.Inserted).
+			Int("updated", ts.Updated).
+			Int("ignored", ts.Ignored).
+			Dur("duration", ts.Duration).
+			Msg("Table synced")
+		all = append(all, ts)
+	}
+	return all, nil
+}
+
+// syncTable copies the rows of a source query of the mapping, run with args,
+// into its target table, inserting new keys and updating rows whose mapped
+// columns differ
+func syncTable(ctx context.Context, firebirdDB, mysqlDB *sql.DB, m config.TableMapping, query string, retry *transfer.Retrier, limit int, args ...interface{}) (TableStats, error) {
+	start := time.Now()
+	ts := TableStats{Name: m.Name, Target: m.Target()}
+	keyIdx := m.KeyIndexes()
+
+	existing, err := loadTargetRows(ctx, mysqlDB, m)
+	if err != nil {
+		return ts, fmt.Errorf("error loading %s: %w", m.Target(), err)
+	}
+	run.Touch(ctx)
+
+	rows, err := firebirdDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return ts, fmt.Errorf("error querying source: %w", err)
+	}
+	defer rows.Close()
+	run.Touch(ctx)
+
+	names, err := rows.Columns()
+	if err != nil {
+		return ts, err
+	}
+	positions, err := sourcePositions(names, m.Columns)
+	if err != nil {
+		return ts, err
+	}
+
+	comparators, err := tableComparators(m)
+	if err != nil {
+		return ts, err
+	}
+
+	w := &tableWriter{db: mysqlDB, mapping: m, stats: &ts, retry: retry, limit: limit}
+	raw := make([]interface{}, len(names))
+	dest := make([]interface{}, len(names))
+	for i := range raw {
+		dest[i] = &raw[i]
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return ts, fmt.Errorf("error scanning source row: %w", err)
+		}
+		ts.Rows++
+
+		values := make([]interface{}, len(m.Columns))
+		for i, pos := range positions {
+			values[i] = normalizeValue(raw[pos])
+		}
+		key, ok := rowKey(values, keyIdx)
+		if !ok {
+			ts.NullKeys++
+			continue
+		}
+
+		current, exists := existing[key]
+		switch {
+		case !exists:
+			w.inserts = append(w.inserts, values)
+		case rowsEqual(comparators, current, values):
+			ts.Ignored++
+		default:
+			w.updates = append(w.updates, values)
+		}
+
+		if len(w.inserts) >= tableBatchSize || len(w.updates) >= tableBatchSize {
+			if err := w.flush(ctx); err != nil {
+				return ts, err
+			}
+		}
+		run.Touch(ctx)
+	}
+	if err := rows.Err(); err != nil {
+		return ts, err
+	}
+	if err := w.flush(ctx); err != nil {
+		return ts, err
+	}
+
+	ts.Duration = time.Since(start)
+	return ts, nil
+}
+
+// BackfillTable syncs the rows the mapping's backfill query returns for the
+// range [from, to) into its target table, as a regular run syncs the rows
+// of its query
+func BackfillTable(ctx context.Context, firebirdDB, mysqlDB *sql.DB, m config.TableMapping, from, to time.Time, cfg config.Config) (TableStats, error) {
+	if m.BackfillQuery == "" {
+		return TableStats{}, fmt.Errorf("%s is not set", config.TableKey(m.Name, "BACKFILL_QUERY"))
+	}
+	return syncTable(ctx, firebirdDB, mysqlDB, m, m.BackfillQuery, transfer.NewRetrier(cfg), transfer.StatementLimit(db.MaxAllowedPacket(mysqlDB, cfg)), from, to)
+}
+
+// loadTargetRows loads the mapped columns of the target table keyed by rowKey
+func loadTargetRows(ctx context.Context, db *sql.DB, m config.TableMapping) (map[string][]interface{}, error) {
+	targets := make([]string, len(m.Columns))
+	for i, c := range m.Columns {
+		targets[i] = c.Target
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT "+strings.Join(targets, ", ")+" FROM "+m.QuotedTarget()+" WHERE "+keyCondition(m.KeyColumns, " IS NOT NULL"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keyIdx := m.KeyIndexes()
+	existing := make(map[string][]interface{})
+	for rows.Next() {
+		values := make([]interface{}, len(targets))
+		dest := make([]interface{}, len(targets))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		for i := range values {
+			values[i] = normalizeValue(values[i])
+		}
+		if key, ok := rowKey(values, keyIdx); ok {
+			existing[key] = values
+		}
+	}
+	return existing, rows.Err()
+}
+
+// sourcePositions returns the position of every mapped source column in the
+// query result; column names are matched case-insensitively
+func sourcePositions(names []string, columns []config.ColumnMapping) ([]int, error) {
+	positions := make([]int, len(columns))
+	for i, c := range columns {
+		positions[i] = -1
+		for j, name := range names {
+			if strings.EqualFold(name, c.Source) {
+				positions[i] = j
+				break
+			}
+		}
+		if positions[i] < 0 {
+			return nil, fmt.Errorf("source query has no column %s (columns: %s)", c.Source, strings.Join(names, ", "))
+		}
+	}
+	return positions, nil
+}
+
+// flush writes the pending inserts and updates, each batch in one transaction
+func (w *tableWriter) flush(ctx context.Context) error {
+	if len(w.inserts) > 0 {
+		sortRowsByKey(w.inserts, w.mapping.KeyIndexes())
+		err := w.retry.Do(ctx, w.mapping.Target()+" insert", len(w.inserts), func() error { return w.insert(ctx, w.inserts) })
+		if err != nil {
+			return err
+		}
+		w.stats.Inserted += len(w.inserts)
+		w.inserts = w.inserts[:0]
+		run.Touch(ctx)
+	}
+	if len(w.updates) > 0 {
+		sortRowsByKey(w.updates, w.mapping.KeyIndexes())
+		err := w.retry.Do(ctx, w.mapping.Target()+" update", len(w.updates), func() error { return w.update(ctx, w.updates) })
+		if err != nil {
+			return err
+		}
+		w.stats.Updated += len(w.updates)
+		w.updates = w.updates[:0]
+		run.Touch(ctx)
+	}
+	return nil
+}
+
+// insert writes rows in one transaction, with multi-value INSERTs that fit
+// max_allowed_packet
+func (w *tableWriter) insert(ctx context.Context, rows [][]interface{}) error {
+	columns := make([]string, len(w.mapping.Columns))
+	for i, c := range w.mapping.Columns {
+		columns[i] = c.Target
+	}
+
+	tx, err := w.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, chunk := range transfer.Chunk(rows, len(columns), w.limit, transfer.RowSize) {
+		values := make([]interface{}, 0, len(chunk)*len(columns))
+		for _, row := range chunk {
+			values = append(values, row...)
+		}
+		if _, err := tx.ExecContext(ctx, transfer.InsertStatement(w.mapping.QuotedTarget(), columns, len(chunk)), values...); err != nil {
+			return fmt.Errorf("bulk insert into %s failed: %w", w.mapping.Target(), err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("bulk insert commit failed: %w", err)
+	}
+	return nil
+}
+
+// update rewrites the non-key columns of rows in one transaction
+func (w *tableWriter) update(ctx context.Context, rows [][]interface{}) error {
+	keyIdx := w.mapping.KeyIndexes()
+	var set []string
+	for _, c := range w.mapping.Columns {
+		if !w.mapping.IsKey(c.Target) {
+			set = append(set, c.Target+" = ?")
+		}
+	}
+	if len(set) == 0 {
+		return nil // Only the key is mapped, rows can never differ
+	}
+
+	tx, err := w.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, "UPDATE "+w.mapping.QuotedTarget()+" SET "+strings.Join(set, ", ")+" WHERE "+keyCondition(w.mapping.KeyColumns, " = ?"))
+	if err != nil {
+		return fmt.Errorf("error preparing update statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, row := range rows {
+		args := make([]interface{}, 0, len(row))
+		for i, v := range row {
+			if !w.mapping.IsKey(w.mapping.Columns[i].Target) {
+				args = append(args, v)
+			}
+		}
+		key := make([]interface{}, len(keyIdx))
+		for i, idx := range keyIdx {
+			key[i] = row[idx]
+		}
+		if _, err := stmt.ExecContext(ctx, append(args, key...)...); err != nil {
+			return fmt.Errorf("update of %s %v failed: %w", w.mapping.Target(), key, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("bulk update commit failed: %w", err)
+	}
+	return nil
+}
+
+// rowKey returns the key of a mapped row, its formatted key column values,
+// so a key read from Firebird matches the one stored in MySQL whatever the
+// driver types, while text keys such as "007" and "7" stay apart; ok is
+// false when a key column is NULL
+func rowKey(values []interface{}, keyIdx []int) (key string, ok bool) {
+	parts := make([]string, len(keyIdx))
+	for i, idx := range keyIdx {
+		if values[idx] == nil {
+			return "", false
+		}
+		parts[i] = compare.Format(values[idx])
+	}
+	return strings.Join(parts, "\x00"), true
+}
+
+// keyCondition returns the WHERE condition applying cond (" = ?") to every key column
+func keyCondition(keys []string, cond string) string {
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = key + cond
+	}
+	return strings.Join(parts, " AND ")
+}
+
+// normalizeValue converts driver values into comparable Go values: byte
+// slices become strings and the trailing blanks of Firebird CHAR columns are
+// removed
+func normalizeValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case []byte:
+		return strings.TrimRight(string(x), " ")
+	case string:
+		return strings.TrimRight(x, " ")
+	case fmt.Stringer:
+		return x.String() // Firebird NUMERIC/DECIMAL
+	}
+	return v
+}
+
+// tableComparators returns the comparator of every mapped column
+func tableComparators(m config.TableMapping) ([]compare.Comparator, error) {
+	comparators := make([]compare.Comparator, len(m.Columns))
+	for i, c := range m.Columns {
+		comparators[i] = compare.Exact
+		if spec, ok := m.Comparators[c.Target]; ok {
+			cmp, err := compare.New(spec)
+			if err != nil {
+				return nil, err
+			}
+			comparators[i] = cmp
+		}
+	}
+	return comparators, nil
+}
+
+// rowsEqual reports whether the stored row matches the current one, column by column
+func rowsEqual(comparators []compare.Comparator, stored, current []interface{}) bool {
+	for i, cmp := range comparators {
+		if !cmp.Equal(stored[i], current[i]) {
+			return false
+		}
+	}
+	return true
+}
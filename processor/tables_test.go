@@ -0,0 +1,47 @@
+package processor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/waldirborbajr/sync/transfer"
+)
+
+func TestSyncTableCompositeKey(t *testing.T) {
+	cfg, firebirdDB, mysqlDB := devDatabases(t, map[string]string{
+		"SYNC_TABLES":               "PRECOS",
+		"SYNC_TABLE_PRECOS_QUERY":   "SELECT ID_ESTOQUE, TABELA, VALOR FROM TB_PRECO",
+		"SYNC_TABLE_PRECOS_TARGET":  "TB_PRECO_WEB",
+		"SYNC_TABLE_PRECOS_KEY":     "ID_ESTOQUE,TABELA",
+		"SYNC_TABLE_PRECOS_COLUMNS": "ID_ESTOQUE,TABELA,VALOR",
+	})
+	// Text keys "007" and "7" are different price tables of product 1
+	execAll(t, firebirdDB,
+		"CREATE TABLE TB_PRECO (ID_ESTOQUE INTEGER, TABELA TEXT, VALOR REAL)",
+		"INSERT INTO TB_PRECO VALUES (1, '007', 10), (1, '7', 11), (2, '007', 12)",
+	)
+	execAll(t, mysqlDB,
+		"CREATE TABLE TB_PRECO_WEB (ID_ESTOQUE INTEGER, TABELA TEXT, VALOR REAL, PRIMARY KEY (ID_ESTOQUE, TABELA))",
+		"INSERT INTO TB_PRECO_WEB VALUES (1, '007', 99), (1, '7', 11)",
+	)
+
+	all, err := syncTables(context.Background(), firebirdDB, mysqlDB, cfg.SyncedTables(), transfer.NewRetrier(cfg), 1<<20)
+	if err != nil {
+		t.Fatalf("syncTables() error = %v", err)
+	}
+	if ts := all[0]; ts.Inserted != 1 || ts.Updated != 1 || ts.Ignored != 1 {
+		t.Errorf("inserted, updated, ignored = %d, %d, %d; want 1, 1, 1", ts.Inserted, ts.Updated, ts.Ignored)
+	}
+	for _, want := range []struct {
+		id     int
+		tabela string
+		valor  float64
+	}{{1, "007", 10}, {1, "7", 11}, {2, "007", 12}} {
+		if n := countRows(t, mysqlDB, "SELECT COUNT(*) FROM TB_PRECO_WEB WHERE ID_ESTOQUE = ? AND TABELA = ? AND VALOR = ?", want.id, want.tabela, want.valor); n != 1 {
+			t.Errorf("row %d/%s not stored with %v", want.id, want.tabela, want.valor)
+		}
+	}
+	if n := countRows(t, mysqlDB, "SELECT COUNT(*) FROM TB_PRECO_WEB"); n != 3 {
+		t.Errorf("%d rows in TB_PRECO_WEB; want 3", n)
+	}
+}
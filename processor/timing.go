@@ -0,0 +1,32 @@
+package processor
+
+import "time"
+
+// stageTimer accumulates the time spent in one stage of a run between
+// explicit start and stop calls. Readings of time.Now carry the monotonic
+// clock, so a wall clock stepped during the run (NTP) does not distort it.
+type stageTimer struct {
+	started time.Time
+	total   time.Duration
+}
+
+// start begins a measured span
+func (t *stageTimer) start() {
+	t.started = time.Now()
+}
+
+// stop ends the span begun by start and adds it to the total
+func (t *stageTimer) stop() {
+	if t.started.IsZero() {
+		return
+	}
+	t.total += time.Since(t.started)
+	t.started = time.Time{}
+}
+
+// measure runs fn as a span of the stage
+func (t *stageTimer) measure(fn func() error) error {
+	t.start()
+	defer t.stop()
+	return fn()
+}
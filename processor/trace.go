@@ -0,0 +1,331 @@
+package processor
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/waldirborbajr/sync/compare"
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/money"
+	"github.com/waldirborbajr/sync/state"
+	"github.com/waldirborbajr/sync/transfer"
+)
+
+// traceHistoryLimit caps the entries read from each history table by Trace
+const traceHistoryLimit = 20
+
+// TraceReport is everything known about one product, gathered by Trace
+type TraceReport struct {
+	IDEstoque  int
+	InFirebird bool          // Firebird TB_ESTOQUE has the key
+	Read       bool          // The run's source query returns the row
+	Stored     bool          // MySQL TB_ESTOQUE has the key
+	Firebird   []TraceValue  // Source values, as read by a run
+	Columns    []TraceColumn // MySQL columns: stored values and the values a run would write
+	Outcome    string        // What a run would do with the row now
+	Rules      []string      // Configuration that applies to the row
+	History    []TraceEvent  // Runs that changed the row, newest first
+}
+
+// TraceValue is a source column value of a traced row
+type TraceValue struct {
+	Column string
+	Value  string
+}
+
+// TraceColumn is a MySQL column of a traced row
+type TraceColumn struct {
+	Column   string
+	Stored   string
+	Expected string
+	Differs  bool
+}
+
+// TraceEvent is a change recorded for a traced row
+type TraceEvent struct {
+	At     time.Time // Zero when the table records none
+	RunID  string
+	Source string // Table the event comes from
+	Detail string
+}
+
+// Trace gathers what is known about the product id: its Firebird values, as
+// the run's source query reads them, its MySQL row, what a run would write
+// and why, and the changes recorded in the history tables the configuration
+// enables. Nothing is written; PRC_DOLAR is derived with the exchange rate
+// cached by the last run, as Verify does.
+func Trace(ctx context.Context, firebirdDB, mysqlDB *sql.DB, cfg config.Config, id int) (*TraceReport, error) {
+	t := &TraceReport{IDEstoque: id}
+
+	var status sql.NullString
+	switch err := firebirdDB.QueryRowContext(ctx, "SELECT STATUS FROM TB_ESTOQUE WHERE ID_ESTOQUE = ?", id).Scan(&status); err {
+	case nil:
+		t.InFirebird = true
+	case sql.ErrNoRows:
+	default:
+		return nil, fmt.Errorf("error reading Firebird TB_ESTOQUE: %w", err)
+	}
+
+	var rows []sourceRow
+	if t.InFirebird {
+		if _, err := readSourceRange(ctx, firebirdDB, cfg, time.Time{}, &keyRange{first: id, last: id}, transfer.NewRetrier(cfg), &rows); err != nil {
+			return nil, fmt.Errorf("error querying Firebird: %w", err)
+		}
+	}
+
+	cfg.MySQLPreload = config.PreloadColumns
+	lk := &lookups{columns: productColumns(cfg), existing: make(map[int]mysqlRecord, 1)}
+	if err := readBack(ctx, mysqlDB, cfg, lk.columns, []int{id}, lk.existing); err != nil {
+		return nil, fmt.Errorf("error reading MySQL TB_ESTOQUE: %w", err)
+	}
+	rec, stored := lk.existing[id]
+	t.Stored = stored
+
+	switch {
+	case !t.InFirebird && stored:
+		t.Outcome = "none: not in Firebird, the MySQL row is an orphan"
+		if softDeleted(cfg, &rec) {
+			t.Outcome = "none: not in Firebird, marked deleted in " + cfg.SoftDeleteColumn
+		}
+	case !t.InFirebird:
+		t.Outcome = "none: in neither database"
+	case len(rows) == 0:
+		t.Outcome = "none: not read by the source query"
+		switch {
+		case status.Valid && strings.TrimSpace(status.String) != "A" && !mapsStatus(cfg) && !cfg.FiltersStatus():
+			t.Outcome += fmt.Sprintf(", STATUS is %q and only active products are read", strings.TrimSpace(status.String))
+		case cfg.RowFilterMode == config.FilterSQL && len(cfg.RowFilters) > 0:
+			t.Outcome += ", ROW_FILTERS exclude it"
+		default:
+			t.Outcome += ", it has no TB_EST_PRODUTO row"
+		}
+	}
+	if stored {
+		for _, c := range lk.columns {
+			t.Columns = append(t.Columns, TraceColumn{Column: c.column, Stored: compare.Format(c.stored(&rec))})
+		}
+	}
+	if len(rows) == 1 {
+		if err := t.compute(ctx, mysqlDB, cfg, lk, rows[0]); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := t.readHistory(ctx, mysqlDB, cfg); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// compute records the source values of src, the values a run would write
+// and the rules that decided them
+func (t *TraceReport) compute(ctx context.Context, mysqlDB *sql.DB, cfg config.Config, lk *lookups, src sourceRow) error {
+	t.Read = true
+	t.Firebird = []TraceValue{
+		{"DESCRICAO", src.Descricao},
+		{"QTD_ATUAL", compare.Format(src.QtdAtual)},
+		{"PRC_CUSTO", formatNullCents(src.PrcCusto)},
+		{"PRC_DOLAR", formatNullCents(src.PrcDolar)},
+		{"ID_GRUPO", formatNullable(src.HasGrupo, src.IDGrupo)},
+		{"STATUS", formatNullable(src.HasStatus, src.Status)},
+	}
+	if incremental(cfg) {
+		t.Firebird = append(t.Firebird, TraceValue{cfg.IncrementalColumn, compare.Format(src.Modified)})
+	}
+	if cfg.UsesSupplier() {
+		t.Firebird = append(t.Firebird, TraceValue{cfg.PricingSupplierColumn, formatNullable(src.HasSupplier, src.Supplier)})
+	}
+
+	if filteredOut(cfg, src) {
+		t.Outcome = "none: ROW_FILTERS exclude it"
+		return nil
+	}
+
+	var err error
+	if !cfg.QuantityOnly() {
+		if lk.protected, err = loadProtectedKeys(ctx, mysqlDB, cfg.ProtectedRowsQuery); err != nil {
+			return err
+		}
+		if cfg.ExchangeRateProvider != "" {
+			if st, err := state.Load(cfg.StateFile); err == nil {
+				lk.usdRate = st.Caches.ExchangeRate
+			}
+		}
+	}
+	if lk.reserved, err = loadReservations(ctx, mysqlDB, cfg.ReservationsQuery); err != nil {
+		return err
+	}
+
+	var op RowOperation
+	if cfg.QuantityOnly() {
+		op = processQuantityRow(lk, src, cfg)
+		t.Rules = append(t.Rules, "SYNC_MODE=quantity: only the quantity columns are written")
+	} else {
+		op = processRowOptimized(lk, src, cfg)
+		t.Rules = append(t.Rules, pricingDescription(op))
+	}
+
+	switch {
+	case op.statusUnmapped:
+		t.Outcome = fmt.Sprintf("skip: STATUS %q has no STATUS_MAP entry", src.Status)
+	case op.stockSkipped:
+		t.Outcome = "skip: STOCK_POLICY=skip and the quantity is not positive"
+	case op.deferred:
+		t.Outcome = "skip: new products are left to the next full run"
+	case op.Type == OpInsert:
+		t.Outcome = "insert"
+	case op.Type == OpUpdate:
+		t.Outcome = "update"
+	default:
+		t.Outcome = "none: MySQL holds the values a run would write"
+	}
+
+	if op.QtdReservada != 0 {
+		t.Rules = append(t.Rules, fmt.Sprintf("RESERVATIONS_QUERY: %s reserved of %s", compare.Format(op.QtdReservada), compare.Format(op.QtdOrigem)))
+	}
+	if op.stockPolicy {
+		t.Rules = append(t.Rules, "STOCK_POLICY="+cfg.StockPolicy+" applied to the quantity")
+	}
+	if op.Status != "" {
+		t.Rules = append(t.Rules, fmt.Sprintf("STATUS_MAP: %s written as %s", src.Status, op.Status))
+	}
+	if op.dollarDerived {
+		t.Rules = append(t.Rules, fmt.Sprintf("PRC_DOLAR derived from PRC_CUSTO at the cached rate %g", lk.usdRate))
+	}
+	if op.priceProtected {
+		t.Rules = append(t.Rules, "PROTECTED_ROWS_QUERY: the stored sale prices are kept")
+	}
+	for _, v := range []struct {
+		bit  constraintViolation
+		name string
+	}{{violationMinMargin, "PRICE_MIN_MARGIN"}, {violationFloor, "PRICE_FLOORS"}, {violationMaxDrop, "PRICE_MAX_DROP"}} {
+		if op.violations&v.bit != 0 {
+			t.Rules = append(t.Rules, fmt.Sprintf("%s violated, PRICE_CONSTRAINT_POLICY=%s", v.name, cfg.PriceConstraintPolicy))
+		}
+	}
+	if op.exprFailed {
+		t.Rules = append(t.Rules, "a PRODUCT_EXTRA_COLUMNS or PRODUCT_TRANSFORMS expression failed")
+	}
+	if softDeleted(cfg, op.existing) {
+		t.Rules = append(t.Rules, "marked deleted in "+cfg.SoftDeleteColumn+", restored by the next run")
+	}
+
+	if t.Outcome == "insert" || t.Outcome == "update" || strings.HasPrefix(t.Outcome, "none") {
+		for i, c := range lk.columns {
+			expected := compare.Format(c.value(&op))
+			if !t.Stored {
+				t.Columns = append(t.Columns, TraceColumn{Column: c.column, Expected: expected, Differs: true})
+				continue
+			}
+			t.Columns[i].Expected = expected
+			t.Columns[i].Differs = slices.Contains(op.changedColumns, c.column)
+		}
+	}
+	return nil
+}
+
+// pricingDescription names the margins the prices of op were calculated with
+func pricingDescription(op RowOperation) string {
+	m := op.margins
+	source := "global margins"
+	if op.pricingRule != "" {
+		source = "PRICING_RULES_FILE rule " + op.pricingRule
+	}
+	return fmt.Sprintf("%s: LUCRO %g, PARC3X %g, PARC6X %g, PARC10X %g", source, m.Lucro, m.Parc3x, m.Parc6x, m.Parc10x)
+}
+
+// readHistory reads the changes of the row recorded in TB_ESTOQUE_SYNC_AUDIT,
+// TB_PRECO_HISTORICO and TB_SYNC_ALTERADOS, the latest of each
+func (t *TraceReport) readHistory(ctx context.Context, mysqlDB *sql.DB, cfg config.Config) error {
+	limit := fmt.Sprintf(" LIMIT %d", traceHistoryLimit)
+	if cfg.AuditEnabled {
+		err := t.readEvents(ctx, mysqlDB, "TB_ESTOQUE_SYNC_AUDIT", `SELECT DT_ALTERACAO, RUN_ID, OPERACAO, COLUNAS, VALORES_ANTERIORES, VALORES_NOVOS
+			FROM TB_ESTOQUE_SYNC_AUDIT WHERE ID_ESTOQUE = ? ORDER BY DT_ALTERACAO DESC, ID DESC`+limit,
+			func(v []sql.NullString) string {
+				if v[2].String == "" {
+					return fmt.Sprintf("%s %s: %s", v[0].String, v[1].String, v[3].String)
+				}
+				return fmt.Sprintf("%s %s: %s -> %s", v[0].String, v[1].String, v[2].String, v[3].String)
+			})
+		if err != nil {
+			return err
+		}
+	}
+	if cfg.PriceHistoryEnabled {
+		err := t.readEvents(ctx, mysqlDB, "TB_PRECO_HISTORICO", `SELECT DT_ALTERACAO, RUN_ID, PRC_CUSTO_ANTERIOR, PRC_CUSTO_NOVO, PRC_VENDA_ANTERIOR, PRC_VENDA_NOVO
+			FROM TB_PRECO_HISTORICO WHERE ID_ESTOQUE = ? ORDER BY DT_ALTERACAO DESC, ID DESC`+limit,
+			func(v []sql.NullString) string {
+				return fmt.Sprintf("PRC_CUSTO %s -> %s, PRC_VENDA %s -> %s", v[0].String, v[1].String, v[2].String, v[3].String)
+			})
+		if err != nil {
+			return err
+		}
+	}
+	if cfg.ChangedKeysEnabled {
+		rows, err := mysqlDB.QueryContext(ctx, "SELECT RUN_ID FROM TB_SYNC_ALTERADOS WHERE ID_ESTOQUE = ?", t.IDEstoque)
+		if err != nil {
+			return fmt.Errorf("error reading TB_SYNC_ALTERADOS: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var runID string
+			if err := rows.Scan(&runID); err != nil {
+				return fmt.Errorf("error reading TB_SYNC_ALTERADOS: %w", err)
+			}
+			// Runs already in another history carry more detail there
+			if !slices.ContainsFunc(t.History, func(e TraceEvent) bool { return e.RunID == runID }) {
+				t.History = append(t.History, TraceEvent{RunID: runID, Source: "TB_SYNC_ALTERADOS", Detail: "changed"})
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("error reading TB_SYNC_ALTERADOS: %w", err)
+		}
+	}
+
+	slices.SortStableFunc(t.History, func(a, b TraceEvent) int { return b.At.Compare(a.At) })
+	return nil
+}
+
+// readEvents appends the events query returns for the row: its time, run
+// ID and four values, which detail describes
+func (t *TraceReport) readEvents(ctx context.Context, mysqlDB *sql.DB, table, query string, detail func([]sql.NullString) string) error {
+	rows, err := mysqlDB.QueryContext(ctx, query, t.IDEstoque)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", table, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var at sql.NullTime
+		var runID string
+		values := make([]sql.NullString, 4)
+		if err := rows.Scan(&at, &runID, &values[0], &values[1], &values[2], &values[3]); err != nil {
+			return fmt.Errorf("error reading %s: %w", table, err)
+		}
+		t.History = append(t.History, TraceEvent{At: at.Time, RunID: runID, Source: table, Detail: detail(values)})
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error reading %s: %w", table, err)
+	}
+	return nil
+}
+
+// formatNullCents formats an amount, empty for NULL
+func formatNullCents(n money.NullCents) string {
+	if !n.Valid {
+		return ""
+	}
+	return n.Cents.String()
+}
+
+// formatNullable formats v, empty when it is NULL
+func formatNullable[T cmp.Ordered](valid bool, v T) string {
+	if !valid {
+		return ""
+	}
+	return compare.Format(v)
+}
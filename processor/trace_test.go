@@ -0,0 +1,63 @@
+package processor
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/waldirborbajr/sync/run"
+)
+
+func TestTrace(t *testing.T) {
+	cfg, firebirdDB, mysqlDB := devDatabases(t, map[string]string{"AUDIT_ENABLED": "true", "LUCRO": "40"})
+	if _, _, _, _, _, err := ProcessRows(run.WithID(context.Background(), "run-1"), firebirdDB, mysqlDB, 2, cfg); err != nil {
+		t.Fatalf("ProcessRows() error = %v", err)
+	}
+	execAll(t, mysqlDB,
+		"UPDATE TB_ESTOQUE SET DESCRICAO = 'edited' WHERE ID_ESTOQUE = 2",
+		"INSERT INTO TB_ESTOQUE (ID_ESTOQUE, DESCRICAO) VALUES (555, 'orphan')",
+	)
+
+	tests := []struct {
+		id      int
+		outcome string
+		read    bool
+		stored  bool
+		history int
+	}{
+		{1, "none: MySQL holds the values a run would write", true, true, 1},
+		{2, "update", true, true, 1},
+		{100, `none: not read by the source query, STATUS is "I" and only active products are read`, false, false, 0},
+		{555, "none: not in Firebird, the MySQL row is an orphan", false, true, 0},
+		{999, "none: in neither database", false, false, 0},
+	}
+	for _, tt := range tests {
+		tr, err := Trace(context.Background(), firebirdDB, mysqlDB, cfg, tt.id)
+		if err != nil {
+			t.Fatalf("Trace(%d) error = %v", tt.id, err)
+		}
+		if tr.Outcome != tt.outcome || tr.Read != tt.read || tr.Stored != tt.stored {
+			t.Errorf("Trace(%d) = %q, read %v, stored %v; want %q, %v, %v", tt.id, tr.Outcome, tr.Read, tr.Stored, tt.outcome, tt.read, tt.stored)
+		}
+		if len(tr.History) != tt.history {
+			t.Errorf("Trace(%d) history = %+v; want %d events", tt.id, tr.History, tt.history)
+		} else if tt.history > 0 && tr.History[0].RunID != "run-1" {
+			t.Errorf("Trace(%d) history = %+v; want the insert of run-1", tt.id, tr.History)
+		}
+	}
+
+	// The edited column is told apart from the others
+	tr, err := Trace(context.Background(), firebirdDB, mysqlDB, cfg, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var differs []string
+	for _, c := range tr.Columns {
+		if c.Differs {
+			differs = append(differs, c.Column)
+		}
+	}
+	if strings.Join(differs, ",") != "DESCRICAO" {
+		t.Errorf("differing columns = %v; want DESCRICAO", differs)
+	}
+}
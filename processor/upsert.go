@@ -0,0 +1,13 @@
+package processor
+
+import (
+	"github.com/waldirborbajr/sync/transfer"
+)
+
+// chunkOps splits ops into groups whose multi-row statement stays within the
+// packet and placeholder limits
+func (w *writer) chunkOps(ops []RowOperation) [][]RowOperation {
+	return transfer.Chunk(ops, len(w.columns)+1, w.packetLimit, func(op RowOperation) int {
+		return transfer.EstimateSize(op.IDEstoque) + transfer.RowSize(w.productValues(op))
+	})
+}
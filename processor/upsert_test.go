@@ -0,0 +1,53 @@
+package processor
+
+import "testing"
+
+func TestUpsertWritesRouteInserts(t *testing.T) {
+	tests := []struct {
+		name      string
+		upsertAll bool
+		unwritten int64
+		desc      string
+	}{
+		// A row inserted since the preload makes the insert batch fail
+		{"separate inserts", false, 2, "stored"},
+		// The upsert writes over it instead
+		{"upsert writes", true, 0, "read"},
+	}
+	for _, tt := range tests {
+		cfg, _, mysqlDB := devDatabases(t, map[string]string{"BATCH_RETRIES": "0"})
+		execAll(t, mysqlDB, "INSERT INTO TB_ESTOQUE (ID_ESTOQUE, DESCRICAO) VALUES (1, 'stored')")
+
+		w := devWriter(cfg, mysqlDB)
+		w.upsert, w.upsertAll = true, tt.upsertAll
+		writeOps(w, RowOperation{Type: OpInsert, IDEstoque: 1, Descricao: "read"}, RowOperation{Type: OpInsert, IDEstoque: 2, Descricao: "read"})
+
+		if got := w.unwritten.Load(); got != tt.unwritten {
+			t.Errorf("%s: unwritten = %d; want %d", tt.name, got, tt.unwritten)
+		}
+		if n := countRows(t, mysqlDB, "SELECT COUNT(*) FROM TB_ESTOQUE WHERE ID_ESTOQUE = 1 AND DESCRICAO = ?", tt.desc); n != 1 {
+			t.Errorf("%s: row 1 not left with %q", tt.name, tt.desc)
+		}
+	}
+}
+
+func TestBatchedUpsertsUpdateRows(t *testing.T) {
+	for _, upsert := range []bool{false, true} {
+		cfg, _, mysqlDB := devDatabases(t, nil)
+		execAll(t, mysqlDB, "INSERT INTO TB_ESTOQUE (ID_ESTOQUE, DESCRICAO) VALUES (1, 'stored'), (2, 'stored'), (3, 'stored')")
+
+		w := devWriter(cfg, mysqlDB)
+		w.upsert = upsert
+		_, updated := writeOps(w, RowOperation{Type: OpUpdate, IDEstoque: 2, Descricao: "read"}, RowOperation{Type: OpUpdate, IDEstoque: 1, Descricao: "read"})
+
+		if updated != 2 {
+			t.Errorf("upsert %v: updated = %d; want 2", upsert, updated)
+		}
+		if n := countRows(t, mysqlDB, "SELECT COUNT(*) FROM TB_ESTOQUE WHERE DESCRICAO = 'read'"); n != 2 {
+			t.Errorf("upsert %v: %d rows updated; want 2", upsert, n)
+		}
+		if n := countRows(t, mysqlDB, "SELECT COUNT(*) FROM TB_ESTOQUE"); n != 3 {
+			t.Errorf("upsert %v: %d rows; want the 3 stored", upsert, n)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package processor
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/waldirborbajr/sync/compare"
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/logger"
+	"github.com/waldirborbajr/sync/state"
+	"github.com/waldirborbajr/sync/transfer"
+)
+
+// VerifyReport is the drift between Firebird and MySQL found by Verify
+type VerifyReport struct {
+	SourceRows int              // Firebird rows read, ROW_FILTERS applied
+	StoredRows int              // MySQL TB_ESTOQUE rows
+	Matching   int              // Rows holding the values a run would write
+	Skipped    int              // Rows a run leaves alone (unmapped status, STOCK_POLICY=skip)
+	Missing    []int            // Firebird keys absent from MySQL, sorted
+	Orphaned   []int            // MySQL keys not read from Firebird nor marked deleted in SOFT_DELETE_COLUMN, sorted
+	Mismatched int              // Rows with at least one mismatching column
+	Mismatches []VerifyMismatch // Mismatching columns, by key and column order
+	Duration   time.Duration
+}
+
+// VerifyMismatch is a stored column value differing from the value a run would write
+type VerifyMismatch struct {
+	IDEstoque int
+	Column    string
+	Stored    string
+	Expected  string
+}
+
+// Drift returns the number of rows out of sync: missing, orphaned and mismatched
+func (r *VerifyReport) Drift() int {
+	return len(r.Missing) + len(r.Orphaned) + r.Mismatched
+}
+
+// Verify compares every Firebird product with its MySQL row, as a run of
+// cfg would compute it, without writing anything. Numbers differing by at
+// most tolerance match even when the column comparator says otherwise.
+// The watermark is ignored, every row is read. PRC_DOLAR is derived with the
+// exchange rate cached by the last run, none is fetched.
+func Verify(ctx context.Context, firebirdDB, mysqlDB *sql.DB, cfg config.Config, tolerance float64) (*VerifyReport, error) {
+	log := logger.GetLogger()
+	start := time.Now()
+
+	within, err := compare.New("tolerance=" + strconv.FormatFloat(tolerance, 'f', -1, 64))
+	if err != nil {
+		return nil, err
+	}
+
+	// Mismatching columns are told apart by their stored values
+	cfg.MySQLPreload = config.PreloadColumns
+	lk := &lookups{columns: productColumns(cfg)}
+	if err := lk.load(mysqlDB, cfg); err != nil {
+		return nil, fmt.Errorf("error loading MySQL records: %w", err)
+	}
+	if !cfg.QuantityOnly() {
+		if lk.protected, err = loadProtectedKeys(ctx, mysqlDB, cfg.ProtectedRowsQuery); err != nil {
+			return nil, err
+		}
+		if cfg.ExchangeRateProvider != "" {
+			st, err := state.Load(cfg.StateFile)
+			if err != nil {
+				log.Warn().Err(err).Msg("Could not read the cached exchange rate")
+			}
+			lk.usdRate = st.Caches.ExchangeRate
+		}
+	}
+	if lk.reserved, err = loadReservations(ctx, mysqlDB, cfg.ReservationsQuery); err != nil {
+		return nil, err
+	}
+
+	r := &VerifyReport{StoredRows: lk.len()}
+	read := make(map[int]struct{}, lk.len())
+	err = readSource(ctx, firebirdDB, cfg, time.Time{}, transfer.NewRetrier(cfg), &ProcessingStats{}, func(src sourceRow) error {
+		read[src.IDEstoque] = struct{}{}
+		if filteredOut(cfg, src) {
+			return nil
+		}
+		r.SourceRows++
+
+		var op RowOperation
+		if cfg.QuantityOnly() {
+			op = processQuantityRow(lk, src, cfg)
+		} else {
+			op = processRowOptimized(lk, src, cfg)
+		}
+		switch {
+		case op.statusUnmapped || op.stockSkipped:
+			r.Skipped++
+		case op.existing == nil:
+			r.Missing = append(r.Missing, src.IDEstoque)
+		case op.Type != OpUpdate:
+			r.Matching++
+		default:
+			r.observe(op, lk.columns, within)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r.Orphaned = lk.missingKeys(cfg, read)
+	slices.Sort(r.Missing)
+	slices.SortStableFunc(r.Mismatches, func(a, b VerifyMismatch) int { return cmp.Compare(a.IDEstoque, b.IDEstoque) })
+
+	r.Duration = time.Since(start)
+	log.Info().
+		Int("source_rows", r.SourceRows).
+		Int("stored_rows", r.StoredRows).
+		Int("missing", len(r.Missing)).
+		Int("orphaned", len(r.Orphaned)).
+		Int("mismatched", r.Mismatched).
+		Dur("duration", r.Duration).
+		Msg("Verification finished")
+	return r, nil
+}
+
+// observe records the columns of an update differing beyond the tolerance,
+// counting the row as matching when there are none
+func (r *VerifyReport) observe(op RowOperation, columns []productColumn, within compare.Comparator) {
+	mismatched := false
+	for _, c := range columns {
+		if !slices.Contains(op.changedColumns, c.column) {
+			continue
+		}
+		stored, expected := c.stored(op.existing), c.value(&op)
+		if within.Equal(stored, expected) {
+			continue
+		}
+		r.Mismatches = append(r.Mismatches, VerifyMismatch{
+			IDEstoque: op.IDEstoque,
+			Column:    c.column,
+			Stored:    compare.Format(stored),
+			Expected:  compare.Format(expected),
+		})
+		mismatched = true
+	}
+	if mismatched {
+		r.Mismatched++
+	} else {
+		r.Matching++
+	}
+}
@@ -0,0 +1,157 @@
+package processor
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/waldirborbajr/sync/logger"
+)
+
+// warehouseKey identifies a TB_ESTOQUE_DEPOSITO row
+type warehouseKey struct {
+	IDEstoque  int
+	IDDeposito int
+}
+
+// WarehouseStats counts the TB_ESTOQUE_DEPOSITO changes applied during the run
+type WarehouseStats struct {
+	Inserted int
+	Updated  int
+	Deleted  int
+	Orphans  int // Source rows skipped because the parent product is not in TB_ESTOQUE
+}
+
+// syncWarehouses syncs per-warehouse quantities into TB_ESTOQUE_DEPOSITO.
+// It runs after all TB_ESTOQUE writes so child rows never precede their
+// parent, and deletes child rows that no longer exist at the source.
+func syncWarehouses(ctx context.Context, firebirdDB, mysqlDB *sql.DB, query, keyColumn string) (WarehouseStats, error) {
+	var ws WarehouseStats
+	log := logger.GetLogger()
+
+	source, err := loadWarehouseRows(ctx, firebirdDB, query)
+	if err != nil {
+		return ws, fmt.Errorf("error running WAREHOUSE_QUERY: %w", err)
+	}
+	target, err := loadWarehouseRows(ctx, mysqlDB, "SELECT ID_ESTOQUE, ID_DEPOSITO, QTD_ATUAL FROM TB_ESTOQUE_DEPOSITO")
+	if err != nil {
+		return ws, fmt.Errorf("error loading TB_ESTOQUE_DEPOSITO: %w", err)
+	}
+	parents, err := loadParentKeys(ctx, mysqlDB, keyColumn)
+	if err != nil {
+		return ws, fmt.Errorf("error loading TB_ESTOQUE keys: %w", err)
+	}
+
+	tx, err := mysqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return ws, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	insertStmt, err := tx.PrepareContext(ctx, "INSERT INTO TB_ESTOQUE_DEPOSITO (ID_ESTOQUE, ID_DEPOSITO, QTD_ATUAL) VALUES (?, ?, ?)")
+	if err != nil {
+		return ws, fmt.Errorf("error preparing warehouse insert: %w", err)
+	}
+	defer insertStmt.Close()
+
+	updateStmt, err := tx.PrepareContext(ctx, "UPDATE TB_ESTOQUE_DEPOSITO SET QTD_ATUAL = ? WHERE ID_ESTOQUE = ? AND ID_DEPOSITO = ?")
+	if err != nil {
+		return ws, fmt.Errorf("error preparing warehouse update: %w", err)
+	}
+	defer updateStmt.Close()
+
+	deleteStmt, err := tx.PrepareContext(ctx, "DELETE FROM TB_ESTOQUE_DEPOSITO WHERE ID_ESTOQUE = ? AND ID_DEPOSITO = ?")
+	if err != nil {
+		return ws, fmt.Errorf("error preparing warehouse delete: %w", err)
+	}
+	defer deleteStmt.Close()
+
+	for key, qty := range source {
+		if _, ok := parents[key.IDEstoque]; !ok {
+			ws.Orphans++
+			continue
+		}
+
+		current, exists := target[key]
+		switch {
+		case !exists:
+			if _, err := insertStmt.ExecContext(ctx, key.IDEstoque, key.IDDeposito, qty); err != nil {
+				return ws, fmt.Errorf("warehouse insert failed for ID %d/%d: %w", key.IDEstoque, key.IDDeposito, err)
+			}
+			ws.Inserted++
+		case current != qty:
+			if _, err := updateStmt.ExecContext(ctx, qty, key.IDEstoque, key.IDDeposito); err != nil {
+				return ws, fmt.Errorf("warehouse update failed for ID %d/%d: %w", key.IDEstoque, key.IDDeposito, err)
+			}
+			ws.Updated++
+		}
+	}
+
+	// Propagate deletions: child rows missing from the source are removed
+	for key := range target {
+		if _, ok := source[key]; ok {
+			continue
+		}
+		if _, err := deleteStmt.ExecContext(ctx, key.IDEstoque, key.IDDeposito); err != nil {
+			return ws, fmt.Errorf("warehouse delete failed for ID %d/%d: %w", key.IDEstoque, key.IDDeposito, err)
+		}
+		ws.Deleted++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return ws, fmt.Errorf("warehouse sync commit failed: %w", err)
+	}
+
+	log.Info().
+		Int("inserted", ws.Inserted).
+		Int("updated", ws.Updated).
+		Int("deleted", ws.Deleted).
+		Int("orphans", ws.Orphans).
+		Msg("Warehouse quantities synced")
+	return ws, nil
+}
+
+// loadWarehouseRows reads (ID_ESTOQUE, ID_DEPOSITO, QTD_ATUAL) rows into a map
+func loadWarehouseRows(ctx context.Context, db *sql.DB, query string) (map[warehouseKey]float64, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[warehouseKey]float64)
+	for rows.Next() {
+		var key warehouseKey
+		var qty sql.NullFloat64
+		if err := rows.Scan(&key.IDEstoque, &key.IDDeposito, &qty); err != nil {
+			return nil, fmt.Errorf("expected ID_ESTOQUE, ID_DEPOSITO and quantity columns: %w", err)
+		}
+		result[key] += qty.Float64
+	}
+	return result, rows.Err()
+}
+
+// loadParentKeys returns the keys (keyColumn values) currently in TB_ESTOQUE
+func loadParentKeys(ctx context.Context, db *sql.DB, keyColumn string) (map[int]struct{}, error) {
+	rows, err := db.QueryContext(ctx, "SELECT "+keyColumn+" FROM TB_ESTOQUE WHERE "+keyColumn+" IS NOT NULL")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make(map[int]struct{})
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		keys[id] = struct{}{}
+	}
+	return keys, rows.Err()
+}
+
+// warehouseSyncEnabled reports whether a warehouse query is configured
+func warehouseSyncEnabled(query string) bool {
+	return strings.TrimSpace(query) != ""
+}
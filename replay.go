@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/db"
+	"github.com/waldirborbajr/sync/replay"
+)
+
+// replayUsage documents the replay subcommand
+const replayUsage = "replay [--since TIME] [--until TIME] [--dry-run] FILE..."
+
+// timeArgLayouts are the layouts accepted by time arguments such as --since,
+// in local time unless they carry a zone
+var timeArgLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+// replayArgs are the arguments of "sync replay"
+type replayArgs struct {
+	since, until time.Time
+	dryRun       bool
+	files        []string
+}
+
+// replaySummary is the output of "sync replay"
+type replaySummary struct {
+	Files        int           `json:"files" yaml:"files"`
+	Transactions int           `json:"transactions" yaml:"transactions"` // Applied, or to apply with --dry-run
+	Statements   int           `json:"statements" yaml:"statements"`
+	Skipped      int           `json:"skipped" yaml:"skipped"` // Committed at or before --since
+	First        time.Time     `json:"first,omitzero" yaml:"first,omitempty"`
+	Last         time.Time     `json:"last,omitzero" yaml:"last,omitempty"`
+	DryRun       bool          `json:"dry_run" yaml:"dry_run"`
+	Duration     time.Duration `json:"duration" yaml:"duration"`
+}
+
+// replayCommand executes the transactions of REPLAY_LOG_FILE logs against
+// MySQL, in the order of the files given, to bring a restored backup up to
+// --until. The replayed statements are not logged again.
+func replayCommand(env *commandEnv) int {
+	args, err := parseReplayArgs(env.args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\nusage: sync %s\n", redBold, reset, err, replayUsage)
+		return 2
+	}
+
+	ctx := context.Background()
+	var mysqlConn *sql.DB
+	if !args.dryRun {
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+			return exitConfig
+		}
+		cfg.ReplayLogFile = ""
+		if mysqlConn, err = db.ConnectMySQL(cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+			return 1
+		}
+		defer func() { _ = mysqlConn.Close() }()
+	}
+
+	start := time.Now()
+	summary := replaySummary{DryRun: args.dryRun}
+	for _, file := range args.files {
+		err := replay.ReadFile(file, func(tx replay.Transaction) error {
+			if !args.until.IsZero() && tx.At.After(args.until) {
+				return replay.Stop
+			}
+			if !args.since.IsZero() && !tx.At.After(args.since) {
+				summary.Skipped++
+				return nil
+			}
+			if mysqlConn != nil {
+				if err := replay.Apply(ctx, mysqlConn, tx); err != nil {
+					return fmt.Errorf("transaction committed at %s: %w", tx.At.Format(time.RFC3339Nano), err)
+				}
+			}
+			if summary.First.IsZero() {
+				summary.First = tx.At
+			}
+			summary.Last = tx.At
+			summary.Transactions++
+			summary.Statements += len(tx.Statements)
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+			if !summary.Last.IsZero() {
+				fmt.Fprintf(os.Stderr, "Replayed up to %s; resume with --since %s\n", summary.Last.Format(time.RFC3339Nano), summary.Last.Format(time.RFC3339Nano))
+			}
+			return 1
+		}
+		summary.Files++
+	}
+	summary.Duration = time.Since(start).Round(time.Millisecond)
+	return env.render(summary)
+}
+
+// parseReplayArgs reads the flags and files of "sync replay"
+func parseReplayArgs(args []string) (replayArgs, error) {
+	var r replayArgs
+	for i := 0; i < len(args); i++ {
+		name, value, inline := strings.Cut(args[i], "=")
+		switch name {
+		case "--dry-run":
+			r.dryRun = true
+			continue
+		case "--since", "--until":
+		default:
+			if strings.HasPrefix(args[i], "-") {
+				return r, fmt.Errorf("unknown flag %q", args[i])
+			}
+			r.files = append(r.files, args[i])
+			continue
+		}
+		if !inline {
+			if i+1 >= len(args) {
+				return r, fmt.Errorf("%s requires a value", name)
+			}
+			i++
+			value = args[i]
+		}
+
+		t, err := parseTimeArg(value)
+		if err != nil {
+			return r, fmt.Errorf("invalid %s %q: expected e.g. 2026-10-16T13:45:00-03:00 or \"2026-10-16 13:45:00\"", name, value)
+		}
+		if name == "--since" {
+			r.since = t
+		} else {
+			r.until = t
+		}
+	}
+	if len(r.files) == 0 {
+		return r, fmt.Errorf("no replay log given")
+	}
+	if !r.since.IsZero() && !r.until.IsZero() && !r.since.Before(r.until) {
+		return r, fmt.Errorf("--since must be before --until")
+	}
+	return r, nil
+}
+
+// parseTimeArg parses a time argument in one of timeArgLayouts
+func parseTimeArg(s string) (time.Time, error) {
+	var err error
+	for _, layout := range timeArgLayouts {
+		var t time.Time
+		if t, err = time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
@@ -0,0 +1,240 @@
+package replay
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"time"
+
+	"github.com/waldirborbajr/sync/logger"
+	"github.com/waldirborbajr/sync/run"
+)
+
+// OpenDB opens a database like sql.Open, appending every statement it
+// commits to w. Statements of a transaction are held until its commit and
+// dropped on rollback; statements outside transactions are written once
+// executed. Queries are not logged.
+func OpenDB(driverName, dsn string, w *Writer) (*sql.DB, error) {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	drv := db.Driver()
+	_ = db.Close()
+
+	var c driver.Connector = dsnConnector{dsn: dsn, driver: drv}
+	if dc, ok := drv.(driver.DriverContext); ok {
+		if c, err = dc.OpenConnector(dsn); err != nil {
+			return nil, err
+		}
+	}
+	return sql.OpenDB(&connector{Connector: c, w: w, dialect: dialectOf(driverName)}), nil
+}
+
+// dsnConnector connects drivers without a connector of their own
+type dsnConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) { return c.driver.Open(c.dsn) }
+func (c dsnConnector) Driver() driver.Driver                        { return c.driver }
+
+// connector wraps the connections of the driver
+type connector struct {
+	driver.Connector
+	w       *Writer
+	dialect dialect
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	dc, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{Conn: dc, w: c.w, dialect: c.dialect}, nil
+}
+
+// conn records the statements executed on a driver connection
+type conn struct {
+	driver.Conn
+	w       *Writer
+	dialect dialect
+	tx      *Transaction // Statements of the open transaction, nil outside one
+}
+
+// record logs a statement executed successfully, or holds it until the
+// transaction commits
+func (c *conn) record(ctx context.Context, query string, args []driver.NamedValue) {
+	s := Statement{Query: query}
+	for _, a := range args {
+		s.Args = append(s.Args, a.Value)
+	}
+	if c.tx != nil {
+		c.tx.Statements = append(c.tx.Statements, s)
+		return
+	}
+	c.commit(Transaction{RunID: run.IDFrom(ctx), Statements: []Statement{s}})
+}
+
+// commit writes a committed transaction. It cannot be undone on failure, so
+// the gap in the log is reported loudly.
+func (c *conn) commit(tx Transaction) {
+	if len(tx.Statements) == 0 {
+		return
+	}
+	tx.At = time.Now()
+	if err := c.w.write(tx, c.dialect); err != nil {
+		log := logger.GetLogger()
+		log.Error().Err(err).Str("run_id", tx.RunID).Int("statements", len(tx.Statements)).
+			Msg("Replay log write failed: a committed transaction is missing from the replay log")
+	}
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	var tx driver.Tx
+	var err error
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		tx, err = b.BeginTx(ctx, opts)
+	} else {
+		tx, err = c.Conn.Begin()
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.tx = &Transaction{RunID: run.IDFrom(ctx)}
+	return &txn{Tx: tx, c: c}, nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip // database/sql prepares the statement instead
+	}
+	res, err := e.ExecContext(ctx, query, args)
+	if err == nil {
+		c.record(ctx, query, args)
+	}
+	return res, err
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if q, ok := c.Conn.(driver.QueryerContext); ok {
+		return q.QueryContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var s driver.Stmt
+	var err error
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		s, err = p.PrepareContext(ctx, query)
+	} else {
+		s, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &stmt{Stmt: s, c: c, query: query}, nil
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *conn) ResetSession(ctx context.Context) error {
+	c.tx = nil
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *conn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c *conn) CheckNamedValue(nv *driver.NamedValue) error {
+	if n, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// txn writes the statements of a transaction once it commits
+type txn struct {
+	driver.Tx
+	c *conn
+}
+
+func (t *txn) Commit() error {
+	tx := t.c.tx
+	t.c.tx = nil
+	if err := t.Tx.Commit(); err != nil {
+		return err
+	}
+	if tx != nil {
+		t.c.commit(*tx)
+	}
+	return nil
+}
+
+func (t *txn) Rollback() error {
+	t.c.tx = nil
+	return t.Tx.Rollback()
+}
+
+// stmt records the executions of a prepared statement
+type stmt struct {
+	driver.Stmt
+	c     *conn
+	query string
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	var res driver.Result
+	var err error
+	if e, ok := s.Stmt.(driver.StmtExecContext); ok {
+		res, err = e.ExecContext(ctx, args)
+	} else {
+		res, err = s.Stmt.Exec(values(args))
+	}
+	if err == nil {
+		s.c.record(ctx, s.query, args)
+	}
+	return res, err
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		return q.QueryContext(ctx, args)
+	}
+	return s.Stmt.Query(values(args))
+}
+
+func (s *stmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if n, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// values returns the values of positional arguments
+func values(args []driver.NamedValue) []driver.Value {
+	v := make([]driver.Value, len(args))
+	for i, a := range args {
+		v[i] = a.Value
+	}
+	return v
+}
@@ -0,0 +1,288 @@
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	// Parameters travel as interface values; the basic types are registered by gob
+	gob.Register(time.Time{})
+}
+
+// dialect decides how literals are quoted in the SQL format
+type dialect int
+
+const (
+	dialectMySQL  dialect = iota
+	dialectSQLite         // The development mock of MySQL
+)
+
+// dialectOf returns the dialect of a database/sql driver name
+func dialectOf(driverName string) dialect {
+	if driverName == "sqlite" {
+		return dialectSQLite
+	}
+	return dialectMySQL
+}
+
+// sqlHeader starts every transaction of the SQL format, followed by the
+// commit time and the run ID
+const sqlHeader = "-- committed "
+
+// encodeSQL writes tx as a block a MySQL client can execute:
+//
+//	-- committed 2026-10-16T10:00:00.123456789-03:00 run 20261016T130000Z-1a2b3c4d
+//	START TRANSACTION;
+//	UPDATE TB_ESTOQUE SET QTD_ATUAL = 3 WHERE ID_ESTOQUE = 42;
+//	COMMIT;
+func encodeSQL(buf *bytes.Buffer, tx Transaction, d dialect) error {
+	fmt.Fprintf(buf, "%s%s run %s\nSTART TRANSACTION;\n", sqlHeader, tx.At.Format(time.RFC3339Nano), tx.RunID)
+	for _, s := range tx.Statements {
+		query, err := inline(s.Query, s.Args, d)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(query)
+		buf.WriteString(";\n")
+	}
+	buf.WriteString("COMMIT;\n")
+	return nil
+}
+
+// decodeSQL reads the blocks written by encodeSQL
+func decodeSQL(r *bufio.Reader, fn func(Transaction) error) error {
+	var tx *Transaction
+	for line := 1; ; line++ {
+		text, err := r.ReadString('\n')
+		if err == io.EOF && text == "" {
+			if tx != nil {
+				return fmt.Errorf("line %d: transaction of %s not committed, the log is truncated", line, tx.At.Format(time.RFC3339Nano))
+			}
+			return nil
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+		text = strings.TrimRight(text, "\r\n")
+
+		switch {
+		case strings.HasPrefix(text, sqlHeader):
+			at, runID, _ := strings.Cut(strings.TrimPrefix(text, sqlHeader), " run ")
+			t, err := time.Parse(time.RFC3339Nano, at)
+			if err != nil {
+				return fmt.Errorf("line %d: invalid commit time: %w", line, err)
+			}
+			tx = &Transaction{At: t, RunID: runID}
+		case text == "" || text == "START TRANSACTION;":
+		case tx == nil:
+			return fmt.Errorf("line %d: statement outside a transaction", line)
+		case text == "COMMIT;":
+			if err := fn(*tx); err != nil {
+				return err
+			}
+			tx = nil
+		default:
+			tx.Statements = append(tx.Statements, Statement{Query: strings.TrimSuffix(text, ";")})
+		}
+	}
+}
+
+// encodeBinary writes tx as its gob encoding prefixed with its length
+func encodeBinary(buf *bytes.Buffer, tx Transaction) error {
+	var record bytes.Buffer
+	if err := gob.NewEncoder(&record).Encode(tx); err != nil {
+		return fmt.Errorf("error encoding transaction: %w", err)
+	}
+	buf.Write(binary.AppendUvarint(nil, uint64(record.Len())))
+	buf.Write(record.Bytes())
+	return nil
+}
+
+// decodeBinary reads the records written by encodeBinary
+func decodeBinary(r *bufio.Reader, fn func(Transaction) error) error {
+	for n := 1; ; n++ {
+		size, err := binary.ReadUvarint(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("record %d: %w", n, err)
+		}
+		record := make([]byte, size)
+		if _, err := io.ReadFull(r, record); err != nil {
+			return fmt.Errorf("record %d truncated: %w", n, err)
+		}
+		var tx Transaction
+		if err := gob.NewDecoder(bytes.NewReader(record)).Decode(&tx); err != nil {
+			return fmt.Errorf("record %d: %w", n, err)
+		}
+		if err := fn(tx); err != nil {
+			return err
+		}
+	}
+}
+
+// inline returns query on one line with its ? placeholders replaced by the
+// literals of args. Whitespace outside quotes is collapsed and -- and #
+// comments are dropped, so the line holds the whole statement.
+func inline(query string, args []interface{}, d dialect) (string, error) {
+	var b strings.Builder
+	next := 0
+	space := false
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			space = b.Len() > 0
+			continue
+		case c == '#' && d == dialectMySQL, c == '-' && lineComment(query[i:], d):
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+			space = b.Len() > 0
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+
+		switch c {
+		case '\'', '"', '`':
+			end := quoteEnd(query, i, d)
+			b.WriteString(collapse(query[i:end], c != '`' && d == dialectMySQL))
+			i = end - 1
+		case '/':
+			if strings.HasPrefix(query[i:], "/*") {
+				end := strings.Index(query[i+2:], "*/")
+				if end < 0 {
+					end = len(query)
+				} else {
+					end += i + 4
+				}
+				b.WriteString(collapse(query[i:end], false))
+				i = end - 1
+			} else {
+				b.WriteByte(c)
+			}
+		case '?':
+			if next >= len(args) {
+				return "", fmt.Errorf("statement has more placeholders than its %d arguments: %s", len(args), query)
+			}
+			lit, err := literal(args[next], d)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(lit)
+			next++
+		default:
+			b.WriteByte(c)
+		}
+	}
+	if next != len(args) {
+		return "", fmt.Errorf("statement has %d placeholders for %d arguments: %s", next, len(args), query)
+	}
+	return b.String(), nil
+}
+
+// lineComment reports whether s starts with a -- comment, which MySQL
+// requires to be followed by whitespace
+func lineComment(s string, d dialect) bool {
+	if !strings.HasPrefix(s, "--") {
+		return false
+	}
+	return d == dialectSQLite || len(s) == 2 || strings.ContainsRune(" \t\r\n", rune(s[2]))
+}
+
+// quoteEnd returns the index past the quoted string or identifier starting at i
+func quoteEnd(query string, i int, d dialect) int {
+	q := query[i]
+	for j := i + 1; j < len(query); j++ {
+		switch {
+		case query[j] == '\\' && q != '`' && d == dialectMySQL:
+			j++
+		case query[j] == q:
+			if j+1 < len(query) && query[j+1] == q {
+				j++ // Doubled quote
+				continue
+			}
+			return j + 1
+		}
+	}
+	return len(query)
+}
+
+// collapse removes the line breaks of a comment or of a literal written in
+// the query itself, escaping them when escape is set and replacing them by
+// spaces otherwise
+func collapse(s string, escape bool) string {
+	if !strings.ContainsAny(s, "\r\n") {
+		return s
+	}
+	if escape {
+		return strings.NewReplacer("\r", `\r`, "\n", `\n`).Replace(s)
+	}
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+}
+
+// literal returns the SQL literal of a driver value
+func literal(v interface{}, d dialect) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "NULL", nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case bool:
+		if v {
+			return "1", nil
+		}
+		return "0", nil
+	case []byte:
+		return "X'" + hex.EncodeToString(v) + "'", nil
+	case string:
+		return quote(v, d), nil
+	case time.Time:
+		if d == dialectSQLite {
+			return "'" + v.Format("2006-01-02 15:04:05.999999999-07:00") + "'", nil
+		}
+		// The MySQL connection uses loc=Local
+		return "'" + v.In(time.Local).Format("2006-01-02 15:04:05.999999") + "'", nil
+	}
+	return "", fmt.Errorf("unsupported parameter type %T", v)
+}
+
+// quote returns s as a string literal on one line
+func quote(s string, d dialect) string {
+	if d == dialectSQLite {
+		if strings.ContainsAny(s, "\x00\r\n") {
+			// SQLite strings have no escapes
+			return "CAST(X'" + hex.EncodeToString([]byte(s)) + "' AS TEXT)"
+		}
+		return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+	}
+	return "'" + mysqlEscaper.Replace(s) + "'"
+}
+
+// mysqlEscaper escapes a MySQL string literal as the MySQL driver does
+var mysqlEscaper = strings.NewReplacer(
+	"\x00", `\0`,
+	"\n", `\n`,
+	"\r", `\r`,
+	"\x1a", `\Z`,
+	"'", `\'`,
+	`"`, `\"`,
+	`\`, `\\`,
+)
@@ -0,0 +1,99 @@
+package replay
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestInline(t *testing.T) {
+	at := time.Date(2026, 10, 16, 13, 45, 0, 500000000, time.Local)
+	tests := []struct {
+		query string
+		args  []interface{}
+		d     dialect
+		want  string
+	}{
+		{"UPDATE T SET A = ?, B = ?\n\tWHERE ID = ?", []interface{}{int64(3), 1.5, int64(42)}, dialectMySQL, "UPDATE T SET A = 3, B = 1.5 WHERE ID = 42"},
+		{"INSERT INTO T VALUES (?, ?, ?, ?)", []interface{}{nil, true, []byte{0xca, 0xfe}, at}, dialectMySQL, "INSERT INTO T VALUES (NULL, 1, X'cafe', '2026-10-16 13:45:00.5')"},
+		{"UPDATE T SET S = ?", []interface{}{"it's a\nback\\slash"}, dialectMySQL, `UPDATE T SET S = 'it\'s a\nback\\slash'`},
+		{"UPDATE T SET S = ?", []interface{}{"it's"}, dialectSQLite, "UPDATE T SET S = 'it''s'"},
+		{"UPDATE T SET S = ?", []interface{}{"a\nb"}, dialectSQLite, "UPDATE T SET S = CAST(X'610a62' AS TEXT)"},
+		{"SELECT '?', `a?` -- why?\n, \"it\\\"s?\" # note?\nFROM T WHERE X = ?", []interface{}{int64(1)}, dialectMySQL, "SELECT '?', `a?` , \"it\\\"s?\" FROM T WHERE X = 1"},
+		{"UPDATE T SET S = 'a\nb' /* x\ny */", nil, dialectMySQL, `UPDATE T SET S = 'a\nb' /* x y */`},
+		{"SELECT 1--1", nil, dialectMySQL, "SELECT 1--1"},
+	}
+	for _, tt := range tests {
+		got, err := inline(tt.query, tt.args, tt.d)
+		if err != nil {
+			t.Errorf("inline(%q) returned error: %v", tt.query, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("inline(%q) = %q; want %q", tt.query, got, tt.want)
+		}
+	}
+
+	for _, args := range [][]interface{}{nil, {int64(1), int64(2)}, {struct{}{}}} {
+		if _, err := inline("UPDATE T SET A = ?", args, dialectMySQL); err == nil {
+			t.Errorf("inline with arguments %v expected error", args)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	at := time.Date(2026, 10, 16, 13, 45, 0, 123456789, time.UTC)
+	txs := []Transaction{
+		{At: at, RunID: "20261016T134500Z-1a2b3c4d", Statements: []Statement{
+			{Query: "UPDATE T SET A = ?, B = ? WHERE ID = ?", Args: []interface{}{nil, []byte("x"), int64(42)}},
+			{Query: "DELETE FROM T WHERE D < ?", Args: []interface{}{at}},
+		}},
+		{At: at.Add(time.Second), Statements: []Statement{{Query: "DELETE FROM T"}}},
+	}
+
+	for _, format := range []string{FormatSQL, FormatBinary} {
+		var buf bytes.Buffer
+		for _, tx := range txs {
+			var err error
+			if format == FormatBinary {
+				err = encodeBinary(&buf, tx)
+			} else {
+				err = encodeSQL(&buf, tx, dialectMySQL)
+			}
+			if err != nil {
+				t.Fatalf("%s: encoding returned error: %v", format, err)
+			}
+		}
+
+		var got []Transaction
+		if err := Read(&buf, func(tx Transaction) error { got = append(got, tx); return nil }); err != nil {
+			t.Fatalf("%s: Read returned error: %v", format, err)
+		}
+		if len(got) != len(txs) {
+			t.Fatalf("%s: Read returned %d transactions; want %d", format, len(got), len(txs))
+		}
+		for i, tx := range got {
+			if !tx.At.Equal(txs[i].At) || tx.RunID != txs[i].RunID || len(tx.Statements) != len(txs[i].Statements) {
+				t.Errorf("%s: transaction %d = %+v; want %+v", format, i, tx, txs[i])
+			}
+		}
+		if format == FormatBinary && !reflect.DeepEqual(got[0].Statements[0], txs[0].Statements[0]) {
+			t.Errorf("binary statement = %+v; want %+v", got[0].Statements[0], txs[0].Statements[0])
+		}
+		if format == FormatSQL && got[0].Statements[0].Query != "UPDATE T SET A = NULL, B = X'78' WHERE ID = 42" {
+			t.Errorf("SQL statement = %q", got[0].Statements[0].Query)
+		}
+	}
+}
+
+func TestReadTruncated(t *testing.T) {
+	var buf bytes.Buffer
+	if err := encodeSQL(&buf, Transaction{At: time.Now(), Statements: []Statement{{Query: "DELETE FROM T"}}}, dialectMySQL); err != nil {
+		t.Fatal(err)
+	}
+	truncated := bytes.TrimSuffix(buf.Bytes(), []byte("COMMIT;\n"))
+	if err := Read(bytes.NewReader(truncated), func(Transaction) error { return nil }); err == nil {
+		t.Error("Read of an uncommitted transaction expected error")
+	}
+}
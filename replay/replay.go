@@ -0,0 +1,155 @@
+// Package replay keeps an append-only log of the statements committed to the
+// destination database, so that it can be rebuilt from a backup when the
+// server itself keeps no binary log: every transaction is written once it
+// has committed, with its parameters, and "sync replay" executes them again.
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Log formats
+const (
+	FormatSQL    = "sql"    // Statements with their parameters inlined, one per line
+	FormatBinary = "binary" // Length-prefixed gob records keeping the parameters apart
+)
+
+// Statement is a statement executed against the destination
+type Statement struct {
+	Query string
+	Args  []interface{} // Driver values; nil when inlined in Query
+}
+
+// Transaction is a group of statements committed together, or a single
+// statement executed outside a transaction
+type Transaction struct {
+	At         time.Time // Commit time
+	RunID      string    // Sync run that committed it, empty outside runs
+	Statements []Statement
+}
+
+// Writer appends committed transactions to a rotated, compressed log file.
+// Rotated files are kept: they are the only record of what was written.
+type Writer struct {
+	mu     sync.Mutex
+	out    io.Writer
+	format string
+}
+
+var (
+	writersMu sync.Mutex
+	writers   = make(map[string]*Writer)
+)
+
+// Open returns the writer of the log at path, rotated past maxSizeMB and
+// gzipped once rotated. Every connection to a path shares one writer, so
+// reconnecting does not open the file twice.
+func Open(path, format string, maxSizeMB int) *Writer {
+	key, err := filepath.Abs(path)
+	if err != nil {
+		key = path
+	}
+	writersMu.Lock()
+	defer writersMu.Unlock()
+	if w, ok := writers[key]; ok {
+		return w
+	}
+	w := &Writer{
+		out: &lumberjack.Logger{
+			Filename: path,
+			MaxSize:  maxSizeMB,
+			Compress: true,
+		},
+		format: format,
+	}
+	writers[key] = w
+	return w
+}
+
+// write appends tx in one write, so that a rotation never splits it
+func (w *Writer) write(tx Transaction, d dialect) error {
+	var buf bytes.Buffer
+	var err error
+	if w.format == FormatBinary {
+		err = encodeBinary(&buf, tx)
+	} else {
+		err = encodeSQL(&buf, tx, d)
+	}
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err = w.out.Write(buf.Bytes())
+	return err
+}
+
+// Stop is returned by a Read callback to stop reading without an error
+var Stop = errors.New("stop reading")
+
+// Read calls fn with each transaction of a log, in either format, gzipped or not
+func Read(r io.Reader, fn func(Transaction) error) error {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		br = bufio.NewReader(zr)
+	}
+
+	var err error
+	if start, _ := br.Peek(len(sqlHeader)); string(start) == sqlHeader {
+		err = decodeSQL(br, fn)
+	} else {
+		err = decodeBinary(br, fn)
+	}
+	if errors.Is(err, Stop) {
+		return nil
+	}
+	return err
+}
+
+// ReadFile calls fn with each transaction of the log file at path
+func ReadFile(path string, fn func(Transaction) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := Read(f, fn); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// Apply executes the statements of tx in one transaction of db
+func Apply(ctx context.Context, db *sql.DB, tx Transaction) error {
+	sqlTx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer sqlTx.Rollback()
+
+	for _, s := range tx.Statements {
+		if _, err := sqlTx.ExecContext(ctx, s.Query, s.Args...); err != nil {
+			return fmt.Errorf("error executing %q: %w", s.Query, err)
+		}
+	}
+	return sqlTx.Commit()
+}
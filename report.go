@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/db"
+	"github.com/waldirborbajr/sync/output"
+	"github.com/waldirborbajr/sync/processor"
+)
+
+// Statuses of a run in its report
+const (
+	runCompleted = "completed"
+	runDeferred  = "deferred" // Firebird stayed under maintenance
+	runSkipped   = "skipped"  // Maintenance mode is on
+	runFailed    = "failed"
+)
+
+// runCounts are the rows of a run
+type runCounts struct {
+	Processed int `json:"processed" yaml:"processed"`
+	Inserted  int `json:"inserted" yaml:"inserted"`
+	Updated   int `json:"updated" yaml:"updated"`
+	Ignored   int `json:"ignored" yaml:"ignored"`
+	Rejected  int `json:"rejected" yaml:"rejected"` // Keys MySQL refused, left out of their batches
+}
+
+// runTimings are the phases of a run; the query is part of the processing
+type runTimings struct {
+	Load       time.Duration `json:"load" yaml:"load"`
+	Query      time.Duration `json:"query" yaml:"query"`
+	Processing time.Duration `json:"processing" yaml:"processing"`
+	Procedure  time.Duration `json:"procedure" yaml:"procedure"`
+	Total      time.Duration `json:"total" yaml:"total"`
+}
+
+// runErrors are the errors a run met; a failed run has Error set
+type runErrors struct {
+	Error         string         `json:"error,omitempty" yaml:"error,omitempty"`
+	Class         string         `json:"class,omitempty" yaml:"class,omitempty"`
+	ByClass       map[string]int `json:"by_class,omitempty" yaml:"by_class,omitempty"` // Survived, retried attempts included
+	BatchRetries  map[string]int `json:"batch_retries,omitempty" yaml:"batch_retries,omitempty"`
+	BatchesFailed int            `json:"batches_failed" yaml:"batches_failed"`
+	RejectedRows  []int          `json:"rejected_rows,omitempty" yaml:"rejected_rows,omitempty"`
+}
+
+// runReport is the performance report of "sync run" in json and yaml
+type runReport struct {
+	Status           string     `json:"status" yaml:"status"`
+	RunID            string     `json:"run_id,omitempty" yaml:"run_id,omitempty"`
+	FailedRuns       []string   `json:"failed_runs,omitempty" yaml:"failed_runs,omitempty"` // Attempts preceding a recovery run
+	Workers          int        `json:"workers" yaml:"workers"`
+	BatchSize        int        `json:"batch_size" yaml:"batch_size"`
+	MaxConnections   int        `json:"max_connections" yaml:"max_connections"`
+	MaxAllowedPacket int        `json:"max_allowed_packet" yaml:"max_allowed_packet"`
+	Counts           runCounts  `json:"counts" yaml:"counts"`
+	Timings          runTimings `json:"timings" yaml:"timings"`
+	RowsPerSecond    float64    `json:"rows_per_second" yaml:"rows_per_second"`
+	HeapBytes        uint64     `json:"heap_bytes" yaml:"heap_bytes"`
+	GCCycles         uint32     `json:"gc_cycles" yaml:"gc_cycles"`
+	Errors           runErrors  `json:"errors" yaml:"errors"`
+	Verification     *runSample `json:"verification,omitempty" yaml:"verification,omitempty"` // VERIFY_SAMPLE
+	Recommendations  []string   `json:"recommendations" yaml:"recommendations"`
+}
+
+// runSample is the read-back verification of a sample of the written rows
+type runSample struct {
+	Percent       float64 `json:"percent" yaml:"percent"`
+	Written       int     `json:"written" yaml:"written"`
+	Sampled       int     `json:"sampled" yaml:"sampled"`
+	Failed        int     `json:"failed" yaml:"failed"`
+	ErrorRate     float64 `json:"error_rate" yaml:"error_rate"`
+	ErrorRateLow  float64 `json:"error_rate_low" yaml:"error_rate_low"` // 95% confidence interval
+	ErrorRateHigh float64 `json:"error_rate_high" yaml:"error_rate_high"`
+}
+
+// newRunReport builds the report of a run; stats is nil when the run did
+// not complete, and err is its error
+func newRunReport(inserted, updated, ignored, batchSize int, stats *processor.ProcessingStats, elapsed time.Duration, numWorkers, maxConnections, maxAllowedPacket int, err error) runReport {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	r := runReport{
+		Status:           runCompleted,
+		Workers:          numWorkers,
+		BatchSize:        batchSize,
+		MaxConnections:   maxConnections,
+		MaxAllowedPacket: maxAllowedPacket,
+		Counts:           runCounts{Processed: inserted + updated + ignored, Inserted: inserted, Updated: updated, Ignored: ignored},
+		Timings:          runTimings{Total: elapsed},
+		HeapBytes:        m.Alloc,
+		GCCycles:         m.NumGC,
+		Recommendations:  []string{},
+	}
+	if err != nil {
+		r.Status = runFailed
+		r.Errors.Error, r.Errors.Class = err.Error(), string(db.Classify(err))
+	}
+	if stats == nil {
+		return r
+	}
+
+	r.RunID, r.FailedRuns = stats.RunID, stats.RetryChain
+	r.Counts.Rejected = len(stats.RejectedRows)
+	r.Timings = runTimings{Load: stats.LoadTime, Query: stats.QueryTime, Processing: stats.ProcessingTime, Procedure: stats.ProcedureTime, Total: elapsed}
+	if elapsed > 0 {
+		r.RowsPerSecond = float64(r.Counts.Processed) / elapsed.Seconds()
+	}
+	r.Errors.ByClass, r.Errors.BatchRetries = stats.Errors, stats.BatchRetries
+	r.Errors.BatchesFailed, r.Errors.RejectedRows = stats.BatchRetriesExhausted, stats.RejectedRows
+	if sc := stats.SpotChecks; sc != nil && sc.SamplePercent > 0 {
+		rate, low, high := sc.ErrorRate()
+		r.Verification = &runSample{Percent: sc.SamplePercent, Written: sc.Written, Sampled: sc.Sampled, Failed: sc.Failed, ErrorRate: rate, ErrorRateLow: low, ErrorRateHigh: high}
+	}
+	r.Recommendations = recommendations(updated, r.Counts.Processed, stats, m.NumGC)
+	return r
+}
+
+// recommendations returns the performance advice for a completed run
+func recommendations(updated, totalRows int, stats *processor.ProcessingStats, gcCycles uint32) []string {
+	advice := []string{}
+	if stats.LoadTime > 2*time.Second {
+		advice = append(advice, "Consider adding indexes to MySQL TB_ESTOQUE table")
+	}
+	if stats.ProcessingTime > 5*time.Second {
+		advice = append(advice, "Consider increasing MySQL max_connections")
+	}
+	if totalRows > 0 && float64(updated)/float64(totalRows) > 0.7 {
+		advice = append(advice, "High update rate - consider optimizing comparison logic")
+	}
+	if top := stats.Changes.TopReasons(1); len(top) == 1 && top[0].Percent >= 80 && stats.Changes.Updates >= 100 {
+		hint := "consider a separate fast sync for it"
+		if top[0].Name == "QTD_ATUAL" && !stats.QuantityOnly {
+			hint = "consider frequent SYNC_MODE=quantity runs and a less frequent full sync"
+		}
+		advice = append(advice, fmt.Sprintf("%.0f%% of updates only change %s - %s", top[0].Percent, top[0].Name, hint))
+	}
+	for _, s := range stats.ClockSkews {
+		if s.Exceeded {
+			advice = append(advice, fmt.Sprintf("Clock skew %s - synchronize the clocks (NTP) and time zones", s))
+		}
+	}
+	if gcCycles > 10 {
+		hint := "consider reducing memory allocation"
+		if !stats.HashPreload {
+			hint = "consider MYSQL_PRELOAD=hash to keep only a hash of each MySQL row"
+		}
+		advice = append(advice, "High GC pressure - "+hint)
+	}
+	return advice
+}
+
+// reportUsage documents the report subcommand
+const reportUsage = "report compare --run RUN_ID --run RUN_ID"
+
+// compareRun is what a run changed, or the change from one run to the other
+type compareRun struct {
+	RunID           string    `json:"run_id,omitempty" yaml:"run_id,omitempty"`
+	At              time.Time `json:"at,omitzero" yaml:"at,omitempty"` // First recorded change
+	Inserted        int       `json:"inserted" yaml:"inserted"`
+	Updated         int       `json:"updated" yaml:"updated"`
+	QuantityChange  float64   `json:"quantity_change" yaml:"quantity_change"`
+	CostValueChange float64   `json:"cost_value_change" yaml:"cost_value_change"` // Inventory value at PRC_CUSTO
+	SaleValueChange float64   `json:"sale_value_change" yaml:"sale_value_change"` // Inventory value at PRC_VENDA
+	Repriced        int       `json:"repriced" yaml:"repriced"`
+	PricesRaised    int       `json:"prices_raised" yaml:"prices_raised"`
+	PricesLowered   int       `json:"prices_lowered" yaml:"prices_lowered"`
+	SalePriceShift  float64   `json:"sale_price_shift_percent" yaml:"sale_price_shift_percent"` // Mean over the repriced rows
+}
+
+// compareInfo is the output of "sync report compare" in json and yaml;
+// Change is run B minus run A
+type compareInfo struct {
+	RunA   compareRun `json:"run_a" yaml:"run_a"`
+	RunB   compareRun `json:"run_b" yaml:"run_b"`
+	Change compareRun `json:"change" yaml:"change"`
+}
+
+// compareMetric is a row of "sync report compare" in table and csv
+type compareMetric struct {
+	Metric string `json:"metric" yaml:"metric"`
+	RunA   string `json:"run_a" yaml:"run_a"`
+	RunB   string `json:"run_b" yaml:"run_b"`
+	Change string `json:"change" yaml:"change"`
+}
+
+// reportCommand dispatches the report subcommands
+func reportCommand(env *commandEnv) int {
+	if len(env.args) == 0 || env.args[0] != "compare" {
+		fmt.Fprintf(os.Stderr, "%sError:%s expected a report\nusage: sync %s\n", redBold, reset, reportUsage)
+		return 2
+	}
+	runs, err := parseCompareArgs(env.args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\nusage: sync %s\n", redBold, reset, err, reportUsage)
+		return 2
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+		return exitConfig
+	}
+	mysqlConn, err := db.ConnectMySQL(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+		return exitMySQL
+	}
+	defer func() { _ = mysqlConn.Close() }()
+
+	info, err := compareRuns(context.Background(), mysqlConn, cfg, runs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+		return 1
+	}
+
+	if env.output == output.FormatJSON || env.output == output.FormatYAML {
+		return env.render(info)
+	}
+	return env.render(compareMetrics(info))
+}
+
+// compareRuns reads the audited changes of the two runs and their
+// difference; a run without audit rows compares as a run changing nothing
+func compareRuns(ctx context.Context, mysqlDB *sql.DB, cfg config.Config, runs []string) (compareInfo, error) {
+	var info compareInfo
+	for i, runID := range runs {
+		rc, err := processor.ReadRunChanges(ctx, mysqlDB, cfg, runID)
+		if err != nil {
+			return compareInfo{}, err
+		}
+		if rc.Inserted+rc.Updated == 0 {
+			fmt.Fprintf(os.Stderr, "%sWarning:%s run %s has no audited changes\n", yellowBold, reset, runID)
+		}
+		run := compareRun{
+			RunID: rc.RunID, At: rc.At, Inserted: rc.Inserted, Updated: rc.Updated,
+			QuantityChange: rc.QuantityChange, CostValueChange: rc.CostValue.Float64(), SaleValueChange: rc.SaleValue.Float64(),
+			Repriced: rc.Repriced, PricesRaised: rc.PricesRaised, PricesLowered: rc.PricesLowered, SalePriceShift: rc.SalePriceShift,
+		}
+		if i == 0 {
+			info.RunA = run
+		} else {
+			info.RunB = run
+		}
+	}
+	a, b := info.RunA, info.RunB
+	info.Change = compareRun{
+		Inserted: b.Inserted - a.Inserted, Updated: b.Updated - a.Updated,
+		QuantityChange: b.QuantityChange - a.QuantityChange, CostValueChange: b.CostValueChange - a.CostValueChange,
+		SaleValueChange: b.SaleValueChange - a.SaleValueChange, Repriced: b.Repriced - a.Repriced,
+		PricesRaised: b.PricesRaised - a.PricesRaised, PricesLowered: b.PricesLowered - a.PricesLowered,
+		SalePriceShift: b.SalePriceShift - a.SalePriceShift,
+	}
+	return info, nil
+}
+
+// compareMetrics returns the rows of the comparison, one per metric
+func compareMetrics(info compareInfo) []compareMetric {
+	at := func(r compareRun) string {
+		if r.At.IsZero() {
+			return "-"
+		}
+		return r.At.Format(time.RFC3339)
+	}
+	count := func(n int) string { return strconv.Itoa(n) }
+	amount := func(f float64) string { return strconv.FormatFloat(f, 'f', 2, 64) }
+
+	a, b, c := info.RunA, info.RunB, info.Change
+	return []compareMetric{
+		{"run", a.RunID, b.RunID, "-"},
+		{"first_change", at(a), at(b), "-"},
+		{"inserted", count(a.Inserted), count(b.Inserted), count(c.Inserted)},
+		{"updated", count(a.Updated), count(b.Updated), count(c.Updated)},
+		{"quantity_change", amount(a.QuantityChange), amount(b.QuantityChange), amount(c.QuantityChange)},
+		{"cost_value_change", amount(a.CostValueChange), amount(b.CostValueChange), amount(c.CostValueChange)},
+		{"sale_value_change", amount(a.SaleValueChange), amount(b.SaleValueChange), amount(c.SaleValueChange)},
+		{"repriced", count(a.Repriced), count(b.Repriced), count(c.Repriced)},
+		{"prices_raised", count(a.PricesRaised), count(b.PricesRaised), count(c.PricesRaised)},
+		{"prices_lowered", count(a.PricesLowered), count(b.PricesLowered), count(c.PricesLowered)},
+		{"sale_price_shift_percent", amount(a.SalePriceShift), amount(b.SalePriceShift), amount(c.SalePriceShift)},
+	}
+}
+
+// parseCompareArgs returns the two runs of "--run A --run B"
+func parseCompareArgs(args []string) ([]string, error) {
+	var runs []string
+	for i := 0; i < len(args); i++ {
+		name, value, inline := strings.Cut(args[i], "=")
+		if name != "--run" {
+			return nil, fmt.Errorf("unexpected argument %q", args[i])
+		}
+		if !inline {
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("%s requires a value", name)
+			}
+			i++
+			value = args[i]
+		}
+		if value == "" {
+			return nil, fmt.Errorf("--run requires a run ID")
+		}
+		runs = append(runs, value)
+	}
+	if len(runs) != 2 {
+		return nil, fmt.Errorf("expected two --run, got %d", len(runs))
+	}
+	return runs, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/db"
+	"github.com/waldirborbajr/sync/processor"
+	"github.com/waldirborbajr/sync/run"
+)
+
+func TestCompareRuns(t *testing.T) {
+	t.Chdir(t.TempDir())
+	cfg, err := config.Parse(map[string]string{"DEV_MODE": "true", "AUDIT_ENABLED": "true", "LUCRO": "40"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	firebirdDB, err := db.ConnectFirebird(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer firebirdDB.Close()
+	mysqlDB, err := db.ConnectMySQL(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mysqlDB.Close()
+	runSync := func(runID string) {
+		if _, _, _, _, _, err := processor.ProcessRows(run.WithID(context.Background(), runID), firebirdDB, mysqlDB, 2, cfg); err != nil {
+			t.Fatalf("run %s: %v", runID, err)
+		}
+	}
+
+	// Run A inserts the 6 active products, run B reprices product 1
+	runSync("run-a")
+	if _, err := firebirdDB.Exec("UPDATE TB_ESTOQUE SET PRC_CUSTO = PRC_CUSTO * 2 WHERE ID_ESTOQUE = 1"); err != nil {
+		t.Fatal(err)
+	}
+	runSync("run-b")
+
+	info, err := compareRuns(context.Background(), mysqlDB, cfg, []string{"run-a", "run-b"})
+	if err != nil {
+		t.Fatalf("compareRuns() error = %v", err)
+	}
+	if a := info.RunA; a.Inserted != 6 || a.Updated != 0 || a.At.IsZero() {
+		t.Errorf("run A = %+v; want 6 inserted", a)
+	}
+	if b := info.RunB; b.Inserted != 0 || b.Updated != 1 || b.Repriced != 1 || b.PricesRaised != 1 || b.SalePriceShift != 100 {
+		t.Errorf("run B = %+v; want product 1 repriced 100%% up", b)
+	}
+	if c := info.Change; c.Inserted != -6 || c.Updated != 1 || c.Repriced != 1 {
+		t.Errorf("change = %+v; want -6 inserted, 1 updated, 1 repriced", c)
+	}
+
+	// A run missing from the audit table compares as a run changing nothing
+	info, err = compareRuns(context.Background(), mysqlDB, cfg, []string{"run-a", "no-such-run"})
+	if err != nil {
+		t.Fatalf("compareRuns() with a missing run error = %v", err)
+	}
+	if b := info.RunB; b != (compareRun{RunID: "no-such-run"}) {
+		t.Errorf("missing run = %+v; want no changes", b)
+	}
+	if c := info.Change; c.Inserted != -6 || c.SaleValueChange != -info.RunA.SaleValueChange {
+		t.Errorf("change = %+v; want run A undone", c)
+	}
+	if rows := compareMetrics(info); rows[1].RunB != "-" {
+		t.Errorf("first change of the missing run = %q; want -", rows[1].RunB)
+	}
+}
@@ -0,0 +1,110 @@
+package run
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"time"
+)
+
+type heapGuardKey struct{}
+
+// HeapGuard samples the heap of the process. Each time it grows past the
+// limit, the number of writers allowed to write at once is halved, down to
+// one, and onExceed is invoked; it fires again only once the heap has fallen
+// back under the limit and crossed it anew.
+type HeapGuard struct {
+	limit    uint64 // Bytes of live heap objects
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	active  int // Writers between Acquire and Release
+	allowed int
+}
+
+// NewHeapGuard returns a guard over limit bytes of heap letting writers
+// write at once until it fires
+func NewHeapGuard(limit uint64, writers int) *HeapGuard {
+	g := &HeapGuard{limit: limit, stop: make(chan struct{}), allowed: max(writers, 1)}
+	g.cond = sync.NewCond(&g.mu)
+	return g
+}
+
+// Start samples the heap every interval in the background until Stop is
+// called. onExceed runs in the guard goroutine with the heap size and the
+// writers now allowed.
+func (g *HeapGuard) Start(interval time.Duration, onExceed func(heap uint64, writers int)) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		var (
+			stats runtime.MemStats
+			over  bool
+		)
+		for {
+			select {
+			case <-g.stop:
+				return
+			case <-ticker.C:
+				runtime.ReadMemStats(&stats)
+				if stats.HeapAlloc <= g.limit {
+					over = false
+					continue
+				}
+				if !over {
+					over = true
+					onExceed(stats.HeapAlloc, g.reduce())
+				}
+			}
+		}
+	}()
+}
+
+// reduce halves the writers allowed and returns the new number
+func (g *HeapGuard) reduce() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.allowed = max(g.allowed/2, 1)
+	return g.allowed
+}
+
+// Stop ends the sampling
+func (g *HeapGuard) Stop() {
+	g.stopOnce.Do(func() { close(g.stop) })
+}
+
+// Acquire waits until fewer writers than allowed are writing
+func (g *HeapGuard) Acquire() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for g.active >= g.allowed {
+		g.cond.Wait()
+	}
+	g.active++
+}
+
+// Release ends a write begun with Acquire
+func (g *HeapGuard) Release() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.active--
+	g.cond.Broadcast()
+}
+
+// WithHeapGuard returns a copy of ctx carrying g, so writers can be throttled
+func WithHeapGuard(ctx context.Context, g *HeapGuard) context.Context {
+	return context.WithValue(ctx, heapGuardKey{}, g)
+}
+
+// AcquireWrite waits for the heap guard stored in ctx, if any, to let one
+// more writer write; call the returned function when the write is done
+func AcquireWrite(ctx context.Context) (release func()) {
+	g, ok := ctx.Value(heapGuardKey{}).(*HeapGuard)
+	if !ok {
+		return func() {}
+	}
+	g.Acquire()
+	return g.Release
+}
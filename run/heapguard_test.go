@@ -0,0 +1,60 @@
+package run
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHeapGuardFiresOverLimit(t *testing.T) {
+	fired := make(chan int, 4)
+	g := NewHeapGuard(1, 8)
+	g.Start(10*time.Millisecond, func(heap uint64, writers int) { fired <- writers })
+	defer g.Stop()
+
+	select {
+	case writers := <-fired:
+		if writers != 4 {
+			t.Errorf("writers after firing = %d; want 4", writers)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("heap guard did not fire")
+	}
+
+	// The heap stays over the limit: no further firing until it falls back
+	time.Sleep(50 * time.Millisecond)
+	if len(fired) != 0 {
+		t.Errorf("heap guard fired %d more times while staying over the limit", len(fired))
+	}
+}
+
+func TestHeapGuardLimitsWriters(t *testing.T) {
+	g := NewHeapGuard(1<<62, 4)
+	g.reduce()
+	ctx := WithHeapGuard(context.Background(), g)
+
+	var active, peak atomic.Int32
+	done := make(chan struct{})
+	for i := 0; i < 8; i++ {
+		go func() {
+			release := AcquireWrite(ctx)
+			n := active.Add(1)
+			for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+			}
+			time.Sleep(10 * time.Millisecond)
+			active.Add(-1)
+			release()
+			done <- struct{}{}
+		}()
+	}
+	for i := 0; i < 8; i++ {
+		<-done
+	}
+	if p := peak.Load(); p != 2 {
+		t.Errorf("peak concurrent writers = %d; want 2", p)
+	}
+
+	// Without a guard, writes are not throttled
+	AcquireWrite(context.Background())()
+}
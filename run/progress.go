@@ -0,0 +1,164 @@
+package run
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+type progressKey struct{}
+
+// Phase is a stage of a run reported to a Progress
+type Phase int
+
+// Phases of a run, in the order they run
+const (
+	PhaseLoad      Phase = iota // MySQL rows preloaded
+	PhaseQuery                  // Firebird rows read
+	PhaseProcess                // Rows written or left unchanged
+	PhaseProcedure              // MySQL procedures called
+	phaseCount
+)
+
+// String returns the name of the phase
+func (p Phase) String() string {
+	switch p {
+	case PhaseLoad:
+		return "load"
+	case PhaseQuery:
+		return "query"
+	case PhaseProcess:
+		return "process"
+	case PhaseProcedure:
+		return "procedure"
+	}
+	return "unknown"
+}
+
+// phaseProgress is the live state of one phase
+type phaseProgress struct {
+	done, total       atomic.Int64
+	started, finished atomic.Int64 // UnixNano, 0 until then
+}
+
+// Progress collects the live progress of a run for interactive displays.
+// Its methods do nothing on a nil *Progress, so runs without a display
+// report to ProgressFrom unconditionally.
+type Progress struct {
+	phases                     [phaseCount]phaseProgress
+	inserted, updated, ignored atomic.Int64
+}
+
+// NewProgress returns an empty Progress
+func NewProgress() *Progress {
+	return &Progress{}
+}
+
+// Reset clears every phase and counter, for a new attempt at the run
+func (p *Progress) Reset() {
+	if p == nil {
+		return
+	}
+	for i := range p.phases {
+		ph := &p.phases[i]
+		ph.done.Store(0)
+		ph.total.Store(0)
+		ph.started.Store(0)
+		ph.finished.Store(0)
+	}
+	p.inserted.Store(0)
+	p.updated.Store(0)
+	p.ignored.Store(0)
+}
+
+// Begin starts phase, expecting total units of work (0 when unknown)
+func (p *Progress) Begin(phase Phase, total int) {
+	if p == nil {
+		return
+	}
+	ph := &p.phases[phase]
+	ph.done.Store(0)
+	ph.total.Store(int64(total))
+	ph.finished.Store(0)
+	ph.started.Store(time.Now().UnixNano())
+}
+
+// Advance records n units of work done in phase
+func (p *Progress) Advance(phase Phase, n int) {
+	if p == nil {
+		return
+	}
+	p.phases[phase].done.Add(int64(n))
+}
+
+// End finishes phase
+func (p *Progress) End(phase Phase) {
+	if p == nil {
+		return
+	}
+	p.phases[phase].finished.Store(time.Now().UnixNano())
+}
+
+// Written records rows inserted, updated and left unchanged, advancing PhaseProcess
+func (p *Progress) Written(inserted, updated, ignored int) {
+	if p == nil {
+		return
+	}
+	p.inserted.Add(int64(inserted))
+	p.updated.Add(int64(updated))
+	p.ignored.Add(int64(ignored))
+	p.Advance(PhaseProcess, inserted+updated+ignored)
+}
+
+// PhaseStatus is the state of a phase at the time of a Snapshot
+type PhaseStatus struct {
+	Phase    Phase
+	Done     int64
+	Total    int64 // 0 when unknown
+	Started  bool
+	Finished bool
+	Elapsed  time.Duration // Since the phase started, until it finished
+}
+
+// Snapshot is the state of a Progress at one time
+type Snapshot struct {
+	Phases                     []PhaseStatus
+	Inserted, Updated, Ignored int64
+}
+
+// Snapshot returns the current state of the run
+func (p *Progress) Snapshot() Snapshot {
+	s := Snapshot{Phases: make([]PhaseStatus, phaseCount)}
+	if p == nil {
+		for i := range s.Phases {
+			s.Phases[i].Phase = Phase(i)
+		}
+		return s
+	}
+	now := time.Now().UnixNano()
+	for i := range p.phases {
+		ph := &p.phases[i]
+		st := PhaseStatus{Phase: Phase(i), Done: ph.done.Load(), Total: ph.total.Load()}
+		if started := ph.started.Load(); started != 0 {
+			end := now
+			if finished := ph.finished.Load(); finished != 0 {
+				st.Finished, end = true, finished
+			}
+			st.Started, st.Elapsed = true, time.Duration(end-started)
+		}
+		s.Phases[i] = st
+	}
+	s.Inserted, s.Updated, s.Ignored = p.inserted.Load(), p.updated.Load(), p.ignored.Load()
+	return s
+}
+
+// WithProgress returns a copy of ctx carrying p, so deep callers can report to it
+func WithProgress(ctx context.Context, p *Progress) context.Context {
+	return context.WithValue(ctx, progressKey{}, p)
+}
+
+// ProgressFrom returns the Progress stored in ctx, nil when there is none
+func ProgressFrom(ctx context.Context) *Progress {
+	p, _ := ctx.Value(progressKey{}).(*Progress)
+	return p
+}
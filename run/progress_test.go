@@ -0,0 +1,44 @@
+package run
+
+import (
+	"context"
+	"testing"
+)
+
+func TestProgressSnapshot(t *testing.T) {
+	p := NewProgress()
+	ctx := WithProgress(context.Background(), p)
+
+	ProgressFrom(ctx).Begin(PhaseLoad, 10)
+	ProgressFrom(ctx).Advance(PhaseLoad, 10)
+	ProgressFrom(ctx).End(PhaseLoad)
+	ProgressFrom(ctx).Begin(PhaseProcess, 8)
+	ProgressFrom(ctx).Written(2, 3, 1)
+
+	s := p.Snapshot()
+	tests := []struct {
+		phase             Phase
+		done, total       int64
+		started, finished bool
+	}{
+		{PhaseLoad, 10, 10, true, true},
+		{PhaseQuery, 0, 0, false, false},
+		{PhaseProcess, 6, 8, true, false},
+		{PhaseProcedure, 0, 0, false, false},
+	}
+	for _, tt := range tests {
+		got := s.Phases[tt.phase]
+		if got.Done != tt.done || got.Total != tt.total || got.Started != tt.started || got.Finished != tt.finished {
+			t.Errorf("%s = %+v; want done %d of %d, started %v, finished %v", tt.phase, got, tt.done, tt.total, tt.started, tt.finished)
+		}
+	}
+	if s.Inserted != 2 || s.Updated != 3 || s.Ignored != 1 {
+		t.Errorf("counters = %d/%d/%d; want 2/3/1", s.Inserted, s.Updated, s.Ignored)
+	}
+
+	// Without a Progress in ctx, reports are dropped
+	ProgressFrom(context.Background()).Written(1, 1, 1)
+	if n := len(ProgressFrom(context.Background()).Snapshot().Phases); n != 4 {
+		t.Errorf("nil Progress snapshot has %d phases; want 4", n)
+	}
+}
@@ -0,0 +1,197 @@
+package run
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// File names in the registry directory
+const (
+	markerSuffix = ".run"    // One per active run, heartbeated while it goes on
+	cancelSuffix = ".cancel" // Asks the run of the same name to stop
+	installLock  = "install.lock"
+)
+
+// Timing of the registry, variables for the tests
+var (
+	// registryHeartbeat is how often an active run touches its marker and
+	// looks for a cancel request
+	registryHeartbeat = 2 * time.Second
+	// staleAfter is how long a marker outlives the process that stopped
+	// heartbeating it, a crashed run
+	staleAfter = 5 * registryHeartbeat
+	// lockWait is how often Begin looks again at an install lock
+	lockWait = 100 * time.Millisecond
+	// staleLock is the age past which an install lock is of a crashed updater
+	staleLock = time.Minute
+)
+
+// ErrCancelled is the cause of the context of a run stopped by Cancel
+var ErrCancelled = errors.New("run cancelled to install an update")
+
+// ErrInstalling is returned by LockInstall while another update is being installed
+var ErrInstalling = errors.New("an update is being installed")
+
+// ActiveRun is a run in progress on this installation
+type ActiveRun struct {
+	ID      string    `json:"run_id"`
+	PID     int       `json:"pid"`
+	Started time.Time `json:"started"`
+}
+
+// Registry is the run state shared by the sync processes of an
+// installation, kept as files in a directory: each active run keeps a
+// marker there, and the updater takes an install lock, so the executable is
+// never replaced while a run is in progress, in this process or another.
+type Registry struct {
+	dir string
+}
+
+// NewRegistry returns the registry kept in dir, created on first use
+func NewRegistry(dir string) *Registry {
+	return &Registry{dir: dir}
+}
+
+// Begin records run id as active until end is called. While an update is
+// being installed it waits for the install to finish. The returned context
+// is cancelled, with cause ErrCancelled, when Cancel asks the run to stop.
+func (r *Registry) Begin(ctx context.Context, id string) (context.Context, func(), error) {
+	if err := os.MkdirAll(r.dir, 0o755); err != nil {
+		return nil, nil, fmt.Errorf("error creating run registry: %w", err)
+	}
+	data, err := json.Marshal(ActiveRun{ID: id, PID: os.Getpid(), Started: time.Now().UTC()})
+	if err != nil {
+		return nil, nil, err
+	}
+	marker := filepath.Join(r.dir, id+markerSuffix)
+
+	// The marker goes first: an updater taking the lock after it sees the run,
+	// one holding the lock before it is waited for
+	for {
+		if err := os.WriteFile(marker, data, 0o644); err != nil {
+			return nil, nil, fmt.Errorf("error registering run: %w", err)
+		}
+		if !r.installing() {
+			break
+		}
+		_ = os.Remove(marker)
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-time.After(lockWait):
+		}
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	cancelFile := filepath.Join(r.dir, id+cancelSuffix)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(registryHeartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				_ = os.Chtimes(marker, now, now)
+				if _, err := os.Stat(cancelFile); err == nil {
+					cancel(ErrCancelled)
+				}
+			}
+		}
+	}()
+
+	end := func() {
+		close(done)
+		<-stopped
+		cancel(nil)
+		_ = os.Remove(marker)
+		_ = os.Remove(cancelFile)
+	}
+	return ctx, end, nil
+}
+
+// Active returns the runs in progress, oldest first. Markers no longer
+// heartbeated, left by crashed processes, are removed.
+func (r *Registry) Active() ([]ActiveRun, error) {
+	entries, err := os.ReadDir(r.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading run registry: %w", err)
+	}
+
+	var runs []ActiveRun
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), markerSuffix) {
+			continue
+		}
+		path := filepath.Join(r.dir, e.Name())
+		info, err := e.Info()
+		if err != nil {
+			continue // Removed meanwhile: the run ended
+		}
+		if time.Since(info.ModTime()) > staleAfter {
+			_ = os.Remove(path)
+			_ = os.Remove(strings.TrimSuffix(path, markerSuffix) + cancelSuffix)
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var ar ActiveRun
+		if err := json.Unmarshal(data, &ar); err != nil {
+			// Being written: still a run in progress
+			ar.ID = strings.TrimSuffix(e.Name(), markerSuffix)
+		}
+		runs = append(runs, ar)
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].Started.Before(runs[j].Started) })
+	return runs, nil
+}
+
+// Cancel asks run id to stop; it notices within a heartbeat
+func (r *Registry) Cancel(id string) error {
+	if err := os.WriteFile(filepath.Join(r.dir, id+cancelSuffix), nil, 0o644); err != nil {
+		return fmt.Errorf("error cancelling run %s: %w", id, err)
+	}
+	return nil
+}
+
+// LockInstall takes the install lock, holding back the runs beginning until
+// release is called. It fails with ErrInstalling while another update holds it.
+func (r *Registry) LockInstall() (release func(), err error) {
+	if err := os.MkdirAll(r.dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating run registry: %w", err)
+	}
+	path := filepath.Join(r.dir, installLock)
+	if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > staleLock {
+		_ = os.Remove(path)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, os.ErrExist) {
+		return nil, ErrInstalling
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error locking the install: %w", err)
+	}
+	_ = f.Close()
+	return func() { _ = os.Remove(path) }, nil
+}
+
+// installing reports whether an updater holds a live install lock
+func (r *Registry) installing() bool {
+	info, err := os.Stat(filepath.Join(r.dir, installLock))
+	return err == nil && time.Since(info.ModTime()) <= staleLock
+}
@@ -0,0 +1,110 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRegistryActiveRuns(t *testing.T) {
+	reg := NewRegistry(filepath.Join(t.TempDir(), "runs"))
+	if runs, err := reg.Active(); err != nil || len(runs) != 0 {
+		t.Fatalf("Active() = %v, %v; want no runs", runs, err)
+	}
+
+	_, end, err := reg.Begin(context.Background(), "run-1")
+	if err != nil {
+		t.Fatalf("Begin() error = %v", err)
+	}
+	runs, err := reg.Active()
+	if err != nil || len(runs) != 1 || runs[0].ID != "run-1" || runs[0].PID != os.Getpid() {
+		t.Errorf("Active() = %+v, %v; want run-1 of this process", runs, err)
+	}
+
+	end()
+	if runs, _ := reg.Active(); len(runs) != 0 {
+		t.Errorf("Active() after end = %+v; want no runs", runs)
+	}
+}
+
+func TestRegistryDropsStaleRuns(t *testing.T) {
+	dir := t.TempDir()
+	reg := NewRegistry(dir)
+	marker := filepath.Join(dir, "crashed"+markerSuffix)
+	if err := os.WriteFile(marker, []byte(`{"run_id":"crashed"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * staleAfter)
+	if err := os.Chtimes(marker, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	if runs, _ := reg.Active(); len(runs) != 0 {
+		t.Errorf("Active() = %+v; want the stale run left out", runs)
+	}
+	if _, err := os.Stat(marker); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("stale marker kept: %v", err)
+	}
+}
+
+func TestRegistryCancel(t *testing.T) {
+	defer func(d time.Duration) { registryHeartbeat = d }(registryHeartbeat)
+	registryHeartbeat = 10 * time.Millisecond
+
+	reg := NewRegistry(t.TempDir())
+	ctx, end, err := reg.Begin(context.Background(), "run-1")
+	if err != nil {
+		t.Fatalf("Begin() error = %v", err)
+	}
+	defer end()
+
+	if err := reg.Cancel("run-1"); err != nil {
+		t.Fatalf("Cancel() error = %v", err)
+	}
+	select {
+	case <-ctx.Done():
+		if !errors.Is(context.Cause(ctx), ErrCancelled) {
+			t.Errorf("cause = %v; want ErrCancelled", context.Cause(ctx))
+		}
+	case <-time.After(time.Second):
+		t.Errorf("run not cancelled")
+	}
+}
+
+func TestRegistryBeginWaitsForInstall(t *testing.T) {
+	defer func(d time.Duration) { lockWait = d }(lockWait)
+	lockWait = 10 * time.Millisecond
+
+	reg := NewRegistry(t.TempDir())
+	release, err := reg.LockInstall()
+	if err != nil {
+		t.Fatalf("LockInstall() error = %v", err)
+	}
+	if _, err := reg.LockInstall(); !errors.Is(err, ErrInstalling) {
+		t.Errorf("second LockInstall() error = %v; want ErrInstalling", err)
+	}
+
+	begun := make(chan struct{})
+	go func() {
+		_, end, err := reg.Begin(context.Background(), "run-1")
+		if err == nil {
+			end()
+		}
+		close(begun)
+	}()
+	select {
+	case <-begun:
+		t.Fatalf("Begin() returned during the install")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	release()
+	select {
+	case <-begun:
+	case <-time.After(time.Second):
+		t.Errorf("Begin() still waiting after the install")
+	}
+}
@@ -0,0 +1,43 @@
+package run
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+type ctxKey struct{}
+
+// machineID identifies this installation; set once at startup
+var machineID string
+
+// SetMachineID records the installation's machine ID for the whole process
+func SetMachineID(id string) {
+	machineID = id
+}
+
+// MachineID returns the installation's machine ID, or an empty string before SetMachineID
+func MachineID() string {
+	return machineID
+}
+
+// NewID returns a sortable identifier for a sync run, e.g. 20250102T030405Z-1a2b3c4d
+func NewID() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().UTC().Format("20060102T150405.000000000Z")
+	}
+	return time.Now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(b)
+}
+
+// WithID returns a copy of ctx carrying the run ID
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// IDFrom returns the run ID stored in ctx, or an empty string
+func IDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
@@ -0,0 +1,71 @@
+package run
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type watchdogKey struct{}
+
+// Watchdog detects hung runs: when Touch has not been called for the
+// configured timeout, onHang is invoked once with the idle duration.
+type Watchdog struct {
+	timeout  time.Duration
+	last     atomic.Int64 // UnixNano of the last progress
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewWatchdog returns a watchdog that fires after timeout without progress
+func NewWatchdog(timeout time.Duration) *Watchdog {
+	w := &Watchdog{timeout: timeout, stop: make(chan struct{})}
+	w.Touch()
+	return w
+}
+
+// Touch records progress (a row read or a batch committed)
+func (w *Watchdog) Touch() {
+	w.last.Store(time.Now().UnixNano())
+}
+
+// Start checks for progress in the background until Stop is called.
+// onHang runs in the watchdog goroutine and is expected to terminate the process.
+func (w *Watchdog) Start(onHang func(idle time.Duration)) {
+	interval := min(max(w.timeout/10, 10*time.Millisecond), time.Second)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+				idle := time.Since(time.Unix(0, w.last.Load()))
+				if idle >= w.timeout {
+					onHang(idle)
+					return
+				}
+			}
+		}
+	}()
+}
+
+// Stop ends the background check
+func (w *Watchdog) Stop() {
+	w.stopOnce.Do(func() { close(w.stop) })
+}
+
+// WithWatchdog returns a copy of ctx carrying w, so deep callers can report progress
+func WithWatchdog(ctx context.Context, w *Watchdog) context.Context {
+	return context.WithValue(ctx, watchdogKey{}, w)
+}
+
+// Touch records progress on the watchdog stored in ctx, if any
+func Touch(ctx context.Context) {
+	if w, ok := ctx.Value(watchdogKey{}).(*Watchdog); ok {
+		w.Touch()
+	}
+}
@@ -0,0 +1,42 @@
+package run
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWatchdogFiresWithoutProgress(t *testing.T) {
+	fired := make(chan time.Duration, 1)
+	w := NewWatchdog(50 * time.Millisecond)
+	w.Start(func(idle time.Duration) { fired <- idle })
+	defer w.Stop()
+
+	select {
+	case idle := <-fired:
+		if idle < 50*time.Millisecond {
+			t.Errorf("watchdog fired after %v; want at least 50ms", idle)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("watchdog did not fire")
+	}
+}
+
+func TestWatchdogTouchDefersFiring(t *testing.T) {
+	fired := make(chan time.Duration, 1)
+	w := NewWatchdog(100 * time.Millisecond)
+	ctx := WithWatchdog(context.Background(), w)
+	w.Start(func(idle time.Duration) { fired <- idle })
+	defer w.Stop()
+
+	for i := 0; i < 6; i++ {
+		time.Sleep(40 * time.Millisecond)
+		Touch(ctx)
+	}
+
+	select {
+	case idle := <-fired:
+		t.Errorf("watchdog fired after %v despite progress", idle)
+	default:
+	}
+}
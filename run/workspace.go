@@ -0,0 +1,108 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type workspaceKey struct{}
+
+// workspacePrefix names the workspace directories under the root
+const workspacePrefix = "sync-"
+
+// Workspace is the temporary directory of one run, holding what it writes
+// besides its reports: spill files, staging files, diagnostic dumps. It is
+// removed when the run succeeds and kept for inspection when it fails.
+type Workspace struct {
+	dir string
+}
+
+// NewWorkspace creates the workspace of run id under root, the system
+// temporary directory when empty. Workspaces kept by failed runs are
+// removed once older than retention.
+func NewWorkspace(root, id string, retention time.Duration) (*Workspace, error) {
+	if root == "" {
+		root = os.TempDir()
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating work directory: %w", err)
+	}
+	pruneWorkspaces(root, retention)
+
+	dir := filepath.Join(root, workspacePrefix+id)
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("error creating run workspace: %w", err)
+	}
+	return &Workspace{dir: dir}, nil
+}
+
+// pruneWorkspaces removes the workspaces under root not modified within retention
+func pruneWorkspaces(root string, retention time.Duration) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return
+	}
+	cutoff := time.Now().Add(-retention)
+	for _, e := range entries {
+		if !e.IsDir() || !strings.HasPrefix(e.Name(), workspacePrefix) {
+			continue
+		}
+		if fi, err := e.Info(); err == nil && fi.ModTime().Before(cutoff) {
+			_ = os.RemoveAll(filepath.Join(root, e.Name()))
+		}
+	}
+}
+
+// Dir returns the workspace directory
+func (w *Workspace) Dir() string {
+	return w.dir
+}
+
+// Create creates a new file in the workspace, its name built from pattern
+// as by os.CreateTemp
+func (w *Workspace) Create(pattern string) (*os.File, error) {
+	return os.CreateTemp(w.dir, pattern)
+}
+
+// WriteFile writes a file of the given name into the workspace and returns its path
+func (w *Workspace) WriteFile(name string, data []byte) (string, error) {
+	path := filepath.Join(w.dir, name)
+	return path, os.WriteFile(path, data, 0o600)
+}
+
+// Close removes the workspace when the run succeeded, and keeps it,
+// returning its directory, when it failed
+func (w *Workspace) Close(succeeded bool) (kept string, err error) {
+	if !succeeded {
+		if empty(w.dir) {
+			return "", os.Remove(w.dir)
+		}
+		return w.dir, nil
+	}
+	if err := os.RemoveAll(w.dir); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	return "", nil
+}
+
+// empty reports whether dir holds no entries
+func empty(dir string) bool {
+	entries, err := os.ReadDir(dir)
+	return err == nil && len(entries) == 0
+}
+
+// WithWorkspace returns a copy of ctx carrying the run workspace
+func WithWorkspace(ctx context.Context, w *Workspace) context.Context {
+	return context.WithValue(ctx, workspaceKey{}, w)
+}
+
+// WorkspaceFrom returns the run workspace stored in ctx, or nil
+func WorkspaceFrom(ctx context.Context) *Workspace {
+	w, _ := ctx.Value(workspaceKey{}).(*Workspace)
+	return w
+}
@@ -0,0 +1,55 @@
+package run
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWorkspace(t *testing.T) {
+	root := t.TempDir()
+
+	tests := []struct {
+		name      string
+		write     bool
+		succeeded bool
+		kept      bool
+	}{
+		{"success", true, true, false},
+		{"failure", true, false, true},
+		{"empty failure", false, false, false},
+	}
+	for _, tt := range tests {
+		ws, err := NewWorkspace(root, tt.name, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.write {
+			if _, err := ws.WriteFile("dump.txt", []byte("dump")); err != nil {
+				t.Fatal(err)
+			}
+		}
+		kept, err := ws.Close(tt.succeeded)
+		if err != nil {
+			t.Fatalf("%s: Close returned error: %v", tt.name, err)
+		}
+		_, statErr := os.Stat(ws.Dir())
+		if (kept != "") != tt.kept || (statErr == nil) != tt.kept {
+			t.Errorf("%s: kept %q, stat error %v; want kept %v", tt.name, kept, statErr, tt.kept)
+		}
+	}
+
+	// Kept workspaces are removed by later runs once past the retention
+	old := filepath.Join(root, workspacePrefix+"failure")
+	past := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(old, past, past); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewWorkspace(root, "next", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Errorf("expired workspace not removed: %v", err)
+	}
+}
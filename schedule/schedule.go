@@ -0,0 +1,191 @@
+// Package schedule parses the cron expressions of daemon jobs and decides
+// which job runs next. Expressions have the five standard fields
+//
+//	minute hour day-of-month month day-of-week
+//
+// each "*", a value, a range "a-b" or a comma-separated list of them, with an
+// optional step ("*/5", "8-18/2"). Months and weekdays may be given by their
+// English three-letter names, and Sunday is 0 or 7. When both day fields are
+// restricted a day matching either runs, as in cron. The descriptors @hourly,
+// @daily (@midnight), @weekly, @monthly and @yearly (@annually) are accepted.
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Spec is a parsed cron expression
+type Spec struct {
+	expr                          string
+	minute, hour, dom, month, dow uint64 // Bit i set when value i matches
+	anyDom, anyDow                bool   // The day field is "*"
+}
+
+// descriptors are the @ shorthands and the expressions they stand for
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// field describes the values one cron field accepts
+type field struct {
+	name     string
+	min, max int
+	names    []string // Names of the values from min on, matched case-insensitively
+}
+
+var (
+	minuteField = field{name: "minute", min: 0, max: 59}
+	hourField   = field{name: "hour", min: 0, max: 23}
+	domField    = field{name: "day of month", min: 1, max: 31}
+	monthField  = field{name: "month", min: 1, max: 12, names: []string{"JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}}
+	dowField    = field{name: "day of week", min: 0, max: 7, names: []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}}
+)
+
+// Parse parses a cron expression, e.g. "*/5 * * * *" or "0 2 * * MON-FRI"
+func Parse(expr string) (*Spec, error) {
+	expr = strings.TrimSpace(expr)
+	fields := strings.Fields(expr)
+	if d, ok := descriptors[strings.ToLower(expr)]; ok {
+		fields = strings.Fields(d)
+	}
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q: expected 5 fields (minute hour day-of-month month day-of-week)", expr)
+	}
+
+	s := &Spec{expr: expr, anyDom: fields[2] == "*", anyDow: fields[4] == "*"}
+	var err error
+	for i, f := range []struct {
+		bits *uint64
+		def  field
+	}{{&s.minute, minuteField}, {&s.hour, hourField}, {&s.dom, domField}, {&s.month, monthField}, {&s.dow, dowField}} {
+		if *f.bits, err = f.def.parse(fields[i]); err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+		}
+	}
+	// Sunday is both 0 and 7
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	return s, nil
+}
+
+// String returns the expression as written
+func (s *Spec) String() string {
+	return s.expr
+}
+
+// MarshalText makes the spec log and render as its expression
+func (s *Spec) MarshalText() ([]byte, error) {
+	return []byte(s.expr), nil
+}
+
+// Next returns the first time matching the spec strictly after t, in t's location.
+// It returns the zero time when nothing matches within five years (e.g. "0 0 30 2 *").
+func (s *Spec) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// Latest returns the last time matching the spec after from and no later
+// than until, or the zero time when there is none. Only the year before
+// until is searched.
+func (s *Spec) Latest(from, until time.Time) time.Time {
+	if limit := until.AddDate(-1, 0, 0); from.Before(limit) {
+		from = limit
+	}
+	var latest time.Time
+	for t := s.Next(from); !t.IsZero() && !t.After(until); t = s.Next(t) {
+		latest = t
+	}
+	return latest
+}
+
+// dayMatches applies the cron rule for the two day fields
+func (s *Spec) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.anyDom || s.anyDow {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// parse returns the bit set of the values matched by a field
+func (f field) parse(s string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(s, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q in %s field", stepStr, f.name)
+			}
+			step = n
+		}
+
+		lo, hi := f.min, f.max
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = f.value(a); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = f.value(b); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				hi = f.max
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range %q in %s field", rng, f.name)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// value parses a single number or name of the field
+func (f field) value(s string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(s, name) {
+			return f.min + i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid %s %q (expected %d-%d)", f.name, s, f.min, f.max)
+	}
+	return v, nil
+}
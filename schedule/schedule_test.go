@@ -0,0 +1,134 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+// at returns a UTC time on 2024-01-<day> (Jan 1st 2024 is a Monday)
+func at(day, hour, minute int) time.Time {
+	return time.Date(2024, 1, day, hour, minute, 0, 0, time.UTC)
+}
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"*/5 * * * *", at(1, 10, 2), at(1, 10, 5)},
+		{"*/5 * * * *", at(1, 10, 5), at(1, 10, 10)},
+		{"0 2 * * *", at(1, 2, 0), at(2, 2, 0)},
+		{"0 2 * * *", at(1, 1, 59), at(1, 2, 0)},
+		{"30 8-18/2 * * *", at(1, 9, 0), at(1, 10, 30)},
+		{"0 3 * * SUN", at(1, 0, 0), at(7, 3, 0)},
+		{"0 3 * * 7", at(1, 0, 0), at(7, 3, 0)},
+		{"0 0 * * mon-fri", at(5, 12, 0), at(8, 0, 0)},
+		{"0 0 15 * MON", at(2, 0, 0), at(8, 0, 0)}, // Either day field matches
+		{"0 0 1 feb *", at(1, 0, 0), time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", at(1, 0, 0), time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", at(1, 0, 0), at(7, 0, 0)},
+		{"@hourly", at(1, 10, 0), at(1, 11, 0)},
+		{"0 0 30 2 *", at(1, 0, 0), time.Time{}},
+		{"0,30 * * * *", at(1, 10, 15), at(1, 10, 30)},
+	}
+
+	for _, tt := range tests {
+		s, err := Parse(tt.expr)
+		if err != nil {
+			t.Errorf("Parse(%q) returned error: %v", tt.expr, err)
+			continue
+		}
+		if got := s.Next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("Parse(%q).Next(%s) = %s; want %s", tt.expr, tt.from, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "*/0 * * * *", "5-1 * * * *", "* * * * FOO", "@often"} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("Parse(%q) expected error", bad)
+		}
+	}
+}
+
+func TestSchedulerOverlap(t *testing.T) {
+	every5, _ := Parse("*/5 * * * *")
+	nightly, _ := Parse("0 2 * * *")
+	s := NewScheduler([]Entry{
+		{Name: "full", Spec: nightly},
+		{Name: "quantity", Spec: every5},
+		{Name: "queued", Spec: every5, Queue: true},
+	}, at(1, 1, 58))
+
+	if got := s.Next(); !got.Equal(at(1, 2, 0)) {
+		t.Fatalf("Next() = %s; want 02:00", got)
+	}
+
+	// All three are due at 02:00; the first one in order runs
+	run, skipped, ok := s.Pop(at(1, 2, 0))
+	if !ok || run.Name != "full" || len(skipped) != 0 {
+		t.Fatalf("Pop(02:00) = %q, %v, %v; want full", run.Name, skipped, ok)
+	}
+
+	// The full run took 7 minutes: the missed quantity occurrence is dropped
+	// and the queued one runs once
+	run, skipped, ok = s.Pop(at(1, 2, 7))
+	if !ok || run.Name != "queued" || len(skipped) != 1 || skipped[0].Name != "quantity" {
+		t.Fatalf("Pop(02:07) = %q, %v, %v; want queued with quantity skipped", run.Name, skipped, ok)
+	}
+	if _, _, ok = s.Pop(at(1, 2, 8)); ok {
+		t.Errorf("Pop(02:08) ran a job; missed occurrences must not repeat")
+	}
+	if got := s.Pending("quantity"); !got.Equal(at(1, 2, 10)) {
+		t.Errorf("Pending(quantity) = %s; want 02:10", got)
+	}
+	if got := s.Pending("full"); !got.Equal(at(2, 2, 0)) {
+		t.Errorf("Pending(full) = %s; want the next night", got)
+	}
+}
+
+func TestSchedulerCatchUp(t *testing.T) {
+	nightly, _ := Parse("0 2 * * *")
+	lastRun := at(1, 2, 0)
+	now := at(2, 7, 30) // The host was off at 02:00 on the 2nd
+
+	tests := []struct {
+		catchUp time.Duration
+		want    bool
+	}{
+		{0, false},
+		{4 * time.Hour, false},
+		{6 * time.Hour, true},
+		{CatchUpAlways, true},
+	}
+	for _, tt := range tests {
+		s := NewScheduler([]Entry{{Name: "full", Spec: nightly, LastRun: lastRun, CatchUp: tt.catchUp}}, now)
+		missed, caught := s.Missed("full")
+		if !missed.Equal(at(2, 2, 0)) || caught != tt.want {
+			t.Errorf("CatchUp %s: Missed() = %s, %v; want 02:00, %v", tt.catchUp, missed, caught, tt.want)
+		}
+		if _, _, ok := s.Pop(now); ok != tt.want {
+			t.Errorf("CatchUp %s: Pop at startup ran = %v; want %v", tt.catchUp, ok, tt.want)
+		}
+		if got := s.Pending("full"); !got.Equal(at(3, 2, 0)) {
+			t.Errorf("CatchUp %s: Pending() = %s; want 02:00 on the 3rd", tt.catchUp, got)
+		}
+	}
+
+	// Nothing was missed when the last run is recent
+	s := NewScheduler([]Entry{{Name: "full", Spec: nightly, LastRun: at(2, 2, 1), CatchUp: CatchUpAlways}}, now)
+	if missed, _ := s.Missed("full"); !missed.IsZero() {
+		t.Errorf("Missed() = %s; want none", missed)
+	}
+}
+
+func TestSchedulerJitter(t *testing.T) {
+	nightly, _ := Parse("0 2 * * *")
+	for i := 0; i < 100; i++ {
+		s := NewScheduler([]Entry{{Name: "full", Spec: nightly, Jitter: 10 * time.Minute}}, at(1, 0, 0))
+		got := s.Pending("full")
+		if got.Before(at(1, 2, 0)) || !got.Before(at(1, 2, 10)) {
+			t.Fatalf("Pending() = %s; want within 10 minutes after 02:00", got)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package schedule
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// Grace is how late an occurrence may start and still count as on time;
+// later ones were missed while another run was active
+const Grace = time.Minute
+
+// CatchUpAlways makes an entry run at startup for a missed occurrence of any age
+const CatchUpAlways = time.Duration(math.MaxInt64)
+
+// Entry is a job known to a Scheduler
+type Entry struct {
+	Name  string
+	Spec  *Spec
+	Queue bool // Run once after a busy period instead of dropping the missed occurrences
+
+	// Occurrences are delayed by a random duration up to Jitter, so many
+	// installations sharing a schedule do not start at the same instant
+	Jitter time.Duration
+
+	// An occurrence missed since LastRun (the host was off) that is at most
+	// CatchUp old runs at startup; 0 skips missed occurrences
+	LastRun time.Time
+	CatchUp time.Duration
+}
+
+// Scheduler decides which entry runs next. Runs are sequential: an occurrence
+// falling while a run is active is dropped, or run once as soon as the run
+// finishes for queued entries. Entries due at the same time run in order, so
+// the later ones are subject to their overlap rule.
+type Scheduler struct {
+	entries []Entry
+	next    []time.Time // Next occurrence of each entry, zero when it never runs
+	missed  []time.Time // Occurrence missed before startup, per entry
+	caught  []bool      // The missed occurrence runs at startup
+	jitter  func(max time.Duration) time.Duration
+}
+
+// NewScheduler schedules entries from now on; missed occurrences allowed by
+// their catch-up window are due immediately
+func NewScheduler(entries []Entry, now time.Time) *Scheduler {
+	s := &Scheduler{
+		entries: entries,
+		next:    make([]time.Time, len(entries)),
+		missed:  make([]time.Time, len(entries)),
+		caught:  make([]bool, len(entries)),
+		jitter:  func(max time.Duration) time.Duration { return rand.N(max) },
+	}
+	for i, e := range entries {
+		if !e.LastRun.IsZero() {
+			s.missed[i] = e.Spec.Latest(e.LastRun, now)
+		}
+		if !s.missed[i].IsZero() && now.Sub(s.missed[i]) <= e.CatchUp {
+			s.next[i] = now
+			s.caught[i] = true
+			continue
+		}
+		s.next[i] = s.after(e, now)
+	}
+	return s
+}
+
+// after returns the first occurrence of e after t, jitter included
+func (s *Scheduler) after(e Entry, t time.Time) time.Time {
+	next := e.Spec.Next(t)
+	if !next.IsZero() && e.Jitter > 0 {
+		next = next.Add(s.jitter(e.Jitter))
+	}
+	return next
+}
+
+// Next returns the earliest pending occurrence, zero when no entry ever runs
+func (s *Scheduler) Next() time.Time {
+	var next time.Time
+	for _, t := range s.next {
+		if !t.IsZero() && (next.IsZero() || t.Before(next)) {
+			next = t
+		}
+	}
+	return next
+}
+
+// Pending returns the next occurrence of the named entry
+func (s *Scheduler) Pending(name string) time.Time {
+	if i := s.index(name); i >= 0 {
+		return s.next[i]
+	}
+	return time.Time{}
+}
+
+// Missed returns the last occurrence of the named entry missed before startup,
+// zero when none was, and whether it is caught up
+func (s *Scheduler) Missed(name string) (at time.Time, caughtUp bool) {
+	i := s.index(name)
+	if i < 0 {
+		return time.Time{}, false
+	}
+	return s.missed[i], s.caught[i]
+}
+
+// Pop returns the entry to run at now, if any, together with the entries whose
+// occurrences were dropped because they were missed. The schedules of both are
+// advanced past now; further missed occurrences of an entry are not repeated.
+func (s *Scheduler) Pop(now time.Time) (run Entry, skipped []Entry, ok bool) {
+	for i, e := range s.entries {
+		due := s.next[i]
+		if due.IsZero() || due.After(now) {
+			continue
+		}
+		s.next[i] = s.after(e, now)
+		if now.Sub(due) >= Grace && !e.Queue {
+			skipped = append(skipped, e)
+			continue
+		}
+		return e, skipped, true
+	}
+	return Entry{}, skipped, false
+}
+
+// index returns the position of the named entry, -1 when unknown
+func (s *Scheduler) index(name string) int {
+	for i, e := range s.entries {
+		if e.Name == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/db"
+	"github.com/waldirborbajr/sync/output"
+	"github.com/waldirborbajr/sync/state"
+)
+
+// stateUsage documents the state subcommand
+const stateUsage = "state verify [--repair] | reset [--force] watermarks|jobs|caches|locks|blobs|all..."
+
+// Parts of the persisted state reset by "sync state reset", besides the
+// state file parts of package state
+const (
+	resetLocks = "locks" // This machine's runs left active in TB_SYNC_INSTANCIAS
+	resetBlobs = "blobs" // TB_SYNC_BLOBS digests: every BLOB is written again
+)
+
+// resetParts are the parts "all" resets
+var resetParts = []string{state.ResetWatermarks, state.ResetJobs, state.ResetCaches, resetLocks, resetBlobs}
+
+// stateVerifyInfo is the output of "sync state verify"
+type stateVerifyInfo struct {
+	StateFile     string        `json:"state_file" yaml:"state_file"`
+	TablesChecked bool          `json:"tables_checked" yaml:"tables_checked"`
+	Issues        []state.Issue `json:"issues" yaml:"issues"`
+	Repaired      bool          `json:"repaired" yaml:"repaired"`
+	Backup        string        `json:"backup,omitempty" yaml:"backup,omitempty"` // Copy of the state file before the repair
+}
+
+// stateResetInfo is the output of "sync state reset"
+type stateResetInfo struct {
+	Reset        []string `json:"reset" yaml:"reset"`
+	Backup       string   `json:"backup,omitempty" yaml:"backup,omitempty"` // Copy of the state file before the reset
+	RunsFinished int64    `json:"runs_finished" yaml:"runs_finished"`
+	BlobDigests  int64    `json:"blob_digests" yaml:"blob_digests"`
+}
+
+// stateCommand verifies, repairs or resets the persisted state: the state
+// file and the MySQL tables holding run state
+func stateCommand(env *commandEnv) int {
+	if len(env.args) == 0 {
+		fmt.Fprintf(os.Stderr, "usage: sync %s\n", stateUsage)
+		return 2
+	}
+	switch env.args[0] {
+	case "verify":
+		return stateVerify(env, env.args[1:])
+	case "reset":
+		return stateReset(env, env.args[1:])
+	}
+	fmt.Fprintf(os.Stderr, "usage: sync %s\n", stateUsage)
+	return 2
+}
+
+// stateVerify checks the state file and tables and, with --repair, fixes
+// what it found after backing the state file up
+func stateVerify(env *commandEnv, args []string) int {
+	repair := false
+	for _, arg := range args {
+		if arg != "--repair" {
+			fmt.Fprintf(os.Stderr, "%sError:%s unexpected argument %q\nusage: sync %s\n", redBold, reset, arg, stateUsage)
+			return 2
+		}
+		repair = true
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+		return exitConfig
+	}
+	if repair {
+		if err := db.CheckWritable(cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "%sError:%s %v - the state cannot be repaired\n", redBold, reset, err)
+			return 1
+		}
+	}
+
+	jobs := make([]string, len(cfg.Jobs))
+	for i, j := range cfg.Jobs {
+		jobs[i] = j.Name
+	}
+	report, err := state.Verify(cfg.StateFile, jobs, time.Now())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+		return 1
+	}
+	info := stateVerifyInfo{StateFile: cfg.StateFile, Issues: append([]state.Issue{}, report.Issues...)}
+
+	if mysqlConn, err := db.ConnectMySQL(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "%sWarning:%s state tables not checked: %v\n", yellowBold, reset, err)
+	} else {
+		defer func() { _ = mysqlConn.Close() }()
+		issues, err := db.CheckStateTables(context.Background(), mysqlConn, cfg, repair)
+		info.Issues = append(info.Issues, issues...)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+			return 1
+		}
+		info.TablesChecked = true
+	}
+
+	if repair && len(info.Issues) > 0 {
+		if len(report.Issues) > 0 {
+			if info.Backup, err = report.Repair(backupSuffix()); err != nil {
+				fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+				return 1
+			}
+		}
+		info.Repaired = true
+	}
+
+	code := renderStateVerify(env, info)
+	if code == 0 && len(info.Issues) > 0 && !info.Repaired {
+		return exitStateIssues
+	}
+	return code
+}
+
+// renderStateVerify prints the verification; in table format the issues
+// and what was, or would be, done about them
+func renderStateVerify(env *commandEnv, info stateVerifyInfo) int {
+	if env.output != output.FormatTable {
+		return env.render(info)
+	}
+	if len(info.Issues) == 0 {
+		fmt.Printf("%sState is sound%s (%s", greenBold, reset, info.StateFile)
+		if info.TablesChecked {
+			fmt.Print(", TB_SYNC_INSTANCIAS, TB_SYNC_BLOBS")
+		}
+		fmt.Println(")")
+		return 0
+	}
+	if code := env.render(info.Issues); code != 0 {
+		return code
+	}
+	fmt.Println()
+	switch {
+	case !info.Repaired:
+		fmt.Printf("%d problems found; 'sync state verify --repair' applies the fixes above\n", len(info.Issues))
+	case info.Backup != "":
+		fmt.Printf("%d problems fixed; the previous state file is kept as %s\n", len(info.Issues), info.Backup)
+	default:
+		fmt.Printf("%d problems fixed\n", len(info.Issues))
+	}
+	return 0
+}
+
+// stateReset clears the named parts of the persisted state. It refuses
+// while a run of this machine is active, unless --force is given.
+func stateReset(env *commandEnv, args []string) int {
+	force := false
+	var parts []string
+	for _, arg := range args {
+		switch {
+		case arg == "--force":
+			force = true
+		case arg == "all":
+			parts = append(parts, resetParts...)
+		case slices.Contains(resetParts, arg):
+			parts = append(parts, arg)
+		default:
+			fmt.Fprintf(os.Stderr, "%sError:%s unexpected argument %q\nusage: sync %s\n", redBold, reset, arg, stateUsage)
+			return 2
+		}
+	}
+	if len(parts) == 0 {
+		fmt.Fprintf(os.Stderr, "%sError:%s nothing to reset\nusage: sync %s\n", redBold, reset, stateUsage)
+		return 2
+	}
+	slices.Sort(parts)
+	parts = slices.Compact(parts)
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+		return exitConfig
+	}
+	if err := db.CheckWritable(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v - the state cannot be reset\n", redBold, reset, err)
+		return 1
+	}
+
+	ctx := context.Background()
+	machineID := ""
+	if st, err := state.Load(cfg.StateFile); err == nil {
+		machineID = st.MachineID
+	}
+	var mysqlConn *sql.DB
+	if conn, err := db.ConnectMySQL(cfg); err != nil {
+		if !force || slices.Contains(parts, resetLocks) || slices.Contains(parts, resetBlobs) {
+			fmt.Fprintf(os.Stderr, "%sError:%s cannot check for an active run: %v (--force resets the state file without checking)\n", redBold, reset, err)
+			return 1
+		}
+	} else {
+		mysqlConn = conn
+		defer func() { _ = mysqlConn.Close() }()
+	}
+	if mysqlConn != nil && machineID != "" && !force {
+		runID, active, err := db.ActiveRun(ctx, mysqlConn, machineID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%sError:%s cannot check for an active run: %v\n", redBold, reset, err)
+			return 1
+		}
+		if active {
+			fmt.Fprintf(os.Stderr, "%sError:%s run %s of this machine is active; wait for it to finish or pass --force\n", redBold, reset, runID)
+			return 1
+		}
+	}
+
+	// The state file first: it is the part most likely to fail
+	info := stateResetInfo{Reset: parts}
+	var fileParts []string
+	for _, part := range parts {
+		if part != resetLocks && part != resetBlobs {
+			fileParts = append(fileParts, part)
+		}
+	}
+	if len(fileParts) > 0 {
+		if info.Backup, err = state.Reset(cfg.StateFile, fileParts, backupSuffix()); err != nil {
+			fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+			return 1
+		}
+	}
+	if slices.Contains(parts, resetLocks) {
+		if machineID == "" {
+			fmt.Fprintf(os.Stderr, "%sError:%s the machine ID cannot be read from %s; run 'sync state verify --repair' first\n", redBold, reset, cfg.StateFile)
+			return 1
+		}
+		if info.RunsFinished, err = db.ResetInstance(ctx, mysqlConn, machineID); err != nil {
+			fmt.Fprintf(os.Stderr, "%sError:%s error resetting TB_SYNC_INSTANCIAS: %v\n", redBold, reset, err)
+			return 1
+		}
+	}
+	if slices.Contains(parts, resetBlobs) {
+		if info.BlobDigests, err = db.ResetBlobDigests(ctx, mysqlConn); err != nil {
+			fmt.Fprintf(os.Stderr, "%sError:%s error resetting TB_SYNC_BLOBS: %v\n", redBold, reset, err)
+			return 1
+		}
+	}
+	return env.render(info)
+}
+
+// backupSuffix is appended to the state file name to back it up before a change
+func backupSuffix() string {
+	return ".bak-" + time.Now().Format("20060102T150405.000000")
+}
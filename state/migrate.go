@@ -0,0 +1,141 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// migrations[v] rewrites a decoded state file of schema version v into
+// version v+1. Every change to the file layout adds one, with SchemaVersion.
+var migrations = [SchemaVersion]func(doc map[string]json.RawMessage) error{
+	0: func(map[string]json.RawMessage) error { return nil }, // Version 1 added the version and checksum
+	1: groupCaches,
+	2: func(map[string]json.RawMessage) error { return nil }, // Version 3 added the backfill checkpoints
+}
+
+// stateV1 is the layout of version 1 files, whose checksum is computed over it
+type stateV1 struct {
+	Version   int    `json:"version,omitempty"`
+	MachineID string `json:"machine_id,omitempty"`
+
+	Maintenance       bool      `json:"maintenance"`
+	MaintenanceSince  time.Time `json:"maintenance_since,omitzero"`
+	MaintenanceReason string    `json:"maintenance_reason,omitempty"`
+
+	Watermarks map[string]time.Time `json:"watermarks,omitempty"`
+	JobRuns    map[string]time.Time `json:"job_runs,omitempty"`
+
+	ExchangeRate   float64   `json:"exchange_rate,omitempty"`
+	ExchangeRateAt time.Time `json:"exchange_rate_at,omitzero"`
+
+	FeatureFlags   map[string]bool `json:"feature_flags,omitempty"`
+	FeatureFlagsAt time.Time       `json:"feature_flags_at,omitzero"`
+
+	Checksum string `json:"checksum,omitempty"`
+}
+
+// groupCaches moves the values fetched from remote services, top-level
+// members in version 1, under "caches"
+func groupCaches(doc map[string]json.RawMessage) error {
+	caches := make(map[string]json.RawMessage)
+	for _, key := range []string{"exchange_rate", "exchange_rate_at", "feature_flags", "feature_flags_at"} {
+		if value, ok := doc[key]; ok {
+			caches[key] = value
+			delete(doc, key)
+		}
+	}
+	if len(caches) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(caches)
+	if err != nil {
+		return err
+	}
+	doc["caches"] = data
+	return nil
+}
+
+// decode decodes a state file of any version up to SchemaVersion, migrating
+// it to the current layout, and returns the version it was written with. A
+// file of a newer version is not decoded.
+func decode(data []byte) (State, int, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return State{}, 0, err
+	}
+	if doc == nil {
+		return State{}, 0, errors.New("not a JSON object")
+	}
+	version := 0
+	if raw, ok := doc["version"]; ok {
+		if err := json.Unmarshal(raw, &version); err != nil {
+			return State{}, 0, fmt.Errorf("invalid version: %w", err)
+		}
+	}
+	if version > SchemaVersion {
+		return State{}, version, nil
+	}
+	if version < 0 {
+		return State{}, version, fmt.Errorf("invalid version %d", version)
+	}
+
+	for v := version; v < SchemaVersion; v++ {
+		if err := migrations[v](doc); err != nil {
+			return State{}, version, fmt.Errorf("error migrating from version %d: %w", v, err)
+		}
+	}
+	migrated, err := json.Marshal(doc)
+	if err != nil {
+		return State{}, version, err
+	}
+	var st State
+	if err := json.Unmarshal(migrated, &st); err != nil {
+		return State{}, version, err
+	}
+	st.Version = SchemaVersion
+	return st, version, nil
+}
+
+// checksumValid reports whether a file written with the given version
+// matches its checksum, computed over the layout of that version
+func checksumValid(version int, data []byte) bool {
+	switch version {
+	case 0:
+		return true // Files predating checksums
+	case 1:
+		var st stateV1
+		if err := json.Unmarshal(data, &st); err != nil {
+			return false
+		}
+		want := st.Checksum
+		st.Checksum = ""
+		sum, err := digest(st)
+		return err == nil && sum == want
+	}
+	var st State
+	if err := json.Unmarshal(data, &st); err != nil {
+		return false
+	}
+	sum, err := checksum(st)
+	return err == nil && sum == st.Checksum
+}
+
+// backupPath is where a state file of the given version is kept when migrated
+func backupPath(path string, version int) string {
+	return fmt.Sprintf("%s.v%d", path, version)
+}
+
+// migrateFile keeps the state file of the given version next to it, for a
+// return to the version of sync that wrote it, and saves it migrated
+func migrateFile(path string, version int, st State) error {
+	if err := copyFile(path, backupPath(path, version)); err != nil && !errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("error keeping the state file before migrating it: %w", err)
+	}
+	if err := Save(path, st); err != nil {
+		return fmt.Errorf("error saving the migrated state file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMigrate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sync_state.json")
+	fetched := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	v1 := stateV1{Version: 1, MachineID: "0123456789abcdef", ExchangeRate: 5.42, ExchangeRateAt: fetched, FeatureFlags: map[string]bool{"hash_preload": true}}
+	sum, err := digest(v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1.Checksum = sum
+	data, err := json.Marshal(v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load of a version 1 state returned error: %v", err)
+	}
+	if st.Version != SchemaVersion || st.MachineID != v1.MachineID || st.Caches.ExchangeRate != 5.42 || !st.Caches.ExchangeRateAt.Equal(fetched) || !st.Caches.FeatureFlags["hash_preload"] {
+		t.Errorf("migrated state = %+v", st)
+	}
+	if kept, err := os.ReadFile(path + ".v1"); err != nil || string(kept) != string(data) {
+		t.Errorf("version 1 file not kept as %s.v1: %v", path, err)
+	}
+	if again, err := Load(path); err != nil || again.Caches.ExchangeRate != 5.42 {
+		t.Errorf("Load of the migrated state = %+v, %v", again, err)
+	}
+
+	// Going back to an older sync is refused with guidance
+	newer := strings.Replace(string(data), `"version":1`, fmt.Sprintf(`"version":%d`, SchemaVersion+1), 1)
+	if err := os.WriteFile(path, []byte(newer), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); !errors.Is(err, ErrNewerVersion) || !strings.Contains(err.Error(), "move it away") {
+		t.Errorf("Load of a newer state = %v; want ErrNewerVersion", err)
+	}
+}
@@ -0,0 +1,206 @@
+package state
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SchemaVersion is the version of the state file written by Save. Older
+// files are migrated by Load; files of version 0 carry no checksum.
+const SchemaVersion = 3
+
+// ErrCorrupt is returned by Load for a state file that cannot be trusted;
+// "sync state verify --repair" fixes it
+var ErrCorrupt = errors.New("state file corrupt")
+
+// ErrNewerVersion is returned by Load for a state file written by a newer
+// version of sync, which this one cannot read without losing what it does
+// not know about
+var ErrNewerVersion = errors.New("state file from a newer version")
+
+// State is the persisted operational state shared by every sync invocation
+type State struct {
+	Version   int    `json:"version,omitempty"`
+	MachineID string `json:"machine_id,omitempty"`
+
+	Maintenance       bool      `json:"maintenance"`
+	MaintenanceSince  time.Time `json:"maintenance_since,omitzero"`
+	MaintenanceReason string    `json:"maintenance_reason,omitempty"`
+
+	// Highest source modification time synced, per table, for incremental runs
+	Watermarks map[string]time.Time `json:"watermarks,omitempty"`
+
+	// Start of the last run of each daemon job, to catch up on missed schedules
+	JobRuns map[string]time.Time `json:"job_runs,omitempty"`
+
+	// Unfinished "sync backfill" runs, per SYNC_TABLES mapping
+	Backfills map[string]Backfill `json:"backfills,omitempty"`
+
+	// Values fetched from remote services
+	Caches Caches `json:"caches,omitzero"`
+
+	// SHA-256 of the file written with an empty checksum, set by Save
+	Checksum string `json:"checksum,omitempty"`
+}
+
+// Backfill is the checkpoint of a backfill: the range [From, To) is synced
+// in chunks of Chunk, and every row before Done has been
+type Backfill struct {
+	From  time.Time `json:"from"`
+	To    time.Time `json:"to"`
+	Chunk string    `json:"chunk"`
+	Done  time.Time `json:"done"`
+}
+
+// Caches holds the last values fetched from remote services, reused while
+// they are unreachable; clearing them only costs a fetch
+type Caches struct {
+	// Last USD/BRL rate fetched for PRC_DOLAR and when (EXCHANGE_RATE_CACHE_TTL)
+	ExchangeRate   float64   `json:"exchange_rate,omitempty"`
+	ExchangeRateAt time.Time `json:"exchange_rate_at,omitzero"`
+
+	// Last feature flags fetched from FEATURE_FLAGS_URL and when, used while it is unreachable
+	FeatureFlags   map[string]bool `json:"feature_flags,omitempty"`
+	FeatureFlagsAt time.Time       `json:"feature_flags_at,omitzero"`
+}
+
+// checksum returns the checksum of st
+func checksum(st State) (string, error) {
+	st.Checksum = ""
+	return digest(st)
+}
+
+// digest returns the SHA-256 of v encoded as JSON
+func digest(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Load reads the state file; a missing file yields the zero State. A file
+// of an older schema version is migrated and saved, its original kept next
+// to it with a ".v<version>" suffix. A file that cannot be decoded or fails
+// its checksum is refused with ErrCorrupt, one from a newer version with
+// ErrNewerVersion.
+func Load(path string) (State, error) {
+	var st State
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return st, fmt.Errorf("error reading state file: %w", err)
+	}
+	st, version, err := decode(data)
+	if err != nil {
+		return State{}, fmt.Errorf("%w: error decoding %s: %v", ErrCorrupt, path, err)
+	}
+	if version > SchemaVersion {
+		return State{}, newerVersion(path, version)
+	}
+	if !checksumValid(version, data) {
+		return State{}, fmt.Errorf("%w: %s fails its checksum, see 'sync state verify'", ErrCorrupt, path)
+	}
+	if version < SchemaVersion {
+		if err := migrateFile(path, version, st); err != nil {
+			return State{}, err
+		}
+	}
+	return st, nil
+}
+
+// newerVersion describes a state file written by a newer version and what
+// to do about it
+func newerVersion(path string, version int) error {
+	previous := backupPath(path, SchemaVersion)
+	advice := "move it away to start from an empty state (incremental runs then read every row once)"
+	if _, err := os.Stat(previous); err == nil {
+		advice = "restore " + previous + ", kept when it was migrated, to go back to this version"
+	}
+	return fmt.Errorf("%w: %s has schema version %d and this version of sync reads up to %d; run the newer sync again, or %s",
+		ErrNewerVersion, path, version, SchemaVersion, advice)
+}
+
+// tempPattern names the temporary files Save writes before renaming them
+const tempPattern = ".sync_state-*"
+
+// Save writes the state file atomically (temporary file + rename), so a
+// crash mid-write never leaves a truncated state behind
+func Save(path string, st State) error {
+	st.Version = SchemaVersion
+	sum, err := checksum(st)
+	if err != nil {
+		return fmt.Errorf("error encoding state: %w", err)
+	}
+	st.Checksum = sum
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding state: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), tempPattern)
+	if err != nil {
+		return fmt.Errorf("error creating temporary state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing state file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error replacing state file: %w", err)
+	}
+	return nil
+}
+
+// Update loads the state, applies fn and saves the result
+func Update(path string, fn func(*State)) (State, error) {
+	st, err := Load(path)
+	if err != nil {
+		return st, err
+	}
+	fn(&st)
+	return st, Save(path, st)
+}
+
+// EnsureMachineID returns the persisted machine ID, generating and saving a
+// new random one on first use. The ID stays stable across runs, hostname
+// changes and binary upgrades, and identifies the installation in logs,
+// run records and fleet reporting.
+func EnsureMachineID(path string) (string, error) {
+	st, err := Load(path)
+	if err != nil {
+		return "", err
+	}
+	if st.MachineID != "" {
+		return st.MachineID, nil
+	}
+
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating machine ID: %w", err)
+	}
+	id := hex.EncodeToString(b)
+
+	st, err = Update(path, func(s *State) {
+		if s.MachineID == "" {
+			s.MachineID = id
+		}
+	})
+	return st.MachineID, err
+}
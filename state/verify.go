@@ -0,0 +1,232 @@
+package state
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+)
+
+// futureTolerance is how far in the future a recorded time may be before it
+// is considered invalid. Watermarks come from the Firebird clock, which may
+// run ahead of the local one.
+const futureTolerance = 24 * time.Hour
+
+// staleTempAge is the age past which a temporary file left by Save belongs
+// to no write in progress
+const staleTempAge = time.Minute
+
+// Issue is a problem found in the persisted state, with what repairing does about it
+type Issue struct {
+	Store   string `json:"store" yaml:"store"` // State file or table
+	Item    string `json:"item" yaml:"item"`
+	Problem string `json:"problem" yaml:"problem"`
+	Fix     string `json:"fix" yaml:"fix"`
+}
+
+// Report is the result of Verify
+type Report struct {
+	Path    string
+	Exists  bool
+	Corrupt bool    // The file cannot be decoded at all
+	Issues  []Issue // Empty when the file is sound
+	State   State   // The state with every issue fixed
+	temps   []string
+}
+
+// Verify checks the state file at path without trusting it: that it decodes,
+// its checksum, the recorded times and the machine ID,
+// the daemon jobs against jobs, and temporary files left by interrupted
+// writes. The report holds the state as Repair would write it.
+func Verify(path string, jobs []string, now time.Time) (*Report, error) {
+	r := &Report{Path: path}
+	if temps, err := filepath.Glob(filepath.Join(filepath.Dir(path), tempPattern)); err == nil {
+		for _, t := range temps {
+			if fi, err := os.Stat(t); err == nil && now.Sub(fi.ModTime()) > staleTempAge {
+				r.temps = append(r.temps, t)
+				r.add("temporary file", filepath.Base(t)+" left by an interrupted write", "remove it")
+			}
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading state file: %w", err)
+	}
+	r.Exists = true
+
+	st, version, err := decode(data)
+	if err != nil {
+		r.Corrupt = true
+		r.add("file", "cannot be decoded: "+err.Error(), "back it up and start from an empty state; incremental runs read everything once")
+		return r, nil
+	}
+	if version > SchemaVersion {
+		return nil, newerVersion(path, version)
+	}
+	if !checksumValid(version, data) {
+		r.add("checksum", "does not match the contents, the file was edited or damaged", "check the entries below, then record the checksum of what remains")
+	}
+
+	if st.MachineID != "" {
+		if b, err := hex.DecodeString(st.MachineID); err != nil || len(b) != 8 {
+			r.add("machine_id", fmt.Sprintf("%q is not 16 hexadecimal digits", st.MachineID), "remove it; a new machine ID is generated on the next run")
+			st.MachineID = ""
+		}
+	}
+	if st.Maintenance && st.MaintenanceSince.IsZero() {
+		r.add("maintenance_since", "maintenance is on without a start time", "set it to now")
+		st.MaintenanceSince = now
+	}
+
+	for _, table := range sortedKeys(st.Watermarks) {
+		if wm := st.Watermarks[table]; wm.IsZero() || wm.After(now.Add(futureTolerance)) {
+			r.add("watermarks."+table, describeTime(wm), "remove it; the next run reads every row")
+			delete(st.Watermarks, table)
+		}
+	}
+	for _, job := range sortedKeys(st.JobRuns) {
+		switch at := st.JobRuns[job]; {
+		case !slices.Contains(jobs, job):
+			r.add("job_runs."+job, "not a SYNC_JOBS job", "remove it")
+			delete(st.JobRuns, job)
+		case at.IsZero() || at.After(now.Add(futureTolerance)):
+			r.add("job_runs."+job, describeTime(at), "remove it; missed runs are not caught up")
+			delete(st.JobRuns, job)
+		}
+	}
+	for _, table := range slices.Sorted(maps.Keys(st.Backfills)) {
+		if b := st.Backfills[table]; !b.From.Before(b.To) || b.Done.Before(b.From) || b.Done.After(b.To) {
+			r.add("backfills."+table, fmt.Sprintf("checkpoint %s outside the range %s to %s", b.Done.Format(time.RFC3339), b.From.Format(time.RFC3339), b.To.Format(time.RFC3339)), "remove it; the backfill starts over")
+			delete(st.Backfills, table)
+		}
+	}
+	if c := &st.Caches; c.ExchangeRate < 0 || c.ExchangeRate > 0 && (c.ExchangeRateAt.IsZero() || c.ExchangeRateAt.After(now.Add(futureTolerance))) {
+		r.add("caches.exchange_rate", fmt.Sprintf("rate %g fetched at %s", c.ExchangeRate, describeTime(c.ExchangeRateAt)), "remove it; the rate is fetched again")
+		c.ExchangeRate, c.ExchangeRateAt = 0, time.Time{}
+	}
+	if c := &st.Caches; c.FeatureFlagsAt.After(now.Add(futureTolerance)) {
+		r.add("caches.feature_flags_at", describeTime(c.FeatureFlagsAt), "remove the cached flags; they are fetched again")
+		c.FeatureFlags, c.FeatureFlagsAt = nil, time.Time{}
+	}
+
+	r.State = st
+	return r, nil
+}
+
+// add records an issue of the state file
+func (r *Report) add(item, problem, fix string) {
+	r.Issues = append(r.Issues, Issue{Store: r.Path, Item: item, Problem: problem, Fix: fix})
+}
+
+// Repair fixes the issues found: the original file is copied next to it
+// with the given suffix, the repaired state saved and stale temporary files
+// removed. It returns the path of the copy, empty when there was no file.
+func (r *Report) Repair(suffix string) (backup string, err error) {
+	if r.Exists {
+		backup = r.Path + suffix
+		if err := copyFile(r.Path, backup); err != nil {
+			return "", fmt.Errorf("error backing up state file: %w", err)
+		}
+		if err := Save(r.Path, r.State); err != nil {
+			return backup, err
+		}
+	}
+	for _, t := range r.temps {
+		if err := os.Remove(t); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return backup, fmt.Errorf("error removing temporary state file: %w", err)
+		}
+	}
+	return backup, nil
+}
+
+// copyFile copies src to dst, which must not exist
+func copyFile(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// describeTime describes an invalid recorded time
+func describeTime(t time.Time) string {
+	if t.IsZero() {
+		return "no time recorded"
+	}
+	return t.Format(time.RFC3339) + " is in the future"
+}
+
+// sortedKeys returns the keys of m in order
+func sortedKeys(m map[string]time.Time) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Parts of the state file cleared by Reset
+const (
+	ResetWatermarks = "watermarks" // Incremental watermarks: the next run reads every row
+	ResetJobs       = "jobs"       // Last daemon job runs: missed runs are not caught up
+	ResetCaches     = "caches"     // Cached exchange rate and feature flags: fetched again
+)
+
+// Reset clears the given parts of the state file after copying it next to
+// itself with suffix, and returns the path of the copy, empty when there was
+// no file. The machine ID and the maintenance flag are kept, unless the file
+// cannot be decoded at all and is replaced by an empty state. The checksum
+// is not checked: the state is saved with a new one.
+func Reset(path string, parts []string, suffix string) (backup string, err error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error reading state file: %w", err)
+	}
+
+	st, version, err := decode(data)
+	if err != nil {
+		st = State{}
+	}
+	if version > SchemaVersion {
+		return "", newerVersion(path, version)
+	}
+	for _, part := range parts {
+		switch part {
+		case ResetWatermarks:
+			st.Watermarks = nil
+		case ResetJobs:
+			st.JobRuns = nil
+		case ResetCaches:
+			st.Caches = Caches{}
+		default:
+			return "", fmt.Errorf("unknown state part %q", part)
+		}
+	}
+
+	backup = path + suffix
+	if err := copyFile(path, backup); err != nil {
+		return "", fmt.Errorf("error backing up state file: %w", err)
+	}
+	return backup, Save(path, st)
+}
@@ -0,0 +1,89 @@
+package state
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChecksum(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sync_state.json")
+	wm := time.Date(2026, 10, 16, 13, 45, 0, 123, time.FixedZone("BRT", -3*3600))
+	if err := Save(path, State{MachineID: "0123456789abcdef", Watermarks: map[string]time.Time{"TB_ESTOQUE": wm}}); err != nil {
+		t.Fatal(err)
+	}
+	st, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load of a saved state returned error: %v", err)
+	}
+	if st.Version != SchemaVersion || !st.Watermarks["TB_ESTOQUE"].Equal(wm) {
+		t.Errorf("Load = %+v", st)
+	}
+
+	data, _ := os.ReadFile(path)
+	edited := strings.Replace(string(data), "2026-10-16", "2026-10-17", 1)
+	if err := os.WriteFile(path, []byte(edited), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Load of an edited state = %v; want ErrCorrupt", err)
+	}
+
+	// Files written before checksums are accepted
+	if err := os.WriteFile(path, []byte(`{"machine_id": "0123456789abcdef"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err != nil {
+		t.Errorf("Load of a version 0 state returned error: %v", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sync_state.json")
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	contents := `{
+		"machine_id": "not-hex",
+		"watermarks": {"TB_ESTOQUE": "2026-10-16T11:00:00Z", "TB_OTHER": "2027-01-01T00:00:00Z"},
+		"job_runs": {"nightly": "2026-10-16T03:00:00Z", "removed": "2026-10-15T03:00:00Z"}
+	}`
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Verify(path, []string{"nightly"}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var items []string
+	for _, issue := range r.Issues {
+		items = append(items, issue.Item)
+	}
+	if got, want := strings.Join(items, " "), "machine_id watermarks.TB_OTHER job_runs.removed"; got != want {
+		t.Errorf("Verify issues = %q; want %q", got, want)
+	}
+
+	backup, err := r.Repair(".bak")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(backup); err != nil {
+		t.Errorf("backup not written: %v", err)
+	}
+	st, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load of the repaired state returned error: %v", err)
+	}
+	if st.MachineID != "" || len(st.Watermarks) != 1 || len(st.JobRuns) != 1 {
+		t.Errorf("repaired state = %+v", st)
+	}
+
+	if err := os.WriteFile(path, []byte("{truncated"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if r, err := Verify(path, nil, now); err != nil || !r.Corrupt {
+		t.Errorf("Verify of an undecodable file = %+v, %v; want corrupt", r, err)
+	}
+}
@@ -0,0 +1,123 @@
+package syncer
+
+import (
+	"time"
+
+	"github.com/waldirborbajr/sync/run"
+)
+
+// RunStarted is sent once both databases are open
+type RunStarted struct {
+	RunID   string
+	At      time.Time
+	Workers int
+}
+
+// Progress is sent while a run is going, with its phases and counts so far
+type Progress struct {
+	RunID string
+	At    time.Time
+	run.Snapshot
+}
+
+// RunFinished is sent when a run completed
+type RunFinished struct {
+	Result
+	At time.Time
+}
+
+// RunFailed is sent when a run failed or a hook refused it
+type RunFailed struct {
+	RunID string
+	At    time.Time
+	Err   error
+	Class string // Error class, see db.Classify
+}
+
+// events are the callbacks registered for each event type
+type events struct {
+	onStarted  []func(RunStarted)
+	onProgress []func(Progress)
+	onFinished []func(RunFinished)
+	onFailed   []func(RunFailed)
+}
+
+// OnRunStarted calls fn when a run starts
+func OnRunStarted(fn func(RunStarted)) Option {
+	return func(e *Engine) { e.events.onStarted = append(e.events.onStarted, fn) }
+}
+
+// OnProgress calls fn every interval while a run is going, every second
+// when interval is 0
+func OnProgress(interval time.Duration, fn func(Progress)) Option {
+	return func(e *Engine) {
+		if interval < 0 {
+			e.invalid("invalid progress interval %s", interval)
+			return
+		}
+		if interval > 0 {
+			e.progress = interval
+		}
+		e.events.onProgress = append(e.events.onProgress, fn)
+	}
+}
+
+// OnRunFinished calls fn when a run completes
+func OnRunFinished(fn func(RunFinished)) Option {
+	return func(e *Engine) { e.events.onFinished = append(e.events.onFinished, fn) }
+}
+
+// OnRunFailed calls fn when a run fails
+func OnRunFailed(fn func(RunFailed)) Option {
+	return func(e *Engine) { e.events.onFailed = append(e.events.onFailed, fn) }
+}
+
+func (ev *events) started(e RunStarted) {
+	for _, fn := range ev.onStarted {
+		fn(e)
+	}
+}
+
+func (ev *events) finished(e RunFinished) {
+	for _, fn := range ev.onFinished {
+		fn(e)
+	}
+}
+
+func (ev *events) failed(e RunFailed) {
+	for _, fn := range ev.onFailed {
+		fn(e)
+	}
+}
+
+// reportProgress sends Progress events of p until the returned function is
+// called, which sends the last one
+func (e *Engine) reportProgress(runID string, p *run.Progress) (stop func()) {
+	send := func() {
+		ev := Progress{RunID: runID, At: time.Now(), Snapshot: p.Snapshot()}
+		for _, fn := range e.events.onProgress {
+			fn(ev)
+		}
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(e.progress)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				send()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+		send()
+	}
+}
@@ -0,0 +1,295 @@
+// Package syncer embeds the Firebird to MySQL synchronization in other Go
+// programs, configured with options instead of environment variables:
+//
+//	import "github.com/waldirborbajr/sync/syncer"
+//
+//	result, err := syncer.New(
+//		syncer.WithSource(firebirdDB),
+//		syncer.WithDestination(mysqlDB),
+//		syncer.WithSettings(map[string]string{"LUCRO": "35", "AUDIT_ENABLED": "true"}),
+//		syncer.WithMapping("DESCRICAO", "NOME"),
+//		syncer.OnRunFinished(func(e syncer.RunFinished) { metrics.Observe(e.Elapsed) }),
+//	).Run(ctx)
+//
+// A run is the product sync of the sync command with its tables, customers
+// and hooks, without what belongs to the command around it: the watchdog,
+// the heap guard, recovery runs and the instance registry.
+package syncer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/db"
+	"github.com/waldirborbajr/sync/limits"
+	"github.com/waldirborbajr/sync/notify"
+	"github.com/waldirborbajr/sync/processor"
+	"github.com/waldirborbajr/sync/run"
+)
+
+// Engine runs synchronizations configured by its options
+type Engine struct {
+	settings map[string]string // WithSettings
+	mappings map[string]string // WithMapping, PRODUCT_COLUMN_MAP entries
+	cfg      *config.Config    // WithConfig, replacing settings and mappings
+	source   *sql.DB           // Firebird, opened from the settings when nil
+	dest     *sql.DB           // MySQL, opened from the settings when nil
+	workers  int               // 0 sizes the pool from MAX_WORKERS and the CPUs
+	hooks    []Hook
+	channels []notify.Channel // WithNotifier
+	events   events           // Typed callbacks
+	err      error            // First invalid option, returned by Run
+	progress time.Duration    // Interval of the Progress events
+}
+
+// Option configures an Engine
+type Option func(*Engine)
+
+// Hook runs around every run. A Before error ends the run before it touches
+// either database; After receives the result, nil when the run failed.
+type Hook struct {
+	Before func(ctx context.Context, runID string) error
+	After  func(ctx context.Context, result *Result, err error)
+}
+
+// Result is what a run wrote
+type Result struct {
+	RunID    string
+	Inserted int
+	Updated  int
+	Ignored  int
+	Elapsed  time.Duration
+	Stats    *processor.ProcessingStats // Everything else the run measured
+}
+
+// New returns an Engine configured by opts. Invalid options are reported by Run.
+func New(opts ...Option) *Engine {
+	e := &Engine{progress: time.Second}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// invalid records the first invalid option
+func (e *Engine) invalid(format string, args ...any) {
+	if e.err == nil {
+		e.err = fmt.Errorf(format, args...)
+	}
+}
+
+// WithSettings sets configuration values keyed like the .env file. Unset
+// keys take their defaults; the process environment is never read.
+func WithSettings(settings map[string]string) Option {
+	return func(e *Engine) {
+		if e.settings == nil {
+			e.settings = make(map[string]string, len(settings))
+		}
+		for k, v := range settings {
+			e.settings[k] = v
+		}
+	}
+}
+
+// WithConfig uses cfg, e.g. from config.LoadConfig, instead of settings
+func WithConfig(cfg config.Config) Option {
+	return func(e *Engine) { e.cfg = &cfg }
+}
+
+// WithSource syncs from the Firebird database db, which stays open
+func WithSource(db *sql.DB) Option {
+	return func(e *Engine) { e.source = db }
+}
+
+// WithDestination syncs into the MySQL database db, which stays open
+func WithDestination(db *sql.DB) Option {
+	return func(e *Engine) { e.dest = db }
+}
+
+// WithMapping writes the product field, one of config.ProductFields, to the
+// TB_ESTOQUE column, or leaves it out when column is "-", as an entry of
+// PRODUCT_COLUMN_MAP
+func WithMapping(field, column string) Option {
+	return func(e *Engine) {
+		if e.mappings == nil {
+			e.mappings = make(map[string]string)
+		}
+		e.mappings[field] = column
+	}
+}
+
+// WithWorkers sets the number of workers writing to MySQL
+func WithWorkers(n int) Option {
+	return func(e *Engine) {
+		if n < 1 {
+			e.invalid("invalid number of workers %d", n)
+			return
+		}
+		e.workers = n
+	}
+}
+
+// WithHook runs h around every run, after the hooks added before it
+func WithHook(h Hook) Option {
+	return func(e *Engine) { e.hooks = append(e.hooks, h) }
+}
+
+// WithNotifier sends the outcome of every run through c
+func WithNotifier(c notify.Channel) Option {
+	return func(e *Engine) {
+		if c.Template == nil {
+			c.Template, _ = notify.ParseTemplate(c.Name, "")
+		}
+		if c.MinSeverity == "" {
+			c.MinSeverity = notify.SeverityInfo
+		}
+		e.channels = append(e.channels, c)
+	}
+}
+
+// Run runs one synchronization and returns what it wrote
+func (e *Engine) Run(ctx context.Context) (*Result, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	cfg, err := e.config()
+	if err != nil {
+		return nil, err
+	}
+
+	runID := run.NewID()
+	ctx = run.WithID(ctx, runID)
+	result, err := e.run(ctx, cfg, runID)
+	for _, h := range e.hooks {
+		if h.After != nil {
+			h.After(ctx, result, err)
+		}
+	}
+	if err != nil {
+		e.events.failed(RunFailed{RunID: runID, At: time.Now(), Err: err, Class: string(db.Classify(err))})
+	} else {
+		e.events.finished(RunFinished{Result: *result, At: time.Now()})
+	}
+	e.notify(ctx, runID, result, err)
+	return result, err
+}
+
+// run runs the hooks and the sync of runID
+func (e *Engine) run(ctx context.Context, cfg config.Config, runID string) (*Result, error) {
+	for _, h := range e.hooks {
+		if h.Before == nil {
+			continue
+		}
+		if err := h.Before(ctx, runID); err != nil {
+			return nil, fmt.Errorf("hook refused run: %w", err)
+		}
+	}
+
+	source, dest, release, err := e.open(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	workers := e.workers
+	if workers == 0 {
+		workers = limits.Workers(cfg)
+	}
+	e.events.started(RunStarted{RunID: runID, At: time.Now(), Workers: workers})
+
+	if len(e.events.onProgress) > 0 {
+		p := run.NewProgress()
+		ctx = run.WithProgress(ctx, p)
+		stop := e.reportProgress(runID, p)
+		defer stop()
+	}
+
+	start := time.Now()
+	inserted, updated, ignored, _, stats, err := processor.ProcessRows(ctx, source, dest, workers, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{RunID: runID, Inserted: inserted, Updated: updated, Ignored: ignored, Elapsed: time.Since(start), Stats: stats}, nil
+}
+
+// config returns the configuration of the options
+func (e *Engine) config() (config.Config, error) {
+	if e.cfg != nil {
+		if e.settings != nil || e.mappings != nil {
+			return config.Config{}, errors.New("WithConfig excludes WithSettings and WithMapping")
+		}
+		return *e.cfg, nil
+	}
+
+	values := make(map[string]string, len(e.settings)+1)
+	for k, v := range e.settings {
+		values[k] = v
+	}
+	if len(e.mappings) > 0 {
+		pairs := make([]string, 0, len(e.mappings))
+		for field, column := range e.mappings {
+			pairs = append(pairs, field+":"+column)
+		}
+		sort.Strings(pairs)
+		if m := values["PRODUCT_COLUMN_MAP"]; m != "" {
+			pairs = append([]string{m}, pairs...)
+		}
+		values["PRODUCT_COLUMN_MAP"] = strings.Join(pairs, ",")
+	}
+	return config.Parse(values)
+}
+
+// open returns the source and destination databases, opening those not
+// given as options, and a function closing what it opened
+func (e *Engine) open(cfg config.Config) (source, dest *sql.DB, release func(), err error) {
+	var opened []*sql.DB
+	release = func() {
+		for _, conn := range opened {
+			_ = conn.Close()
+		}
+	}
+
+	source, dest = e.source, e.dest
+	if source == nil {
+		if source, err = db.ConnectFirebird(cfg); err != nil {
+			return nil, nil, nil, fmt.Errorf("firebird unreachable: %w", err)
+		}
+		opened = append(opened, source)
+	}
+	if dest == nil {
+		if dest, err = db.ConnectMySQL(cfg); err != nil {
+			release()
+			return nil, nil, nil, fmt.Errorf("mysql unreachable: %w", err)
+		}
+		opened = append(opened, dest)
+	}
+	return source, dest, release, nil
+}
+
+// notify sends the outcome of the run through the notifiers
+func (e *Engine) notify(ctx context.Context, runID string, result *Result, err error) {
+	if len(e.channels) == 0 {
+		return
+	}
+	host, _ := os.Hostname()
+	ev := notify.Event{Severity: notify.SeverityInfo, Host: host, RunID: runID, At: time.Now()}
+	if err != nil {
+		ev.Severity, ev.Title, ev.Text = notify.SeverityError, "Sync failed on "+host, err.Error()
+	} else {
+		ev.Title = "Sync finished on " + host
+		ev.Text = fmt.Sprintf("%d inserted, %d updated, %d unchanged in %s", result.Inserted, result.Updated, result.Ignored, result.Elapsed.Round(time.Second))
+		if n := len(result.Stats.RejectedRows); n > 0 {
+			ev.Severity = notify.SeverityWarning
+			ev.Text += fmt.Sprintf("; %d rows rejected by MySQL", n)
+		}
+	}
+	// Deliveries are best effort: the run's outcome is its result
+	_ = notify.Send(context.WithoutCancel(ctx), e.channels, ev)
+}
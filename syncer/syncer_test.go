@@ -0,0 +1,75 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/waldirborbajr/sync/config"
+)
+
+func TestConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		column  string // Column of DESCRICAO
+		wantErr bool
+	}{
+		{"defaults", nil, "DESCRICAO", false},
+		{"mapping", []Option{WithMapping("DESCRICAO", "NOME")}, "NOME", false},
+		{"mapping and settings", []Option{WithSettings(map[string]string{"PRODUCT_COLUMN_MAP": "PRC_DOLAR:-"}), WithMapping("DESCRICAO", "NOME")}, "NOME", false},
+		{"invalid setting", []Option{WithSettings(map[string]string{"LUCRO": "-5"})}, "", true},
+		{"config and settings", []Option{WithConfig(mustParse(t)), WithSettings(map[string]string{"LUCRO": "35"})}, "", true},
+	}
+	for _, tt := range tests {
+		cfg, err := New(tt.opts...).config()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: config() error = %v; want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err == nil && cfg.ProductColumn("DESCRICAO") != tt.column {
+			t.Errorf("%s: DESCRICAO written to %q; want %q", tt.name, cfg.ProductColumn("DESCRICAO"), tt.column)
+		}
+	}
+}
+
+func TestRunRefusedByHook(t *testing.T) {
+	refused := errors.New("maintenance window")
+	var started, afterCalled bool
+	var failed []RunFailed
+	result, err := New(
+		WithHook(Hook{Before: func(context.Context, string) error { return refused }}),
+		WithHook(Hook{After: func(_ context.Context, r *Result, err error) { afterCalled = r == nil && errors.Is(err, refused) }}),
+		OnRunStarted(func(RunStarted) { started = true }),
+		OnRunFailed(func(e RunFailed) { failed = append(failed, e) }),
+	).Run(context.Background())
+
+	if result != nil || !errors.Is(err, refused) {
+		t.Fatalf("Run() = %v, %v; want the hook's error", result, err)
+	}
+	if started {
+		t.Errorf("RunStarted sent for a refused run")
+	}
+	if !afterCalled {
+		t.Errorf("After hook not called with the error")
+	}
+	if len(failed) != 1 || failed[0].RunID == "" || !errors.Is(failed[0].Err, refused) {
+		t.Errorf("RunFailed events %+v; want one with the run ID and the error", failed)
+	}
+}
+
+func TestInvalidOption(t *testing.T) {
+	if _, err := New(WithWorkers(0)).Run(context.Background()); err == nil {
+		t.Errorf("Run() with 0 workers: expected error")
+	}
+}
+
+// mustParse returns the default configuration
+func mustParse(t *testing.T) config.Config {
+	t.Helper()
+	cfg, err := config.Parse(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/db"
+	"github.com/waldirborbajr/sync/output"
+	"github.com/waldirborbajr/sync/processor"
+)
+
+// traceUsage documents the trace subcommand
+const traceUsage = "trace --id ID_ESTOQUE"
+
+// traceSummary is the summary of "sync trace"
+type traceSummary struct {
+	IDEstoque  int    `json:"id_estoque" yaml:"id_estoque"`
+	InFirebird bool   `json:"in_firebird" yaml:"in_firebird"`
+	Read       bool   `json:"read" yaml:"read"` // Returned by the run's source query
+	InMySQL    bool   `json:"in_mysql" yaml:"in_mysql"`
+	Outcome    string `json:"outcome" yaml:"outcome"` // What a run would do with the row now
+}
+
+// traceValue is a Firebird column of the traced row
+type traceValue struct {
+	Column string `json:"column" yaml:"column"`
+	Value  string `json:"value" yaml:"value"`
+}
+
+// traceColumn is a MySQL column of the traced row
+type traceColumn struct {
+	Column   string `json:"column" yaml:"column"`
+	Stored   string `json:"stored" yaml:"stored"`
+	Expected string `json:"expected" yaml:"expected"`
+	Differs  bool   `json:"differs" yaml:"differs"`
+}
+
+// traceEvent is a recorded change of the traced row
+type traceEvent struct {
+	At     time.Time `json:"at,omitzero" yaml:"at,omitempty"`
+	RunID  string    `json:"run_id" yaml:"run_id"`
+	Source string    `json:"source" yaml:"source"`
+	Detail string    `json:"detail" yaml:"detail"`
+}
+
+// traceInfo is the output of "sync trace" in json and yaml
+type traceInfo struct {
+	Summary  traceSummary  `json:"summary" yaml:"summary"`
+	Firebird []traceValue  `json:"firebird" yaml:"firebird"`
+	MySQL    []traceColumn `json:"mysql" yaml:"mysql"`
+	Rules    []string      `json:"rules" yaml:"rules"`
+	History  []traceEvent  `json:"history" yaml:"history"`
+}
+
+// traceCommand prints everything known about one product, the first thing
+// support needs when a price or quantity looks wrong on the webshop
+func traceCommand(env *commandEnv) int {
+	id, err := parseTraceArgs(env.args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\nusage: sync %s\n", redBold, reset, err, traceUsage)
+		return 2
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+		return exitConfig
+	}
+	firebirdConn, err := db.ConnectFirebird(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+		return 1
+	}
+	defer func() { _ = firebirdConn.Close() }()
+	mysqlConn, err := db.ConnectMySQL(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+		return 1
+	}
+	defer func() { _ = mysqlConn.Close() }()
+
+	t, err := processor.Trace(context.Background(), firebirdConn, mysqlConn, cfg, id)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+		return 1
+	}
+
+	info := traceInfo{
+		Summary:  traceSummary{IDEstoque: t.IDEstoque, InFirebird: t.InFirebird, Read: t.Read, InMySQL: t.Stored, Outcome: t.Outcome},
+		Firebird: make([]traceValue, len(t.Firebird)),
+		MySQL:    make([]traceColumn, len(t.Columns)),
+		Rules:    append([]string{}, t.Rules...),
+		History:  make([]traceEvent, len(t.History)),
+	}
+	for i, v := range t.Firebird {
+		info.Firebird[i] = traceValue(v)
+	}
+	for i, c := range t.Columns {
+		info.MySQL[i] = traceColumn(c)
+	}
+	for i, e := range t.History {
+		info.History[i] = traceEvent(e)
+	}
+	return renderTrace(env, info)
+}
+
+// renderTrace prints the trace: in table format one section after another,
+// leaving out the empty ones
+func renderTrace(env *commandEnv, info traceInfo) int {
+	if env.output != output.FormatTable {
+		return env.render(info)
+	}
+	if code := env.render(info.Summary); code != 0 {
+		return code
+	}
+	sections := []struct {
+		title string
+		v     any
+		n     int
+	}{
+		{"Firebird", info.Firebird, len(info.Firebird)},
+		{"MySQL TB_ESTOQUE", info.MySQL, len(info.MySQL)},
+		{"History", info.History, len(info.History)},
+	}
+	for _, s := range sections {
+		if s.n == 0 {
+			continue
+		}
+		fmt.Printf("\n%s\n", s.title)
+		if code := env.render(s.v); code != 0 {
+			return code
+		}
+	}
+	if len(info.Rules) > 0 {
+		fmt.Println("\nRules")
+		for _, r := range info.Rules {
+			fmt.Println("  " + r)
+		}
+	}
+	return 0
+}
+
+// parseTraceArgs reads the --id flag of args
+func parseTraceArgs(args []string) (int, error) {
+	id, found := 0, false
+	for i := 0; i < len(args); i++ {
+		name, value, inline := strings.Cut(args[i], "=")
+		if name != "--id" {
+			return 0, fmt.Errorf("unexpected argument %q", args[i])
+		}
+		if !inline {
+			if i+1 >= len(args) {
+				return 0, fmt.Errorf("%s requires a value", name)
+			}
+			i++
+			value = args[i]
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid --id %q: expected an ID_ESTOQUE", value)
+		}
+		id, found = n, true
+	}
+	if !found {
+		return 0, fmt.Errorf("--id is required")
+	}
+	return id, nil
+}
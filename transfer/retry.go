@@ -0,0 +1,103 @@
+package transfer
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/db"
+	"github.com/waldirborbajr/sync/logger"
+	"github.com/waldirborbajr/sync/run"
+)
+
+// Retrier repeats batch writes that failed with a transient error (deadlock,
+// lock wait timeout, dropped connection) with exponential backoff. A batch
+// is written in one transaction, so a failed attempt leaves nothing behind.
+// It also counts, per class, every error the run survives.
+type Retrier struct {
+	attempts int           // Retries per batch, 0 disables
+	backoff  time.Duration // Wait before the first retry, doubled for each further one
+
+	mu        sync.Mutex
+	retries   map[string]int // Retries per error class
+	exhausted int            // Batches that still failed after every retry
+	errors    map[string]int // Errors per class, retried attempts included
+}
+
+// NewRetrier returns the retrier configured by BATCH_RETRIES and BATCH_RETRY_BACKOFF
+func NewRetrier(cfg config.Config) *Retrier {
+	return &Retrier{attempts: cfg.BatchRetries, backoff: cfg.BatchRetryBackoff}
+}
+
+// Do runs write, retrying it while it fails with a retryable error class.
+// what and rows describe the batch in the logs.
+func (r *Retrier) Do(ctx context.Context, what string, rows int, write func() error) error {
+	log := logger.GetLogger()
+
+	backoff := r.backoff
+	for attempt := 1; ; attempt++ {
+		err := write()
+		r.Record(err)
+		if err == nil || !db.IsRetryable(err) || ctx.Err() != nil {
+			return err
+		}
+		if attempt > r.attempts {
+			if r.attempts > 0 {
+				r.mu.Lock()
+				r.exhausted++
+				r.mu.Unlock()
+				log.Error().Err(err).Str("batch", what).Int("count", rows).Int("retries", r.attempts).Msg("Batch still failing after retries")
+			}
+			return err
+		}
+
+		class := db.Classify(err)
+		r.mu.Lock()
+		if r.retries == nil {
+			r.retries = make(map[string]int)
+		}
+		r.retries[string(class)]++
+		r.mu.Unlock()
+
+		log.Warn().
+			Err(err).
+			Str("batch", what).
+			Int("count", rows).
+			Str("error_class", string(class)).
+			Int("attempt", attempt).
+			Int("max_attempts", r.attempts).
+			Dur("backoff", backoff).
+			Msg("Batch failed with a retryable error, retrying")
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return err
+		}
+		run.Touch(ctx)
+		backoff *= 2
+	}
+}
+
+// Record counts err, if any, under its class
+func (r *Retrier) Record(err error) {
+	if err == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.errors == nil {
+		r.errors = make(map[string]int)
+	}
+	r.errors[string(db.Classify(err))]++
+}
+
+// Counts returns the retries and the errors per class, nil when there were
+// none, and the batches that still failed after every retry
+func (r *Retrier) Counts() (retries map[string]int, exhausted int, errors map[string]int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return maps.Clone(r.retries), r.exhausted, maps.Clone(r.errors)
+}
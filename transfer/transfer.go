@@ -0,0 +1,89 @@
+// Package transfer holds what every path writing batches of rows to MySQL
+// shares: multi-row statements sized to max_allowed_packet and the placeholder
+// limit, and the Retrier repeating batches that failed with a transient error.
+package transfer
+
+import (
+	"strings"
+	"time"
+
+	"github.com/waldirborbajr/sync/money"
+)
+
+// MaxPlaceholders is the MySQL limit of placeholders in one prepared statement
+const MaxPlaceholders = 65535
+
+// packetShare is the part of max_allowed_packet a single statement may use,
+// leaving room for the statement text and protocol overhead
+const packetShare = 0.75
+
+// StatementLimit returns the bytes of values a single statement may carry
+// under a max_allowed_packet of packet bytes
+func StatementLimit(packet int) int {
+	return int(float64(packet) * packetShare)
+}
+
+// Chunk splits rows into groups whose multi-row statement stays within limit
+// bytes, as estimated by size, and the placeholder limit, with perRow
+// placeholders a row. A row larger than limit gets a group of its own.
+func Chunk[T any](rows []T, perRow, limit int, size func(T) int) [][]T {
+	maxRows := max(MaxPlaceholders/max(perRow, 1), 1)
+
+	var chunks [][]T
+	start, total := 0, 0
+	for i, row := range rows {
+		rowSize := size(row)
+		if i > start && (total+rowSize > limit || i-start >= maxRows) {
+			chunks = append(chunks, rows[start:i])
+			start, total = i, 0
+		}
+		total += rowSize
+	}
+	if start < len(rows) {
+		chunks = append(chunks, rows[start:])
+	}
+	return chunks
+}
+
+// RowSize returns the approximate bytes values take in a statement
+func RowSize(values []interface{}) int {
+	size := 0
+	for _, v := range values {
+		size += EstimateSize(v)
+	}
+	return size
+}
+
+// EstimateSize returns the approximate bytes a value takes in a statement
+func EstimateSize(v interface{}) int {
+	const overhead = 4 // Type and length prefixes, separators
+	switch x := v.(type) {
+	case string:
+		return len(x) + overhead
+	case []byte:
+		return len(x) + overhead
+	case money.Cents:
+		return 20 + overhead // Bound as a decimal string
+	case int, int64, float64:
+		return 8 + overhead
+	case time.Time:
+		return 12 + overhead
+	}
+	return 16 + overhead
+}
+
+// InsertStatement returns a multi-row INSERT of rows rows into the columns
+// of table, with a placeholder per value
+func InsertStatement(table string, columns []string, rows int) string {
+	placeholders := "(?" + strings.Repeat(", ?", len(columns)-1) + ")"
+
+	var sb strings.Builder
+	sb.WriteString("INSERT INTO " + table + " (" + strings.Join(columns, ", ") + ") VALUES ")
+	for i := range rows {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(placeholders)
+	}
+	return sb.String()
+}
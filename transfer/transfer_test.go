@@ -0,0 +1,100 @@
+package transfer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/waldirborbajr/sync/config"
+)
+
+func TestChunk(t *testing.T) {
+	sizes := func(n, size int) []int {
+		rows := make([]int, n)
+		for i := range rows {
+			rows[i] = size
+		}
+		return rows
+	}
+	tests := []struct {
+		name   string
+		rows   []int
+		perRow int
+		limit  int
+		want   []int // Rows per chunk
+	}{
+		{"empty", nil, 2, 100, nil},
+		{"one chunk", sizes(5, 10), 2, 100, []int{5}},
+		{"packet limit", sizes(5, 40), 2, 100, []int{2, 2, 1}},
+		{"oversized row alone", []int{10, 200, 10}, 2, 100, []int{1, 1, 1}},
+		{"placeholder limit", sizes(3, 1), MaxPlaceholders / 2, 1 << 20, []int{2, 1}},
+	}
+	for _, tt := range tests {
+		chunks := Chunk(tt.rows, tt.perRow, tt.limit, func(size int) int { return size })
+		var got []int
+		for _, c := range chunks {
+			got = append(got, len(c))
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: chunks of %v; want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: chunks of %v; want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestInsertStatement(t *testing.T) {
+	want := "INSERT INTO `shop`.`groups` (ID, NAME) VALUES (?, ?), (?, ?)"
+	if got := InsertStatement("`shop`.`groups`", []string{"ID", "NAME"}, 2); got != want {
+		t.Errorf("InsertStatement() = %q; want %q", got, want)
+	}
+}
+
+func TestRetrier(t *testing.T) {
+	deadlock := &mysql.MySQLError{Number: 1213, Message: "Deadlock found"}
+	syntax := &mysql.MySQLError{Number: 1064, Message: "You have an error in your SQL syntax"}
+	tests := []struct {
+		name      string
+		errs      []error // Returned by the successive attempts, then nil
+		attempts  int
+		wantErr   bool
+		retries   int
+		exhausted int
+	}{
+		{"success", nil, 2, false, 0, 0},
+		{"recovered", []error{deadlock}, 2, false, 1, 0},
+		{"exhausted", []error{deadlock, deadlock, deadlock}, 2, true, 2, 1},
+		{"not retryable", []error{syntax}, 2, true, 0, 0},
+		{"disabled", []error{deadlock}, 0, true, 0, 0},
+	}
+	for _, tt := range tests {
+		r := NewRetrier(config.Config{BatchRetries: tt.attempts, BatchRetryBackoff: time.Millisecond})
+		calls := 0
+		err := r.Do(context.Background(), "test", 1, func() error {
+			calls++
+			if calls <= len(tt.errs) {
+				return tt.errs[calls-1]
+			}
+			return nil
+		})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: Do() error = %v; want error %v", tt.name, err, tt.wantErr)
+		}
+		retries, exhausted, errs := r.Counts()
+		if got := retries["deadlock"]; got != tt.retries {
+			t.Errorf("%s: %d deadlock retries; want %d", tt.name, got, tt.retries)
+		}
+		if exhausted != tt.exhausted {
+			t.Errorf("%s: %d batches exhausted; want %d", tt.name, exhausted, tt.exhausted)
+		}
+		if got := errs["deadlock"] + errs["other"]; got != len(tt.errs) {
+			t.Errorf("%s: %d errors counted; want %d", tt.name, got, len(tt.errs))
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/waldirborbajr/sync/logger"
+	"github.com/waldirborbajr/sync/run"
+)
+
+// clearLine returns the cursor to the start of the line and clears it
+const clearLine = "\r\033[K"
+
+// Line keeps a single progress line of the MySQL preload and the processing
+// under the log lines of a run, until Stop is called
+type Line struct {
+	out      io.Writer
+	progress *run.Progress
+	stop     chan struct{}
+	done     chan struct{}
+	restore  func()
+
+	mu   sync.Mutex
+	text string // Line currently shown
+}
+
+// StartLine redraws the progress line of p on out every interval. Console
+// log lines are written above it.
+func StartLine(out io.Writer, p *run.Progress, interval time.Duration) *Line {
+	l := &Line{out: out, progress: p, stop: make(chan struct{}), done: make(chan struct{})}
+	l.restore = logger.SetConsole(l, zerolog.TraceLevel)
+
+	go func() {
+		defer close(l.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-l.stop:
+				return
+			case <-ticker.C:
+				l.draw(progressLine(p.Snapshot()))
+			}
+		}
+	}()
+	return l
+}
+
+// Stop clears the line and gives the console back to the log lines
+func (l *Line) Stop() {
+	close(l.stop)
+	<-l.done
+	l.draw("")
+	l.restore()
+}
+
+// Write writes console log lines above the progress line
+func (l *Line) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.text != "" {
+		_, _ = io.WriteString(l.out, clearLine)
+	}
+	n, err := l.out.Write(p)
+	if l.text = progressLine(l.progress.Snapshot()); l.text != "" {
+		_, _ = io.WriteString(l.out, l.text)
+	}
+	return n, err
+}
+
+// draw replaces the line shown with text
+func (l *Line) draw(text string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if text == l.text {
+		return
+	}
+	_, _ = io.WriteString(l.out, clearLine+text)
+	l.text = text
+}
+
+// progressLine describes the preload or processing under way, empty between them
+func progressLine(s run.Snapshot) string {
+	var ph *run.PhaseStatus
+	for _, phase := range []run.Phase{run.PhaseProcess, run.PhaseLoad} {
+		if st := s.Phases[phase]; st.Started && !st.Finished {
+			ph = &st
+			break
+		}
+	}
+	if ph == nil {
+		return ""
+	}
+
+	rate := 0.0
+	if ph.Elapsed > 0 {
+		rate = float64(ph.Done) / ph.Elapsed.Seconds()
+	}
+	if !estimated(*ph) {
+		return fmt.Sprintf("%s: %d rows, %.0f rows/s", ph.Phase, ph.Done, rate)
+	}
+	eta := "-"
+	if rate > 0 {
+		eta = time.Duration(float64(ph.Total-ph.Done) / rate * float64(time.Second)).Round(time.Second).String()
+	}
+	return fmt.Sprintf("%s: %3d%%  %d/%d rows, %.0f rows/s, ETA %s", ph.Phase, ph.Done*100/ph.Total, ph.Done, ph.Total, rate, eta)
+}
@@ -0,0 +1,168 @@
+// Package tui draws the live progress of an interactive run: a View with a
+// bar per phase, the row counters and the latest warnings and errors in place
+// of its log lines, or a single progress Line under them.
+package tui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/waldirborbajr/sync/logger"
+	"github.com/waldirborbajr/sync/run"
+)
+
+// barWidth is the number of cells of a progress bar
+const barWidth = 30
+
+// feedSize is the number of warnings and errors shown
+const feedSize = 5
+
+// lineWidth truncates the lines of the feed
+const lineWidth = 100
+
+// escapes matches the ANSI escape sequences of colored console lines
+var escapes = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+// View redraws the progress of a run on a terminal until Stop is called
+type View struct {
+	out      io.Writer
+	title    string
+	progress *run.Progress
+	started  time.Time
+	stop     chan struct{}
+	done     chan struct{}
+	restore  func()
+
+	mu    sync.Mutex
+	feed  []string // Latest warnings and errors, oldest first
+	lines int      // Lines of the last frame, redrawn over
+}
+
+// Interactive reports whether f is a terminal the view can draw on
+func Interactive(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// Start draws p to out every interval. Console log lines below warnings are
+// dropped and the others are shown in the feed until Stop.
+func Start(out io.Writer, title string, p *run.Progress, interval time.Duration) *View {
+	v := &View{out: out, title: title, progress: p, started: time.Now(), stop: make(chan struct{}), done: make(chan struct{})}
+	v.restore = logger.SetConsole(v, zerolog.WarnLevel)
+
+	go func() {
+		defer close(v.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			v.draw()
+			select {
+			case <-v.stop:
+				v.draw()
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return v
+}
+
+// Stop draws the final frame and gives the console back to the log lines
+func (v *View) Stop() {
+	close(v.stop)
+	<-v.done
+	v.restore()
+}
+
+// Write receives the formatted console log lines and keeps them in the feed
+func (v *View) Write(p []byte) (int, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for _, line := range strings.Split(strings.TrimRight(escapes.ReplaceAllString(string(p), ""), "\n"), "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		if len(line) > lineWidth {
+			line = line[:lineWidth-3] + "..."
+		}
+		v.feed = append(v.feed, line)
+	}
+	if len(v.feed) > feedSize {
+		v.feed = v.feed[len(v.feed)-feedSize:]
+	}
+	return len(p), nil
+}
+
+// draw replaces the previous frame with the current one
+func (v *View) draw() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	frame := render(v.title, time.Since(v.started), v.progress.Snapshot(), v.feed)
+
+	var b strings.Builder
+	if v.lines > 0 {
+		fmt.Fprintf(&b, "\033[%dA", v.lines) // Back to the first line of the last frame
+	}
+	b.WriteString("\033[J") // Clear it down to the end of the screen
+	b.WriteString(frame)
+	_, _ = io.WriteString(v.out, b.String())
+	v.lines = strings.Count(frame, "\n")
+}
+
+// render returns a frame of the view
+func render(title string, elapsed time.Duration, s run.Snapshot, feed []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s  %s\n\n", title, elapsed.Round(time.Second))
+	for _, ph := range s.Phases {
+		fmt.Fprintf(&b, "  %-10s %s  %s\n", ph.Phase, bar(ph), detail(ph))
+	}
+	fmt.Fprintf(&b, "\n  inserted %d   updated %d   unchanged %d\n", s.Inserted, s.Updated, s.Ignored)
+	if len(feed) > 0 {
+		b.WriteString("\n  warnings and errors:\n")
+		for _, line := range feed {
+			fmt.Fprintf(&b, "    %s\n", line)
+		}
+	}
+	return b.String()
+}
+
+// estimated reports whether the total of a phase is known and not yet
+// overtaken; totals are estimates, from the rows of the previous runs
+func estimated(ph run.PhaseStatus) bool {
+	return ph.Total > 0 && ph.Done <= ph.Total
+}
+
+// bar draws the completion of a phase; without an estimate, only a finished phase is filled
+func bar(ph run.PhaseStatus) string {
+	filled := 0
+	switch {
+	case ph.Finished:
+		filled = barWidth
+	case estimated(ph):
+		filled = int(ph.Done * barWidth / ph.Total)
+	}
+	return "[" + strings.Repeat("#", filled) + strings.Repeat(".", barWidth-filled) + "]"
+}
+
+// detail describes the state of a phase
+func detail(ph run.PhaseStatus) string {
+	if !ph.Started {
+		return "waiting"
+	}
+	elapsed := ph.Elapsed.Round(100 * time.Millisecond)
+	switch {
+	case ph.Finished && ph.Total == 0 && ph.Done == 0:
+		return fmt.Sprintf("done      %s", elapsed)
+	case ph.Finished:
+		return fmt.Sprintf("100%%  %d  %s", ph.Done, elapsed)
+	case estimated(ph):
+		return fmt.Sprintf("%3d%%  %d/%d  %s", ph.Done*100/ph.Total, ph.Done, ph.Total, elapsed)
+	}
+	return fmt.Sprintf("      %d  %s", ph.Done, elapsed)
+}
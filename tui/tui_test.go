@@ -0,0 +1,75 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/waldirborbajr/sync/run"
+)
+
+func TestBarAndDetail(t *testing.T) {
+	tests := []struct {
+		ph     run.PhaseStatus
+		filled int
+		detail string
+	}{
+		{run.PhaseStatus{Phase: run.PhaseLoad}, 0, "waiting"},
+		{run.PhaseStatus{Phase: run.PhaseLoad, Started: true, Done: 50, Total: 200, Elapsed: time.Second}, 7, " 25%  50/200  1s"},
+		{run.PhaseStatus{Phase: run.PhaseQuery, Started: true, Done: 250, Total: 200, Elapsed: time.Second}, 0, "      250  1s"},
+		{run.PhaseStatus{Phase: run.PhaseQuery, Started: true, Done: 42, Elapsed: 1500 * time.Millisecond}, 0, "      42  1.5s"},
+		{run.PhaseStatus{Phase: run.PhaseProcess, Started: true, Finished: true, Done: 200, Total: 180, Elapsed: 2 * time.Second}, barWidth, "100%  200  2s"},
+		{run.PhaseStatus{Phase: run.PhaseProcedure, Started: true, Finished: true, Elapsed: 300 * time.Millisecond}, barWidth, "done      300ms"},
+	}
+	for _, tt := range tests {
+		if got := strings.Count(bar(tt.ph), "#"); got != tt.filled {
+			t.Errorf("bar(%+v) fills %d cells; want %d", tt.ph, got, tt.filled)
+		}
+		if got := detail(tt.ph); got != tt.detail {
+			t.Errorf("detail(%+v) = %q; want %q", tt.ph, got, tt.detail)
+		}
+	}
+}
+
+func TestViewFeed(t *testing.T) {
+	v := &View{}
+	for i := 0; i < feedSize+2; i++ {
+		_, _ = v.Write([]byte("\x1b[90m2026-10-16T12:00:00Z\x1b[0m \x1b[33m[WRN]\x1b[0m Slow batch " + strings.Repeat("x", i) + "\n"))
+	}
+	if len(v.feed) != feedSize {
+		t.Fatalf("feed holds %d lines; want %d", len(v.feed), feedSize)
+	}
+	if want := "2026-10-16T12:00:00Z [WRN] Slow batch xx"; v.feed[0] != want {
+		t.Errorf("oldest feed line = %q; want %q", v.feed[0], want)
+	}
+
+	frame := render("sync run", time.Second, run.NewProgress().Snapshot(), v.feed)
+	for _, want := range []string{"load", "procedure", "inserted 0", "warnings and errors:", "[WRN] Slow batch xxxxxx"} {
+		if !strings.Contains(frame, want) {
+			t.Errorf("frame lacks %q:\n%s", want, frame)
+		}
+	}
+}
+
+func TestProgressLine(t *testing.T) {
+	phases := func(load, process run.PhaseStatus) run.Snapshot {
+		s := run.NewProgress().Snapshot()
+		load.Phase, process.Phase = run.PhaseLoad, run.PhaseProcess
+		s.Phases[run.PhaseLoad], s.Phases[run.PhaseProcess] = load, process
+		return s
+	}
+	tests := []struct {
+		s    run.Snapshot
+		want string
+	}{
+		{phases(run.PhaseStatus{}, run.PhaseStatus{}), ""},
+		{phases(run.PhaseStatus{Started: true, Done: 250, Total: 1000, Elapsed: time.Second}, run.PhaseStatus{}), "load:  25%  250/1000 rows, 250 rows/s, ETA 3s"},
+		{phases(run.PhaseStatus{Started: true, Finished: true, Done: 1000, Total: 1000}, run.PhaseStatus{Started: true, Done: 1500, Total: 1000, Elapsed: 3 * time.Second}), "process: 1500 rows, 500 rows/s"},
+		{phases(run.PhaseStatus{Started: true, Finished: true}, run.PhaseStatus{Started: true, Finished: true}), ""},
+	}
+	for _, tt := range tests {
+		if got := progressLine(tt.s); got != tt.want {
+			t.Errorf("progressLine() = %q; want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/logger"
+	"github.com/waldirborbajr/sync/run"
+)
+
+// idlePoll is how often InstallWhenIdle looks again at the runs in progress
+var idlePoll = time.Second
+
+// DeferredError is returned when runs in progress hold back an install; the
+// downloaded release is kept for the next attempt
+type DeferredError struct {
+	Runs []run.ActiveRun
+}
+
+func (e *DeferredError) Error() string {
+	return fmt.Sprintf("install deferred: run %s in progress", e.Runs[0].ID)
+}
+
+// InstallWhenIdle installs downloadPath once no run of reg is in progress,
+// so the executable is never replaced during a run. With ActiveRunsCancel the
+// runs are asked to stop first. Runs still going after wait leave the
+// install for later, a *DeferredError.
+func InstallWhenIdle(ctx context.Context, reg *run.Registry, downloadPath, policy string, wait time.Duration) error {
+	log := logger.GetLogger()
+	deadline := time.Now().Add(wait)
+	cancelled := make(map[string]bool)
+	for waiting := false; ; waiting = true {
+		release, err := reg.LockInstall()
+		if err != nil {
+			return err
+		}
+		runs, err := reg.Active()
+		if err == nil && len(runs) == 0 {
+			err = InstallUpdateWithContext(ctx, downloadPath)
+			release()
+			return err
+		}
+		// Released while waiting: deferred runs go on beginning, and cancelled
+		// ones must not wait for the lock to end
+		release()
+		if err != nil {
+			return err
+		}
+
+		if policy == config.ActiveRunsCancel {
+			for _, ar := range runs {
+				if cancelled[ar.ID] {
+					continue
+				}
+				if err := reg.Cancel(ar.ID); err != nil {
+					return err
+				}
+				cancelled[ar.ID] = true
+				log.Warn().Str("run_id", ar.ID).Int("pid", ar.PID).Msg("Cancelling the run in progress to install the update")
+			}
+		}
+		if !time.Now().Before(deadline) {
+			return &DeferredError{Runs: runs}
+		}
+		if !waiting {
+			log.Info().Int("runs", len(runs)).Str("policy", policy).Dur("wait", wait).Msg("Waiting for the runs in progress before installing the update")
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(min(idlePoll, time.Until(deadline))):
+		}
+	}
+}
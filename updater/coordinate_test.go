@@ -0,0 +1,28 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/run"
+)
+
+func TestInstallWhenIdleDefers(t *testing.T) {
+	for _, policy := range []string{config.ActiveRunsDefer, config.ActiveRunsCancel} {
+		reg := run.NewRegistry(t.TempDir())
+		_, end, err := reg.Begin(context.Background(), "run-1")
+		if err != nil {
+			t.Fatalf("Begin() error = %v", err)
+		}
+
+		// The run outlasts the wait: nothing is installed
+		err = InstallWhenIdle(context.Background(), reg, "release.bin", policy, 0)
+		var deferred *DeferredError
+		if !errors.As(err, &deferred) || deferred.Runs[0].ID != "run-1" {
+			t.Errorf("%s: InstallWhenIdle() error = %v; want deferred by run-1", policy, err)
+		}
+		end()
+	}
+}
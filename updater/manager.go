@@ -5,10 +5,12 @@ import (
 
 	"github.com/waldirborbajr/sync/config"
 	"github.com/waldirborbajr/sync/logger"
+	"github.com/waldirborbajr/sync/run"
 )
 
-// RunUpdateFlow faz a checagem de versão e faz o download se configurado
-func RunUpdateFlow(ctx context.Context, currentVersion string, cfg config.Config) (downloaded bool, filePath string, info UpdateInfo, err error) {
+// RunUpdateFlow faz a checagem de versão e faz o download se configurado;
+// a instalação espera as execuções em andamento de reg (UPDATE_ACTIVE_RUNS)
+func RunUpdateFlow(ctx context.Context, currentVersion string, cfg config.Config, reg *run.Registry) (downloaded bool, filePath string, info UpdateInfo, err error) {
 	log := logger.GetLogger()
 	isNew, info, err := CheckForUpdateWithContext(ctx, currentVersion, cfg)
 	if err != nil {
@@ -26,7 +28,7 @@ func RunUpdateFlow(ctx context.Context, currentVersion string, cfg config.Config
 			return false, "", info, err
 		}
 		log.Info().Msg("Installing update...")
-		if err := InstallUpdateWithContext(ctx, path); err != nil {
+		if err := InstallWhenIdle(ctx, reg, path, cfg.UpdateActiveRuns, cfg.UpdateActiveRunsWait); err != nil {
 			return true, path, info, err
 		}
 		return true, path, info, nil
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/waldirborbajr/sync/config"
+	"github.com/waldirborbajr/sync/db"
+	"github.com/waldirborbajr/sync/output"
+	"github.com/waldirborbajr/sync/processor"
+)
+
+// verifyUsage documents the verify subcommand
+const verifyUsage = "verify [--tolerance AMOUNT] [--max-drift ROWS]"
+
+// verifyDriftLimit is how many drifted rows the table output lists
+const verifyDriftLimit = 50
+
+// verifySummary is the summary of "sync verify"
+type verifySummary struct {
+	SourceRows int           `json:"source_rows" yaml:"source_rows"`
+	StoredRows int           `json:"stored_rows" yaml:"stored_rows"`
+	Matching   int           `json:"matching" yaml:"matching"`
+	Skipped    int           `json:"skipped" yaml:"skipped"`
+	Missing    int           `json:"missing" yaml:"missing"`       // In Firebird, not in MySQL
+	Orphaned   int           `json:"orphaned" yaml:"orphaned"`     // In MySQL, not in Firebird
+	Mismatched int           `json:"mismatched" yaml:"mismatched"` // Rows with columns differing beyond the tolerance
+	Drift      int           `json:"drift" yaml:"drift"`
+	MaxDrift   int           `json:"max_drift" yaml:"max_drift"`
+	Tolerance  float64       `json:"tolerance" yaml:"tolerance"`
+	Passed     bool          `json:"passed" yaml:"passed"`
+	Duration   time.Duration `json:"duration" yaml:"duration"`
+}
+
+// verifyDrift is a drifted row, or column of a row, of "sync verify"
+type verifyDrift struct {
+	IDEstoque int    `json:"id_estoque" yaml:"id_estoque"`
+	Kind      string `json:"kind" yaml:"kind"` // missing, orphaned or mismatch
+	Column    string `json:"column,omitempty" yaml:"column,omitempty"`
+	Stored    string `json:"stored,omitempty" yaml:"stored,omitempty"`
+	Expected  string `json:"expected,omitempty" yaml:"expected,omitempty"`
+}
+
+// verifyInfo is the output of "sync verify" in json and yaml
+type verifyInfo struct {
+	Summary verifySummary `json:"summary" yaml:"summary"`
+	Drift   []verifyDrift `json:"drift" yaml:"drift"`
+}
+
+// verifyCommand compares Firebird with MySQL without writing and reports the
+// rows out of sync, exiting with exitDrift when there are more than --max-drift
+func verifyCommand(env *commandEnv) int {
+	tolerance, maxDrift, err := parseVerifyArgs(env.args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\nusage: sync %s\n", redBold, reset, err, verifyUsage)
+		return 2
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+		return exitConfig
+	}
+	firebirdConn, err := db.ConnectFirebird(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+		return 1
+	}
+	defer func() { _ = firebirdConn.Close() }()
+	mysqlConn, err := db.ConnectMySQL(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+		return 1
+	}
+	defer func() { _ = mysqlConn.Close() }()
+
+	r, err := processor.Verify(context.Background(), firebirdConn, mysqlConn, cfg, tolerance)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError:%s %v\n", redBold, reset, err)
+		return 1
+	}
+
+	info := verifyInfo{
+		Summary: verifySummary{
+			SourceRows: r.SourceRows,
+			StoredRows: r.StoredRows,
+			Matching:   r.Matching,
+			Skipped:    r.Skipped,
+			Missing:    len(r.Missing),
+			Orphaned:   len(r.Orphaned),
+			Mismatched: r.Mismatched,
+			Drift:      r.Drift(),
+			MaxDrift:   maxDrift,
+			Tolerance:  tolerance,
+			Passed:     r.Drift() <= maxDrift,
+			Duration:   r.Duration.Round(time.Millisecond),
+		},
+		Drift: make([]verifyDrift, 0, len(r.Missing)+len(r.Orphaned)+len(r.Mismatches)),
+	}
+	for _, key := range r.Missing {
+		info.Drift = append(info.Drift, verifyDrift{IDEstoque: key, Kind: "missing"})
+	}
+	for _, key := range r.Orphaned {
+		info.Drift = append(info.Drift, verifyDrift{IDEstoque: key, Kind: "orphaned"})
+	}
+	for _, m := range r.Mismatches {
+		info.Drift = append(info.Drift, verifyDrift{IDEstoque: m.IDEstoque, Kind: "mismatch", Column: m.Column, Stored: m.Stored, Expected: m.Expected})
+	}
+
+	code := renderVerify(env, info)
+	if code == 0 && !info.Summary.Passed {
+		return exitDrift
+	}
+	return code
+}
+
+// renderVerify prints the verification: in table format the summary, then
+// the first verifyDriftLimit drifted rows
+func renderVerify(env *commandEnv, info verifyInfo) int {
+	if env.output != output.FormatTable {
+		return env.render(info)
+	}
+	if code := env.render(info.Summary); code != 0 || len(info.Drift) == 0 {
+		return code
+	}
+	fmt.Println()
+	if code := env.render(info.Drift[:min(len(info.Drift), verifyDriftLimit)]); code != 0 {
+		return code
+	}
+	if more := len(info.Drift) - verifyDriftLimit; more > 0 {
+		fmt.Printf("... and %d more (-o json lists them all)\n", more)
+	}
+	return 0
+}
+
+// parseVerifyArgs reads the --tolerance and --max-drift flags of args
+func parseVerifyArgs(args []string) (tolerance float64, maxDrift int, err error) {
+	for i := 0; i < len(args); i++ {
+		name, value, inline := strings.Cut(args[i], "=")
+		if name != "--tolerance" && name != "--max-drift" {
+			return 0, 0, fmt.Errorf("unexpected argument %q", args[i])
+		}
+		if !inline {
+			if i+1 >= len(args) {
+				return 0, 0, fmt.Errorf("%s requires a value", name)
+			}
+			i++
+			value = args[i]
+		}
+
+		if name == "--tolerance" {
+			if tolerance, err = strconv.ParseFloat(value, 64); err != nil || tolerance < 0 {
+				return 0, 0, fmt.Errorf("invalid --tolerance %q: expected a non-negative amount, e.g. 0.01", value)
+			}
+		} else if maxDrift, err = strconv.Atoi(value); err != nil || maxDrift < 0 {
+			return 0, 0, fmt.Errorf("invalid --max-drift %q: expected a non-negative number of rows", value)
+		}
+	}
+	return tolerance, maxDrift, nil
+}
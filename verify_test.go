@@ -0,0 +1,35 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/waldirborbajr/sync/output"
+)
+
+func TestVerifyMaxDrift(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv("DEV_MODE", "true")
+	if err := os.WriteFile(".env", []byte("DEV_MODE=true\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The 6 active products of the Firebird mock are missing from the empty MySQL mock
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{[]string{"--max-drift", "6"}, 0},
+		{[]string{"--max-drift=7"}, 0},
+		{[]string{"--max-drift", "5"}, exitDrift},
+		{nil, exitDrift},
+		{[]string{"--max-drift", "-1"}, 2},
+		{[]string{"--max-drift"}, 2},
+	}
+	for _, tt := range tests {
+		env := &commandEnv{args: tt.args, output: output.FormatJSON}
+		if got := verifyCommand(env); got != tt.want {
+			t.Errorf("verify %v = %d; want %d", tt.args, got, tt.want)
+		}
+	}
+}